@@ -446,6 +446,136 @@ func init() {
 		return nil
 	})
 
+	type BucketSetLifecycleOption struct {
+		ID             string `help:"ID or name of bucket" json:"-"`
+		RuleId         string
+		Prefix         string
+		Disable        bool `help:"add the rule disabled"`
+		ExpirationDays int  `help:"delete objects days after last modified"`
+		// 未完成分片上传的过期天数
+		AbortMultipartDays     int    `help:"abort incomplete multipart uploads days after initiation"`
+		TransitionDays         int    `help:"transit objects days after last modified"`
+		TransitionStorageClass string `help:"storage class objects transit to"`
+	}
+	R(&BucketSetLifecycleOption{}, "bucket-set-lifecycle", "Set bucket lifecycle, replace the existing rules", func(s *mcclient.ClientSession, args *BucketSetLifecycleOption) error {
+		rule := api.BucketLifecycleRule{
+			Id:                                 args.RuleId,
+			Prefix:                             args.Prefix,
+			Enabled:                            !args.Disable,
+			ExpirationDays:                     args.ExpirationDays,
+			AbortIncompleteMultipartUploadDays: args.AbortMultipartDays,
+		}
+		if args.TransitionDays > 0 {
+			rule.Transitions = []api.BucketLifecycleTransition{
+				{
+					Days:         args.TransitionDays,
+					StorageClass: args.TransitionStorageClass,
+				},
+			}
+		}
+		rules := api.BucketLifecycleRules{Data: []api.BucketLifecycleRule{rule}}
+		result, err := modules.Buckets.PerformAction(s, args.ID, "set-lifecycle", jsonutils.Marshal(rules))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type BucketGetLifecycleOption struct {
+		ID string `help:"ID or name of bucket" json:"-"`
+	}
+	R(&BucketGetLifecycleOption{}, "bucket-get-lifecycle", "Get bucket lifecycle", func(s *mcclient.ClientSession, args *BucketGetLifecycleOption) error {
+		result, err := modules.Buckets.GetSpecific(s, args.ID, "lifecycle", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&BucketGetLifecycleOption{}, "bucket-delete-lifecycle", "Delete bucket lifecycle", func(s *mcclient.ClientSession, args *BucketGetLifecycleOption) error {
+		result, err := modules.Buckets.PerformAction(s, args.ID, "delete-lifecycle", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type BucketSetVersioningOption struct {
+		ID     string `help:"ID or name of bucket" json:"-"`
+		STATUS string `help:"versioning status" choices:"Enabled|Suspended" json:"status"`
+	}
+	R(&BucketSetVersioningOption{}, "bucket-set-versioning", "Enable or suspend bucket versioning", func(s *mcclient.ClientSession, args *BucketSetVersioningOption) error {
+		result, err := modules.Buckets.PerformAction(s, args.ID, "set-versioning", jsonutils.Marshal(args))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type BucketGetVersioningOption struct {
+		ID string `help:"ID or name of bucket" json:"-"`
+	}
+	R(&BucketGetVersioningOption{}, "bucket-get-versioning", "Get bucket versioning status", func(s *mcclient.ClientSession, args *BucketGetVersioningOption) error {
+		result, err := modules.Buckets.GetSpecific(s, args.ID, "versioning", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	type BucketListObjectVersionsOptions struct {
+		ID              string `help:"ID or name of bucket" json:"-"`
+		Prefix          string `help:"List versions of objects with prefix"`
+		KeyMarker       string `help:"paging key marker"`
+		VersionIdMarker string `help:"paging version id marker"`
+		Limit           int    `help:"maximal items per request"`
+	}
+	R(&BucketListObjectVersionsOptions{}, "bucket-object-version-list", "List object versions in a bucket", func(s *mcclient.ClientSession, args *BucketListObjectVersionsOptions) error {
+		params, err := options.StructToParams(args)
+		if err != nil {
+			return err
+		}
+		result, err := modules.Buckets.GetSpecific(s, args.ID, "object-versions", params)
+		if err != nil {
+			return err
+		}
+		listResult := modulebase.ListResult{}
+		err = result.Unmarshal(&listResult)
+		if err != nil {
+			return err
+		}
+		printList(&listResult, []string{})
+		return nil
+	})
+
+	type BucketObjectVersionOptions struct {
+		ID        string `help:"ID or name of bucket" json:"-"`
+		KEY       string `help:"Key of object" json:"key"`
+		VERSIONID string `help:"Version id of object" json:"version_id"`
+	}
+	R(&BucketObjectVersionOptions{}, "bucket-object-version-delete", "Permanently delete a version of object", func(s *mcclient.ClientSession, args *BucketObjectVersionOptions) error {
+		result, err := modules.Buckets.PerformAction(s, args.ID, "delete-object-version", jsonutils.Marshal(args))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&BucketObjectVersionOptions{}, "bucket-object-version-restore", "Restore a version of object as the latest one", func(s *mcclient.ClientSession, args *BucketObjectVersionOptions) error {
+		result, err := modules.Buckets.PerformAction(s, args.ID, "restore-object-version", jsonutils.Marshal(args))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
 	type BucketSetRefererOption struct {
 		ID string `help:"ID or name of bucket" json:"-"`
 		// 域名列表
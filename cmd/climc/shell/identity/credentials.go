@@ -20,6 +20,7 @@ import (
 
 	"yunion.io/x/jsonutils"
 
+	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
 	"yunion.io/x/onecloud/pkg/mcclient/modules"
@@ -28,7 +29,7 @@ import (
 func init() {
 	type CredentialListOptions struct {
 		Scope      string `help:"scope" choices:"project|domain|system"`
-		Type       string `help:"credential type" choices:"totp|recovery|aksk"`
+		Type       string `help:"credential type" choices:"totp|recovery|aksk|app_cred"`
 		User       string `help:"filter by user"`
 		UserDomain string `help:"the domain of user"`
 	}
@@ -237,6 +238,87 @@ func init() {
 		return nil
 	})
 
+	type AppCredentialCreateOptions struct {
+		CredentialAkSkOptions
+		Name          string   `help:"name of the application credential"`
+		Role          []string `help:"roles granted to the credential, must be assigned to the user in the project"`
+		AllowedAction []string `help:"allowed policy actions in the form of <service>/<resource>/<action>, e.g. compute/servers/list"`
+		AllowedIp     []string `help:"allowed source ips or CIDRs"`
+		ExpireHours   int      `help:"expire after the hours, never expire if not specified"`
+	}
+	R(&AppCredentialCreateOptions{}, "credential-create-app-cred", "Create application credential with a subset of roles of the user in the project", func(s *mcclient.ClientSession, args *AppCredentialCreateOptions) error {
+		var uid string
+		var pid string
+		var err error
+		if len(args.User) > 0 {
+			uid, err = modules.UsersV3.FetchId(s, args.User, args.UserDomain)
+			if err != nil {
+				return err
+			}
+		}
+		if len(args.Project) > 0 {
+			pid, err = modules.Projects.FetchId(s, args.Project, args.ProjectDomain)
+			if err != nil {
+				return err
+			}
+		}
+		blob := api.SAppCredentialBlob{
+			Roles:          args.Role,
+			AllowedActions: args.AllowedAction,
+			AllowedIps:     args.AllowedIp,
+		}
+		if args.ExpireHours > 0 {
+			blob.Expire = time.Now().Add(time.Duration(args.ExpireHours) * time.Hour).Unix()
+		}
+		cred, err := modules.Credentials.CreateAppCredential(s, uid, pid, args.Name, blob)
+		if err != nil {
+			return err
+		}
+		printObject(jsonutils.Marshal(&cred))
+		return nil
+	})
+
+	R(&CredentialAkSkOptions{}, "credential-get-app-cred", "Get application credentials for user and project", func(s *mcclient.ClientSession, args *CredentialAkSkOptions) error {
+		var uid string
+		var err error
+		if len(args.User) > 0 {
+			uid, err = modules.UsersV3.FetchId(s, args.User, args.UserDomain)
+			if err != nil {
+				return err
+			}
+		}
+		var pid string
+		if len(args.Project) > 0 {
+			pid, err = modules.Projects.FetchId(s, args.Project, args.ProjectDomain)
+			if err != nil {
+				return err
+			}
+		}
+		creds, err := modules.Credentials.GetAppCredentials(s, uid, pid)
+		if err != nil {
+			return err
+		}
+		result := modulebase.ListResult{}
+		result.Data = make([]jsonutils.JSONObject, len(creds))
+		for i := range creds {
+			result.Data[i] = jsonutils.Marshal(creds[i])
+		}
+		printList(&result, nil)
+		return nil
+	})
+
+	type AppCredentialRevokeOptions struct {
+		ID string `help:"id of the application credential"`
+	}
+	R(&AppCredentialRevokeOptions{}, "credential-revoke-app-cred", "Revoke application credential", func(s *mcclient.ClientSession, args *AppCredentialRevokeOptions) error {
+		err := modules.Credentials.RevokeAppCredential(s, args.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Println("success")
+		return nil
+	})
+
 	type OIDCCredentialOptions struct {
 		User          string `help:"User"`
 		UserDomain    string `help:"domain of user"`
@@ -87,6 +87,10 @@ func init() {
 		Disabled bool   `help:"Set the domain disabled"`
 
 		Displayname string `help:"display name"`
+
+		EnableMfa  bool   `help:"enforce mfa on all users of the domain"`
+		DisableMfa bool   `help:"do not enforce mfa on all users of the domain"`
+		MfaMode    string `help:"default mfa mode of the users of the domain" choices:"totp|webauthn|any|all"`
 	}
 	R(&DomainCreateOptions{}, "domain-create", "Create a new domain", func(s *mcclient.ClientSession, args *DomainCreateOptions) error {
 		params := jsonutils.NewDict()
@@ -102,6 +106,14 @@ func init() {
 		if len(args.Displayname) > 0 {
 			params.Add(jsonutils.NewString(args.Displayname), "displayname")
 		}
+		if args.EnableMfa {
+			params.Add(jsonutils.JSONTrue, "enable_mfa")
+		} else if args.DisableMfa {
+			params.Add(jsonutils.JSONFalse, "enable_mfa")
+		}
+		if len(args.MfaMode) > 0 {
+			params.Add(jsonutils.NewString(args.MfaMode), "mfa_mode")
+		}
 		result, err := modules.Domains.Create(s, params)
 		if err != nil {
 			return err
@@ -119,6 +131,10 @@ func init() {
 		Driver   string `help:"Set the domain Driver"`
 
 		Displayname string `help:"display name"`
+
+		EnableMfa  bool   `help:"enforce mfa on all users of the domain"`
+		DisableMfa bool   `help:"do not enforce mfa on all users of the domain"`
+		MfaMode    string `help:"default mfa mode of the users of the domain" choices:"totp|webauthn|any|all"`
 	}
 	R(&DomainUpdateOptions{}, "domain-update", "Update a domain", func(s *mcclient.ClientSession, args *DomainUpdateOptions) error {
 		obj, err := modules.Domains.Get(s, args.ID, nil)
@@ -148,6 +164,14 @@ func init() {
 		if len(args.Displayname) > 0 {
 			params.Add(jsonutils.NewString(args.Displayname), "displayname")
 		}
+		if args.EnableMfa {
+			params.Add(jsonutils.JSONTrue, "enable_mfa")
+		} else if args.DisableMfa {
+			params.Add(jsonutils.JSONFalse, "enable_mfa")
+		}
+		if len(args.MfaMode) > 0 {
+			params.Add(jsonutils.NewString(args.MfaMode), "mfa_mode")
+		}
 		result, err := modules.Domains.Patch(s, objId, params)
 		if err != nil {
 			return err
@@ -117,6 +117,24 @@ func init() {
 		return nil
 	})
 
+	R(&IdentityProviderDetailOptions{}, "idp-enable-scim", "Enable SCIM provisioning of an identity provider and renew its bearer token", func(s *mcclient.ClientSession, args *IdentityProviderDetailOptions) error {
+		result, err := modules.IdentityProviders.PerformAction(s, args.ID, "enable-scim", nil)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&IdentityProviderDetailOptions{}, "idp-disable-scim", "Disable SCIM provisioning of an identity provider", func(s *mcclient.ClientSession, args *IdentityProviderDetailOptions) error {
+		idp, err := modules.IdentityProviders.PerformAction(s, args.ID, "disable-scim", nil)
+		if err != nil {
+			return err
+		}
+		printObject(idp)
+		return nil
+	})
+
 	type IdentityProviderConfigLDAPOptions struct {
 		ID string `help:"ID of idp to config" json:"-"`
 		api.SLDAPIdpConfigOptions
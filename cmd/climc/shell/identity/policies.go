@@ -23,6 +23,7 @@ import (
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/timeutils"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
@@ -346,6 +347,91 @@ func init() {
 		return nil
 	})
 
+	type PolicySimulateOptions struct {
+		User      string   `help:"user id or name, the roles of the user in the project are simulated if no role is specified"`
+		Project   string   `help:"project id or name"`
+		Role      []string `help:"role id or name"`
+		Group     []string `help:"group id or name the user belongs to, default to the groups of the user"`
+		Ip        string   `help:"login IP"`
+		Time      string   `help:"time of the requests, e.g. 2020-06-01T10:00:00Z"`
+		Tag       []string `help:"metadata tag of the target object, in the format of key=value"`
+		Draft     string   `help:"path to a draft policy file to simulate with"`
+		DraftName string   `help:"name of the draft policy"`
+		Scope     string   `help:"scope of the draft policy" choices:"system|domain|project"`
+		Replace   string   `help:"id or name of the policy replaced by the draft"`
+		REQUEST   []string `help:"request to simulate, in the format of scope:service:resource:action:extra"`
+	}
+	R(&PolicySimulateOptions{}, "policy-simulate", "Simulate the policy results of requests for a user or roles", func(s *mcclient.ClientSession, args *PolicySimulateOptions) error {
+		input := api.PolicySimulateInput{
+			UserId:    args.User,
+			ProjectId: args.Project,
+			RoleIds:   args.Role,
+			Groups:    args.Group,
+			LoginIp:   args.Ip,
+		}
+		if len(args.Time) > 0 {
+			tm, err := timeutils.ParseTimeStr(args.Time)
+			if err != nil {
+				return fmt.Errorf("invalid time %s: %s", args.Time, err)
+			}
+			input.Time = tm
+		}
+		var tags map[string]string
+		for _, tag := range args.Tag {
+			idx := strings.Index(tag, "=")
+			if idx <= 0 {
+				return fmt.Errorf("invalid tag %s, should be in the form of key=value", tag)
+			}
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[tag[:idx]] = tag[idx+1:]
+		}
+		for _, reqStr := range args.REQUEST {
+			parts := strings.Split(reqStr, ":")
+			if len(parts) < 2 {
+				return fmt.Errorf("invalid request %s, should be in the form of [system|domain|project]:service[:resource:action:extra]", reqStr)
+			}
+			req := api.PolicySimulateRequest{
+				Scope:   rbacutils.TRbacScope(parts[0]),
+				Service: parts[1],
+				Tags:    tags,
+			}
+			if len(parts) > 2 {
+				req.Resource = parts[2]
+			}
+			if len(parts) > 3 {
+				req.Action = parts[3]
+			}
+			if len(parts) > 4 {
+				req.Extra = parts[4:]
+			}
+			input.Requests = append(input.Requests, req)
+		}
+		if len(args.Draft) > 0 {
+			policyBytes, err := ioutil.ReadFile(args.Draft)
+			if err != nil {
+				return err
+			}
+			blob, err := jsonutils.ParseYAML(string(policyBytes))
+			if err != nil {
+				return fmt.Errorf("parse draft policy %s: %s", args.Draft, err)
+			}
+			input.Draft = &api.PolicySimulateDraft{
+				Name:    args.DraftName,
+				Scope:   rbacutils.TRbacScope(args.Scope),
+				Blob:    blob,
+				Replace: args.Replace,
+			}
+		}
+		result, err := modules.Policies.PerformClassAction(s, "simulate", jsonutils.Marshal(input))
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
 	type PolicyExplainOptions struct {
 		User          string   `help:"For user"`
 		UserDomain    string   `help:"Domain for user"`
@@ -168,9 +168,10 @@ func init() {
 		SkipPasswordComplexityCheck bool `help:"do password complexity check, default is false"`
 
 		// DefaultProject string `help:"Default project"`
-		SystemAccount bool `help:"is a system account?"`
-		NoWebConsole  bool `help:"allow web console access"`
-		EnableMfa     bool `help:"enable TOTP mfa"`
+		SystemAccount bool   `help:"is a system account?"`
+		NoWebConsole  bool   `help:"allow web console access"`
+		EnableMfa     bool   `help:"enable TOTP mfa"`
+		MfaMode       string `help:"mfa mode, default is the mfa mode of the domain" choices:"totp|webauthn|any|all"`
 
 		IdpId       string `help:"Id of identity provider to link with"`
 		IdpEntityId string `help:"Entity id of identity provider to link with"`
@@ -220,6 +221,9 @@ func init() {
 		if args.EnableMfa {
 			params.Add(jsonutils.JSONTrue, "enable_mfa")
 		}
+		if len(args.MfaMode) > 0 {
+			params.Add(jsonutils.NewString(args.MfaMode), "mfa_mode")
+		}
 
 		if len(args.IdpId) > 0 {
 			params.Add(jsonutils.NewString(args.IdpId), "idp_id")
@@ -267,6 +271,9 @@ func init() {
 		EnableMfa  bool `help:"turn on enable_mfa"`
 		DisableMfa bool `help:"turn off enable_mfa"`
 
+		MfaMode      string `help:"mfa mode" choices:"totp|webauthn|any|all"`
+		ClearMfaMode bool   `help:"clear mfa mode of the user to follow the mfa mode of the domain"`
+
 		// DefaultProject string `help:"Default project"`
 		// Option []string `help:"User options"`
 
@@ -329,6 +336,11 @@ func init() {
 		} else if args.DisableMfa {
 			params.Add(jsonutils.JSONFalse, "enable_mfa")
 		}
+		if len(args.MfaMode) > 0 {
+			params.Add(jsonutils.NewString(args.MfaMode), "mfa_mode")
+		} else if args.ClearMfaMode {
+			params.Add(jsonutils.NewString(""), "mfa_mode")
+		}
 		if len(args.Lang) > 0 {
 			params.Add(jsonutils.NewString(args.Lang), "lang")
 		}
@@ -15,6 +15,8 @@
 package misc
 
 import (
+	"fmt"
+
 	"yunion.io/x/jsonutils"
 
 	"yunion.io/x/onecloud/pkg/mcclient"
@@ -70,4 +72,30 @@ func init() {
 		printObject(result)
 		return nil
 	})
+
+	type TaskCancelOptions struct {
+		ID     string `help:"ID of the task"`
+		Reason string `help:"reason of the cancellation"`
+	}
+	R(&TaskCancelOptions{}, "region-task-cancel", "Cancel a running region task and its subtasks", func(s *mcclient.ClientSession, args *TaskCancelOptions) error {
+		params := jsonutils.NewDict()
+		if len(args.Reason) > 0 {
+			params.Add(jsonutils.NewString(args.Reason), "reason")
+		}
+		result, err := modules.ComputeTasks.PerformAction(s, args.ID, "cancel", params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
+
+	R(&TaskShowOptions{}, "region-task-tree", "Show the parent and subtasks hierarchy of a region task", func(s *mcclient.ClientSession, args *TaskShowOptions) error {
+		result, err := modules.ComputeTasks.GetSpecific(s, args.ID, "tree", nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println(result.PrettyString())
+		return nil
+	})
 }
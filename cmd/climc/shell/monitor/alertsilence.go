@@ -0,0 +1,19 @@
+package monitor
+
+import (
+	"yunion.io/x/onecloud/cmd/climc/shell"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	baseoptions "yunion.io/x/onecloud/pkg/mcclient/options"
+	options "yunion.io/x/onecloud/pkg/mcclient/options/monitor"
+)
+
+func init() {
+	cmd := shell.NewResourceCmd(modules.AlertSilenceManager)
+	cmd.List(new(options.AlertSilenceListOptions))
+	cmd.Create(new(options.AlertSilenceCreateOptions))
+	cmd.Show(new(baseoptions.BaseShowOptions))
+	cmd.Update(new(options.AlertSilenceUpdateOptions))
+	cmd.Delete(new(baseoptions.BaseIdOptions))
+	cmd.Perform("enable", new(baseoptions.BaseIdOptions))
+	cmd.Perform("disable", new(baseoptions.BaseIdOptions))
+}
@@ -35,14 +35,22 @@ import (
 	"yunion.io/x/pkg/errors"
 
 	"yunion.io/x/onecloud/pkg/apigateway/options"
+	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/util/webauthnutils"
 )
 
 const (
 	TotpEnable  = '1'
 	TotpDisable = '0'
+
+	WebAuthnChallengeCreate = 'c'
+	WebAuthnChallengeGet    = 'g'
+
+	// the length of the fixed fields before the token
+	authTokenHeaderLength = 50
 )
 
 var (
@@ -62,6 +70,40 @@ type SAuthToken struct {
 
 	retryCount     int    // 重试计数器
 	lockExpireTime uint32 // 锁定时间
+
+	mfaMode        string
+	initWebAuthn   bool
+	verifyWebAuthn bool
+
+	// the challenge of the ongoing WebAuthn ceremony
+	challengeType   byte
+	challengeExpire uint32
+	challenge       [webauthnutils.CHALLENGE_LENGTH]byte
+}
+
+func encodeBool(msg *bytes.Buffer, v bool) {
+	if v {
+		msg.WriteByte(TotpEnable)
+	} else {
+		msg.WriteByte(TotpDisable)
+	}
+}
+
+func encodeMfaMode(mode string) byte {
+	for i := range api.MFA_MODES {
+		if api.MFA_MODES[i] == mode {
+			return byte('a' + i)
+		}
+	}
+	return TotpDisable
+}
+
+func decodeMfaMode(b byte) string {
+	idx := int(b) - 'a'
+	if idx >= 0 && idx < len(api.MFA_MODES) {
+		return api.MFA_MODES[idx]
+	}
+	return ""
 }
 
 func (t SAuthToken) encodeBytes() []byte {
@@ -91,6 +133,17 @@ func (t SAuthToken) encodeBytes() []byte {
 	expBytes := make([]byte, 4)
 	binary.LittleEndian.PutUint32(expBytes, t.lockExpireTime)
 	msg.Write(expBytes)
+	msg.WriteByte(encodeMfaMode(t.mfaMode))
+	encodeBool(&msg, t.initWebAuthn)
+	encodeBool(&msg, t.verifyWebAuthn)
+	if t.challengeType == 0 {
+		msg.WriteByte(TotpDisable)
+	} else {
+		msg.WriteByte(t.challengeType)
+	}
+	binary.LittleEndian.PutUint32(expBytes, t.challengeExpire)
+	msg.Write(expBytes)
+	msg.Write(t.challenge[:])
 	msg.WriteString(t.token)
 	return msg.Bytes()
 }
@@ -123,7 +176,7 @@ func Decode(t string) (*SAuthToken, error) {
 
 func decodeBytes(tt []byte) (*SAuthToken, error) {
 	ret := SAuthToken{}
-	if len(tt) < 10 {
+	if len(tt) < authTokenHeaderLength {
 		return nil, errors.Wrap(errors.ErrInvalidStatus, "too short")
 	}
 	if tt[0] == TotpEnable {
@@ -149,7 +202,15 @@ func decodeBytes(tt []byte) (*SAuthToken, error) {
 	// 4: skip rand number
 	ret.retryCount = int(tt[5])
 	ret.lockExpireTime = binary.LittleEndian.Uint32(tt[6:])
-	ret.token = string(tt[10:])
+	ret.mfaMode = decodeMfaMode(tt[10])
+	ret.initWebAuthn = tt[11] == TotpEnable
+	ret.verifyWebAuthn = tt[12] == TotpEnable
+	if tt[13] == WebAuthnChallengeCreate || tt[13] == WebAuthnChallengeGet {
+		ret.challengeType = tt[13]
+	}
+	ret.challengeExpire = binary.LittleEndian.Uint32(tt[14:])
+	copy(ret.challenge[:], tt[18:authTokenHeaderLength])
+	ret.token = string(tt[authTokenHeaderLength:])
 	return &ret, nil
 }
 
@@ -199,19 +260,50 @@ func (t SAuthToken) GetAuthCookie(token mcclient.TokenCredential) string {
 	info.Add(jsonutils.NewBool(t.enableTotp), "totp_on")                      // 用户totp 开启状态。 True（已开启）|False(未开启)
 	info.Add(jsonutils.NewBool(t.isSsoLogin), "is_sso")                       // 用户是否通过SSO登录
 	info.Add(jsonutils.NewBool(options.Options.EnableTotp), "system_totp_on") // 全局totp 开启状态。 True（已开启）|False(未开启)
+	info.Add(jsonutils.NewString(t.GetMfaMode()), "mfa_mode")                 // MFA认证方式 totp|webauthn|any|all
+	info.Add(jsonutils.NewBool(t.verifyWebAuthn), "webauthn_verified")        // 用户WebAuthn硬件密钥验证通过
+	info.Add(jsonutils.NewBool(t.initWebAuthn), "webauthn_init")              // 是否注册了WebAuthn硬件密钥
 	info.Add(jsonutils.NewString(token.GetUserId()), "user_id")
 	info.Add(jsonutils.NewString(token.GetUserName()), "user")
 	return info.String()
 }
 
-func (t SAuthToken) IsTotpVerified() bool {
+// MfaModeSatisfied reports whether the factors verified satisfy the MFA mode
+func MfaModeSatisfied(mode string, totpVerified, webAuthnVerified bool) bool {
+	switch mode {
+	case api.MFA_MODE_WEBAUTHN:
+		return webAuthnVerified
+	case api.MFA_MODE_ANY:
+		return totpVerified || webAuthnVerified
+	case api.MFA_MODE_ALL:
+		return totpVerified && webAuthnVerified
+	default:
+		return totpVerified
+	}
+}
+
+// IsMfaVerified reports whether the user passes the second factor
+// authentications required by the MFA mode
+func (t SAuthToken) IsMfaVerified() bool {
 	if !options.Options.EnableTotp {
 		return true
 	}
 	if !t.enableTotp {
 		return true
 	}
-	return t.verifyTotp
+	return MfaModeSatisfied(t.GetMfaMode(), t.verifyTotp, t.verifyWebAuthn)
+}
+
+// IsAnyMfaFactorVerified reports whether the user passes any of the second factors
+func (t SAuthToken) IsAnyMfaFactorVerified() bool {
+	return t.verifyTotp || t.verifyWebAuthn
+}
+
+func (t SAuthToken) GetMfaMode() string {
+	if len(t.mfaMode) == 0 {
+		return api.MFA_MODE_TOTP
+	}
+	return t.mfaMode
 }
 
 func (t SAuthToken) IsTotpEnabled() bool {
@@ -226,17 +318,27 @@ func (t *SAuthToken) SetTotpInitialized() {
 	t.initTotp = true
 }
 
+func (t SAuthToken) IsWebAuthnInitialized() bool {
+	return t.initWebAuthn
+}
+
+func (t *SAuthToken) SetWebAuthnInitialized() {
+	t.initWebAuthn = true
+}
+
 func (t *SAuthToken) SetToken(tid string) {
 	t.token = tid
 }
 
-func NewAuthToken(tid string, enableTotp bool, isTotpInit bool, isSsoLogin bool) *SAuthToken {
+func NewAuthToken(tid string, enableTotp bool, mfaMode string, isTotpInit bool, isWebAuthnInit bool, isSsoLogin bool) *SAuthToken {
 	return &SAuthToken{
-		token:      tid,
-		enableTotp: enableTotp,
-		initTotp:   isTotpInit,
-		isSsoLogin: isSsoLogin,
-		verifyTotp: false,
+		token:        tid,
+		enableTotp:   enableTotp,
+		mfaMode:      mfaMode,
+		initTotp:     isTotpInit,
+		initWebAuthn: isWebAuthnInit,
+		isSsoLogin:   isSsoLogin,
+		verifyTotp:   false,
 	}
 }
 
@@ -257,10 +359,17 @@ func (t *SAuthToken) updateRetryCount() {
 	}
 }
 
-func (t *SAuthToken) VerifyTotpPasscode(s *mcclient.ClientSession, uid, passcode string) error {
+func (t *SAuthToken) checkLocked() error {
 	if t.lockExpireTime > uint32(time.Now().Unix()) {
 		return errors.Wrapf(httperrors.ErrResourceBusy, "locked, retry after %d seconds", t.lockExpireTime-uint32(time.Now().Unix()))
 	}
+	return nil
+}
+
+func (t *SAuthToken) VerifyTotpPasscode(s *mcclient.ClientSession, uid, passcode string) error {
+	if err := t.checkLocked(); err != nil {
+		return err
+	}
 
 	secret, err := fetchUserTotpCredSecret(s, uid)
 	if err != nil {
@@ -54,3 +54,60 @@ func TestEncoeDecode(t *testing.T) {
 		t.Fatalf("token2 != token")
 	}
 }
+
+func TestWebAuthnChallenge(t *testing.T) {
+	SetupTest()
+	token := NewAuthToken("token", true, "all", true, true, false)
+	challenge, err := token.NewWebAuthnChallenge(WebAuthnChallengeGet)
+	if err != nil {
+		t.Fatalf("NewWebAuthnChallenge fail %s", err)
+	}
+
+	token2, err := decodeBytes(token.encodeBytes())
+	if err != nil {
+		t.Fatalf("decodeBytes fail %s", err)
+	}
+	if *token2 != *token {
+		t.Fatalf("token2 != token")
+	}
+
+	// the challenge of a get ceremony can not be used to create credentials
+	if _, err := token2.PopWebAuthnChallenge(WebAuthnChallengeCreate); err == nil {
+		t.Fatalf("pop challenge of another ceremony should fail")
+	}
+	token2, _ = decodeBytes(token.encodeBytes())
+	popped, err := token2.PopWebAuthnChallenge(WebAuthnChallengeGet)
+	if err != nil {
+		t.Fatalf("PopWebAuthnChallenge fail %s", err)
+	}
+	if !reflect.DeepEqual(popped, challenge) {
+		t.Fatalf("popped challenge mismatch")
+	}
+	if _, err := token2.PopWebAuthnChallenge(WebAuthnChallengeGet); err == nil {
+		t.Fatalf("challenge should be used only once")
+	}
+
+}
+
+func TestMfaModeSatisfied(t *testing.T) {
+	cases := []struct {
+		mode     string
+		totp     bool
+		webauthn bool
+		want     bool
+	}{
+		{"", true, false, true},
+		{"totp", false, true, false},
+		{"webauthn", true, false, false},
+		{"webauthn", false, true, true},
+		{"any", false, true, true},
+		{"any", true, false, true},
+		{"all", true, false, false},
+		{"all", true, true, true},
+	}
+	for _, c := range cases {
+		if got := MfaModeSatisfied(c.mode, c.totp, c.webauthn); got != c.want {
+			t.Errorf("mode %q totp %v webauthn %v: want %v got %v", c.mode, c.totp, c.webauthn, c.want, got)
+		}
+	}
+}
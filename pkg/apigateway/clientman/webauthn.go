@@ -0,0 +1,118 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientman
+
+import (
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/webauthnutils"
+)
+
+const WEBAUTHN_CHALLENGE_TIMEOUT = 5 * time.Minute // WebAuthn验证的超时时间
+
+// 转换为webauthn凭证
+func ToWebAuthnCredentials(creds []modules.SWebAuthnCredential) ([]webauthnutils.SCredential, error) {
+	ret := make([]webauthnutils.SCredential, len(creds))
+	for i := range creds {
+		id, err := webauthnutils.DecodeBase64(creds[i].CredentialId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode credential id of %s", creds[i].Id)
+		}
+		pubKey, err := webauthnutils.DecodeBase64(creds[i].PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode public key of %s", creds[i].Id)
+		}
+		ret[i] = webauthnutils.SCredential{
+			Id:        id,
+			PublicKey: pubKey,
+			SignCount: creds[i].SignCount,
+		}
+	}
+	return ret, nil
+}
+
+// NewWebAuthnChallenge 生成WebAuthn注册(create)或验证(get)的随机挑战, 保存在认证cookie中
+func (t *SAuthToken) NewWebAuthnChallenge(challengeType byte) ([]byte, error) {
+	challenge, err := webauthnutils.NewChallenge()
+	if err != nil {
+		return nil, errors.Wrap(err, "NewChallenge")
+	}
+	t.challengeType = challengeType
+	t.challengeExpire = uint32(time.Now().Add(WEBAUTHN_CHALLENGE_TIMEOUT).Unix())
+	copy(t.challenge[:], challenge)
+	return challenge, nil
+}
+
+// PopWebAuthnChallenge 取出并清除随机挑战, 每个挑战只能使用一次
+func (t *SAuthToken) PopWebAuthnChallenge(challengeType byte) ([]byte, error) {
+	cType, expire, challenge := t.challengeType, t.challengeExpire, t.challenge
+	t.challengeType = 0
+	t.challengeExpire = 0
+	t.challenge = [webauthnutils.CHALLENGE_LENGTH]byte{}
+	if cType != challengeType {
+		return nil, errors.Wrap(httperrors.ErrInvalidStatus, "no ongoing webauthn ceremony")
+	}
+	if expire < uint32(time.Now().Unix()) {
+		return nil, errors.Wrap(httperrors.ErrTimeout, "webauthn challenge expired")
+	}
+	return challenge[:], nil
+}
+
+// VerifyWebAuthnAssertion 验证用户硬件密钥的签名, 失败次数与TOTP共用重试计数器
+func (t *SAuthToken) VerifyWebAuthnAssertion(s *mcclient.ClientSession, uid string, rp *webauthnutils.SRelyingParty, assertion *webauthnutils.SPublicKeyCredential) error {
+	if err := t.checkLocked(); err != nil {
+		return err
+	}
+	challenge, err := t.PopWebAuthnChallenge(WebAuthnChallengeGet)
+	if err != nil {
+		return err
+	}
+
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, uid)
+	if err != nil {
+		return errors.Wrap(err, "fetch webauthn credentials error")
+	}
+	registered, err := ToWebAuthnCredentials(creds)
+	if err != nil {
+		return errors.Wrap(err, "ToWebAuthnCredentials")
+	}
+	idx := webauthnutils.FindCredential(assertion, registered)
+	if idx < 0 {
+		t.updateRetryCount()
+		return errors.Wrap(httperrors.ErrInvalidCredential, "unknown webauthn credential")
+	}
+	signCount, err := rp.VerifyAssertion(assertion, challenge, &registered[idx])
+	if err != nil {
+		t.updateRetryCount()
+		return errors.Wrapf(httperrors.ErrInvalidCredential, "verify assertion: %v", err)
+	}
+	if signCount > creds[idx].SignCount {
+		err := modules.Credentials.UpdateWebAuthnSignCount(s, creds[idx], signCount)
+		if err != nil {
+			log.Errorf("update sign count of webauthn credential %s error %s", creds[idx].Id, err)
+		}
+	}
+
+	t.verifyWebAuthn = true
+	t.lockExpireTime = 0
+	t.retryCount = 0
+	return nil
+}
@@ -79,6 +79,11 @@ func (h *AuthHandlers) AddMethods() {
 		NewHP(h.resetTotpSecrets, "credential"),
 		NewHP(h.validatePasscode, "passcode"),
 		NewHP(h.resetTotpRecoveryQuestions, "recovery"),
+		// webauthn
+		NewHP(beginWebAuthnRegistration, "webauthn", "register", "begin"),
+		NewHP(finishWebAuthnRegistration, "webauthn", "register", "finish"),
+		NewHP(beginWebAuthnLogin, "webauthn", "login", "begin"),
+		NewHP(finishWebAuthnLogin, "webauthn", "login", "finish"),
 		NewHP(h.postLoginHandler, "login"),
 		NewHP(h.postLogoutHandler, "logout"),
 		NewHP(h.handleSsoLogin, "ssologin"),
@@ -94,6 +99,7 @@ func (h *AuthHandlers) AddMethods() {
 		NewHP(h.getResources, "scoped_resources"),
 		NewHP(fetchIdpBasicConfig, "idp", "<idp_id>", "info"),
 		NewHP(fetchIdpSAMLMetadata, "idp", "<idp_id>", "saml-metadata"),
+		NewHP(listWebAuthnCredentials, "webauthn", "credentials"),
 	)
 	h.AddByMethod(POST, FetchAuthToken,
 		NewHP(h.resetUserPassword, "password"),
@@ -106,6 +112,7 @@ func (h *AuthHandlers) AddMethods() {
 	)
 	h.AddByMethod(DELETE, FetchAuthToken,
 		NewHP(h.doDeletePolicies, "policies"),
+		NewHP(removeWebAuthnCredential, "webauthn", "credentials", "<credential_id>"),
 	)
 }
 
@@ -277,8 +284,8 @@ func doTenantLogin(ctx context.Context, req *http.Request, body jsonutils.JSONOb
 	if err != nil {
 		return nil, nil, errors.Wrapf(httperrors.ErrInvalidCredential, "fetchAuthToken fail %s", err)
 	}
-	if !authToken.IsTotpVerified() {
-		return nil, nil, errors.Wrap(httperrors.ErrInvalidCredential, "TOTP authentication failed")
+	if !authToken.IsMfaVerified() {
+		return nil, nil, errors.Wrap(httperrors.ErrInvalidCredential, "MFA authentication failed")
 	}
 
 	ntoken, err := auth.Client().SetProject(tenantId, "", "", token)
@@ -303,8 +310,9 @@ func fetchUserInfoById(s *mcclient.ClientSession, userId string) (jsonutils.JSON
 	return info, nil
 }
 
+// 用户开启MFA或者所属域强制开启MFA
 func isUserEnableTotp(userInfo jsonutils.JSONObject) bool {
-	return jsonutils.QueryBoolean(userInfo, "enable_mfa", false)
+	return jsonutils.QueryBoolean(userInfo, "enable_mfa", false) || jsonutils.QueryBoolean(userInfo, "mfa_enforced", false)
 }
 
 func (h *AuthHandlers) doCredentialLogin(ctx context.Context, req *http.Request, body jsonutils.JSONObject) (mcclient.TokenCredential, error) {
@@ -574,8 +582,12 @@ func (h *AuthHandlers) doLogin(ctx context.Context, w http.ResponseWriter, req *
 		if err != nil {
 			return err
 		}
+		isWebAuthnInit, err := isUserWebAuthnCredInitialed(s, token.GetUserId())
+		if err != nil {
+			return err
+		}
 		isIdpLogin := body.Contains("idp_driver")
-		authToken = clientman.NewAuthToken(token.GetTokenString(), isUserEnableTotp(userInfo), isTotpInit, isIdpLogin)
+		authToken = clientman.NewAuthToken(token.GetTokenString(), isUserEnableTotp(userInfo), getUserMfaMode(userInfo), isTotpInit, isWebAuthnInit, isIdpLogin)
 	}
 
 	if !isUserAllowWebconsole(userInfo) {
@@ -839,7 +851,7 @@ func getUserInfo2(s *mcclient.ClientSession, uid string, pid string, loginIp str
 	for _, k := range []string{
 		"displayname", "email", "id", "name",
 		"enabled", "mobile", "allow_web_console",
-		"created_at", "enable_mfa", "is_system_account",
+		"created_at", "enable_mfa", "mfa_enforced", "effective_mfa_mode", "is_system_account",
 		"last_active_at", "last_login_ip",
 		"last_login_source",
 		"password_expires_at", "failed_auth_count", "failed_auth_at",
@@ -1186,6 +1198,7 @@ func (h *AuthHandlers) resetUserPassword(ctx context.Context, w http.ResponseWri
 	newPwd, _ := body.GetString("password_new")
 	confirmPwd, _ := body.GetString("password_confirm")
 	passcode, _ := body.GetString("passcode")
+	hasWebAuthn := body.Contains("credential")
 
 	if newPwd != confirmPwd {
 		httperrors.InputParameterError(ctx, w, "new password mismatch")
@@ -1213,11 +1226,28 @@ func (h *AuthHandlers) resetUserPassword(ctx context.Context, w http.ResponseWri
 	}
 
 	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
-	// 2.如果已开启MFA，验证 随机密码正确
+	// 2.如果已开启MFA，按MFA认证方式验证 随机密码 和/或 硬件密钥签名正确
 	if isMfaEnabled(user) {
-		err = authToken.VerifyTotpPasscode(s, t.GetUserId(), passcode)
-		if err != nil {
-			httperrors.InputParameterError(ctx, w, "invalid passcode")
+		totpOk, webauthnOk := false, false
+		if len(passcode) > 0 {
+			totpOk = authToken.VerifyTotpPasscode(s, t.GetUserId(), passcode) == nil
+		}
+		if hasWebAuthn {
+			cred, err := fetchWebAuthnCredential(body)
+			if err != nil {
+				httperrors.GeneralServerError(ctx, w, err)
+				return
+			}
+			rp, err := getWebAuthnRelyingParty()
+			if err != nil {
+				httperrors.GeneralServerError(ctx, w, err)
+				return
+			}
+			webauthnOk = authToken.VerifyWebAuthnAssertion(s, t.GetUserId(), rp, cred) == nil
+		}
+		if !clientman.MfaModeSatisfied(getUserMfaMode(user), totpOk, webauthnOk) {
+			saveAuthCookie(w, authToken, t)
+			httperrors.InputParameterError(ctx, w, "invalid passcode or security key")
 			return
 		}
 	}
@@ -1249,9 +1279,5 @@ func isMfaEnabled(user jsonutils.JSONObject) bool {
 		return false
 	}
 
-	if ok, _ := user.Bool("enable_mfa"); ok {
-		return true
-	}
-
-	return false
+	return isUserEnableTotp(user)
 }
@@ -0,0 +1,331 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/apigateway/clientman"
+	"yunion.io/x/onecloud/pkg/apigateway/options"
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+	"yunion.io/x/onecloud/pkg/util/webauthnutils"
+)
+
+// 浏览器完成WebAuthn操作的超时时间(毫秒)
+const webAuthnCeremonyTimeoutMs = int(clientman.WEBAUTHN_CHALLENGE_TIMEOUT / time.Millisecond)
+
+// 获取WebAuthn依赖方(relying party)配置, rpId必须配置, 不能取自请求中客户端可以伪造的Host,
+// 未配置允许的origin时只允许 https://<rpId>
+func getWebAuthnRelyingParty() (*webauthnutils.SRelyingParty, error) {
+	rpId := options.Options.WebauthnRpId
+	if len(rpId) == 0 {
+		return nil, httperrors.NewNotSupportedError("webauthn_rp_id is not configured")
+	}
+	origins := options.Options.WebauthnOrigins
+	if len(origins) == 0 {
+		origins = []string{fmt.Sprintf("https://%s", rpId)}
+	}
+	return &webauthnutils.SRelyingParty{
+		Id:   rpId,
+		Name: options.Options.WebauthnRpName,
+		CheckOrigin: func(origin string) bool {
+			return utils.IsInStringArray(origin, origins)
+		},
+		RequireUserVerification: options.Options.WebauthnRequireUserVerification,
+	}, nil
+}
+
+func getUserMfaMode(userInfo jsonutils.JSONObject) string {
+	mode, _ := userInfo.GetString("effective_mfa_mode")
+	if len(mode) == 0 {
+		return api.MFA_MODE_TOTP
+	}
+	return mode
+}
+
+// 检查用户是否注册了WebAuthn硬件密钥.true -- 已注册；false--未注册
+func isUserWebAuthnCredInitialed(s *mcclient.ClientSession, uid string) (bool, error) {
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, uid)
+	if err != nil {
+		return false, err
+	}
+	return len(creds) > 0, nil
+}
+
+// 已设置TOTP密钥或者注册了硬件密钥的用户需要先通过任意一种MFA认证才能注册新的硬件密钥,
+// 避免仅凭密码绕过已有的MFA认证
+func canRegisterWebAuthn(authToken *clientman.SAuthToken, hasCreds bool) bool {
+	if authToken.IsMfaVerified() {
+		return true
+	}
+	if authToken.IsTotpInitialized() || hasCreds {
+		return authToken.IsAnyMfaFactorVerified()
+	}
+	return true
+}
+
+func fetchWebAuthnCredential(body jsonutils.JSONObject) (*webauthnutils.SPublicKeyCredential, error) {
+	credJson, err := body.Get("credential")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("credential")
+	}
+	cred := &webauthnutils.SPublicKeyCredential{}
+	err = credJson.Unmarshal(cred)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("unmarshal credential: %v", err)
+	}
+	return cred, nil
+}
+
+// 开始注册硬件密钥, 返回 navigator.credentials.create() 的 publicKey 参数
+func beginWebAuthnRegistration(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if !canRegisterWebAuthn(authToken, len(creds) > 0) {
+		httperrors.ForbiddenError(ctx, w, "MFA authentication required before registering a new security key")
+		return
+	}
+	registered, err := clientman.ToWebAuthnCredentials(creds)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	rp, err := getWebAuthnRelyingParty()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	challenge, err := authToken.NewWebAuthnChallenge(clientman.WebAuthnChallengeCreate)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	saveAuthCookie(w, authToken, t)
+
+	user := webauthnutils.SUserEntity{
+		Id:          webauthnutils.EncodeBase64([]byte(t.GetUserId())),
+		Name:        t.GetUserName(),
+		DisplayName: t.GetUserName(),
+	}
+	opts := rp.NewCreationOptions(challenge, user, registered, webAuthnCeremonyTimeoutMs)
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.Marshal(opts), "public_key")
+	appsrv.SendJSON(w, resp)
+}
+
+// 完成注册硬件密钥, 验证 navigator.credentials.create() 返回的凭证并保存到keystone
+func finishWebAuthnRegistration(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return
+	}
+
+	_, _, body := appsrv.FetchEnv(ctx, w, req)
+	if body == nil {
+		httperrors.InvalidInputError(ctx, w, "request body is empty")
+		return
+	}
+	cred, err := fetchWebAuthnCredential(body)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	name, _ := body.GetString("name")
+
+	rp, err := getWebAuthnRelyingParty()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	challenge, err := authToken.PopWebAuthnChallenge(clientman.WebAuthnChallengeCreate)
+	saveAuthCookie(w, authToken, t)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	registered, err := rp.VerifyAttestation(cred, challenge)
+	if err != nil {
+		log.Warningf("VerifyAttestation %s", err)
+		httperrors.InvalidCredentialError(ctx, w, "invalid security key: %v", err)
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	blob := api.SWebAuthnCredentialBlob{
+		CredentialId: webauthnutils.EncodeBase64(registered.Id),
+		PublicKey:    webauthnutils.EncodeBase64(registered.PublicKey),
+		SignCount:    registered.SignCount,
+		Aaguid:       webauthnutils.EncodeBase64(registered.Aaguid),
+		Timestamp:    time.Now().Unix(),
+	}
+	saved, err := modules.Credentials.SaveWebAuthnCredential(s, t.GetUserId(), name, blob)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	authToken.SetWebAuthnInitialized()
+	saveAuthCookie(w, authToken, t)
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewString(saved.Id), "id")
+	resp.Add(jsonutils.NewString(saved.Name), "name")
+	appsrv.SendJSON(w, resp)
+}
+
+// 开始验证硬件密钥, 返回 navigator.credentials.get() 的 publicKey 参数
+func beginWebAuthnLogin(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return
+	}
+
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if len(creds) == 0 {
+		httperrors.NotFoundError(ctx, w, "no security key registered")
+		return
+	}
+	registered, err := clientman.ToWebAuthnCredentials(creds)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	rp, err := getWebAuthnRelyingParty()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	challenge, err := authToken.NewWebAuthnChallenge(clientman.WebAuthnChallengeGet)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	saveAuthCookie(w, authToken, t)
+
+	opts := rp.NewRequestOptions(challenge, registered, webAuthnCeremonyTimeoutMs)
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.Marshal(opts), "public_key")
+	appsrv.SendJSON(w, resp)
+}
+
+// 完成验证硬件密钥, 可以替代或者配合TOTP动态口令完成MFA认证
+func finishWebAuthnLogin(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t, authToken, err := fetchAuthInfo(ctx, req)
+	if err != nil {
+		httperrors.InvalidCredentialError(ctx, w, "fetchAuthInfo fail: %s", err)
+		return
+	}
+
+	_, _, body := appsrv.FetchEnv(ctx, w, req)
+	if body == nil {
+		httperrors.InvalidInputError(ctx, w, "request body is empty")
+		return
+	}
+	cred, err := fetchWebAuthnCredential(body)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+
+	rp, err := getWebAuthnRelyingParty()
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	err = authToken.VerifyWebAuthnAssertion(s, t.GetUserId(), rp, cred)
+
+	saveAuthCookie(w, authToken, t)
+
+	if err != nil {
+		log.Warningf("VerifyWebAuthnAssertion %s", err)
+		httperrors.InvalidCredentialError(ctx, w, "invalid security key: %v", err)
+		return
+	}
+
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewBool(authToken.IsMfaVerified()), "mfa_verified")
+	appsrv.SendJSON(w, resp)
+}
+
+// 获取用户注册的硬件密钥列表
+func listWebAuthnCredentials(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t := AppContextToken(ctx)
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	creds, err := modules.Credentials.GetWebAuthnCredentials(s, t.GetUserId())
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	data := jsonutils.NewArray()
+	for i := range creds {
+		item := jsonutils.NewDict()
+		item.Add(jsonutils.NewString(creds[i].Id), "id")
+		item.Add(jsonutils.NewString(creds[i].Name), "name")
+		item.Add(jsonutils.NewString(creds[i].CredentialId), "credential_id")
+		item.Add(jsonutils.NewTimeString(creds[i].CreatedAt), "created_at")
+		data.Add(item)
+	}
+	resp := jsonutils.NewDict()
+	resp.Add(data, "data")
+	appsrv.SendJSON(w, resp)
+}
+
+// 删除用户注册的硬件密钥
+func removeWebAuthnCredential(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	t := AppContextToken(ctx)
+	params, _, _ := appsrv.FetchEnv(ctx, w, req)
+	s := auth.GetAdminSession(ctx, FetchRegion(req), "")
+	err := modules.Credentials.RemoveWebAuthnCredential(s, t.GetUserId(), params["<credential_id>"])
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	appsrv.SendJSON(w, jsonutils.NewDict())
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/apigateway/options"
+)
+
+func TestGetWebAuthnRelyingParty(t *testing.T) {
+	opts := options.Options
+	defer func() {
+		options.Options = opts
+	}()
+
+	options.Options = &options.GatewayOptions{}
+	if _, err := getWebAuthnRelyingParty(); err == nil {
+		t.Errorf("the relying party id should be configured")
+	}
+
+	options.Options.WebauthnRpId = "console.example.com"
+	rp, err := getWebAuthnRelyingParty()
+	if err != nil {
+		t.Fatalf("getWebAuthnRelyingParty: %v", err)
+	}
+	if rp.Id != "console.example.com" {
+		t.Errorf("want rp id console.example.com got %s", rp.Id)
+	}
+	for origin, want := range map[string]bool{
+		"https://console.example.com":      true,
+		"http://console.example.com":       false,
+		"https://console.example.com:8443": false,
+		"https://evil.example.com":         false,
+	} {
+		if got := rp.CheckOrigin(origin); got != want {
+			t.Errorf("origin %s: want %v got %v", origin, want, got)
+		}
+	}
+
+	options.Options.WebauthnOrigins = []string{"https://console.example.com:8443"}
+	rp, err = getWebAuthnRelyingParty()
+	if err != nil {
+		t.Fatalf("getWebAuthnRelyingParty: %v", err)
+	}
+	if !rp.CheckOrigin("https://console.example.com:8443") || rp.CheckOrigin("https://console.example.com") {
+		t.Errorf("only the configured origins should be allowed")
+	}
+}
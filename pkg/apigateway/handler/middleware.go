@@ -116,8 +116,8 @@ func fetchAndSetAuthContext(ctx context.Context, w http.ResponseWriter, r *http.
 		return ctx, errors.Wrap(err, "fetchAuthInfo")
 	}
 	// 启用双因子认证
-	if !authToken.IsTotpVerified() {
-		return ctx, errors.Wrap(httperrors.ErrInvalidCredential, "TOTP authentication failed")
+	if !authToken.IsMfaVerified() {
+		return ctx, errors.Wrap(httperrors.ErrInvalidCredential, "MFA authentication failed")
 	}
 	// no more send auth header, save auth info in cookie
 	// setAuthHeader(w, authHeader)
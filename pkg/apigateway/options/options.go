@@ -28,6 +28,11 @@ type GatewayOptions struct {
 
 	EnableTotp bool `help:"Enable two-factor authentication" default:"true"`
 
+	WebauthnRpId                    string   `help:"WebAuthn relying party id, the domain name of the web console, required by the security keys"`
+	WebauthnRpName                  string   `help:"WebAuthn relying party name shown by the authenticators" default:"Onecloud"`
+	WebauthnOrigins                 []string `help:"Origins of the web console allowed in WebAuthn ceremonies, default is https://<webauthn_rp_id>"`
+	WebauthnRequireUserVerification bool     `help:"Require the authenticators to verify the user by PIN or biometrics" default:"false"`
+
 	SsoRedirectUrl     string `help:"SSO idp redirect URL"`
 	SsoAuthCallbackUrl string `help:"SSO idp auth callback URL"`
 	SsoLinkCallbackUrl string `help:"SSO idp link user callback URL"`
@@ -39,6 +39,9 @@ const (
 	BUCKET_STATUS_DELETE_FAIL  = "delete_fail"
 	BUCKET_STATUS_UNKNOWN      = "unknown"
 
+	// 不支持原生生命周期的存储，规则保存在该metadata中，由region定时执行
+	BUCKET_METADATA_LIFECYCLE = "__lifecycle"
+
 	BUCKET_UPLOAD_OBJECT_KEY_HEADER          = "X-Yunion-Bucket-Upload-Key"
 	BUCKET_UPLOAD_OBJECT_ACL_HEADER          = "X-Yunion-Bucket-Upload-Acl"
 	BUCKET_UPLOAD_OBJECT_STORAGECLASS_HEADER = "X-Yunion-Bucket-Upload-Storageclass"
@@ -185,6 +188,59 @@ type BucketGetObjectsOutput struct {
 	NextMarker string `json:"next_marker"`
 }
 
+type BucketSetVersioningInput struct {
+	// 多版本状态，开启后只能暂停，不能关闭
+	// enum: Enabled, Suspended
+	Status string `json:"status"`
+}
+
+func (input *BucketSetVersioningInput) Validate() error {
+	return cloudprovider.ValidateVersioningStatus(input.Status)
+}
+
+type BucketGetVersioningOutput struct {
+	// 多版本状态，从未开启时为空
+	// example: Enabled
+	Status string `json:"status"`
+}
+
+type BucketGetObjectVersionsInput struct {
+	// Prefix
+	Prefix string `json:"prefix"`
+	// 分页标识，上一页返回的next_key_marker
+	KeyMarker string `json:"key_marker"`
+	// 分页标识，上一页返回的next_version_id_marker
+	VersionIdMarker string `json:"version_id_marker"`
+	// 最大输出条目数
+	Limit *int `json:"limit"`
+}
+
+type BucketGetObjectVersionsOutput struct {
+	// 对象版本列表
+	Data []cloudprovider.SCloudObjectVersion `json:"data"`
+	// 下一页请求的key_marker标识
+	NextKeyMarker string `json:"next_key_marker"`
+	// 下一页请求的version_id_marker标识
+	NextVersionIdMarker string `json:"next_version_id_marker"`
+}
+
+type BucketObjectVersionInput struct {
+	// 对象key
+	Key string `json:"key"`
+	// 对象版本
+	VersionId string `json:"version_id"`
+}
+
+func (input *BucketObjectVersionInput) Validate() error {
+	if len(input.Key) == 0 {
+		return httperrors.NewMissingParameterError("key")
+	}
+	if len(input.VersionId) == 0 {
+		return httperrors.NewMissingParameterError("version_id")
+	}
+	return nil
+}
+
 type BucketWebsiteRoutingRule struct {
 	ConditionErrorCode string
 	ConditionPrefix    string
@@ -319,6 +375,59 @@ func (input *BucketCORSRules) Validate() error {
 	return nil
 }
 
+type BucketLifecycleTransition struct {
+	// 对象最后修改多少天后转换
+	Days int
+	// 转换的目标存储类型
+	StorageClass string
+}
+
+type BucketLifecycleRule struct {
+	// 规则区别标识
+	Id string
+	// 规则作用的对象前缀，为空表示整个bucket
+	Prefix string
+	// 是否启用
+	Enabled bool
+	// 对象最后修改多少天后删除，0表示不删除
+	ExpirationDays int
+	// 存储类型转换
+	Transitions []BucketLifecycleTransition
+	// 未完成的分片上传初始化多少天后终止
+	AbortIncompleteMultipartUploadDays int
+}
+
+type BucketLifecycleRules struct {
+	Data []BucketLifecycleRule `json:"data"`
+
+	// 是否由region模拟执行，存储不支持原生生命周期时为true
+	Emulated bool `json:"emulated"`
+}
+
+func (input *BucketLifecycleRules) Validate() error {
+	for i := range input.Data {
+		rule := input.Data[i]
+		if rule.ExpirationDays < 0 {
+			return httperrors.NewInputParameterError("invalid expiration_days %d", rule.ExpirationDays)
+		}
+		if rule.AbortIncompleteMultipartUploadDays < 0 {
+			return httperrors.NewInputParameterError("invalid abort_incomplete_multipart_upload_days %d", rule.AbortIncompleteMultipartUploadDays)
+		}
+		if rule.ExpirationDays == 0 && rule.AbortIncompleteMultipartUploadDays == 0 && len(rule.Transitions) == 0 {
+			return httperrors.NewMissingParameterError("expiration_days")
+		}
+		for _, t := range rule.Transitions {
+			if t.Days <= 0 {
+				return httperrors.NewInputParameterError("invalid transition days %d", t.Days)
+			}
+			if len(t.StorageClass) == 0 {
+				return httperrors.NewMissingParameterError("storage_class")
+			}
+		}
+	}
+	return nil
+}
+
 type BucketRefererConf struct {
 	// Referer Type
 	// enmu: Black-List, White-List
@@ -0,0 +1,133 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import (
+	"time"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+const (
+	WEBHOOK_STATUS_READY = "ready"
+
+	WEBHOOK_DELIVERY_STATUS_PENDING   = "pending"
+	WEBHOOK_DELIVERY_STATUS_SUCCEEDED = "succeeded"
+	WEBHOOK_DELIVERY_STATUS_FAILED    = "failed"
+
+	// the action of the event sent by perform test
+	WEBHOOK_ACTION_PING = "ping"
+
+	WEBHOOK_HEADER_ID        = "X-Webhook-Id"
+	WEBHOOK_HEADER_DELIVERY  = "X-Webhook-Delivery"
+	WEBHOOK_HEADER_EVENT     = "X-Webhook-Event"
+	WEBHOOK_HEADER_SIGNATURE = "X-Webhook-Signature-256"
+	// unix seconds when the request is sent, signed together with the body
+	WEBHOOK_HEADER_TIMESTAMP = "X-Webhook-Timestamp"
+)
+
+type WebhookCreateInput struct {
+	apis.VirtualResourceCreateInput
+	apis.EnabledBaseResourceCreateInput
+
+	// 接收事件的地址, 仅支持 http 和 https
+	// example: https://ci.example.com/hooks/onecloud
+	Url string `json:"url"`
+	// 签名密钥, 不为空时请求头 X-Webhook-Signature-256 为 "<X-Webhook-Timestamp>.<请求体>" 的 HMAC-SHA256 签名,
+	// 接收方应拒绝时间戳过旧的请求以防止重放
+	Secret string `json:"secret"`
+	// 订阅的资源类型, 比如 server, disk, 为空时订阅所有资源
+	ResourceTypes []string `json:"resource_types"`
+	// 订阅的操作, 比如 create, delete, start, 为空时订阅所有操作
+	Actions []string `json:"actions"`
+	// 订阅事件的范围, project 只订阅所属项目的资源事件, domain 和 system 需要对应的权限
+	// enum: project,domain,system
+	// default: project
+	EventScope string `json:"event_scope"`
+	// 发送失败后的最大重试次数
+	// default: 5
+	MaxRetries *int `json:"max_retries"`
+}
+
+type WebhookUpdateInput struct {
+	apis.VirtualResourceBaseUpdateInput
+
+	Url           string   `json:"url"`
+	Secret        *string  `json:"secret"`
+	ResourceTypes []string `json:"resource_types"`
+	Actions       []string `json:"actions"`
+	MaxRetries    *int     `json:"max_retries"`
+}
+
+type WebhookListInput struct {
+	apis.VirtualResourceListInput
+	apis.EnabledResourceBaseListInput
+
+	// 订阅了该资源类型的 webhook
+	ResourceType string `json:"resource_type"`
+	EventScope   string `json:"event_scope"`
+}
+
+type WebhookDetails struct {
+	apis.VirtualResourceDetails
+
+	SWebhook
+
+	// 是否设置了签名密钥
+	HasSecret bool `json:"has_secret"`
+}
+
+type WebhookTestInput struct {
+}
+
+// WebhookEvent is the body posted to the webhooks
+type WebhookEvent struct {
+	WebhookId  string    `json:"webhook_id"`
+	DeliveryId string    `json:"delivery_id"`
+	EventId    int64     `json:"event_id"`
+	OpsTime    time.Time `json:"ops_time"`
+
+	ObjType string `json:"obj_type"`
+	ObjId   string `json:"obj_id"`
+	ObjName string `json:"obj_name"`
+	Action  string `json:"action"`
+	Notes   string `json:"notes"`
+
+	OwnerProjectId string `json:"owner_project_id"`
+	OwnerDomainId  string `json:"owner_domain_id"`
+
+	UserId    string `json:"user_id"`
+	User      string `json:"user"`
+	ProjectId string `json:"project_id"`
+	Project   string `json:"project"`
+}
+
+type WebhookDeliveryListInput struct {
+	apis.StatusStandaloneResourceListInput
+
+	// 所属 webhook
+	Webhook string `json:"webhook"`
+	ObjType string `json:"obj_type"`
+	ObjId   string `json:"obj_id"`
+	Action  string `json:"action"`
+}
+
+type WebhookDeliveryDetails struct {
+	apis.StatusStandaloneResourceDetails
+
+	SWebhookDelivery
+
+	Webhook string `json:"webhook"`
+}
@@ -2371,6 +2371,38 @@ type SVpcResourceBase struct {
 	VpcId string `json:"vpc_id"`
 }
 
+// SWebhook is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SWebhook.
+type SWebhook struct {
+	apis.SVirtualResourceBase
+	apis.SEnabledResourceBase
+	Url string `json:"url"`
+	// ResourceTypes are the keywords of the resources, empty for all resources
+	ResourceTypes interface{} `json:"resource_types"`
+	// Actions are the opslog actions, empty for all actions
+	Actions interface{} `json:"actions"`
+	// EventScope is the scope of the resources whose events are sent,
+	// the resources of the project or the domain of the webhook or all
+	EventScope string `json:"event_scope"`
+	MaxRetries int    `json:"max_retries"`
+}
+
+// SWebhookDelivery is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SWebhookDelivery.
+type SWebhookDelivery struct {
+	apis.SStatusStandaloneResourceBase
+	WebhookId string `json:"webhook_id"`
+	EventId   int64  `json:"event_id"`
+	ObjType   string `json:"obj_type"`
+	ObjId     string `json:"obj_id"`
+	Action    string `json:"action"`
+	// Payload is the body posted, the retries send the same body
+	Payload       string    `json:"payload"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	DeliveredAt   time.Time `json:"delivered_at"`
+	ResponseCode  int       `json:"response_code"`
+	Reason        string    `json:"reason"`
+}
+
 // SWire is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SWire.
 type SWire struct {
 	apis.SInfrasResourceBase
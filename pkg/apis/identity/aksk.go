@@ -25,6 +25,8 @@ const (
 	TOTP_TYPE             = "totp"
 	RECOVERY_SECRETS_TYPE = "recovery_secret"
 	OIDC_CREDENTIAL_TYPE  = "oidc"
+	WEBAUTHN_TYPE         = "webauthn"
+	APP_CREDENTIAL_TYPE   = "app_cred"
 )
 
 type SAccessKeySecretBlob struct {
@@ -39,6 +41,17 @@ func (info SAccessKeySecretBlob) IsValid() bool {
 	return false
 }
 
+// SWebAuthnCredentialBlob is the blob of a WebAuthn credential, the binary
+// fields are base64url encoded
+type SWebAuthnCredentialBlob struct {
+	CredentialId string `json:"credential_id"`
+	// COSE_Key encoded public key
+	PublicKey string `json:"public_key"`
+	SignCount uint32 `json:"sign_count"`
+	Aaguid    string `json:"aaguid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 type SAccessKeySecretInfo struct {
 	AccessKey string
 	SAccessKeySecretBlob
@@ -0,0 +1,114 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"net"
+	"strings"
+)
+
+// SAppCredentialBlob is the blob of an application credential. An application
+// credential is signed like AK/SK, but it is bound to a project of the user
+// and is granted a subset of the roles of the user in the project.
+type SAppCredentialBlob struct {
+	SAccessKeySecretBlob
+
+	// 授予应用凭证的角色ID列表, 必须是用户在项目中角色的子集
+	Roles []string `json:"roles"`
+	// 允许的操作, 格式为 <service>/<resource>/<action>, 支持通配符*, 为空则不限制
+	// example: compute/servers/list
+	AllowedActions []string `json:"allowed_actions"`
+	// 允许的来源IP或者网段, 为空则不限制
+	// example: 10.0.0.0/8
+	AllowedIps []string `json:"allowed_ips"`
+}
+
+// SAppCredentialInfo is the restriction of the tokens issued by
+// authenticating with an application credential
+type SAppCredentialInfo struct {
+	// 应用凭证ID
+	Id string `json:"id"`
+	// 允许的操作
+	AllowedActions []string `json:"allowed_actions"`
+}
+
+func (blob SAppCredentialBlob) IsIpAllowed(ip string) bool {
+	if len(blob.AllowedIps) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range blob.AllowedIps {
+		if strings.Contains(allowed, "/") {
+			_, ipNet, err := net.ParseCIDR(allowed)
+			if err == nil && ipNet.Contains(addr) {
+				return true
+			}
+		} else if allowedAddr := net.ParseIP(allowed); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (info SAppCredentialInfo) IsZero() bool {
+	return len(info.Id) == 0
+}
+
+// IsActionAllowed reports whether the policy action is allowed by the
+// application credential, the omitted segments of a pattern match anything
+func (info SAppCredentialInfo) IsActionAllowed(service string, resource string, action string) bool {
+	if info.IsZero() || len(info.AllowedActions) == 0 {
+		return true
+	}
+	for _, pattern := range info.AllowedActions {
+		if matchAppCredentialAction(pattern, service, resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAppCredentialAction(pattern string, service string, resource string, action string) bool {
+	segs := strings.Split(pattern, "/")
+	if len(segs) > 3 {
+		return false
+	}
+	for i, val := range []string{service, resource, action} {
+		if i >= len(segs) || segs[i] == "*" {
+			continue
+		}
+		if segs[i] != val {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidAppCredentialAction validates the format of an allowed action
+func IsValidAppCredentialAction(pattern string) bool {
+	segs := strings.Split(pattern, "/")
+	if len(segs) > 3 {
+		return false
+	}
+	for i := range segs {
+		if len(segs[i]) == 0 {
+			return false
+		}
+	}
+	return true
+}
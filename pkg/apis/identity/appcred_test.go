@@ -0,0 +1,69 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import "testing"
+
+func TestSAppCredentialInfo_IsActionAllowed(t *testing.T) {
+	info := SAppCredentialInfo{
+		Id:             "cred",
+		AllowedActions: []string{"compute/servers/list", "compute/servers/get", "image/*/list", "k8s"},
+	}
+	cases := []struct {
+		service  string
+		resource string
+		action   string
+		want     bool
+	}{
+		{"compute", "servers", "list", true},
+		{"compute", "servers", "delete", false},
+		{"compute", "disks", "list", false},
+		{"image", "images", "list", true},
+		{"image", "images", "create", false},
+		{"k8s", "clusters", "delete", true},
+	}
+	for _, c := range cases {
+		got := info.IsActionAllowed(c.service, c.resource, c.action)
+		if got != c.want {
+			t.Errorf("%s/%s/%s want %v got %v", c.service, c.resource, c.action, c.want, got)
+		}
+	}
+	if !(SAppCredentialInfo{}).IsActionAllowed("compute", "servers", "delete") {
+		t.Errorf("tokens not issued by application credentials should not be restricted")
+	}
+	if !(SAppCredentialInfo{Id: "cred"}).IsActionAllowed("compute", "servers", "delete") {
+		t.Errorf("application credentials without allowed actions should not be restricted")
+	}
+}
+
+func TestSAppCredentialBlob_IsIpAllowed(t *testing.T) {
+	blob := SAppCredentialBlob{
+		AllowedIps: []string{"10.0.0.0/8", "192.168.1.10"},
+	}
+	cases := map[string]bool{
+		"10.1.2.3":     true,
+		"192.168.1.10": true,
+		"192.168.1.11": false,
+		"":             false,
+	}
+	for ip, want := range cases {
+		if got := blob.IsIpAllowed(ip); got != want {
+			t.Errorf("%s want %v got %v", ip, want, got)
+		}
+	}
+	if !(SAppCredentialBlob{}).IsIpAllowed("") {
+		t.Errorf("application credentials without allowed ips should not be restricted")
+	}
+}
@@ -31,4 +31,12 @@ type CredentialUpdateInput struct {
 
 	// enabled
 	Enabled *bool `json:"enabled"`
+
+	// 凭证内容为只读, 不允许更新, WebAuthn凭证的签名计数器通过 update-sign-count 操作更新
+	Blob *string `json:"blob"`
+}
+
+type CredentialUpdateSignCountInput struct {
+	// WebAuthn凭证最近一次签名的计数器, 只能增加
+	SignCount uint32 `json:"sign_count"`
 }
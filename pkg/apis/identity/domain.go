@@ -40,6 +40,13 @@ type DomainUpdateInput struct {
 
 	// 是否启用
 	Enabled *bool `json:"enabled"`
+
+	// 是否强制域内所有用户开启MFA认证
+	EnableMfa *bool `json:"enable_mfa"`
+
+	// 域内用户默认的MFA认证方式
+	// enum: totp,webauthn,any,all
+	MfaMode string `json:"mfa_mode"`
 }
 
 type DomainCreateInput struct {
@@ -50,4 +57,11 @@ type DomainCreateInput struct {
 
 	// 是否启用
 	Enabled *bool `json:"enabled"`
+
+	// 是否强制域内所有用户开启MFA认证
+	EnableMfa *bool `json:"enable_mfa"`
+
+	// 域内用户默认的MFA认证方式
+	// enum: totp,webauthn,any,all
+	MfaMode string `json:"mfa_mode"`
 }
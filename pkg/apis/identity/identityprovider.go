@@ -126,3 +126,11 @@ type GetIdpSsoRedirectUriOutput struct {
 type PerformDefaultSsoInput struct {
 	Enable *bool `json:"enable" help:"enable default sso" negative:"disable"`
 }
+
+type PerformEnableScimOutput struct {
+	// SCIM服务的路径, 相对于keystone的服务地址
+	// example: /scim/v2/6f8ed2b5a43c4ea98ff5e5fd3c0a6f36
+	ScimPath string `json:"scim_path"`
+	// SCIM客户端使用的Bearer Token, 仅在启用时返回一次, 再次启用会生成新的Token
+	Token string `json:"token"`
+}
@@ -447,6 +447,10 @@ type UserUpdateInput struct {
 
 	EnableMfa *bool `json:"enable_mfa"`
 
+	// MFA认证方式, 为空时使用所属域的设置
+	// enum: totp,webauthn,any,all
+	MfaMode *string `json:"mfa_mode"`
+
 	Password string `json:"password"`
 
 	SkipPasswordComplexityCheck *bool `json:"skip_password_complexity_check"`
@@ -469,6 +473,10 @@ type UserCreateInput struct {
 
 	EnableMfa *bool `json:"enable_mfa"`
 
+	// MFA认证方式, 为空时使用所属域的设置
+	// enum: totp,webauthn,any,all
+	MfaMode *string `json:"mfa_mode"`
+
 	Password string `json:"password"`
 
 	SkipPasswordComplexityCheck *bool `json:"skip_password_complexity_check"`
@@ -14,7 +14,14 @@
 
 package identity
 
-import "yunion.io/x/onecloud/pkg/apis"
+import (
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
 
 type PolicyDetails struct {
 	EnabledIdentityBaseResourceDetails
@@ -31,3 +38,89 @@ type PolicyBindRoleInput struct {
 	//	IP白名单
 	Ips []string `json:"ips"`
 }
+
+type PolicySimulateRequest struct {
+	// 请求的权限范围，system|domain|project|user，默认为project
+	Scope rbacutils.TRbacScope `json:"scope"`
+	// 服务类型，例如compute
+	Service string `json:"service"`
+	// 资源，例如servers
+	Resource string `json:"resource"`
+	// 操作，例如get|list|create|update|delete|perform
+	Action string `json:"action"`
+	// 操作的附加信息，例如perform的具体操作
+	Extra []string `json:"extra"`
+	// 目标资源的标签，用于匹配规则的标签条件，为空时带标签条件的规则不生效
+	Tags map[string]string `json:"tags"`
+}
+
+type PolicySimulateDraft struct {
+	// 草稿权限的名称
+	Name string `json:"name"`
+	// 草稿权限的生效范围，project|domain|system，默认为project
+	Scope rbacutils.TRbacScope `json:"scope"`
+	// 草稿权限的内容，格式同创建权限
+	Blob jsonutils.JSONObject `json:"blob"`
+	// 草稿替换的权限ID或名称，为空时草稿作为绑定到角色的新权限参与模拟
+	Replace string `json:"replace"`
+}
+
+type PolicySimulateInput struct {
+	// 用户ID或名称
+	UserId string `json:"user_id"`
+	// 项目ID或名称
+	ProjectId string `json:"project_id"`
+	// 角色ID或名称列表，为空时使用用户在项目中的角色
+	RoleIds []string `json:"role_ids"`
+	// 登录IP
+	LoginIp string `json:"login_ip"`
+	// 用户所属组的ID或名称，为空时使用用户所属的组
+	Groups []string `json:"groups"`
+	// 请求时间，用于匹配规则的时间条件，默认为当前时间
+	Time time.Time `json:"time"`
+
+	// 未保存的草稿权限
+	Draft *PolicySimulateDraft `json:"draft"`
+
+	// 模拟的请求列表
+	Requests []PolicySimulateRequest `json:"requests"`
+}
+
+type PolicySimulateMatch struct {
+	// 权限名称
+	Policy string `json:"policy"`
+	// 是否为草稿权限
+	IsDraft bool `json:"is_draft"`
+	// 是否为服务内置的默认权限
+	IsDefault bool `json:"is_default"`
+	// 权限的生效范围
+	Scope rbacutils.TRbacScope `json:"scope"`
+	// 匹配的规则，依次为service, resource, action和extra
+	Rule []string `json:"rule"`
+	// 规则的条件
+	Conditions jsonutils.JSONObject `json:"conditions"`
+	// 规则的结果，allow|deny
+	Result rbacutils.TRbacResult `json:"result"`
+}
+
+type PolicySimulateResult struct {
+	PolicySimulateRequest
+
+	// 模拟的结果，allow|deny
+	Result rbacutils.TRbacResult `json:"result"`
+	// 匹配的规则，任一规则允许则允许请求
+	Matches []PolicySimulateMatch `json:"matches"`
+	// 是否模拟了请求服务内置的默认权限，只有认证服务(identity)的默认权限参与模拟，其他服务只模拟通用的默认权限
+	DefaultPoliciesEvaluated bool `json:"default_policies_evaluated"`
+}
+
+type PolicySimulateOutput struct {
+	UserId    string   `json:"user_id"`
+	ProjectId string   `json:"project_id"`
+	RoleIds   []string `json:"role_ids"`
+	Groups    []string `json:"groups"`
+	// 参与模拟的权限名称，按生效范围分组
+	Policies map[rbacutils.TRbacScope][]string `json:"policies"`
+
+	Results []PolicySimulateResult `json:"results"`
+}
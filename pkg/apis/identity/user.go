@@ -18,6 +18,21 @@ import (
 	"time"
 )
 
+const (
+	// 仅使用TOTP动态口令
+	MFA_MODE_TOTP = "totp"
+	// 仅使用WebAuthn硬件密钥
+	MFA_MODE_WEBAUTHN = "webauthn"
+	// TOTP动态口令或WebAuthn硬件密钥任选其一
+	MFA_MODE_ANY = "any"
+	// 同时验证TOTP动态口令和WebAuthn硬件密钥
+	MFA_MODE_ALL = "all"
+)
+
+var (
+	MFA_MODES = []string{MFA_MODE_TOTP, MFA_MODE_WEBAUTHN, MFA_MODE_ANY, MFA_MODE_ALL}
+)
+
 type UserDetails struct {
 	EnabledIdentityBaseResourceDetails
 	// IdpResourceInfo
@@ -35,5 +50,10 @@ type UserDetails struct {
 
 	IsLocal bool `json:"is_local"`
 
+	// 用户或所属域开启了MFA认证
+	MfaEnforced bool `json:"mfa_enforced"`
+	// 实际生效的MFA认证方式, 用户未设置时使用所属域的设置
+	EffectiveMfaMode string `json:"effective_mfa_mode"`
+
 	ExternalResourceInfo
 }
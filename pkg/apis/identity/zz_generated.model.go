@@ -55,11 +55,13 @@ type SCredential struct {
 // SDomain is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SDomain.
 type SDomain struct {
 	apis.SStandaloneResourceBase
-	Extra    interface{} `json:"extra"`
-	Enabled  *bool       `json:"enabled,omitempty"`
-	IsDomain *bool       `json:"is_domain,omitempty"`
-	DomainId string      `json:"domain_id"`
-	ParentId string      `json:"parent_id"`
+	Extra     interface{} `json:"extra"`
+	Enabled   *bool       `json:"enabled,omitempty"`
+	IsDomain  *bool       `json:"is_domain,omitempty"`
+	DomainId  string      `json:"domain_id"`
+	ParentId  string      `json:"parent_id"`
+	EnableMfa *bool       `json:"enable_mfa,omitempty"`
+	MfaMode   string      `json:"mfa_mode"`
 }
 
 // SEnabledIdentityBaseResource is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SEnabledIdentityBaseResource.
@@ -142,6 +144,8 @@ type SIdentityProvider struct {
 	IsSso *bool `json:"is_sso,omitempty"`
 	// 是否是缺省SSO登录方式
 	IsDefault *bool `json:"is_default,omitempty"`
+	// 是否启用SCIM推送用户和组
+	ScimEnabled *bool `json:"scim_enabled,omitempty"`
 }
 
 // SIdmapping is an autogenerated struct via yunion.io/x/onecloud/pkg/keystone/models.SIdmapping.
@@ -292,6 +296,7 @@ type SUser struct {
 	DefaultProjectId string `json:"default_project_id"`
 	AllowWebConsole  *bool  `json:"allow_web_console,omitempty"`
 	EnableMfa        *bool  `json:"enable_mfa,omitempty"`
+	MfaMode          string `json:"mfa_mode"`
 	Lang             string `json:"lang"`
 }
 
@@ -183,6 +183,8 @@ type EvalMatch struct {
 	Metric    string            `json:"metric"`
 	Tags      map[string]string `json:"tags"`
 	Unit      string            `json:"unit"`
+	// 匹配到的静默规则 Id
+	SilenceId string `json:"silence_id,omitempty"`
 }
 
 type AlertTestRunOutput struct {
@@ -5,6 +5,8 @@ import "yunion.io/x/onecloud/pkg/apis"
 const (
 	SEND_STATE_OK     = "ok"
 	SEND_STATE_SILENT = "silent"
+	// 匹配到静默规则, 没有发送通知
+	SEND_STATE_SILENCED = "silenced"
 )
 
 type AlertRecordListInput struct {
@@ -19,6 +21,8 @@ type AlertRecordListInput struct {
 	State    string `json:"state"`
 	ResType  string `json:"res_type"`
 	Alerting bool   `json:"alerting"`
+	// 通知发送状态, 比如: ok, silent, silenced
+	SendState string `json:"send_state"`
 }
 
 type AlertRecordDetails struct {
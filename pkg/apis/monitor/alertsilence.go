@@ -0,0 +1,67 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"time"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+type AlertSilenceCreateInput struct {
+	apis.StatusStandaloneResourceCreateInput
+
+	// 静默的报警 Id, 为空时匹配所有报警
+	AlertId string `json:"alert_id"`
+	// 静默的资源 Id, 比如宿主机或虚拟机 Id, 为空时匹配所有资源
+	ResIds []string `json:"res_ids"`
+	// 资源标签匹配条件, 所有标签都相等时才匹配
+	Tags map[string]string `json:"tags"`
+	// 静默开始时间, 默认为当前时间
+	StartTime time.Time `json:"start_time"`
+	// 静默结束时间
+	EndTime time.Time `json:"end_time"`
+	// 备注, 比如维护原因
+	Comment string `json:"comment"`
+}
+
+type AlertSilenceUpdateInput struct {
+	apis.StatusStandaloneResourceBaseUpdateInput
+
+	ResIds    []string          `json:"res_ids"`
+	Tags      map[string]string `json:"tags"`
+	StartTime *time.Time        `json:"start_time"`
+	EndTime   *time.Time        `json:"end_time"`
+	Comment   *string           `json:"comment"`
+}
+
+type AlertSilenceListInput struct {
+	apis.StatusStandaloneResourceListInput
+	apis.EnabledResourceBaseListInput
+	apis.ScopedResourceBaseListInput
+
+	AlertId string `json:"alert_id"`
+	// 只列出当前生效的静默
+	Active *bool `json:"active"`
+}
+
+type AlertSilenceDetails struct {
+	apis.StatusStandaloneResourceDetails
+	apis.ScopedResourceBaseInfo
+
+	AlertName string `json:"alert_name"`
+	// 当前是否生效
+	Active bool `json:"active"`
+}
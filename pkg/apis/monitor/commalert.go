@@ -67,10 +67,17 @@ type CommonAlertQuery struct {
 	*AlertQuery
 	// metric points'value的运算方式
 	Reduce string `json:"reduce"`
+	// 运算方式的参数, 例如 percentile 的百分位数
+	ReduceParams []float64 `json:"reduce_params"`
 	// 比较运算符, 比如: >, <, >=, <=
+	// 或基线比较: baseline_stddev, threshold 为标准差的倍数; baseline_history, threshold 为对比的天数
 	Comparator string `json:"comparator"`
 	// 报警阀值
 	Threshold float64 `json:"threshold"`
+	// 基线比较的其余参数, baseline_stddev 为计算基线的小时数, baseline_history 为允许偏离的百分比
+	BaselineParams []float64 `json:"baseline_params"`
+	// 基线比较的方向: above, below, 为空时双向比较
+	BaselineDirection string `json:"baseline_direction"`
 	//field yunsuan
 	FieldOpt      string `json:"field_opt"`
 	ConditionType string `json:"condition_type"`
@@ -126,13 +133,17 @@ type CommonAlertDetails struct {
 }
 
 type CommonAlertMetricDetails struct {
-	Comparator    string    `json:"comparator"`
-	Threshold     float64   `json:"threshold"`
-	WithinRange   []float64 `json:"within_range"`
-	ConditionType string    `json:"condition_type"`
-	ThresholdStr  string    `json:"threshold_str"`
+	Comparator  string    `json:"comparator"`
+	Threshold   float64   `json:"threshold"`
+	WithinRange []float64 `json:"within_range"`
+	// 基线比较的参数和方向
+	BaselineParams    []float64 `json:"baseline_params"`
+	BaselineDirection string    `json:"baseline_direction"`
+	ConditionType     string    `json:"condition_type"`
+	ThresholdStr      string    `json:"threshold_str"`
 	// metric points'value的运算方式
 	Reduce                 string           `json:"reduce"`
+	ReduceParams           []float64        `json:"reduce_params"`
 	DB                     string           `json:"db"`
 	Measurement            string           `json:"measurement"`
 	MeasurementDisplayName string           `json:"measurement_display_name"`
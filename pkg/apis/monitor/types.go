@@ -14,10 +14,31 @@
 
 package monitor
 
+import (
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
 const (
-	DataSourceTypeInfluxdb = "influxdb"
+	DataSourceTypeInfluxdb   = "influxdb"
+	DataSourceTypePrometheus = "prometheus"
 )
 
+type DataSourceCreateInput struct {
+	apis.StandaloneResourceCreateInput
+
+	// 数据源类型
+	// enum: influxdb, prometheus
+	Type string `json:"type"`
+	// 数据源地址, prometheus 为 http api 的地址, 例如: http://192.168.0.1:9090
+	Url string `json:"url"`
+	// 认证用户名
+	User string `json:"user"`
+	// 认证密码
+	Password string `json:"password"`
+	// 默认数据库, prometheus 忽略此参数
+	Database string `json:"database"`
+}
+
 type DataSourceConfig struct {
 	Id     string
 	Name   string
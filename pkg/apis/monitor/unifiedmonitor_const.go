@@ -35,15 +35,20 @@ var (
 		METRIC_RES_TYPE_STORAGE:      "storage_id",
 	}
 	AlertReduceFunc = map[string]string{
-		"avg":          "average value",
-		"sum":          "Summation",
-		"min":          "minimum value",
-		"max":          "Maximum",
-		"count":        "count value",
-		"last":         "Latest value",
-		"median":       "median",
-		"diff":         "The difference between the latest value and the oldest value. The judgment basis value must be legal",
-		"percent_diff": "The difference between the new value and the old value,based on the percentage of the old value",
+		"avg":            "average value",
+		"sum":            "Summation",
+		"min":            "minimum value",
+		"max":            "Maximum",
+		"count":          "count value",
+		"last":           "Latest value",
+		"median":         "median",
+		"diff":           "The difference between the latest value and the oldest value. The judgment basis value must be legal",
+		"percent_diff":   "The difference between the new value and the old value,based on the percentage of the old value",
+		"percentile":     "The Nth percentile of values, N is the first reduce param, default 95",
+		"rate":           "Per-second increase of a counter, counter resets are handled",
+		"delta":          "The latest value minus the oldest value",
+		"stddev":         "Population standard deviation",
+		"count_non_null": "count of not null value",
 	}
 )
 
@@ -114,6 +114,23 @@ type SAlertResourceJointsBase struct {
 	AlertResourceId string `json:"alert_resource_id"`
 }
 
+// SAlertSilence is an autogenerated struct via yunion.io/x/onecloud/pkg/monitor/models.SAlertSilence.
+type SAlertSilence struct {
+	apis.SEnabledResourceBase
+	apis.SStatusStandaloneResourceBase
+	SMonitorScopedResource
+	// AlertId is empty for all alerts
+	AlertId string `json:"alert_id"`
+	// ResIds matches the resource id tags of the eval match, e.g. host_id or vm_id
+	ResIds interface{} `json:"res_ids"`
+	// Tags all equal to the eval match tags
+	Tags      interface{} `json:"tags"`
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	CreatedBy string      `json:"created_by"`
+	Comment   string      `json:"comment"`
+}
+
 // SAlertnotification is an autogenerated struct via yunion.io/x/onecloud/pkg/monitor/models.SAlertnotification.
 type SAlertnotification struct {
 	SAlertJointsBase
@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/pkg/trace"
 
 	"yunion.io/x/onecloud/pkg/i18n"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type AppContextKey string
@@ -124,6 +125,15 @@ func AppContextTaskId(ctx context.Context) string {
 	}
 }
 
+func AppContextTaskName(ctx context.Context) string {
+	val := ctx.Value(APP_CONTEXT_KEY_TASKNAME)
+	if val != nil {
+		return val.(string)
+	} else {
+		return ""
+	}
+}
+
 func AppContextTaskNotifyUrl(ctx context.Context) string {
 	val := ctx.Value(APP_CONTEXT_KEY_TASK_NOTIFY_URL)
 	if val != nil {
@@ -178,10 +188,12 @@ type AppContextData struct {
 	TaskNotifyUrl string
 	ServiceName   string
 	Lang          string
+	// TraceParent is the W3C trace context of the current span
+	TraceParent string
 }
 
 func (self *AppContextData) IsZero() bool {
-	return len(self.TaskNotifyUrl) == 0 && len(self.TaskId) == 0 && len(self.ObjectId) == 0 && len(self.ObjectType) == 0 && len(self.RequestId) == 0 && self.Trace.IsZero() && len(self.ServiceName) == 0
+	return len(self.TaskNotifyUrl) == 0 && len(self.TaskId) == 0 && len(self.ObjectId) == 0 && len(self.ObjectType) == 0 && len(self.RequestId) == 0 && self.Trace.IsZero() && len(self.ServiceName) == 0 && len(self.TraceParent) == 0
 }
 
 func FetchAppContextData(ctx context.Context) AppContextData {
@@ -206,6 +218,7 @@ func FetchAppContextData(ctx context.Context) AppContextData {
 		TaskNotifyUrl: taskNotifyUrl,
 		ServiceName:   serviceName,
 		Lang:          lang,
+		TraceParent:   tracing.SpanContextFromContext(ctx).Traceparent(),
 	}
 }
 
@@ -235,5 +248,10 @@ func (self *AppContextData) GetContext() context.Context {
 	if len(self.Lang) > 0 {
 		ctx = i18n.WithLang(ctx, self.Lang)
 	}
+	if len(self.TraceParent) > 0 {
+		if sc, err := tracing.ParseTraceparent(self.TraceParent); err == nil {
+			ctx = tracing.ContextWithRemoteParent(ctx, sc)
+		}
+	}
 	return ctx
 }
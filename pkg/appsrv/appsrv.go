@@ -41,6 +41,7 @@ import (
 	"yunion.io/x/onecloud/pkg/i18n"
 	"yunion.io/x/onecloud/pkg/proxy"
 	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type Application struct {
@@ -226,6 +227,7 @@ func (app *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	duration := float64(time.Since(start).Nanoseconds()) / 1000000
 	counter.hit += 1
 	counter.duration += duration
+	app.observeRequest(hi, r.Method, lrw.status, duration/1000)
 	skipLog := false
 	if params != nil {
 		if params.SkipLog {
@@ -285,6 +287,15 @@ func (t *appTask) Run() {
 				}
 			}()
 			t.ctx = context.WithValue(t.ctx, appctx.APP_CONTEXT_KEY_TRACE, span)
+
+			var otSpan *tracing.SSpan
+			t.ctx, otSpan = tracing.StartServerSpan(t.ctx, t.r, fmt.Sprintf("%s %s", t.r.Method, t.hand.GetName(nil)))
+			otSpan.SetAttribute("service.name", t.app.GetName())
+			otSpan.SetAttribute("request_id", t.rid)
+			defer func() {
+				otSpan.SetStatusCode(t.fw.status)
+				otSpan.End()
+			}()
 			t.hand.handler(t.ctx, &t.fw, t.r)
 		}()
 	} // otherwise, the task has been timeout
@@ -390,6 +401,7 @@ func (app *Application) addDefaultHandlers() {
 	app.AddDefaultHandler("POST", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/worker_stats", WorkerStatsHandler, "worker_stats")
+	app.AddDefaultHandler("GET", "/metrics", MetricsHandler, "metrics")
 }
 
 func timeoutHandle(h http.Handler) http.HandlerFunc {
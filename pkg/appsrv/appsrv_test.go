@@ -77,6 +77,14 @@ func (suite *ApplicationTestSuit) TestHandler() {
 	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/delaypanic", nil, "the handler is delay panic"))
 }
 
+func (suite *ApplicationTestSuit) TestMetrics() {
+	app := suite.app
+	app.addDefaultHandlers()
+	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/delay", nil, "delay pong"))
+	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/metrics", nil, `appsrv_request_duration_seconds_count{app="test",code="2xx",handler="get_delay",method="GET"}`))
+	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/metrics", nil, "appsrv_worker_queue_length"))
+}
+
 func TestApplicationTestSuite(t *testing.T) {
 	suite.Run(t, new(ApplicationTestSuit))
 }
@@ -0,0 +1,115 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "appsrv",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the requests by handler and status class",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"app", "method", "handler", "code"},
+	)
+
+	workerQueueDesc = prometheus.NewDesc(
+		"appsrv_worker_queue_length", "Tasks waiting in the queue of the worker manager",
+		[]string{"worker"}, nil,
+	)
+	workerActiveDesc = prometheus.NewDesc(
+		"appsrv_worker_active", "Active workers of the worker manager",
+		[]string{"worker"}, nil,
+	)
+	workerDetachedDesc = prometheus.NewDesc(
+		"appsrv_worker_detached", "Detached workers of the worker manager",
+		[]string{"worker"}, nil,
+	)
+	workerMaxDesc = prometheus.NewDesc(
+		"appsrv_worker_max", "Max workers of the worker manager",
+		[]string{"worker"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(workerCollector{})
+}
+
+func statusClass(status int) string {
+	switch {
+	case status < 400:
+		return "2xx"
+	case status < 500:
+		return "4xx"
+	default:
+		return "5xx"
+	}
+}
+
+func (app *Application) observeRequest(hi *SHandlerInfo, method string, status int, seconds float64) {
+	requestDuration.WithLabelValues(app.GetName(), method, hi.GetName(nil), statusClass(status)).Observe(seconds)
+}
+
+// workerCollector collects the states of all the worker managers on scraping
+type workerCollector struct{}
+
+func (c workerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerQueueDesc
+	ch <- workerActiveDesc
+	ch <- workerDetachedDesc
+	ch <- workerMaxDesc
+}
+
+func (c workerCollector) Collect(ch chan<- prometheus.Metric) {
+	workerManagerLock.Lock()
+	managers := make([]*SWorkerManager, len(workerManagers))
+	copy(managers, workerManagers)
+	workerManagerLock.Unlock()
+
+	// the worker managers of the applications in a process may share the name
+	states := make(map[string]*SWorkerManagerStates)
+	for _, wm := range managers {
+		state := wm.getState()
+		if s, ok := states[state.Name]; ok {
+			s.QueueCnt += state.QueueCnt
+			s.ActiveWorkerCnt += state.ActiveWorkerCnt
+			s.DetachWorkerCnt += state.DetachWorkerCnt
+			s.MaxWorkerCnt += state.MaxWorkerCnt
+		} else {
+			states[state.Name] = &state
+		}
+	}
+	for _, state := range states {
+		ch <- prometheus.MustNewConstMetric(workerQueueDesc, prometheus.GaugeValue, float64(state.QueueCnt), state.Name)
+		ch <- prometheus.MustNewConstMetric(workerActiveDesc, prometheus.GaugeValue, float64(state.ActiveWorkerCnt), state.Name)
+		ch <- prometheus.MustNewConstMetric(workerDetachedDesc, prometheus.GaugeValue, float64(state.DetachWorkerCnt), state.Name)
+		ch <- prometheus.MustNewConstMetric(workerMaxDesc, prometheus.GaugeValue, float64(state.MaxWorkerCnt), state.Name)
+	}
+}
+
+// MetricsHandler exposes the metrics registered to the default prometheus registry,
+// it is served at /metrics, except for monitor serving it at /prometheus/metrics
+func MetricsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	promhttp.Handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SRate is the rate of a token bucket, Qps tokens are refilled every second
+// and at most Burst tokens are kept
+type SRate struct {
+	Qps   float64
+	Burst int
+}
+
+func (rate SRate) IsValid() bool {
+	return rate.Qps > 0 && rate.Burst > 0
+}
+
+// FullAfter returns the duration an empty bucket takes to be full, a bucket
+// untouched for that long is identical to a new one and can be dropped
+func (rate SRate) FullAfter() time.Duration {
+	return time.Duration(float64(rate.Burst) / rate.Qps * float64(time.Second))
+}
+
+// SBucket is the state of a token bucket, it is marshalled as is by the
+// stores sharing the buckets among the replicas
+type SBucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// SLimit is a token bucket of a request, it is kept by the stores by key
+type SLimit struct {
+	Key  string
+	Rate SRate
+}
+
+func (b *SBucket) refill(rate SRate, now time.Time) {
+	if b.Last.IsZero() {
+		b.Tokens = float64(rate.Burst)
+	} else if now.After(b.Last) {
+		b.Tokens += now.Sub(b.Last).Seconds() * rate.Qps
+		if b.Tokens > float64(rate.Burst) {
+			b.Tokens = float64(rate.Burst)
+		}
+	}
+	if now.After(b.Last) {
+		b.Last = now
+	}
+}
+
+func (b *SBucket) wait(rate SRate) time.Duration {
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) / rate.Qps * float64(time.Second))
+}
+
+// Take refills the bucket up to now and takes a token out of it. If no token
+// is left, the duration to wait for the next token is returned
+func (b *SBucket) Take(rate SRate, now time.Time) (bool, time.Duration) {
+	rejected, wait := TakeAll([]*SBucket{b}, []SRate{rate}, now)
+	return rejected < 0, wait
+}
+
+// TakeAll refills the buckets up to now and takes a token out of every one of
+// them, or out of none of them if any is empty, so a request rejected by a
+// bucket doesn't consume the others. The index of the empty bucket and the
+// duration to wait for its next token are returned, the index is -1 if the
+// tokens are taken
+func TakeAll(buckets []*SBucket, rates []SRate, now time.Time) (int, time.Duration) {
+	for i := range buckets {
+		buckets[i].refill(rates[i], now)
+	}
+	for i := range buckets {
+		if wait := buckets[i].wait(rates[i]); wait > 0 {
+			return i, wait
+		}
+	}
+	for i := range buckets {
+		buckets[i].Tokens -= 1
+	}
+	return -1, 0
+}
+
+// IStore keeps the token buckets of the keys
+type IStore interface {
+	// Take takes a token out of the buckets of the limits all together, see
+	// TakeAll
+	Take(ctx context.Context, limits []SLimit, now time.Time) (int, time.Duration, error)
+}
+
+const (
+	memoryStoreSweepInterval = time.Minute
+)
+
+// SMemoryStore keeps the buckets in the process, the limits are enforced
+// per replica
+type SMemoryStore struct {
+	lock      sync.Mutex
+	buckets   map[string]*SBucket
+	fullAt    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *SMemoryStore {
+	return &SMemoryStore{
+		buckets: make(map[string]*SBucket),
+		fullAt:  make(map[string]time.Time),
+	}
+}
+
+func (s *SMemoryStore) Take(ctx context.Context, limits []SLimit, now time.Time) (int, time.Duration, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sweep(now)
+
+	buckets := make([]*SBucket, len(limits))
+	rates := make([]SRate, len(limits))
+	for i, limit := range limits {
+		b, ok := s.buckets[limit.Key]
+		if !ok {
+			b = &SBucket{}
+			s.buckets[limit.Key] = b
+		}
+		buckets[i] = b
+		rates[i] = limit.Rate
+	}
+	rejected, wait := TakeAll(buckets, rates, now)
+	for i, limit := range limits {
+		s.fullAt[limit.Key] = buckets[i].Last.Add(limit.Rate.FullAfter())
+	}
+	return rejected, wait, nil
+}
+
+// sweep drops the buckets that have been refilled, they are identical to the
+// new ones
+func (s *SMemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryStoreSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, fullAt := range s.fullAt {
+		if now.After(fullAt) {
+			delete(s.buckets, key)
+			delete(s.fullAt, key)
+		}
+	}
+}
+
+func (s *SMemoryStore) size() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.buckets)
+}
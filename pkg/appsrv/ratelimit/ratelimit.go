@@ -0,0 +1,264 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	RATE_LIMIT_CLASS_READ  = "read"
+	RATE_LIMIT_CLASS_WRITE = "write"
+
+	RATE_LIMIT_SCOPE_USER    = "user"
+	RATE_LIMIT_SCOPE_PROJECT = "project"
+	RATE_LIMIT_SCOPE_IP      = "ip"
+)
+
+// SConfig is the rates of the handler classes, the rates of a project are
+// the rates of a user multiplied by ProjectFactor
+type SConfig struct {
+	Read  SRate
+	Write SRate
+
+	// ProjectFactor disables the project level limiting if not positive
+	ProjectFactor     float64
+	ExemptSystemAdmin bool
+
+	// TrustedProxies are the ips or cidrs of the proxies whose X-Forwarded-For
+	// and X-Real-Ip headers are trusted, the headers of the other peers are ignored
+	TrustedProxies []string
+}
+
+type SLimiter struct {
+	config         SConfig
+	store          IStore
+	trustedProxies []*net.IPNet
+}
+
+var (
+	defaultLimiter *SLimiter
+	limiterLock    sync.RWMutex
+
+	rejectCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "appsrv",
+			Name:      "rate_limit_rejected_total",
+			Help:      "Requests rejected by the rate limiter",
+		},
+		[]string{"class", "scope"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rejectCounter)
+}
+
+// Init turns on the rate limiting of the authenticated handlers, the buckets
+// are kept in memory until SetStore is called
+func Init(config SConfig) {
+	limiterLock.Lock()
+	defer limiterLock.Unlock()
+
+	store := IStore(NewMemoryStore())
+	if defaultLimiter != nil {
+		store = defaultLimiter.store
+	}
+	defaultLimiter = NewLimiter(config, store)
+}
+
+// SetStore replaces the store of the rate limiter, e.g. with the one shared
+// by the replicas of the service
+func SetStore(store IStore) {
+	limiterLock.Lock()
+	defer limiterLock.Unlock()
+
+	if defaultLimiter == nil {
+		log.Warningf("rate limiter not initialized, store ignored")
+		return
+	}
+	defaultLimiter.store = store
+}
+
+func getLimiter() *SLimiter {
+	limiterLock.RLock()
+	defer limiterLock.RUnlock()
+
+	return defaultLimiter
+}
+
+func NewLimiter(config SConfig, store IStore) *SLimiter {
+	l := &SLimiter{
+		config: config,
+		store:  store,
+	}
+	for _, proxy := range config.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Warningf("invalid trusted proxy %s: %v", proxy, err)
+			continue
+		}
+		l.trustedProxies = append(l.trustedProxies, ipNet)
+	}
+	return l
+}
+
+func handlerClass(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RATE_LIMIT_CLASS_READ
+	default:
+		return RATE_LIMIT_CLASS_WRITE
+	}
+}
+
+func (l *SLimiter) isTrustedProxy(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range l.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIp returns the client ip of the request. The forwarded headers can
+// be forged by any client, so they are honoured only if the peer is a trusted
+// proxy, and the nearest address not of a trusted proxy is taken
+func (l *SLimiter) requestIp(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		peer = host
+	}
+	if !l.isTrustedProxy(peer) {
+		return peer
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); len(fwd) > 0 {
+		ips := strings.Split(fwd, ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(ips[i])
+			if i == 0 || !l.isTrustedProxy(ip) {
+				return ip
+			}
+		}
+	}
+	if ip := r.Header.Get("X-Real-Ip"); len(ip) > 0 {
+		return ip
+	}
+	return peer
+}
+
+type sLimitKey struct {
+	scope string
+	id    string
+	rate  SRate
+}
+
+func (l *SLimiter) keys(r *http.Request, token mcclient.TokenCredential, guest bool) (string, []sLimitKey) {
+	class := handlerClass(r.Method)
+	rate := l.config.Read
+	if class == RATE_LIMIT_CLASS_WRITE {
+		rate = l.config.Write
+	}
+	if !rate.IsValid() {
+		return class, nil
+	}
+	if token == nil || guest {
+		return class, []sLimitKey{{scope: RATE_LIMIT_SCOPE_IP, id: l.requestIp(r), rate: rate}}
+	}
+	if l.config.ExemptSystemAdmin && token.HasSystemAdminPrivilege() {
+		return class, nil
+	}
+	keys := []sLimitKey{{scope: RATE_LIMIT_SCOPE_USER, id: token.GetUserId(), rate: rate}}
+	if l.config.ProjectFactor > 0 && len(token.GetProjectId()) > 0 {
+		keys = append(keys, sLimitKey{
+			scope: RATE_LIMIT_SCOPE_PROJECT,
+			id:    token.GetProjectId(),
+			rate: SRate{
+				Qps:   rate.Qps * l.config.ProjectFactor,
+				Burst: int(math.Ceil(float64(rate.Burst) * l.config.ProjectFactor)),
+			},
+		})
+	}
+	return class, keys
+}
+
+// Allow takes a token out of the buckets of the user, the project and the
+// client ip of the request all together. Errors of the store are logged and
+// let the request pass, as an unavailable store shall not take the API down
+func (l *SLimiter) Allow(ctx context.Context, r *http.Request, token mcclient.TokenCredential, guest bool) (bool, string, time.Duration) {
+	class, keys := l.keys(r, token, guest)
+	if len(keys) == 0 {
+		return true, "", 0
+	}
+	limits := make([]SLimit, len(keys))
+	for i, k := range keys {
+		limits[i] = SLimit{
+			Key:  fmt.Sprintf("%s/%s/%s", class, k.scope, k.id),
+			Rate: k.rate,
+		}
+	}
+	rejected, wait, err := l.store.Take(ctx, limits, time.Now())
+	if err != nil {
+		log.Errorf("rate limit %s: %v", limits[0].Key, err)
+		return true, "", 0
+	}
+	if rejected >= 0 {
+		k := keys[rejected]
+		rejectCounter.WithLabelValues(class, k.scope).Inc()
+		return false, fmt.Sprintf("%s rate of %s %s exceeded", class, k.scope, k.id), wait
+	}
+	return true, "", 0
+}
+
+// Check returns false and responds 429 if the request exceeds the rate
+// limits, it always passes if the rate limiting is not turned on
+func Check(ctx context.Context, w http.ResponseWriter, r *http.Request, token mcclient.TokenCredential, guest bool) bool {
+	l := getLimiter()
+	if l == nil {
+		return true
+	}
+	allowed, msg, wait := l.Allow(ctx, r, token, guest)
+	if allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+	httperrors.TooManyRequestsError(ctx, w, "%s", msg)
+	return false
+}
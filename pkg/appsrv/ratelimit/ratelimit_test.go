@@ -0,0 +1,173 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func TestSBucket_Take(t *testing.T) {
+	rate := SRate{Qps: 2, Burst: 3}
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		after   time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 500 * time.Millisecond},
+		{250 * time.Millisecond, false, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, false, 500 * time.Millisecond},
+	}
+	bucket := SBucket{}
+	for i, c := range cases {
+		allowed, wait := bucket.Take(rate, start.Add(c.after))
+		if allowed != c.allowed || wait != c.wait {
+			t.Errorf("take %d after %s: want %v %s, got %v %s", i, c.after, c.allowed, c.wait, allowed, wait)
+		}
+	}
+}
+
+func TestSMemoryStore_Sweep(t *testing.T) {
+	store := NewMemoryStore()
+	rate := SRate{Qps: 1, Burst: 10}
+	now := time.Now()
+	store.Take(context.Background(), []SLimit{{Key: "a", Rate: rate}}, now)
+	store.Take(context.Background(), []SLimit{{Key: "b", Rate: rate}}, now.Add(memoryStoreSweepInterval-5*time.Second))
+	if store.size() != 2 {
+		t.Fatalf("want 2 buckets, got %d", store.size())
+	}
+	store.Take(context.Background(), []SLimit{{Key: "b", Rate: rate}}, now.Add(memoryStoreSweepInterval+time.Second))
+	if store.size() != 1 {
+		t.Errorf("want the full bucket swept, got %d buckets", store.size())
+	}
+}
+
+func TestSMemoryStore_TakeAll(t *testing.T) {
+	store := NewMemoryStore()
+	limits := []SLimit{
+		{Key: "user", Rate: SRate{Qps: 0.1, Burst: 2}},
+		{Key: "project", Rate: SRate{Qps: 1, Burst: 1}},
+	}
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		after    time.Duration
+		rejected int
+		wait     time.Duration
+	}{
+		{0, -1, 0},
+		{0, 1, time.Second},
+		// the user token is kept when the project bucket rejects
+		{time.Second, -1, 0},
+	}
+	for i, c := range cases {
+		rejected, wait, err := store.Take(context.Background(), limits, now.Add(c.after))
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+		if rejected != c.rejected || wait != c.wait {
+			t.Errorf("take %d after %s: want %d %s, got %d %s", i, c.after, c.rejected, c.wait, rejected, wait)
+		}
+	}
+}
+
+func TestSLimiter_Allow(t *testing.T) {
+	limiter := NewLimiter(SConfig{
+		Read:              SRate{Qps: 1, Burst: 2},
+		Write:             SRate{Qps: 1, Burst: 1},
+		ProjectFactor:     1.5,
+		ExemptSystemAdmin: true,
+	}, NewMemoryStore())
+	alice := &mcclient.SSimpleToken{UserId: "alice", ProjectId: "p1"}
+	bob := &mcclient.SSimpleToken{UserId: "bob", ProjectId: "p1"}
+	admin := &mcclient.SSimpleToken{UserId: "admin", ProjectId: "system", Project: "system", Roles: "admin"}
+	cases := []struct {
+		name    string
+		method  string
+		token   mcclient.TokenCredential
+		guest   bool
+		allowed bool
+	}{
+		{"alice read", "GET", alice, false, true},
+		{"alice read", "GET", alice, false, true},
+		{"user burst exceeded", "GET", alice, false, false},
+		{"bob read", "GET", bob, false, true},
+		{"project burst exceeded", "GET", bob, false, false},
+		{"write class has its own bucket", "POST", bob, false, true},
+		{"write burst exceeded", "DELETE", bob, false, false},
+		{"guest by ip", "GET", nil, true, true},
+		{"guest by ip", "GET", nil, true, true},
+		{"guest burst exceeded", "GET", nil, true, false},
+	}
+	for i := 0; i < 3; i++ {
+		cases = append(cases, struct {
+			name    string
+			method  string
+			token   mcclient.TokenCredential
+			guest   bool
+			allowed bool
+		}{"system admin exempted", "POST", admin, false, true})
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/servers", nil)
+		allowed, msg, _ := limiter.Allow(context.Background(), r, c.token, c.guest)
+		if allowed != c.allowed {
+			t.Errorf("%s: want %v, got %v %s", c.name, c.allowed, allowed, msg)
+		}
+	}
+}
+
+func TestSLimiter_requestIp(t *testing.T) {
+	limiter := NewLimiter(SConfig{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}}, NewMemoryStore())
+	cases := []struct {
+		name    string
+		peer    string
+		forward string
+		realIp  string
+		want    string
+	}{
+		{name: "direct", peer: "1.2.3.4:5678", want: "1.2.3.4"},
+		{name: "forged forward", peer: "1.2.3.4:5678", forward: "5.6.7.8", realIp: "5.6.7.8", want: "1.2.3.4"},
+		{name: "trusted proxy", peer: "10.0.0.1:5678", forward: "5.6.7.8", want: "5.6.7.8"},
+		{name: "forged by client behind proxies", peer: "10.0.0.1:5678", forward: "9.9.9.9, 5.6.7.8, 192.168.1.1", want: "5.6.7.8"},
+		{name: "real ip from trusted proxy", peer: "192.168.2.3:5678", realIp: "5.6.7.8", want: "5.6.7.8"},
+		{name: "trusted proxy without header", peer: "10.0.0.1:5678", want: "10.0.0.1"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/servers", nil)
+		req.RemoteAddr = c.peer
+		if len(c.forward) > 0 {
+			req.Header.Set("X-Forwarded-For", c.forward)
+		}
+		if len(c.realIp) > 0 {
+			req.Header.Set("X-Real-Ip", c.realIp)
+		}
+		if got := limiter.requestIp(req); got != c.want {
+			t.Errorf("%s: want %s got %s", c.name, c.want, got)
+		}
+	}
+}
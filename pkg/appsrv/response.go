@@ -37,6 +37,8 @@ type responseWriterChannel struct {
 	statusChan chan int
 	statusResp chan bool
 
+	// status is the status written by the handler, 200 if not written
+	status   int
 	isClosed bool
 }
 
@@ -47,6 +49,7 @@ func newResponseWriterChannel(backend http.ResponseWriter) responseWriterChannel
 		bodyResp:   make(chan responseWriterResponse),
 		statusChan: make(chan int),
 		statusResp: make(chan bool),
+		status:     http.StatusOK,
 		isClosed:   false,
 	}
 }
@@ -68,6 +71,7 @@ func (w *responseWriterChannel) WriteHeader(status int) {
 	if w.isClosed {
 		return
 	}
+	w.status = status
 	w.statusChan <- status
 	<-w.statusResp
 }
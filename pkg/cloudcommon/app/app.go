@@ -18,12 +18,18 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"yunion.io/x/log"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/appsrv/ratelimit"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/util/seclib2"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Application {
@@ -32,6 +38,33 @@ func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Applica
 	app := appsrv.NewApplication(options.ApplicationID, options.RequestWorkerCount, dbAccess)
 	app.CORSAllowHosts(options.CorsHosts)
 
+	if options.EnableRateLimit {
+		log.Infof("rate limit: read %f qps, write %f qps", options.RateLimitReadQps, options.RateLimitWriteQps)
+		ratelimit.Init(ratelimit.SConfig{
+			Read: ratelimit.SRate{
+				Qps:   options.RateLimitReadQps,
+				Burst: options.RateLimitReadBurst,
+			},
+			Write: ratelimit.SRate{
+				Qps:   options.RateLimitWriteQps,
+				Burst: options.RateLimitWriteBurst,
+			},
+			ProjectFactor:     options.RateLimitProjectFactor,
+			ExemptSystemAdmin: options.RateLimitExemptSystemAdmin,
+			TrustedProxies:    options.RateLimitTrustedProxies,
+		})
+	}
+
+	if len(options.TracingOtlpEndpoint) > 0 {
+		initTracing(options)
+	}
+
+	lockman.SetSlowLockThresholds(
+		time.Duration(options.SlowLockWaitSeconds)*time.Second,
+		time.Duration(options.SlowLockHoldSeconds)*time.Second,
+	)
+	app.AddDefaultHandler("GET", "/lock_stats", auth.Authenticate(lockStatsHandler), "lock_stats")
+
 	// app.SetContext(appsrv.APP_CONTEXT_KEY_CACHE, cache)
 	// if dbConn != nil {
 	//	app.SetContext(appsrv.APP_CONTEXT_KEY_DB, dbConn)
@@ -39,6 +72,27 @@ func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Applica
 	return app
 }
 
+func initTracing(options *common_options.BaseOptions) {
+	headers := make(map[string]string)
+	for _, h := range options.TracingOtlpHeaders {
+		pos := strings.IndexByte(h, '=')
+		if pos <= 0 {
+			log.Fatalf("invalid tracing otlp header %q, expect key=value", h)
+		}
+		headers[h[:pos]] = h[pos+1:]
+	}
+	log.Infof("export traces to %s, sample ratio %f", options.TracingOtlpEndpoint, options.TracingSampleRatio)
+	err := tracing.Init(tracing.SExporterOptions{
+		Endpoint:    options.TracingOtlpEndpoint,
+		ServiceName: options.ApplicationID,
+		SampleRatio: options.TracingSampleRatio,
+		Headers:     headers,
+	})
+	if err != nil {
+		log.Fatalf("init tracing: %v", err)
+	}
+}
+
 func ServeForever(app *appsrv.Application, options *common_options.BaseOptions) {
 	ServeForeverWithCleanup(app, options, nil)
 }
@@ -74,5 +128,11 @@ func ServeForeverExtended(app *appsrv.Application, options *common_options.BaseO
 		}
 		sslfile = options.SslKeyfile
 	}
-	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, onStop, isMaster)
+	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, func() {
+		if onStop != nil {
+			onStop()
+		}
+		// flush the spans of the requests
+		tracing.Shutdown()
+	}, isMaster)
 }
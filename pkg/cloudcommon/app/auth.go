@@ -68,6 +68,7 @@ func InitAuth(options *common_options.CommonOptions, authComplete auth.AuthCompl
 		auth.SetEndpointType(options.SessionEndpointType)
 	}
 
+	auth.SetTokenCacheExpireSeconds(options.TokenCacheExpireSeconds)
 	auth.Init(a, options.DebugClient, true, options.SslCertfile, options.SslKeyfile) // , authComplete)
 
 	users := options.NotifyAdminUsers
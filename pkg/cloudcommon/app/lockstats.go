@@ -0,0 +1,41 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+)
+
+// lockStatsHandler dumps the locks held or waited for in the service
+func lockStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	userCred := auth.FetchUserCredential(ctx, nil)
+	if userCred == nil || !userCred.HasSystemAdminPrivilege() {
+		httperrors.ForbiddenError(ctx, w, "not enough privilege")
+		return
+	}
+	holders := lockman.GetLockHolders()
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.Marshal(holders), "locks")
+	ret.Add(jsonutils.NewInt(int64(len(holders))), "total")
+	appsrv.SendJSON(w, ret)
+}
@@ -41,6 +41,9 @@ var (
 	historicalUniqueName = false
 
 	enableQuotaCheck = false
+
+	// zero means the service keeps no tasks in the database
+	taskStageWatchdogIntervalSeconds = 0
 )
 
 func SetRegion(region string) {
@@ -67,6 +70,14 @@ func GetTenantCacheExpireSeconds() time.Duration {
 	return time.Duration(tenantCacheExpireSeconds) * time.Second
 }
 
+func SetTaskStageWatchdogIntervalSeconds(sec int) {
+	taskStageWatchdogIntervalSeconds = sec
+}
+
+func GetTaskStageWatchdogInterval() time.Duration {
+	return time.Duration(taskStageWatchdogIntervalSeconds) * time.Second
+}
+
 func SetRoleCacheExpireHours(h int) {
 	roleCacheExpireHours = h
 }
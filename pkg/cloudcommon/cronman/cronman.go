@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
@@ -42,6 +43,15 @@ type TCronJobFunction func(ctx context.Context, userCred mcclient.TokenCredentia
 
 var manager *SCronJobManager
 
+// the jobs every service adds to its cron job manager
+var defaultJobs []func(manager *SCronJobManager)
+
+// RegisterDefaultJobs adds jobs to the cron job manager of every service
+// when it is initialized, e.g. the watchdog of the tasks
+func RegisterDefaultJobs(addJobs func(manager *SCronJobManager)) {
+	defaultJobs = append(defaultJobs, addJobs)
+}
+
 type ICronTimer interface {
 	Next(time.Time) time.Time
 }
@@ -54,6 +64,10 @@ func (t *Timer1) Next(now time.Time) time.Time {
 	return now.Add(t.dur)
 }
 
+func (t *Timer1) String() string {
+	return fmt.Sprintf("every %s", t.dur)
+}
+
 type Timer2 struct {
 	day, hour, min, sec int
 }
@@ -63,6 +77,10 @@ func (t *Timer2) Next(now time.Time) time.Time {
 	return time.Date(next.Year(), next.Month(), next.Day(), t.hour, t.min, t.sec, 0, next.Location())
 }
 
+func (t *Timer2) String() string {
+	return fmt.Sprintf("every %d days at %02d:%02d:%02d", t.day, t.hour, t.min, t.sec)
+}
+
 type TimerHour struct {
 	hour, min, sec int
 }
@@ -72,12 +90,22 @@ func (t *TimerHour) Next(now time.Time) time.Time {
 	return time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), t.min, t.sec, 0, next.Location())
 }
 
+func (t *TimerHour) String() string {
+	return fmt.Sprintf("every %d hours at xx:%02d:%02d", t.hour, t.min, t.sec)
+}
+
 type SCronJob struct {
 	Name     string
 	job      TCronJobFunction
 	Timer    ICronTimer
 	Next     time.Time
 	StartRun bool
+
+	historyLock  sync.Mutex
+	lastStart    time.Time
+	lastDuration time.Duration
+	lastError    string
+	runCount     int
 }
 
 type CronJobTimerHeap []*SCronJob
@@ -127,6 +155,9 @@ type SCronJobManager struct {
 	running  bool
 	workers  *appsrv.SWorkerManager
 	dataLock *sync.Mutex
+
+	// schedules overrides the timers of the jobs by name
+	schedules map[string]ICronTimer
 }
 
 func InitCronJobManager(isDbWorker bool, workerCount int) *SCronJobManager {
@@ -136,6 +167,11 @@ func InitCronJobManager(isDbWorker bool, workerCount int) *SCronJobManager {
 			workers:  appsrv.NewWorkerManager("CronJobWorkers", workerCount, 1024, isDbWorker),
 			dataLock: new(sync.Mutex),
 			add:      make(chan struct{}),
+
+			schedules: make(map[string]ICronTimer),
+		}
+		for _, addJobs := range defaultJobs {
+			addJobs(manager)
 		}
 	}
 	return manager
@@ -166,28 +202,10 @@ func (self *SCronJobManager) AddJobAtIntervalsWithStartRun(name string, interval
 	if interval <= 0 {
 		return errors.New("AddJobAtIntervals: interval must > 0")
 	}
-	self.dataLock.Lock()
-	defer self.dataLock.Unlock()
-
-	if !self.IsNameUnique(name) {
-		return ErrCronJobNameConflict
-	}
-
 	t := Timer1{
 		dur: interval,
 	}
-	job := SCronJob{
-		Name:     name,
-		job:      jobFunc,
-		Timer:    &t,
-		StartRun: startRun,
-	}
-	if !self.running {
-		self.jobs = append(self.jobs, &job)
-	} else {
-		self.addJob(&job)
-	}
-	return nil
+	return self.addJobWithTimer(name, &t, jobFunc, startRun)
 }
 
 func (self *SCronJobManager) AddJobEveryFewDays(name string, day, hour, min, sec int, jobFunc TCronJobFunction, startRun bool) error {
@@ -202,31 +220,13 @@ func (self *SCronJobManager) AddJobEveryFewDays(name string, day, hour, min, sec
 		return errors.New("AddJobEveryFewDays: sec must > 0")
 	}
 
-	self.dataLock.Lock()
-	defer self.dataLock.Unlock()
-
-	if !self.IsNameUnique(name) {
-		return ErrCronJobNameConflict
-	}
-
 	t := Timer2{
 		day:  day,
 		hour: hour,
 		min:  min,
 		sec:  sec,
 	}
-	job := SCronJob{
-		Name:     name,
-		job:      jobFunc,
-		Timer:    &t,
-		StartRun: startRun,
-	}
-	if !self.running {
-		self.jobs = append(self.jobs, &job)
-	} else {
-		self.addJob(&job)
-	}
-	return nil
+	return self.addJobWithTimer(name, &t, jobFunc, startRun)
 }
 
 func (self *SCronJobManager) AddJobEveryFewHour(name string, hour, min, sec int, jobFunc TCronJobFunction, startRun bool) error {
@@ -239,6 +239,46 @@ func (self *SCronJobManager) AddJobEveryFewHour(name string, hour, min, sec int,
 		return errors.New("AddJobEveryFewHour: sec must > 0")
 	}
 
+	t := TimerHour{
+		hour: hour,
+		min:  min,
+		sec:  sec,
+	}
+	return self.addJobWithTimer(name, &t, jobFunc, startRun)
+}
+
+// AddJobWithCronSpec adds a job scheduled by a cron expression, see ParseCronSpec
+func (self *SCronJobManager) AddJobWithCronSpec(name string, spec string, jobFunc TCronJobFunction, startRun bool) error {
+	t, err := ParseCronSpec(spec)
+	if err != nil {
+		return errors.Wrap(err, "AddJobWithCronSpec")
+	}
+	return self.addJobWithTimer(name, t, jobFunc, startRun)
+}
+
+// SetJobSchedules overrides the timers of the jobs with cron expressions,
+// every schedule is in the form of <job name>=<cron spec>. It applies to
+// the jobs added afterwards
+func (self *SCronJobManager) SetJobSchedules(schedules []string) error {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	for _, schedule := range schedules {
+		i := strings.Index(schedule, "=")
+		if i <= 0 {
+			return errors.Errorf("invalid cron job schedule %q, expect <name>=<cron spec>", schedule)
+		}
+		name := strings.TrimSpace(schedule[:i])
+		t, err := ParseCronSpec(schedule[i+1:])
+		if err != nil {
+			return errors.Wrapf(err, "schedule of cron job %s", name)
+		}
+		self.schedules[name] = t
+	}
+	return nil
+}
+
+func (self *SCronJobManager) addJobWithTimer(name string, timer ICronTimer, jobFunc TCronJobFunction, startRun bool) error {
 	self.dataLock.Lock()
 	defer self.dataLock.Unlock()
 
@@ -246,15 +286,14 @@ func (self *SCronJobManager) AddJobEveryFewHour(name string, hour, min, sec int,
 		return ErrCronJobNameConflict
 	}
 
-	t := TimerHour{
-		hour: hour,
-		min:  min,
-		sec:  sec,
+	if t, ok := self.schedules[name]; ok {
+		log.Infof("Cron job %s is scheduled by %s", name, t)
+		timer = t
 	}
 	job := SCronJob{
 		Name:     name,
 		job:      jobFunc,
-		Timer:    &t,
+		Timer:    timer,
 		StartRun: startRun,
 	}
 	if !self.running {
@@ -391,11 +430,15 @@ func (job *SCronJob) runJob(isStart bool) {
 }
 
 func (job *SCronJob) runJobInWorker(isStart bool) {
+	start := time.Now()
 	defer func() {
+		var errMsg string
 		if r := recover(); r != nil {
 			log.Errorf("CronJob task %s run error: %s", job.Name, r)
 			debug.PrintStack()
+			errMsg = fmt.Sprintf("%v", r)
 		}
+		job.saveHistory(start, time.Since(start), errMsg)
 	}()
 
 	log.Debugf("Cron job: %s started", job.Name)
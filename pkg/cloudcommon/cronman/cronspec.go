@@ -0,0 +1,246 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type cronBounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = cronBounds{name: "second", min: 0, max: 59}
+	minuteBounds = cronBounds{name: "minute", min: 0, max: 59}
+	hourBounds   = cronBounds{name: "hour", min: 0, max: 23}
+	domBounds    = cronBounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = cronBounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted as sunday as well
+	dowBounds = cronBounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// the search of the next time gives up after the years, e.g. for 0 0 30 2 *
+const cronSearchYears = 5
+
+// SCronSpecTimer is the timer of a standard cron expression, the fields are
+//
+//	[second] minute hour day-of-month month day-of-week
+//
+// a field accepts *, ?, lists, ranges and steps, e.g. 1,15 or 1-5 or */10,
+// month and day of week accept the english abbreviations, e.g. jan or mon.
+// The expression may be prefixed by TZ=<zone> or CRON_TZ=<zone>, the default
+// zone is the local one. The descriptors @yearly, @monthly, @weekly, @daily
+// and @hourly are supported as well.
+type SCronSpecTimer struct {
+	spec string
+
+	second, minute, hour, dom, month, dow uint64
+	location                              *time.Location
+}
+
+func ParseCronSpec(spec string) (*SCronSpecTimer, error) {
+	t := &SCronSpecTimer{
+		spec:     spec,
+		location: time.Local,
+	}
+	expr := strings.TrimSpace(spec)
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		i := strings.IndexAny(expr, " \t")
+		if i < 0 {
+			return nil, errors.Errorf("cron spec %q: missing fields after time zone", spec)
+		}
+		zone := expr[strings.Index(expr, "=")+1 : i]
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cron spec %q: load time zone %s", spec, zone)
+		}
+		t.location = loc
+		expr = strings.TrimSpace(expr[i:])
+	}
+	if strings.HasPrefix(expr, "@") {
+		desc, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, errors.Errorf("cron spec %q: unknown descriptor %s", spec, expr)
+		}
+		expr = desc
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, errors.Errorf("cron spec %q: expect 5 or 6 fields, got %d", spec, len(fields))
+	}
+	var err error
+	targets := []*uint64{&t.second, &t.minute, &t.hour, &t.dom, &t.month, &t.dow}
+	bounds := []cronBounds{secondBounds, minuteBounds, hourBounds, domBounds, monthBounds, dowBounds}
+	for i := range fields {
+		*targets[i], err = parseCronField(fields[i], bounds[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cron spec %q", spec)
+		}
+	}
+	// sunday is 0 for time.Weekday
+	if t.dow&(1<<7) != 0 {
+		t.dow = (t.dow | 1) &^ (1 << 7)
+	}
+	return t, nil
+}
+
+func (b cronBounds) parseValue(s string) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s %q", b.name, s)
+	}
+	if v < b.min || v > b.max {
+		return 0, errors.Errorf("%s %d out of range [%d, %d]", b.name, v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// parseCronField returns the bits of the values matched by the field
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid %s step %q", b.name, part[i+1:])
+			}
+			part = part[:i]
+		}
+		start, end := b.min, b.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			var err error
+			if start, err = b.parseValue(part[:i]); err != nil {
+				return 0, err
+			}
+			if end, err = b.parseValue(part[i+1:]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, errors.Errorf("invalid %s range %q", b.name, part)
+			}
+		default:
+			v, err := b.parseValue(part)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			// a single value with step means from the value to the max, e.g. 5/15
+			if step == 1 {
+				end = v
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (t *SCronSpecTimer) String() string {
+	return t.spec
+}
+
+func (t *SCronSpecTimer) dayMatches(now time.Time) bool {
+	domMatch := t.dom&(1<<uint(now.Day())) != 0
+	dowMatch := t.dow&(1<<uint(now.Weekday())) != 0
+	// like the vixie cron, the day matches either field if both are restricted
+	domAll := t.dom == domAllBits
+	dowAll := t.dow == dowAllBits
+	if !domAll && !dowAll {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+var (
+	domAllBits = mustParseCronField("*", domBounds)
+	dowAllBits = mustParseCronField("0-6", dowBounds)
+)
+
+func mustParseCronField(field string, b cronBounds) uint64 {
+	bits, err := parseCronField(field, b)
+	if err != nil {
+		panic(err)
+	}
+	return bits
+}
+
+// Next returns the first matched time after now, or the zero time if nothing matches
+// in the next years, the job of zero next time never runs
+func (t *SCronSpecTimer) Next(now time.Time) time.Time {
+	origLoc := now.Location()
+	next := now.In(t.location).Add(time.Second - time.Duration(now.Nanosecond()))
+	yearLimit := next.Year() + cronSearchYears
+
+	for next.Year() <= yearLimit {
+		if t.month&(1<<uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, t.location).AddDate(0, 1, 0)
+			continue
+		}
+		if !t.dayMatches(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, t.location).AddDate(0, 0, 1)
+			continue
+		}
+		if t.hour&(1<<uint(next.Hour())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), 0, 0, 0, t.location).Add(time.Hour)
+			continue
+		}
+		if t.minute&(1<<uint(next.Minute())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), 0, 0, t.location).Add(time.Minute)
+			continue
+		}
+		if t.second&(1<<uint(next.Second())) == 0 {
+			next = next.Add(time.Second)
+			continue
+		}
+		return next.In(origLoc)
+	}
+	return time.Time{}
+}
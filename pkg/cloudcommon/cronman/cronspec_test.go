@@ -0,0 +1,106 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCronSpecTimer_Next(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+	// Monday
+	now := time.Date(2020, 6, 1, 10, 20, 30, 500, time.UTC)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2020, 6, 1, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2020, 6, 1, 10, 20, 45, 0, time.UTC)},
+		{"35 2 * * *", time.Date(2020, 6, 2, 2, 35, 0, 0, time.UTC)},
+		{"0 0 22 * * mon-fri", time.Date(2020, 6, 1, 22, 0, 0, 0, time.UTC)},
+		{"0 3 * * sat,7", time.Date(2020, 6, 6, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 5", time.Date(2020, 6, 5, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 6, 1, 11, 0, 0, 0, time.UTC)},
+		{"TZ=Asia/Shanghai 0 2 * * *", time.Date(2020, 6, 2, 2, 0, 0, 0, shanghai)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		spec := c.spec
+		if !strings.HasPrefix(spec, "TZ=") {
+			// independent of the local time zone
+			spec = "TZ=UTC " + spec
+		}
+		timer, err := ParseCronSpec(spec)
+		if err != nil {
+			t.Errorf("parse %q: %v", c.spec, err)
+			continue
+		}
+		got := timer.Next(now)
+		if !got.Equal(c.want) {
+			t.Errorf("%q next of %s: want %s, got %s", c.spec, now, c.want, got)
+		}
+	}
+}
+
+func TestParseCronSpec_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every",
+		"TZ=Nowhere/City * * * * *",
+	} {
+		if _, err := ParseCronSpec(spec); err == nil {
+			t.Errorf("expect error of %q", spec)
+		}
+	}
+}
+
+func TestSCronJobManager_SetJobSchedules(t *testing.T) {
+	manager := &SCronJobManager{
+		jobs:      make([]*SCronJob, 0),
+		dataLock:  new(sync.Mutex),
+		schedules: make(map[string]ICronTimer),
+	}
+	if err := manager.SetJobSchedules([]string{"Cleanup=35 2 * * *"}); err != nil {
+		t.Fatalf("SetJobSchedules: %v", err)
+	}
+	if err := manager.SetJobSchedules([]string{"35 2 * * *"}); err == nil {
+		t.Errorf("expect error of schedule without job name")
+	}
+	manager.AddJobEveryFewHour("Cleanup", 1, 35, 0, nil, false)
+	manager.AddJobEveryFewHour("Other", 1, 35, 0, nil, false)
+	for _, status := range manager.GetJobStatuses() {
+		want := "every 1 hours at xx:35:00"
+		if status.Name == "Cleanup" {
+			want = "35 2 * * *"
+		}
+		if status.Schedule != want {
+			t.Errorf("job %s schedule: want %q, got %q", status.Name, want, status.Schedule)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+)
+
+// SCronJobStatus is the schedule and the last run of a cron job
+type SCronJobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Next     time.Time `json:"next"`
+
+	LastStart time.Time `json:"last_start"`
+	// LastDuration is the seconds the last run took
+	LastDuration float64 `json:"last_duration"`
+	// LastError is the panic of the last run, empty if it succeeded
+	LastError string `json:"last_error"`
+	RunCount  int    `json:"run_count"`
+}
+
+var (
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "cronman",
+			Name:      "job_duration_seconds",
+			Help:      "Duration of the cron job runs",
+			Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+		},
+		[]string{"job"},
+	)
+	jobErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "cronman",
+			Name:      "job_errors_total",
+			Help:      "Runs of the cron job ended with panic",
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(jobDuration, jobErrors)
+}
+
+func (job *SCronJob) saveHistory(start time.Time, duration time.Duration, errMsg string) {
+	jobDuration.WithLabelValues(job.Name).Observe(duration.Seconds())
+	if len(errMsg) > 0 {
+		jobErrors.WithLabelValues(job.Name).Inc()
+	}
+
+	job.historyLock.Lock()
+	defer job.historyLock.Unlock()
+
+	job.lastStart = start
+	job.lastDuration = duration
+	job.lastError = errMsg
+	job.runCount += 1
+}
+
+func (job *SCronJob) getStatus() SCronJobStatus {
+	job.historyLock.Lock()
+	defer job.historyLock.Unlock()
+
+	status := SCronJobStatus{
+		Name:         job.Name,
+		Next:         job.Next,
+		LastStart:    job.lastStart,
+		LastDuration: job.lastDuration.Seconds(),
+		LastError:    job.lastError,
+		RunCount:     job.runCount,
+	}
+	if s, ok := job.Timer.(fmt.Stringer); ok {
+		status.Schedule = s.String()
+	}
+	return status
+}
+
+// GetJobStatuses returns the statuses of the jobs sorted by name
+func (self *SCronJobManager) GetJobStatuses() []SCronJobStatus {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	ret := make([]SCronJobStatus, len(self.jobs))
+	for i := range self.jobs {
+		ret[i] = self.jobs[i].getStatus()
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func AddCronJobHandler(prefix string, app *appsrv.Application) {
+	app.AddHandler2("GET", fmt.Sprintf("%s/cronjobs", prefix), auth.Authenticate(cronJobsHandler), nil, "list_cronjobs", nil)
+}
+
+func cronJobsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	userCred := auth.FetchUserCredential(ctx, nil)
+	if userCred == nil || !userCred.HasSystemAdminPrivilege() {
+		httperrors.ForbiddenError(ctx, w, "not enough privilege")
+		return
+	}
+	statuses := make([]SCronJobStatus, 0)
+	if manager != nil {
+		statuses = manager.GetJobStatuses()
+	}
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.Marshal(statuses), "cronjobs")
+	appsrv.SendJSON(w, ret)
+}
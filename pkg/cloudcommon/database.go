@@ -25,6 +25,7 @@ import (
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/appsrv/ratelimit"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
@@ -37,6 +38,8 @@ const (
 )
 
 func InitDB(options *common_options.DBOptions) {
+	consts.SetTaskStageWatchdogIntervalSeconds(options.TaskStageWatchdogIntervalSeconds)
+
 	if options.DebugSqlchemy {
 		log.Warningf("debug Sqlchemy is turned on")
 		sqlchemy.DEBUG_SQLCHEMY = true
@@ -83,10 +86,23 @@ func InitDB(options *common_options.DBOptions) {
 			log.Fatalf("etcd lockman: %v", err)
 		}
 		lockman.Init(lm)
+	case common_options.LockMethodDB:
+		log.Infof("using db lockman")
+		lm, err := lockman.NewDBLockManager(&lockman.SDBLockManagerConfig{
+			DB:      dbConn,
+			LockTTL: options.DBLockTTL,
+		})
+		if err != nil {
+			log.Fatalf("db lockman: %v", err)
+		}
+		lockman.Init(lm)
 	}
 	// lm := lockman.NewNoopLockManager()
 
-	if len(options.EtcdEndpoints) != 0 {
+	if options.InformerBackend == common_options.InformerBackendStream {
+		log.Infof("using stream as resource informer backend")
+		informer.Init(informer.NewStreamBackend())
+	} else if len(options.EtcdEndpoints) != 0 {
 		log.Infof("using etcd as resource informer backend")
 		tlsCfg, err := options.GetEtcdTLSConfig()
 		if err != nil {
@@ -105,6 +121,36 @@ func InitDB(options *common_options.DBOptions) {
 		}
 		informer.Init(informerBackend)
 	}
+
+	if options.RateLimitStore == common_options.RateLimitStoreEtcd {
+		log.Infof("using etcd as rate limit store")
+		tlsCfg, err := options.GetEtcdTLSConfig()
+		if err != nil {
+			log.Fatalf("get etcd rate limit store tls config err: %v", err)
+		}
+		var cli *etcd.SEtcdClient
+		cli, err = etcd.NewEtcdClient(&etcd.SEtcdOptions{
+			EtcdEndpoint:              options.EtcdEndpoints,
+			EtcdUsername:              options.EtcdUsername,
+			EtcdPassword:              options.EtcdPassword,
+			EtcdTimeoutSeconds:        5,
+			EtcdRequestTimeoutSeconds: 2,
+			EtcdLeaseExpireSeconds:    5,
+			EtcdEnabldSsl:             options.EtcdUseTLS,
+			TLSConfig:                 tlsCfg,
+		}, func() {
+			// the store does not rely on the session
+			if err := cli.RestartSession(); err != nil {
+				log.Errorf("restart rate limit store session error: %v", err)
+			}
+		})
+		if err != nil {
+			log.Fatalf("new etcd rate limit store error: %v", err)
+		}
+		// the buckets are not shared with the other services
+		prefix := fmt.Sprintf("%s/%s", options.EtcdRateLimitPrefix, consts.GetServiceType())
+		ratelimit.SetStore(etcd.NewRateLimitStore(cli, prefix))
+	}
 }
 
 func CloseDB() {
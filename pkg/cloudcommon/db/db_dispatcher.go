@@ -254,10 +254,15 @@ func listItemQueryFiltersRaw(manager IModelManager,
 	doCheckRbac bool,
 	useRawQuery bool,
 ) (*sqlchemy.SQuery, error) {
-	ownerId, queryScope, err := FetchCheckQueryOwnerScope(ctx, userCred, query, manager, action, doCheckRbac)
+	// only the standalone models have metadata tags
+	_, withTags := manager.(IStandaloneModelManager)
+	ownerId, queryScope, tagConds, err := fetchCheckQueryOwnerScope(ctx, userCred, query, manager, action, doCheckRbac, withTags)
 	if err != nil {
 		return nil, httperrors.NewGeneralError(err)
 	}
+	if len(tagConds) > 0 {
+		q = filterByPolicyTags(manager, q, tagConds)
+	}
 
 	if !useRawQuery {
 		// Specifically for joint resource, these filters will exclude
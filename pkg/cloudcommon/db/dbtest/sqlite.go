@@ -0,0 +1,88 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// the sqlite driver speaking the functions of mysql used by sqlchemy, it is
+// registered only when built with cgo
+const sqliteDriver = "sqlite3_dbtest"
+
+// ITable is the table spec of a model manager, i.e. db.ITableSpec
+type ITable interface {
+	Name() string
+	Columns() []sqlchemy.IColumnSpec
+}
+
+// OpenSqliteDB opens a sqlite database in a temp dir, the test is skipped
+// if sqlite is not available, e.g. built without cgo
+func OpenSqliteDB(t *testing.T) (*sql.DB, func()) {
+	dir, err := ioutil.TempDir("", "dbtest")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	conn, err := sql.Open(sqliteDriver, filepath.Join(dir, "test.db")+"?_busy_timeout=5000")
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("open sqlite: %v", err)
+	}
+	// the connections share the database file
+	conn.SetMaxOpenConns(1)
+	return conn, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// CreateTables creates the tables of the model managers
+func CreateTables(t *testing.T, conn *sql.DB, tables ...ITable) {
+	for _, table := range tables {
+		cols := make([]string, 0)
+		for _, col := range table.Columns() {
+			// sqlite takes the type without the charset of mysql
+			colType := strings.Fields(col.ColType())[0]
+			cols = append(cols, fmt.Sprintf("`%s` %s", col.Name(), colType))
+		}
+		_, err := conn.Exec(fmt.Sprintf("CREATE TABLE `%s` (%s)", table.Name(), strings.Join(cols, ", ")))
+		if err != nil {
+			t.Fatalf("create table %s: %v", table.Name(), err)
+		}
+	}
+}
+
+// SetupSqliteDB serves the model managers by sqlchemy from a sqlite database,
+// the returned function closes and removes the database
+func SetupSqliteDB(t *testing.T, tables ...ITable) func() {
+	conn, cleanup := OpenSqliteDB(t)
+	CreateTables(t, conn, tables...)
+	sqlchemy.SetDB(conn)
+	return func() {
+		sqlchemy.CloseDB()
+		cleanup()
+	}
+}
@@ -310,6 +310,13 @@ type IScopedResourceManager interface {
 }
 
 func FetchCheckQueryOwnerScope(ctx context.Context, userCred mcclient.TokenCredential, data jsonutils.JSONObject, manager IScopedResourceManager, action string, doCheckRbac bool) (mcclient.IIdentityProvider, rbacutils.TRbacScope, error) {
+	ownerId, queryScope, _, err := fetchCheckQueryOwnerScope(ctx, userCred, data, manager, action, doCheckRbac, false)
+	return ownerId, queryScope, err
+}
+
+// with withTags, the tag conditions of policy rules are evaluated as well, a query denied in the required scope
+// is still allowed if the tag conditions allow it. The query should be then restricted to the objects meeting any of the returned conditions
+func fetchCheckQueryOwnerScope(ctx context.Context, userCred mcclient.TokenCredential, data jsonutils.JSONObject, manager IScopedResourceManager, action string, doCheckRbac bool, withTags bool) (mcclient.IIdentityProvider, rbacutils.TRbacScope, []rbacutils.STagCondition, error) {
 	var scope rbacutils.TRbacScope
 
 	var allowScope rbacutils.TRbacScope
@@ -336,7 +343,7 @@ func FetchCheckQueryOwnerScope(ctx context.Context, userCred mcclient.TokenCrede
 
 	ownerId, err := manager.FetchOwnerId(ctx, data)
 	if err != nil {
-		return nil, queryScope, err
+		return nil, queryScope, nil, err
 	}
 	if ownerId != nil {
 		switch resScope {
@@ -386,11 +393,22 @@ func FetchCheckQueryOwnerScope(ctx context.Context, userCred mcclient.TokenCrede
 		// }
 		requireScope = queryScope
 	}
+	if doCheckRbac && withTags && consts.IsRbacEnabled() && (requireScope.HigherThan(allowScope) || policy.PolicyManager.HasTagConditions(userCred)) {
+		tagConds := policy.PolicyManager.ListTagConditions(requireScope, userCred, consts.GetServiceType(), manager.KeywordPlural(), action)
+		if len(tagConds) > 0 {
+			return ownerId, queryScope, tagConds, nil
+		}
+		if tagConds == nil && !requireScope.HigherThan(allowScope) {
+			return ownerId, queryScope, nil, nil
+		}
+		return nil, scope, nil, httperrors.NewForbiddenError("not enough privilege (require:%s,allow:%s,query:%s)",
+			requireScope, allowScope, queryScope)
+	}
 	if doCheckRbac && requireScope.HigherThan(allowScope) {
-		return nil, scope, httperrors.NewForbiddenError("not enough privilege (require:%s,allow:%s,query:%s)",
+		return nil, scope, nil, httperrors.NewForbiddenError("not enough privilege (require:%s,allow:%s,query:%s)",
 			requireScope, allowScope, queryScope)
 	}
-	return ownerId, queryScope, nil
+	return ownerId, queryScope, nil, nil
 }
 
 func mapKeys(idMap map[string]string) []string {
@@ -24,42 +24,67 @@ func NewBaseLockManger(m ILockManager) *SBaseLockManager {
 	return &SBaseLockManager{manager: m}
 }
 
+// lockKey records the waiting and the holding of the lock for the
+// diagnostics of the contentions
+func (m *SBaseLockManager) lockKey(ctx context.Context, key string) {
+	idx := sLockDiagIndex{manager: m, key: key, holder: ctx}
+	if !diag.wait(idx) {
+		m.manager.LockKey(ctx, key)
+		return
+	}
+	acquired := false
+	defer func() {
+		// LockKey panics if ctx is done before the lock is acquired
+		if !acquired {
+			diag.cancel(idx)
+		}
+	}()
+	m.manager.LockKey(ctx, key)
+	acquired = true
+	diag.acquired(idx)
+}
+
+func (m *SBaseLockManager) unlockKey(ctx context.Context, key string) {
+	m.manager.UnlockKey(ctx, key)
+	diag.release(sLockDiagIndex{manager: m, key: key, holder: ctx})
+}
+
 func (m *SBaseLockManager) LockClass(ctx context.Context, manager ILockedClass, projectId string) {
 	key := getClassKey(manager, projectId)
-	m.manager.LockKey(ctx, key)
+	m.lockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) ReleaseClass(ctx context.Context, manager ILockedClass, projectId string) {
 	key := getClassKey(manager, projectId)
-	m.manager.UnlockKey(ctx, key)
+	m.unlockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) LockObject(ctx context.Context, model ILockedObject) {
 	key := getObjectKey(model)
-	m.manager.LockKey(ctx, key)
+	m.lockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) ReleaseObject(ctx context.Context, model ILockedObject) {
 	key := getObjectKey(model)
-	m.manager.UnlockKey(ctx, key)
+	m.unlockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) LockRawObject(ctx context.Context, resName string, resId string) {
 	key := getRawObjectKey(resName, resId)
-	m.manager.LockKey(ctx, key)
+	m.lockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) ReleaseRawObject(ctx context.Context, resName string, resId string) {
 	key := getRawObjectKey(resName, resId)
-	m.manager.UnlockKey(ctx, key)
+	m.unlockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) LockJointObject(ctx context.Context, model ILockedObject, model2 ILockedObject) {
 	key := getJointObjectKey(model, model2)
-	m.manager.LockKey(ctx, key)
+	m.lockKey(ctx, key)
 }
 
 func (m *SBaseLockManager) ReleaseJointObject(ctx context.Context, model ILockedObject, model2 ILockedObject) {
 	key := getJointObjectKey(model, model2)
-	m.manager.UnlockKey(ctx, key)
+	m.unlockKey(ctx, key)
 }
@@ -0,0 +1,386 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	crand "crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/util/atexit"
+)
+
+const (
+	dbLockDefaultTable = "lockman_leases_tbl"
+	dbLockMaxKeyLength = 128
+
+	dbLockMinRetryInterval = 10 * time.Millisecond
+	dbLockMaxRetryInterval = 500 * time.Millisecond
+	dbLockWarnInterval     = 30 * time.Second
+)
+
+// SDBLockManagerConfig configures the lock manager keeping the locks as
+// leases in a table of the database shared by the replicas. The expiry of
+// the leases is computed with the clocks of the replicas, which shall be
+// synchronized well within LockTTL
+type SDBLockManagerConfig struct {
+	DB        *sql.DB
+	TableName string
+	// LockTTL is the seconds a lease lasts without renewal, the leases of
+	// a crashed replica are taken over after it
+	LockTTL int
+}
+
+func (config *SDBLockManagerConfig) validate() error {
+	if config.DB == nil {
+		return fmt.Errorf("no database configured")
+	}
+	if config.TableName == "" {
+		config.TableName = dbLockDefaultTable
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = 10
+	}
+	return nil
+}
+
+type SDBLockRecord struct {
+	m     *sync.Mutex
+	depth int
+
+	key    string
+	holder string
+}
+
+func (rec *SDBLockRecord) lockContext(ctx context.Context, lockman *SDBLockManager) {
+	rec.m.Lock()
+	defer rec.m.Unlock()
+
+	rec.depth += 1
+	if rec.depth > 32 {
+		// NOTE callers are responsible for ensuring unlock got called
+		bug("%s: depth > 32", rec.key)
+		panic(debug.Stack())
+	}
+
+	if rec.depth == 1 {
+		lockman.acquire(ctx, rec.key, rec.holder)
+	}
+
+	if debug_log {
+		log.Infof("%s: lock depth %d\n%s", rec.key, rec.depth, debug.Stack())
+	}
+}
+
+// unlockContext returns true if the lease shall be released, which is left
+// to the caller to do out of the table lock
+func (rec *SDBLockRecord) unlockContext(ctx context.Context) (needRelease bool) {
+	rec.m.Lock()
+	defer rec.m.Unlock()
+
+	if debug_log {
+		log.Infof("%s: unlock depth %d\n%s", rec.key, rec.depth, debug.Stack())
+	}
+
+	rec.depth -= 1
+	if rec.depth <= 0 {
+		if rec.depth < 0 {
+			bug("%s: overly unlocked", rec.key)
+		}
+		return true
+	}
+	return false
+}
+
+type SDBLockManager struct {
+	*SBaseLockManager
+	tableLock *sync.Mutex
+	lockTable map[SLockTableIndex]*SDBLockRecord
+
+	config *SDBLockManagerConfig
+	// id identifies the lock manager in the holders of the leases
+	id   string
+	seq  int64
+	held int64
+	stop chan struct{}
+}
+
+func NewDBLockManager(config *SDBLockManagerConfig) (ILockManager, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (lock_key VARCHAR(%d) NOT NULL, holder VARCHAR(128) NOT NULL, expire_at BIGINT NOT NULL, PRIMARY KEY (lock_key))", config.TableName, dbLockMaxKeyLength)
+	if _, err := config.DB.Exec(q); err != nil {
+		return nil, errors.Wrapf(err, "create table %s", config.TableName)
+	}
+	lockman := SDBLockManager{
+		tableLock: &sync.Mutex{},
+		lockTable: map[SLockTableIndex]*SDBLockRecord{},
+
+		config: config,
+		id:     randomHolderId(),
+		stop:   make(chan struct{}),
+	}
+	atexit.Register(atexit.ExitHandler{
+		Prio:   atexit.PRIO_LOG_OTHER,
+		Reason: "db-lockman",
+		Value:  lockman,
+		Func:   atexit.ExitHandlerFunc(lockman.destroyAtExit),
+	})
+	lockman.SBaseLockManager = NewBaseLockManger(&lockman)
+	go lockman.renewLoop()
+	return &lockman, nil
+}
+
+func randomHolderId() string {
+	buf := make([]byte, 8)
+	if _, err := crand.Read(buf); err != nil {
+		panic(fmt.Sprintf("read random: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (lockman *SDBLockManager) destroyAtExit(eh atexit.ExitHandler) {
+	log.Infof("releasing db lockman leases")
+	close(lockman.stop)
+	q := fmt.Sprintf("DELETE FROM %s WHERE holder LIKE ?", lockman.config.TableName)
+	if _, err := lockman.config.DB.Exec(q, lockman.id+"/%"); err != nil {
+		log.Errorf("db lockman release leases: %v", err)
+	}
+}
+
+func (lockman *SDBLockManager) ttl() time.Duration {
+	return time.Duration(lockman.config.LockTTL) * time.Second
+}
+
+func nowMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// dbLockKey fits the key into the lock_key column
+func dbLockKey(key string) string {
+	if len(key) <= dbLockMaxKeyLength {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return fmt.Sprintf("sha1-%s", hex.EncodeToString(sum[:]))
+}
+
+// tryAcquire takes the lease of the key if it is free or expired
+func (lockman *SDBLockManager) tryAcquire(key, holder string) (bool, error) {
+	db := lockman.config.DB
+	table := lockman.config.TableName
+	now := time.Now()
+	expireAt := nowMillis(now.Add(lockman.ttl()))
+
+	q := fmt.Sprintf("UPDATE %s SET holder = ?, expire_at = ? WHERE lock_key = ? AND expire_at < ?", table)
+	result, err := db.Exec(q, holder, expireAt, key, nowMillis(now))
+	if err != nil {
+		return false, errors.Wrap(err, "take over expired lease")
+	}
+	if cnt, err := result.RowsAffected(); err == nil && cnt == 1 {
+		return true, nil
+	}
+
+	q = fmt.Sprintf("INSERT INTO %s (lock_key, holder, expire_at) VALUES (?, ?, ?)", table)
+	if _, err := db.Exec(q, key, holder, expireAt); err != nil {
+		// the error of duplicate primary key differs among the drivers,
+		// tell it by looking up the lease
+		var cur string
+		q = fmt.Sprintf("SELECT holder FROM %s WHERE lock_key = ?", table)
+		if qerr := db.QueryRow(q, key).Scan(&cur); qerr == nil {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "insert lease")
+	}
+	return true, nil
+}
+
+func (lockman *SDBLockManager) acquire(ctx context.Context, key, holder string) {
+	start := time.Now()
+	warnAt := start.Add(dbLockWarnInterval)
+	interval := dbLockMinRetryInterval
+	for {
+		acquired, err := lockman.tryAcquire(key, holder)
+		if err != nil {
+			log.Errorf("%s: db lock: %v", key, err)
+		} else if acquired {
+			atomic.AddInt64(&lockman.held, 1)
+			return
+		}
+		if now := time.Now(); now.After(warnAt) {
+			log.Warningf("%s: waiting for db lock for %s", key, now.Sub(start))
+			warnAt = now.Add(dbLockWarnInterval)
+		}
+		// jitter the retries of the contenders
+		wait := interval/2 + time.Duration(rand.Int63n(int64(interval)))
+		select {
+		case <-ctx.Done():
+			panic(fmt.Sprintf("%s: db lock: %v", key, ctx.Err()))
+		case <-time.After(wait):
+		}
+		interval *= 2
+		if interval > dbLockMaxRetryInterval {
+			interval = dbLockMaxRetryInterval
+		}
+	}
+}
+
+func (lockman *SDBLockManager) release(key, holder string) {
+	atomic.AddInt64(&lockman.held, -1)
+	q := fmt.Sprintf("DELETE FROM %s WHERE lock_key = ? AND holder = ?", lockman.config.TableName)
+	for i := 0; i < 3; i++ {
+		result, err := lockman.config.DB.Exec(q, key, holder)
+		if err != nil {
+			log.Errorf("%s: release db lock: %v", key, err)
+			if i < 2 {
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if cnt, err := result.RowsAffected(); err == nil && cnt == 0 {
+			log.Errorf("%s: db lock lease lost before release", key)
+		}
+		return
+	}
+	log.Errorf("%s: release db lock failure", key)
+}
+
+// renewLoop extends the leases of the lock manager every third of LockTTL
+func (lockman *SDBLockManager) renewLoop() {
+	ticker := time.NewTicker(lockman.ttl() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lockman.stop:
+			return
+		case <-ticker.C:
+			lockman.renew()
+		}
+	}
+}
+
+func (lockman *SDBLockManager) renew() {
+	held := atomic.LoadInt64(&lockman.held)
+	if held <= 0 {
+		return
+	}
+	now := time.Now()
+	q := fmt.Sprintf("UPDATE %s SET expire_at = ? WHERE holder LIKE ? AND expire_at >= ?", lockman.config.TableName)
+	result, err := lockman.config.DB.Exec(q, nowMillis(now.Add(lockman.ttl())), lockman.id+"/%", nowMillis(now))
+	if err != nil {
+		log.Errorf("renew db lock leases: %v", err)
+		return
+	}
+	if cnt, err := result.RowsAffected(); err == nil && cnt < held {
+		// the rows of the leases acquired or released during the update
+		// make the count inaccurate, only log it
+		log.Warningf("renew db lock leases: %d renewed, %d held", cnt, held)
+	}
+}
+
+func (lockman *SDBLockManager) getRecordWithLock(ctx context.Context, key string) *SDBLockRecord {
+	lockman.tableLock.Lock()
+	defer lockman.tableLock.Unlock()
+
+	return lockman.getRecord(ctx, key, true)
+}
+
+func (lockman *SDBLockManager) getRecord(ctx context.Context, key string, alloc bool) *SDBLockRecord {
+	idx := SLockTableIndex{
+		key:    key,
+		holder: ctx,
+	}
+	_, ok := lockman.lockTable[idx]
+	if !ok {
+		if !alloc {
+			return nil
+		}
+		lockman.seq += 1
+		lockman.lockTable[idx] = &SDBLockRecord{
+			m:      &sync.Mutex{},
+			key:    dbLockKey(key),
+			holder: fmt.Sprintf("%s/%d", lockman.id, lockman.seq),
+		}
+	}
+	return lockman.lockTable[idx]
+}
+
+func (lockman *SDBLockManager) LockKey(ctx context.Context, key string) {
+	record := lockman.getRecordWithLock(ctx, key)
+
+	record.lockContext(ctx, lockman)
+}
+
+func (lockman *SDBLockManager) UnlockKey(ctx context.Context, key string) {
+	record := func() *SDBLockRecord {
+		lockman.tableLock.Lock()
+		defer lockman.tableLock.Unlock()
+
+		record := lockman.getRecord(ctx, key, false)
+		if record == nil {
+			bug("%s: unlock a non-existent lock\n%s", key, debug.Stack())
+			return nil
+		}
+
+		if !record.unlockContext(ctx) {
+			return nil
+		}
+		idx := SLockTableIndex{
+			key:    key,
+			holder: ctx,
+		}
+		delete(lockman.lockTable, idx)
+		return record
+	}()
+	if record != nil {
+		// the release retries on errors of the database, which shall not
+		// block the locking of the other keys. Should the release fail, the
+		// lease expires in LockTTL as the renewal of it stops
+		lockman.release(record.key, record.holder)
+	}
+}
+
+// getSortedJointObjectKey orders the pair of objects, so that the joint
+// locks of (a, b) and (b, a) are the same lease. The pair is locked as a
+// single lease, a holder never waits for one of the objects with the other
+// in hand
+func getSortedJointObjectKey(model ILockedObject, model2 ILockedObject) string {
+	key, key2 := getObjectKey(model), getObjectKey(model2)
+	if key2 < key {
+		key, key2 = key2, key
+	}
+	return fmt.Sprintf("%s-%s", key, key2)
+}
+
+func (lockman *SDBLockManager) LockJointObject(ctx context.Context, model ILockedObject, model2 ILockedObject) {
+	lockman.lockKey(ctx, getSortedJointObjectKey(model, model2))
+}
+
+func (lockman *SDBLockManager) ReleaseJointObject(ctx context.Context, model ILockedObject, model2 ILockedObject) {
+	lockman.unlockKey(ctx, getSortedJointObjectKey(model, model2))
+}
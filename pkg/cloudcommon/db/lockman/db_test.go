@@ -0,0 +1,117 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/dbtest"
+)
+
+func TestDBLockManager(t *testing.T) {
+	db, cleanup := dbtest.OpenSqliteDB(t)
+	defer cleanup()
+
+	cfgs := []*testLockManagerConfig{}
+	shared := newSharedObject()
+	for i := 0; i < 2; i++ {
+		lockman, err := NewDBLockManager(&SDBLockManagerConfig{
+			DB:      db,
+			LockTTL: 3,
+		})
+		if err != nil {
+			t.Fatalf("new db lockman: %v", err)
+		}
+		cfgs = append(cfgs, &testLockManagerConfig{
+			players: 3,
+			cycles:  2,
+			lockman: lockman,
+			shared:  shared,
+		})
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(len(cfgs))
+	for _, cfg := range cfgs {
+		go func(cfg *testLockManagerConfig) {
+			testLockManager(t, cfg)
+			wg.Done()
+		}(cfg)
+	}
+	wg.Wait()
+}
+
+func TestDBLockManager_Expire(t *testing.T) {
+	db, cleanup := dbtest.OpenSqliteDB(t)
+	defer cleanup()
+
+	crashed, err := NewDBLockManager(&SDBLockManagerConfig{DB: db, LockTTL: 1})
+	if err != nil {
+		t.Fatalf("new db lockman: %v", err)
+	}
+	// a crashed replica renews no more
+	close(crashed.(*SDBLockManager).stop)
+	crashed.LockKey(context.Background(), "expire")
+
+	lockman, err := NewDBLockManager(&SDBLockManagerConfig{DB: db, LockTTL: 1})
+	if err != nil {
+		t.Fatalf("new db lockman: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	lockman.LockKey(ctx, "expire")
+	if waited := time.Since(start); waited < 500*time.Millisecond {
+		t.Errorf("lease taken over in %s before expiry", waited)
+	}
+	lockman.UnlockKey(ctx, "expire")
+}
+
+func TestDBLockManager_Renew(t *testing.T) {
+	db, cleanup := dbtest.OpenSqliteDB(t)
+	defer cleanup()
+
+	holder, err := NewDBLockManager(&SDBLockManagerConfig{DB: db, LockTTL: 1})
+	if err != nil {
+		t.Fatalf("new db lockman: %v", err)
+	}
+	other, err := NewDBLockManager(&SDBLockManagerConfig{DB: db, LockTTL: 1})
+	if err != nil {
+		t.Fatalf("new db lockman: %v", err)
+	}
+	holderCtx := context.Background()
+	holder.LockKey(holderCtx, "renew")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("lease of a living holder taken over")
+			}
+		}()
+		other.LockKey(ctx, "renew")
+	}()
+	holder.UnlockKey(holderCtx, "renew")
+}
+
+func TestGetSortedJointObjectKey(t *testing.T) {
+	a, b := newSharedObject(), newSharedObject()
+	if getSortedJointObjectKey(a, b) != getSortedJointObjectKey(b, a) {
+		t.Errorf("joint keys of (a, b) and (b, a) differ")
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/appsrv"
+)
+
+const (
+	LOCK_STATE_WAITING = "waiting"
+	LOCK_STATE_HELD    = "held"
+
+	lockDiagCheckInterval = 10 * time.Second
+)
+
+var (
+	// locks waited or held longer than the thresholds are logged
+	slowLockWaitThreshold = 10 * time.Second
+	slowLockHoldThreshold = 30 * time.Second
+
+	lockWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "lockman",
+			Name:      "wait_duration_seconds",
+			Help:      "Time waited to acquire the locks by the resource of the key",
+			Buckets:   []float64{.001, .01, .1, .5, 1, 5, 10, 30, 60, 300},
+		},
+		[]string{"resource"},
+	)
+	lockHoldDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "lockman",
+			Name:      "hold_duration_seconds",
+			Help:      "Time the locks were held by the resource of the key",
+			Buckets:   []float64{.001, .01, .1, .5, 1, 5, 10, 30, 60, 300},
+		},
+		[]string{"resource"},
+	)
+	slowLockCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "lockman",
+			Name:      "slow_total",
+			Help:      "Locks waited or held longer than the thresholds",
+		},
+		[]string{"resource", "state"},
+	)
+	locksDesc = prometheus.NewDesc(
+		"lockman_locks", "Locks currently held or waited for", []string{"state"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lockWaitDuration, lockHoldDuration, slowLockCounter, lockDiagCollector{})
+}
+
+// SetSlowLockThresholds sets the durations after which the waiting and the
+// holding of a lock are logged as warnings
+func SetSlowLockThresholds(wait, hold time.Duration) {
+	diag.lock.Lock()
+	defer diag.lock.Unlock()
+
+	slowLockWaitThreshold = wait
+	slowLockHoldThreshold = hold
+}
+
+// SLockHolder is a context holding or waiting for a lock
+type SLockHolder struct {
+	Key   string `json:"key"`
+	State string `json:"state"`
+	Depth int    `json:"depth"`
+
+	RequestId string `json:"request_id"`
+	Handler   string `json:"handler"`
+	TaskId    string `json:"task_id"`
+	TaskName  string `json:"task_name"`
+
+	Since time.Time `json:"since"`
+	// Duration is the seconds since the context started to hold or to
+	// wait for the lock
+	Duration float64 `json:"duration"`
+}
+
+type sLockDiagIndex struct {
+	manager *SBaseLockManager
+	key     string
+	holder  context.Context
+}
+
+type sLockDiagEntry struct {
+	state  string
+	depth  int
+	since  time.Time
+	warned bool
+}
+
+type sLockDiag struct {
+	lock    sync.Mutex
+	entries map[sLockDiagIndex]*sLockDiagEntry
+	once    sync.Once
+}
+
+var diag = &sLockDiag{
+	entries: make(map[sLockDiagIndex]*sLockDiagEntry),
+}
+
+// lockResource is the keyword of the resource of a lock key, keeping the
+// labels of the metrics enumerable
+func lockResource(key string) string {
+	if pos := strings.IndexByte(key, '-'); pos > 0 {
+		return key[:pos]
+	}
+	return key
+}
+
+// wait records ctx waiting for the lock, returns false if ctx holds it already
+func (d *sLockDiag) wait(idx sLockDiagIndex) bool {
+	d.once.Do(func() {
+		go d.checkLoop()
+	})
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if entry, ok := d.entries[idx]; ok && entry.state == LOCK_STATE_HELD {
+		entry.depth += 1
+		return false
+	}
+	d.entries[idx] = &sLockDiagEntry{
+		state: LOCK_STATE_WAITING,
+		since: time.Now(),
+	}
+	return true
+}
+
+func (d *sLockDiag) acquired(idx sLockDiagIndex) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	entry, ok := d.entries[idx]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	waited := now.Sub(entry.since)
+	resource := lockResource(idx.key)
+	lockWaitDuration.WithLabelValues(resource).Observe(waited.Seconds())
+	if waited > slowLockWaitThreshold && !entry.warned {
+		slowLockCounter.WithLabelValues(resource, LOCK_STATE_WAITING).Inc()
+		log.Warningf("lock %s acquired after waiting for %s by %s", idx.key, waited, describeHolder(idx.holder))
+	}
+	entry.state = LOCK_STATE_HELD
+	entry.depth = 1
+	entry.since = now
+	entry.warned = false
+}
+
+func (d *sLockDiag) cancel(idx sLockDiagIndex) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if entry, ok := d.entries[idx]; ok && entry.state == LOCK_STATE_WAITING {
+		delete(d.entries, idx)
+	}
+}
+
+func (d *sLockDiag) release(idx sLockDiagIndex) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	entry, ok := d.entries[idx]
+	if !ok {
+		return
+	}
+	entry.depth -= 1
+	if entry.depth > 0 {
+		return
+	}
+	held := time.Since(entry.since)
+	resource := lockResource(idx.key)
+	lockHoldDuration.WithLabelValues(resource).Observe(held.Seconds())
+	if held > slowLockHoldThreshold && !entry.warned {
+		slowLockCounter.WithLabelValues(resource, LOCK_STATE_HELD).Inc()
+		log.Warningf("lock %s released after held for %s by %s", idx.key, held, describeHolder(idx.holder))
+	}
+	delete(d.entries, idx)
+}
+
+// checkLoop warns the locks still waited for or held over the thresholds,
+// once for each of them, so that a hang is visible before it ends
+func (d *sLockDiag) checkLoop() {
+	ticker := time.NewTicker(lockDiagCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.check()
+	}
+}
+
+func (d *sLockDiag) check() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	for idx, entry := range d.entries {
+		if entry.warned {
+			continue
+		}
+		threshold := slowLockHoldThreshold
+		if entry.state == LOCK_STATE_WAITING {
+			threshold = slowLockWaitThreshold
+		}
+		if dur := now.Sub(entry.since); dur > threshold {
+			entry.warned = true
+			slowLockCounter.WithLabelValues(lockResource(idx.key), entry.state).Inc()
+			log.Warningf("lock %s %s for %s by %s", idx.key, entry.state, dur, describeHolder(idx.holder))
+		}
+	}
+}
+
+func newLockHolder(idx sLockDiagIndex, entry *sLockDiagEntry, now time.Time) SLockHolder {
+	holder := SLockHolder{
+		Key:       idx.key,
+		State:     entry.state,
+		Depth:     entry.depth,
+		RequestId: appctx.AppContextRequestId(idx.holder),
+		TaskId:    appctx.AppContextTaskId(idx.holder),
+		TaskName:  appctx.AppContextTaskName(idx.holder),
+		Since:     entry.since,
+		Duration:  now.Sub(entry.since).Seconds(),
+	}
+	if params := appsrv.AppContextGetParams(idx.holder); params != nil {
+		if params.Request != nil {
+			holder.Handler = fmt.Sprintf("%s %s", params.Request.Method, params.Request.URL.Path)
+		} else {
+			holder.Handler = params.Name
+		}
+	}
+	return holder
+}
+
+func describeHolder(ctx context.Context) string {
+	holder := newLockHolder(sLockDiagIndex{holder: ctx}, &sLockDiagEntry{}, time.Now())
+	parts := make([]string, 0, 4)
+	for _, kv := range [][2]string{
+		{"request", holder.RequestId},
+		{"handler", holder.Handler},
+		{"task_id", holder.TaskId},
+		{"task", holder.TaskName},
+	} {
+		if len(kv[1]) > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%s", kv[0], kv[1]))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("context %p", ctx)
+	}
+	return strings.Join(parts, " ")
+}
+
+// GetLockHolders returns the contexts holding or waiting for the locks of all
+// the lock managers of the process, the longest first
+func GetLockHolders() []SLockHolder {
+	diag.lock.Lock()
+	defer diag.lock.Unlock()
+
+	now := time.Now()
+	ret := make([]SLockHolder, 0, len(diag.entries))
+	for idx, entry := range diag.entries {
+		ret = append(ret, newLockHolder(idx, entry, now))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Since.Before(ret[j].Since)
+	})
+	return ret
+}
+
+type lockDiagCollector struct{}
+
+func (c lockDiagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- locksDesc
+}
+
+func (c lockDiagCollector) Collect(ch chan<- prometheus.Metric) {
+	diag.lock.Lock()
+	counts := map[string]int{
+		LOCK_STATE_HELD:    0,
+		LOCK_STATE_WAITING: 0,
+	}
+	for _, entry := range diag.entries {
+		counts[entry.state] += 1
+	}
+	diag.lock.Unlock()
+
+	for state, cnt := range counts {
+		ch <- prometheus.MustNewConstMetric(locksDesc, prometheus.GaugeValue, float64(cnt), state)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lockman
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/appctx"
+)
+
+func findLockHolder(key string, state string) *SLockHolder {
+	for _, holder := range GetLockHolders() {
+		if holder.Key == key && holder.State == state {
+			return &holder
+		}
+	}
+	return nil
+}
+
+func TestLockDiag(t *testing.T) {
+	lockman := NewInMemoryLockManager()
+	obj := newSharedObject()
+	key := getObjectKey(obj)
+
+	holderCtx := context.WithValue(context.Background(), appctx.APP_CONTEXT_KEY_REQUEST_ID, "req-holder")
+	holderCtx = context.WithValue(holderCtx, appctx.APP_CONTEXT_KEY_TASKNAME, "GuestStartTask-1")
+	lockman.LockObject(holderCtx, obj)
+	lockman.LockObject(holderCtx, obj)
+
+	waiterCtx := context.WithValue(context.Background(), appctx.APP_CONTEXT_KEY_REQUEST_ID, "req-waiter")
+	acquired := make(chan struct{})
+	go func() {
+		lockman.LockObject(waiterCtx, obj)
+		close(acquired)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for findLockHolder(key, LOCK_STATE_WAITING) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	held := findLockHolder(key, LOCK_STATE_HELD)
+	if held == nil || held.RequestId != "req-holder" || held.TaskName != "GuestStartTask-1" || held.Depth != 2 {
+		t.Fatalf("held lock %#v", held)
+	}
+	waiting := findLockHolder(key, LOCK_STATE_WAITING)
+	if waiting == nil || waiting.RequestId != "req-waiter" {
+		t.Fatalf("waiting lock %#v", waiting)
+	}
+
+	lockman.ReleaseObject(holderCtx, obj)
+	if findLockHolder(key, LOCK_STATE_HELD) == nil {
+		t.Errorf("reentrant lock released too early")
+	}
+	lockman.ReleaseObject(holderCtx, obj)
+	<-acquired
+
+	held = findLockHolder(key, LOCK_STATE_HELD)
+	if held == nil || held.RequestId != "req-waiter" || findLockHolder(key, LOCK_STATE_WAITING) != nil {
+		t.Errorf("lock not handed over to the waiter: %#v", GetLockHolders())
+	}
+	lockman.ReleaseObject(waiterCtx, obj)
+	if holders := GetLockHolders(); len(holders) != 0 {
+		t.Errorf("locks left %#v", holders)
+	}
+}
+
+func TestLockResource(t *testing.T) {
+	for key, want := range map[string]string{
+		"guest-1234":    "guest",
+		"guest":         "guest",
+		"-cloudregions": "-cloudregions",
+	} {
+		if got := lockResource(key); got != want {
+			t.Errorf("%s: want %s, got %s", key, want, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/sqlchemy"
+)
+
+var (
+	dbOpenConnsDesc = prometheus.NewDesc(
+		"db_open_connections", "Established connections of the database pool, both in use and idle", nil, nil,
+	)
+	dbInUseConnsDesc = prometheus.NewDesc(
+		"db_in_use_connections", "Connections of the database pool currently in use", nil, nil,
+	)
+	dbIdleConnsDesc = prometheus.NewDesc(
+		"db_idle_connections", "Idle connections of the database pool", nil, nil,
+	)
+	dbMaxOpenConnsDesc = prometheus.NewDesc(
+		"db_max_open_connections", "Max open connections of the database pool", nil, nil,
+	)
+	dbWaitCountDesc = prometheus.NewDesc(
+		"db_wait_count_total", "Total number of connections waited for", nil, nil,
+	)
+	dbWaitDurationDesc = prometheus.NewDesc(
+		"db_wait_duration_seconds_total", "Total time blocked waiting for a new connection", nil, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(dbStatsCollector{})
+}
+
+// dbStatsCollector collects the pool stats of the database of sqlchemy,
+// nothing is collected by the services without database
+type dbStatsCollector struct{}
+
+func (c dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenConnsDesc
+	ch <- dbInUseConnsDesc
+	ch <- dbIdleConnsDesc
+	ch <- dbMaxOpenConnsDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	db := sqlchemy.GetDB()
+	if db == nil {
+		return
+	}
+	stats := db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenConnsDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseConnsDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleConnsDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenConnsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
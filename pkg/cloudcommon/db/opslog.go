@@ -78,6 +78,18 @@ var _ IModel = (*SOpsLog)(nil)
 
 var opslogQueryWorkerMan *appsrv.SWorkerManager
 
+// OpsLogListener is called with the opslogs inserted, it shall return at once
+// and leave the slow work, e.g. sending the event to outside, to the workers
+type OpsLogListener func(opslog *SOpsLog)
+
+var opsLogListeners []OpsLogListener
+
+// RegisterOpsLogListener adds the listener of the opslogs, it is called
+// before the service starts serving
+func RegisterOpsLogListener(listener OpsLogListener) {
+	opsLogListeners = append(opsLogListeners, listener)
+}
+
 func init() {
 	OpsLog = &SOpsLogManager{NewModelBaseManagerWithSplitable(
 		SOpsLog{},
@@ -191,6 +203,10 @@ func (manager *SOpsLogManager) LogEvent(model IModel, action string, notes inter
 	err := manager.TableSpec().Insert(context.Background(), opslog)
 	if err != nil {
 		log.Errorf("fail to insert opslog: %s", err)
+		return
+	}
+	for _, listener := range opsLogListeners {
+		listener(opslog)
 	}
 }
 
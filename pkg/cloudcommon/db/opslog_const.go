@@ -256,6 +256,14 @@ const (
 	ACT_SET_POLICY     = "set_policy"
 	ACT_DELETE_POLICY  = "delete_policy"
 
+	ACT_SET_LIFECYCLE    = "set_lifecycle"
+	ACT_DELETE_LIFECYCLE = "delete_lifecycle"
+	ACT_EXPIRE_OBJECTS   = "expire_objects"
+
+	ACT_SET_VERSIONING         = "set_versioning"
+	ACT_DELETE_OBJECT_VERSION  = "delete_object_version"
+	ACT_RESTORE_OBJECT_VERSION = "restore_object_version"
+
 	ACT_GRANT_PRIVILEGE  = "grant_privilege"
 	ACT_REVOKE_PRIVILEGE = "revoke_privilege"
 	ACT_SET_PRIVILEGES   = "set_privileges"
@@ -15,6 +15,9 @@
 package db
 
 import (
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
 	"yunion.io/x/onecloud/pkg/httperrors"
@@ -67,7 +70,7 @@ func isObjectRbacAllowed(model IModel, userCred mcclient.TokenCredential, action
 		}
 	}
 
-	scope := policy.PolicyManager.AllowScope(userCred, consts.GetServiceType(), manager.KeywordPlural(), action, extra...)
+	scope := objectAllowScope(model, userCred, action, extra...)
 
 	if !requireScope.HigherThan(scope) {
 		return nil
@@ -75,6 +78,49 @@ func isObjectRbacAllowed(model IModel, userCred mcclient.TokenCredential, action
 	return httperrors.NewForbiddenError("not enough privilege (require:%s,allow:%s:resource:%s)", requireScope, scope, resScope)
 }
 
+// objectAllowScope evaluates the policy rules against the metadata tags of the object if any rule is conditioned on tags
+func objectAllowScope(model IModel, userCred mcclient.TokenCredential, action string, extra ...string) rbacutils.TRbacScope {
+	resource := model.GetModelManager().KeywordPlural()
+	metaModel, ok := model.(IStandaloneModel)
+	if !ok || !consts.IsRbacEnabled() || !policy.PolicyManager.HasTagConditions(userCred) {
+		return policy.PolicyManager.AllowScope(userCred, consts.GetServiceType(), resource, action, extra...)
+	}
+	tags, err := metaModel.GetAllMetadata(userCred)
+	if err != nil {
+		log.Errorf("GetAllMetadata of %s %s fail %s", model.Keyword(), model.GetId(), err)
+		return rbacutils.ScopeNone
+	}
+	return policy.PolicyManager.ObjectAllowScope(userCred, tags, consts.GetServiceType(), resource, action, extra...)
+}
+
+func policyTagsCondition(manager IModelManager, q *sqlchemy.SQuery, tags map[string]string) sqlchemy.ICondition {
+	conds := make([]sqlchemy.ICondition, 0, len(tags))
+	for k, v := range tags {
+		metaQ := Metadata.Query("obj_id").Equals("obj_type", manager.Keyword()).Equals("key", k)
+		if len(v) > 0 && v != rbacutils.WILD_MATCH {
+			metaQ = metaQ.Equals("value", v)
+		}
+		conds = append(conds, sqlchemy.In(q.Field("id"), metaQ.SubQuery()))
+	}
+	return sqlchemy.AND(conds...)
+}
+
+// filterByPolicyTags keeps the objects meeting any of the tag conditions of policy rules
+func filterByPolicyTags(manager IModelManager, q *sqlchemy.SQuery, tagConds []rbacutils.STagCondition) *sqlchemy.SQuery {
+	conds := make([]sqlchemy.ICondition, 0, len(tagConds))
+	for _, tagCond := range tagConds {
+		andConds := make([]sqlchemy.ICondition, 0, len(tagCond.NotTags)+1)
+		if len(tagCond.Tags) > 0 {
+			andConds = append(andConds, policyTagsCondition(manager, q, tagCond.Tags))
+		}
+		for _, notTags := range tagCond.NotTags {
+			andConds = append(andConds, sqlchemy.NOT(policyTagsCondition(manager, q, notTags)))
+		}
+		conds = append(conds, sqlchemy.AND(andConds...))
+	}
+	return q.Filter(sqlchemy.OR(conds...))
+}
+
 func isJointObjectRbacAllowed(item IJointModel, userCred mcclient.TokenCredential, action string, extra ...string) error {
 	err1 := isObjectRbacAllowed(JointMaster(item), userCred, action, extra...)
 	err2 := isObjectRbacAllowed(JointSlave(item), userCred, action, extra...)
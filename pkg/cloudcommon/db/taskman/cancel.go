@@ -0,0 +1,200 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// TASK_CANCEL_STAGE is the stage a cancelled task enters, a task class
+	// implements OnCancel to recover the status of its objects, e.g.
+	//
+	//	func (self *GuestStartTask) OnCancel(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
+	//		guest.SetStatus(self.UserCred, api.VM_READY, "cancelled")
+	//		self.SetStageFailed(ctx, data)
+	//	}
+	//
+	// the task fails with the cancel reason when the class has no OnCancel.
+	//
+	// The calls the task has sent to the hosts or the other services are not
+	// aborted, e.g. a disk being deployed by the host keeps being deployed, and
+	// their callbacks are dropped once the task is finished. OnCancel shall not
+	// assume the remote operations are stopped. OnCancel runs only once, the
+	// callbacks arriving in the cancel stage are dropped, and it gets no data
+	// of them
+	TASK_CANCEL_STAGE = "on_cancel"
+
+	TASK_CANCEL_KEY = "__cancel"
+)
+
+func (self *STask) IsFinished() bool {
+	return self.Stage == TASK_STAGE_COMPLETE || self.Stage == TASK_STAGE_FAILED
+}
+
+func (self *STask) IsCancelled() bool {
+	return self.Params != nil && self.Params.Contains(TASK_CANCEL_KEY)
+}
+
+func (self *STask) getCancelReason() jsonutils.JSONObject {
+	reason, _ := self.Params.Get(TASK_CANCEL_KEY, "reason")
+	if reason == nil {
+		reason = jsonutils.NewString("cancelled")
+	}
+	return reason
+}
+
+func (self *STask) AllowPerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	if db.IsAdminAllowPerform(userCred, self, "cancel") {
+		return true
+	}
+	return db.IsProjectAllowPerform(userCred, self, "cancel") && userCred.GetProjectId() == self.UserCred.GetProjectId()
+}
+
+// PerformCancel stops a running task: the task enters TASK_CANCEL_STAGE and the
+// pending subtasks of its current stage are cancelled as well. The remote calls
+// of the task, e.g. those to the hosts, are not aborted, their callbacks are
+// dropped once the task finished
+func (self *STask) PerformCancel(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if self.IsFinished() {
+		return nil, httperrors.NewInvalidStatusError("task %s(%s) is already %s", self.TaskName, self.Id, self.Stage)
+	}
+	if self.IsCancelled() {
+		return nil, httperrors.NewInvalidStatusError("task %s(%s) is being cancelled", self.TaskName, self.Id)
+	}
+	reason, _ := data.GetString("reason")
+	if len(reason) == 0 {
+		reason = fmt.Sprintf("cancelled by %s", userCred.GetUserName())
+	}
+	err := self.cancel(ctx, userCred, reason)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	return nil, nil
+}
+
+// lockTaskObjects takes the locks execITask holds while a stage of the task runs
+func (self *STask) lockTaskObjects(ctx context.Context) (func(), error) {
+	objManager, ok := db.GetModelManager(self.ObjName).(db.IStandaloneModelManager)
+	if !ok {
+		return nil, errors.Wrapf(errors.ErrNotFound, "resource manager of %s", self.ObjName)
+	}
+	if self.ObjId == MULTI_OBJECTS_ID {
+		lockman.LockClass(ctx, objManager, self.UserCred.GetProjectId())
+		return func() {
+			lockman.ReleaseClass(ctx, objManager, self.UserCred.GetProjectId())
+		}, nil
+	}
+	obj, err := objManager.FetchById(self.ObjId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch %s %s", self.ObjName, self.ObjId)
+	}
+	lockman.LockObject(ctx, obj)
+	return func() {
+		lockman.ReleaseObject(ctx, obj)
+	}, nil
+}
+
+func (self *STask) cancel(ctx context.Context, userCred mcclient.TokenCredential, reason string) error {
+	subtasks, err := func() ([]SSubTask, error) {
+		// a running stage can't be interrupted, wait for it to move the task on
+		release, err := self.lockTaskObjects(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "lockTaskObjects")
+		}
+		defer release()
+
+		// the task may have been moved on while waiting for the locks
+		task := TaskManager.fetchTask(self.Id)
+		if task == nil {
+			return nil, errors.Wrapf(errors.ErrNotFound, "task %s", self.Id)
+		}
+		if task.IsFinished() || task.IsCancelled() {
+			return nil, errors.Wrapf(httperrors.ErrInvalidStatus, "task %s(%s) is %s", task.TaskName, task.Id, task.Stage)
+		}
+		stage := task.Stage
+		// the pending subtasks must be fetched before leaving the stage
+		subtasks := SubTaskManager.GetInitSubtasks(task.Id, stage)
+
+		cancel := jsonutils.NewDict()
+		cancel.Add(jsonutils.NewString(reason), "reason")
+		cancel.Add(jsonutils.NewString(stage), "stage")
+		cancel.Add(jsonutils.NewString(userCred.GetUserName()), "user")
+		cancel.Add(jsonutils.NewTimeString(time.Now()), "cancel_at")
+		data := jsonutils.NewDict()
+		data.Add(cancel, TASK_CANCEL_KEY)
+		if err := task.SetStage(TASK_CANCEL_STAGE, data); err != nil {
+			return nil, errors.Wrapf(err, "set task %s cancel stage", task.Id)
+		}
+		return subtasks, nil
+	}()
+	if err != nil {
+		return err
+	}
+	log.Infof("Task %s(%s) cancelled: %s", self.TaskName, self.Id, reason)
+
+	// the parent has left the stage, so the failures of the subtasks don't schedule it again
+	for i := range subtasks {
+		subtask := TaskManager.fetchTask(subtasks[i].SubtaskId)
+		if subtask == nil || subtask.IsFinished() || subtask.IsCancelled() {
+			continue
+		}
+		err := subtask.cancel(ctx, userCred, fmt.Sprintf("parent task %s cancelled: %s", self.Id, reason))
+		if err != nil {
+			log.Errorf("cancel subtask %s of %s fail %s", subtask.Id, self.Id, err)
+		}
+	}
+	return self.ScheduleRun(nil)
+}
+
+// enterCancelStage marks TASK_CANCEL_STAGE run, it returns false if the stage
+// has been run, e.g. scheduled by a late callback of the cancelled stage, so
+// that OnCancel doesn't run again nor fail the task twice
+func (self *STask) enterCancelStage(ctx context.Context) bool {
+	lockman.LockRawObject(ctx, TaskManager.Keyword(), self.Id)
+	defer lockman.ReleaseRawObject(ctx, TaskManager.Keyword(), self.Id)
+
+	task := TaskManager.fetchTask(self.Id)
+	if task == nil || task.Stage != TASK_CANCEL_STAGE {
+		return false
+	}
+	cancel, _ := task.Params.Get(TASK_CANCEL_KEY)
+	cancelDict, ok := cancel.(*jsonutils.JSONDict)
+	if !ok || cancelDict.Contains("run_at") {
+		return false
+	}
+	cancelDict = cancelDict.Copy()
+	cancelDict.Add(jsonutils.NewTimeString(time.Now()), "run_at")
+	data := jsonutils.NewDict()
+	data.Add(cancelDict, TASK_CANCEL_KEY)
+	err := task.SaveParams(data)
+	if err != nil {
+		log.Errorf("mark cancel stage of task %s run fail %s", task.Id, err)
+		return false
+	}
+	self.Params = task.Params
+	return true
+}
@@ -0,0 +1,239 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/dbtest"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type STestCancelObjManager struct {
+	db.SStandaloneResourceBaseManager
+}
+
+type STestCancelObj struct {
+	db.SStandaloneResourceBase
+}
+
+var testCancelObjManager *STestCancelObjManager
+
+func init() {
+	testCancelObjManager = &STestCancelObjManager{
+		SStandaloneResourceBaseManager: db.NewStandaloneResourceBaseManager(
+			STestCancelObj{},
+			"test_cancel_objs_tbl",
+			"test_cancel_obj",
+			"test_cancel_objs",
+		),
+	}
+	testCancelObjManager.SetVirtualObject(testCancelObjManager)
+	db.RegisterModelManager(testCancelObjManager)
+	RegisterTask(TestCancelTask{})
+	RegisterTask(TestLateCallbackTask{})
+}
+
+// TestCancelTask reports the stages it runs to the test
+type TestCancelTask struct {
+	STask
+}
+
+var testCancelTaskStages = make(chan string, 10)
+
+func (self *TestCancelTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	testCancelTaskStages <- self.Stage
+}
+
+func (self *TestCancelTask) OnCancel(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	testCancelTaskStages <- fmt.Sprintf("%s %s", self.Stage, self.getCancelReason())
+	self.SetStageFailed(ctx, self.getCancelReason())
+}
+
+// TestLateCallbackTask blocks in OnCancel until released
+type TestLateCallbackTask struct {
+	STask
+}
+
+var (
+	testLateCallbackCancels = make(chan string, 10)
+	testLateCallbackRelease = make(chan struct{})
+)
+
+func (self *TestLateCallbackTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+}
+
+func (self *TestLateCallbackTask) OnCancel(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	testLateCallbackCancels <- data.String()
+	<-testLateCallbackRelease
+	self.SetStageFailed(ctx, self.getCancelReason())
+}
+
+func newTestTaskDB(t *testing.T) func() {
+	cleanup := dbtest.SetupSqliteDB(t,
+		TaskManager.TableSpec(),
+		SubTaskManager.TableSpec(),
+		TaskObjectManager.TableSpec(),
+		testCancelObjManager.TableSpec(),
+	)
+	lockman.Init(lockman.NewInMemoryLockManager())
+	return cleanup
+}
+
+func TestSTask_Cancel(t *testing.T) {
+	cleanup := newTestTaskDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userCred := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	obj := &STestCancelObj{}
+	obj.Id = "obj"
+	obj.Name = "obj"
+	obj.SetModelManager(testCancelObjManager, obj)
+	if err := testCancelObjManager.TableSpec().Insert(ctx, obj); err != nil {
+		t.Fatalf("insert object: %v", err)
+	}
+
+	parent, err := TaskManager.NewTask(ctx, "TestCancelTask", obj, userCred, nil, "", "")
+	if err != nil {
+		t.Fatalf("new task: %v", err)
+	}
+	parent.SetStage("OnWait", nil)
+	subtask, err := TaskManager.NewTask(ctx, "TestCancelTask", obj, userCred, nil, parent.Id, "")
+	if err != nil {
+		t.Fatalf("new subtask: %v", err)
+	}
+
+	// a stage of the task is running
+	stageCtx := context.WithValue(ctx, "stage", "running")
+	lockman.LockObject(stageCtx, obj)
+	done := make(chan error)
+	go func() {
+		done <- parent.cancel(ctx, userCred, "test")
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("cancel should wait for the running stage: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if task := TaskManager.fetchTask(parent.Id); task.IsCancelled() {
+		t.Fatalf("task cancelled while its stage is running")
+	}
+	lockman.ReleaseObject(stageCtx, obj)
+	if err := <-done; err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	stages := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case stage := <-testCancelTaskStages:
+			stages[stage] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("cancel stages not run, got %v", stages)
+		}
+	}
+	for _, want := range []string{
+		`on_cancel "test"`,
+		fmt.Sprintf(`on_cancel "parent task %s cancelled: test"`, parent.Id),
+	} {
+		if !stages[want] {
+			t.Errorf("stage %s not run, got %v", want, stages)
+		}
+	}
+	for _, id := range []string{parent.Id, subtask.Id} {
+		task := TaskManager.fetchTask(id)
+		if !task.IsCancelled() {
+			t.Errorf("task %s not cancelled", id)
+		}
+		cancelStage, _ := task.Params.GetString(TASK_CANCEL_KEY, "stage")
+		if id == parent.Id && cancelStage != "OnWait" {
+			t.Errorf("task %s cancelled at stage %s", id, cancelStage)
+		}
+	}
+
+	if err := parent.cancel(ctx, userCred, "again"); err == nil {
+		t.Errorf("a cancelled task should not be cancelled again")
+	}
+}
+
+func TestSTask_CancelLateCallback(t *testing.T) {
+	cleanup := newTestTaskDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userCred := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	obj := &STestCancelObj{}
+	obj.Id = "late-obj"
+	obj.Name = "late-obj"
+	obj.SetModelManager(testCancelObjManager, obj)
+	if err := testCancelObjManager.TableSpec().Insert(ctx, obj); err != nil {
+		t.Fatalf("insert object: %v", err)
+	}
+	task, err := TaskManager.NewTask(ctx, "TestLateCallbackTask", obj, userCred, nil, "", "")
+	if err != nil {
+		t.Fatalf("new task: %v", err)
+	}
+	task.SetStage("OnWait", nil)
+
+	if err := task.cancel(ctx, userCred, "test"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	select {
+	case data := <-testLateCallbackCancels:
+		if data != "{}" {
+			t.Errorf("OnCancel should get no data, got %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cancel stage not run")
+	}
+
+	// the callbacks of OnWait arrive while OnCancel is running
+	failed := jsonutils.NewDict()
+	failed.Add(jsonutils.NewString("ERROR"), "__status__")
+	for _, data := range []jsonutils.JSONObject{jsonutils.NewDict(), failed} {
+		done := make(chan struct{})
+		go func() {
+			TaskManager.execTask(task.Id, data)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the late callback %s should be dropped instead of waiting for OnCancel", data)
+		}
+	}
+	close(testLateCallbackRelease)
+
+	select {
+	case data := <-testLateCallbackCancels:
+		t.Fatalf("OnCancel run again with %s", data)
+	case <-time.After(200 * time.Millisecond):
+	}
+	cancelled := TaskManager.fetchTask(task.Id)
+	if cancelled.Stage != TASK_STAGE_FAILED {
+		t.Errorf("want task failed, got stage %s", cancelled.Stage)
+	}
+	if runAt, _ := cancelled.Params.GetString(TASK_CANCEL_KEY, "run_at"); len(runAt) == 0 {
+		t.Errorf("the run of the cancel stage is not recorded")
+	}
+}
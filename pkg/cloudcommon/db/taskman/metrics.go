@@ -0,0 +1,70 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/jsonutils"
+)
+
+var (
+	stageCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "taskman",
+			Name:      "stage_total",
+			Help:      "Times the tasks entered the stage, the complete and failed stages count the finished tasks",
+		},
+		[]string{"task_name", "stage"},
+	)
+	stageDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "taskman",
+			Name:      "stage_duration_seconds",
+			Help:      "Duration of the task stages, from entering the stage to leaving it",
+			Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+		},
+		[]string{"task_name", "stage"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(stageCounter, stageDuration)
+}
+
+// currentStageStart returns the time the task entered the current stage,
+// that is the time the previous stage ended, or the creation of the task
+func (self *STask) currentStageStart(params *jsonutils.JSONDict) time.Time {
+	stages := stageHistory(params)
+	if len(stages) == 0 {
+		return self.CreatedAt
+	}
+	last := stages[len(stages)-1]
+	for _, key := range []string{"complete_at", "timeout_at"} {
+		if t, err := last.GetTime(key); err == nil {
+			return t
+		}
+	}
+	return self.CreatedAt
+}
+
+func (self *STask) observeStage(params *jsonutils.JSONDict, nextStage string) {
+	if start := self.currentStageStart(params); !start.IsZero() {
+		stageDuration.WithLabelValues(self.TaskName, self.Stage).Observe(time.Since(start).Seconds())
+	}
+	stageCounter.WithLabelValues(self.TaskName, nextStage).Inc()
+}
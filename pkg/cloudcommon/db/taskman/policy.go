@@ -0,0 +1,255 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// the stuck stage being retried, the retries are kept while the task goes back to it
+	TASK_RETRY_STAGE_KEY = "__retry_stage"
+	// the history of the retries, kept out of __stages which records the completed stages only
+	TASK_STAGE_RETRIES_KEY = "__stage_retries"
+)
+
+// STaskStagePolicy declares how long a stage may wait for its callback and
+// how the watchdog handles the stage when the deadline passed
+type STaskStagePolicy struct {
+	// Timeout of the stage, zero means no deadline
+	Timeout time.Duration
+	// MaxRetries is the times to retry the stage before failing the task
+	MaxRetries int
+	// RetryStage is the stage to run again on retry, default the stage before the stuck one
+	RetryStage string
+	// Backoff before the first retry, doubled on every retry
+	Backoff time.Duration
+}
+
+// STaskPolicy is the stage policies of a task class
+type STaskPolicy struct {
+	// Default applies to the stages not in Stages
+	Default *STaskStagePolicy
+	Stages  map[string]STaskStagePolicy
+}
+
+var taskPolicyTable = make(map[string]*STaskPolicy)
+
+func init() {
+	cronman.RegisterDefaultJobs(addTaskStageWatchdog)
+}
+
+// addTaskStageWatchdog runs the watchdog in every service keeping the tasks in the database
+func addTaskStageWatchdog(cron *cronman.SCronJobManager) {
+	interval := consts.GetTaskStageWatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	err := cron.AddJobAtIntervals("TaskStageWatchdog", interval, TaskManager.CheckStageTimeouts)
+	if err != nil {
+		log.Errorf("add task stage watchdog fail %s", err)
+	}
+}
+
+func normalizeStageName(stage string) string {
+	return utils.Kebab2Camel(stage, "_")
+}
+
+// RegisterTaskPolicy declares the stage timeouts and retries of a task class,
+// the stages are keyed by the names passed to SetStage, e.g. OnStartComplete
+func RegisterTaskPolicy(task interface{}, policy STaskPolicy) {
+	taskName := gotypes.GetInstanceTypeName(task)
+	stages := make(map[string]STaskStagePolicy, len(policy.Stages))
+	for stage, p := range policy.Stages {
+		stages[normalizeStageName(stage)] = p
+	}
+	policy.Stages = stages
+	taskPolicyTable[taskName] = &policy
+}
+
+func getTaskStagePolicy(taskName string, stage string) *STaskStagePolicy {
+	if stage == TASK_STAGE_COMPLETE || stage == TASK_STAGE_FAILED {
+		return nil
+	}
+	policy, ok := taskPolicyTable[taskName]
+	if !ok {
+		return nil
+	}
+	if p, ok := policy.Stages[normalizeStageName(stage)]; ok {
+		return &p
+	}
+	return policy.Default
+}
+
+func (p *STaskStagePolicy) backoff(retries int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	return p.Backoff << uint(retries)
+}
+
+// updateStageDeadline is called when the task enters a new stage
+func (self *STask) updateStageDeadline(params *jsonutils.JSONDict) {
+	retryStage, _ := params.GetString(TASK_RETRY_STAGE_KEY)
+	if self.Stage != retryStage {
+		self.StageRetries = 0
+		params.Remove(TASK_RETRY_STAGE_KEY)
+	}
+	self.StageDeadline = time.Time{}
+	if policy := getTaskStagePolicy(self.TaskName, self.Stage); policy != nil && policy.Timeout > 0 {
+		self.StageDeadline = time.Now().Add(policy.Timeout)
+	}
+}
+
+func (self *STask) IsStageTimeout(now time.Time) bool {
+	return !self.StageDeadline.IsZero() && self.StageDeadline.Before(now) &&
+		self.Stage != TASK_STAGE_COMPLETE && self.Stage != TASK_STAGE_FAILED
+}
+
+// previousStage returns the stage recorded before the current one
+func (self *STask) previousStage() string {
+	stages, _ := self.Params.GetArray("__stages")
+	if len(stages) == 0 {
+		return ""
+	}
+	name, _ := stages[len(stages)-1].GetString("name")
+	return name
+}
+
+// stageHistory merges the completed stages and the timeout retries, every retry
+// follows the completed stages counted by its stage_index
+func stageHistory(params *jsonutils.JSONDict) []jsonutils.JSONObject {
+	stages, _ := params.GetArray("__stages")
+	retries, _ := params.GetArray(TASK_STAGE_RETRIES_KEY)
+	if len(retries) == 0 {
+		return stages
+	}
+	ret := make([]jsonutils.JSONObject, 0, len(stages)+len(retries))
+	j := 0
+	for i := 0; i <= len(stages); i++ {
+		for ; j < len(retries); j++ {
+			if idx, _ := retries[j].Int("stage_index"); idx > int64(i) {
+				break
+			}
+			ret = append(ret, retries[j])
+		}
+		if i < len(stages) {
+			ret = append(ret, stages[i])
+		}
+	}
+	return ret
+}
+
+// CheckStageTimeouts is the watchdog of the tasks, the stages passed their
+// deadlines are retried or failed according to the policy of the task class
+func (manager *STaskManager) CheckStageTimeouts(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	now := time.Now()
+	q := manager.Query().IsNotNull("stage_deadline").LT("stage_deadline", now)
+	q = q.NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+	tasks := make([]STask, 0)
+	err := db.FetchModelObjects(manager, q, &tasks)
+	if err != nil {
+		log.Errorf("fetch timeout tasks fail %s", err)
+		return
+	}
+	for i := range tasks {
+		tasks[i].handleStageTimeout(ctx, now)
+	}
+}
+
+func (self *STask) handleStageTimeout(ctx context.Context, now time.Time) {
+	lockman.LockRawObject(ctx, "tasks", self.Id)
+	defer lockman.ReleaseRawObject(ctx, "tasks", self.Id)
+
+	task := TaskManager.fetchTask(self.Id)
+	if task == nil || !task.IsStageTimeout(now) {
+		return
+	}
+	stage := task.Stage
+	policy := getTaskStagePolicy(task.TaskName, stage)
+	if policy != nil && task.StageRetries < policy.MaxRetries {
+		retryStage := policy.RetryStage
+		if len(retryStage) == 0 {
+			retryStage = task.previousStage()
+		}
+		if len(retryStage) > 0 {
+			task.retryStage(ctx, stage, retryStage, policy)
+			return
+		}
+	}
+
+	log.Warningf("Task %s(%s) stage %s timeout at %s", task.TaskName, task.Id, stage, task.StageDeadline)
+	_, err := db.Update(task, func() error {
+		task.StageDeadline = time.Time{}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("clear task %s stage deadline fail %s", task.Id, err)
+		return
+	}
+	// the failed handler of the stage recovers the object status, then fails the task
+	reason := fmt.Sprintf("stage %s timeout after %d retries", stage, task.StageRetries)
+	task.ScheduleRun(Error2TaskData(fmt.Errorf(reason)))
+}
+
+func (self *STask) retryStage(ctx context.Context, stuckStage string, retryStage string, policy *STaskStagePolicy) {
+	backoff := policy.backoff(self.StageRetries)
+	log.Warningf("Task %s(%s) stage %s timeout, retry %s after %s (%d/%d)", self.TaskName, self.Id, stuckStage, retryStage, backoff, self.StageRetries+1, policy.MaxRetries)
+	_, err := db.Update(self, func() error {
+		params := self.Params.Copy()
+		params.Set(TASK_RETRY_STAGE_KEY, jsonutils.NewString(stuckStage))
+		retries, _ := params.Get(TASK_STAGE_RETRIES_KEY)
+		if retries == nil {
+			retries = jsonutils.NewArray()
+			params.Add(retries, TASK_STAGE_RETRIES_KEY)
+		}
+		retryData := jsonutils.NewDict()
+		retryData.Add(jsonutils.NewString(stuckStage), "name")
+		retryData.Add(jsonutils.NewString(retryStage), "retry_stage")
+		retryData.Add(jsonutils.NewTimeString(time.Now()), "timeout_at")
+		stages, _ := params.GetArray("__stages")
+		retryData.Add(jsonutils.NewInt(int64(len(stages))), "stage_index")
+		retries.(*jsonutils.JSONArray).Add(retryData)
+		self.Params = params
+		self.Stage = retryStage
+		self.StageRetries += 1
+		// the stuck stage renews the deadline, this one covers the retry stage never going back to it
+		self.StageDeadline = time.Now().Add(backoff + policy.Timeout)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("set task %s retry stage fail %s", self.Id, err)
+		return
+	}
+	taskId := self.Id
+	time.AfterFunc(backoff, func() {
+		if err := runTask(taskId, nil); err != nil {
+			log.Errorf("retry task %s fail %s", taskId, err)
+		}
+	})
+}
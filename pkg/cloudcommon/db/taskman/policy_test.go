@@ -0,0 +1,135 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const testWatchdogTimeout = 500 * time.Millisecond
+
+func init() {
+	RegisterTask(TestWatchdogTask{})
+	RegisterTaskPolicy(TestWatchdogTask{}, STaskPolicy{
+		Stages: map[string]STaskStagePolicy{
+			"OnWait": {Timeout: testWatchdogTimeout, MaxRetries: 1},
+		},
+	})
+}
+
+// TestWatchdogTask waits in OnWait for a callback never coming
+type TestWatchdogTask struct {
+	STask
+}
+
+var testWatchdogTaskStages = make(chan string, 10)
+
+func (self *TestWatchdogTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	self.SetStage("OnWait", nil)
+	testWatchdogTaskStages <- "OnInit"
+}
+
+func (self *TestWatchdogTask) OnWaitFailed(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	self.SetStageFailed(ctx, data)
+	testWatchdogTaskStages <- "OnWaitFailed"
+}
+
+func waitWatchdogTaskStage(t *testing.T, want string) {
+	select {
+	case stage := <-testWatchdogTaskStages:
+		if stage != want {
+			t.Fatalf("want stage %s run, got %s", want, stage)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stage %s not run", want)
+	}
+}
+
+func TestSTaskManager_CheckStageTimeouts(t *testing.T) {
+	cleanup := newTestTaskDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userCred := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	obj := &STestCancelObj{}
+	obj.Id = "watchdog-obj"
+	obj.Name = "watchdog-obj"
+	obj.SetModelManager(testCancelObjManager, obj)
+	if err := testCancelObjManager.TableSpec().Insert(ctx, obj); err != nil {
+		t.Fatalf("insert object: %v", err)
+	}
+
+	task, err := TaskManager.NewTask(ctx, "TestWatchdogTask", obj, userCred, nil, "", "")
+	if err != nil {
+		t.Fatalf("new task: %v", err)
+	}
+	task.SetStage("OnWait", nil)
+
+	// the deadline is not passed
+	TaskManager.CheckStageTimeouts(ctx, userCred, false)
+	if task := TaskManager.fetchTask(task.Id); task.Stage != "OnWait" || task.StageRetries != 0 {
+		t.Fatalf("task touched before the deadline: stage %s retries %d", task.Stage, task.StageRetries)
+	}
+
+	// the first timeout goes back to the stage before OnWait
+	time.Sleep(testWatchdogTimeout + 100*time.Millisecond)
+	TaskManager.CheckStageTimeouts(ctx, userCred, false)
+	retried := TaskManager.fetchTask(task.Id)
+	if retried.Stage != TASK_INIT_STAGE || retried.StageRetries != 1 {
+		t.Fatalf("want task retried at %s, got stage %s retries %d", TASK_INIT_STAGE, retried.Stage, retried.StageRetries)
+	}
+	if stage := retried.previousStage(); stage != TASK_INIT_STAGE {
+		t.Errorf("the retry should be kept out of the stage history, previous stage %s", stage)
+	}
+	retries, _ := retried.Params.GetArray(TASK_STAGE_RETRIES_KEY)
+	if len(retries) != 1 {
+		t.Fatalf("want 1 retry recorded, got %d", len(retries))
+	}
+	if name, _ := retries[0].GetString("name"); name != "OnWait" {
+		t.Errorf("want the retry of OnWait recorded, got %s", name)
+	}
+	waitWatchdogTaskStage(t, "OnInit")
+	if task := TaskManager.fetchTask(task.Id); task.Stage != "OnWait" || task.StageRetries != 1 {
+		t.Fatalf("want the retries kept back at OnWait, got stage %s retries %d", task.Stage, task.StageRetries)
+	}
+
+	// the retries are used up, the task fails
+	time.Sleep(testWatchdogTimeout + 100*time.Millisecond)
+	TaskManager.CheckStageTimeouts(ctx, userCred, false)
+	waitWatchdogTaskStage(t, "OnWaitFailed")
+	failed := TaskManager.fetchTask(task.Id)
+	if failed.Stage != TASK_STAGE_FAILED {
+		t.Fatalf("want task failed, got stage %s", failed.Stage)
+	}
+	stages := []string{}
+	for _, timing := range failed.getStageTimings() {
+		name := timing.Name
+		if timing.IsTimeout {
+			name += " timeout"
+		}
+		stages = append(stages, name)
+	}
+	want := `["on_init","OnWait timeout","on_init","OnWait"]`
+	if jsonutils.Marshal(stages).String() != want {
+		t.Errorf("want stage timings %s, got %s", want, jsonutils.Marshal(stages))
+	}
+}
@@ -45,6 +45,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/util/httputils"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 const (
@@ -93,6 +94,11 @@ type STask struct {
 
 	Stage string `width:"64" charset:"ascii" nullable:"false" default:"on_init" list:"user"` // Column(VARCHAR(64, charset='ascii'), nullable=False, default='on_init')
 
+	// StageDeadline is the time the current stage times out, see RegisterTaskPolicy
+	StageDeadline time.Time `nullable:"true" list:"user"`
+	// StageRetries is the times the current stage has been retried after timeout
+	StageRetries int `nullable:"false" default:"0" list:"user"`
+
 	taskObject  db.IStandaloneModel   `ignore:"true"`
 	taskObjects []db.IStandaloneModel `ignore:"true"`
 }
@@ -277,12 +283,14 @@ func (manager *STaskManager) NewTask(
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
 	}
+	task.updateStageDeadline(data)
 	task.SetModelManager(manager, task)
 	err := manager.TableSpec().Insert(ctx, task)
 	if err != nil {
 		log.Errorf("Task insert error %s", err)
 		return nil, err
 	}
+	stageCounter.WithLabelValues(taskName, TASK_INIT_STAGE).Inc()
 	parentTask := task.GetParentTask()
 	if parentTask != nil {
 		st := &SSubTask{TaskId: parentTask.Id, Stage: parentTask.Stage, SubtaskId: task.Id}
@@ -327,12 +335,14 @@ func (manager *STaskManager) NewParallelTask(
 		Params:   data,
 		Stage:    TASK_INIT_STAGE,
 	}
+	task.updateStageDeadline(data)
 	task.SetModelManager(manager, task)
 	err := manager.TableSpec().Insert(ctx, task)
 	if err != nil {
 		log.Errorf("Task insert error %s", err)
 		return nil, err
 	}
+	stageCounter.WithLabelValues(taskName, TASK_INIT_STAGE).Inc()
 	for _, obj := range objs {
 		to := STaskObject{TaskId: task.Id, ObjId: obj.GetId()}
 		to.SetModelManager(TaskObjectManager, &to)
@@ -385,6 +395,11 @@ func (manager *STaskManager) execTask(taskId string, data jsonutils.JSONObject)
 	if baseTask == nil {
 		return
 	}
+	if baseTask.IsFinished() {
+		// late callbacks of the remote calls, e.g. those of a cancelled task
+		log.Warningf("Task %s(%s) is %s, drop data %s", baseTask.TaskName, taskId, baseTask.Stage, data)
+		return
+	}
 	taskType, ok := taskTable[baseTask.TaskName]
 	if !ok {
 		log.Errorf("Cannot find task %s", baseTask.TaskName)
@@ -405,6 +420,27 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	ctxData := task.GetRequestContext()
 	ctx := ctxData.GetContext()
 
+	// the stages are the children of the span creating the task, the
+	// requests sent by the stage, including notifyRemoteTask, are the
+	// children of the stage
+	ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("%s.%s", task.TaskName, task.Stage), tracing.SPAN_KIND_INTERNAL)
+	defer span.End()
+	span.SetAttribute("task.id", task.Id)
+	span.SetAttribute("task.name", task.TaskName)
+	span.SetAttribute("task.stage", task.Stage)
+	span.SetAttribute("task.obj_id", task.ObjId)
+	// the locks acquired by the stage are shown with the task
+	ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_TASKNAME, fmt.Sprintf("%s-%s", task.TaskName, task.Id))
+
+	if task.Stage == TASK_CANCEL_STAGE {
+		// the late callbacks of the cancelled stage schedule the task as well
+		if !task.enterCancelStage(ctx) {
+			log.Warningf("Task %s(%s) cancel stage has been run, drop data %s", task.TaskName, task.Id, odata)
+			return
+		}
+		odata = nil
+	}
+
 	taskFailed := false
 
 	var data jsonutils.JSONObject
@@ -437,6 +473,7 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 
 	var stageName string
 	if taskFailed {
+		span.SetError("stage %s failed", task.Stage)
 		stageName = fmt.Sprintf("%sFailed", task.Stage)
 	} else {
 		stageName = task.Stage
@@ -453,6 +490,12 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		funcValue = taskValue.MethodByName(stageName)
 
 		if !funcValue.IsValid() || funcValue.IsNil() {
+			if task.Stage == TASK_CANCEL_STAGE {
+				// cancel handler is optional, the task fails with the cancel reason
+				task.SetStageFailed(ctx, task.getCancelReason())
+				task.SaveRequestContext(&ctxData)
+				return
+			}
 			msg := fmt.Sprintf("Stage %s not found", stageName)
 			if taskFailed {
 				// failed handler is optional, ignore the error
@@ -538,6 +581,7 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 			// call set stage failed, should not call task.SetStageFailed
 			// func SetStageFailed may be overloading
 			log.Errorf("Task %s PANIC on stage %s: %v \n%s", task.TaskName, stageName, r, debug.Stack())
+			span.SetError("panic: %v", r)
 			SetStageFailedFuncValue := taskValue.MethodByName("SetStageFailed")
 			SetStageFailedFuncValue.Call(
 				[]reflect.Value{
@@ -615,6 +659,7 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 			params.Update(data)
 		}
 		if len(stageName) > 0 {
+			self.observeStage(params, stageName)
 			stages, _ := params.Get("__stages")
 			if stages == nil {
 				stages = jsonutils.NewArray()
@@ -626,6 +671,7 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 			stageData.Add(jsonutils.NewTimeString(time.Now()), "complete_at")
 			stageList.Add(stageData)
 			self.Stage = stageName
+			self.updateStageDeadline(params)
 		}
 		self.Params = params
 		return nil
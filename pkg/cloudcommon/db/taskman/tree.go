@@ -0,0 +1,177 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// the parent chain of tasks is short, the limit only guards against loops
+const maxTaskTreeDepth = 32
+
+type STaskStageTiming struct {
+	Name      string    `json:"name"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Duration  float64   `json:"duration"`
+	IsTimeout bool      `json:"is_timeout,omitempty"`
+}
+
+type STaskTreeNode struct {
+	Id       string `json:"id"`
+	TaskName string `json:"task_name,omitempty"`
+	ObjName  string `json:"obj_name,omitempty"`
+	ObjId    string `json:"obj_id,omitempty"`
+	Stage    string `json:"stage,omitempty"`
+
+	// ParentStage and SubtaskStatus are the stage of the parent task
+	// the subtask belongs to and the result of the subtask
+	ParentStage   string `json:"parent_stage,omitempty"`
+	SubtaskStatus string `json:"subtask_status,omitempty"`
+
+	IsCancelled   bool      `json:"is_cancelled,omitempty"`
+	StageDeadline time.Time `json:"stage_deadline,omitempty"`
+	StageRetries  int       `json:"stage_retries,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// Duration is the seconds from creation to the last update
+	Duration float64 `json:"duration"`
+
+	Stages   []STaskStageTiming `json:"stages,omitempty"`
+	Subtasks []*STaskTreeNode   `json:"subtasks,omitempty"`
+}
+
+// getStageTimings converts the stage history of the params into timings,
+// every stage starts when the previous one completed
+func (self *STask) getStageTimings() []STaskStageTiming {
+	stages := stageHistory(self.Params)
+	ret := make([]STaskStageTiming, 0, len(stages))
+	start := self.CreatedAt
+	for _, stage := range stages {
+		timing := STaskStageTiming{StartAt: start}
+		timing.Name, _ = stage.GetString("name")
+		if end, err := stage.GetTime("complete_at"); err == nil {
+			timing.EndAt = end
+		} else if end, err := stage.GetTime("timeout_at"); err == nil {
+			timing.EndAt = end
+			timing.IsTimeout = true
+		}
+		if !timing.EndAt.IsZero() {
+			timing.Duration = timing.EndAt.Sub(timing.StartAt).Seconds()
+			start = timing.EndAt
+		}
+		ret = append(ret, timing)
+	}
+	return ret
+}
+
+func (self *STask) getTreeNode() *STaskTreeNode {
+	return &STaskTreeNode{
+		Id:            self.Id,
+		TaskName:      self.TaskName,
+		ObjName:       self.ObjName,
+		ObjId:         self.ObjId,
+		Stage:         self.Stage,
+		IsCancelled:   self.IsCancelled(),
+		StageDeadline: self.StageDeadline,
+		StageRetries:  self.StageRetries,
+		CreatedAt:     self.CreatedAt,
+		UpdatedAt:     self.UpdatedAt,
+		Duration:      self.UpdatedAt.Sub(self.CreatedAt).Seconds(),
+		Stages:        self.getStageTimings(),
+	}
+}
+
+func (manager *SSubTaskmanager) GetSubtasksOfTask(taskId string) []SSubTask {
+	subtasks := make([]SSubTask, 0)
+	q := manager.Query().Equals("task_id", taskId)
+	err := db.FetchModelObjects(manager, q, &subtasks)
+	if err != nil {
+		log.Errorf("GetSubtasksOfTask fail %s", err)
+		return nil
+	}
+	return subtasks
+}
+
+// isAllowGetDetails checks the permission the dispatcher checks on getting the task
+func (self *STask) isAllowGetDetails(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	if consts.IsRbacEnabled() {
+		return db.IsObjectRbacAllowed(self, userCred, policy.PolicyActionGet) == nil
+	}
+	return self.AllowGetDetails(ctx, userCred, nil)
+}
+
+// buildTree shows only the ids of the tasks the user is not allowed to get,
+// e.g. those of the other projects in the tree
+func (self *STask) buildTree(ctx context.Context, userCred mcclient.TokenCredential, depth int) *STaskTreeNode {
+	var node *STaskTreeNode
+	if self.isAllowGetDetails(ctx, userCred) {
+		node = self.getTreeNode()
+	} else {
+		node = &STaskTreeNode{Id: self.Id}
+	}
+	if depth >= maxTaskTreeDepth {
+		return node
+	}
+	subtasks := SubTaskManager.GetSubtasksOfTask(self.Id)
+	for i := range subtasks {
+		var child *STaskTreeNode
+		if subtask := TaskManager.fetchTask(subtasks[i].SubtaskId); subtask != nil {
+			child = subtask.buildTree(ctx, userCred, depth+1)
+		} else {
+			child = &STaskTreeNode{Id: subtasks[i].SubtaskId}
+		}
+		child.ParentStage = subtasks[i].Stage
+		child.SubtaskStatus = subtasks[i].Status
+		node.Subtasks = append(node.Subtasks, child)
+	}
+	return node
+}
+
+// getRootTask walks up the parent tasks in the same service
+func (self *STask) getRootTask() *STask {
+	root := self
+	visited := map[string]bool{self.Id: true}
+	for i := 0; i < maxTaskTreeDepth; i++ {
+		parent := root.GetParentTask()
+		if parent == nil || visited[parent.Id] {
+			break
+		}
+		visited[parent.Id] = true
+		root = parent
+	}
+	return root
+}
+
+func (self *STask) AllowGetDetailsTree(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) bool {
+	return self.AllowGetDetails(ctx, userCred, query)
+}
+
+// GetDetailsTree returns the task hierarchy containing the task, from its root
+// task down to all the subtasks, with the stages and timings of every task the
+// user is allowed to get
+func (self *STask) GetDetailsTree(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	root := self.getRootTask()
+	return jsonutils.Marshal(root.buildTree(ctx, userCred, 0)), nil
+}
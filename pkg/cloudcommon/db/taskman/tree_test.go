@@ -0,0 +1,76 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func TestSTask_GetDetailsTree(t *testing.T) {
+	cleanup := newTestTaskDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	alice := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	bob := &mcclient.SSimpleToken{UserId: "bob", User: "bob", ProjectId: "other"}
+	obj := &STestCancelObj{}
+	obj.Id = "tree-obj"
+	obj.Name = "tree-obj"
+	obj.SetModelManager(testCancelObjManager, obj)
+	if err := testCancelObjManager.TableSpec().Insert(ctx, obj); err != nil {
+		t.Fatalf("insert object: %v", err)
+	}
+
+	// the task of bob is a subtask of the task of alice
+	parent, err := TaskManager.NewTask(ctx, "TestLateCallbackTask", obj, alice, nil, "", "")
+	if err != nil {
+		t.Fatalf("new task: %v", err)
+	}
+	parent.SetStage("OnWait", nil)
+	subtask, err := TaskManager.NewTask(ctx, "TestLateCallbackTask", obj, bob, nil, parent.Id, "")
+	if err != nil {
+		t.Fatalf("new subtask: %v", err)
+	}
+
+	for _, c := range []struct {
+		userCred    mcclient.TokenCredential
+		parentShown bool
+		subShown    bool
+	}{
+		{userCred: alice, parentShown: true, subShown: false},
+		{userCred: bob, parentShown: false, subShown: true},
+	} {
+		tree, err := subtask.GetDetailsTree(ctx, c.userCred, nil)
+		if err != nil {
+			t.Fatalf("GetDetailsTree: %v", err)
+		}
+		root := STaskTreeNode{}
+		if err := tree.Unmarshal(&root); err != nil {
+			t.Fatalf("unmarshal tree: %v", err)
+		}
+		if root.Id != parent.Id || len(root.Subtasks) != 1 || root.Subtasks[0].Id != subtask.Id {
+			t.Fatalf("%s: want the tree of %s and %s, got %s", c.userCred.GetUserName(), parent.Id, subtask.Id, tree)
+		}
+		if shown := len(root.TaskName) > 0; shown != c.parentShown {
+			t.Errorf("%s: want the task of alice shown %v, got %s", c.userCred.GetUserName(), c.parentShown, tree)
+		}
+		if shown := len(root.Subtasks[0].TaskName) > 0; shown != c.subShown {
+			t.Errorf("%s: want the task of bob shown %v, got %s", c.userCred.GetUserName(), c.subShown, tree)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/appsrv/ratelimit"
+)
+
+const (
+	rateLimitMaxConflictRetry = 5
+)
+
+// SRateLimitStore shares the token buckets of the rate limiter among the
+// replicas of a service. The buckets of a request are updated in a single
+// transaction with compare-and-swap on their revisions, so concurrent
+// requests of a user on different replicas never take the same token twice
+// and a bucket rejecting the request doesn't consume the others. A bucket is
+// put with a lease expiring after it is refilled, so the buckets of idle keys
+// are dropped by etcd. The lease is kept by the bucket as long as it outlives
+// the refill, a new one is granted only when the bucket is created or its
+// lease is about to expire
+type SRateLimitStore struct {
+	cli    *SEtcdClient
+	prefix string
+}
+
+// sRateLimitBucket is the bucket put in etcd with the expiry of its lease
+type sRateLimitBucket struct {
+	ratelimit.SBucket
+	LeaseExpireAt time.Time `json:"lease_expire_at"`
+}
+
+type sRateLimitLease struct {
+	id       clientv3.LeaseID
+	expireAt time.Time
+}
+
+func NewRateLimitStore(cli *SEtcdClient, prefix string) *SRateLimitStore {
+	return &SRateLimitStore{
+		cli:    cli,
+		prefix: prefix,
+	}
+}
+
+func (s *SRateLimitStore) grant(ctx context.Context, rate ratelimit.SRate, now time.Time) (*sRateLimitLease, error) {
+	// the lease lasts twice the refill, so it is renewed at most once a
+	// refill while the key is busy
+	ttl := int64(math.Ceil(2*rate.FullAfter().Seconds())) + 1
+	lease, err := s.cli.client.Grant(ctx, ttl)
+	if err != nil {
+		return nil, errors.Wrap(err, "grant lease")
+	}
+	return &sRateLimitLease{
+		id:       lease.ID,
+		expireAt: now.Add(time.Duration(ttl) * time.Second),
+	}, nil
+}
+
+func (s *SRateLimitStore) revoke(ctx context.Context, leases []clientv3.LeaseID) {
+	for _, id := range leases {
+		if _, err := s.cli.client.Revoke(ctx, id); err != nil {
+			log.Warningf("revoke lease %x: %v", id, err)
+		}
+	}
+}
+
+func (s *SRateLimitStore) Take(ctx context.Context, limits []ratelimit.SLimit, now time.Time) (int, time.Duration, error) {
+	nctx, cancel := context.WithTimeout(ctx, s.cli.requestTimeout)
+	defer cancel()
+
+	keys := make([]string, len(limits))
+	rates := make([]ratelimit.SRate, len(limits))
+	for i, limit := range limits {
+		keys[i] = s.cli.getKey(fmt.Sprintf("%s/%s", s.prefix, limit.Key))
+		rates[i] = limit.Rate
+	}
+	// the leases granted are kept across the retries, the ones left unused
+	// and the ones replaced are revoked at last
+	granted := make([]*sRateLimitLease, len(limits))
+	unused := make([]clientv3.LeaseID, 0)
+	defer func() {
+		for _, lease := range granted {
+			if lease != nil {
+				unused = append(unused, lease.id)
+			}
+		}
+		s.revoke(nctx, unused)
+	}()
+
+	for i := 0; i < rateLimitMaxConflictRetry; i++ {
+		gets := make([]clientv3.Op, len(keys))
+		for j := range keys {
+			gets[j] = clientv3.OpGet(keys[j])
+		}
+		resp, err := s.cli.client.Txn(nctx).Then(gets...).Commit()
+		if err != nil {
+			return -1, 0, errors.Wrap(err, "get buckets")
+		}
+		stored := make([]sRateLimitBucket, len(keys))
+		buckets := make([]*ratelimit.SBucket, len(keys))
+		kvLeases := make([]clientv3.LeaseID, len(keys))
+		cmps := make([]clientv3.Cmp, len(keys))
+		for j, r := range resp.Responses {
+			cmps[j] = clientv3.Compare(clientv3.CreateRevision(keys[j]), "=", 0)
+			if kvs := r.GetResponseRange().Kvs; len(kvs) > 0 {
+				if err := json.Unmarshal(kvs[0].Value, &stored[j]); err != nil {
+					return -1, 0, errors.Wrapf(err, "unmarshal bucket %s", kvs[0].Value)
+				}
+				kvLeases[j] = clientv3.LeaseID(kvs[0].Lease)
+				cmps[j] = clientv3.Compare(clientv3.ModRevision(keys[j]), "=", kvs[0].ModRevision)
+			}
+			buckets[j] = &stored[j].SBucket
+		}
+		rejected, wait := ratelimit.TakeAll(buckets, rates, now)
+		if rejected >= 0 {
+			// nothing is taken, the buckets are refilled the same way by
+			// the next request
+			return rejected, wait, nil
+		}
+		puts := make([]clientv3.Op, len(keys))
+		renewed := make([]bool, len(keys))
+		replaced := make([]clientv3.LeaseID, 0)
+		for j := range keys {
+			leaseId := kvLeases[j]
+			if leaseId == clientv3.NoLease || stored[j].LeaseExpireAt.Before(stored[j].Last.Add(rates[j].FullAfter())) {
+				if granted[j] == nil {
+					granted[j], err = s.grant(nctx, rates[j], now)
+					if err != nil {
+						return -1, 0, err
+					}
+				}
+				if leaseId != clientv3.NoLease {
+					replaced = append(replaced, leaseId)
+				}
+				leaseId = granted[j].id
+				renewed[j] = true
+				stored[j].LeaseExpireAt = granted[j].expireAt
+			}
+			val, err := json.Marshal(&stored[j])
+			if err != nil {
+				return -1, 0, errors.Wrap(err, "marshal bucket")
+			}
+			puts[j] = clientv3.OpPut(keys[j], string(val), clientv3.WithLease(leaseId))
+		}
+		txnResp, err := s.cli.client.Txn(nctx).If(cmps...).Then(puts...).Commit()
+		if err != nil {
+			return -1, 0, errors.Wrap(err, "update buckets")
+		}
+		if txnResp.Succeeded {
+			for j := range keys {
+				if renewed[j] {
+					granted[j] = nil
+				}
+			}
+			unused = append(unused, replaced...)
+			return -1, 0, nil
+		}
+	}
+	return -1, 0, errors.Wrapf(errors.ErrTimeout, "buckets %v updated concurrently", keys)
+}
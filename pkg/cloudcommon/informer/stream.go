@@ -0,0 +1,212 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+)
+
+const (
+	StreamBackendType = "stream"
+
+	streamBufferSize   = 4096
+	streamMaxBatchSize = 1024
+
+	StreamWatchDefaultTimeout = 30 * time.Second
+	StreamWatchMaxTimeout     = 60 * time.Second
+
+	// the resources not polled for the duration are no longer informed
+	streamWatchIdleExpire = 5 * time.Minute
+)
+
+// SStreamEvent is a change of a resource, the revisions increase in the order
+// of the changes
+type SStreamEvent struct {
+	Revision      int64               `json:"revision"`
+	EventType     TEventType          `json:"event_type"`
+	KeywordPlural string              `json:"keyword_plural"`
+	Object        *jsonutils.JSONDict `json:"object"`
+	OldObject     *jsonutils.JSONDict `json:"old_object,omitempty"`
+}
+
+// SStreamWatchResult is the response of a poll, the next poll resumes from
+// Revision. Compacted is true if some of the events after the revision of the
+// poll are lost, e.g. the buffer has been overwritten or the service has been
+// restarted
+type SStreamWatchResult struct {
+	Revision  int64          `json:"revision"`
+	Compacted bool           `json:"compacted"`
+	Events    []SStreamEvent `json:"events"`
+}
+
+// SStreamBackend keeps the latest events in memory and serves them to the
+// long polling watchers of the service, so that the resources can be watched
+// without etcd. The events are lost on restart and are not shared by the
+// replicas of the service
+type SStreamBackend struct {
+	lock sync.Mutex
+
+	events []SStreamEvent
+	// revision is the revision of the latest event
+	revision int64
+	// compacted is the revision of the latest event dropped from the buffer
+	compacted int64
+	// notify is closed and renewed on new events
+	notify chan struct{}
+
+	watched   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewStreamBackend() *SStreamBackend {
+	// revisions start from the boot time, so that the revisions of the
+	// previous run are detected as compacted
+	base := time.Now().UnixNano()
+	return &SStreamBackend{
+		events:    make([]SStreamEvent, 0, streamBufferSize),
+		revision:  base,
+		compacted: base,
+		notify:    make(chan struct{}),
+		watched:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (b *SStreamBackend) GetType() string {
+	return StreamBackendType
+}
+
+func (b *SStreamBackend) Create(ctx context.Context, obj *ModelObject) error {
+	b.append(EventTypeCreate, obj, nil)
+	return nil
+}
+
+func (b *SStreamBackend) Update(ctx context.Context, obj *ModelObject, oldObj *jsonutils.JSONDict) error {
+	b.append(EventTypeUpdate, obj, oldObj)
+	return nil
+}
+
+func (b *SStreamBackend) Delete(ctx context.Context, obj *ModelObject) error {
+	b.append(EventTypeDelete, obj, nil)
+	return nil
+}
+
+func (b *SStreamBackend) append(eventType TEventType, obj *ModelObject, oldObj *jsonutils.JSONDict) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.revision += 1
+	b.events = append(b.events, SStreamEvent{
+		Revision:      b.revision,
+		EventType:     eventType,
+		KeywordPlural: obj.KeywordPlural,
+		Object:        obj.Object,
+		OldObject:     oldObj,
+	})
+	if len(b.events) >= 2*streamBufferSize {
+		drop := len(b.events) - streamBufferSize
+		b.compacted = b.events[drop-1].Revision
+		events := make([]SStreamEvent, streamBufferSize, 2*streamBufferSize)
+		copy(events, b.events[drop:])
+		b.events = events
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// touch marks the resource watched, the resources not watched any more are
+// swept
+func (b *SStreamBackend) touch(keywordPlural string) {
+	now := time.Now()
+	b.watched[keywordPlural] = now
+	AddWatchedResources(keywordPlural)
+
+	if now.Sub(b.lastSweep) < time.Minute {
+		return
+	}
+	b.lastSweep = now
+	for res, last := range b.watched {
+		if now.Sub(last) > streamWatchIdleExpire {
+			delete(b.watched, res)
+			DeleteWatchedResources(res)
+		}
+	}
+}
+
+// collect returns the events of the resource after the revision, the caller
+// holds the lock
+func (b *SStreamBackend) collect(keywordPlural string, revision int64) *SStreamWatchResult {
+	ret := &SStreamWatchResult{
+		Revision: b.revision,
+		Events:   []SStreamEvent{},
+	}
+	if revision < b.compacted || revision > b.revision {
+		ret.Compacted = true
+		return ret
+	}
+	start := sort.Search(len(b.events), func(i int) bool {
+		return b.events[i].Revision > revision
+	})
+	for i := start; i < len(b.events); i++ {
+		if b.events[i].KeywordPlural != keywordPlural {
+			continue
+		}
+		ret.Events = append(ret.Events, b.events[i])
+		if len(ret.Events) >= streamMaxBatchSize {
+			ret.Revision = b.events[i].Revision
+			break
+		}
+	}
+	return ret
+}
+
+// Watch returns the events of the resource after the revision, waits for the
+// new events until timeout if there are none. A non-positive revision returns
+// the current revision at once to start the watch from
+func (b *SStreamBackend) Watch(ctx context.Context, keywordPlural string, revision int64, timeout time.Duration) *SStreamWatchResult {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		b.lock.Lock()
+		b.touch(keywordPlural)
+		if revision <= 0 {
+			ret := &SStreamWatchResult{Revision: b.revision, Events: []SStreamEvent{}}
+			b.lock.Unlock()
+			return ret
+		}
+		ret := b.collect(keywordPlural, revision)
+		notify := b.notify
+		b.lock.Unlock()
+
+		if ret.Compacted || len(ret.Events) > 0 {
+			return ret
+		}
+		select {
+		case <-notify:
+			// events of other resources also wake up the watch, the
+			// events of the resource are collected again
+		case <-timer.C:
+			return ret
+		case <-ctx.Done():
+			return ret
+		}
+	}
+}
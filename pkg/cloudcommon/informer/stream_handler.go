@@ -0,0 +1,83 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+)
+
+var (
+	// the long polls hold the workers, keep them off the request workers
+	streamWatchWorkerMan *appsrv.SWorkerManager
+)
+
+func init() {
+	streamWatchWorkerMan = appsrv.NewWorkerManager("informer_watch_worker", 32, 1024, false)
+}
+
+// AddWatchHandler serves the events of the stream backend at
+// GET <prefix>/informer/watch?resource=<keyword_plural>&revision=<revision>&timeout=<seconds>
+func AddWatchHandler(prefix string, app *appsrv.Application) {
+	hi := app.AddHandler2("GET", fmt.Sprintf("%s/informer/watch", prefix), auth.Authenticate(streamWatchHandler), nil, "informer_watch", nil)
+	hi.SetProcessTimeout(StreamWatchMaxTimeout + time.Minute).SetWorkerManager(streamWatchWorkerMan)
+}
+
+func streamWatchHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	userCred := auth.FetchUserCredential(ctx, nil)
+	if userCred == nil || !userCred.HasSystemAdminPrivilege() {
+		httperrors.ForbiddenError(ctx, w, "not enough privilege")
+		return
+	}
+	be := GetDefaultBackend()
+	if be == nil {
+		httperrors.GeneralServerError(ctx, w, httperrors.NewNotSupportedError("informer backend not init"))
+		return
+	}
+	stream, ok := be.(*SStreamBackend)
+	if !ok {
+		httperrors.GeneralServerError(ctx, w, httperrors.NewNotSupportedError("informer backend is %s", be.GetType()))
+		return
+	}
+	_, query, _ := appsrv.FetchEnv(ctx, w, r)
+	resource, _ := query.GetString("resource")
+	if len(resource) == 0 {
+		httperrors.GeneralServerError(ctx, w, httperrors.NewMissingParameterError("resource"))
+		return
+	}
+	revision, _ := query.Int("revision")
+	timeout := StreamWatchDefaultTimeout
+	if query.Contains("timeout") {
+		seconds, err := query.Int("timeout")
+		if err != nil || seconds <= 0 {
+			httperrors.GeneralServerError(ctx, w, httperrors.NewInputParameterError("invalid timeout"))
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+		if timeout > StreamWatchMaxTimeout {
+			timeout = StreamWatchMaxTimeout
+		}
+	}
+	ret := stream.Watch(r.Context(), resource, revision, timeout)
+	appsrv.SendJSON(w, jsonutils.Marshal(ret))
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type streamTestObj struct {
+	Id   string
+	Name string
+}
+
+func TestStreamBackendWatch(t *testing.T) {
+	ctx := context.Background()
+	be := NewStreamBackend()
+
+	ret := be.Watch(ctx, "servers", 0, time.Second)
+	if ret.Compacted || len(ret.Events) != 0 {
+		t.Fatalf("initial watch want no events, got %#v", ret)
+	}
+	rev := ret.Revision
+
+	be.Create(ctx, NewModel(streamTestObj{Id: "s1", Name: "vm"}, "servers", "s1"))
+	be.Create(ctx, NewModel(streamTestObj{Id: "d1", Name: "disk"}, "disks", "d1"))
+	be.Delete(ctx, NewModel(streamTestObj{Id: "s1", Name: "vm"}, "servers", "s1"))
+
+	ret = be.Watch(ctx, "servers", rev, time.Second)
+	if ret.Compacted {
+		t.Fatalf("watch from %d compacted", rev)
+	}
+	if len(ret.Events) != 2 {
+		t.Fatalf("want 2 server events, got %d", len(ret.Events))
+	}
+	if ret.Events[0].EventType != EventTypeCreate || ret.Events[1].EventType != EventTypeDelete {
+		t.Errorf("unexpected events order %s %s", ret.Events[0].EventType, ret.Events[1].EventType)
+	}
+	if ret.Revision != rev+3 {
+		t.Errorf("want revision %d, got %d", rev+3, ret.Revision)
+	}
+
+	// resuming from the returned revision waits for new events
+	start := time.Now()
+	ret = be.Watch(ctx, "servers", ret.Revision, 100*time.Millisecond)
+	if len(ret.Events) != 0 || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("resumed watch should time out without events, got %d", len(ret.Events))
+	}
+}
+
+func TestStreamBackendWatchWakeup(t *testing.T) {
+	ctx := context.Background()
+	be := NewStreamBackend()
+	rev := be.Watch(ctx, "disks", 0, time.Second).Revision
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		be.Update(ctx, NewModel(streamTestObj{Id: "d1", Name: "new"}, "disks", "d1"), nil)
+	}()
+	ret := be.Watch(ctx, "disks", rev, 5*time.Second)
+	if len(ret.Events) != 1 || ret.Events[0].EventType != EventTypeUpdate {
+		t.Fatalf("want 1 update event, got %#v", ret.Events)
+	}
+}
+
+func TestStreamBackendCompacted(t *testing.T) {
+	ctx := context.Background()
+	be := NewStreamBackend()
+	rev := be.Watch(ctx, "servers", 0, time.Second).Revision
+
+	for i := 0; i < 2*streamBufferSize; i++ {
+		be.Create(ctx, NewModel(streamTestObj{Id: "s"}, "servers", "s"))
+	}
+	ret := be.Watch(ctx, "servers", rev, time.Second)
+	if !ret.Compacted {
+		t.Fatalf("watch from dropped revision %d should be compacted", rev)
+	}
+	// a revision the backend never issued is compacted too
+	ret = be.Watch(ctx, "servers", ret.Revision+100, time.Second)
+	if !ret.Compacted {
+		t.Fatalf("watch from future revision should be compacted")
+	}
+}
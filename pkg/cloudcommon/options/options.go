@@ -98,6 +98,22 @@ type BaseOptions struct {
 
 	CustomizedPrivatePrefixes []string `help:"customized private prefixes"`
 
+	EnableRateLimit            bool     `help:"enable per user and per project rate limiting of the APIs" default:"false"`
+	RateLimitReadQps           float64  `help:"requests per second a user can make to the read (GET/HEAD) APIs" default:"50"`
+	RateLimitReadBurst         int      `help:"max burst requests a user can make to the read APIs" default:"100"`
+	RateLimitWriteQps          float64  `help:"requests per second a user can make to the write APIs" default:"10"`
+	RateLimitWriteBurst        int      `help:"max burst requests a user can make to the write APIs" default:"20"`
+	RateLimitProjectFactor     float64  `help:"rates of a project relative to the rates of a user, 0 disables the project level limiting" default:"5"`
+	RateLimitExemptSystemAdmin bool     `help:"exempt the system admins and the service accounts from rate limiting" default:"true" json:",allowfalse"`
+	RateLimitTrustedProxies    []string `help:"ips or cidrs of the proxies whose X-Forwarded-For and X-Real-Ip headers are trusted to limit the anonymous requests by client ip"`
+
+	TracingOtlpEndpoint string   `help:"base url of the OTLP/HTTP receiver to export the traces, e.g. http://otel-collector:4318, tracing is disabled if empty"`
+	TracingSampleRatio  float64  `help:"ratio of the traces started by the service to export, the traces of the callers follow the decisions of the callers" default:"1"`
+	TracingOtlpHeaders  []string `help:"extra headers of the OTLP requests in format of key=value"`
+
+	SlowLockWaitSeconds int `help:"warn the locks waited for longer than the seconds" default:"10"`
+	SlowLockHoldSeconds int `help:"warn the locks held longer than the seconds" default:"30"`
+
 	structarg.BaseOptions
 
 	GlobalHTTPProxy  string `help:"Global http proxy"`
@@ -107,6 +123,13 @@ type BaseOptions struct {
 const (
 	LockMethodInMemory = "inmemory"
 	LockMethodEtcd     = "etcd"
+	LockMethodDB       = "db"
+
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreEtcd   = "etcd"
+
+	InformerBackendEtcd   = "etcd"
+	InformerBackendStream = "stream"
 )
 
 type CommonOptions struct {
@@ -118,6 +141,8 @@ type CommonOptions struct {
 	AdminProjectDomain string `help:"Domain of Admin project" default:"default"`
 	AuthTokenCacheSize uint32 `help:"Auth token Cache Size" default:"2048"`
 
+	TokenCacheExpireSeconds int `help:"seconds a verified token is cached before verified by keystone again, i.e. the delay a disabled user or a revoked token is rejected" default:"300"`
+
 	TenantCacheExpireSeconds int `help:"expire seconds of cached tenant/domain info. defailt 15 minutes" default:"900"`
 
 	SessionEndpointType string `help:"Client session end point type"`
@@ -145,7 +170,14 @@ type DBOptions struct {
 
 	HistoricalUniqueName bool `help:"use historically unique name" default:"false"`
 
-	LockmanMethod string `help:"method for lock synchronization" choices:"inmemory|etcd" default:"inmemory"`
+	LockmanMethod string `help:"method for lock synchronization, db keeps the locks in the database shared by the replicas" choices:"inmemory|etcd|db" default:"inmemory"`
+	DBLockTTL     int    `help:"ttl of db lock leases in seconds, the clocks of the replicas shall be synchronized well within it" default:"10"`
+
+	TaskStageWatchdogIntervalSeconds int `help:"interval to check the task stages passed their deadlines" default:"60"`
+
+	RateLimitStore string `help:"where the rate limiter keeps the token buckets, etcd shares them among the replicas" choices:"memory|etcd" default:"memory"`
+
+	InformerBackend string `help:"backend of the resource informer, stream serves the change events at the informer/watch endpoint of the service itself when etcd is not available" choices:"etcd|stream" default:"etcd"`
 
 	// SplitableMaxKeepSegments  int `help:"maximal segements of splitable to keep, default 6 segments" default:"6"`
 	// SplitableMaxDurationHours int `help:"maximal number of hours that a splitable segement lasts, default 30 days" default:"720"`
@@ -154,6 +186,8 @@ type DBOptions struct {
 
 	EtcdLockPrefix string `help:"prefix of etcd lock records" default:"/onecloud/lockman"`
 	EtcdLockTTL    int    `help:"ttl of etcd lock records" default:"5"`
+
+	EtcdRateLimitPrefix string `help:"prefix of etcd rate limit records" default:"/onecloud/ratelimit"`
 }
 
 type EtcdOptions struct {
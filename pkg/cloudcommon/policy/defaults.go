@@ -69,6 +69,11 @@ var (
 	}
 )
 
+// GetDefaultPolicies returns the default policies built in the service, including those of the common services
+func GetDefaultPolicies() []rbacutils.SRbacPolicy {
+	return predefinedDefaultPolicies
+}
+
 func AppendDefaultPolicies(policies []rbacutils.SRbacPolicy) {
 	predefinedDefaultPolicies = append(predefinedDefaultPolicies, policies...)
 }
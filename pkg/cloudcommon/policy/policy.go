@@ -147,7 +147,16 @@ func policyKey(userCred mcclient.TokenCredential) string {
 	return strings.Join(keys, "-")
 }
 
-func permissionKey(scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) string {
+// policyUserKey is the cache key of the policies conditioned on groups, which are evaluated against the groups of each user
+func policyUserKey(key string, userCred mcclient.TokenCredential) string {
+	if userCred == nil || auth.IsGuestToken(userCred) {
+		return key
+	}
+	return strings.Join([]string{key, userCred.GetUserId()}, "-")
+}
+
+// groups is empty unless the policies are conditioned on groups
+func permissionKey(scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, groups []string, service string, resource string, action string, extra ...string) string {
 	queryKeys := []string{string(scope)}
 	queryKeys = append(queryKeys, userCred.GetProjectId())
 	roles := userCred.GetRoleIds()
@@ -155,6 +164,11 @@ func permissionKey(scope rbacutils.TRbacScope, userCred mcclient.TokenCredential
 		sort.Strings(roles)
 	}
 	queryKeys = append(queryKeys, strings.Join(roles, ":"))
+	if len(groups) > 0 {
+		groups = append([]string{}, groups...)
+		sort.Strings(groups)
+		queryKeys = append(queryKeys, strings.Join(groups, ":"))
+	}
 	queryKeys = append(queryKeys, getMaskedLoginIp(userCred))
 	if rbacutils.WILD_MATCH == service || len(service) == 0 {
 		service = rbacutils.WILD_MATCH
@@ -189,7 +203,28 @@ func (manager *SPolicyManager) AllowScope(userCred mcclient.TokenCredential, ser
 	return rbacutils.ScopeNone
 }
 
-func (manager *SPolicyManager) Allow(targetScope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
+// ObjectAllowScope is the AllowScope of a request targeting an object, whose metadata tags are evaluated by the tag conditions of rules,
+// the rules conditioned on tags never match in AllowScope
+func (manager *SPolicyManager) ObjectAllowScope(userCred mcclient.TokenCredential, objTags map[string]string, service string, resource string, action string, extra ...string) rbacutils.TRbacScope {
+	if objTags == nil {
+		objTags = map[string]string{}
+	}
+	for _, scope := range []rbacutils.TRbacScope{
+		rbacutils.ScopeSystem,
+		rbacutils.ScopeDomain,
+		rbacutils.ScopeProject,
+		rbacutils.ScopeUser,
+	} {
+		result := manager.allowObject(scope, userCred, objTags, service, resource, action, extra...)
+		if result == rbacutils.Allow {
+			return scope
+		}
+	}
+	return rbacutils.ScopeNone
+}
+
+// GetRetryScopes returns the scopes whose policies are consulted for a request of the target scope
+func GetRetryScopes(targetScope rbacutils.TRbacScope) []rbacutils.TRbacScope {
 	var retryScopes []rbacutils.TRbacScope
 	switch targetScope {
 	case rbacutils.ScopeSystem:
@@ -213,7 +248,11 @@ func (manager *SPolicyManager) Allow(targetScope rbacutils.TRbacScope, userCred
 			rbacutils.ScopeUser,
 		}
 	}
-	for _, scope := range retryScopes {
+	return retryScopes
+}
+
+func (manager *SPolicyManager) Allow(targetScope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
+	for _, scope := range GetRetryScopes(targetScope) {
 		result := manager.allow(scope, userCred, service, resource, action, extra...)
 		if result == rbacutils.Allow {
 			return rbacutils.Allow
@@ -222,6 +261,67 @@ func (manager *SPolicyManager) Allow(targetScope rbacutils.TRbacScope, userCred
 	return rbacutils.Deny
 }
 
+// ListTagConditions returns the tag conditions of the objects that a request in the target scope is allowed on,
+// an object is allowed if it meets any of the conditions. nil is returned if the request is allowed regardless of the tags.
+func (manager *SPolicyManager) ListTagConditions(targetScope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) []rbacutils.STagCondition {
+	ret := make([]rbacutils.STagCondition, 0)
+	if !gotypes.IsNil(userCred) && !mcclient.GetAppCredential(userCred).IsActionAllowed(service, resource, action) {
+		return ret
+	}
+	policies, err := manager.fetchMatchedPolicies(userCred)
+	if err != nil {
+		log.Errorf("fetchMatchedPolicyGroup fail %s", err)
+		return ret
+	}
+	cctx := &rbacutils.SConditionContext{
+		Groups: policies.Groups,
+		Time:   time.Now(),
+	}
+	// evaluated in the same way as allowWithoutCache, any allowing policy allows the request
+	for _, scope := range GetRetryScopes(targetScope) {
+		for _, policy := range policies.Policies[scope] {
+			ret = append(ret, policy.GetTagConditionsWithContext(cctx, service, resource, action, extra...)...)
+		}
+		for _, defaultPolicy := range manager.defaultPolicies[scope] {
+			if isMatched, _ := defaultPolicy.Match(userCred); !isMatched {
+				continue
+			}
+			ret = append(ret, defaultPolicy.Rules.GetTagConditionsWithContext(cctx, service, resource, action, extra...)...)
+		}
+	}
+	if consts.IsRbacDebug() {
+		log.Debugf("[RBAC: %s] %s %s %s %#v tag conditions %s", targetScope, service, resource, action, extra, jsonutils.Marshal(ret))
+	}
+	for i := range ret {
+		if ret[i].IsEmpty() {
+			return nil
+		}
+	}
+	return ret
+}
+
+// HasTagConditions tells whether the decisions for the user vary with the metadata tags of objects
+func (manager *SPolicyManager) HasTagConditions(userCred mcclient.TokenCredential) bool {
+	policies, err := manager.fetchMatchedPolicies(userCred)
+	if err != nil {
+		log.Errorf("fetchMatchedPolicyGroup fail %s", err)
+		return false
+	}
+	for _, policySet := range policies.Policies {
+		if policySet.HasTagConditions() {
+			return true
+		}
+	}
+	for _, defaultPolicies := range manager.defaultPolicies {
+		for i := range defaultPolicies {
+			if defaultPolicies[i].Rules.HasTagConditions() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type fetchResult struct {
 	output *mcclient.SFetchMatchPoliciesOutput
 	err    error
@@ -235,20 +335,57 @@ type policyTask struct {
 }
 
 func (t *policyTask) Run() {
-	val := t.manager.policyCache.Get(t.key)
 	result := fetchResult{}
-	if gotypes.IsNil(val) {
-		pg, err := DefaultPolicyFetcher(context.Background(), t.userCred)
-		if err != nil {
-			result.err = errors.Wrap(err, "DefaultPolicyFetcher")
-		} else {
-			t.manager.policyCache.Set(t.key, pg)
-			result.output = pg
+	result.output, result.err = t.manager.getMatchedPolicies(t.key, t.userCred)
+	t.resChan <- result
+}
+
+// groupPolicies takes the place of the policies conditioned on groups in the cache,
+// which are cached for each user along with the groups of the user
+var groupPolicies = &mcclient.SFetchMatchPoliciesOutput{}
+
+func (manager *SPolicyManager) getMatchedPolicies(key string, userCred mcclient.TokenCredential) (*mcclient.SFetchMatchPoliciesOutput, error) {
+	userKey := policyUserKey(key, userCred)
+	val := manager.policyCache.Get(key)
+	if !gotypes.IsNil(val) {
+		output := val.(*mcclient.SFetchMatchPoliciesOutput)
+		if output != groupPolicies {
+			return output, nil
 		}
+		val = manager.policyCache.Get(userKey)
+		if !gotypes.IsNil(val) {
+			return val.(*mcclient.SFetchMatchPoliciesOutput), nil
+		}
+	}
+	output, err := DefaultPolicyFetcher(context.Background(), userCred)
+	if err != nil {
+		return nil, errors.Wrap(err, "DefaultPolicyFetcher")
+	}
+	if manager.hasGroupConditions(output) {
+		manager.policyCache.Set(key, groupPolicies)
+		manager.policyCache.Set(userKey, output)
 	} else {
-		result.output = val.(*mcclient.SFetchMatchPoliciesOutput)
+		// shared by the users of the same project, roles and login ip
+		output.Groups = nil
+		manager.policyCache.Set(key, output)
 	}
-	t.resChan <- result
+	return output, nil
+}
+
+func (manager *SPolicyManager) hasGroupConditions(output *mcclient.SFetchMatchPoliciesOutput) bool {
+	for _, policySet := range output.Policies {
+		if policySet.HasGroupConditions() {
+			return true
+		}
+	}
+	for _, defaultPolicies := range manager.defaultPolicies {
+		for i := range defaultPolicies {
+			if defaultPolicies[i].Rules.HasGroupConditions() {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (t *policyTask) Dump() string {
@@ -272,28 +409,52 @@ func (manager *SPolicyManager) fetchMatchedPolicies(userCred mcclient.TokenCrede
 }
 
 func (manager *SPolicyManager) allow(scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
+	return manager.allowObject(scope, userCred, nil, service, resource, action, extra...)
+}
+
+// objTags is nil if the request does not target an object
+func (manager *SPolicyManager) allowObject(scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, objTags map[string]string, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
+	// tokens issued by application credentials are restricted to the allowed actions
+	if !gotypes.IsNil(userCred) && !mcclient.GetAppCredential(userCred).IsActionAllowed(service, resource, action) {
+		if consts.IsRbacDebug() {
+			log.Debugf("%s:%s:%s not allowed by application credential", service, resource, action)
+		}
+		return rbacutils.Deny
+	}
 	// first download userCred policy
 	policies, err := manager.fetchMatchedPolicies(userCred)
 	if err != nil {
 		log.Errorf("fetchMatchedPolicyGroup fail %s", err)
 		return rbacutils.Deny
 	}
+	policySet, ok := policies.Policies[scope]
+	if !ok {
+		policySet = rbacutils.TPolicySet{}
+	}
+	// decisions on objects or varying with time are not cached
+	cacheable := objTags == nil && !policySet.HasTimeConditions()
 	// check permission
-	key := permissionKey(scope, userCred, service, resource, action, extra...)
-	val := manager.permissionCache.AtomicGet(key)
-	if !gotypes.IsNil(val) {
-		if consts.IsRbacDebug() {
-			log.Debugf("query %s:%s:%s:%s from cache %s", service, resource, action, extra, val)
+	var key string
+	if cacheable {
+		key = permissionKey(scope, userCred, policies.Groups, service, resource, action, extra...)
+		val := manager.permissionCache.AtomicGet(key)
+		if !gotypes.IsNil(val) {
+			if consts.IsRbacDebug() {
+				log.Debugf("query %s:%s:%s:%s from cache %s", service, resource, action, extra, val)
+			}
+			return val.(rbacutils.TRbacResult)
 		}
-		return val.(rbacutils.TRbacResult)
 	}
 
-	policySet, ok := policies.Policies[scope]
-	if !ok {
-		policySet = rbacutils.TPolicySet{}
+	cctx := &rbacutils.SConditionContext{
+		Groups:     policies.Groups,
+		Time:       time.Now(),
+		ObjectTags: objTags,
+	}
+	result := manager.allowWithoutCache(policySet, scope, userCred, cctx, service, resource, action, extra...)
+	if cacheable {
+		manager.permissionCache.Set(key, result)
 	}
-	result := manager.allowWithoutCache(policySet, scope, userCred, service, resource, action, extra...)
-	manager.permissionCache.Set(key, result)
 	return result
 }
 
@@ -323,33 +484,29 @@ func getMatchedPolicyRules(policies []rbacutils.SPolicyInfo, userCred rbacutils.
 }
 */
 
-func (manager *SPolicyManager) allowWithoutCache(policies rbacutils.TPolicySet, scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
+func isScopedDeny(scope rbacutils.TRbacScope, service string, resource string) bool {
+	switch scope {
+	case rbacutils.ScopeUser:
+		return !isUserResource(service, resource)
+	case rbacutils.ScopeProject:
+		return !isProjectResource(service, resource)
+	case rbacutils.ScopeDomain:
+		return isSystemResource(service, resource)
+	}
+	// no deny at all for system scope
+	return false
+}
+
+func (manager *SPolicyManager) allowWithoutCache(policies rbacutils.TPolicySet, scope rbacutils.TRbacScope, userCred mcclient.TokenCredential, cctx *rbacutils.SConditionContext, service string, resource string, action string, extra ...string) rbacutils.TRbacResult {
 	matchRules := make([]rbacutils.SRbacRule, 0)
 	findMatchPolicy := false
 	if len(policies) == 0 {
 		log.Warningf("no policies fetched for scope %s", scope)
 	} else {
-		matchRules = policies.GetMatchRules(service, resource, action, extra...)
+		matchRules = policies.GetMatchRulesWithContext(cctx, service, resource, action, extra...)
 	}
 
-	scopedDeny := false
-	switch scope {
-	case rbacutils.ScopeUser:
-		if !isUserResource(service, resource) {
-			scopedDeny = true
-		}
-	case rbacutils.ScopeProject:
-		if !isProjectResource(service, resource) {
-			scopedDeny = true
-		}
-	case rbacutils.ScopeDomain:
-		if isSystemResource(service, resource) {
-			scopedDeny = true
-		}
-	case rbacutils.ScopeSystem:
-		// no deny at all for system scope
-	}
-	if scopedDeny {
+	if isScopedDeny(scope, service, resource) {
 		rule := rbacutils.SRbacRule{
 			Service:  service,
 			Resource: resource,
@@ -366,7 +523,7 @@ func (manager *SPolicyManager) allowWithoutCache(policies rbacutils.TPolicySet,
 			if !isMatched {
 				continue
 			}
-			rule := defaultPolicies[i].Rules.GetMatchRule(service, resource, action, extra...)
+			rule := defaultPolicies[i].Rules.GetMatchRuleWithContext(cctx, service, resource, action, extra...)
 			if rule != nil {
 				matchRules = append(matchRules, *rule)
 			}
@@ -397,10 +554,8 @@ func (manager *SPolicyManager) allowWithoutCache(policies rbacutils.TPolicySet,
 	return result
 }
 
-//
 // result: allow/deny for the named policy
 // userResult: allow/deny for the matched policies of userCred
-//
 func explainPolicy(userCred mcclient.TokenCredential, policyReq jsonutils.JSONObject, policyData *sPolicyData) ([]string, rbacutils.TRbacResult, rbacutils.TRbacResult, error) {
 	_, request, result, userResult, err := explainPolicyInternal(userCred, policyReq, policyData)
 	return request, result, userResult, err
@@ -471,7 +626,13 @@ func explainPolicyInternal(userCred mcclient.TokenCredential, policyReq jsonutil
 			if err != nil {
 				return scope, reqStrs, rbacutils.Deny, userResult, errors.Wrap(err, "getPolicy")
 			}
-			rule := policy.GetMatchRule(service, resource, action, extra...)
+			cctx := &rbacutils.SConditionContext{
+				Time: time.Now(),
+			}
+			if policies, err := PolicyManager.fetchMatchedPolicies(userCred); err == nil {
+				cctx.Groups = policies.Groups
+			}
+			rule := policy.GetMatchRuleWithContext(cctx, service, resource, action, extra...)
 			result = rbacutils.Deny
 			if rule != nil {
 				result = rule.Result
@@ -0,0 +1,128 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+func testPolicyFetcher(t *testing.T, scope rbacutils.TRbacScope, policyStr string, groups map[string][]string) PolicyFetchFunc {
+	policyJson, err := jsonutils.ParseString(policyStr)
+	if err != nil {
+		t.Fatalf("parse policy %s", err)
+	}
+	policy, err := rbacutils.DecodePolicy(policyJson)
+	if err != nil {
+		t.Fatalf("DecodePolicy %s", err)
+	}
+	return func(ctx context.Context, token mcclient.TokenCredential) (*mcclient.SFetchMatchPoliciesOutput, error) {
+		return &mcclient.SFetchMatchPoliciesOutput{
+			Policies: rbacutils.TPolicyGroup{
+				scope: rbacutils.TPolicySet{policy},
+			},
+			Groups: groups[token.GetUserId()],
+		}, nil
+	}
+}
+
+func newTestPolicyManager() *SPolicyManager {
+	manager := &SPolicyManager{
+		lock: &sync.Mutex{},
+	}
+	manager.init(time.Minute)
+	return manager
+}
+
+func TestDenyTagConditions(t *testing.T) {
+	fetcher := DefaultPolicyFetcher
+	defer func() {
+		DefaultPolicyFetcher = fetcher
+	}()
+	DefaultPolicyFetcher = testPolicyFetcher(t, rbacutils.ScopeProject, `{
+		"compute": {
+			"servers": {
+				"*": "allow",
+				"list": {"result": "deny", "conditions": {"tags": {"user:env": "prod"}}},
+				"delete": {"result": "deny", "conditions": {"tags": {"user:env": "prod"}}}
+			}
+		}
+	}`, nil)
+	manager := newTestPolicyManager()
+	userCred := &mcclient.SSimpleToken{UserId: "alice", ProjectId: "demo", RoleIds: "member"}
+
+	prod := map[string]string{"user:env": "prod"}
+	dev := map[string]string{"user:env": "dev"}
+	if scope := manager.ObjectAllowScope(userCred, prod, "compute", "servers", "delete"); scope != rbacutils.ScopeNone {
+		t.Errorf("delete prod server: want %s got %s", rbacutils.ScopeNone, scope)
+	}
+	if scope := manager.ObjectAllowScope(userCred, dev, "compute", "servers", "delete"); scope != rbacutils.ScopeProject {
+		t.Errorf("delete dev server: want %s got %s", rbacutils.ScopeProject, scope)
+	}
+	if scope := manager.ObjectAllowScope(userCred, prod, "compute", "servers", "get"); scope != rbacutils.ScopeProject {
+		t.Errorf("get prod server: want %s got %s", rbacutils.ScopeProject, scope)
+	}
+	if !manager.HasTagConditions(userCred) {
+		t.Errorf("the policies should have tag conditions")
+	}
+
+	if conds := manager.ListTagConditions(rbacutils.ScopeProject, userCred, "compute", "servers", "get"); conds != nil {
+		t.Errorf("get servers should not be restricted by tags: %s", jsonutils.Marshal(conds))
+	}
+	conds := manager.ListTagConditions(rbacutils.ScopeProject, userCred, "compute", "servers", "list")
+	want := `[{"not_tags":[{"user:env":"prod"}]}]`
+	if jsonutils.Marshal(conds).String() != want {
+		t.Errorf("list servers: want conditions %s got %s", want, jsonutils.Marshal(conds))
+	}
+	if conds := manager.ListTagConditions(rbacutils.ScopeSystem, userCred, "compute", "servers", "list"); conds == nil || len(conds) > 0 {
+		t.Errorf("list servers of system scope should be denied: %s", jsonutils.Marshal(conds))
+	}
+}
+
+func TestGroupPoliciesCache(t *testing.T) {
+	fetcher := DefaultPolicyFetcher
+	defer func() {
+		DefaultPolicyFetcher = fetcher
+	}()
+	DefaultPolicyFetcher = testPolicyFetcher(t, rbacutils.ScopeProject, `{
+		"compute": {
+			"servers": {
+				"get": "allow",
+				"delete": {"result": "allow", "conditions": {"groups": ["ops"]}}
+			}
+		}
+	}`, map[string][]string{"alice": {"ops"}})
+	manager := newTestPolicyManager()
+
+	alice := &mcclient.SSimpleToken{UserId: "alice", ProjectId: "demo", RoleIds: "member"}
+	bob := &mcclient.SSimpleToken{UserId: "bob", ProjectId: "demo", RoleIds: "member"}
+	if scope := manager.AllowScope(alice, "compute", "servers", "delete"); scope != rbacutils.ScopeProject {
+		t.Errorf("alice delete: want %s got %s", rbacutils.ScopeProject, scope)
+	}
+	// bob shares the project and roles with alice but not the groups
+	if scope := manager.AllowScope(bob, "compute", "servers", "delete"); scope != rbacutils.ScopeNone {
+		t.Errorf("bob delete: want %s got %s", rbacutils.ScopeNone, scope)
+	}
+	if scope := manager.AllowScope(bob, "compute", "servers", "get"); scope != rbacutils.ScopeProject {
+		t.Errorf("bob get: want %s got %s", rbacutils.ScopeProject, scope)
+	}
+}
@@ -55,6 +55,9 @@ const (
 	META_HEADER_CONTENT_MD5         = "Content-MD5"
 
 	META_HEADER_PREFIX = "X-Yunion-Meta-"
+
+	BUCKET_VERSIONING_ENABLED   = "Enabled"
+	BUCKET_VERSIONING_SUSPENDED = "Suspended"
 )
 
 type SBucketStats struct {
@@ -109,6 +112,28 @@ type SBucketCORSRule struct {
 	Id string
 }
 
+type SBucketLifecycleTransition struct {
+	// 对象最后修改多少天后转换
+	Days int
+	// 转换的目标存储类型
+	StorageClass string
+}
+
+type SBucketLifecycleRule struct {
+	// 规则区别标识
+	Id string
+	// 规则作用的对象前缀，为空表示整个bucket
+	Prefix string
+	// 是否启用
+	Enabled bool
+	// 对象最后修改多少天后删除，0表示不删除
+	ExpirationDays int
+	// 存储类型转换
+	Transitions []SBucketLifecycleTransition
+	// 未完成的分片上传初始化多少天后终止，0表示不终止
+	AbortIncompleteMultipartUploadDays int
+}
+
 type SBucketRefererConf struct {
 	// 域名列表
 	DomainList []string
@@ -185,6 +210,26 @@ type SListObjectResult struct {
 	IsTruncated    bool
 }
 
+type SCloudObjectVersion struct {
+	Key       string
+	VersionId string
+	IsLatest  bool
+	// 删除标记，没有对象内容
+	IsDeleteMarker bool
+	SizeBytes      int64
+	StorageClass   string
+	ETag           string
+	LastModified   time.Time
+}
+
+type SListObjectVersionsResult struct {
+	Versions            []SCloudObjectVersion
+	CommonPrefixes      []string
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	IsTruncated         bool
+}
+
 type SGetObjectRange struct {
 	Start int64
 	End   int64
@@ -267,6 +312,17 @@ type ICloudBucket interface {
 	GetCORSRules() ([]SBucketCORSRule, error)
 	DeleteCORS() error
 
+	SetLifecycle(rules []SBucketLifecycleRule) error
+	GetLifecycle() ([]SBucketLifecycleRule, error)
+	DeleteLifecycle() error
+
+	// 返回 Enabled|Suspended，从未开启过多版本返回空
+	GetVersioning() (string, error)
+	SetVersioning(status string) error
+	ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (SListObjectVersionsResult, error)
+	GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *SGetObjectRange) (io.ReadCloser, error)
+	DeleteObjectVersion(ctx context.Context, key string, versionId string) error
+
 	SetReferer(conf SBucketRefererConf) error
 	GetReferer() (SBucketRefererConf, error)
 
@@ -293,6 +349,10 @@ type ICloudObject interface {
 
 	GetAcl() TBucketACLType
 	SetAcl(acl TBucketACLType) error
+
+	GetTags() (map[string]string, error)
+	// SetTags replaces all tags of the object, empty tags removes the tagging
+	SetTags(ctx context.Context, tags map[string]string) error
 }
 
 type SCloudObject struct {
@@ -345,6 +405,14 @@ func (o *SBaseCloudObject) GetMeta() http.Header {
 	return o.Meta
 }
 
+func (o *SBaseCloudObject) GetTags() (map[string]string, error) {
+	return nil, errors.Wrap(ErrNotImplemented, "GetTags")
+}
+
+func (o *SBaseCloudObject) SetTags(ctx context.Context, tags map[string]string) error {
+	return errors.Wrap(ErrNotImplemented, "SetTags")
+}
+
 //func (o *SBaseCloudObject) SetMeta(meta http.Header) error {
 //    return nil
 //}
@@ -0,0 +1,198 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"context"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	LIFECYCLE_STATUS_ENABLED  = "Enabled"
+	LIFECYCLE_STATUS_DISABLED = "Disabled"
+)
+
+type SS3LifecycleFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type SS3LifecycleExpiration struct {
+	Days int `xml:"Days"`
+}
+
+type SS3LifecycleTransition struct {
+	Days         int    `xml:"Days"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type SS3LifecycleAbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+type SS3LifecycleRule struct {
+	ID                             string                                      `xml:"ID,omitempty"`
+	Prefix                         *string                                     `xml:"Prefix,omitempty"`
+	Filter                         *SS3LifecycleFilter                         `xml:"Filter,omitempty"`
+	Status                         string                                      `xml:"Status"`
+	Expiration                     *SS3LifecycleExpiration                     `xml:"Expiration,omitempty"`
+	Transition                     []SS3LifecycleTransition                    `xml:"Transition,omitempty"`
+	AbortIncompleteMultipartUpload *SS3LifecycleAbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+// SS3LifecycleConfiguration is the XML document of the S3 ?lifecycle sub-resource
+type SS3LifecycleConfiguration struct {
+	XMLName xml.Name           `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration"`
+	Rule    []SS3LifecycleRule `xml:"Rule"`
+}
+
+func NewS3LifecycleConfiguration(rules []SBucketLifecycleRule) SS3LifecycleConfiguration {
+	conf := SS3LifecycleConfiguration{}
+	for i := range rules {
+		rule := SS3LifecycleRule{
+			ID:     rules[i].Id,
+			Filter: &SS3LifecycleFilter{Prefix: rules[i].Prefix},
+			Status: LIFECYCLE_STATUS_DISABLED,
+		}
+		if rules[i].Enabled {
+			rule.Status = LIFECYCLE_STATUS_ENABLED
+		}
+		if rules[i].ExpirationDays > 0 {
+			rule.Expiration = &SS3LifecycleExpiration{Days: rules[i].ExpirationDays}
+		}
+		for _, t := range rules[i].Transitions {
+			rule.Transition = append(rule.Transition, SS3LifecycleTransition{Days: t.Days, StorageClass: t.StorageClass})
+		}
+		if rules[i].AbortIncompleteMultipartUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload = &SS3LifecycleAbortIncompleteMultipartUpload{
+				DaysAfterInitiation: rules[i].AbortIncompleteMultipartUploadDays,
+			}
+		}
+		conf.Rule = append(conf.Rule, rule)
+	}
+	return conf
+}
+
+func (conf SS3LifecycleConfiguration) ToRules() []SBucketLifecycleRule {
+	rules := make([]SBucketLifecycleRule, 0, len(conf.Rule))
+	for i, r := range conf.Rule {
+		rule := SBucketLifecycleRule{
+			Id:      r.ID,
+			Enabled: r.Status == LIFECYCLE_STATUS_ENABLED,
+		}
+		if len(rule.Id) == 0 {
+			rule.Id = strconv.Itoa(i)
+		}
+		if r.Filter != nil {
+			rule.Prefix = r.Filter.Prefix
+		} else if r.Prefix != nil {
+			rule.Prefix = *r.Prefix
+		}
+		if r.Expiration != nil {
+			rule.ExpirationDays = r.Expiration.Days
+		}
+		for _, t := range r.Transition {
+			rule.Transitions = append(rule.Transitions, SBucketLifecycleTransition{Days: t.Days, StorageClass: t.StorageClass})
+		}
+		if r.AbortIncompleteMultipartUpload != nil {
+			rule.AbortIncompleteMultipartUploadDays = r.AbortIncompleteMultipartUpload.DaysAfterInitiation
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func ValidateLifecycleRules(rules []SBucketLifecycleRule) error {
+	ids := map[string]bool{}
+	for i := range rules {
+		if len(rules[i].Id) > 0 {
+			if ids[rules[i].Id] {
+				return errors.Wrapf(ErrDuplicateId, "rule id %s", rules[i].Id)
+			}
+			ids[rules[i].Id] = true
+		}
+		if rules[i].ExpirationDays < 0 || rules[i].AbortIncompleteMultipartUploadDays < 0 {
+			return errors.Wrapf(httperrors.ErrInputParameter, "rule %d: negative days", i)
+		}
+		if rules[i].ExpirationDays == 0 && rules[i].AbortIncompleteMultipartUploadDays == 0 && len(rules[i].Transitions) == 0 {
+			return errors.Wrapf(httperrors.ErrInputParameter, "rule %d: no action", i)
+		}
+		for _, t := range rules[i].Transitions {
+			if t.Days <= 0 || len(t.StorageClass) == 0 {
+				return errors.Wrapf(httperrors.ErrInputParameter, "rule %d: invalid transition", i)
+			}
+			if rules[i].ExpirationDays > 0 && t.Days >= rules[i].ExpirationDays {
+				return errors.Wrapf(httperrors.ErrInputParameter, "rule %d: transition after expiration", i)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyLifecycleRules emulates bucket lifecycle for backends without native support,
+// it only handles expiration and aborting incomplete multipart uploads
+func ApplyLifecycleRules(ctx context.Context, bucket ICloudBucket, rules []SBucketLifecycleRule, now time.Time) (int, error) {
+	expired := 0
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if rule.ExpirationDays > 0 {
+			deadline := now.Add(-time.Duration(rule.ExpirationDays) * 24 * time.Hour)
+			objs, err := GetAllObjects(bucket, rule.Prefix, true)
+			if err != nil {
+				return expired, errors.Wrapf(err, "GetAllObjects %s", rule.Prefix)
+			}
+			for _, obj := range objs {
+				if strings.HasSuffix(obj.GetKey(), "/") || obj.GetLastModified().After(deadline) {
+					continue
+				}
+				err := bucket.DeleteObject(ctx, obj.GetKey())
+				if err != nil {
+					log.Errorf("lifecycle rule %s delete %s/%s fail %s", rule.Id, bucket.GetName(), obj.GetKey(), err)
+					continue
+				}
+				expired += 1
+			}
+		}
+		if rule.AbortIncompleteMultipartUploadDays > 0 {
+			deadline := now.Add(-time.Duration(rule.AbortIncompleteMultipartUploadDays) * 24 * time.Hour)
+			uploads, err := bucket.ListMultipartUploads()
+			if err != nil {
+				if errors.Cause(err) == ErrNotImplemented {
+					continue
+				}
+				return expired, errors.Wrap(err, "ListMultipartUploads")
+			}
+			for _, upload := range uploads {
+				if !strings.HasPrefix(upload.ObjectName, rule.Prefix) || upload.Initiated.After(deadline) {
+					continue
+				}
+				err := bucket.AbortMultipartUpload(ctx, upload.ObjectName, upload.UploadID)
+				if err != nil {
+					log.Errorf("lifecycle rule %s abort upload %s of %s/%s fail %s", rule.Id, upload.UploadID, bucket.GetName(), upload.ObjectName, err)
+				}
+			}
+		}
+	}
+	return expired, nil
+}
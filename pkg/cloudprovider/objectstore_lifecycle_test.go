@@ -0,0 +1,69 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestLifecycleConfiguration(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []SBucketLifecycleRule
+	}{
+		{
+			in: `<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><ID>logs</ID><Filter><Prefix>logs/</Prefix></Filter><Status>Enabled</Status><Expiration><Days>30</Days></Expiration><Transition><Days>7</Days><StorageClass>STANDARD_IA</StorageClass></Transition></Rule></LifecycleConfiguration>`,
+			want: []SBucketLifecycleRule{
+				{
+					Id:             "logs",
+					Prefix:         "logs/",
+					Enabled:        true,
+					ExpirationDays: 30,
+					Transitions:    []SBucketLifecycleTransition{{Days: 7, StorageClass: "STANDARD_IA"}},
+				},
+			},
+		},
+		{
+			in: `<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Rule><Prefix>tmp/</Prefix><Status>Disabled</Status><AbortIncompleteMultipartUpload><DaysAfterInitiation>3</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule></LifecycleConfiguration>`,
+			want: []SBucketLifecycleRule{
+				{
+					Id:                                 "0",
+					Prefix:                             "tmp/",
+					AbortIncompleteMultipartUploadDays: 3,
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		conf := SS3LifecycleConfiguration{}
+		err := xml.Unmarshal([]byte(c.in), &conf)
+		if err != nil {
+			t.Fatalf("unmarshal %s: %s", c.in, err)
+		}
+		got := conf.ToRules()
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("got %#v want %#v", got, c.want)
+		}
+		if err := ValidateLifecycleRules(got); err != nil {
+			t.Fatalf("validate %#v: %s", got, err)
+		}
+		again := NewS3LifecycleConfiguration(got).ToRules()
+		if !reflect.DeepEqual(again, c.want) {
+			t.Fatalf("round trip got %#v want %#v", again, c.want)
+		}
+	}
+}
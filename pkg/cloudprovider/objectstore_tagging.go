@@ -0,0 +1,85 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"encoding/xml"
+	"sort"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	MAX_OBJECT_TAG_COUNT = 10
+	MAX_BUCKET_TAG_COUNT = 50
+
+	MAX_TAG_KEY_LENGTH   = 128
+	MAX_TAG_VALUE_LENGTH = 256
+)
+
+type SS3Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// SS3Tagging is the XML document of the S3 ?tagging sub-resource
+type SS3Tagging struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Tagging"`
+	TagSet  []SS3Tag `xml:"TagSet>Tag"`
+}
+
+func NewS3Tagging(tags map[string]string) SS3Tagging {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tagging := SS3Tagging{TagSet: []SS3Tag{}}
+	for _, k := range keys {
+		tagging.TagSet = append(tagging.TagSet, SS3Tag{Key: k, Value: tags[k]})
+	}
+	return tagging
+}
+
+func (tagging SS3Tagging) ToTags() map[string]string {
+	tags := make(map[string]string, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+// ValidateTags checks tags against the limits of S3 object (maxCount=10) or bucket (maxCount=50) tagging
+func ValidateTags(tags []SS3Tag, maxCount int) error {
+	if len(tags) > maxCount {
+		return errors.Wrapf(httperrors.ErrInputParameter, "too many tags, at most %d", maxCount)
+	}
+	keys := map[string]bool{}
+	for _, tag := range tags {
+		if len(tag.Key) == 0 || len(tag.Key) > MAX_TAG_KEY_LENGTH {
+			return errors.Wrapf(httperrors.ErrInputParameter, "invalid tag key %q", tag.Key)
+		}
+		if len(tag.Value) > MAX_TAG_VALUE_LENGTH {
+			return errors.Wrapf(httperrors.ErrInputParameter, "tag value of %s too long", tag.Key)
+		}
+		if keys[tag.Key] {
+			return errors.Wrapf(httperrors.ErrInputParameter, "duplicate tag key %s", tag.Key)
+		}
+		keys[tag.Key] = true
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestS3Tagging(t *testing.T) {
+	in := `<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><TagSet><Tag><Key>env</Key><Value>prod</Value></Tag><Tag><Key>app</Key><Value>web</Value></Tag></TagSet></Tagging>`
+	tagging := SS3Tagging{}
+	err := xml.Unmarshal([]byte(in), &tagging)
+	if err != nil {
+		t.Fatalf("unmarshal %s: %s", in, err)
+	}
+	want := map[string]string{"env": "prod", "app": "web"}
+	if got := tagging.ToTags(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+
+	out, err := xml.Marshal(NewS3Tagging(want))
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+	wantOut := `<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><TagSet><Tag><Key>app</Key><Value>web</Value></Tag><Tag><Key>env</Key><Value>prod</Value></Tag></TagSet></Tagging>`
+	if string(out) != wantOut {
+		t.Fatalf("got %s want %s", out, wantOut)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"context"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+func ValidateVersioningStatus(status string) error {
+	switch status {
+	case BUCKET_VERSIONING_ENABLED, BUCKET_VERSIONING_SUSPENDED:
+		return nil
+	default:
+		return errors.Wrapf(httperrors.ErrInputParameter, "invalid versioning status %q", status)
+	}
+}
+
+// GetObjectVersion find the version of the key by paging through ListObjectVersions
+func GetObjectVersion(bucket ICloudBucket, key string, versionId string) (*SCloudObjectVersion, error) {
+	keyMarker, versionIdMarker := "", ""
+	for {
+		result, err := bucket.ListObjectVersions(key, keyMarker, versionIdMarker, "", 1000)
+		if err != nil {
+			return nil, errors.Wrap(err, "ListObjectVersions")
+		}
+		for i := range result.Versions {
+			if result.Versions[i].Key == key && result.Versions[i].VersionId == versionId {
+				return &result.Versions[i], nil
+			}
+		}
+		if !result.IsTruncated || len(result.NextKeyMarker) == 0 || result.NextKeyMarker > key {
+			break
+		}
+		keyMarker, versionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	return nil, errors.Wrapf(ErrNotFound, "%s version %s", key, versionId)
+}
+
+// RestoreObjectVersion makes a noncurrent version the latest one by copying its content,
+// the user defined metadata of the old version is not kept
+func RestoreObjectVersion(ctx context.Context, bucket ICloudBucket, key string, versionId string) error {
+	version, err := GetObjectVersion(bucket, key, versionId)
+	if err != nil {
+		return errors.Wrap(err, "GetObjectVersion")
+	}
+	if version.IsDeleteMarker {
+		// removing the delete marker brings back the previous version
+		err = bucket.DeleteObjectVersion(ctx, key, versionId)
+		if err != nil {
+			return errors.Wrap(err, "DeleteObjectVersion")
+		}
+		return nil
+	}
+	if version.IsLatest {
+		return nil
+	}
+	stream, err := bucket.GetObjectVersion(ctx, key, versionId, nil)
+	if err != nil {
+		return errors.Wrap(err, "GetObjectVersion")
+	}
+	defer stream.Close()
+	err = UploadObject(ctx, bucket, key, 0, stream, version.SizeBytes, "", version.StorageClass, nil, false)
+	if err != nil {
+		return errors.Wrap(err, "UploadObject")
+	}
+	return nil
+}
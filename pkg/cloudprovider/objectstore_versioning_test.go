@@ -0,0 +1,204 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudprovider
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// sVersionBucket is a fake bucket listing the versions page by page like
+// S3, the versions are sorted by key and the latest version comes first
+type sVersionBucket struct {
+	ICloudBucket
+
+	versions []SCloudObjectVersion
+	contents map[string]string
+	pageSize int
+
+	listed  int
+	deleted []string
+	put     map[string]string
+}
+
+func newVersionBucket(pageSize int, versions ...SCloudObjectVersion) *sVersionBucket {
+	b := &sVersionBucket{
+		versions: versions,
+		contents: make(map[string]string),
+		pageSize: pageSize,
+		put:      make(map[string]string),
+	}
+	for _, v := range versions {
+		if !v.IsDeleteMarker {
+			b.contents[v.VersionId] = "content of " + v.VersionId
+		}
+	}
+	return b
+}
+
+func (b *sVersionBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (SListObjectVersionsResult, error) {
+	b.listed++
+	result := SListObjectVersionsResult{}
+	start := 0
+	if len(keyMarker) > 0 {
+		for start < len(b.versions) && b.versions[start].Key <= keyMarker {
+			if b.versions[start].Key == keyMarker && len(versionIdMarker) > 0 && b.versions[start].VersionId == versionIdMarker {
+				start++
+				break
+			}
+			start++
+		}
+	}
+	if maxCount > b.pageSize {
+		maxCount = b.pageSize
+	}
+	for i := start; i < len(b.versions); i++ {
+		if !strings.HasPrefix(b.versions[i].Key, prefix) {
+			continue
+		}
+		if len(result.Versions) == maxCount {
+			last := result.Versions[len(result.Versions)-1]
+			result.IsTruncated = true
+			result.NextKeyMarker, result.NextVersionIdMarker = last.Key, last.VersionId
+			break
+		}
+		result.Versions = append(result.Versions, b.versions[i])
+	}
+	return result, nil
+}
+
+func (b *sVersionBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *SGetObjectRange) (io.ReadCloser, error) {
+	content, ok := b.contents[versionId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (b *sVersionBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	b.deleted = append(b.deleted, versionId)
+	return nil
+}
+
+func (b *sVersionBucket) PutObject(ctx context.Context, key string, input io.Reader, sizeBytes int64, cannedAcl TBucketACLType, storageClassStr string, meta http.Header) error {
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	b.put[key] = string(content)
+	return nil
+}
+
+func testObjectVersions() []SCloudObjectVersion {
+	versions := []SCloudObjectVersion{
+		{Key: "a.txt", VersionId: "a1", IsLatest: true},
+		{Key: "doc.txt", VersionId: "v6", IsDeleteMarker: true, IsLatest: true},
+		{Key: "doc.txt", VersionId: "v5"},
+		{Key: "doc.txt", VersionId: "v4"},
+		{Key: "doc.txt", VersionId: "v3"},
+		{Key: "doc.txt", VersionId: "v2"},
+		{Key: "doc.txt", VersionId: "v1"},
+		{Key: "doc.txt.bak", VersionId: "b1", IsLatest: true},
+		{Key: "readme", VersionId: "r2", IsLatest: true},
+		{Key: "readme", VersionId: "r1"},
+	}
+	for i := range versions {
+		if !versions[i].IsDeleteMarker {
+			versions[i].SizeBytes = int64(len("content of " + versions[i].VersionId))
+		}
+	}
+	return versions
+}
+
+func TestGetObjectVersion(t *testing.T) {
+	cases := []struct {
+		name      string
+		key       string
+		versionId string
+		pageSize  int
+		marker    bool
+		listed    int
+		notFound  bool
+	}{
+		{name: "first page", key: "doc.txt", versionId: "v5", pageSize: 1000, listed: 1},
+		{name: "delete marker", key: "doc.txt", versionId: "v6", pageSize: 2, marker: true, listed: 1},
+		{name: "last page", key: "doc.txt", versionId: "v1", pageSize: 2, listed: 3},
+		{name: "page by page", key: "doc.txt", versionId: "v1", pageSize: 1, listed: 6},
+		{name: "version of another key", key: "doc.txt", versionId: "b1", pageSize: 2, listed: 4, notFound: true},
+		{name: "stop after the key", key: "doc.txt", versionId: "v0", pageSize: 1, listed: 7, notFound: true},
+		{name: "no such key", key: "none", versionId: "v1", pageSize: 2, listed: 1, notFound: true},
+	}
+	for _, c := range cases {
+		bucket := newVersionBucket(c.pageSize, testObjectVersions()...)
+		version, err := GetObjectVersion(bucket, c.key, c.versionId)
+		if c.notFound {
+			if errors.Cause(err) != ErrNotFound {
+				t.Errorf("%s: want not found got %v", c.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if version.Key != c.key || version.VersionId != c.versionId || version.IsDeleteMarker != c.marker {
+			t.Errorf("%s: got version %#v", c.name, version)
+		}
+		if bucket.listed != c.listed {
+			t.Errorf("%s: want %d pages listed got %d", c.name, c.listed, bucket.listed)
+		}
+	}
+}
+
+func TestRestoreObjectVersion(t *testing.T) {
+	cases := []struct {
+		name      string
+		key       string
+		versionId string
+		deleted   []string
+		put       string
+		wantErr   bool
+	}{
+		{name: "noncurrent version", key: "doc.txt", versionId: "v1", put: "content of v1"},
+		{name: "noncurrent version on the next page", key: "readme", versionId: "r1", put: "content of r1"},
+		{name: "delete marker", key: "doc.txt", versionId: "v6", deleted: []string{"v6"}},
+		{name: "latest version", key: "readme", versionId: "r2"},
+		{name: "no such version", key: "readme", versionId: "r0", wantErr: true},
+	}
+	for _, c := range cases {
+		bucket := newVersionBucket(4, testObjectVersions()...)
+		err := RestoreObjectVersion(context.Background(), bucket, c.key, c.versionId)
+		if c.wantErr {
+			if errors.Cause(err) != ErrNotFound {
+				t.Errorf("%s: want not found got %v", c.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if strings.Join(bucket.deleted, ",") != strings.Join(c.deleted, ",") {
+			t.Errorf("%s: want versions %v deleted got %v", c.name, c.deleted, bucket.deleted)
+		}
+		put, ok := bucket.put[c.key]
+		if len(c.put) > 0 {
+			if put != c.put {
+				t.Errorf("%s: want %q put got %q", c.name, c.put, put)
+			}
+		} else if ok || len(bucket.put) > 0 {
+			t.Errorf("%s: nothing should be put, got %v", c.name, bucket.put)
+		}
+	}
+}
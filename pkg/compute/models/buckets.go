@@ -1682,6 +1682,410 @@ func (bucket *SBucket) PerformDeletePolicy(
 	return nil, nil
 }
 
+func (bucket *SBucket) getEmulatedLifecycle(userCred mcclient.TokenCredential) ([]cloudprovider.SBucketLifecycleRule, error) {
+	rules := []cloudprovider.SBucketLifecycleRule{}
+	conf := bucket.GetMetadataJson(api.BUCKET_METADATA_LIFECYCLE, userCred)
+	if conf == nil {
+		return rules, nil
+	}
+	err := conf.Unmarshal(&rules)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal %s", api.BUCKET_METADATA_LIFECYCLE)
+	}
+	return rules, nil
+}
+
+func (bucket *SBucket) AllowGetDetailsLifecycle(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+func (bucket *SBucket) GetDetailsLifecycle(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	input jsonutils.JSONObject,
+) (api.BucketLifecycleRules, error) {
+	output := api.BucketLifecycleRules{}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return output, errors.Wrap(err, "GetIBucket")
+	}
+	rules, err := iBucket.GetLifecycle()
+	if err != nil {
+		if errors.Cause(err) != cloudprovider.ErrNotImplemented {
+			return output, httperrors.NewInternalServerError("iBucket.GetLifecycle error %s", err)
+		}
+		rules, err = bucket.getEmulatedLifecycle(userCred)
+		if err != nil {
+			return output, httperrors.NewInternalServerError("getEmulatedLifecycle error %s", err)
+		}
+		output.Emulated = true
+	}
+	for i := range rules {
+		rule := api.BucketLifecycleRule{
+			Id:                                 rules[i].Id,
+			Prefix:                             rules[i].Prefix,
+			Enabled:                            rules[i].Enabled,
+			ExpirationDays:                     rules[i].ExpirationDays,
+			AbortIncompleteMultipartUploadDays: rules[i].AbortIncompleteMultipartUploadDays,
+		}
+		for _, t := range rules[i].Transitions {
+			rule.Transitions = append(rule.Transitions, api.BucketLifecycleTransition{
+				Days:         t.Days,
+				StorageClass: t.StorageClass,
+			})
+		}
+		output.Data = append(output.Data, rule)
+	}
+	return output, nil
+}
+
+func (bucket *SBucket) AllowPerformSetLifecycle(
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketLifecycleRules,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+func (bucket *SBucket) PerformSetLifecycle(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketLifecycleRules,
+) (jsonutils.JSONObject, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+	rules := []cloudprovider.SBucketLifecycleRule{}
+	for i := range input.Data {
+		rule := cloudprovider.SBucketLifecycleRule{
+			Id:                                 input.Data[i].Id,
+			Prefix:                             input.Data[i].Prefix,
+			Enabled:                            input.Data[i].Enabled,
+			ExpirationDays:                     input.Data[i].ExpirationDays,
+			AbortIncompleteMultipartUploadDays: input.Data[i].AbortIncompleteMultipartUploadDays,
+		}
+		if len(rule.Id) == 0 {
+			rule.Id = strconv.Itoa(i)
+		}
+		for _, t := range input.Data[i].Transitions {
+			rule.Transitions = append(rule.Transitions, cloudprovider.SBucketLifecycleTransition{
+				Days:         t.Days,
+				StorageClass: t.StorageClass,
+			})
+		}
+		rules = append(rules, rule)
+	}
+	err = cloudprovider.ValidateLifecycleRules(rules)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("%v", err)
+	}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIBucket")
+	}
+	if len(rules) == 0 {
+		err = iBucket.DeleteLifecycle()
+	} else {
+		err = iBucket.SetLifecycle(rules)
+	}
+	if err != nil {
+		if errors.Cause(err) != cloudprovider.ErrNotImplemented {
+			return nil, httperrors.NewInternalServerError("iBucket.SetLifecycle error %s", err)
+		}
+		// 存储不支持生命周期，由region定时删除过期对象，无法模拟存储类型转换
+		for i := range rules {
+			if len(rules[i].Transitions) > 0 {
+				return nil, httperrors.NewNotSupportedError("storage class transition is not supported by %s", bucket.GetProviderName())
+			}
+		}
+		if len(rules) == 0 {
+			err = bucket.RemoveMetadata(ctx, api.BUCKET_METADATA_LIFECYCLE, userCred)
+		} else {
+			err = bucket.SetMetadata(ctx, api.BUCKET_METADATA_LIFECYCLE, jsonutils.Marshal(rules), userCred)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "save emulated lifecycle")
+		}
+	}
+	db.OpsLog.LogEvent(bucket, db.ACT_SET_LIFECYCLE, rules, userCred)
+	logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_SET_LIFECYCLE, rules, userCred, true)
+	return nil, nil
+}
+
+func (bucket *SBucket) AllowPerformDeleteLifecycle(
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+func (bucket *SBucket) PerformDeleteLifecycle(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject,
+) (jsonutils.JSONObject, error) {
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIBucket")
+	}
+	err = iBucket.DeleteLifecycle()
+	if err != nil {
+		if errors.Cause(err) != cloudprovider.ErrNotImplemented {
+			return nil, httperrors.NewInternalServerError("iBucket.DeleteLifecycle error %s", err)
+		}
+		err = bucket.RemoveMetadata(ctx, api.BUCKET_METADATA_LIFECYCLE, userCred)
+		if err != nil {
+			return nil, errors.Wrap(err, "RemoveMetadata")
+		}
+	}
+	db.OpsLog.LogEvent(bucket, db.ACT_DELETE_LIFECYCLE, nil, userCred)
+	logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_DELETE_LIFECYCLE, nil, userCred, true)
+	return nil, nil
+}
+
+func (bucket *SBucket) AllowGetDetailsVersioning(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+func (bucket *SBucket) GetDetailsVersioning(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	input jsonutils.JSONObject,
+) (api.BucketGetVersioningOutput, error) {
+	output := api.BucketGetVersioningOutput{}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return output, errors.Wrap(err, "GetIBucket")
+	}
+	output.Status, err = iBucket.GetVersioning()
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotImplemented {
+			return output, httperrors.NewNotSupportedError("versioning is not supported by %s", bucket.GetProviderName())
+		}
+		return output, httperrors.NewInternalServerError("iBucket.GetVersioning error %s", err)
+	}
+	return output, nil
+}
+
+func (bucket *SBucket) AllowPerformSetVersioning(
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketSetVersioningInput,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+// 开启或暂停多版本
+func (bucket *SBucket) PerformSetVersioning(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketSetVersioningInput,
+) (jsonutils.JSONObject, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("%v", err)
+	}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIBucket")
+	}
+	err = iBucket.SetVersioning(input.Status)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotImplemented {
+			return nil, httperrors.NewNotSupportedError("versioning is not supported by %s", bucket.GetProviderName())
+		}
+		return nil, httperrors.NewInternalServerError("iBucket.SetVersioning error %s", err)
+	}
+	db.OpsLog.LogEvent(bucket, db.ACT_SET_VERSIONING, input, userCred)
+	logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_SET_VERSIONING, input, userCred, true)
+	return nil, nil
+}
+
+func (bucket *SBucket) AllowGetDetailsObjectVersions(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	input api.BucketGetObjectVersionsInput,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+// 获取bucket的对象版本列表
+func (bucket *SBucket) GetDetailsObjectVersions(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	input api.BucketGetObjectVersionsInput,
+) (api.BucketGetObjectVersionsOutput, error) {
+	output := api.BucketGetObjectVersionsOutput{}
+	if len(bucket.ExternalId) == 0 {
+		return output, httperrors.NewInvalidStatusError("no external bucket")
+	}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return output, errors.Wrap(err, "GetIBucket")
+	}
+	limit := 0
+	if input.Limit != nil {
+		limit = *input.Limit
+	}
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 1000 {
+		limit = 1000
+	}
+	result, err := iBucket.ListObjectVersions(input.Prefix, input.KeyMarker, input.VersionIdMarker, "", limit)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotImplemented {
+			return output, httperrors.NewNotSupportedError("versioning is not supported by %s", bucket.GetProviderName())
+		}
+		return output, httperrors.NewInternalServerError("fail to list object versions: %s", err)
+	}
+	output.Data = result.Versions
+	if result.IsTruncated {
+		output.NextKeyMarker = result.NextKeyMarker
+		output.NextVersionIdMarker = result.NextVersionIdMarker
+	}
+	return output, nil
+}
+
+func (bucket *SBucket) AllowPerformDeleteObjectVersion(
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketObjectVersionInput,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+// 永久删除对象的指定版本
+func (bucket *SBucket) PerformDeleteObjectVersion(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketObjectVersionInput,
+) (jsonutils.JSONObject, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIBucket")
+	}
+	err = iBucket.DeleteObjectVersion(ctx, input.Key, input.VersionId)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotImplemented {
+			return nil, httperrors.NewNotSupportedError("versioning is not supported by %s", bucket.GetProviderName())
+		}
+		return nil, httperrors.NewInternalServerError("iBucket.DeleteObjectVersion error %s", err)
+	}
+	db.OpsLog.LogEvent(bucket, db.ACT_DELETE_OBJECT_VERSION, input, userCred)
+	logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_DELETE_OBJECT_VERSION, input, userCred, true)
+	bucket.syncWithCloudBucket(ctx, userCred, iBucket, nil, true)
+	return nil, nil
+}
+
+func (bucket *SBucket) AllowPerformRestoreObjectVersion(
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketObjectVersionInput,
+) bool {
+	return bucket.IsOwner(userCred)
+}
+
+// 将对象的历史版本恢复为最新版本
+func (bucket *SBucket) PerformRestoreObjectVersion(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.BucketObjectVersionInput,
+) (jsonutils.JSONObject, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+	iBucket, err := bucket.GetIBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIBucket")
+	}
+	err = cloudprovider.RestoreObjectVersion(ctx, iBucket, input.Key, input.VersionId)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotFound {
+			return nil, httperrors.NewResourceNotFoundError2("object version", input.VersionId)
+		}
+		if errors.Cause(err) == cloudprovider.ErrNotImplemented {
+			return nil, httperrors.NewNotSupportedError("versioning is not supported by %s", bucket.GetProviderName())
+		}
+		return nil, httperrors.NewInternalServerError("RestoreObjectVersion error %s", err)
+	}
+	db.OpsLog.LogEvent(bucket, db.ACT_RESTORE_OBJECT_VERSION, input, userCred)
+	logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_RESTORE_OBJECT_VERSION, input, userCred, true)
+	bucket.syncWithCloudBucket(ctx, userCred, iBucket, nil, true)
+	return nil, nil
+}
+
+// ApplyEmulatedLifecycles 定时执行保存在metadata中的生命周期规则
+func (manager *SBucketManager) ApplyEmulatedLifecycles(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := db.Metadata.Query("obj_id").Equals("obj_type", manager.Keyword()).Equals("key", api.BUCKET_METADATA_LIFECYCLE)
+	rows, err := q.Rows()
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Errorf("query bucket lifecycle metadata fail %s", err)
+		}
+		return
+	}
+	bucketIds := []string{}
+	for rows.Next() {
+		var bucketId string
+		err = rows.Scan(&bucketId)
+		if err != nil {
+			log.Errorf("scan bucket id fail %s", err)
+			continue
+		}
+		bucketIds = append(bucketIds, bucketId)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, bucketId := range bucketIds {
+		obj, err := manager.FetchById(bucketId)
+		if err != nil {
+			log.Errorf("fetch bucket %s fail %s", bucketId, err)
+			continue
+		}
+		bucket := obj.(*SBucket)
+		rules, err := bucket.getEmulatedLifecycle(userCred)
+		if err != nil || len(rules) == 0 {
+			continue
+		}
+		iBucket, err := bucket.GetIBucket()
+		if err != nil {
+			log.Errorf("bucket %s GetIBucket fail %s", bucket.Name, err)
+			continue
+		}
+		expired, err := cloudprovider.ApplyLifecycleRules(ctx, iBucket, rules, now)
+		if err != nil {
+			log.Errorf("bucket %s ApplyLifecycleRules fail %s", bucket.Name, err)
+		}
+		if expired > 0 {
+			notes := jsonutils.Marshal(map[string]int{"expired": expired})
+			db.OpsLog.LogEvent(bucket, db.ACT_EXPIRE_OBJECTS, notes, userCred)
+			logclient.AddActionLogWithContext(ctx, bucket, logclient.ACT_EXPIRE_OBJECTS, notes, userCred, true)
+		}
+	}
+}
+
 func (manager *SBucketManager) usageQByCloudEnv(q *sqlchemy.SQuery, providers []string, brands []string, cloudEnv string) *sqlchemy.SQuery {
 	return CloudProviderFilter(q, q.Field("manager_id"), providers, brands, cloudEnv)
 }
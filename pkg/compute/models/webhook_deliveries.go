@@ -0,0 +1,462 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	webhookRetryBaseInterval = 30 * time.Second
+	webhookRetryMaxInterval  = time.Hour
+
+	// a delivery being sent is leased for the duration, it is sent again by
+	// the retry job if the lease expires, e.g. the service restarted
+	webhookDeliveryLease = time.Minute
+)
+
+var (
+	webhookDeliveryWorkerMan *appsrv.SWorkerManager
+)
+
+// SWebhookDeliveryManager keeps the delivery log of the webhooks, a delivery
+// is an event posted to a webhook with the attempts and the result
+type SWebhookDeliveryManager struct {
+	db.SStatusStandaloneResourceBaseManager
+}
+
+var WebhookDeliveryManager *SWebhookDeliveryManager
+
+func init() {
+	WebhookDeliveryManager = &SWebhookDeliveryManager{
+		SStatusStandaloneResourceBaseManager: db.NewStatusStandaloneResourceBaseManager(
+			SWebhookDelivery{},
+			"webhook_deliveries_tbl",
+			"webhook_delivery",
+			"webhook_deliveries",
+		),
+	}
+	WebhookDeliveryManager.SetVirtualObject(WebhookDeliveryManager)
+}
+
+type SWebhookDelivery struct {
+	db.SStatusStandaloneResourceBase
+
+	WebhookId string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user"`
+	EventId   int64  `nullable:"false" list:"user"`
+	ObjType   string `width:"40" charset:"ascii" nullable:"false" list:"user"`
+	ObjId     string `width:"128" charset:"ascii" nullable:"false" index:"true" list:"user"`
+	Action    string `width:"32" charset:"utf8" nullable:"false" list:"user"`
+	// Payload is the body posted, the retries send the same body
+	Payload string `charset:"utf8" nullable:"false" get:"user"`
+
+	Attempts      int       `nullable:"false" default:"0" list:"user"`
+	NextAttemptAt time.Time `nullable:"true" index:"true" list:"user"`
+	DeliveredAt   time.Time `nullable:"true" list:"user"`
+	ResponseCode  int       `nullable:"false" default:"0" list:"user"`
+	Reason        string    `width:"1024" charset:"utf8" nullable:"true" list:"user"`
+}
+
+// InitWebhookDelivery starts sending the opslog events to the webhooks
+func InitWebhookDelivery() {
+	webhookDeliveryWorkerMan = appsrv.NewWorkerManager("webhookDeliveryWorkerManager", 8, 10240, true)
+	db.RegisterOpsLogListener(func(event *db.SOpsLog) {
+		webhookDeliveryWorkerMan.Run(&webhookTask{
+			name: fmt.Sprintf("event %d", event.Id),
+			f: func(ctx context.Context) {
+				WebhookManager.onOpsLog(event)
+			},
+		}, nil, nil)
+	})
+}
+
+type webhookTask struct {
+	name string
+	f    func(ctx context.Context)
+}
+
+func (t *webhookTask) Run() {
+	t.f(context.Background())
+}
+
+func (t *webhookTask) Dump() string {
+	return t.name
+}
+
+func (manager *SWebhookDeliveryManager) ResourceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeProject
+}
+
+func (manager *SWebhookDeliveryManager) NamespaceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeProject
+}
+
+func (manager *SWebhookDeliveryManager) FilterByOwner(q *sqlchemy.SQuery, owner mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	if owner != nil {
+		switch scope {
+		case rbacutils.ScopeProject, rbacutils.ScopeDomain:
+			webhooks := WebhookManager.Query("id")
+			if scope == rbacutils.ScopeProject {
+				webhooks = webhooks.Equals("tenant_id", owner.GetProjectId())
+			} else {
+				webhooks = webhooks.Equals("domain_id", owner.GetProjectDomainId())
+			}
+			q = q.In("webhook_id", webhooks.SubQuery())
+		}
+	}
+	return q
+}
+
+func (manager *SWebhookDeliveryManager) FetchOwnerId(ctx context.Context, data jsonutils.JSONObject) (mcclient.IIdentityProvider, error) {
+	return db.FetchProjectInfo(ctx, data)
+}
+
+func (delivery *SWebhookDelivery) GetOwnerId() mcclient.IIdentityProvider {
+	webhook, err := delivery.GetWebhook()
+	if err != nil {
+		return nil
+	}
+	return webhook.GetOwnerId()
+}
+
+func (manager *SWebhookDeliveryManager) AllowCreateItem(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return false
+}
+
+func (delivery *SWebhookDelivery) AllowUpdateItem(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	return false
+}
+
+func (manager *SWebhookDeliveryManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.WebhookDeliveryListInput,
+) (*sqlchemy.SQuery, error) {
+	q, err := manager.SStatusStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StatusStandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.ListItemFilter")
+	}
+	if len(query.Webhook) > 0 {
+		webhook, err := WebhookManager.FetchByIdOrName(userCred, query.Webhook)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(WebhookManager.Keyword(), query.Webhook)
+			}
+			return nil, errors.Wrapf(err, "fetch webhook %s", query.Webhook)
+		}
+		q = q.Equals("webhook_id", webhook.GetId())
+	}
+	if len(query.ObjType) > 0 {
+		q = q.Equals("obj_type", query.ObjType)
+	}
+	if len(query.ObjId) > 0 {
+		q = q.Equals("obj_id", query.ObjId)
+	}
+	if len(query.Action) > 0 {
+		q = q.Equals("action", query.Action)
+	}
+	return q, nil
+}
+
+func (manager *SWebhookDeliveryManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.WebhookDeliveryListInput,
+) (*sqlchemy.SQuery, error) {
+	return manager.SStatusStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.StatusStandaloneResourceListInput)
+}
+
+func (manager *SWebhookDeliveryManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.WebhookDeliveryDetails {
+	rows := make([]api.WebhookDeliveryDetails, len(objs))
+	stdRows := manager.SStatusStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	webhookIds := make([]string, len(objs))
+	for i := range rows {
+		rows[i].StatusStandaloneResourceDetails = stdRows[i]
+		webhookIds[i] = objs[i].(*SWebhookDelivery).WebhookId
+	}
+	webhooks := make(map[string]SWebhook)
+	if err := db.FetchStandaloneObjectsByIds(WebhookManager, webhookIds, &webhooks); err != nil {
+		log.Errorf("FetchStandaloneObjectsByIds %s: %v", WebhookManager.KeywordPlural(), err)
+		return rows
+	}
+	for i := range rows {
+		if webhook, ok := webhooks[webhookIds[i]]; ok {
+			rows[i].Webhook = webhook.Name
+		}
+	}
+	return rows
+}
+
+func (delivery *SWebhookDelivery) GetWebhook() (*SWebhook, error) {
+	obj, err := WebhookManager.FetchById(delivery.WebhookId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch webhook %s", delivery.WebhookId)
+	}
+	return obj.(*SWebhook), nil
+}
+
+func (manager *SWebhookDeliveryManager) createDelivery(ctx context.Context, webhook *SWebhook, event *db.SOpsLog) (*SWebhookDelivery, error) {
+	delivery := &SWebhookDelivery{
+		WebhookId:     webhook.Id,
+		EventId:       event.Id,
+		ObjType:       event.ObjType,
+		ObjId:         event.ObjId,
+		Action:        event.Action,
+		NextAttemptAt: time.Now().Add(webhookDeliveryLease),
+	}
+	delivery.Id = db.DefaultUUIDGenerator()
+	delivery.Name = fmt.Sprintf("%s.%s", event.ObjType, event.Action)
+	delivery.Status = api.WEBHOOK_DELIVERY_STATUS_PENDING
+	delivery.Payload = jsonutils.Marshal(api.WebhookEvent{
+		WebhookId:      webhook.Id,
+		DeliveryId:     delivery.Id,
+		EventId:        event.Id,
+		OpsTime:        event.OpsTime,
+		ObjType:        event.ObjType,
+		ObjId:          event.ObjId,
+		ObjName:        event.ObjName,
+		Action:         event.Action,
+		Notes:          event.Notes,
+		OwnerProjectId: event.OwnerProjectId,
+		OwnerDomainId:  event.OwnerDomainId,
+		UserId:         event.UserId,
+		User:           event.User,
+		ProjectId:      event.ProjectId,
+		Project:        event.Project,
+	}).String()
+	delivery.SetModelManager(manager, delivery)
+	if err := manager.TableSpec().Insert(ctx, delivery); err != nil {
+		return nil, errors.Wrap(err, "insert")
+	}
+	return delivery, nil
+}
+
+// SignWebhookPayload returns the value of the signature header of the payload,
+// the timestamp is signed as well so that a captured delivery can not be replayed later
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", timestamp)))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryInterval backs off exponentially from the base interval
+func webhookRetryInterval(attempts int) time.Duration {
+	interval := webhookRetryBaseInterval
+	for i := 1; i < attempts; i++ {
+		interval *= 2
+		if interval >= webhookRetryMaxInterval {
+			return webhookRetryMaxInterval
+		}
+	}
+	return interval
+}
+
+// webhookDialControl checks the address actually dialed, which stops a host
+// passing the validation from resolving to an internal address later
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "split %s", address)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isWebhookAddressAllowed(ip, options.Options.WebhookAllowedCidrs) {
+		return errors.Errorf("address %s is not allowed", host)
+	}
+	return nil
+}
+
+func getWebhookClient() *http.Client {
+	timeout := time.Duration(options.Options.WebhookDeliveryTimeoutSeconds) * time.Second
+	tr := httputils.GetTransport(false)
+	// a proxy would dial the destination on behalf of the webhook
+	tr.Proxy = nil
+	tr.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 5 * time.Second,
+		Control:   webhookDialControl,
+	}).DialContext
+	return &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+	}
+}
+
+func (delivery *SWebhookDelivery) post(ctx context.Context, webhook *SWebhook) (int, error) {
+	secret, err := webhook.getSecret()
+	if err != nil {
+		return 0, errors.Wrap(err, "get secret")
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(api.WEBHOOK_HEADER_ID, webhook.Id)
+	header.Set(api.WEBHOOK_HEADER_DELIVERY, delivery.Id)
+	header.Set(api.WEBHOOK_HEADER_EVENT, delivery.Name)
+	timestamp := time.Now().Unix()
+	header.Set(api.WEBHOOK_HEADER_TIMESTAMP, fmt.Sprintf("%d", timestamp))
+	if len(secret) > 0 {
+		header.Set(api.WEBHOOK_HEADER_SIGNATURE, SignWebhookPayload(secret, timestamp, []byte(delivery.Payload)))
+	}
+	resp, err := httputils.Request(getWebhookClient(), ctx, httputils.POST, webhook.Url, header, strings.NewReader(delivery.Payload), false)
+	if err != nil {
+		return 0, err
+	}
+	defer httputils.CloseResponse(resp)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the response body is never kept, the deliveries are readable by the owner of the webhook
+		return resp.StatusCode, errors.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliver posts the payload to the webhook and records the result, the
+// failed delivery is scheduled to retry with backoff until max retries
+func (delivery *SWebhookDelivery) deliver(ctx context.Context) {
+	webhook, err := delivery.GetWebhook()
+	if err != nil {
+		delivery.setResult(api.WEBHOOK_DELIVERY_STATUS_FAILED, 0, err.Error(), time.Time{})
+		return
+	}
+	_, err = db.Update(delivery, func() error {
+		delivery.Attempts += 1
+		delivery.NextAttemptAt = time.Now().Add(webhookDeliveryLease)
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update attempts of webhook delivery %s: %v", delivery.Id, err)
+		return
+	}
+	code, err := delivery.post(ctx, webhook)
+	if err == nil {
+		delivery.setResult(api.WEBHOOK_DELIVERY_STATUS_SUCCEEDED, code, "", time.Time{})
+		return
+	}
+	if delivery.Attempts > webhook.MaxRetries || delivery.Action == api.WEBHOOK_ACTION_PING {
+		delivery.setResult(api.WEBHOOK_DELIVERY_STATUS_FAILED, code, err.Error(), time.Time{})
+		return
+	}
+	next := time.Now().Add(webhookRetryInterval(delivery.Attempts))
+	delivery.setResult(api.WEBHOOK_DELIVERY_STATUS_PENDING, code, err.Error(), next)
+}
+
+func (delivery *SWebhookDelivery) setResult(status string, code int, reason string, next time.Time) {
+	_, err := db.Update(delivery, func() error {
+		delivery.Status = status
+		delivery.ResponseCode = code
+		delivery.Reason = reason
+		delivery.NextAttemptAt = next
+		if status == api.WEBHOOK_DELIVERY_STATUS_SUCCEEDED {
+			delivery.DeliveredAt = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("update result of webhook delivery %s: %v", delivery.Id, err)
+	}
+}
+
+func (delivery *SWebhookDelivery) startDeliver() {
+	webhookDeliveryWorkerMan.Run(&webhookTask{
+		name: fmt.Sprintf("delivery %s", delivery.Id),
+		f:    delivery.deliver,
+	}, nil, nil)
+}
+
+func (delivery *SWebhookDelivery) AllowPerformRedeliver(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsProjectAllowPerform(userCred, delivery, "redeliver")
+}
+
+// PerformRedeliver sends the delivery again, e.g. after the receiver is fixed
+func (delivery *SWebhookDelivery) PerformRedeliver(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if delivery.Status == api.WEBHOOK_DELIVERY_STATUS_PENDING {
+		return nil, httperrors.NewInvalidStatusError("delivery is pending")
+	}
+	_, err := db.Update(delivery, func() error {
+		delivery.Status = api.WEBHOOK_DELIVERY_STATUS_PENDING
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now().Add(webhookDeliveryLease)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "db.Update")
+	}
+	delivery.startDeliver()
+	return nil, nil
+}
+
+// RetryDeliveries sends the pending deliveries whose next attempts are due
+// and cleans the delivery logs out of date
+func (manager *SWebhookDeliveryManager) RetryDeliveries(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := manager.Query().Equals("status", api.WEBHOOK_DELIVERY_STATUS_PENDING).LE("next_attempt_at", time.Now())
+	deliveries := make([]SWebhookDelivery, 0)
+	if err := db.FetchModelObjects(manager, q, &deliveries); err != nil {
+		log.Errorf("fetch pending webhook deliveries: %v", err)
+		return
+	}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		_, err := db.Update(delivery, func() error {
+			delivery.NextAttemptAt = time.Now().Add(webhookDeliveryLease)
+			return nil
+		})
+		if err != nil {
+			log.Errorf("lease webhook delivery %s: %v", delivery.Id, err)
+			continue
+		}
+		delivery.startDeliver()
+	}
+
+	// the expired deliveries are deleted without opslog, or the opslog events
+	// would be delivered to the webhooks again
+	expired := time.Now().AddDate(0, 0, -options.Options.WebhookDeliveryKeepDays)
+	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE status != ? AND created_at < ?", manager.TableSpec().Name())
+	if _, err := sqlchemy.GetDB().Exec(sqlStr, api.WEBHOOK_DELIVERY_STATUS_PENDING, expired); err != nil {
+		log.Errorf("delete expired webhook deliveries: %v", err)
+	}
+}
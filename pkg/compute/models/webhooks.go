@@ -0,0 +1,471 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	webhookDefaultMaxRetries = 5
+	webhookMaxRetriesLimit   = 20
+
+	// the enabled webhooks are reloaded at the interval to match the events
+	webhookCacheExpire = 30 * time.Second
+)
+
+// SWebhookManager manages the webhooks, the events of the opslog matching
+// the resource types and actions of a webhook are posted to its url
+type SWebhookManager struct {
+	db.SVirtualResourceBaseManager
+	db.SEnabledResourceBaseManager
+
+	cacheLock    sync.Mutex
+	cache        []SWebhook
+	cacheExpires time.Time
+}
+
+var WebhookManager *SWebhookManager
+
+func init() {
+	WebhookManager = &SWebhookManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SWebhook{},
+			"webhooks_tbl",
+			"webhook",
+			"webhooks",
+		),
+	}
+	WebhookManager.SetVirtualObject(WebhookManager)
+}
+
+type SWebhook struct {
+	db.SVirtualResourceBase
+	db.SEnabledResourceBase
+
+	Url string `width:"512" charset:"ascii" nullable:"false" list:"user" create:"required" update:"user"`
+	// Secret is encrypted with the id of the webhook
+	Secret string `width:"256" charset:"ascii" nullable:"true" create:"optional"`
+
+	// ResourceTypes are the keywords of the resources, empty for all resources
+	ResourceTypes jsonutils.JSONObject `nullable:"true" list:"user" create:"optional" update:"user"`
+	// Actions are the opslog actions, empty for all actions
+	Actions jsonutils.JSONObject `nullable:"true" list:"user" create:"optional" update:"user"`
+	// EventScope is the scope of the resources whose events are sent,
+	// the resources of the project or the domain of the webhook or all
+	EventScope string `width:"16" charset:"ascii" nullable:"false" default:"project" list:"user" create:"optional"`
+	MaxRetries int    `nullable:"false" default:"5" list:"user" create:"optional" update:"user"`
+}
+
+func (manager *SWebhookManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.WebhookListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SVirtualResourceBaseManager.ListItemFilter(ctx, q, userCred, query.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, query.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	if len(query.ResourceType) > 0 {
+		q = q.Filter(sqlchemy.OR(
+			sqlchemy.IsNullOrEmpty(q.Field("resource_types")),
+			sqlchemy.Equals(q.Field("resource_types"), "[]"),
+			sqlchemy.Contains(q.Field("resource_types"), fmt.Sprintf("%q", query.ResourceType)),
+		))
+	}
+	if len(query.EventScope) > 0 {
+		q = q.Equals("event_scope", query.EventScope)
+	}
+	return q, nil
+}
+
+func (manager *SWebhookManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.WebhookListInput,
+) (*sqlchemy.SQuery, error) {
+	q, err := manager.SVirtualResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (manager *SWebhookManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	return manager.SVirtualResourceBaseManager.QueryDistinctExtraField(q, field)
+}
+
+func (manager *SWebhookManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.WebhookDetails {
+	rows := make([]api.WebhookDetails, len(objs))
+	virtRows := manager.SVirtualResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	for i := range rows {
+		rows[i].VirtualResourceDetails = virtRows[i]
+		rows[i].HasSecret = len(objs[i].(*SWebhook).Secret) > 0
+	}
+	return rows
+}
+
+// the destinations the webhooks are not allowed to post to unless allowed
+// explicitly by WebhookAllowedCidrs, besides the loopback, link-local,
+// multicast and unspecified addresses
+var webhookPrivateCidrs = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+func ipInCidrs(ip net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Errorf("invalid cidr %s: %v", cidr, err)
+			continue
+		}
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWebhookAddressAllowed keeps the webhooks away from the internal
+// addresses, e.g. the metadata service 169.254.169.254, unless the address
+// is in the CIDRs allowed by the operator
+func isWebhookAddressAllowed(ip net.IP, allowedCidrs []string) bool {
+	if ipInCidrs(ip, allowedCidrs) {
+		return true
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	return !ipInCidrs(ip, webhookPrivateCidrs)
+}
+
+func validateWebhookUrl(urlStr string) error {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return httperrors.NewInputParameterError("invalid url %q: %v", urlStr, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return httperrors.NewInputParameterError("unsupported url scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if len(host) == 0 {
+		return httperrors.NewInputParameterError("url %q without host", urlStr)
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if strings.EqualFold(host, "localhost") {
+		return httperrors.NewInputParameterError("url host %s is not allowed", host)
+	} else {
+		// the host resolved later is checked again when the delivery dials
+		ips, _ = net.LookupIP(host)
+	}
+	for _, ip := range ips {
+		if !isWebhookAddressAllowed(ip, options.Options.WebhookAllowedCidrs) {
+			return httperrors.NewInputParameterError("url host %s resolves to address %s not allowed", host, ip)
+		}
+	}
+	return nil
+}
+
+func validateWebhookMaxRetries(maxRetries int) error {
+	if maxRetries < 0 || maxRetries > webhookMaxRetriesLimit {
+		return httperrors.NewOutOfRangeError("max_retries should be between 0 and %d", webhookMaxRetriesLimit)
+	}
+	return nil
+}
+
+func (manager *SWebhookManager) ValidateCreateData(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider,
+	query jsonutils.JSONObject,
+	input api.WebhookCreateInput,
+) (api.WebhookCreateInput, error) {
+	var err error
+	input.VirtualResourceCreateInput, err = manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.VirtualResourceCreateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SVirtualResourceBaseManager.ValidateCreateData")
+	}
+	if err := validateWebhookUrl(input.Url); err != nil {
+		return input, err
+	}
+	if len(input.EventScope) == 0 {
+		input.EventScope = string(rbacutils.ScopeProject)
+	}
+	scope := rbacutils.TRbacScope(input.EventScope)
+	if !utils.IsInStringArray(input.EventScope, []string{string(rbacutils.ScopeProject), string(rbacutils.ScopeDomain), string(rbacutils.ScopeSystem)}) {
+		return input, httperrors.NewInputParameterError("invalid event_scope %q", input.EventScope)
+	}
+	allowScope := policy.PolicyManager.AllowScope(userCred, api.SERVICE_TYPE, manager.KeywordPlural(), policy.PolicyActionCreate)
+	if scope.HigherThan(allowScope) {
+		return input, httperrors.NewForbiddenError("not enough privilege to subscribe the events of %s scope", scope)
+	}
+	if input.MaxRetries == nil {
+		maxRetries := webhookDefaultMaxRetries
+		input.MaxRetries = &maxRetries
+	}
+	if err := validateWebhookMaxRetries(*input.MaxRetries); err != nil {
+		return input, err
+	}
+	if input.Enabled == nil {
+		input.SetEnabled()
+	}
+	input.Status = api.WEBHOOK_STATUS_READY
+	return input, nil
+}
+
+func (webhook *SWebhook) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	webhook.SVirtualResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
+	if len(webhook.Secret) > 0 {
+		if err := webhook.saveSecret(webhook.Secret); err != nil {
+			log.Errorf("save secret of webhook %s: %v", webhook.Name, err)
+		}
+	}
+	WebhookManager.invalidateCache()
+}
+
+func (webhook *SWebhook) ValidateUpdateData(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.WebhookUpdateInput,
+) (api.WebhookUpdateInput, error) {
+	var err error
+	input.VirtualResourceBaseUpdateInput, err = webhook.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, input.VirtualResourceBaseUpdateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SVirtualResourceBase.ValidateUpdateData")
+	}
+	if len(input.Url) > 0 {
+		if err := validateWebhookUrl(input.Url); err != nil {
+			return input, err
+		}
+	}
+	if input.MaxRetries != nil {
+		if err := validateWebhookMaxRetries(*input.MaxRetries); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
+
+func (webhook *SWebhook) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	webhook.SVirtualResourceBase.PostUpdate(ctx, userCred, query, data)
+	if data.Contains("secret") {
+		secret, _ := data.GetString("secret")
+		if err := webhook.saveSecret(secret); err != nil {
+			log.Errorf("save secret of webhook %s: %v", webhook.Name, err)
+		}
+	}
+	WebhookManager.invalidateCache()
+}
+
+func (webhook *SWebhook) PostDelete(ctx context.Context, userCred mcclient.TokenCredential) {
+	webhook.SVirtualResourceBase.PostDelete(ctx, userCred)
+	WebhookManager.invalidateCache()
+}
+
+func (webhook *SWebhook) saveSecret(secret string) error {
+	var err error
+	if len(secret) > 0 {
+		secret, err = utils.EncryptAESBase64(webhook.Id, secret)
+		if err != nil {
+			return errors.Wrap(err, "EncryptAESBase64")
+		}
+	}
+	_, err = db.Update(webhook, func() error {
+		webhook.Secret = secret
+		return nil
+	})
+	return err
+}
+
+func (webhook *SWebhook) getSecret() (string, error) {
+	if len(webhook.Secret) == 0 {
+		return "", nil
+	}
+	return utils.DescryptAESBase64(webhook.Id, webhook.Secret)
+}
+
+func (webhook *SWebhook) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) bool {
+	return db.IsProjectAllowPerform(userCred, webhook, "enable")
+}
+
+func (webhook *SWebhook) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(webhook, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	WebhookManager.invalidateCache()
+	return nil, nil
+}
+
+func (webhook *SWebhook) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) bool {
+	return db.IsProjectAllowPerform(userCred, webhook, "disable")
+}
+
+func (webhook *SWebhook) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(webhook, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	WebhookManager.invalidateCache()
+	return nil, nil
+}
+
+func (webhook *SWebhook) AllowPerformTest(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.WebhookTestInput) bool {
+	return db.IsProjectAllowPerform(userCred, webhook, "test")
+}
+
+// PerformTest sends a ping event to the webhook, the result is in the delivery returned
+func (webhook *SWebhook) PerformTest(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.WebhookTestInput) (jsonutils.JSONObject, error) {
+	event := &db.SOpsLog{
+		ObjType:        webhook.Keyword(),
+		ObjId:          webhook.Id,
+		ObjName:        webhook.Name,
+		Action:         api.WEBHOOK_ACTION_PING,
+		OpsTime:        time.Now().UTC(),
+		OwnerProjectId: webhook.ProjectId,
+		OwnerDomainId:  webhook.DomainId,
+		ProjectId:      userCred.GetProjectId(),
+		Project:        userCred.GetProjectName(),
+		UserId:         userCred.GetUserId(),
+		User:           userCred.GetUserName(),
+	}
+	delivery, err := WebhookDeliveryManager.createDelivery(ctx, webhook, event)
+	if err != nil {
+		return nil, errors.Wrap(err, "createDelivery")
+	}
+	delivery.deliver(ctx)
+	return jsonutils.Marshal(delivery), nil
+}
+
+func (webhook *SWebhook) getStrings(obj jsonutils.JSONObject) []string {
+	ret := []string{}
+	if obj != nil {
+		obj.Unmarshal(&ret)
+	}
+	return ret
+}
+
+// MatchEvent checks the resource type, the action and the owner of the event,
+// the events of the resources without owner only match the system webhooks
+func (webhook *SWebhook) MatchEvent(event *db.SOpsLog) bool {
+	if resTypes := webhook.getStrings(webhook.ResourceTypes); len(resTypes) > 0 && !utils.IsInStringArray(event.ObjType, resTypes) {
+		return false
+	}
+	if actions := webhook.getStrings(webhook.Actions); len(actions) > 0 && !utils.IsInStringArray(event.Action, actions) {
+		return false
+	}
+	switch rbacutils.TRbacScope(webhook.EventScope) {
+	case rbacutils.ScopeSystem:
+		return true
+	case rbacutils.ScopeDomain:
+		return len(event.OwnerProjectId) > 0 && event.OwnerDomainId == webhook.DomainId
+	default:
+		return len(event.OwnerProjectId) > 0 && event.OwnerProjectId == webhook.ProjectId
+	}
+}
+
+func (manager *SWebhookManager) invalidateCache() {
+	manager.cacheLock.Lock()
+	defer manager.cacheLock.Unlock()
+
+	manager.cacheExpires = time.Time{}
+}
+
+func (manager *SWebhookManager) getEnabledWebhooks() ([]SWebhook, error) {
+	manager.cacheLock.Lock()
+	defer manager.cacheLock.Unlock()
+
+	if time.Now().Before(manager.cacheExpires) {
+		return manager.cache, nil
+	}
+	q := manager.Query().IsTrue("enabled").IsFalse("pending_deleted")
+	webhooks := make([]SWebhook, 0)
+	if err := db.FetchModelObjects(manager, q, &webhooks); err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	manager.cache = webhooks
+	manager.cacheExpires = time.Now().Add(webhookCacheExpire)
+	return webhooks, nil
+}
+
+// onOpsLog creates the deliveries of the event for the matched webhooks and
+// sends them, the events of the webhooks themselves are skipped so that a
+// delivery never triggers another one
+func (manager *SWebhookManager) onOpsLog(event *db.SOpsLog) {
+	ctx := context.Background()
+	if event.ObjType == manager.Keyword() || event.ObjType == WebhookDeliveryManager.Keyword() {
+		return
+	}
+	webhooks, err := manager.getEnabledWebhooks()
+	if err != nil {
+		log.Errorf("get enabled webhooks: %v", err)
+		return
+	}
+	for i := range webhooks {
+		if !webhooks[i].MatchEvent(event) {
+			continue
+		}
+		delivery, err := WebhookDeliveryManager.createDelivery(ctx, &webhooks[i], event)
+		if err != nil {
+			log.Errorf("create delivery of event %d for webhook %s: %v", event.Id, webhooks[i].Name, err)
+			continue
+		}
+		delivery.startDeliver()
+	}
+}
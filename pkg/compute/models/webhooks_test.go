@@ -0,0 +1,119 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+func TestWebhookMatchEvent(t *testing.T) {
+	webhook := &SWebhook{
+		ResourceTypes: jsonutils.NewStringArray([]string{"server", "disk"}),
+		Actions:       jsonutils.NewStringArray([]string{"create", "delete"}),
+		EventScope:    "project",
+	}
+	webhook.ProjectId = "p1"
+	webhook.DomainId = "d1"
+
+	cases := []struct {
+		name  string
+		scope string
+		event db.SOpsLog
+		want  bool
+	}{
+		{"match", "project", db.SOpsLog{ObjType: "server", Action: "create", OwnerProjectId: "p1", OwnerDomainId: "d1"}, true},
+		{"other resource", "project", db.SOpsLog{ObjType: "network", Action: "create", OwnerProjectId: "p1", OwnerDomainId: "d1"}, false},
+		{"other action", "project", db.SOpsLog{ObjType: "disk", Action: "start", OwnerProjectId: "p1", OwnerDomainId: "d1"}, false},
+		{"other project", "project", db.SOpsLog{ObjType: "disk", Action: "delete", OwnerProjectId: "p2", OwnerDomainId: "d1"}, false},
+		{"domain", "domain", db.SOpsLog{ObjType: "disk", Action: "delete", OwnerProjectId: "p2", OwnerDomainId: "d1"}, true},
+		{"other domain", "domain", db.SOpsLog{ObjType: "disk", Action: "delete", OwnerProjectId: "p3", OwnerDomainId: "d2"}, false},
+		{"no owner", "domain", db.SOpsLog{ObjType: "disk", Action: "delete"}, false},
+		{"system", "system", db.SOpsLog{ObjType: "disk", Action: "delete"}, true},
+	}
+	for _, c := range cases {
+		webhook.EventScope = c.scope
+		if got := webhook.MatchEvent(&c.event); got != c.want {
+			t.Errorf("%s: want %v got %v", c.name, c.want, got)
+		}
+	}
+
+	webhook.ResourceTypes = nil
+	webhook.Actions = jsonutils.NewArray()
+	webhook.EventScope = "project"
+	if !webhook.MatchEvent(&db.SOpsLog{ObjType: "network", Action: "update", OwnerProjectId: "p1"}) {
+		t.Errorf("empty filters should match all events of the project")
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// echo -n '1600000000.{"action":"create"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=19acdbd9ca2c2eb1f089e9548073d5bdbb16542cf6110a4adef6532193eecadc"
+	if got := SignWebhookPayload("secret", 1600000000, []byte(`{"action":"create"}`)); got != want {
+		t.Errorf("want %s got %s", want, got)
+	}
+}
+
+func TestWebhookRetryInterval(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		20: time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := webhookRetryInterval(attempts); got != want {
+			t.Errorf("attempts %d: want %s got %s", attempts, want, got)
+		}
+	}
+}
+
+func TestIsWebhookAddressAllowed(t *testing.T) {
+	cases := []struct {
+		ip      string
+		allowed []string
+		want    bool
+	}{
+		{"8.8.8.8", nil, true},
+		{"127.0.0.1", nil, false},
+		{"169.254.169.254", nil, false},
+		{"10.1.2.3", nil, false},
+		{"172.20.0.1", nil, false},
+		{"192.168.1.1", nil, false},
+		{"0.0.0.0", nil, false},
+		{"::1", nil, false},
+		{"fd00::1", nil, false},
+		{"10.1.2.3", []string{"10.1.0.0/16"}, true},
+		{"10.2.2.3", []string{"10.1.0.0/16"}, false},
+	}
+	for _, c := range cases {
+		if got := isWebhookAddressAllowed(net.ParseIP(c.ip), c.allowed); got != c.want {
+			t.Errorf("%s allowed by %v: want %v got %v", c.ip, c.allowed, c.want, got)
+		}
+	}
+	for _, u := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook", "http://[::1]/hook", "https://169.254.169.254/latest"} {
+		if err := validateWebhookUrl(u); err == nil {
+			t.Errorf("url %s should be rejected", u)
+		}
+	}
+	if err := validateWebhookUrl("https://203.0.113.10/hook"); err != nil {
+		t.Errorf("public url rejected: %v", err)
+	}
+}
@@ -117,6 +117,15 @@ type ComputeOptions struct {
 	SyncCloudImagesDay  int `default:"1" help:"Days auto sync public cloud images data, default 1 day"`
 	SyncCloudImagesHour int `default:"3" help:"What hour start sync public cloud images, default 03:00"`
 
+	// webhook delivery
+	WebhookDeliveryTimeoutSeconds int `default:"10" help:"Timeout of posting an event to a webhook, default 10 seconds"`
+	WebhookDeliveryKeepDays       int `default:"7" help:"Days to keep the webhook delivery logs, default 7 days"`
+	// the loopback, link-local and private addresses are rejected unless allowed here
+	WebhookAllowedCidrs []string `help:"CIDRs of the internal addresses the webhooks are allowed to post to, e.g. 10.0.0.0/8"`
+
+	// cron job schedules
+	CronJobSchedules []string `help:"Cron expressions overriding the schedules of the built-in cron jobs, e.g. SnapshotsCleanup=TZ=Asia/Shanghai 35 2 * * *"`
+
 	EnablePreAllocateIpAddr bool `help:"Enable private and public cloud private ip pre allocate, default false" default:"false"`
 
 	DefaultImageCacheDir string `default:"image_cache"`
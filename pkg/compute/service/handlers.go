@@ -17,10 +17,12 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/proxy"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/quotas"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
 	"yunion.io/x/onecloud/pkg/compute/capabilities"
 	"yunion.io/x/onecloud/pkg/compute/misc"
 	"yunion.io/x/onecloud/pkg/compute/models"
@@ -49,6 +51,8 @@ func InitHandlers(app *appsrv.Application) {
 	sshkeys.AddSshKeysHandler("", app)
 	taskman.AddTaskHandler("", app)
 	misc.AddMiscHandler("", app)
+	cronman.AddCronJobHandler("", app)
+	informer.AddWatchHandler("", app)
 
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
@@ -229,6 +233,9 @@ func InitHandlers(app *appsrv.Application) {
 
 		models.MongoDBManager,
 		models.ElasticSearchManager,
+
+		models.WebhookManager,
+		models.WebhookDeliveryManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
@@ -215,3 +215,7 @@ func NewJsonClientError(err errors.Error, msg string, params ...interface{}) *ht
 	}
 	return httputils.NewJsonClientError(code, string(err), msg, params...)
 }
+
+func NewTooManyRequestsError(msg string, params ...interface{}) *httputils.JSONClientError {
+	return httputils.NewJsonClientError(httpErrorCode[ErrTooManyRequests], string(ErrTooManyRequests), msg, params...)
+}
@@ -18,12 +18,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
+	"strings"
 	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/gotypes"
 	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/apis"
@@ -111,7 +114,20 @@ func (manager *SCredentialManager) InitializeData() error {
 	return nil
 }
 
+// validateNotByAppCredential forbids a token issued by an application credential
+// to alter the credentials and passwords, otherwise a leaked application credential could be escalated
+func validateNotByAppCredential(userCred mcclient.TokenCredential, action string) error {
+	if !mcclient.GetAppCredential(userCred).IsZero() {
+		return httperrors.NewForbiddenError("not allow to %s with an application credential", action)
+	}
+	return nil
+}
+
 func (manager *SCredentialManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	err := validateNotByAppCredential(userCred, "create credential")
+	if err != nil {
+		return nil, err
+	}
 	if !data.Contains("type") {
 		return nil, httperrors.NewInputParameterError("missing input field type")
 	}
@@ -144,6 +160,29 @@ func (manager *SCredentialManager) ValidateCreateData(ctx context.Context, userC
 	if len(blob) == 0 {
 		return nil, httperrors.NewInputParameterError("missing input field blob")
 	}
+	typeStr, _ := data.GetString("type")
+	if typeStr == api.WEBAUTHN_TYPE {
+		webauthn, err := parseWebAuthnBlob(blob)
+		if err != nil {
+			return nil, err
+		}
+		cred, err := manager.fetchWebAuthnCredential(userId, webauthn.CredentialId)
+		if err != nil {
+			return nil, httperrors.NewGeneralError(err)
+		}
+		if cred != nil {
+			return nil, httperrors.NewDuplicateResourceError("webauthn credential %s exists", webauthn.CredentialId)
+		}
+	} else if typeStr == api.APP_CREDENTIAL_TYPE {
+		if len(projectId) == 0 || projectId == api.DEFAULT_PROJECT {
+			return nil, httperrors.NewInputParameterError("application credential must be bound to a project")
+		}
+		appCred, err := validateAppCredentialBlob(userId, projectId, blob)
+		if err != nil {
+			return nil, err
+		}
+		blob = jsonutils.Marshal(appCred).String()
+	}
 	blobEnc, err := keys.CredentialKeyManager.Encrypt([]byte(blob))
 	if err != nil {
 		return nil, httperrors.NewInternalServerError("encrypt error %s", err)
@@ -168,8 +207,20 @@ func (self *SCredential) ValidateDeleteCondition(ctx context.Context) error {
 	return self.SStandaloneResourceBase.ValidateDeleteCondition(ctx)
 }
 
+func (self *SCredential) CustomizeDelete(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	return validateNotByAppCredential(userCred, "delete credential")
+}
+
 func (self *SCredential) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.CredentialUpdateInput) (api.CredentialUpdateInput, error) {
-	var err error
+	err := validateNotByAppCredential(userCred, "update credential")
+	if err != nil {
+		return input, err
+	}
+
+	if input.Blob != nil {
+		// the sign count of a webauthn credential is advanced by update-sign-count only
+		return input, httperrors.NewForbiddenError("blob of %s credential is readonly", self.Type)
+	}
 
 	input.StandaloneResourceBaseUpdateInput, err = self.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
 	if err != nil {
@@ -179,6 +230,49 @@ func (self *SCredential) ValidateUpdateData(ctx context.Context, userCred mcclie
 	return input, nil
 }
 
+func (self *SCredential) AllowPerformUpdateSignCount(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.CredentialUpdateSignCountInput,
+) bool {
+	return db.IsAdminAllowPerform(userCred, self, "update-sign-count")
+}
+
+// 更新WebAuthn凭证的签名计数器, 计数器只能增加, 用于发现被克隆的硬件密钥
+func (self *SCredential) PerformUpdateSignCount(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.CredentialUpdateSignCountInput,
+) (jsonutils.JSONObject, error) {
+	err := validateNotByAppCredential(userCred, "update sign count")
+	if err != nil {
+		return nil, err
+	}
+	webauthn, err := self.GetWebAuthnCredential()
+	if err != nil {
+		return nil, errors.Wrapf(httperrors.ErrNotSupported, "update sign count of %s credential", self.Type)
+	}
+	if input.SignCount <= webauthn.SignCount {
+		return nil, httperrors.NewInputParameterError("sign_count %d should be greater than %d", input.SignCount, webauthn.SignCount)
+	}
+	webauthn.SignCount = input.SignCount
+	blobEnc, err := keys.CredentialKeyManager.Encrypt([]byte(jsonutils.Marshal(webauthn).String()))
+	if err != nil {
+		return nil, errors.Wrap(err, "encrypt credential blob")
+	}
+	_, err = db.Update(self, func() error {
+		self.EncryptedBlob = string(blobEnc)
+		self.KeyHash = keys.CredentialKeyManager.PrimaryKeyHash()
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update credential blob")
+	}
+	return nil, nil
+}
+
 func (self *SCredential) GetExtraDetails(
 	ctx context.Context,
 	userCred mcclient.TokenCredential,
@@ -204,14 +298,17 @@ func (manager *SCredentialManager) FetchCustomizeColumns(
 		rows[i] = api.CredentialDetails{
 			StandaloneResourceDetails: stdRows[i],
 		}
-		rows[i] = credentialExtra(objs[i].(*SCredential), rows[i])
+		rows[i] = credentialExtra(objs[i].(*SCredential), userCred, rows[i])
 	}
 
 	return rows
 }
 
-func credentialExtra(cred *SCredential, out api.CredentialDetails) api.CredentialDetails {
-	out.Blob = string(cred.getBlob())
+func credentialExtra(cred *SCredential, userCred mcclient.TokenCredential, out api.CredentialDetails) api.CredentialDetails {
+	// the secrets, e.g. AK/SK and TOTP, are never revealed to a token issued by an application credential
+	if mcclient.GetAppCredential(userCred).IsZero() {
+		out.Blob = string(cred.getBlob())
+	}
 
 	usr, _ := UserManager.FetchUserExtended(cred.UserId, "", "", "")
 	if usr != nil {
@@ -242,6 +339,180 @@ func (self *SCredential) GetAccessKeySecret() (*api.SAccessKeySecretBlob, error)
 	return nil, errors.Error("no an AK/SK credential")
 }
 
+func validateAppCredentialBlob(userId string, projectId string, blob string) (*api.SAppCredentialBlob, error) {
+	blobJson, err := jsonutils.ParseString(blob)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid application credential blob: %s", err)
+	}
+	appCred := api.SAppCredentialBlob{}
+	err = blobJson.Unmarshal(&appCred)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid application credential blob: %s", err)
+	}
+	if len(appCred.Secret) == 0 {
+		return nil, httperrors.NewInputParameterError("missing secret of application credential blob")
+	}
+	if !appCred.IsValid() {
+		return nil, httperrors.NewInputParameterError("application credential expires in the past")
+	}
+	if len(appCred.Roles) == 0 {
+		return nil, httperrors.NewInputParameterError("missing roles of application credential blob")
+	}
+	roles, err := AssignmentManager.FetchUserProjectRoles(userId, projectId)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	roleIds := make([]string, 0, len(appCred.Roles))
+	for _, roleStr := range appCred.Roles {
+		find := false
+		for i := range roles {
+			if roles[i].Id == roleStr || roles[i].Name == roleStr {
+				if !utils.IsInStringArray(roles[i].Id, roleIds) {
+					roleIds = append(roleIds, roles[i].Id)
+				}
+				find = true
+				break
+			}
+		}
+		if !find {
+			return nil, httperrors.NewForbiddenError("role %s is not assigned to the user in the project", roleStr)
+		}
+	}
+	appCred.Roles = roleIds
+	for _, action := range appCred.AllowedActions {
+		if !api.IsValidAppCredentialAction(action) {
+			return nil, httperrors.NewInputParameterError("invalid allowed action %s, should be <service>/<resource>/<action>", action)
+		}
+	}
+	for _, ip := range appCred.AllowedIps {
+		if strings.Contains(ip, "/") {
+			_, _, err = net.ParseCIDR(ip)
+		} else if net.ParseIP(ip) == nil {
+			err = errors.Error("invalid ip")
+		}
+		if err != nil {
+			return nil, httperrors.NewInputParameterError("invalid allowed ip %s", ip)
+		}
+	}
+	return &appCred, nil
+}
+
+func (self *SCredential) GetAppCredential() (*api.SAppCredentialBlob, error) {
+	if self.Type != api.APP_CREDENTIAL_TYPE {
+		return nil, errors.Error("not an application credential")
+	}
+	blobJson, err := jsonutils.Parse(self.getBlob())
+	if err != nil {
+		return nil, errors.Wrap(err, "jsonutils.Parse")
+	}
+	ret := api.SAppCredentialBlob{}
+	err = blobJson.Unmarshal(&ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "blobJson.Unmarshal")
+	}
+	return &ret, nil
+}
+
+// FetchAppCredentialRoles returns the roles granted to the application
+// credential, the roles revoked from the user since the credential was
+// created are excluded
+func (self *SCredential) FetchAppCredentialRoles() ([]SRole, error) {
+	appCred, err := self.GetAppCredential()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetAppCredential")
+	}
+	roles, err := AssignmentManager.FetchUserProjectRoles(self.UserId, self.ProjectId)
+	if err != nil {
+		return nil, errors.Wrap(err, "AssignmentManager.FetchUserProjectRoles")
+	}
+	ret := make([]SRole, 0, len(roles))
+	for i := range roles {
+		if utils.IsInStringArray(roles[i].Id, appCred.Roles) {
+			ret = append(ret, roles[i])
+		}
+	}
+	return ret, nil
+}
+
+// FetchAppCredential returns the enabled and unexpired application credential
+func (manager *SCredentialManager) FetchAppCredential(id string) (*SCredential, *api.SAppCredentialBlob, error) {
+	obj, err := manager.FetchById(id)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "FetchById")
+	}
+	cred := obj.(*SCredential)
+	if cred.Type != api.APP_CREDENTIAL_TYPE {
+		return nil, nil, errors.Wrapf(httperrors.ErrInvalidCredential, "%s is not an application credential", id)
+	}
+	if !cred.Enabled.IsTrue() {
+		return nil, nil, errors.Wrap(httperrors.ErrInvalidCredential, "application credential revoked")
+	}
+	appCred, err := cred.GetAppCredential()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "GetAppCredential")
+	}
+	if !appCred.IsValid() {
+		return nil, nil, errors.Wrap(httperrors.ErrInvalidCredential, "application credential expired")
+	}
+	return cred, appCred, nil
+}
+
+func parseWebAuthnBlob(blob string) (*api.SWebAuthnCredentialBlob, error) {
+	blobJson, err := jsonutils.ParseString(blob)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid webauthn blob: %s", err)
+	}
+	ret := api.SWebAuthnCredentialBlob{}
+	err = blobJson.Unmarshal(&ret)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid webauthn blob: %s", err)
+	}
+	if len(ret.CredentialId) == 0 {
+		return nil, httperrors.NewInputParameterError("missing credential_id of webauthn blob")
+	}
+	if len(ret.PublicKey) == 0 {
+		return nil, httperrors.NewInputParameterError("missing public_key of webauthn blob")
+	}
+	return &ret, nil
+}
+
+func (self *SCredential) GetWebAuthnCredential() (*api.SWebAuthnCredentialBlob, error) {
+	if self.Type != api.WEBAUTHN_TYPE {
+		return nil, errors.Error("not a webauthn credential")
+	}
+	blobJson, err := jsonutils.Parse(self.getBlob())
+	if err != nil {
+		return nil, errors.Wrap(err, "jsonutils.Parse")
+	}
+	ret := api.SWebAuthnCredentialBlob{}
+	err = blobJson.Unmarshal(&ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "blobJson.Unmarshal")
+	}
+	return &ret, nil
+}
+
+// fetchWebAuthnCredential returns the webauthn credential of the user with
+// the credential id, nil if not found
+func (manager *SCredentialManager) fetchWebAuthnCredential(userId string, credId string) (*SCredential, error) {
+	q := manager.Query().Equals("user_id", userId).Equals("type", api.WEBAUTHN_TYPE)
+	creds := make([]SCredential, 0)
+	err := db.FetchModelObjects(manager, q, &creds)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range creds {
+		blob, err := creds[i].GetWebAuthnCredential()
+		if err != nil {
+			continue
+		}
+		if blob.CredentialId == credId {
+			return &creds[i], nil
+		}
+	}
+	return nil, nil
+}
+
 func (manager *SCredentialManager) ResourceScope() rbacutils.TRbacScope {
 	return rbacutils.ScopeUser
 }
@@ -299,7 +570,10 @@ func (manager *SCredentialManager) ListItemFilter(
 	userCred mcclient.TokenCredential,
 	query api.CredentialListInput,
 ) (*sqlchemy.SQuery, error) {
-	var err error
+	err := validateNotByAppCredential(userCred, "list credentials")
+	if err != nil {
+		return nil, err
+	}
 	q, err = manager.SStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StandaloneResourceListInput)
 	if err != nil {
 		return nil, errors.Wrap(err, "SStandaloneResourceBaseManager.ListItemFilter")
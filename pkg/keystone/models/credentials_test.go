@@ -0,0 +1,94 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/dbtest"
+	"yunion.io/x/onecloud/pkg/keystone/keys"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func TestSCredential_AppCredentialToken(t *testing.T) {
+	cleanup := dbtest.SetupSqliteDB(t,
+		CredentialManager.TableSpec(),
+		UserManager.TableSpec(),
+		LocalUserManager.TableSpec(),
+		DomainManager.TableSpec(),
+		db.Metadata.TableSpec(),
+	)
+	defer cleanup()
+	if err := keys.CredentialKeyManager.InitEmpty(); err != nil {
+		t.Fatalf("init credential keys: %v", err)
+	}
+
+	ctx := context.Background()
+	secret := jsonutils.Marshal(api.SAccessKeySecretBlob{Secret: "sk"}).String()
+	blobEnc, err := keys.CredentialKeyManager.Encrypt([]byte(secret))
+	if err != nil {
+		t.Fatalf("encrypt blob: %v", err)
+	}
+	cred := &SCredential{}
+	cred.Id = "aksk"
+	cred.Name = "aksk"
+	cred.Type = api.ACCESS_SECRET_TYPE
+	cred.UserId = "alice"
+	cred.ProjectId = "demo"
+	cred.EncryptedBlob = string(blobEnc)
+	cred.SetModelManager(CredentialManager, cred)
+	if err := CredentialManager.TableSpec().Insert(ctx, cred); err != nil {
+		t.Fatalf("insert credential: %v", err)
+	}
+
+	userToken := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	appCredToken := &mcclient.SSimpleToken{UserId: "alice", User: "alice", ProjectId: "demo"}
+	appCredToken.AppCredential = api.SAppCredentialInfo{Id: "appcred"}
+
+	for _, c := range []struct {
+		name     string
+		userCred mcclient.TokenCredential
+		want     string
+	}{
+		{name: "user token", userCred: userToken, want: secret},
+		{name: "app credential token", userCred: appCredToken, want: ""},
+	} {
+		rows := CredentialManager.FetchCustomizeColumns(ctx, c.userCred, jsonutils.NewDict(), []interface{}{cred}, nil, false)
+		if len(rows) != 1 {
+			t.Fatalf("%s: want 1 row got %d", c.name, len(rows))
+		}
+		if rows[0].Blob != c.want {
+			t.Errorf("%s: want blob %q got %q", c.name, c.want, rows[0].Blob)
+		}
+	}
+
+	if _, err := CredentialManager.ListItemFilter(ctx, CredentialManager.Query(), appCredToken, api.CredentialListInput{}); err == nil {
+		t.Errorf("an application credential token should not list the credentials")
+	}
+	if _, err := CredentialManager.ListItemFilter(ctx, CredentialManager.Query(), userToken, api.CredentialListInput{}); err != nil {
+		t.Errorf("list credentials: %v", err)
+	}
+	if err := cred.CustomizeDelete(ctx, appCredToken, nil, nil); err == nil {
+		t.Errorf("an application credential token should not delete the credentials")
+	}
+	if err := cred.CustomizeDelete(ctx, userToken, nil, nil); err != nil {
+		t.Errorf("delete credential: %v", err)
+	}
+}
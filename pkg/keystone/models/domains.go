@@ -23,6 +23,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -66,6 +67,11 @@ type SDomain struct {
 
 	DomainId string `width:"64" charset:"ascii" default:"default" nullable:"false" index:"true"`
 	ParentId string `width:"64" charset:"ascii"`
+
+	// 强制域内所有用户开启MFA认证
+	EnableMfa tristate.TriState `nullable:"false" default:"false" list:"admin" update:"admin" create:"admin_optional"`
+	// 域内用户默认的MFA认证方式
+	MfaMode string `width:"16" charset:"ascii" nullable:"true" list:"admin" update:"admin" create:"admin_optional"`
 }
 
 func (manager *SDomainManager) InitializeData() error {
@@ -339,6 +345,9 @@ func (domain *SDomain) ValidateUpdateData(ctx context.Context, userCred mcclient
 			}
 		}
 	}
+	if len(input.MfaMode) > 0 && !utils.IsInStringArray(input.MfaMode, api.MFA_MODES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_mode %s", input.MfaMode)
+	}
 	var err error
 	input.StandaloneResourceBaseUpdateInput, err = domain.SStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StandaloneResourceBaseUpdateInput)
 	if err != nil {
@@ -526,6 +535,9 @@ func (manager *SDomainManager) ValidateCreateData(
 ) (api.DomainCreateInput, error) {
 	var err error
 
+	if len(input.MfaMode) > 0 && !utils.IsInStringArray(input.MfaMode, api.MFA_MODES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_mode %s", input.MfaMode)
+	}
 	input.StandaloneResourceCreateInput, err = manager.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StandaloneResourceCreateInput)
 	if err != nil {
 		return input, errors.Wrap(err, "SStandaloneResourceBaseManager.ValidateCreateData")
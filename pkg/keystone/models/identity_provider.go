@@ -107,6 +107,10 @@ type SIdentityProvider struct {
 	IsSso tristate.TriState `nullable:"true" list:"domain"`
 	// 是否是缺省SSO登录方式
 	IsDefault tristate.TriState `nullable:"true" list:"domain"`
+	// 是否启用SCIM推送用户和组
+	ScimEnabled tristate.TriState `default:"false" nullable:"true" list:"domain"`
+	// SCIM Bearer Token的SHA256摘要
+	ScimTokenHash string `width:"64" charset:"ascii" nullable:"true"`
 }
 
 func (manager *SIdentityProviderManager) initializeAutoCreateUser() error {
@@ -680,7 +684,7 @@ func (self *SIdentityProvider) getLinkedEntityQuery(manager db.IStandaloneModelM
 	return q
 }
 
-func (self *SIdentityProvider) getLinkedUsers() ([]SUser, error) {
+func (self *SIdentityProvider) GetLinkedUsers() ([]SUser, error) {
 	q := self.getLinkedUserQuery()
 	users := make([]SUser, 0)
 	err := db.FetchModelObjects(UserManager, q, &users)
@@ -736,7 +740,7 @@ func (self *SIdentityProvider) getLinkedGroupQuery() *sqlchemy.SQuery {
 	return self.getLinkedEntityQuery(GroupManager, api.IdMappingEntityGroup)
 }
 
-func (self *SIdentityProvider) getLinkedGroups() ([]SGroup, error) {
+func (self *SIdentityProvider) GetLinkedGroups() ([]SGroup, error) {
 	q := self.getLinkedGroupQuery()
 	groups := make([]SGroup, 0)
 	err := db.FetchModelObjects(GroupManager, q, &groups)
@@ -857,7 +861,7 @@ func (self *SIdentityProvider) isSsoIdp() bool {
 
 func (self *SIdentityProvider) Purge(ctx context.Context, userCred mcclient.TokenCredential) error {
 	// delete users
-	users, err := self.getLinkedUsers()
+	users, err := self.GetLinkedUsers()
 	if err != nil {
 		return errors.Wrap(err, "getNonlocalUsers")
 	}
@@ -882,7 +886,7 @@ func (self *SIdentityProvider) Purge(ctx context.Context, userCred mcclient.Toke
 		}
 	}
 	// delete groups
-	groups, err := self.getLinkedGroups()
+	groups, err := self.GetLinkedGroups()
 	if err != nil {
 		return errors.Wrap(err, "getNonlocalGroups")
 	}
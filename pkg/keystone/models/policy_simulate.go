@@ -0,0 +1,298 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	policyman "yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	draftPolicyName   = "<draft>"
+	defaultPolicyName = "<default>"
+)
+
+// sSimulatePolicy is a policy taking part in a simulation
+type sSimulatePolicy struct {
+	name      string
+	isDraft   bool
+	isDefault bool
+	scope     rbacutils.TRbacScope
+	policy    rbacutils.TPolicy
+}
+
+func (manager *SPolicyManager) AllowPerformSimulate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowClassPerform(userCred, manager, "simulate")
+}
+
+// 模拟任意用户、项目和角色的组合对一组请求的权限结果，可以使用未保存的草稿权限
+func (manager *SPolicyManager) PerformSimulate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.PolicySimulateInput) (api.PolicySimulateOutput, error) {
+	output := api.PolicySimulateOutput{}
+	// the simulated identity matched by the default policies
+	identity := &mcclient.SSimpleToken{
+		Token:   rbacutils.FAKE_TOKEN,
+		Context: mcclient.SAuthContext{Ip: input.LoginIp},
+	}
+	if len(input.Requests) == 0 {
+		return output, errors.Wrap(httperrors.ErrInputParameter, "empty requests")
+	}
+	if len(input.UserId) > 0 {
+		usr, err := UserManager.FetchByIdOrName(userCred, input.UserId)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return output, httperrors.NewResourceNotFoundError2(UserManager.Keyword(), input.UserId)
+			}
+			return output, errors.Wrap(err, "UserManager.FetchByIdOrName")
+		}
+		output.UserId = usr.GetId()
+	}
+	if len(input.ProjectId) > 0 {
+		proj, err := ProjectManager.FetchByIdOrName(userCred, input.ProjectId)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return output, httperrors.NewResourceNotFoundError2(ProjectManager.Keyword(), input.ProjectId)
+			}
+			return output, errors.Wrap(err, "ProjectManager.FetchByIdOrName")
+		}
+		output.ProjectId = proj.GetId()
+		identity.ProjectId = proj.GetId()
+		identity.Project = proj.GetName()
+		identity.ProjectDomainId = proj.(*SProject).DomainId
+	}
+	roleNames := make([]string, 0)
+	if len(input.RoleIds) > 0 {
+		for _, roleId := range input.RoleIds {
+			role, err := RoleManager.FetchByIdOrName(userCred, roleId)
+			if err != nil {
+				if errors.Cause(err) == sql.ErrNoRows {
+					return output, httperrors.NewResourceNotFoundError2(RoleManager.Keyword(), roleId)
+				}
+				return output, errors.Wrap(err, "RoleManager.FetchByIdOrName")
+			}
+			output.RoleIds = append(output.RoleIds, role.GetId())
+			roleNames = append(roleNames, role.GetName())
+		}
+	} else if len(output.UserId) > 0 && len(output.ProjectId) > 0 {
+		roles, err := AssignmentManager.FetchUserProjectRoles(output.UserId, output.ProjectId)
+		if err != nil {
+			return output, errors.Wrap(err, "FetchUserProjectRoles")
+		}
+		if len(roles) == 0 {
+			return output, errors.Wrapf(httperrors.ErrInputParameter, "user %s has no role in project %s", input.UserId, input.ProjectId)
+		}
+		for i := range roles {
+			output.RoleIds = append(output.RoleIds, roles[i].Id)
+			roleNames = append(roleNames, roles[i].Name)
+		}
+	} else {
+		return output, errors.Wrap(httperrors.ErrInputParameter, "either role_ids or both user_id and project_id should be specified")
+	}
+	identity.RoleIds = strings.Join(output.RoleIds, ",")
+	identity.Roles = strings.Join(roleNames, ",")
+	if len(input.Groups) > 0 {
+		for _, groupId := range input.Groups {
+			group, err := GroupManager.FetchByIdOrName(userCred, groupId)
+			if err != nil {
+				if errors.Cause(err) == sql.ErrNoRows {
+					return output, httperrors.NewResourceNotFoundError2(GroupManager.Keyword(), groupId)
+				}
+				return output, errors.Wrap(err, "GroupManager.FetchByIdOrName")
+			}
+			output.Groups = append(output.Groups, group.GetId(), group.GetName())
+		}
+	} else if len(output.UserId) > 0 {
+		groups, err := UsergroupManager.GetUserGroupIdsAndNames(output.UserId)
+		if err != nil {
+			return output, errors.Wrap(err, "GetUserGroupIdsAndNames")
+		}
+		output.Groups = groups
+	}
+
+	policies, err := manager.fetchSimulatePolicies(userCred, output.RoleIds, output.ProjectId, input.LoginIp, input.Draft)
+	if err != nil {
+		return output, errors.Wrap(err, "fetchSimulatePolicies")
+	}
+	output.Policies = make(map[rbacutils.TRbacScope][]string)
+	for i := range policies {
+		output.Policies[policies[i].scope] = append(output.Policies[policies[i].scope], policies[i].name)
+	}
+	policies = append(policies, matchSimulateDefaultPolicies(policyman.GetDefaultPolicies(), identity)...)
+
+	tm := input.Time
+	if tm.IsZero() {
+		tm = time.Now()
+	}
+	output.Results = make([]api.PolicySimulateResult, len(input.Requests))
+	for i := range input.Requests {
+		cctx := rbacutils.SConditionContext{
+			Groups:     output.Groups,
+			Time:       tm,
+			ObjectTags: input.Requests[i].Tags,
+		}
+		output.Results[i] = simulatePolicyRequest(policies, cctx, input.Requests[i])
+	}
+	return output, nil
+}
+
+// fetchSimulatePolicies fetches the policies bound to the roles, the draft takes the place of the policy it replaces
+func (manager *SPolicyManager) fetchSimulatePolicies(userCred mcclient.TokenCredential, roleIds []string, projectId string, loginIp string, draft *api.PolicySimulateDraft) ([]sSimulatePolicy, error) {
+	policyIds, err := RolePolicyManager.getMatchPolicyIds2(false, roleIds, projectId, loginIp)
+	if err != nil {
+		return nil, errors.Wrap(err, "getMatchPolicyIds2")
+	}
+	var draftPolicy *sSimulatePolicy
+	if draft != nil {
+		if draft.Blob == nil {
+			return nil, errors.Wrap(httperrors.ErrInputParameter, "missing draft blob")
+		}
+		data, err := rbacutils.DecodePolicyData(draft.Blob)
+		if err != nil {
+			return nil, errors.Wrap(httperrors.ErrInputParameter, "fail to decode draft policy data")
+		}
+		draftPolicy = &sSimulatePolicy{
+			name:    draft.Name,
+			isDraft: true,
+			scope:   rbacutils.String2ScopeDefault(string(draft.Scope), rbacutils.ScopeProject),
+			policy:  data,
+		}
+		if len(draftPolicy.name) == 0 {
+			draftPolicy.name = draftPolicyName
+		}
+	}
+	includeDraft := draftPolicy != nil
+	if draft != nil && len(draft.Replace) > 0 {
+		replaced, err := manager.FetchByIdOrName(userCred, draft.Replace)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(manager.Keyword(), draft.Replace)
+			}
+			return nil, errors.Wrap(err, "FetchByIdOrName")
+		}
+		// the draft is bound wherever the replaced policy is bound
+		ids := stringutils2.SSortedStrings(policyIds)
+		includeDraft = ids.Contains(replaced.GetId())
+		policyIds = ids.Remove(replaced.GetId())
+	}
+	ret := make([]sSimulatePolicy, 0, len(policyIds)+1)
+	for _, id := range policyIds {
+		policyObj, err := manager.FetchById(id)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				continue
+			}
+			return nil, errors.Wrapf(err, "FetchPolicy %s", id)
+		}
+		policy := policyObj.(*SPolicy)
+		data, err := policy.getPolicy()
+		if err != nil {
+			return nil, errors.Wrapf(err, "getPolicy %s", policy.Name)
+		}
+		ret = append(ret, sSimulatePolicy{
+			name:   policy.Name,
+			scope:  policy.Scope,
+			policy: data,
+		})
+	}
+	if includeDraft {
+		ret = append(ret, *draftPolicy)
+	}
+	return ret, nil
+}
+
+// matchSimulateDefaultPolicies returns the default policies applied to the simulated identity,
+// only the default policies of keystone and the common ones are built in keystone
+func matchSimulateDefaultPolicies(defaults []rbacutils.SRbacPolicy, identity rbacutils.IRbacIdentity2) []sSimulatePolicy {
+	ret := make([]sSimulatePolicy, 0)
+	for i := range defaults {
+		if isMatched, _ := defaults[i].Match(identity); !isMatched {
+			continue
+		}
+		ret = append(ret, sSimulatePolicy{
+			name:      defaultPolicyName,
+			isDefault: true,
+			scope:     defaults[i].Scope,
+			policy:    defaults[i].Rules,
+		})
+	}
+	return ret
+}
+
+// simulatePolicyRequest evaluates a request in the same way as the policy manager of the services:
+// the policies of the target scope and the higher scopes are tried in turn, any matched allow rule allows the request.
+// The default policies passed in are evaluated like the others, while those of the services other than keystone
+// are unknown here, so the result tells whether the default policies of the requested service are evaluated.
+// The scoped denials of the services never turn a request allowed by a rule into denied, so they are left out.
+func simulatePolicyRequest(policies []sSimulatePolicy, cctx rbacutils.SConditionContext, req api.PolicySimulateRequest) api.PolicySimulateResult {
+	req.Scope = rbacutils.String2ScopeDefault(string(req.Scope), rbacutils.ScopeProject)
+	if len(req.Service) == 0 {
+		req.Service = rbacutils.WILD_MATCH
+	}
+	if len(req.Resource) == 0 {
+		req.Resource = rbacutils.WILD_MATCH
+	}
+	if len(req.Action) == 0 {
+		req.Action = rbacutils.WILD_MATCH
+	}
+	result := api.PolicySimulateResult{
+		PolicySimulateRequest: req,
+		Result:                rbacutils.Deny,
+		Matches:               make([]api.PolicySimulateMatch, 0),
+
+		DefaultPoliciesEvaluated: req.Service == api.SERVICE_TYPE,
+	}
+	for _, scope := range policyman.GetRetryScopes(req.Scope) {
+		for i := range policies {
+			if policies[i].scope != scope {
+				continue
+			}
+			rule := policies[i].policy.GetMatchRuleWithContext(&cctx, req.Service, req.Resource, req.Action, req.Extra...)
+			if rule == nil {
+				continue
+			}
+			match := api.PolicySimulateMatch{
+				Policy:    policies[i].name,
+				IsDraft:   policies[i].isDraft,
+				IsDefault: policies[i].isDefault,
+				Scope:     scope,
+				Rule:      append([]string{rule.Service, rule.Resource, rule.Action}, rule.Extra...),
+				Result:    rule.Result,
+			}
+			if rule.Conditions != nil {
+				match.Conditions = rule.Conditions.Encode()
+			}
+			result.Matches = append(result.Matches, match)
+			if rule.Result == rbacutils.Allow {
+				result.Result = rbacutils.Allow
+			}
+		}
+		if result.Result == rbacutils.Allow {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+func TestSimulatePolicyRequest(t *testing.T) {
+	decode := func(str string) rbacutils.TPolicy {
+		json, err := jsonutils.ParseString(str)
+		if err != nil {
+			t.Fatalf("parse policy %s", err)
+		}
+		policy, err := rbacutils.DecodePolicy(json)
+		if err != nil {
+			t.Fatalf("DecodePolicy %s", err)
+		}
+		return policy
+	}
+	policies := []sSimulatePolicy{
+		{
+			name:   "project-viewer",
+			scope:  rbacutils.ScopeProject,
+			policy: decode(`{"compute": {"*": {"get": "allow", "list": "allow", "*": "deny"}}}`),
+		},
+		{
+			name:   "system-ops",
+			scope:  rbacutils.ScopeSystem,
+			policy: decode(`{"compute": {"servers": {"perform": {"stop": {"result": "allow", "conditions": {"groups": ["ops"]}}}}}}`),
+		},
+		{
+			name:    "draft",
+			isDraft: true,
+			scope:   rbacutils.ScopeProject,
+			policy:  decode(`{"compute": {"servers": {"delete": {"result": "allow", "conditions": {"tags": {"user:env": "dev"}}}}}}`),
+		},
+	}
+	defaults := []rbacutils.SRbacPolicy{
+		{
+			Auth:  true,
+			Scope: rbacutils.ScopeProject,
+			Rules: rbacutils.TPolicy{{Resource: "usages", Action: "get", Result: rbacutils.Allow}},
+		},
+		{
+			Auth:  true,
+			Roles: []string{"admin"},
+			Scope: rbacutils.ScopeSystem,
+			Rules: rbacutils.TPolicy{{Service: api.SERVICE_TYPE, Resource: "services", Action: "list", Result: rbacutils.Allow}},
+		},
+	}
+	identity := &mcclient.SSimpleToken{Token: rbacutils.FAKE_TOKEN, Project: "demo", Roles: "member"}
+	policies = append(policies, matchSimulateDefaultPolicies(defaults, identity)...)
+	cases := []struct {
+		name    string
+		groups  []string
+		req     api.PolicySimulateRequest
+		want    rbacutils.TRbacResult
+		matches []string
+
+		defaultEvaluated bool
+	}{
+		{
+			name:    "project get",
+			req:     api.PolicySimulateRequest{Service: "compute", Resource: "servers", Action: "get"},
+			want:    rbacutils.Allow,
+			matches: []string{"project-viewer"},
+		},
+		{
+			name:    "system get",
+			req:     api.PolicySimulateRequest{Scope: rbacutils.ScopeSystem, Service: "compute", Resource: "servers", Action: "get"},
+			want:    rbacutils.Deny,
+			matches: []string{},
+		},
+		{
+			name:    "stop not in group",
+			req:     api.PolicySimulateRequest{Service: "compute", Resource: "servers", Action: "perform", Extra: []string{"stop"}},
+			want:    rbacutils.Deny,
+			matches: []string{"project-viewer"},
+		},
+		{
+			name:    "stop in group",
+			groups:  []string{"ops"},
+			req:     api.PolicySimulateRequest{Service: "compute", Resource: "servers", Action: "perform", Extra: []string{"stop"}},
+			want:    rbacutils.Allow,
+			matches: []string{"system-ops"},
+		},
+		{
+			name:    "delete dev server by draft",
+			req:     api.PolicySimulateRequest{Service: "compute", Resource: "servers", Action: "delete", Tags: map[string]string{"user:env": "dev"}},
+			want:    rbacutils.Allow,
+			matches: []string{"project-viewer", "draft"},
+		},
+		{
+			name:    "delete prod server",
+			req:     api.PolicySimulateRequest{Service: "compute", Resource: "servers", Action: "delete", Tags: map[string]string{"user:env": "prod"}},
+			want:    rbacutils.Deny,
+			matches: []string{"project-viewer"},
+		},
+		{
+			name:    "usages by default policy",
+			req:     api.PolicySimulateRequest{Service: "image", Resource: "usages", Action: "get"},
+			want:    rbacutils.Allow,
+			matches: []string{"<default>"},
+		},
+		{
+			name:    "default policy of other roles",
+			req:     api.PolicySimulateRequest{Scope: rbacutils.ScopeSystem, Service: api.SERVICE_TYPE, Resource: "services", Action: "list"},
+			want:    rbacutils.Deny,
+			matches: []string{},
+
+			defaultEvaluated: true,
+		},
+	}
+	for _, c := range cases {
+		cctx := rbacutils.SConditionContext{
+			Groups:     c.groups,
+			Time:       time.Now(),
+			ObjectTags: c.req.Tags,
+		}
+		result := simulatePolicyRequest(policies, cctx, c.req)
+		if result.Result != c.want {
+			t.Errorf("%s: want %s got %s", c.name, c.want, result.Result)
+		}
+		if result.DefaultPoliciesEvaluated != c.defaultEvaluated {
+			t.Errorf("%s: want default policies evaluated %v got %v", c.name, c.defaultEvaluated, result.DefaultPoliciesEvaluated)
+		}
+		matches := make([]string, len(result.Matches))
+		for i := range result.Matches {
+			matches[i] = result.Matches[i].Policy
+		}
+		if jsonutils.Marshal(matches).String() != jsonutils.Marshal(c.matches).String() {
+			t.Errorf("%s: want matches %v got %v", c.name, c.matches, matches)
+		}
+	}
+}
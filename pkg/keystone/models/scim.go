@@ -0,0 +1,153 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/seclib"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+func scimTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (idp *SIdentityProvider) AllowPerformEnableScim(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, idp, "enable-scim")
+}
+
+// 启用SCIM, 由IdP推送用户和组, 每次调用都会生成新的Bearer Token
+func (idp *SIdentityProvider) PerformEnableScim(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if !idp.isSsoIdp() {
+		return nil, errors.Wrapf(httperrors.ErrNotSupported, "scim is not supported by %s idp", idp.Driver)
+	}
+	token := base64.RawURLEncoding.EncodeToString([]byte(seclib.RandomPassword(32)))
+	_, err := db.Update(idp, func() error {
+		idp.ScimEnabled = tristate.True
+		idp.ScimTokenHash = scimTokenHash(token)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update")
+	}
+	db.OpsLog.LogEvent(idp, db.ACT_UPDATE, "enable scim", userCred)
+	logclient.AddSimpleActionLog(idp, logclient.ACT_ENABLE, "scim", userCred, true)
+	output := api.PerformEnableScimOutput{
+		ScimPath: fmt.Sprintf("%s/%s", api.ScimServicePrefix, idp.Id),
+		Token:    token,
+	}
+	return jsonutils.Marshal(output), nil
+}
+
+func (idp *SIdentityProvider) AllowPerformDisableScim(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) bool {
+	return db.IsAdminAllowPerform(userCred, idp, "disable-scim")
+}
+
+// 禁用SCIM, 已推送的用户和组保持不变
+func (idp *SIdentityProvider) PerformDisableScim(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	_, err := db.Update(idp, func() error {
+		idp.ScimEnabled = tristate.False
+		idp.ScimTokenHash = ""
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update")
+	}
+	db.OpsLog.LogEvent(idp, db.ACT_UPDATE, "disable scim", userCred)
+	logclient.AddSimpleActionLog(idp, logclient.ACT_DISABLE, "scim", userCred, true)
+	return nil, nil
+}
+
+// VerifyScimToken checks the bearer token presented by a SCIM client
+func (idp *SIdentityProvider) VerifyScimToken(token string) bool {
+	if !idp.GetEnabled() || !idp.ScimEnabled.IsTrue() || len(idp.ScimTokenHash) == 0 || len(token) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(scimTokenHash(token)), []byte(idp.ScimTokenHash)) == 1
+}
+
+// GetScimDomain returns the domain where the users and groups pushed by SCIM reside
+func (idp *SIdentityProvider) GetScimDomain(ctx context.Context) (*SDomain, error) {
+	return idp.GetSingleDomain(ctx, api.DefaultRemoteDomainId, idp.Name, fmt.Sprintf("%s provider %s", idp.Driver, idp.Name), false)
+}
+
+// FetchLinkedUser returns sql.ErrNoRows if the user does not belong to the idp
+func (idp *SIdentityProvider) FetchLinkedUser(id string) (*SUser, error) {
+	usr, err := UserManager.fetchUserById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !usr.LinkedWithIdp(idp.Id) {
+		return nil, sql.ErrNoRows
+	}
+	return usr, nil
+}
+
+// FetchLinkedGroup returns sql.ErrNoRows if the group does not belong to the idp
+func (idp *SIdentityProvider) FetchLinkedGroup(id string) (*SGroup, error) {
+	grp := GroupManager.fetchGroupById(id)
+	if grp == nil || !grp.LinkedWithIdp(idp.Id) {
+		return nil, sql.ErrNoRows
+	}
+	return grp, nil
+}
+
+// GetLinkedEntityIds returns the map from the public id to the external id of the linked entities
+func (idp *SIdentityProvider) GetLinkedEntityIds(entityType string) (map[string]string, error) {
+	q := IdmappingManager.Query().Equals("domain_id", idp.Id).Equals("entity_type", entityType)
+	idmaps := make([]SIdmapping, 0)
+	err := db.FetchModelObjects(IdmappingManager, q, &idmaps)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	ret := make(map[string]string, len(idmaps))
+	for i := range idmaps {
+		ret[idmaps[i].PublicId] = idmaps[i].IdpEntityId
+	}
+	return ret, nil
+}
+
+// FetchMemberships returns the memberships of the users or of the groups
+func (manager *SUsergroupManager) FetchMemberships(userIds []string, groupIds []string) ([]SUsergroupMembership, error) {
+	q := manager.Query()
+	if userIds != nil {
+		q = q.In("user_id", userIds)
+	}
+	if groupIds != nil {
+		q = q.In("group_id", groupIds)
+	}
+	members := make([]SUsergroupMembership, 0)
+	err := db.FetchModelObjects(manager, q, &members)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	return members, nil
+}
@@ -88,6 +88,25 @@ func (manager *SUsergroupManager) getUserGroupIds(userId string) []string {
 	return groupIds
 }
 
+// GetUserGroupIdsAndNames returns the ids and names of the groups of a user, matched by the group conditions of policy rules
+func (manager *SUsergroupManager) GetUserGroupIdsAndNames(userId string) ([]string, error) {
+	groupIds := manager.getUserGroupIds(userId)
+	if len(groupIds) == 0 {
+		return nil, nil
+	}
+	groups := make([]SGroup, 0)
+	q := GroupManager.Query().In("id", groupIds)
+	err := db.FetchModelObjects(GroupManager, q, &groups)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	ret := make([]string, 0, 2*len(groups))
+	for i := range groups {
+		ret = append(ret, groups[i].Id, groups[i].Name)
+	}
+	return ret, nil
+}
+
 func (manager *SUsergroupManager) getGroupUserIds(groupId string) []string {
 	members := make([]SUsergroupMembership, 0)
 	q := manager.Query().Equals("group_id", groupId)
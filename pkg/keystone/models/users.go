@@ -23,6 +23,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -95,6 +96,8 @@ type SUser struct {
 
 	AllowWebConsole tristate.TriState `nullable:"false" default:"true" list:"domain" update:"domain" create:"domain_optional"`
 	EnableMfa       tristate.TriState `nullable:"false" default:"false" list:"domain" update:"domain" create:"domain_optional"`
+	// MFA认证方式, 为空时使用所属域的设置
+	MfaMode string `width:"16" charset:"ascii" nullable:"true" list:"domain" update:"domain" create:"domain_optional"`
 
 	Lang string `width:"8" charset:"ascii" nullable:"false" list:"domain" update:"domain" create:"domain_optional"`
 }
@@ -482,6 +485,9 @@ func (manager *SUserManager) ValidateCreateData(
 			return input, errors.Wrap(err, "validatePasswordComplexity")
 		}
 	}
+	if input.MfaMode != nil && len(*input.MfaMode) > 0 && !utils.IsInStringArray(*input.MfaMode, api.MFA_MODES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_mode %s", *input.MfaMode)
+	}
 	input.EnabledIdentityBaseResourceCreateInput, err = manager.SEnabledIdentityBaseResourceManager.ValidateCreateData(ctx, userCred, ownerId, query, input.EnabledIdentityBaseResourceCreateInput)
 	if err != nil {
 		return input, errors.Wrap(err, "SEnabledIdentityBaseResourceManager.ValidateCreateData")
@@ -530,6 +536,12 @@ func (user *SUser) ValidateUpdateData(ctx context.Context, userCred mcclient.Tok
 			}
 		}
 	}
+	if len(input.Password) > 0 {
+		err := validateNotByAppCredential(userCred, "change password")
+		if err != nil {
+			return input, err
+		}
+	}
 	if len(input.Password) > 0 && (input.SkipPasswordComplexityCheck == nil || *input.SkipPasswordComplexityCheck == false) {
 		passwd := input.Password
 		usrExt, err := UserManager.FetchUserExtended(user.Id, "", "", "")
@@ -548,6 +560,9 @@ func (user *SUser) ValidateUpdateData(ctx context.Context, userCred mcclient.Tok
 			return input, httperrors.NewInputParameterError("invalid password: %s", err)
 		}
 	}
+	if input.MfaMode != nil && len(*input.MfaMode) > 0 && !utils.IsInStringArray(*input.MfaMode, api.MFA_MODES) {
+		return input, httperrors.NewInputParameterError("invalid mfa_mode %s", *input.MfaMode)
+	}
 	var err error
 	input.EnabledIdentityBaseUpdateInput, err = user.SEnabledIdentityBaseResource.ValidateUpdateData(ctx, userCred, query, input.EnabledIdentityBaseUpdateInput)
 	if err != nil {
@@ -662,6 +677,8 @@ func userExtra(user *SUser, out api.UserDetails) api.UserDetails {
 		out.IsLocal = false
 	}
 
+	out.MfaEnforced, out.EffectiveMfaMode = user.GetMfaSetting()
+
 	external, update, _ := user.getExternalResources()
 	if len(external) > 0 {
 		out.ExtResource = jsonutils.Marshal(external)
@@ -676,6 +693,29 @@ func userExtra(user *SUser, out api.UserDetails) api.UserDetails {
 	return out
 }
 
+// GetMfaSetting returns whether the user must pass the MFA authentication and
+// the MFA mode, a domain enabling MFA enforces it on all the users, the mode
+// of the user overrides the mode of the domain
+func (user *SUser) GetMfaSetting() (bool, string) {
+	enforced := user.EnableMfa.Bool()
+	mode := user.MfaMode
+	domain, err := DomainManager.FetchDomainById(user.DomainId)
+	if err != nil {
+		log.Errorf("FetchDomainById %s fail %s", user.DomainId, err)
+	} else {
+		if domain.EnableMfa.Bool() {
+			enforced = true
+		}
+		if len(mode) == 0 {
+			mode = domain.MfaMode
+		}
+	}
+	if len(mode) == 0 {
+		mode = api.MFA_MODE_TOTP
+	}
+	return enforced, mode
+}
+
 func (user *SUser) initLocalData(passwd string) error {
 	localUsr, err := LocalUserManager.register(user.Id, user.DomainId, user.Name)
 	if err != nil {
@@ -29,6 +29,8 @@ type SKeystoneOptions struct {
 	FernetKeyRepository    string `help:"fernet key repo directory" token:"key_repository" default:"/etc/yunion/keystone/fernet-keys"`
 	SetupCredentialKeys    bool   `help:"setup standalone fernet keys for credentials" token:"setup_credential_key" default:"false" json:",allowfalse"`
 
+	AppCredentialTokenExpirationSeconds int `default:"900" help:"expiration seconds of the tokens issued by application credentials, kept short since the services cache the verified tokens and the revocation of an application credential is only noticed by keystone"`
+
 	BootstrapAdminUserPassword string `help:"bootstreap sysadmin user password" default:"sysadmin"`
 	ResetAdminUserPassword     bool   `help:"reset sysadmin password if exists and this option is true" json:",allowfalse"`
 
@@ -0,0 +1,460 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
+// IFilter is a parsed SCIM filter expression, see RFC7644 section 3.4.2.2
+type IFilter interface {
+	Match(obj jsonutils.JSONObject) bool
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type sToken struct {
+	kind tokenKind
+	val  string
+}
+
+func tokenize(str string) ([]sToken, error) {
+	tokens := make([]sToken, 0)
+	for i := 0; i < len(str); {
+		c := str[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, sToken{kind: tokenLParen, val: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, sToken{kind: tokenRParen, val: ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, sToken{kind: tokenLBracket, val: "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, sToken{kind: tokenRBracket, val: "]"})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(str) && str[j] != '"'; j++ {
+				if str[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(str) {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "unterminated string at %d", i)
+			}
+			val, err := strconv.Unquote(str[i : j+1])
+			if err != nil {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "invalid string %s", str[i:j+1])
+			}
+			tokens = append(tokens, sToken{kind: tokenString, val: val})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(str) && !strings.ContainsRune(" \t\n\r()[]\"", rune(str[j])); j++ {
+			}
+			tokens = append(tokens, sToken{kind: tokenWord, val: str[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type sFilterParser struct {
+	tokens []sToken
+	pos    int
+}
+
+// ParseFilter parses the filter parameter of list requests and the
+// value filter of PATCH paths
+func ParseFilter(str string) (IFilter, error) {
+	tokens, err := tokenize(str)
+	if err != nil {
+		return nil, err
+	}
+	parser := &sFilterParser{tokens: tokens}
+	filter, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "unexpected %q", parser.tokens[parser.pos].val)
+	}
+	return filter, nil
+}
+
+func (p *sFilterParser) peek() *sToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *sFilterParser) next() (*sToken, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "unexpected end of filter")
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *sFilterParser) expect(kind tokenKind, val string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind != kind {
+		return newBadRequestError(SCIM_TYPE_INVALID_FILTER, "expect %q, got %q", val, tok.val)
+	}
+	return nil
+}
+
+func (p *sFilterParser) peekKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok != nil && tok.kind == tokenWord && strings.EqualFold(tok.val, keyword)
+}
+
+func (p *sFilterParser) parseOr() (IFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &sOrFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sFilterParser) parseAnd() (IFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &sAndFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sFilterParser) parseUnary() (IFilter, error) {
+	not := false
+	if p.peekKeyword("not") {
+		p.pos++
+		not = true
+	}
+	tok := p.peek()
+	if tok != nil && tok.kind == tokenLParen {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(tokenRParen, ")")
+		if err != nil {
+			return nil, err
+		}
+		if not {
+			return &sNotFilter{filter: filter}, nil
+		}
+		return filter, nil
+	}
+	if not {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "expect ( after not")
+	}
+	return p.parseAttrExpr()
+}
+
+func (p *sFilterParser) parseAttrExpr() (IFilter, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind != tokenWord {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "expect attribute, got %q", tok.val)
+	}
+	path := normalizeAttrPath(tok.val)
+
+	next := p.peek()
+	if next != nil && next.kind == tokenLBracket {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(tokenRBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return &sValuePathFilter{attr: path, filter: filter}, nil
+	}
+
+	opTok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opTok.val)
+	if opTok.kind != tokenWord {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "expect operator, got %q", opTok.val)
+	}
+	if op == "pr" {
+		return &sPresentFilter{path: path}, nil
+	}
+	switch op {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "unsupported operator %q", opTok.val)
+	}
+	valTok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	var value jsonutils.JSONObject
+	switch valTok.kind {
+	case tokenString:
+		value = jsonutils.NewString(valTok.val)
+	case tokenWord:
+		switch strings.ToLower(valTok.val) {
+		case "true":
+			value = jsonutils.JSONTrue
+		case "false":
+			value = jsonutils.JSONFalse
+		case "null":
+			value = jsonutils.JSONNull
+		default:
+			num, err := strconv.ParseFloat(valTok.val, 64)
+			if err != nil {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "invalid value %q", valTok.val)
+			}
+			value = jsonutils.NewFloat64(num)
+		}
+	default:
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_FILTER, "expect value, got %q", valTok.val)
+	}
+	return &sCompareFilter{path: path, op: op, value: value}, nil
+}
+
+// normalizeAttrPath strips the schema URN prefix of a fully qualified attribute,
+// e.g. urn:ietf:params:scim:schemas:core:2.0:User:name.givenName
+func normalizeAttrPath(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if idx := strings.LastIndex(path, ":"); idx >= 0 {
+			return path[idx+1:]
+		}
+	}
+	return path
+}
+
+func getAttr(obj jsonutils.JSONObject, attr string) (string, jsonutils.JSONObject) {
+	dict, ok := obj.(*jsonutils.JSONDict)
+	if !ok {
+		return "", nil
+	}
+	for k, v := range dict.Value() {
+		if strings.EqualFold(k, attr) {
+			return k, v
+		}
+	}
+	return "", nil
+}
+
+// resolveValues returns all the values of an attribute path, multi-valued
+// attributes are flattened
+func resolveValues(obj jsonutils.JSONObject, path string) []jsonutils.JSONObject {
+	current := []jsonutils.JSONObject{obj}
+	for _, seg := range strings.Split(path, ".") {
+		next := make([]jsonutils.JSONObject, 0)
+		for _, o := range current {
+			_, val := getAttr(o, seg)
+			if val == nil || val == jsonutils.JSONNull {
+				continue
+			}
+			if arr, ok := val.(*jsonutils.JSONArray); ok {
+				next = append(next, arr.Value()...)
+			} else {
+				next = append(next, val)
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+type sAndFilter struct {
+	left  IFilter
+	right IFilter
+}
+
+func (f *sAndFilter) Match(obj jsonutils.JSONObject) bool {
+	return f.left.Match(obj) && f.right.Match(obj)
+}
+
+type sOrFilter struct {
+	left  IFilter
+	right IFilter
+}
+
+func (f *sOrFilter) Match(obj jsonutils.JSONObject) bool {
+	return f.left.Match(obj) || f.right.Match(obj)
+}
+
+type sNotFilter struct {
+	filter IFilter
+}
+
+func (f *sNotFilter) Match(obj jsonutils.JSONObject) bool {
+	return !f.filter.Match(obj)
+}
+
+type sPresentFilter struct {
+	path string
+}
+
+func (f *sPresentFilter) Match(obj jsonutils.JSONObject) bool {
+	for _, val := range resolveValues(obj, f.path) {
+		if str, ok := val.(*jsonutils.JSONString); ok && len(str.Value()) == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// sValuePathFilter matches the elements of a multi-valued attribute,
+// e.g. emails[type eq "work" and value co "@example.com"]
+type sValuePathFilter struct {
+	attr   string
+	filter IFilter
+}
+
+func (f *sValuePathFilter) Match(obj jsonutils.JSONObject) bool {
+	for _, val := range resolveValues(obj, f.attr) {
+		if f.filter.Match(val) {
+			return true
+		}
+	}
+	return false
+}
+
+type sCompareFilter struct {
+	path  string
+	op    string
+	value jsonutils.JSONObject
+}
+
+func (f *sCompareFilter) Match(obj jsonutils.JSONObject) bool {
+	values := resolveValues(obj, f.path)
+	if f.op == "ne" {
+		return !(&sCompareFilter{path: f.path, op: "eq", value: f.value}).Match(obj)
+	}
+	if f.value == jsonutils.JSONNull {
+		return f.op == "eq" && len(values) == 0
+	}
+	for _, val := range values {
+		// the complex values are compared by their value sub-attribute
+		if _, ok := val.(*jsonutils.JSONDict); ok {
+			_, val = getAttr(val, "value")
+			if val == nil {
+				continue
+			}
+		}
+		if compareValue(f.op, val, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareValue(op string, actual jsonutils.JSONObject, expect jsonutils.JSONObject) bool {
+	switch ev := expect.(type) {
+	case *jsonutils.JSONBool:
+		av, ok := actual.(*jsonutils.JSONBool)
+		if !ok {
+			return false
+		}
+		return op == "eq" && av.Value() == ev.Value()
+	case *jsonutils.JSONFloat:
+		var av float64
+		switch v := actual.(type) {
+		case *jsonutils.JSONInt:
+			av = float64(v.Value())
+		case *jsonutils.JSONFloat:
+			av = v.Value()
+		default:
+			return false
+		}
+		return compareOrdered(op, av, ev.Value())
+	case *jsonutils.JSONString:
+		av, ok := actual.(*jsonutils.JSONString)
+		if !ok {
+			return false
+		}
+		// string attributes of the core schemas are case insensitive
+		a, e := strings.ToLower(av.Value()), strings.ToLower(ev.Value())
+		switch op {
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		default:
+			return compareOrdered(op, float64(strings.Compare(a, e)), 0)
+		}
+	}
+	return false
+}
+
+func compareOrdered(op string, a, b float64) bool {
+	switch op {
+	case "eq":
+		return a == b
+	case "gt":
+		return a > b
+	case "ge":
+		return a >= b
+	case "lt":
+		return a < b
+	case "le":
+		return a <= b
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestParseFilter(t *testing.T) {
+	user, _ := jsonutils.ParseString(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"id": "2819c223",
+		"userName": "Bjensen@example.com",
+		"name": {"familyName": "Jensen", "givenName": "Barbara"},
+		"active": true,
+		"emails": [
+			{"value": "bjensen@example.com", "type": "work", "primary": true},
+			{"value": "babs@jensen.org", "type": "home"}
+		],
+		"meta": {"lastModified": "2011-05-13T04:42:34Z"}
+	}`)
+	cases := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "bjensen@example.com"`, true},
+		{`username Eq "bjensen@example.com"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "bjen"`, true},
+		{`userName ne "bjensen@example.com"`, false},
+		{`name.familyName co "ens"`, true},
+		{`userName ew "example.org"`, false},
+		{`title pr`, false},
+		{`name pr and active eq true`, true},
+		{`active eq false`, false},
+		{`emails co "jensen.org"`, true},
+		{`emails[type eq "work" and value co "@example.com"]`, true},
+		{`emails[type eq "work" and value co "@jensen.org"]`, false},
+		{`emails.type eq "home"`, true},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`, false},
+		{`meta.lastModified ge "2011-05-13T04:42:34Z"`, true},
+		{`userName eq "x" or (name.givenName eq "Barbara" and not (active eq false))`, true},
+		{`externalId eq null`, true},
+		{`id ne null`, true},
+	}
+	for _, c := range cases {
+		filter, err := ParseFilter(c.filter)
+		if err != nil {
+			t.Errorf("ParseFilter %s: %s", c.filter, err)
+			continue
+		}
+		if got := filter.Match(user); got != c.want {
+			t.Errorf("%s: want %v, got %v", c.filter, c.want, got)
+		}
+	}
+
+	for _, str := range []string{
+		`userName`,
+		`userName eq`,
+		`userName xx "a"`,
+		`(userName eq "a"`,
+		`userName eq "a" and`,
+		`not userName eq "a"`,
+		`userName eq "a`,
+		`emails[type eq "work"`,
+	} {
+		_, err := ParseFilter(str)
+		if err == nil {
+			t.Errorf("invalid filter %s should fail", str)
+		} else if e, ok := err.(*SError); !ok || e.ScimType != SCIM_TYPE_INVALID_FILTER {
+			t.Errorf("%s: want invalidFilter, got %v", str, err)
+		}
+	}
+}
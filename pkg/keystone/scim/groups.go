@@ -0,0 +1,336 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/stringutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type sGroupInfo struct {
+	displayName string
+	externalId  string
+	memberIds   []string
+}
+
+func parseGroup(body jsonutils.JSONObject) (*sGroupInfo, error) {
+	input := SGroup{}
+	err := canonicalize(body).Unmarshal(&input)
+	if err != nil {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "invalid group: %s", err)
+	}
+	info := &sGroupInfo{
+		displayName: strings.TrimSpace(input.DisplayName),
+		externalId:  input.ExternalId,
+		memberIds:   make([]string, 0, len(input.Members)),
+	}
+	if len(info.displayName) == 0 {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "displayName is required")
+	}
+	for i := range input.Members {
+		if len(input.Members[i].Value) > 0 {
+			info.memberIds = append(info.memberIds, input.Members[i].Value)
+		}
+	}
+	return info, nil
+}
+
+// fetchGroupResources converts the groups with their external ids and members
+func fetchGroupResources(req *sRequest, groups []models.SGroup) ([]SGroup, error) {
+	extIds, err := req.idp.GetLinkedEntityIds(api.IdMappingEntityGroup)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLinkedEntityIds")
+	}
+	users, err := req.idp.GetLinkedUsers()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLinkedUsers")
+	}
+	userMap := make(map[string]*models.SUser, len(users))
+	for i := range users {
+		userMap[users[i].Id] = &users[i]
+	}
+	groupMembers := make(map[string][]SMultiValue)
+	if len(groups) > 0 {
+		groupIds := make([]string, len(groups))
+		for i := range groups {
+			groupIds[i] = groups[i].Id
+		}
+		members, err := models.UsergroupManager.FetchMemberships(nil, groupIds)
+		if err != nil {
+			return nil, errors.Wrap(err, "FetchMemberships")
+		}
+		for i := range members {
+			usr, ok := userMap[members[i].UserId]
+			if !ok {
+				continue
+			}
+			groupMembers[members[i].GroupId] = append(groupMembers[members[i].GroupId], SMultiValue{
+				Value:   usr.Id,
+				Display: usr.Name,
+				Ref:     req.location("Users", usr.Id),
+			})
+		}
+	}
+	ret := make([]SGroup, len(groups))
+	for i := range groups {
+		grp := &groups[i]
+		ret[i] = SGroup{
+			Schemas:     []string{SCHEMA_GROUP},
+			Id:          grp.Id,
+			ExternalId:  extIds[grp.Id],
+			DisplayName: grp.Name,
+			Members:     groupMembers[grp.Id],
+			Meta: &SMeta{
+				ResourceType: RESOURCE_TYPE_GROUP,
+				Created:      formatTime(grp.CreatedAt),
+				LastModified: formatTime(grp.UpdatedAt),
+				Location:     req.location("Groups", grp.Id),
+			},
+		}
+	}
+	return ret, nil
+}
+
+func fetchGroupResource(req *sRequest, grp *models.SGroup) (*SGroup, error) {
+	resources, err := fetchGroupResources(req, []models.SGroup{*grp})
+	if err != nil {
+		return nil, err
+	}
+	return &resources[0], nil
+}
+
+func fetchLinkedGroup(req *sRequest) (*models.SGroup, error) {
+	grp, err := req.idp.FetchLinkedGroup(req.id)
+	if err != nil {
+		return nil, newNotFoundError("group %s not found", req.id)
+	}
+	return grp, nil
+}
+
+func checkGroupNameUnique(domainId string, name string, excludeId string) error {
+	q := models.GroupManager.Query().Equals("domain_id", domainId).Equals("name", name)
+	if len(excludeId) > 0 {
+		q = q.NotEquals("id", excludeId)
+	}
+	cnt, err := q.CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "CountWithError")
+	}
+	if cnt > 0 {
+		return newConflictError("displayName %s already exists", name)
+	}
+	return nil
+}
+
+// syncGroupMembers only accepts the users provisioned to the identity provider
+func syncGroupMembers(ctx context.Context, req *sRequest, grp *models.SGroup, memberIds []string) error {
+	for _, uid := range memberIds {
+		_, err := req.idp.FetchLinkedUser(uid)
+		if err != nil {
+			return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "member %s not found", uid)
+		}
+	}
+	models.UsergroupManager.SyncGroupUsers(ctx, req.userCred(), grp.Id, memberIds)
+	return nil
+}
+
+func listGroups(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	groups, err := req.idp.GetLinkedGroups()
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "GetLinkedGroups")
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	resources, err := fetchGroupResources(req, groups)
+	if err != nil {
+		return 0, nil, err
+	}
+	objs := make([]interface{}, len(resources))
+	for i := range resources {
+		objs[i] = resources[i]
+	}
+	resp, err := paginate(req.query, objs)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resp, nil
+}
+
+func getGroup(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	grp, err := fetchLinkedGroup(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchGroupResource(req, grp)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+func createGroup(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	info, err := parseGroup(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	domain, err := req.idp.GetScimDomain(ctx)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "GetScimDomain")
+	}
+	extId := info.externalId
+	if len(extId) > 0 {
+		_, err = models.IdmappingManager.FetchByIdpAndEntityId(ctx, req.idp.Id, extId, api.IdMappingEntityGroup)
+		if err == nil {
+			return 0, nil, newConflictError("group %s already exists", extId)
+		}
+	} else {
+		extId = stringutils.UUID4()
+	}
+	err = checkGroupNameUnique(domain.Id, info.displayName, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	grp, err := models.GroupManager.RegisterExternalGroup(ctx, req.idp.Id, domain.Id, extId, info.displayName)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "RegisterExternalGroup")
+	}
+	db.OpsLog.LogEvent(grp, db.ACT_CREATE, grp.GetShortDesc(ctx), req.userCred())
+	logclient.AddSimpleActionLog(grp, logclient.ACT_CREATE, "scim", req.userCred(), true)
+	err = syncGroupMembers(ctx, req, grp, info.memberIds)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchGroupResource(req, grp)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, resource, nil
+}
+
+func updateGroup(ctx context.Context, req *sRequest, grp *models.SGroup, info *sGroupInfo) error {
+	if len(info.externalId) > 0 {
+		extIds, err := req.idp.GetLinkedEntityIds(api.IdMappingEntityGroup)
+		if err != nil {
+			return errors.Wrap(err, "GetLinkedEntityIds")
+		}
+		if extIds[grp.Id] != info.externalId {
+			return newBadRequestError(SCIM_TYPE_MUTABILITY, "externalId is immutable")
+		}
+	}
+	if info.displayName != grp.Name {
+		err := checkGroupNameUnique(grp.DomainId, info.displayName, grp.Id)
+		if err != nil {
+			return err
+		}
+		diff, err := db.Update(grp, func() error {
+			grp.Name = info.displayName
+			grp.Displayname = info.displayName
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "Update")
+		}
+		db.OpsLog.LogEvent(grp, db.ACT_UPDATE, diff, req.userCred())
+	}
+	return syncGroupMembers(ctx, req, grp, info.memberIds)
+}
+
+func replaceGroup(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	grp, err := fetchLinkedGroup(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := parseGroup(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = updateGroup(ctx, req, grp, info)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchGroupResource(req, grp)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+func patchGroup(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	grp, err := fetchLinkedGroup(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	ops, err := ParsePatchRequest(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchGroupResource(req, grp)
+	if err != nil {
+		return 0, nil, err
+	}
+	obj := jsonutils.Marshal(resource).(*jsonutils.JSONDict)
+	err = ApplyPatch(obj, ops)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := parseGroup(obj)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = updateGroup(ctx, req, grp, info)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err = fetchGroupResource(req, grp)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+func deleteGroup(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	grp, err := fetchLinkedGroup(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	models.UsergroupManager.SyncGroupUsers(ctx, req.userCred(), grp.Id, []string{})
+	err = grp.UnlinkIdp(req.idp.Id)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "UnlinkIdp")
+	}
+	err = grp.ValidateDeleteCondition(ctx)
+	if err != nil {
+		log.Warningf("scim deprovisioned group %s is kept: %s", grp.Name, err)
+		return http.StatusNoContent, nil, nil
+	}
+	err = grp.Delete(ctx, req.userCred())
+	if err != nil {
+		log.Errorf("delete scim deprovisioned group %s error: %s", grp.Name, err)
+	}
+	return http.StatusNoContent, nil, nil
+}
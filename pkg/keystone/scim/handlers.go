@@ -0,0 +1,257 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type sRequest struct {
+	idp     *models.SIdentityProvider
+	id      string
+	query   url.Values
+	body    jsonutils.JSONObject
+	baseUrl string
+}
+
+func (req *sRequest) userCred() mcclient.TokenCredential {
+	return models.GetDefaultAdminCred()
+}
+
+func (req *sRequest) location(resource string, id string) string {
+	return fmt.Sprintf("%s/%s/%s", req.baseUrl, resource, id)
+}
+
+type scimHandlerFunc func(ctx context.Context, req *sRequest) (int, interface{}, error)
+
+// AddHandler registers the SCIM 2.0 service of the identity providers, the
+// IdP pushes users and groups to /scim/v2/<idp_id> with the bearer token
+// generated by the enable-scim action of the identity provider
+func AddHandler(app *appsrv.Application) {
+	prefix := api.ScimServicePrefix + "/<idp_id>"
+	app.AddHandler2("GET", prefix+"/ServiceProviderConfig", scimHandler(getServiceProviderConfig), nil, "scim_service_provider_config", nil)
+	app.AddHandler2("GET", prefix+"/ResourceTypes", scimHandler(getResourceTypes), nil, "scim_resource_types", nil)
+
+	app.AddHandler2("GET", prefix+"/Users", scimHandler(listUsers), nil, "scim_list_users", nil)
+	app.AddHandler2("POST", prefix+"/Users", scimHandler(createUser), nil, "scim_create_user", nil)
+	app.AddHandler2("GET", prefix+"/Users/<id>", scimHandler(getUser), nil, "scim_get_user", nil)
+	app.AddHandler2("PUT", prefix+"/Users/<id>", scimHandler(replaceUser), nil, "scim_replace_user", nil)
+	app.AddHandler2("PATCH", prefix+"/Users/<id>", scimHandler(patchUser), nil, "scim_patch_user", nil)
+	app.AddHandler2("DELETE", prefix+"/Users/<id>", scimHandler(deleteUser), nil, "scim_delete_user", nil)
+
+	app.AddHandler2("GET", prefix+"/Groups", scimHandler(listGroups), nil, "scim_list_groups", nil)
+	app.AddHandler2("POST", prefix+"/Groups", scimHandler(createGroup), nil, "scim_create_group", nil)
+	app.AddHandler2("GET", prefix+"/Groups/<id>", scimHandler(getGroup), nil, "scim_get_group", nil)
+	app.AddHandler2("PUT", prefix+"/Groups/<id>", scimHandler(replaceGroup), nil, "scim_replace_group", nil)
+	app.AddHandler2("PATCH", prefix+"/Groups/<id>", scimHandler(patchGroup), nil, "scim_patch_group", nil)
+	app.AddHandler2("DELETE", prefix+"/Groups/<id>", scimHandler(deleteGroup), nil, "scim_delete_group", nil)
+}
+
+func fetchBearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func requestBaseUrl(r *http.Request, idpId string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s%s/%s", scheme, r.Host, api.ScimServicePrefix, idpId)
+}
+
+func scimHandler(f scimHandlerFunc) appsrv.FilterHandler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		params := appctx.AppContextParams(ctx)
+		idpId := params["<idp_id>"]
+		idp, err := models.IdentityProviderManager.FetchIdentityProviderById(idpId)
+		if err != nil || !idp.VerifyScimToken(fetchBearerToken(r)) {
+			// do not tell whether the identity provider exists
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			sendError(w, newError(http.StatusUnauthorized, "", "invalid bearer token"))
+			return
+		}
+		req := &sRequest{
+			idp:     idp,
+			id:      params["<id>"],
+			query:   r.URL.Query(),
+			baseUrl: requestBaseUrl(r, idp.Id),
+		}
+		if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
+			req.body, err = appsrv.FetchJSON(r)
+			if err != nil || req.body == nil {
+				sendError(w, newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "invalid request body"))
+				return
+			}
+		}
+		code, resp, err := f(ctx, req)
+		if err != nil {
+			sendError(w, err)
+			return
+		}
+		if resp == nil {
+			appsrv.SendNoContent(w)
+			return
+		}
+		sendResponse(w, code, resp)
+	}
+}
+
+func sendResponse(w http.ResponseWriter, code int, obj interface{}) {
+	body := []byte(jsonutils.Marshal(obj).String())
+	w.Header().Set("Content-Type", CONTENT_TYPE+";charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	scimErr, ok := errors.Cause(err).(*SError)
+	if !ok {
+		if errors.Cause(err) == sql.ErrNoRows {
+			scimErr = newNotFoundError("resource not found")
+		} else {
+			log.Errorf("scim request error: %s", err)
+			scimErr = newError(http.StatusInternalServerError, "", "%s", err)
+		}
+	}
+	sendResponse(w, scimErr.code, scimErr)
+}
+
+// parseListParams returns the 1-based start index and the page size
+func parseListParams(query url.Values) (IFilter, int, int, error) {
+	var filter IFilter
+	if str := query.Get("filter"); len(str) > 0 {
+		var err error
+		filter, err = ParseFilter(str)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	start, count := 1, DEFAULT_PAGE_SIZE
+	if str := query.Get("startIndex"); len(str) > 0 {
+		start, _ = strconv.Atoi(str)
+		if start < 1 {
+			start = 1
+		}
+	}
+	if str := query.Get("count"); len(str) > 0 {
+		count, _ = strconv.Atoi(str)
+		if count < 0 {
+			count = 0
+		} else if count > MAX_PAGE_SIZE {
+			count = MAX_PAGE_SIZE
+		}
+	}
+	return filter, start, count, nil
+}
+
+// paginate filters the resources in memory and returns the requested page
+func paginate(query url.Values, resources []interface{}) (*SListResponse, error) {
+	filter, start, count, err := parseListParams(query)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]interface{}, 0, len(resources))
+	for i := range resources {
+		if filter == nil || filter.Match(jsonutils.Marshal(resources[i])) {
+			matched = append(matched, resources[i])
+		}
+	}
+	resp := &SListResponse{
+		Schemas:      []string{SCHEMA_LIST_RESPONSE},
+		TotalResults: len(matched),
+		StartIndex:   start,
+		Resources:    []interface{}{},
+	}
+	if start <= len(matched) {
+		end := start - 1 + count
+		if end > len(matched) {
+			end = len(matched)
+		}
+		resp.Resources = matched[start-1 : end]
+	}
+	resp.ItemsPerPage = len(resp.Resources)
+	return resp, nil
+}
+
+func getServiceProviderConfig(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	conf := SServiceProviderConfig{
+		Schemas: []string{SCHEMA_SERVICE_PROVIDER_CONFIG},
+		Patch:   SSupported{Supported: true},
+		Filter: SFilterConfig{
+			Supported:  true,
+			MaxResults: MAX_PAGE_SIZE,
+		},
+		AuthenticationSchemes: []SAuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "OAuth Bearer Token",
+				Description: "Authentication with the token generated by enabling SCIM of the identity provider",
+				Primary:     true,
+			},
+		},
+	}
+	return http.StatusOK, conf, nil
+}
+
+func getResourceTypes(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	types := []interface{}{
+		SResourceType{
+			Schemas:  []string{SCHEMA_RESOURCE_TYPE},
+			Id:       RESOURCE_TYPE_USER,
+			Name:     RESOURCE_TYPE_USER,
+			Endpoint: "/Users",
+			Schema:   SCHEMA_USER,
+		},
+		SResourceType{
+			Schemas:  []string{SCHEMA_RESOURCE_TYPE},
+			Id:       RESOURCE_TYPE_GROUP,
+			Name:     RESOURCE_TYPE_GROUP,
+			Endpoint: "/Groups",
+			Schema:   SCHEMA_GROUP,
+		},
+	}
+	resp := &SListResponse{
+		Schemas:      []string{SCHEMA_LIST_RESPONSE},
+		TotalResults: len(types),
+		StartIndex:   1,
+		ItemsPerPage: len(types),
+		Resources:    types,
+	}
+	return http.StatusOK, resp, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"yunion.io/x/pkg/tristate"
+
+	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/dbtest"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+)
+
+// the tokens are kept hashed by the identity providers
+func scimTokenHashForTest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestScimHandler_BearerToken(t *testing.T) {
+	cleanup := dbtest.SetupSqliteDB(t, models.IdentityProviderManager.TableSpec())
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, idp := range []struct {
+		id          string
+		enabled     bool
+		scimEnabled bool
+	}{
+		{id: "okta", enabled: true, scimEnabled: true},
+		{id: "disabled", enabled: false, scimEnabled: true},
+		{id: "noscim", enabled: true, scimEnabled: false},
+	} {
+		obj := &models.SIdentityProvider{}
+		obj.Id = idp.id
+		obj.Name = idp.id
+		obj.Driver = "saml"
+		obj.Enabled = tristate.NewFromBool(idp.enabled)
+		obj.ScimEnabled = tristate.NewFromBool(idp.scimEnabled)
+		obj.ScimTokenHash = scimTokenHashForTest(idp.id + "-token")
+		obj.SetModelManager(models.IdentityProviderManager, obj)
+		if err := models.IdentityProviderManager.TableSpec().Insert(ctx, obj); err != nil {
+			t.Fatalf("insert idp %s: %v", idp.id, err)
+		}
+	}
+
+	var served *sRequest
+	handler := scimHandler(func(ctx context.Context, req *sRequest) (int, interface{}, error) {
+		served = req
+		return http.StatusOK, map[string]string{"id": req.id}, nil
+	})
+	cases := []struct {
+		name  string
+		idpId string
+		auth  string
+		want  int
+	}{
+		{name: "valid token", idpId: "okta", auth: "Bearer okta-token", want: http.StatusOK},
+		{name: "lower case scheme", idpId: "okta", auth: "bearer okta-token", want: http.StatusOK},
+		{name: "no token", idpId: "okta", auth: "", want: http.StatusUnauthorized},
+		{name: "basic auth", idpId: "okta", auth: "Basic okta-token", want: http.StatusUnauthorized},
+		{name: "wrong token", idpId: "okta", auth: "Bearer wrong-token", want: http.StatusUnauthorized},
+		{name: "token of another idp", idpId: "okta", auth: "Bearer noscim-token", want: http.StatusUnauthorized},
+		{name: "wrong idp", idpId: "unknown", auth: "Bearer okta-token", want: http.StatusUnauthorized},
+		{name: "disabled idp", idpId: "disabled", auth: "Bearer disabled-token", want: http.StatusUnauthorized},
+		{name: "scim disabled", idpId: "noscim", auth: "Bearer noscim-token", want: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		served = nil
+		r := httptest.NewRequest("GET", "/scim/v2/"+c.idpId+"/Users/u1", nil)
+		if len(c.auth) > 0 {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		params := map[string]string{"<idp_id>": c.idpId, "<id>": "u1"}
+		handler(context.WithValue(ctx, appctx.APP_CONTEXT_KEY_PARAMS, params), w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: want status %d got %d", c.name, c.want, w.Code)
+		}
+		if c.want != http.StatusOK {
+			if served != nil {
+				t.Errorf("%s: the request should not be served", c.name)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s: missing WWW-Authenticate", c.name)
+			}
+		} else if served == nil || served.idp.Id != c.idpId || served.id != "u1" {
+			t.Errorf("%s: the request is not served for idp %s", c.name, c.idpId)
+		}
+	}
+}
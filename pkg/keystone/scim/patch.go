@@ -0,0 +1,321 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
+const (
+	PATCH_OP_ADD     = "add"
+	PATCH_OP_REPLACE = "replace"
+	PATCH_OP_REMOVE  = "remove"
+)
+
+type SPatchOperation struct {
+	Op    string
+	Path  string
+	Value jsonutils.JSONObject
+}
+
+// sPatchPath is the parsed path of a PATCH operation, see RFC7644 section 3.5.2,
+// e.g. members, name.givenName, emails[type eq "work"].value
+type sPatchPath struct {
+	attr   string
+	filter IFilter
+	sub    string
+}
+
+func parsePatchPath(path string) (*sPatchPath, error) {
+	ret := &sPatchPath{}
+	if idx := strings.Index(path, "["); idx >= 0 {
+		end := strings.LastIndex(path, "]")
+		if end < idx {
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %s", path)
+		}
+		filter, err := ParseFilter(path[idx+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		ret.attr = normalizeAttrPath(path[:idx])
+		ret.filter = filter
+		rest := path[end+1:]
+		if len(rest) > 0 {
+			if rest[0] != '.' || len(rest) == 1 {
+				return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %s", path)
+			}
+			ret.sub = rest[1:]
+		}
+	} else {
+		if strings.ContainsAny(path, "] ") {
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %s", path)
+		}
+		attr := normalizeAttrPath(path)
+		if idx := strings.Index(attr, "."); idx >= 0 {
+			ret.attr, ret.sub = attr[:idx], attr[idx+1:]
+		} else {
+			ret.attr = attr
+		}
+	}
+	if len(ret.attr) == 0 || strings.Contains(ret.sub, ".") {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_PATH, "invalid path %s", path)
+	}
+	return ret, nil
+}
+
+// ParsePatchRequest parses the body of a PATCH request
+func ParsePatchRequest(body jsonutils.JSONObject) ([]SPatchOperation, error) {
+	_, opsJson := getAttr(body, "Operations")
+	opsArray, ok := opsJson.(*jsonutils.JSONArray)
+	if !ok {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "missing Operations")
+	}
+	ops := make([]SPatchOperation, 0, opsArray.Length())
+	for _, opJson := range opsArray.Value() {
+		op := SPatchOperation{}
+		_, v := getAttr(opJson, "op")
+		if v != nil {
+			op.Op, _ = v.GetString()
+		}
+		op.Op = strings.ToLower(op.Op)
+		switch op.Op {
+		case PATCH_OP_ADD, PATCH_OP_REPLACE, PATCH_OP_REMOVE:
+		default:
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "invalid op %q", op.Op)
+		}
+		_, v = getAttr(opJson, "path")
+		if v != nil {
+			op.Path, _ = v.GetString()
+		}
+		_, op.Value = getAttr(opJson, "value")
+		if op.Op != PATCH_OP_REMOVE && op.Value == nil {
+			return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "missing value of %s", op.Op)
+		}
+		if op.Op == PATCH_OP_REMOVE && len(op.Path) == 0 {
+			return nil, newError(400, SCIM_TYPE_NO_TARGET, "path is required by remove")
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// ApplyPatch applies the operations to the JSON representation of a resource
+func ApplyPatch(obj *jsonutils.JSONDict, ops []SPatchOperation) error {
+	for i := range ops {
+		err := applyOperation(obj, ops[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(obj *jsonutils.JSONDict, op SPatchOperation) error {
+	if op.Op == PATCH_OP_REMOVE {
+		path, err := parsePatchPath(op.Path)
+		if err != nil {
+			return err
+		}
+		return applyRemove(obj, path, op.Value)
+	}
+	replace := op.Op == PATCH_OP_REPLACE
+	if len(op.Path) == 0 {
+		// the attributes of the value are merged into the resource,
+		// the keys of the value could be paths as well
+		dict, ok := op.Value.(*jsonutils.JSONDict)
+		if !ok {
+			return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "value must be an object when path is absent")
+		}
+		for k, v := range dict.Value() {
+			path, err := parsePatchPath(k)
+			if err != nil {
+				return err
+			}
+			err = applyValue(obj, path, v, replace)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	path, err := parsePatchPath(op.Path)
+	if err != nil {
+		return err
+	}
+	return applyValue(obj, path, op.Value, replace)
+}
+
+func applyValue(obj *jsonutils.JSONDict, path *sPatchPath, value jsonutils.JSONObject, replace bool) error {
+	key, current := getAttr(obj, path.attr)
+	if len(key) == 0 {
+		key = path.attr
+	}
+	if path.filter == nil {
+		if len(path.sub) == 0 {
+			setAttr(obj, path.attr, value, replace)
+			return nil
+		}
+		parent, ok := current.(*jsonutils.JSONDict)
+		if !ok {
+			if _, isArray := current.(*jsonutils.JSONArray); isArray {
+				return newBadRequestError(SCIM_TYPE_INVALID_PATH, "%s is multi-valued", path.attr)
+			}
+			parent = jsonutils.NewDict()
+			obj.Set(key, parent)
+		}
+		setAttr(parent, path.sub, value, replace)
+		return nil
+	}
+
+	elems := make([]jsonutils.JSONObject, 0)
+	if arr, ok := current.(*jsonutils.JSONArray); ok {
+		elems = arr.Value()
+	}
+	matched := false
+	for _, elem := range elems {
+		dict, ok := elem.(*jsonutils.JSONDict)
+		if !ok || !path.filter.Match(elem) {
+			continue
+		}
+		matched = true
+		if len(path.sub) > 0 {
+			setAttr(dict, path.sub, value, true)
+		} else if v, ok := value.(*jsonutils.JSONDict); ok {
+			dict.Update(v)
+		} else {
+			return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "value of %s must be an object", path.attr)
+		}
+	}
+	if matched {
+		return nil
+	}
+	// create the element when the filter is a simple equality,
+	// e.g. emails[type eq "work"].value
+	cmp, ok := path.filter.(*sCompareFilter)
+	if !ok || cmp.op != "eq" || strings.Contains(cmp.path, ".") {
+		return newError(400, SCIM_TYPE_NO_TARGET, "no element of %s matches the filter", path.attr)
+	}
+	elem := jsonutils.NewDict()
+	elem.Set(cmp.path, cmp.value)
+	if len(path.sub) > 0 {
+		elem.Set(path.sub, value)
+	} else if v, ok := value.(*jsonutils.JSONDict); ok {
+		elem.Update(v)
+	} else {
+		return newBadRequestError(SCIM_TYPE_INVALID_VALUE, "value of %s must be an object", path.attr)
+	}
+	obj.Set(key, jsonutils.NewArray(append(elems, elem)...))
+	return nil
+}
+
+// setAttr sets the attribute of obj, the values are appended to a
+// multi-valued attribute when adding
+func setAttr(obj *jsonutils.JSONDict, attr string, value jsonutils.JSONObject, replace bool) {
+	key, current := getAttr(obj, attr)
+	if len(key) == 0 {
+		key = attr
+	}
+	arr, isArray := current.(*jsonutils.JSONArray)
+	if replace || !isArray {
+		obj.Set(key, value)
+		return
+	}
+	elems := arr.Value()
+	newElems := []jsonutils.JSONObject{value}
+	if v, ok := value.(*jsonutils.JSONArray); ok {
+		newElems = v.Value()
+	}
+	for _, elem := range newElems {
+		if indexOfValue(elems, elem) < 0 {
+			elems = append(elems, elem)
+		}
+	}
+	obj.Set(key, jsonutils.NewArray(elems...))
+}
+
+func applyRemove(obj *jsonutils.JSONDict, path *sPatchPath, value jsonutils.JSONObject) error {
+	key, current := getAttr(obj, path.attr)
+	if current == nil {
+		return nil
+	}
+	arr, isArray := current.(*jsonutils.JSONArray)
+	if path.filter == nil {
+		if len(path.sub) > 0 {
+			if isArray {
+				for _, elem := range arr.Value() {
+					if dict, ok := elem.(*jsonutils.JSONDict); ok {
+						dict.RemoveIgnoreCase(path.sub)
+					}
+				}
+			} else if dict, ok := current.(*jsonutils.JSONDict); ok {
+				dict.RemoveIgnoreCase(path.sub)
+			}
+			return nil
+		}
+		if !isArray || value == nil {
+			obj.Remove(key)
+			return nil
+		}
+		// remove the given values from a multi-valued attribute,
+		// e.g. {"op":"remove","path":"members","value":[{"value":"<id>"}]}
+		removes := []jsonutils.JSONObject{value}
+		if v, ok := value.(*jsonutils.JSONArray); ok {
+			removes = v.Value()
+		}
+		elems := make([]jsonutils.JSONObject, 0)
+		for _, elem := range arr.Value() {
+			if indexOfValue(removes, elem) < 0 {
+				elems = append(elems, elem)
+			}
+		}
+		obj.Set(key, jsonutils.NewArray(elems...))
+		return nil
+	}
+	if !isArray {
+		return nil
+	}
+	elems := make([]jsonutils.JSONObject, 0)
+	for _, elem := range arr.Value() {
+		dict, ok := elem.(*jsonutils.JSONDict)
+		if !ok || !path.filter.Match(elem) {
+			elems = append(elems, elem)
+			continue
+		}
+		if len(path.sub) > 0 {
+			dict.RemoveIgnoreCase(path.sub)
+			elems = append(elems, elem)
+		}
+	}
+	obj.Set(key, jsonutils.NewArray(elems...))
+	return nil
+}
+
+// indexOfValue compares the complex values by their value sub-attribute
+func indexOfValue(elems []jsonutils.JSONObject, target jsonutils.JSONObject) int {
+	_, tv := getAttr(target, "value")
+	for i, elem := range elems {
+		_, ev := getAttr(elem, "value")
+		if tv != nil && ev != nil {
+			if tv.String() == ev.String() {
+				return i
+			}
+		} else if elem.String() == target.String() {
+			return i
+		}
+	}
+	return -1
+}
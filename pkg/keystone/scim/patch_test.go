@@ -0,0 +1,178 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func applyPatchString(t *testing.T, resource string, patch string) (*jsonutils.JSONDict, error) {
+	obj, err := jsonutils.ParseString(resource)
+	if err != nil {
+		t.Fatalf("parse resource %s", err)
+	}
+	body, err := jsonutils.ParseString(patch)
+	if err != nil {
+		t.Fatalf("parse patch %s", err)
+	}
+	ops, err := ParsePatchRequest(body)
+	if err != nil {
+		return nil, err
+	}
+	dict := obj.(*jsonutils.JSONDict)
+	return dict, ApplyPatch(dict, ops)
+}
+
+func TestApplyPatchUser(t *testing.T) {
+	resource := `{"userName":"bob","active":true,"emails":[{"value":"bob@example.com","type":"work","primary":true}]}`
+	cases := []struct {
+		name  string
+		patch string
+		check func(obj *jsonutils.JSONDict) bool
+	}{
+		{
+			name:  "okta deactivate",
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","value":{"active":false}}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				user := SUser{}
+				obj.Unmarshal(&user)
+				return user.Active != nil && !*user.Active
+			},
+		},
+		{
+			name:  "azure deactivate",
+			patch: `{"Operations":[{"op":"Replace","path":"active","value":"False"}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				user := SUser{}
+				obj.Unmarshal(&user)
+				return user.Active != nil && !*user.Active
+			},
+		},
+		{
+			name:  "replace email by filter",
+			patch: `{"Operations":[{"op":"replace","path":"emails[type eq \"work\"].value","value":"robert@example.com"}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				user := SUser{}
+				obj.Unmarshal(&user)
+				return len(user.Emails) == 1 && user.Emails[0].Value == "robert@example.com"
+			},
+		},
+		{
+			name:  "add element by filter",
+			patch: `{"Operations":[{"op":"add","path":"phoneNumbers[type eq \"mobile\"].value","value":"+1 555 0100"}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				user := SUser{}
+				obj.Unmarshal(&user)
+				return len(user.PhoneNumbers) == 1 && user.PhoneNumbers[0].Value == "+1 555 0100" && user.PhoneNumbers[0].Type == "mobile"
+			},
+		},
+		{
+			name:  "sub attribute path as value key",
+			patch: `{"Operations":[{"op":"add","value":{"name.givenName":"Robert","displayName":"Robert"}}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				given, _ := obj.GetString("name", "givenName")
+				display, _ := obj.GetString("displayName")
+				return given == "Robert" && display == "Robert"
+			},
+		},
+		{
+			name:  "remove emails",
+			patch: `{"Operations":[{"op":"remove","path":"emails[type eq \"work\"]"}]}`,
+			check: func(obj *jsonutils.JSONDict) bool {
+				emails, _ := obj.GetArray("emails")
+				return len(emails) == 0
+			},
+		},
+	}
+	for _, c := range cases {
+		obj, err := applyPatchString(t, resource, c.patch)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !c.check(obj) {
+			t.Errorf("%s: unexpected result %s", c.name, obj)
+		}
+	}
+}
+
+func TestApplyPatchGroupMembers(t *testing.T) {
+	resource := `{"displayName":"dev","members":[{"value":"u1"},{"value":"u2"}]}`
+	memberIds := func(obj *jsonutils.JSONDict) []string {
+		group := SGroup{}
+		obj.Unmarshal(&group)
+		ids := make([]string, len(group.Members))
+		for i := range group.Members {
+			ids[i] = group.Members[i].Value
+		}
+		return ids
+	}
+	cases := []struct {
+		name  string
+		patch string
+		want  []string
+	}{
+		{
+			name:  "add members",
+			patch: `{"Operations":[{"op":"add","path":"members","value":[{"value":"u2"},{"value":"u3"}]}]}`,
+			want:  []string{"u1", "u2", "u3"},
+		},
+		{
+			name:  "remove member by filter",
+			patch: `{"Operations":[{"op":"remove","path":"members[value eq \"u1\"]"}]}`,
+			want:  []string{"u2"},
+		},
+		{
+			name:  "remove members by value",
+			patch: `{"Operations":[{"op":"Remove","path":"members","value":[{"value":"u2"}]}]}`,
+			want:  []string{"u1"},
+		},
+		{
+			name:  "replace members",
+			patch: `{"Operations":[{"op":"replace","path":"members","value":[{"value":"u4"}]}]}`,
+			want:  []string{"u4"},
+		},
+		{
+			name:  "remove all members",
+			patch: `{"Operations":[{"op":"remove","path":"members"}]}`,
+			want:  []string{},
+		},
+	}
+	for _, c := range cases {
+		obj, err := applyPatchString(t, resource, c.patch)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		got := memberIds(obj)
+		if jsonutils.Marshal(got).String() != jsonutils.Marshal(c.want).String() {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	for _, patch := range []string{
+		`{"Operations":[{"op":"move","path":"members"}]}`,
+		`{"Operations":[{"op":"remove"}]}`,
+		`{"Operations":[{"op":"add","path":"members"}]}`,
+		`{"Operations":[{"op":"replace","path":"members[value sw \"x\"].display","value":"x"}]}`,
+		`{"Operations":[{"op":"replace","path":"displayName]","value":"x"}]}`,
+	} {
+		if _, err := applyPatchString(t, resource, patch); err == nil {
+			t.Errorf("patch %s should fail", patch)
+		}
+	}
+}
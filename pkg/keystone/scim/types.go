@@ -0,0 +1,228 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
+// https://tools.ietf.org/html/rfc7643
+// https://tools.ietf.org/html/rfc7644
+
+const (
+	SCHEMA_USER                    = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCHEMA_GROUP                   = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCHEMA_SERVICE_PROVIDER_CONFIG = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCHEMA_RESOURCE_TYPE           = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCHEMA_LIST_RESPONSE           = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCHEMA_PATCH_OP                = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCHEMA_ERROR                   = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	CONTENT_TYPE = "application/scim+json"
+
+	RESOURCE_TYPE_USER  = "User"
+	RESOURCE_TYPE_GROUP = "Group"
+
+	DEFAULT_PAGE_SIZE = 100
+	MAX_PAGE_SIZE     = 1000
+)
+
+const (
+	SCIM_TYPE_INVALID_FILTER = "invalidFilter"
+	SCIM_TYPE_INVALID_PATH   = "invalidPath"
+	SCIM_TYPE_INVALID_SYNTAX = "invalidSyntax"
+	SCIM_TYPE_INVALID_VALUE  = "invalidValue"
+	SCIM_TYPE_NO_TARGET      = "noTarget"
+	SCIM_TYPE_MUTABILITY     = "mutability"
+	SCIM_TYPE_UNIQUENESS     = "uniqueness"
+)
+
+type SMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type SName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// SMultiValue is an element of the multi-valued attributes, e.g. emails, members
+type SMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SUser struct {
+	Schemas      []string      `json:"schemas"`
+	Id           string        `json:"id,omitempty"`
+	ExternalId   string        `json:"externalId,omitempty"`
+	UserName     string        `json:"userName"`
+	Name         *SName        `json:"name,omitempty"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	Active       *bool         `json:"active,omitempty"`
+	Emails       []SMultiValue `json:"emails,omitempty"`
+	PhoneNumbers []SMultiValue `json:"phoneNumbers,omitempty"`
+	// readonly, the memberships are managed through the Groups
+	Groups []SMultiValue `json:"groups,omitempty"`
+	Meta   *SMeta        `json:"meta,omitempty"`
+}
+
+type SGroup struct {
+	Schemas     []string      `json:"schemas"`
+	Id          string        `json:"id,omitempty"`
+	ExternalId  string        `json:"externalId,omitempty"`
+	DisplayName string        `json:"displayName"`
+	Members     []SMultiValue `json:"members,omitempty"`
+	Meta        *SMeta        `json:"meta,omitempty"`
+}
+
+type SListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults,allowempty"`
+	StartIndex   int           `json:"startIndex,allowempty"`
+	ItemsPerPage int           `json:"itemsPerPage,allowempty"`
+	Resources    []interface{} `json:"Resources,allowempty"`
+}
+
+type SSupported struct {
+	Supported bool `json:"supported,allowempty"`
+}
+
+type SBulkConfig struct {
+	Supported      bool `json:"supported,allowempty"`
+	MaxOperations  int  `json:"maxOperations,allowempty"`
+	MaxPayloadSize int  `json:"maxPayloadSize,allowempty"`
+}
+
+type SFilterConfig struct {
+	Supported  bool `json:"supported,allowempty"`
+	MaxResults int  `json:"maxResults,allowempty"`
+}
+
+type SAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+type SServiceProviderConfig struct {
+	Schemas               []string                `json:"schemas"`
+	Patch                 SSupported              `json:"patch"`
+	Bulk                  SBulkConfig             `json:"bulk"`
+	Filter                SFilterConfig           `json:"filter"`
+	ChangePassword        SSupported              `json:"changePassword"`
+	Sort                  SSupported              `json:"sort"`
+	Etag                  SSupported              `json:"etag"`
+	AuthenticationSchemes []SAuthenticationScheme `json:"authenticationSchemes"`
+}
+
+type SResourceType struct {
+	Schemas  []string `json:"schemas"`
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	Endpoint string   `json:"endpoint"`
+	Schema   string   `json:"schema"`
+}
+
+// SError is the error response of SCIM, the status is a string as required by RFC7644
+type SError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+
+	code int
+}
+
+func (e *SError) Error() string {
+	if len(e.ScimType) > 0 {
+		return fmt.Sprintf("%s %s: %s", e.Status, e.ScimType, e.Detail)
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Detail)
+}
+
+func newError(code int, scimType string, msg string, params ...interface{}) *SError {
+	if len(params) > 0 {
+		msg = fmt.Sprintf(msg, params...)
+	}
+	return &SError{
+		Schemas:  []string{SCHEMA_ERROR},
+		Status:   fmt.Sprintf("%d", code),
+		ScimType: scimType,
+		Detail:   msg,
+		code:     code,
+	}
+}
+
+func newBadRequestError(scimType string, msg string, params ...interface{}) *SError {
+	return newError(http.StatusBadRequest, scimType, msg, params...)
+}
+
+func newNotFoundError(msg string, params ...interface{}) *SError {
+	return newError(http.StatusNotFound, "", msg, params...)
+}
+
+func newConflictError(msg string, params ...interface{}) *SError {
+	return newError(http.StatusConflict, SCIM_TYPE_UNIQUENESS, msg, params...)
+}
+
+var canonicalAttrs = map[string]string{}
+
+func init() {
+	for _, attr := range []string{
+		"schemas", "id", "externalId", "meta",
+		"userName", "name", "formatted", "familyName", "givenName", "displayName",
+		"active", "emails", "phoneNumbers", "groups", "members",
+		"value", "display", "type", "primary", "$ref",
+	} {
+		canonicalAttrs[strings.ToLower(attr)] = attr
+	}
+}
+
+// canonicalize renames the attributes to their canonical names, since
+// the attribute names of SCIM are case insensitive
+func canonicalize(obj jsonutils.JSONObject) jsonutils.JSONObject {
+	switch v := obj.(type) {
+	case *jsonutils.JSONDict:
+		ret := jsonutils.NewDict()
+		for k, val := range v.Value() {
+			if attr, ok := canonicalAttrs[strings.ToLower(k)]; ok {
+				k = attr
+			}
+			ret.Set(k, canonicalize(val))
+		}
+		return ret
+	case *jsonutils.JSONArray:
+		elems := v.Value()
+		ret := make([]jsonutils.JSONObject, len(elems))
+		for i := range elems {
+			ret[i] = canonicalize(elems[i])
+		}
+		return jsonutils.NewArray(ret...)
+	}
+	return obj
+}
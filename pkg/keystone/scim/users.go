@@ -0,0 +1,405 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scim
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/keystone/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+const (
+	maxMobileLength = 20
+)
+
+func formatTime(tm time.Time) string {
+	if tm.IsZero() {
+		return ""
+	}
+	return tm.UTC().Format(time.RFC3339)
+}
+
+// sUserInfo is the user attributes stored by keystone
+type sUserInfo struct {
+	userName    string
+	externalId  string
+	displayName string
+	email       string
+	mobile      string
+	active      bool
+}
+
+func primaryValue(values []SMultiValue) string {
+	for i := range values {
+		if values[i].Primary {
+			return values[i].Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+func parseUser(body jsonutils.JSONObject) (*sUserInfo, error) {
+	input := SUser{}
+	err := canonicalize(body).Unmarshal(&input)
+	if err != nil {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_SYNTAX, "invalid user: %s", err)
+	}
+	info := &sUserInfo{
+		userName:    strings.TrimSpace(input.UserName),
+		externalId:  input.ExternalId,
+		displayName: input.DisplayName,
+		email:       primaryValue(input.Emails),
+		mobile:      primaryValue(input.PhoneNumbers),
+		active:      input.Active == nil || *input.Active,
+	}
+	if len(info.userName) == 0 {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "userName is required")
+	}
+	if len(info.displayName) == 0 && input.Name != nil {
+		info.displayName = input.Name.Formatted
+		if len(info.displayName) == 0 {
+			info.displayName = strings.TrimSpace(input.Name.GivenName + " " + input.Name.FamilyName)
+		}
+	}
+	if len(info.mobile) > maxMobileLength {
+		return nil, newBadRequestError(SCIM_TYPE_INVALID_VALUE, "phone number %s too long", info.mobile)
+	}
+	return info, nil
+}
+
+func userToScim(req *sRequest, usr *models.SUser, externalId string, groups []SMultiValue) SUser {
+	active := usr.Enabled.Bool()
+	ret := SUser{
+		Schemas:     []string{SCHEMA_USER},
+		Id:          usr.Id,
+		ExternalId:  externalId,
+		UserName:    usr.Name,
+		DisplayName: usr.Displayname,
+		Active:      &active,
+		Groups:      groups,
+		Meta: &SMeta{
+			ResourceType: RESOURCE_TYPE_USER,
+			Created:      formatTime(usr.CreatedAt),
+			LastModified: formatTime(usr.UpdatedAt),
+			Location:     req.location("Users", usr.Id),
+		},
+	}
+	if len(usr.Displayname) > 0 {
+		ret.Name = &SName{Formatted: usr.Displayname}
+	}
+	if len(usr.Email) > 0 {
+		ret.Emails = []SMultiValue{{Value: usr.Email, Type: "work", Primary: true}}
+	}
+	if len(usr.Mobile) > 0 {
+		ret.PhoneNumbers = []SMultiValue{{Value: usr.Mobile, Type: "mobile", Primary: true}}
+	}
+	return ret
+}
+
+// fetchUserResources converts the users with their external ids and the
+// groups of the identity provider they belong to
+func fetchUserResources(req *sRequest, users []models.SUser) ([]SUser, error) {
+	extIds, err := req.idp.GetLinkedEntityIds(api.IdMappingEntityUser)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLinkedEntityIds")
+	}
+	groups, err := req.idp.GetLinkedGroups()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLinkedGroups")
+	}
+	userGroups := make(map[string][]SMultiValue)
+	if len(groups) > 0 {
+		groupMap := make(map[string]*models.SGroup, len(groups))
+		groupIds := make([]string, len(groups))
+		for i := range groups {
+			groupMap[groups[i].Id] = &groups[i]
+			groupIds[i] = groups[i].Id
+		}
+		var userIds []string
+		if len(users) == 1 {
+			userIds = []string{users[0].Id}
+		}
+		members, err := models.UsergroupManager.FetchMemberships(userIds, groupIds)
+		if err != nil {
+			return nil, errors.Wrap(err, "FetchMemberships")
+		}
+		for i := range members {
+			grp := groupMap[members[i].GroupId]
+			userGroups[members[i].UserId] = append(userGroups[members[i].UserId], SMultiValue{
+				Value:   grp.Id,
+				Display: grp.Name,
+				Ref:     req.location("Groups", grp.Id),
+			})
+		}
+	}
+	ret := make([]SUser, len(users))
+	for i := range users {
+		ret[i] = userToScim(req, &users[i], extIds[users[i].Id], userGroups[users[i].Id])
+	}
+	return ret, nil
+}
+
+func fetchUserResource(req *sRequest, usr *models.SUser) (*SUser, error) {
+	resources, err := fetchUserResources(req, []models.SUser{*usr})
+	if err != nil {
+		return nil, err
+	}
+	return &resources[0], nil
+}
+
+func fetchLinkedUser(req *sRequest) (*models.SUser, error) {
+	usr, err := req.idp.FetchLinkedUser(req.id)
+	if err != nil {
+		return nil, newNotFoundError("user %s not found", req.id)
+	}
+	return usr, nil
+}
+
+func checkUserNameUnique(domainId string, name string, excludeId string) error {
+	q := models.UserManager.Query().Equals("domain_id", domainId).Equals("name", name)
+	if len(excludeId) > 0 {
+		q = q.NotEquals("id", excludeId)
+	}
+	cnt, err := q.CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "CountWithError")
+	}
+	if cnt > 0 {
+		return newConflictError("userName %s already exists", name)
+	}
+	return nil
+}
+
+func listUsers(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	users, err := req.idp.GetLinkedUsers()
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "GetLinkedUsers")
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+	resources, err := fetchUserResources(req, users)
+	if err != nil {
+		return 0, nil, err
+	}
+	objs := make([]interface{}, len(resources))
+	for i := range resources {
+		objs[i] = resources[i]
+	}
+	resp, err := paginate(req.query, objs)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resp, nil
+}
+
+func getUser(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	usr, err := fetchLinkedUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchUserResource(req, usr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+func createUser(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	info, err := parseUser(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	domain, err := req.idp.GetScimDomain(ctx)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "GetScimDomain")
+	}
+	extId := info.externalId
+	if len(extId) == 0 {
+		extId = info.userName
+	}
+	_, err = models.IdmappingManager.FetchByIdpAndEntityId(ctx, req.idp.Id, extId, api.IdMappingEntityUser)
+	if err == nil {
+		return 0, nil, newConflictError("user %s already exists", extId)
+	}
+	err = checkUserNameUnique(domain.Id, info.userName, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	usr, err := req.idp.SyncOrCreateUser(ctx, extId, info.userName, domain.Id, info.active, func(user *models.SUser) {
+		user.Displayname = info.displayName
+		user.Email = info.email
+		user.Mobile = info.mobile
+	})
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "SyncOrCreateUser")
+	}
+	db.OpsLog.LogEvent(usr, db.ACT_CREATE, usr.GetShortDesc(ctx), req.userCred())
+	logclient.AddSimpleActionLog(usr, logclient.ACT_CREATE, "scim", req.userCred(), true)
+	resource, err := fetchUserResource(req, usr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, resource, nil
+}
+
+func updateUser(ctx context.Context, req *sRequest, usr *models.SUser, info *sUserInfo) error {
+	if len(info.externalId) > 0 {
+		extIds, err := req.idp.GetLinkedEntityIds(api.IdMappingEntityUser)
+		if err != nil {
+			return errors.Wrap(err, "GetLinkedEntityIds")
+		}
+		if extIds[usr.Id] != info.externalId {
+			return newBadRequestError(SCIM_TYPE_MUTABILITY, "externalId is immutable")
+		}
+	}
+	if info.userName != usr.Name {
+		err := checkUserNameUnique(usr.DomainId, info.userName, usr.Id)
+		if err != nil {
+			return err
+		}
+	}
+	enabled := tristate.False
+	if info.active {
+		enabled = tristate.True
+	}
+	wasEnabled := usr.Enabled
+	diff, err := db.Update(usr, func() error {
+		usr.Name = info.userName
+		usr.Displayname = info.displayName
+		usr.Email = info.email
+		usr.Mobile = info.mobile
+		usr.Enabled = enabled
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	db.OpsLog.LogEvent(usr, db.ACT_UPDATE, diff, req.userCred())
+	if wasEnabled != enabled {
+		if enabled.IsTrue() {
+			logclient.AddSimpleActionLog(usr, logclient.ACT_ENABLE, "scim", req.userCred(), true)
+		} else {
+			logclient.AddSimpleActionLog(usr, logclient.ACT_DISABLE, "scim", req.userCred(), true)
+		}
+	}
+	return nil
+}
+
+func replaceUser(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	usr, err := fetchLinkedUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := parseUser(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = updateUser(ctx, req, usr, info)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchUserResource(req, usr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+func patchUser(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	usr, err := fetchLinkedUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	ops, err := ParsePatchRequest(req.body)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err := fetchUserResource(req, usr)
+	if err != nil {
+		return 0, nil, err
+	}
+	obj := jsonutils.Marshal(resource).(*jsonutils.JSONDict)
+	err = ApplyPatch(obj, ops)
+	if err != nil {
+		return 0, nil, err
+	}
+	info, err := parseUser(obj)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = updateUser(ctx, req, usr, info)
+	if err != nil {
+		return 0, nil, err
+	}
+	resource, err = fetchUserResource(req, usr)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, resource, nil
+}
+
+// deleteUser deprovisions the user: the user is disabled at once, so that
+// the tokens issued to the user fail the verification by keystone, then
+// unlinked from the identity provider and deleted if possible. The services
+// keep serving a token verified before for token_cache_expire_seconds
+// (5 minutes by default) at most
+func deleteUser(ctx context.Context, req *sRequest) (int, interface{}, error) {
+	usr, err := fetchLinkedUser(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if usr.Enabled.IsTrue() {
+		_, err = db.Update(usr, func() error {
+			usr.Enabled = tristate.False
+			return nil
+		})
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "disable")
+		}
+		db.OpsLog.LogEvent(usr, db.ACT_DISABLE, "scim deprovision", req.userCred())
+		logclient.AddSimpleActionLog(usr, logclient.ACT_DISABLE, "scim", req.userCred(), true)
+	}
+	models.UsergroupManager.SyncUserGroups(ctx, req.userCred(), usr.Id, []string{})
+	err = usr.UnlinkIdp(req.idp.Id)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "UnlinkIdp")
+	}
+	err = usr.ValidateDeleteCondition(ctx)
+	if err != nil {
+		log.Warningf("scim deprovisioned user %s is kept disabled: %s", usr.Name, err)
+		return http.StatusNoContent, nil, nil
+	}
+	err = usr.Delete(ctx, req.userCred())
+	if err != nil {
+		log.Errorf("delete scim deprovisioned user %s error: %s", usr.Name, err)
+	}
+	return http.StatusNoContent, nil, nil
+}
@@ -20,8 +20,10 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/quotas"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
 	"yunion.io/x/onecloud/pkg/keystone/cronjobs"
 	"yunion.io/x/onecloud/pkg/keystone/models"
+	"yunion.io/x/onecloud/pkg/keystone/scim"
 	"yunion.io/x/onecloud/pkg/keystone/tokens"
 	"yunion.io/x/onecloud/pkg/keystone/usages"
 )
@@ -41,8 +43,10 @@ func InitHandlers(app *appsrv.Application) {
 
 	usages.AddUsageHandler(API_VERSION, app)
 	taskman.AddTaskHandler(API_VERSION, app)
+	informer.AddWatchHandler(API_VERSION, app)
 
 	tokens.AddHandler(app)
+	scim.AddHandler(app)
 
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
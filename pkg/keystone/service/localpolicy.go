@@ -32,6 +32,12 @@ func localPolicyFetcher(ctx context.Context, token mcclient.TokenCredential) (*m
 	output := mcclient.SFetchMatchPoliciesOutput{}
 	output.Names = names
 	output.Policies = groups
+	if len(token.GetUserId()) > 0 {
+		output.Groups, err = models.UsergroupManager.GetUserGroupIdsAndNames(token.GetUserId())
+		if err != nil {
+			return nil, errors.Wrap(err, "GetUserGroupIdsAndNames")
+		}
+	}
 
 	return &output, nil
 }
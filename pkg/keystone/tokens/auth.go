@@ -49,6 +49,10 @@ func authUserByToken(ctx context.Context, tokenStr string) (*api.SUserExtended,
 	if err != nil {
 		return nil, errors.Wrap(err, "token.ParseFernetToken")
 	}
+	if len(token.AppCredId) > 0 {
+		// otherwise the token could be rescoped to gain all roles of the user
+		return nil, errors.Wrap(ErrInvalidAuthMethod, "token issued by application credential")
+	}
 	return models.UserManager.FetchUserExtended(token.UserId, "", "", "")
 }
 
@@ -325,32 +329,46 @@ func authUserByOAuth2(ctx context.Context, input mcclient.SAuthenticationInputV3
 	return usr, nil
 }
 
-func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*api.SUserExtended, string, api.SAccessKeySecretInfo, error) {
+func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*api.SUserExtended, string, api.SAccessKeySecretInfo, string, error) {
 	var aksk api.SAccessKeySecretInfo
 
 	akskRequest, err := s3auth.Decode(input.Auth.Identity.AccessKeyRequest)
 	if err != nil {
-		return nil, "", aksk, errors.Wrap(err, "s3auth.Decode")
+		return nil, "", aksk, "", errors.Wrap(err, "s3auth.Decode")
 	}
 	keyId := akskRequest.GetAccessKey()
 	obj, err := models.CredentialManager.FetchById(keyId)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, "", aksk, ErrInvalidAccessKeyId
+			return nil, "", aksk, "", ErrInvalidAccessKeyId
 		} else {
-			return nil, "", aksk, errors.Wrap(err, "CredentialManager.FetchById")
+			return nil, "", aksk, "", errors.Wrap(err, "CredentialManager.FetchById")
 		}
 	}
 	credential := obj.(*models.SCredential)
 	if !credential.Enabled.IsTrue() {
-		return nil, "", aksk, errors.Wrap(httperrors.ErrInvalidStatus, "Access Key disabled")
+		return nil, "", aksk, "", errors.Wrap(httperrors.ErrInvalidStatus, "Access Key disabled")
 	}
-	akBlob, err := credential.GetAccessKeySecret()
-	if err != nil {
-		return nil, "", aksk, errors.Wrap(err, "credential.GetAccessKeySecret")
+	var akBlob *api.SAccessKeySecretBlob
+	var appCredId string
+	if credential.Type == api.APP_CREDENTIAL_TYPE {
+		appCred, err := credential.GetAppCredential()
+		if err != nil {
+			return nil, "", aksk, "", errors.Wrap(err, "credential.GetAppCredential")
+		}
+		if !appCred.IsIpAllowed(input.Auth.Context.Ip) {
+			return nil, "", aksk, "", errors.Wrapf(ErrSourceIpNotAllowed, "ip %s", input.Auth.Context.Ip)
+		}
+		akBlob = &appCred.SAccessKeySecretBlob
+		appCredId = credential.Id
+	} else {
+		akBlob, err = credential.GetAccessKeySecret()
+		if err != nil {
+			return nil, "", aksk, "", errors.Wrap(err, "credential.GetAccessKeySecret")
+		}
 	}
 	if !akBlob.IsValid() {
-		return nil, "", aksk, ErrExpiredAccessKey
+		return nil, "", aksk, "", ErrExpiredAccessKey
 	}
 	aksk.AccessKey = keyId
 	aksk.Secret = akBlob.Secret
@@ -358,13 +376,13 @@ func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationIn
 
 	err = akskRequest.Verify(akBlob.Secret)
 	if err != nil {
-		return nil, "", aksk, errors.Wrap(err, "Verify")
+		return nil, "", aksk, "", errors.Wrap(err, "Verify")
 	}
 	usrExt, err := models.UserManager.FetchUserExtended(credential.UserId, "", "", "")
 	if err != nil {
-		return nil, "", aksk, errors.Wrap(err, "UserManager.FetchUserExtended")
+		return nil, "", aksk, "", errors.Wrap(err, "UserManager.FetchUserExtended")
 	}
-	return usrExt, credential.ProjectId, aksk, nil
+	return usrExt, credential.ProjectId, aksk, appCredId, nil
 }
 
 // +onecloud:swagger-gen-route-method=POST
@@ -378,6 +396,7 @@ func authUserByAccessKeyV3(ctx context.Context, input mcclient.SAuthenticationIn
 // keystone v3认证API
 func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3) (*mcclient.TokenCredentialV3, error) {
 	var akskInfo api.SAccessKeySecretInfo
+	var appCredId string
 	var user *api.SUserExtended
 	var err error
 	if len(input.Auth.Identity.Methods) != 1 {
@@ -391,12 +410,17 @@ func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3)
 		if err != nil {
 			return nil, errors.Wrap(err, "authUserByTokenV3")
 		}
-	case api.AUTH_METHOD_AKSK:
-		// auth by aksk
-		user, input.Auth.Scope.Project.Id, akskInfo, err = authUserByAccessKeyV3(ctx, input)
+	case api.AUTH_METHOD_AKSK, api.AUTH_METHOD_APP_CRED:
+		// auth by aksk or application credential, both are signed in the same way
+		user, input.Auth.Scope.Project.Id, akskInfo, appCredId, err = authUserByAccessKeyV3(ctx, input)
 		if err != nil {
 			return nil, errors.Wrap(err, "authUserByAccessKeyV3")
 		}
+		if len(appCredId) > 0 {
+			method = api.AUTH_METHOD_APP_CRED
+		} else if method == api.AUTH_METHOD_APP_CRED {
+			return nil, errors.Wrap(ErrInvalidAuthMethod, "not an application credential")
+		}
 	case api.AUTH_METHOD_CAS:
 		// auth by apereo CAS
 		user, err = authUserByCASV3(ctx, input)
@@ -447,8 +471,16 @@ func AuthenticateV3(ctx context.Context, input mcclient.SAuthenticationInputV3)
 	token.Method = method
 	token.AuditIds = []string{utils.GenRequestId(16)}
 	now := time.Now().UTC()
-	token.ExpiresAt = now.Add(time.Duration(options.Options.TokenExpirationSeconds) * time.Second)
 	token.Context = input.Auth.Context
+	token.AppCredId = appCredId
+	token.ExpiresAt = now.Add(token.getLifetime())
+
+	if len(appCredId) > 0 {
+		// application credential is always scoped to the bound project
+		input.Auth.Scope.Project.Name = ""
+		input.Auth.Scope.Project.Domain.Id = ""
+		input.Auth.Scope.Project.Domain.Name = ""
+	}
 
 	if len(input.Auth.Scope.Project.Id) == 0 && len(input.Auth.Scope.Project.Name) == 0 && len(input.Auth.Scope.Domain.Id) == 0 && len(input.Auth.Scope.Domain.Name) == 0 {
 		// unscoped auth
@@ -29,4 +29,5 @@ const (
 	ErrUserNotInProject   = errors.Error("user not in project")
 	ErrInvalidAccessKeyId = errors.Error("invalid access key id")
 	ErrExpiredAccessKey   = errors.Error("expired access key")
+	ErrSourceIpNotAllowed = errors.Error("source ip not allowed")
 )
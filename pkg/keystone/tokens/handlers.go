@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -52,6 +53,26 @@ func FetchAuthContext(authCtx mcclient.SAuthContext, r *http.Request) mcclient.S
 	return authCtx
 }
 
+// getSignedRequestIp returns the ip of a client signing requests with access keys or application credentials.
+// The ip in the auth context is only trusted if the request comes from a service allowed to verify tokens,
+// e.g. s3gateway, which verifies the signed requests of its clients, otherwise the peer address is taken.
+func getSignedRequestIp(ctx context.Context, authCtx mcclient.SAuthContext, r *http.Request) string {
+	peerIp := netutils2.GetHttpRequestIp(r)
+	tokenStr := r.Header.Get(api.AUTH_TOKEN_HEADER)
+	if len(tokenStr) == 0 || len(authCtx.Ip) == 0 {
+		return peerIp
+	}
+	token, err := FernetTokenVerifier(ctx, tokenStr)
+	if err != nil {
+		log.Warningf("verify token of the forwarded auth request from %s fail %s", peerIp, err)
+		return peerIp
+	}
+	if !token.IsAllow(rbacutils.ScopeSystem, api.SERVICE_TYPE, "tokens", "perform", "auth") {
+		return peerIp
+	}
+	return authCtx.Ip
+}
+
 func authenticateTokensV2(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	_, _, body := appsrv.FetchEnv(ctx, w, r)
 	input := mcclient.SAuthenticationInputV2{}
@@ -84,6 +105,10 @@ func authenticateTokensV3(ctx context.Context, w http.ResponseWriter, r *http.Re
 		return
 	}
 	input.Auth.Context = FetchAuthContext(input.Auth.Context, r)
+	if len(input.Auth.Identity.Methods) == 1 && utils.IsInStringArray(input.Auth.Identity.Methods[0], []string{api.AUTH_METHOD_AKSK, api.AUTH_METHOD_APP_CRED}) {
+		// the allowed ips of application credentials are checked against the ip
+		input.Auth.Context.Ip = getSignedRequestIp(ctx, input.Auth.Context, r)
+	}
 	token, err := AuthenticateV3(ctx, input)
 	if err != nil {
 		switch errors.Cause(err) {
@@ -227,6 +252,16 @@ func verifyCommon(ctx context.Context, w http.ResponseWriter, tokenStr string) (
 		log.Errorf("ParseFernetToken %s fail: %s", tokenStr, err)
 		return nil, httperrors.NewInvalidCredentialError("invalid token")
 	}
+	_, err = token.fetchEnabledUser()
+	if err != nil {
+		return nil, httperrors.NewInvalidCredentialError("invalid user: %s", err)
+	}
+	if len(token.AppCredId) > 0 {
+		_, _, err := token.fetchAppCredential()
+		if err != nil {
+			return nil, httperrors.NewInvalidCredentialError("invalid application credential: %s", err)
+		}
+	}
 	return &token, nil
 }
 
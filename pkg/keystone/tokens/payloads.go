@@ -39,6 +39,8 @@ const (
 	SProjectScopedPayloadWithContextVersion = TScopedPayloadVersion(5)
 	SDomainScopedPayloadWithContextVersion  = TScopedPayloadVersion(4)
 	SUnscopedPayloadWithContextVersion      = TScopedPayloadVersion(3)
+
+	SAppCredentialPayloadVersion = TScopedPayloadVersion(6)
 )
 
 type ITokenPayload interface {
@@ -171,6 +173,26 @@ func (p *SProjectScopedPayloadWithContext) Encode() ([]byte, error) {
 	return msgpackEncoder(p)
 }
 
+// SAppCredentialPayload is the payload of the tokens issued by application
+// credentials, which are always project scoped
+type SAppCredentialPayload struct {
+	SProjectScopedPayloadWithContext
+	AppCredId SUuidPayload
+}
+
+func (p *SAppCredentialPayload) Unmarshal(tk []byte) error {
+	return msgpackDecoder(p, tk, SAppCredentialPayloadVersion)
+}
+
+func (p *SAppCredentialPayload) Decode(token *SAuthToken) {
+	p.SProjectScopedPayloadWithContext.Decode(token)
+	token.AppCredId = p.AppCredId.getUuid()
+}
+
+func (p *SAppCredentialPayload) Encode() ([]byte, error) {
+	return msgpackEncoder(p)
+}
+
 type SDomainScopedPayload struct {
 	Version   TScopedPayloadVersion
 	UserId    SUuidPayload
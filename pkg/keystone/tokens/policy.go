@@ -35,5 +35,12 @@ func fetchTokenPolicies(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	output := mcclient.SFetchMatchPoliciesOutput{}
 	output.Names = names
 	output.Policies = group
+	if len(token.GetUserId()) > 0 {
+		output.Groups, err = models.UsergroupManager.GetUserGroupIdsAndNames(token.GetUserId())
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+	}
 	appsrv.SendJSON(w, output.Encode())
 }
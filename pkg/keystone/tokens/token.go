@@ -23,6 +23,7 @@ import (
 	"yunion.io/x/pkg/utils"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/keystone/keys"
 	"yunion.io/x/onecloud/pkg/keystone/models"
 	"yunion.io/x/onecloud/pkg/keystone/options"
@@ -58,10 +59,14 @@ type SAuthToken struct {
 	AuditIds  []string
 
 	Context mcclient.SAuthContext
+
+	// the id of the application credential issuing the token
+	AppCredId string
 }
 
 func (t *SAuthToken) Decode(tk []byte) error {
 	for _, payload := range []ITokenPayload{
+		&SAppCredentialPayload{},
 		&SProjectScopedPayloadWithContext{},
 		&SDomainScopedPayloadWithContext{},
 		&SUnscopedPayloadWithContext{},
@@ -101,6 +106,19 @@ func (t *SAuthToken) getProjectScopedPayloadWithContext() ITokenPayload {
 	return &p
 }
 
+func (t *SAuthToken) getAppCredentialPayload() ITokenPayload {
+	p := SAppCredentialPayload{}
+	p.Version = SAppCredentialPayloadVersion
+	p.UserId.parse(t.UserId)
+	p.ProjectId.parse(t.ProjectId)
+	p.Method = authMethodStr2Id(t.Method)
+	p.ExpiresAt = float64(t.ExpiresAt.Unix())
+	p.AuditIds = auditStrings2Bytes(t.AuditIds)
+	p.Context = authContext2Payload(t.Context)
+	p.AppCredId.parse(t.AppCredId)
+	return &p
+}
+
 func (t *SAuthToken) getDomainScopedPayload() ITokenPayload {
 	p := SDomainScopedPayload{}
 	p.Version = SDomainScopedPayloadVersion
@@ -146,6 +164,9 @@ func (t *SAuthToken) getUnscopedPayloadWithContext() ITokenPayload {
 }
 
 func (t *SAuthToken) getPayload() ITokenPayload {
+	if len(t.AppCredId) > 0 {
+		return t.getAppCredentialPayload()
+	}
 	if len(t.ProjectId) > 0 {
 		return t.getProjectScopedPayloadWithContext()
 	}
@@ -184,9 +205,9 @@ func (t *SAuthToken) EncodeFernetToken() (string, error) {
 }
 
 func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential, error) {
-	userExt, err := models.UserManager.FetchUserExtended(t.UserId, "", "", "")
+	userExt, err := t.fetchEnabledUser()
 	if err != nil {
-		return nil, errors.Wrap(err, "UserManager.FetchUserExtended")
+		return nil, errors.Wrap(err, "fetchEnabledUser")
 	}
 	ret := mcclient.SSimpleToken{
 		Token:    token,
@@ -197,7 +218,6 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 		Expires:  t.ExpiresAt,
 		Context:  t.Context,
 	}
-	var roles []models.SRole
 	if len(t.ProjectId) > 0 {
 		proj, err := models.ProjectManager.FetchProjectById(t.ProjectId)
 		if err != nil {
@@ -207,7 +227,6 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 		ret.Project = proj.Name
 		ret.ProjectDomainId = proj.DomainId
 		ret.ProjectDomain = proj.GetDomain().Name
-		roles, err = models.AssignmentManager.FetchUserProjectRoles(t.UserId, t.ProjectId)
 	} else if len(t.DomainId) > 0 {
 		domain, err := models.DomainManager.FetchDomainById(t.DomainId)
 		if err != nil {
@@ -215,7 +234,14 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 		}
 		ret.ProjectDomainId = t.DomainId
 		ret.ProjectDomain = domain.Name
-		roles, err = models.AssignmentManager.FetchUserProjectRoles(t.UserId, t.DomainId)
+	}
+	roles, err := t.getRoles()
+	if err != nil {
+		return nil, errors.Wrap(err, "getRoles")
+	}
+	ret.AppCredential, err = t.getAppCredential()
+	if err != nil {
+		return nil, errors.Wrap(err, "getAppCredential")
 	}
 	roleStrs := make([]string, len(roles))
 	roleIdStrs := make([]string, len(roles))
@@ -228,7 +254,67 @@ func (t *SAuthToken) GetSimpleUserCred(token string) (mcclient.TokenCredential,
 	return &ret, nil
 }
 
+// fetchEnabledUser returns the user of the token, the tokens issued before
+// the user or its domain was disabled, e.g. deprovisioned by scim, are invalid
+func (t *SAuthToken) fetchEnabledUser() (*api.SUserExtended, error) {
+	userExt, err := models.UserManager.FetchUserExtended(t.UserId, "", "", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "UserManager.FetchUserExtended")
+	}
+	if !userExt.Enabled {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "user disabled")
+	}
+	if !userExt.DomainEnabled {
+		return nil, errors.Wrap(httperrors.ErrInvalidCredential, "user domain disabled")
+	}
+	return userExt, nil
+}
+
+// getLifetime returns how long the token lasts, a token issued by an
+// application credential lasts no longer than app_credential_token_expiration_seconds
+func (t *SAuthToken) getLifetime() time.Duration {
+	secs := options.Options.TokenExpirationSeconds
+	if len(t.AppCredId) > 0 && options.Options.AppCredentialTokenExpirationSeconds > 0 && options.Options.AppCredentialTokenExpirationSeconds < secs {
+		secs = options.Options.AppCredentialTokenExpirationSeconds
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// fetchAppCredential returns the application credential issuing the token,
+// which must be still valid
+func (t *SAuthToken) fetchAppCredential() (*models.SCredential, *api.SAppCredentialBlob, error) {
+	cred, appCred, err := models.CredentialManager.FetchAppCredential(t.AppCredId)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "FetchAppCredential")
+	}
+	if cred.UserId != t.UserId || cred.ProjectId != t.ProjectId {
+		return nil, nil, errors.Wrap(httperrors.ErrInvalidCredential, "application credential mismatch")
+	}
+	return cred, appCred, nil
+}
+
+func (t *SAuthToken) getAppCredential() (api.SAppCredentialInfo, error) {
+	ret := api.SAppCredentialInfo{}
+	if len(t.AppCredId) == 0 {
+		return ret, nil
+	}
+	_, appCred, err := t.fetchAppCredential()
+	if err != nil {
+		return ret, errors.Wrap(err, "fetchAppCredential")
+	}
+	ret.Id = t.AppCredId
+	ret.AllowedActions = appCred.AllowedActions
+	return ret, nil
+}
+
 func (t *SAuthToken) getRoles() ([]models.SRole, error) {
+	if len(t.AppCredId) > 0 {
+		cred, _, err := t.fetchAppCredential()
+		if err != nil {
+			return nil, errors.Wrap(err, "fetchAppCredential")
+		}
+		return cred.FetchAppCredentialRoles()
+	}
 	var roleProjectId string
 	if len(t.ProjectId) > 0 {
 		roleProjectId = t.ProjectId
@@ -251,7 +337,7 @@ func (t *SAuthToken) getTokenV3(
 	token := mcclient.TokenCredentialV3{}
 	token.Token.AccessKey = akskInfo
 	token.Token.ExpiresAt = t.ExpiresAt
-	token.Token.IssuedAt = t.ExpiresAt.Add(-time.Duration(options.Options.TokenExpirationSeconds) * time.Second)
+	token.Token.IssuedAt = t.ExpiresAt.Add(-t.getLifetime())
 	token.Token.AuditIds = t.AuditIds
 	token.Token.Methods = []string{t.Method}
 	token.Token.User.Id = user.Id
@@ -277,6 +363,10 @@ func (t *SAuthToken) getTokenV3(
 	if err != nil {
 		return nil, errors.Wrap(err, "getRoles")
 	}
+	token.Token.AppCredential, err = t.getAppCredential()
+	if err != nil {
+		return nil, errors.Wrap(err, "getAppCredential")
+	}
 
 	if len(roles) == 0 {
 		if project != nil || domain != nil {
@@ -21,6 +21,7 @@ import (
 	"github.com/golang-plus/uuid"
 
 	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/keystone/options"
 	"yunion.io/x/onecloud/pkg/util/fernetool"
 )
 
@@ -66,3 +67,63 @@ func TestSAuthToken_Encode(t *testing.T) {
 		}
 	}
 }
+
+func TestSAuthToken_AppCredential(t *testing.T) {
+	token := SAuthToken{}
+	token.UserId = newUuid()
+	token.Method = api.AUTH_METHOD_APP_CRED
+	token.ProjectId = newUuid()
+	token.ExpiresAt = time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	token.AuditIds = []string{newUuid()}
+	token.AppCredId = newUuid()
+
+	tk, err := token.Encode()
+	if err != nil {
+		t.Fatalf("SAuthToken encode fail %s", err)
+	}
+	token2 := SAuthToken{}
+	err = token2.Decode(tk)
+	if err != nil {
+		t.Fatalf("SAuthToken decode fail %s", err)
+	}
+	if token2.AppCredId != token.AppCredId || token2.ProjectId != token.ProjectId || token2.Method != token.Method {
+		t.Errorf("decoded token mismatch %#v != %#v", token2, token)
+	}
+
+	// tokens not issued by application credentials keep the project scoped payload
+	token.AppCredId = ""
+	tk, err = token.Encode()
+	if err != nil {
+		t.Fatalf("SAuthToken encode fail %s", err)
+	}
+	token3 := SAuthToken{}
+	err = token3.Decode(tk)
+	if err != nil {
+		t.Fatalf("SAuthToken decode fail %s", err)
+	}
+	if len(token3.AppCredId) > 0 || token3.ProjectId != token.ProjectId {
+		t.Errorf("decoded token mismatch %#v != %#v", token3, token)
+	}
+}
+
+func TestSAuthToken_GetLifetime(t *testing.T) {
+	opts := options.Options
+	defer func() {
+		options.Options = opts
+	}()
+	options.Options.TokenExpirationSeconds = 86400
+	options.Options.AppCredentialTokenExpirationSeconds = 900
+
+	token := SAuthToken{}
+	if got := token.getLifetime(); got != 24*time.Hour {
+		t.Errorf("want the token lasting 24h got %s", got)
+	}
+	token.AppCredId = newUuid()
+	if got := token.getLifetime(); got != 15*time.Minute {
+		t.Errorf("want the application credential token lasting 15m got %s", got)
+	}
+	options.Options.TokenExpirationSeconds = 600
+	if got := token.getLifetime(); got != 10*time.Minute {
+		t.Errorf("the application credential token should not outlast the other tokens, got %s", got)
+	}
+}
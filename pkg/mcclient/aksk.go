@@ -33,13 +33,14 @@ type SAkskTokenCredential struct {
 	Token           TokenCredential
 }
 
-func (this *Client) _verifyKeySecret(aksk s3auth.IAccessKeySecretRequest, aCtx SAuthContext) (*SAkskTokenCredential, error) {
+// the ip in aCtx is only trusted by keystone if the request is made with a service token
+func (this *Client) _verifyKeySecret(aksk s3auth.IAccessKeySecretRequest, aCtx SAuthContext, token string) (*SAkskTokenCredential, error) {
 	input := SAuthenticationInputV3{}
 	input.Auth.Identity.Methods = []string{api.AUTH_METHOD_AKSK}
 	input.Auth.Identity.AccessKeyRequest = aksk.Encode()
 	input.Auth.Context = aCtx
 
-	hdr, rbody, err := this.jsonRequest(context.Background(), this.authUrl, "", "POST", "/auth/tokens", nil, jsonutils.Marshal(&input))
+	hdr, rbody, err := this.jsonRequest(context.Background(), this.authUrl, token, "POST", "/auth/tokens", nil, jsonutils.Marshal(&input))
 	if err != nil {
 		return nil, err
 	}
@@ -58,14 +59,15 @@ func (this *Client) _verifyKeySecret(aksk s3auth.IAccessKeySecretRequest, aCtx S
 	return &ret, nil
 }
 
-func (this *Client) VerifyRequest(req http.Request, aksk s3auth.IAccessKeySecretRequest, virtualHost bool) (*SAkskTokenCredential, error) {
+// VerifyRequest verifies the signed request of a client on behalf of the service identified by serviceToken
+func (this *Client) VerifyRequest(req http.Request, aksk s3auth.IAccessKeySecretRequest, virtualHost bool, serviceToken string) (*SAkskTokenCredential, error) {
 	cliIp := netutils2.GetHttpRequestIp(&req)
 	aCtx := SAuthContext{
 		Source: AuthSourceSrv,
 		Ip:     cliIp,
 	}
 
-	token, err := this._verifyKeySecret(aksk, aCtx)
+	token, err := this._verifyKeySecret(aksk, aCtx, serviceToken)
 	if err != nil {
 		return nil, errors.Wrap(err, "this._verifyKeySecret")
 	}
@@ -100,7 +102,7 @@ func (this *Client) AuthenticateByAccessKey(accessKey string, secret string, sou
 		return nil, errors.Wrap(err, "s3auth.DecodeAccessKeyRequest")
 	}
 
-	token, err := this._verifyKeySecret(aksk, aCtx)
+	token, err := this._verifyKeySecret(aksk, aCtx, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "this._verifyKeySecret")
 	}
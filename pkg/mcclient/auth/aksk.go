@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/pkg/util/cache"
 
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/netutils2"
 	"yunion.io/x/onecloud/pkg/util/s3auth"
 )
 
@@ -42,9 +43,14 @@ func newAccessKeyCache() *sAccessKeyCache {
 	}
 }
 
-func (c *sAccessKeyCache) addToken(cred *mcclient.SAkskTokenCredential) {
+// the tokens are cached per client ip, as application credentials may only be used from some ips
+func accessKeyCacheKey(accessKey string, ip string) string {
+	return accessKey + "@" + ip
+}
+
+func (c *sAccessKeyCache) addToken(key string, cred *mcclient.SAkskTokenCredential) {
 	item := &sAkSkCacheItem{cred}
-	c.Set(cred.AccessKeySecret.AccessKey, item)
+	c.Set(key, item)
 }
 
 func (c *sAccessKeyCache) getToken(token string) (*mcclient.SAkskTokenCredential, bool) {
@@ -59,13 +65,14 @@ func (c *sAccessKeyCache) deleteToken(token string) bool {
 	return c.Delete(token)
 }
 
-func (c *sAccessKeyCache) Verify(cli *mcclient.Client, req http.Request, virtualHost bool) (mcclient.TokenCredential, error) {
+func (c *sAccessKeyCache) Verify(cli *mcclient.Client, req http.Request, virtualHost bool, serviceToken string) (mcclient.TokenCredential, error) {
 	aksk, err := s3auth.DecodeAccessKeyRequest(req, virtualHost)
 	if err != nil {
 		return nil, errors.Wrap(err, "s3auth.DecodeAccessKeyRequestV2")
 	}
 
-	token, found := c.getToken(aksk.GetAccessKey())
+	key := accessKeyCacheKey(aksk.GetAccessKey(), netutils2.GetHttpRequestIp(&req))
+	token, found := c.getToken(key)
 	if found {
 		if token.Token.IsValid() && token.AccessKeySecret.IsValid() {
 			err = aksk.Verify(token.AccessKeySecret.Secret)
@@ -74,16 +81,16 @@ func (c *sAccessKeyCache) Verify(cli *mcclient.Client, req http.Request, virtual
 			}
 			return token.Token, nil
 		} else {
-			c.deleteToken(aksk.GetAccessKey())
+			c.deleteToken(key)
 		}
 	}
 
-	token, err = cli.VerifyRequest(req, aksk, virtualHost)
+	token, err = cli.VerifyRequest(req, aksk, virtualHost, serviceToken)
 	if err != nil {
 		return nil, errors.Wrap(err, "cli.VerifyRequest")
 	}
 
-	c.addToken(token)
+	c.addToken(key, token)
 
 	return token.Token, nil
 }
@@ -34,6 +34,9 @@ var (
 	manager           *authManager
 	defaultTimeout    int   = 600 // maybe time.Duration better
 	defaultCacheCount int64 = 100000
+	// a verified token is verified by keystone again after it is cached for
+	// tokenCacheExpire, so that the revocation of the token takes effect
+	tokenCacheExpire = 5 * time.Minute
 	// initCh             chan bool = make(chan bool)
 	globalEndpointType string
 )
@@ -53,6 +56,12 @@ func SetTimeout(t time.Duration) {
 	defaultTimeout = int(t)
 }
 
+func SetTokenCacheExpireSeconds(sec int) {
+	if sec > 0 {
+		tokenCacheExpire = time.Duration(sec) * time.Second
+	}
+}
+
 func SetEndpointType(epType string) {
 	globalEndpointType = epType
 }
@@ -74,6 +83,7 @@ func NewAuthInfo(authUrl, domain, user, passwd, project, projectDomain string) *
 
 type cacheItem struct {
 	credential mcclient.TokenCredential
+	expireAt   time.Time
 }
 
 func (item *cacheItem) Size() int {
@@ -91,7 +101,10 @@ func NewTokenCacheVerify() *TokenCacheVerify {
 }
 
 func (c *TokenCacheVerify) AddToken(cred mcclient.TokenCredential) error {
-	item := &cacheItem{cred}
+	item := &cacheItem{
+		credential: cred,
+		expireAt:   time.Now().Add(tokenCacheExpire),
+	}
 	c.Set(cred.GetTokenString(), item)
 	return nil
 }
@@ -103,7 +116,12 @@ func (c *TokenCacheVerify) GetToken(token string) (mcclient.TokenCredential, boo
 		return nil, false
 	}
 
-	return item.(*cacheItem).credential, true
+	cached := item.(*cacheItem)
+	if time.Now().After(cached.expireAt) {
+		c.DeleteToken(token)
+		return nil, false
+	}
+	return cached.credential, true
 }
 
 func (c *TokenCacheVerify) DeleteToken(token string) bool {
@@ -160,7 +178,7 @@ func (a *authManager) verifyRequest(req http.Request, virtualHost bool) (mcclien
 	if a.adminCredential == nil {
 		return nil, fmt.Errorf("No valid admin token credential")
 	}
-	cred, err := a.accessKeyCache.Verify(a.client, req, virtualHost)
+	cred, err := a.accessKeyCache.Verify(a.client, req, virtualHost, a.getTokenString())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,41 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func TestTokenCacheVerify_Expire(t *testing.T) {
+	expire := tokenCacheExpire
+	defer func() {
+		tokenCacheExpire = expire
+	}()
+	tokenCacheExpire = 50 * time.Millisecond
+
+	c := NewTokenCacheVerify()
+	c.AddToken(&mcclient.SSimpleToken{Token: "token", Expires: time.Now().Add(time.Hour)})
+	if _, found := c.GetToken("token"); !found {
+		t.Fatalf("the token should be cached")
+	}
+	// the token is verified by keystone again though not expired yet
+	time.Sleep(100 * time.Millisecond)
+	if _, found := c.GetToken("token"); found {
+		t.Errorf("the token should be dropped from the cache after %s", tokenCacheExpire)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	streamWatchTimeoutSeconds = 30
+)
+
+var (
+	streamWatchRetryInterval = 5 * time.Second
+)
+
+// IServiceResourceManager is the resource manager knowing the service
+// serving the resource, the stream watcher polls the service for the events
+type IServiceResourceManager interface {
+	IResourceManager
+	ServiceType() string
+	EndpointType() string
+	GetApiVersion() string
+}
+
+// sStreamWatcher long polls the informer watch endpoint of the services, it
+// is used when there is no etcd in the region
+type sStreamWatcher struct {
+	client        *mcclient.Client
+	region        string
+	interfaceType string
+	// getToken returns the token of every poll, the watchers live longer
+	// than a token, e.g. the admin token of the services is renewed by the
+	// auth manager
+	getToken func() mcclient.TokenCredential
+	// reauth renews the token rejected by the services, it is nil if the
+	// token can't be renewed, e.g. the token of a user
+	reauth func()
+
+	lock    sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newStreamWatcher(client *mcclient.Client, region, interfaceType string, getToken func() mcclient.TokenCredential, reauth func()) *sStreamWatcher {
+	return &sStreamWatcher{
+		client:        client,
+		region:        region,
+		interfaceType: interfaceType,
+		getToken:      getToken,
+		reauth:        reauth,
+		cancels:       make(map[string]context.CancelFunc),
+	}
+}
+
+func (w *sStreamWatcher) poll(ctx context.Context, resMan IServiceResourceManager, revision int64) (*informer.SStreamWatchResult, error) {
+	query := jsonutils.NewDict()
+	query.Add(jsonutils.NewString(resMan.KeyString()), "resource")
+	query.Add(jsonutils.NewInt(revision), "revision")
+	query.Add(jsonutils.NewInt(streamWatchTimeoutSeconds), "timeout")
+	url := fmt.Sprintf("/informer/watch?%s", query.QueryString())
+	session := w.client.NewSession(ctx, w.region, "", w.interfaceType, w.getToken(), "")
+	_, body, err := session.JSONVersionRequest(resMan.ServiceType(), resMan.EndpointType(), httputils.GET, url, nil, nil, resMan.GetApiVersion())
+	if err != nil {
+		if httputils.ErrorCode(err) == http.StatusUnauthorized && w.reauth != nil {
+			// the token expired or revoked before renewed, the next poll goes with a new one
+			log.Warningf("watch %s unauthorized, renew the token", resMan.KeyString())
+			w.reauth()
+		}
+		return nil, errors.Wrapf(err, "watch %s", resMan.KeyString())
+	}
+	ret := new(informer.SStreamWatchResult)
+	if err := body.Unmarshal(ret); err != nil {
+		return nil, errors.Wrap(err, "unmarshal watch result")
+	}
+	return ret, nil
+}
+
+func (w *sStreamWatcher) Watch(ctx context.Context, resMan IServiceResourceManager, handler informer.ResourceEventHandler) error {
+	// the first poll returns the current revision at once and surfaces the
+	// errors of the endpoint to the caller
+	ret, err := w.poll(ctx, resMan, 0)
+	if err != nil {
+		return err
+	}
+	key := resMan.KeyString()
+	w.Unwatch(key)
+
+	w.lock.Lock()
+	ctx, cancel := context.WithCancel(ctx)
+	w.cancels[key] = cancel
+	w.lock.Unlock()
+
+	go w.run(ctx, resMan, ret.Revision, handler)
+	return nil
+}
+
+func (w *sStreamWatcher) Unwatch(key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if cancel, ok := w.cancels[key]; ok {
+		cancel()
+		delete(w.cancels, key)
+	}
+}
+
+func (w *sStreamWatcher) run(ctx context.Context, resMan IServiceResourceManager, revision int64, handler informer.ResourceEventHandler) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		ret, err := w.poll(ctx, resMan, revision)
+		if err != nil {
+			log.Errorf("poll %s events error: %v", resMan.KeyString(), err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(streamWatchRetryInterval):
+			}
+			continue
+		}
+		if ret.Compacted {
+			// the events between are lost, the watcher goes on from the
+			// current revision as etcd watchers do after reconnecting
+			log.Warningf("events of %s after revision %d are compacted, resume from %d", resMan.KeyString(), revision, ret.Revision)
+		}
+		for i := range ret.Events {
+			processStreamEvent(handler, &ret.Events[i])
+		}
+		revision = ret.Revision
+	}
+}
+
+func processStreamEvent(handler informer.ResourceEventHandler, event *informer.SStreamEvent) {
+	switch event.EventType {
+	case informer.EventTypeCreate:
+		handler.OnAdd(event.Object)
+	case informer.EventTypeUpdate:
+		handler.OnUpdate(event.OldObject, event.Object)
+	case informer.EventTypeDelete:
+		handler.OnDelete(event.Object)
+	default:
+		log.Errorf("Invalid event type %s of %s revision %d", event.EventType, event.KeywordPlural, event.Revision)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// testStreamService serves the informer watch endpoint, only the valid
+// token is accepted and it is renewed once the first event is served
+type testStreamService struct {
+	lock  sync.Mutex
+	token string
+}
+
+func (s *testStreamService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	token := s.token
+	s.lock.Unlock()
+	if r.Header.Get(mcclient.AUTH_TOKEN) != token {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":401,"class":"Unauthorized","details":"invalid token"}}`))
+		return
+	}
+	revision, _ := strconv.ParseInt(r.URL.Query().Get("revision"), 10, 64)
+	ret := informer.SStreamWatchResult{Revision: revision}
+	switch revision {
+	case 0:
+		ret.Revision = 1
+	case 1, 2:
+		if revision == 1 {
+			// the token of the watcher expires
+			s.lock.Lock()
+			s.token = "token2"
+			s.lock.Unlock()
+		}
+		ret.Revision = revision + 1
+		ret.Events = []informer.SStreamEvent{{
+			Revision:      ret.Revision,
+			EventType:     informer.EventTypeCreate,
+			KeywordPlural: "servers",
+			Object:        jsonutils.Marshal(map[string]int64{"revision": ret.Revision}).(*jsonutils.JSONDict),
+		}}
+	default:
+		// no more events
+		time.Sleep(50 * time.Millisecond)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(jsonutils.Marshal(ret).String()))
+}
+
+type testStreamResourceManager struct {
+	url string
+}
+
+func (man testStreamResourceManager) KeyString() string    { return "servers" }
+func (man testStreamResourceManager) GetKeyword() string   { return "server" }
+func (man testStreamResourceManager) ServiceType() string  { return man.url }
+func (man testStreamResourceManager) EndpointType() string { return "" }
+func (man testStreamResourceManager) GetApiVersion() string {
+	return ""
+}
+
+type testStreamHandler struct {
+	added chan *jsonutils.JSONDict
+}
+
+func (h *testStreamHandler) OnAdd(obj *jsonutils.JSONDict) {
+	h.added <- obj
+}
+
+func (h *testStreamHandler) OnUpdate(oldObj, newObj *jsonutils.JSONDict) {
+}
+
+func (h *testStreamHandler) OnDelete(obj *jsonutils.JSONDict) {
+}
+
+func (h *testStreamHandler) waitAdded(t *testing.T, revision int64) {
+	select {
+	case obj := <-h.added:
+		if got, _ := obj.Int("revision"); got != revision {
+			t.Fatalf("want the event of revision %d got %s", revision, obj)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the event of revision %d is not received", revision)
+	}
+}
+
+func TestStreamWatcher_RenewToken(t *testing.T) {
+	interval := streamWatchRetryInterval
+	defer func() {
+		streamWatchRetryInterval = interval
+	}()
+	streamWatchRetryInterval = 10 * time.Millisecond
+
+	svc := &testStreamService{token: "token1"}
+	srv := httptest.NewServer(svc)
+	defer srv.Close()
+
+	var lock sync.Mutex
+	token := "token1"
+	getToken := func() mcclient.TokenCredential {
+		lock.Lock()
+		defer lock.Unlock()
+		return &mcclient.SSimpleToken{Token: token}
+	}
+	reauthed := make(chan struct{}, 10)
+	reauth := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		token = "token2"
+		reauthed <- struct{}{}
+	}
+
+	client := mcclient.NewClient(srv.URL, 10, false, true, "", "")
+	w := newStreamWatcher(client, "region", "", getToken, reauth)
+	handler := &testStreamHandler{added: make(chan *jsonutils.JSONDict, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := w.Watch(ctx, testStreamResourceManager{url: srv.URL}, handler); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	handler.waitAdded(t, 2)
+
+	select {
+	case <-reauthed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the rejected token is not renewed")
+	}
+	// the watcher goes on with the renewed token
+	handler.waitAdded(t, 3)
+}
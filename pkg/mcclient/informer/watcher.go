@@ -18,11 +18,14 @@ import (
 	"context"
 
 	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/cloudcommon/etcd"
 	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
 )
 
 type SWatchManager struct {
@@ -30,6 +33,8 @@ type SWatchManager struct {
 	region        string
 	interfaceType string
 	watchBackend  informer.IWatcher
+	// streamWatcher polls the services when there is no etcd in the region
+	streamWatcher *sStreamWatcher
 }
 
 func NewWatchManagerBySession(session *mcclient.ClientSession) (*SWatchManager, error) {
@@ -37,6 +42,27 @@ func NewWatchManagerBySession(session *mcclient.ClientSession) (*SWatchManager,
 }
 
 func NewWatchManager(client *mcclient.Client, token mcclient.TokenCredential, region, interfaceType string) (*SWatchManager, error) {
+	if catalog := client.GetServiceCatalog(); client.AuthVersion() == "v3" && catalog != nil {
+		if _, err := catalog.GetServiceURL(apis.SERVICE_TYPE_ETCD, region, "", interfaceType); err != nil {
+			log.Infof("no etcd endpoint found in region %s, watch resources from the services: %v", region, err)
+			getToken := func() mcclient.TokenCredential {
+				return token
+			}
+			var reauth func()
+			if auth.IsAuthed() && auth.AdminCredential().GetTokenString() == token.GetTokenString() {
+				// the services watch with the admin token renewed by the auth manager
+				getToken = auth.AdminCredential
+				reauth = auth.ReAuth
+			}
+			man := &SWatchManager{
+				client:        client,
+				region:        region,
+				interfaceType: interfaceType,
+				streamWatcher: newStreamWatcher(client, region, interfaceType, getToken, reauth),
+			}
+			return man, nil
+		}
+	}
 	endpoint, err := client.GetCommonEtcdEndpoint(token, region, interfaceType)
 	if err != nil {
 		return nil, errors.Wrap(err, "get common etcd endpoint")
@@ -106,6 +132,13 @@ func (w *sWatcher) AddEventHandler(ctx context.Context, handler EventHandler) er
 }
 
 func (man *SWatchManager) watch(ctx context.Context, resMan IResourceManager, handler informer.ResourceEventHandler) error {
+	if man.streamWatcher != nil {
+		svcMan, ok := resMan.(IServiceResourceManager)
+		if !ok {
+			return errors.Errorf("resource manager of %s does not provide the service to watch", resMan.KeyString())
+		}
+		return man.streamWatcher.Watch(ctx, svcMan, handler)
+	}
 	return man.watchBackend.Watch(ctx, resMan.KeyString(), handler)
 }
 
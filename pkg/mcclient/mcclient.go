@@ -421,6 +421,8 @@ func (this *Client) NewSession(ctx context.Context, region, zone, endpointType s
 type SFetchMatchPoliciesOutput struct {
 	Names    map[rbacutils.TRbacScope][]string `json:"names"`
 	Policies rbacutils.TPolicyGroup            `json:"policies"`
+	// ids and names of the groups of the user, evaluated by the group conditions of rules
+	Groups []string `json:"groups"`
 }
 
 func (o *SFetchMatchPoliciesOutput) Decode(object jsonutils.JSONObject) error {
@@ -436,6 +438,12 @@ func (o *SFetchMatchPoliciesOutput) Decode(object jsonutils.JSONObject) error {
 	if err != nil {
 		return errors.Wrap(err, "DecodePolicyGroup")
 	}
+	if object.Contains("groups") {
+		err = object.Unmarshal(&o.Groups, "groups")
+		if err != nil {
+			return errors.Wrap(err, "unmarshal groups")
+		}
+	}
 	return nil
 }
 
@@ -443,6 +451,9 @@ func (o SFetchMatchPoliciesOutput) Encode() jsonutils.JSONObject {
 	output := jsonutils.NewDict()
 	output.Set("names", jsonutils.Marshal(o.Names))
 	output.Set("policies", o.Policies.Encode())
+	if len(o.Groups) > 0 {
+		output.Set("groups", jsonutils.NewStringArray(o.Groups))
+	}
 	return output
 }
 
@@ -0,0 +1,25 @@
+package modules
+
+import "yunion.io/x/onecloud/pkg/mcclient/modulebase"
+
+type SAlertSilenceManager struct {
+	*modulebase.ResourceManager
+}
+
+var (
+	AlertSilenceManager *SAlertSilenceManager
+)
+
+func init() {
+	AlertSilenceManager = NewAlertSilenceManager()
+	register(AlertSilenceManager)
+}
+
+func NewAlertSilenceManager() *SAlertSilenceManager {
+	man := NewMonitorV2Manager("alertsilence", "alertsilences",
+		[]string{"id", "name", "enabled", "alert_id", "alert_name", "res_ids", "tags", "start_time", "end_time", "active", "created_by", "comment"},
+		[]string{})
+	return &SAlertSilenceManager{
+		ResourceManager: &man,
+	}
+}
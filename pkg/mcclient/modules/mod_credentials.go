@@ -43,6 +43,8 @@ const (
 	TOTP_TYPE             = api.TOTP_TYPE
 	RECOVERY_SECRETS_TYPE = api.RECOVERY_SECRETS_TYPE
 	OIDC_CREDENTIAL_TYPE  = api.OIDC_CREDENTIAL_TYPE
+	WEBAUTHN_TYPE         = api.WEBAUTHN_TYPE
+	APP_CREDENTIAL_TYPE   = api.APP_CREDENTIAL_TYPE
 )
 
 type STotpSecret struct {
@@ -74,6 +76,22 @@ type SOpenIDConnectCredential struct {
 	api.SAccessKeySecretBlob
 }
 
+type SWebAuthnCredential struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	api.SWebAuthnCredentialBlob
+}
+
+type SAppCredential struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	ProjectId string    `json:"project_id"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	api.SAppCredentialBlob
+}
+
 func (manager *SCredentialManager) fetchCredentials(s *mcclient.ClientSession, secType string, uid string, pid string) ([]jsonutils.JSONObject, error) {
 	query := jsonutils.NewDict()
 	query.Add(jsonutils.NewString(secType), "type")
@@ -105,6 +123,14 @@ func (manager *SCredentialManager) FetchOIDCSecrets(s *mcclient.ClientSession, u
 	return manager.fetchCredentials(s, OIDC_CREDENTIAL_TYPE, uid, pid)
 }
 
+func (manager *SCredentialManager) FetchWebAuthnCredentials(s *mcclient.ClientSession, uid string) ([]jsonutils.JSONObject, error) {
+	return manager.fetchCredentials(s, WEBAUTHN_TYPE, uid, "")
+}
+
+func (manager *SCredentialManager) FetchAppCredentials(s *mcclient.ClientSession, uid string, pid string) ([]jsonutils.JSONObject, error) {
+	return manager.fetchCredentials(s, APP_CREDENTIAL_TYPE, uid, pid)
+}
+
 func (manager *SCredentialManager) GetTotpSecret(s *mcclient.ClientSession, uid string) (string, error) {
 	secrets, err := manager.FetchTotpSecrets(s, uid)
 	if err != nil {
@@ -237,6 +263,166 @@ func (manager *SCredentialManager) GetOIDCSecret(s *mcclient.ClientSession, uid
 	return oidcCreds, nil
 }
 
+func DecodeWebAuthnCredential(secret jsonutils.JSONObject) (SWebAuthnCredential, error) {
+	curr := SWebAuthnCredential{}
+	blobStr, err := secret.GetString("blob")
+	if err != nil {
+		return curr, errors.Wrap(err, "secret.GetString")
+	}
+	blobJson, err := jsonutils.ParseString(blobStr)
+	if err != nil {
+		return curr, errors.Wrap(err, "jsonutils.ParseString")
+	}
+	err = blobJson.Unmarshal(&curr.SWebAuthnCredentialBlob)
+	if err != nil {
+		return curr, errors.Wrap(err, "blobJson.Unmarshal")
+	}
+	curr.Id, _ = secret.GetString("id")
+	curr.Name, _ = secret.GetString("name")
+	curr.CreatedAt, _ = secret.GetTime("created_at")
+	return curr, nil
+}
+
+// GetWebAuthnCredentials returns the WebAuthn credentials (hardware keys) registered by the user
+func (manager *SCredentialManager) GetWebAuthnCredentials(s *mcclient.ClientSession, uid string) ([]SWebAuthnCredential, error) {
+	secrets, err := manager.FetchWebAuthnCredentials(s, uid)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]SWebAuthnCredential, 0)
+	for i := range secrets {
+		curr, err := DecodeWebAuthnCredential(secrets[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "DecodeWebAuthnCredential")
+		}
+		creds = append(creds, curr)
+	}
+	return creds, nil
+}
+
+func (manager *SCredentialManager) SaveWebAuthnCredential(s *mcclient.ClientSession, uid string, name string, blob api.SWebAuthnCredentialBlob) (SWebAuthnCredential, error) {
+	cred := SWebAuthnCredential{SWebAuthnCredentialBlob: blob}
+	if cred.Timestamp == 0 {
+		cred.Timestamp = time.Now().Unix()
+	}
+	if len(name) == 0 {
+		name = fmt.Sprintf("webauthn-%s-%d", uid, cred.Timestamp)
+	}
+	blobJson := jsonutils.Marshal(&cred.SWebAuthnCredentialBlob)
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(DEFAULT_PROJECT), "project_id")
+	params.Add(jsonutils.NewString(WEBAUTHN_TYPE), "type")
+	params.Add(jsonutils.NewString(uid), "user_id")
+	params.Add(jsonutils.NewString(blobJson.String()), "blob")
+	params.Add(jsonutils.NewString(name), "name")
+	result, err := manager.Create(s, params)
+	if err != nil {
+		return cred, err
+	}
+	cred.Id, _ = result.GetString("id")
+	cred.Name, _ = result.GetString("name")
+	cred.CreatedAt, _ = result.GetTime("created_at")
+	return cred, nil
+}
+
+// UpdateWebAuthnSignCount saves the signature counter of the last assertion
+func (manager *SCredentialManager) UpdateWebAuthnSignCount(s *mcclient.ClientSession, cred SWebAuthnCredential, signCount uint32) error {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewInt(int64(signCount)), "sign_count")
+	_, err := manager.PerformAction(s, cred.Id, "update-sign-count", params)
+	return err
+}
+
+func (manager *SCredentialManager) RemoveWebAuthnCredential(s *mcclient.ClientSession, uid string, id string) error {
+	creds, err := manager.GetWebAuthnCredentials(s, uid)
+	if err != nil {
+		return err
+	}
+	for i := range creds {
+		if creds[i].Id == id {
+			_, err := manager.Delete(s, id, nil)
+			return err
+		}
+	}
+	return httperrors.NewNotFoundError("no webauthn credential %s for %s", id, uid)
+}
+
+func DecodeAppCredential(secret jsonutils.JSONObject) (SAppCredential, error) {
+	curr := SAppCredential{}
+	blobStr, err := secret.GetString("blob")
+	if err != nil {
+		return curr, errors.Wrap(err, "secret.GetString")
+	}
+	blobJson, err := jsonutils.ParseString(blobStr)
+	if err != nil {
+		return curr, errors.Wrap(err, "jsonutils.ParseString")
+	}
+	err = blobJson.Unmarshal(&curr.SAppCredentialBlob)
+	if err != nil {
+		return curr, errors.Wrap(err, "blobJson.Unmarshal")
+	}
+	curr.Id, _ = secret.GetString("id")
+	curr.Name, _ = secret.GetString("name")
+	curr.ProjectId, _ = secret.GetString("project_id")
+	curr.Enabled = jsonutils.QueryBoolean(secret, "enabled", false)
+	curr.CreatedAt, _ = secret.GetTime("created_at")
+	return curr, nil
+}
+
+// GetAppCredentials returns the application credentials of the user in the project
+func (manager *SCredentialManager) GetAppCredentials(s *mcclient.ClientSession, uid string, pid string) ([]SAppCredential, error) {
+	secrets, err := manager.FetchAppCredentials(s, uid, pid)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]SAppCredential, 0)
+	for i := range secrets {
+		curr, err := DecodeAppCredential(secrets[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "DecodeAppCredential")
+		}
+		creds = append(creds, curr)
+	}
+	return creds, nil
+}
+
+// CreateAppCredential creates an application credential granted the roles of
+// the user in the project, the credential authenticates as an AccessKey/Secret
+// with the id of the credential as the AccessKey
+func (manager *SCredentialManager) CreateAppCredential(s *mcclient.ClientSession, uid string, pid string, name string, blob api.SAppCredentialBlob) (SAppCredential, error) {
+	cred := SAppCredential{SAppCredentialBlob: blob}
+	if len(cred.Secret) == 0 {
+		cred.Secret = base64.URLEncoding.EncodeToString([]byte(seclib.RandomPassword(32)))
+	}
+	if len(name) == 0 {
+		name = fmt.Sprintf("app-cred-%s-%s-%d", uid, pid, time.Now().Unix())
+	}
+	params := jsonutils.NewDict()
+	if len(pid) > 0 {
+		params.Add(jsonutils.NewString(pid), "project_id")
+	}
+	params.Add(jsonutils.NewString(APP_CREDENTIAL_TYPE), "type")
+	if len(uid) > 0 {
+		params.Add(jsonutils.NewString(uid), "user_id")
+	}
+	params.Add(jsonutils.NewString(jsonutils.Marshal(&cred.SAppCredentialBlob).String()), "blob")
+	params.Add(jsonutils.NewString(name), "name")
+	result, err := manager.Create(s, params)
+	if err != nil {
+		return cred, err
+	}
+	return DecodeAppCredential(result)
+}
+
+// RevokeAppCredential disables the application credential, the tokens issued
+// by the credential become invalid as well
+func (manager *SCredentialManager) RevokeAppCredential(s *mcclient.ClientSession, id string) error {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.JSONFalse, "enabled")
+	_, err := manager.Update(s, id, params)
+	return err
+}
+
 func (manager *SCredentialManager) DoCreateAccessKeySecret(s *mcclient.ClientSession, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	key, err := manager.CreateAccessKeySecret(s, "", "", time.Time{})
 	if err != nil {
@@ -402,6 +588,10 @@ func (manager *SCredentialManager) RemoveRecoverySecrets(s *mcclient.ClientSessi
 	return manager.removeCredentials(s, RECOVERY_SECRETS_TYPE, uid, "")
 }
 
+func (manager *SCredentialManager) RemoveWebAuthnCredentials(s *mcclient.ClientSession, uid string) error {
+	return manager.removeCredentials(s, WEBAUTHN_TYPE, uid, "")
+}
+
 func (manager *SCredentialManager) RemoveOIDCSecrets(s *mcclient.ClientSession, uid string, pid string) error {
 	return manager.removeCredentials(s, OIDC_CREDENTIAL_TYPE, uid, pid)
 }
@@ -0,0 +1,35 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modules
+
+import "yunion.io/x/onecloud/pkg/mcclient/modulebase"
+
+var (
+	Webhooks          modulebase.ResourceManager
+	WebhookDeliveries modulebase.ResourceManager
+)
+
+func init() {
+	Webhooks = NewComputeManager("webhook", "webhooks",
+		[]string{"ID", "Name", "Enabled", "Status", "Url", "Resource_Types", "Actions", "Event_Scope", "Max_Retries", "Has_Secret", "Tenant"},
+		[]string{})
+
+	WebhookDeliveries = NewComputeManager("webhook_delivery", "webhook_deliveries",
+		[]string{"ID", "Name", "Status", "Webhook", "Obj_Type", "Obj_Id", "Action", "Attempts", "Response_Code", "Next_Attempt_At", "Delivered_At", "Reason", "Created_At"},
+		[]string{})
+
+	registerCompute(&Webhooks)
+	registerCompute(&WebhookDeliveries)
+}
@@ -189,6 +189,26 @@ func (c *AlertCondition) Median() *AlertCondition {
 	return c.setReducer("median")
 }
 
+func (c *AlertCondition) Percentile(n float64) *AlertCondition {
+	return c.setReducer("percentile", n)
+}
+
+func (c *AlertCondition) Rate() *AlertCondition {
+	return c.setReducer("rate")
+}
+
+func (c *AlertCondition) Delta() *AlertCondition {
+	return c.setReducer("delta")
+}
+
+func (c *AlertCondition) Stddev() *AlertCondition {
+	return c.setReducer("stddev")
+}
+
+func (c *AlertCondition) CountNonNull() *AlertCondition {
+	return c.setReducer("count_non_null")
+}
+
 func (c *AlertCondition) setEvaluator(typ string, threshold float64) *AlertCondition {
 	c.evaluator = &monitor.Condition{
 		Type:   typ,
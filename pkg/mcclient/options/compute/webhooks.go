@@ -0,0 +1,75 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type WebhookListOptions struct {
+	options.BaseListOptions
+
+	ResourceType string `help:"list the webhooks subscribing the resource type, e.g. server"`
+	EventScope   string `help:"scope of the subscribed events" choices:"project|domain|system"`
+}
+
+func (opts *WebhookListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(opts)
+}
+
+type WebhookCreateOptions struct {
+	options.BaseCreateOptions
+
+	URL          string   `help:"url receiving the events, http or https" json:"url"`
+	Secret       string   `help:"secret to sign the body with HMAC-SHA256"`
+	ResourceType []string `help:"subscribed resource type, e.g. server, all resources if not specified" json:"resource_types"`
+	Action       []string `help:"subscribed action, e.g. create, delete, all actions if not specified" json:"actions"`
+	EventScope   string   `help:"scope of the subscribed events, default project" choices:"project|domain|system"`
+	MaxRetries   *int     `help:"max retries of a failed delivery, default 5"`
+	Disabled     *bool    `help:"create the webhook disabled"`
+}
+
+func (opts *WebhookCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(opts)
+}
+
+type WebhookUpdateOptions struct {
+	options.BaseUpdateOptions
+
+	Url          string   `help:"url receiving the events"`
+	Secret       *string  `help:"secret to sign the body, empty string to remove the secret"`
+	ResourceType []string `help:"subscribed resource type" json:"resource_types"`
+	Action       []string `help:"subscribed action" json:"actions"`
+	MaxRetries   *int     `help:"max retries of a failed delivery"`
+}
+
+func (opts *WebhookUpdateOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(opts)
+}
+
+type WebhookDeliveryListOptions struct {
+	options.BaseListOptions
+
+	Webhook string `help:"ID or name of webhook"`
+	ObjType string `help:"resource type of the event"`
+	ObjId   string `help:"resource id of the event"`
+	Action  string `help:"action of the event"`
+}
+
+func (opts *WebhookDeliveryListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(opts)
+}
@@ -87,7 +87,8 @@ func (o AlertPauseOptions) Description() string {
 }
 
 type AlertConditionOptions struct {
-	REDUCER    string   `help:"Metric query reducer, e.g. 'avg'" choices:"avg|sum|min|max|count|last|median"`
+	REDUCER    string   `help:"Metric query reducer, e.g. 'avg'" choices:"avg|sum|min|max|count|last|median|percentile|rate|delta|stddev|count_non_null"`
+	Percentile float64  `help:"Percentile of the percentile reducer" default:"95"`
 	DATABASE   string   `help:"Metric database, e.g. 'telegraf'"`
 	METRIC     string   `help:"Query metric format <measurement>.<field>, e.g. 'cpu.cpu_usage'"`
 	COMPARATOR string   `help:"Evaluator compare" choices:"gt|lt"`
@@ -124,6 +125,16 @@ func (opt AlertConditionOptions) Params(conf *monitor2.AlertConfig) (*monitor2.A
 		cond.Last()
 	case "median":
 		cond.Median()
+	case "percentile":
+		cond.Percentile(opt.Percentile)
+	case "rate":
+		cond.Rate()
+	case "delta":
+		cond.Delta()
+	case "stddev":
+		cond.Stddev()
+	case "count_non_null":
+		cond.CountNonNull()
 	}
 
 	q := cond.Query().From(opt.Period)
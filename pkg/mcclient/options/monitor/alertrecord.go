@@ -9,11 +9,12 @@ import (
 type AlertRecordListOptions struct {
 	options.BaseListOptions
 
-	AlertId  string   `help:"id of alert"`
-	Level    string   `help:"alert level"`
-	State    string   `help:"alert state"`
-	ResTypes []string `json:"res_types"`
-	Alerting bool     `json:"alerting"`
+	AlertId   string   `help:"id of alert"`
+	Level     string   `help:"alert level"`
+	State     string   `help:"alert state"`
+	ResTypes  []string `json:"res_types"`
+	Alerting  bool     `json:"alerting"`
+	SendState string   `help:"send state of notification" choices:"ok|silent|silenced"`
 }
 
 func (o *AlertRecordListOptions) Params() (jsonutils.JSONObject, error) {
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/timeutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type AlertSilenceListOptions struct {
+	options.BaseListOptions
+
+	AlertId string `help:"id of alert"`
+	Active  *bool  `help:"only list the silences in effect"`
+}
+
+func (o *AlertSilenceListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(o)
+}
+
+type AlertSilenceCreateOptions struct {
+	NAME     string   `help:"Name of silence"`
+	Alert    string   `help:"ID or name of the silenced alert, empty for all alerts" json:"alert_id"`
+	ResId    []string `help:"ID of the silenced resource, e.g. host or guest id" json:"res_ids"`
+	Tag      []string `help:"Tag of the silenced resource, e.g. brand=OneCloud" json:"-"`
+	Start    string   `help:"Start time of silence, default now" json:"-"`
+	End      string   `help:"End time of silence" json:"-"`
+	Duration string   `help:"Duration of silence from start time, e.g. 2h" json:"-"`
+	Comment  string   `help:"Comment of silence, e.g. reason of the maintenance"`
+}
+
+func parseSilenceTime(str string) (time.Time, error) {
+	tm, err := timeutils.ParseTimeStr(str)
+	if err != nil {
+		return tm, fmt.Errorf("invalid time %q: %v", str, err)
+	}
+	return tm, nil
+}
+
+func (o *AlertSilenceCreateOptions) Params() (jsonutils.JSONObject, error) {
+	params, err := options.StructToParams(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.Tag) > 0 {
+		tags := jsonutils.NewDict()
+		for _, tag := range o.Tag {
+			parts := strings.SplitN(tag, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid tag %q, usage <key>=<value>", tag)
+			}
+			tags.Add(jsonutils.NewString(parts[1]), parts[0])
+		}
+		params.Add(tags, "tags")
+	}
+	start := time.Now()
+	if len(o.Start) > 0 {
+		start, err = parseSilenceTime(o.Start)
+		if err != nil {
+			return nil, err
+		}
+		params.Add(jsonutils.NewTimeString(start), "start_time")
+	}
+	if len(o.End) > 0 {
+		end, err := parseSilenceTime(o.End)
+		if err != nil {
+			return nil, err
+		}
+		params.Add(jsonutils.NewTimeString(end), "end_time")
+	} else if len(o.Duration) > 0 {
+		duration, err := time.ParseDuration(o.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %v", o.Duration, err)
+		}
+		params.Add(jsonutils.NewTimeString(start.Add(duration)), "end_time")
+	} else {
+		return nil, fmt.Errorf("end or duration is required")
+	}
+	return params, nil
+}
+
+type AlertSilenceUpdateOptions struct {
+	ID      string `help:"ID or name of silence" json:"-"`
+	End     string `help:"End time of silence" json:"-"`
+	Comment string `help:"Comment of silence"`
+}
+
+func (o *AlertSilenceUpdateOptions) GetId() string {
+	return o.ID
+}
+
+func (o *AlertSilenceUpdateOptions) Params() (jsonutils.JSONObject, error) {
+	params, err := options.StructToParams(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.End) > 0 {
+		end, err := parseSilenceTime(o.End)
+		if err != nil {
+			return nil, err
+		}
+		params.Add(jsonutils.NewTimeString(end), "end_time")
+	}
+	return params, nil
+}
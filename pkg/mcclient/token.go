@@ -20,6 +20,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/gotypes"
 
+	api "yunion.io/x/onecloud/pkg/apis/identity"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
 )
 
@@ -97,3 +98,18 @@ type TokenCredential interface {
 	GetLoginSource() string
 	GetLoginIp() string
 }
+
+// IAppCredentialToken is implemented by the tokens carrying the restriction
+// of the application credential they are issued by
+type IAppCredentialToken interface {
+	GetAppCredential() api.SAppCredentialInfo
+}
+
+// GetAppCredential returns the application credential which the token is
+// issued by, a zero value if the token is not issued by an application credential
+func GetAppCredential(token TokenCredential) api.SAppCredentialInfo {
+	if appCredToken, ok := token.(IAppCredentialToken); ok {
+		return appCredToken.GetAppCredential()
+	}
+	return api.SAppCredentialInfo{}
+}
@@ -44,6 +44,8 @@ type SSimpleToken struct {
 	Expires time.Time
 
 	Context SAuthContext
+
+	AppCredential api.SAppCredentialInfo
 }
 
 func (self *SSimpleToken) GetTokenString() string {
@@ -182,6 +184,10 @@ func (this *SSimpleToken) GetLoginIp() string {
 	return this.Context.Ip
 }
 
+func (this *SSimpleToken) GetAppCredential() api.SAppCredentialInfo {
+	return this.AppCredential
+}
+
 func SimplifyToken(token TokenCredential) TokenCredential {
 	simToken, ok := token.(*SSimpleToken)
 	if ok {
@@ -205,6 +211,7 @@ func SimplifyToken(token TokenCredential) TokenCredential {
 			Source: token.GetLoginSource(),
 			Ip:     token.GetLoginIp(),
 		},
+		AppCredential: GetAppCredential(token),
 	}
 }
 
@@ -0,0 +1,231 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/alerting"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+	"yunion.io/x/onecloud/pkg/monitor/validators"
+)
+
+const (
+	// baseline_stddev: params [k, window_hours], fire when the reduced value is
+	// outside mean ± k·stddev of the points in the window before the query range
+	EvaluatorTypeBaselineStddev = "baseline_stddev"
+	// baseline_history: params [days, tolerance_percent], fire when the reduced
+	// value deviates from the mean of the same range over the last days
+	EvaluatorTypeBaselineHistory = "baseline_history"
+
+	defaultBaselineWindow = 24 * time.Hour
+	defaultBaselineDays   = 7
+)
+
+// sBaseline is the allowed range of the reduced value of one series
+type sBaseline struct {
+	Mean  float64
+	Lower float64
+	Upper float64
+}
+
+// baselineEvaluator compares the reduced value with a baseline fetched through
+// the same tsdb query, so the bounds differ between series
+type baselineEvaluator struct {
+	Type string
+	// K is the multiple of stddev for baseline_stddev
+	K      float64
+	Window time.Duration
+	// Days and Tolerance are used by baseline_history
+	Days      int
+	Tolerance float64
+	// Direction is one of above, below or empty for both
+	Direction string
+}
+
+func newBaselineEvaluator(cond *monitor.Condition) (*baselineEvaluator, error) {
+	if err := validators.ValidateAlertConditionBaselineEvaluator(*cond); err != nil {
+		return nil, err
+	}
+	e := &baselineEvaluator{
+		Type:   cond.Type,
+		Window: defaultBaselineWindow,
+		Days:   defaultBaselineDays,
+	}
+	if len(cond.Operators) > 0 {
+		e.Direction = cond.Operators[0]
+	}
+	switch cond.Type {
+	case EvaluatorTypeBaselineStddev:
+		e.K = cond.Params[0]
+		if len(cond.Params) > 1 {
+			e.Window = time.Duration(cond.Params[1] * float64(time.Hour))
+		}
+	case EvaluatorTypeBaselineHistory:
+		e.Days = int(cond.Params[0])
+		if len(cond.Params) > 1 {
+			e.Tolerance = cond.Params[1]
+		}
+	}
+	return e, nil
+}
+
+// Eval can't judge without a baseline, the QueryCondition calls EvalBaseline instead
+func (e *baselineEvaluator) Eval(reducedValue *float64) bool {
+	return false
+}
+
+func (e *baselineEvaluator) EvalBaseline(reducedValue *float64, baseline *sBaseline) bool {
+	if reducedValue == nil || baseline == nil {
+		return false
+	}
+	val := *reducedValue
+	switch e.Direction {
+	case "above":
+		return val > baseline.Upper
+	case "below":
+		return val < baseline.Lower
+	}
+	return val > baseline.Upper || val < baseline.Lower
+}
+
+func (e *baselineEvaluator) String() string {
+	dir := e.Direction
+	if dir == "" {
+		dir = "outside"
+	}
+	switch e.Type {
+	case EvaluatorTypeBaselineStddev:
+		return fmt.Sprintf("%s mean ± %.2f stddev of last %s", dir, e.K, tsdb.FormatDuration(e.Window))
+	case EvaluatorTypeBaselineHistory:
+		return fmt.Sprintf("%s mean ± %.2f%% of last %d days", dir, e.Tolerance, e.Days)
+	}
+	return e.Type
+}
+
+func (e *baselineEvaluator) stddevBaseline(values []float64) *sBaseline {
+	if len(values) == 0 {
+		return nil
+	}
+	sum := float64(0)
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := float64(0)
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	delta := e.K * math.Sqrt(variance/float64(len(values)))
+	return &sBaseline{Mean: mean, Lower: mean - delta, Upper: mean + delta}
+}
+
+func (e *baselineEvaluator) historyBaseline(values []float64) *sBaseline {
+	if len(values) == 0 {
+		return nil
+	}
+	sum := float64(0)
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	delta := math.Abs(mean) * e.Tolerance / 100
+	return &sBaseline{Mean: mean, Lower: mean - delta, Upper: mean + delta}
+}
+
+func baselineSeriesKey(series *tsdb.TimeSeries) string {
+	keys := make([]string, 0, len(series.Tags))
+	for k := range series.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, series.Name)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, series.Tags[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func msTimeRange(from, to time.Time) *tsdb.TimeRange {
+	ms := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return tsdb.NewTimeRange(ms(from), ms(to))
+}
+
+// fetchBaselines queries the baseline ranges and returns the baseline of every series
+func (c *QueryCondition) fetchBaselines(evalCtx *alerting.EvalContext, timeRange *tsdb.TimeRange, e *baselineEvaluator) (map[string]*sBaseline, error) {
+	from, err := timeRange.ParseFrom()
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse from %q", timeRange.From)
+	}
+	to, err := timeRange.ParseTo()
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse to %q", timeRange.To)
+	}
+
+	values := make(map[string][]float64)
+	switch e.Type {
+	case EvaluatorTypeBaselineStddev:
+		// the window right before the evaluated range, so an anomaly doesn't hide itself
+		ret, err := c.executeQuery(evalCtx, msTimeRange(from.Add(-e.Window), from))
+		if err != nil {
+			return nil, errors.Wrap(err, "query baseline window")
+		}
+		for _, series := range ret.series {
+			key := baselineSeriesKey(series)
+			for _, point := range series.Points {
+				if point.IsValid() {
+					values[key] = append(values[key], point.Value())
+				}
+			}
+		}
+	case EvaluatorTypeBaselineHistory:
+		for day := 1; day <= e.Days; day++ {
+			shift := time.Duration(day) * 24 * time.Hour
+			ret, err := c.executeQuery(evalCtx, msTimeRange(from.Add(-shift), to.Add(-shift)))
+			if err != nil {
+				return nil, errors.Wrapf(err, "query baseline of %d days ago", day)
+			}
+			for _, series := range ret.series {
+				reducedValue, _ := c.Reducer.Reduce(series)
+				if reducedValue == nil {
+					continue
+				}
+				key := baselineSeriesKey(series)
+				values[key] = append(values[key], *reducedValue)
+			}
+		}
+	}
+
+	baselines := make(map[string]*sBaseline, len(values))
+	for key, vals := range values {
+		if e.Type == EvaluatorTypeBaselineStddev {
+			baselines[key] = e.stddevBaseline(vals)
+		} else {
+			baselines[key] = e.historyBaseline(vals)
+		}
+	}
+	return baselines, nil
+}
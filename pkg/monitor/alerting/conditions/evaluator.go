@@ -26,7 +26,7 @@ import (
 
 // AlertEvaluator evaluates the reduced value of a timeserie.
 // Returning true if a timeseries is violating the condition
-// ex: ThresholdEvaluator, NoValueEvaluator, RangeEvaluator
+// ex: ThresholdEvaluator, NoValueEvaluator, RangeEvaluator, BaselineEvaluator
 type AlertEvaluator interface {
 	Eval(reducedValue *float64) bool
 	String() string
@@ -140,6 +140,9 @@ func NewAlertEvaluator(cond *monitor.Condition) (AlertEvaluator, error) {
 	if utils.IsInStringArray(typ, validators.EvaluatorRangedTypes) {
 		return newRangedEvaluator(cond)
 	}
+	if utils.IsInStringArray(typ, validators.EvaluatorBaselineTypes) {
+		return newBaselineEvaluator(cond)
+	}
 
 	if typ == "no_value" {
 		return &noValueEvaluator{}, nil
@@ -65,4 +65,43 @@ func TestEvalutors(t *testing.T) {
 			So(evaluator.Eval(nil), ShouldBeTrue)
 		})
 	})
+
+	Convey("baseline_stddev", t, func() {
+		evaluator, err := NewAlertEvaluator(&monitor.Condition{Type: "baseline_stddev", Params: []float64{2, 24}})
+		So(err, ShouldBeNil)
+		e := evaluator.(*baselineEvaluator)
+		baseline := e.stddevBaseline([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+		So(baseline.Mean, ShouldEqual, 5)
+		So(baseline.Lower, ShouldEqual, 1)
+		So(baseline.Upper, ShouldEqual, 9)
+
+		val := float64(10)
+		So(e.EvalBaseline(&val, baseline), ShouldBeTrue)
+		val = 5
+		So(e.EvalBaseline(&val, baseline), ShouldBeFalse)
+		So(e.EvalBaseline(&val, nil), ShouldBeFalse)
+		So(e.Eval(&val), ShouldBeFalse)
+	})
+
+	Convey("baseline_history", t, func() {
+		evaluator, err := NewAlertEvaluator(&monitor.Condition{
+			Type:      "baseline_history",
+			Params:    []float64{3, 50},
+			Operators: []string{"above"},
+		})
+		So(err, ShouldBeNil)
+		e := evaluator.(*baselineEvaluator)
+		baseline := e.historyBaseline([]float64{10, 20, 30})
+		So(baseline.Upper, ShouldEqual, 30)
+
+		val := float64(31)
+		So(e.EvalBaseline(&val, baseline), ShouldBeTrue)
+		val = 1
+		So(e.EvalBaseline(&val, baseline), ShouldBeFalse)
+
+		_, err = NewAlertEvaluator(&monitor.Condition{Type: "baseline_history", Params: []float64{0}})
+		So(err, ShouldNotBeNil)
+		_, err = NewAlertEvaluator(&monitor.Condition{Type: "baseline_history", Params: []float64{3}, Operators: []string{"sideways"}})
+		So(err, ShouldNotBeNil)
+	})
 }
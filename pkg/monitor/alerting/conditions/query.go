@@ -160,7 +160,17 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 	if err != nil {
 		return nil, errors.Wrap(err, "GetQueryResources err")
 	}
+	baselineEval, isBaseline := c.Evaluator.(*baselineEvaluator)
+	var baselines map[string]*sBaseline
+	if isBaseline && len(seriesList) > 0 {
+		baselines, err = c.fetchBaselines(context, timeRange, baselineEval)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetchBaselines")
+		}
+	}
 	for _, series := range seriesList {
+		// the key must be taken before the tags are filled by resource fields
+		baselineKey := baselineSeriesKey(series)
 		if len(c.ResType) != 0 {
 			isLatestOfSerie, resource := c.serieIsLatestResource(allResources, series)
 			if !isLatestOfSerie {
@@ -169,7 +179,19 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 			c.FillSerieByResourceField(resource, series)
 		}
 		reducedValue, valStrArr := c.Reducer.Reduce(series)
-		evalMatch := c.Evaluator.Eval(reducedValue)
+		var evalMatch bool
+		if isBaseline {
+			baseline := baselines[baselineKey]
+			evalMatch = baselineEval.EvalBaseline(reducedValue, baseline)
+			if context.IsTestRun && baseline != nil {
+				context.Logs = append(context.Logs, &monitor.ResultLogEntry{
+					Message: fmt.Sprintf("Condition[%d]: Baseline of %s: mean %v, range [%v, %v]",
+						c.Index, series.Name, baseline.Mean, baseline.Lower, baseline.Upper),
+				})
+			}
+		} else {
+			evalMatch = c.Evaluator.Eval(reducedValue)
+		}
 
 		if reducedValue == nil {
 			emptySeriesCount++
@@ -155,8 +155,33 @@ func (s *queryReducer) Reduce(series *tsdb.TimeSeries) (*float64, []string) {
 		if len(values) >= 1 {
 			sort.Float64s(values)
 			length := len(values)
-			index := math.Floor(float64(length) * pNum / float64(100))
-			value = values[int64(index)]
+			index := int(math.Floor(float64(length) * pNum / float64(100)))
+			if index >= length {
+				index = length - 1
+			}
+			value = values[index]
+		}
+	case "rate":
+		allNull, value = calculateRate(series)
+	case "delta":
+		allNull, value = calculateDelta(series)
+	case "stddev":
+		var values []float64
+		sum := float64(0)
+		for _, v := range series.Points {
+			if v.IsValid() {
+				allNull = false
+				values = append(values, v.Value())
+				sum += v.Value()
+			}
+		}
+		if len(values) >= 1 {
+			avg := sum / float64(len(values))
+			variance := float64(0)
+			for _, v := range values {
+				variance += (v - avg) * (v - avg)
+			}
+			value = math.Sqrt(variance / float64(len(values)))
 		}
 	}
 
@@ -209,6 +234,50 @@ func calculateDiff(series *tsdb.TimeSeries, allNull bool, value float64, fn func
 	return allNull, value
 }
 
+func validPoints(series *tsdb.TimeSeries) tsdb.TimeSeriesPoints {
+	points := make(tsdb.TimeSeriesPoints, 0, len(series.Points))
+	for _, point := range series.Points {
+		if point.IsValid() {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// calculateDelta returns the newest value minus the oldest value, unlike diff
+// the sign is kept, so a decreasing series has a negative delta
+func calculateDelta(series *tsdb.TimeSeries) (bool, float64) {
+	points := validPoints(series)
+	if len(points) < 2 {
+		return true, 0
+	}
+	return false, points[len(points)-1].Value() - points[0].Value()
+}
+
+// calculateRate returns the per-second increase of a counter, a decreasing
+// value is treated as a counter reset
+func calculateRate(series *tsdb.TimeSeries) (bool, float64) {
+	points := validPoints(series)
+	if len(points) < 2 {
+		return true, 0
+	}
+	increase := float64(0)
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1].Value(), points[i].Value()
+		if cur >= prev {
+			increase += cur - prev
+		} else {
+			increase += cur
+		}
+	}
+	// timestamps are in milliseconds
+	seconds := (points[len(points)-1].Timestamp() - points[0].Timestamp()) / 1000
+	if seconds <= 0 {
+		return true, 0
+	}
+	return false, increase / seconds
+}
+
 var diff = func(newest, oldest float64) float64 {
 	return newest - oldest
 }
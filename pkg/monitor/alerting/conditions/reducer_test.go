@@ -184,6 +184,49 @@ func TestSimpleReducer(t *testing.T) {
 			reduce, _ := reducer.Reduce(series)
 			So(reduce, ShouldBeNil)
 		})
+
+		Convey("percentile", func() {
+			reducer := &queryReducer{Type: "percentile", Params: []float64{50}}
+			series := &tsdb.TimeSeries{Name: "test time series"}
+			for i, v := range []float64{5, 1, 4, 2, 3} {
+				series.Points = append(series.Points, tsdb.NewTimePointByVal(v, float64(i)))
+			}
+			reduce, _ := reducer.Reduce(series)
+			So(*reduce, ShouldEqual, 3)
+
+			reducer.Params = []float64{100}
+			reduce, _ = reducer.Reduce(series)
+			So(*reduce, ShouldEqual, 5)
+		})
+
+		Convey("rate with counter reset", func() {
+			reducer := newSimpleReducerByType("rate")
+			series := &tsdb.TimeSeries{Name: "test time series"}
+			series.Points = append(series.Points, tsdb.NewTimePointByVal(100, 0))
+			series.Points = append(series.Points, tsdb.NewTimePoint(nil, 5000))
+			series.Points = append(series.Points, tsdb.NewTimePointByVal(160, 10000))
+			series.Points = append(series.Points, tsdb.NewTimePointByVal(40, 20000))
+			reduce, _ := reducer.Reduce(series)
+			So(*reduce, ShouldEqual, float64(5))
+		})
+
+		Convey("rate one point", func() {
+			reducer := newSimpleReducerByType("rate")
+			series := &tsdb.TimeSeries{Name: "test time series"}
+			series.Points = append(series.Points, tsdb.NewTimePointByVal(100, 0))
+			reduce, _ := reducer.Reduce(series)
+			So(reduce, ShouldBeNil)
+		})
+
+		Convey("delta keeps sign", func() {
+			result := testReducer("delta", 40, 35, 30)
+			So(result, ShouldEqual, float64(-10))
+		})
+
+		Convey("stddev", func() {
+			result := testReducer("stddev", 2, 4, 4, 4, 5, 5, 7, 9)
+			So(result, ShouldEqual, float64(2))
+		})
 	})
 }
 
@@ -218,13 +218,35 @@ func (c *EvalContext) GetNotificationTemplateConfig() monitor.NotificationTempla
 	}
 }
 
+// currentEvalMatches returns the matches of the current state
+func (c *EvalContext) currentEvalMatches() []*monitor.EvalMatch {
+	if c.Firing {
+		return c.EvalMatches
+	}
+	return c.AlertOkEvalMatches
+}
+
+// IsSilenced is true when every match is covered by a silence
+func (c *EvalContext) IsSilenced() bool {
+	matches := c.currentEvalMatches()
+	if len(matches) == 0 {
+		return false
+	}
+	for _, match := range matches {
+		if len(match.SilenceId) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetEvalMatches returns the matches to notify, the silenced ones are skipped
 func (c *EvalContext) GetEvalMatches() []monitor.EvalMatch {
 	ret := make([]monitor.EvalMatch, 0)
-	matches := c.EvalMatches
-	if !c.Firing {
-		matches = c.AlertOkEvalMatches
-	}
-	for _, c := range matches {
+	for _, c := range c.currentEvalMatches() {
+		if len(c.SilenceId) != 0 {
+			continue
+		}
 		ret = append(ret, monitor.EvalMatch{
 			Condition: c.Condition,
 			Value:     c.Value,
@@ -0,0 +1,374 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/monitor/options"
+)
+
+const (
+	// labels of the alert can be used to group besides the match tags
+	GroupLabelAlertId   = "alert_id"
+	GroupLabelAlertName = "alert_name"
+	GroupLabelLevel     = "level"
+)
+
+var levelPriority = map[string]int{
+	"":          0,
+	"normal":    0,
+	"important": 1,
+	"fatal":     2,
+	"critical":  2,
+}
+
+// groupedMatch is the latest match of one series of an alert in the group
+type groupedMatch struct {
+	alertId   string
+	alertName string
+	level     string
+	match     monitor.EvalMatch
+}
+
+// notificationGroup collects the matches of the same notifier and group labels,
+// they are sent in one notification when the group timer fires
+type notificationGroup struct {
+	key      string
+	labels   map[string]string
+	notifier Notifier
+	params   jsonutils.JSONObject
+	userCred mcclient.TokenCredential
+
+	firing   map[string]*groupedMatch
+	resolved map[string]*groupedMatch
+	// states of the alert notifications waiting for the group
+	states map[string]*notifierState
+
+	timer     *time.Timer
+	lastFlush time.Time
+}
+
+func (g *notificationGroup) isEmpty() bool {
+	return len(g.firing) == 0 && len(g.resolved) == 0
+}
+
+// notificationGrouper aggregates the notifications of the evaluated alerts
+// like the alertmanager grouping: the first notification of a group waits for
+// groupWait, the following ones are sent at most once every groupInterval
+type notificationGrouper struct {
+	lock          sync.Mutex
+	groupBy       []string
+	groupWait     time.Duration
+	groupInterval time.Duration
+	groups        map[string]*notificationGroup
+
+	// send is the notification sender, replaced in tests
+	send func(group *notificationGroup, evalCtx *EvalContext) error
+}
+
+func newNotificationGrouper(groupBy []string, groupWait, groupInterval time.Duration) *notificationGrouper {
+	g := &notificationGrouper{
+		groupBy:       groupBy,
+		groupWait:     groupWait,
+		groupInterval: groupInterval,
+		groups:        make(map[string]*notificationGroup),
+	}
+	g.send = g.sendGroup
+	return g
+}
+
+func newNotificationGrouperFromOptions() *notificationGrouper {
+	if len(options.Options.AlertingGroupBy) == 0 {
+		return nil
+	}
+	return newNotificationGrouper(
+		options.Options.AlertingGroupBy,
+		time.Duration(options.Options.AlertingGroupWaitSeconds)*time.Second,
+		time.Duration(options.Options.AlertingGroupIntervalSeconds)*time.Second,
+	)
+}
+
+func (g *notificationGrouper) groupLabels(rule *Rule, match monitor.EvalMatch) map[string]string {
+	labels := make(map[string]string, len(g.groupBy))
+	for _, key := range g.groupBy {
+		switch key {
+		case GroupLabelAlertId:
+			labels[key] = rule.Id
+		case GroupLabelAlertName:
+			labels[key] = rule.Name
+		case GroupLabelLevel:
+			labels[key] = rule.Level
+		default:
+			labels[key] = match.Tags[key]
+		}
+	}
+	return labels
+}
+
+func sortedLabelsString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, labels[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// matchKey identifies the series of an alert, the repeated matches of
+// successive evaluations are deduplicated by it
+func matchKey(alertId string, match monitor.EvalMatch) string {
+	return fmt.Sprintf("%s/%s/%s", alertId, match.Metric, sortedLabelsString(match.Tags))
+}
+
+// Add puts the matches of the evaluation into their groups,
+// it returns false if there is nothing to group and the notification should be sent directly
+func (g *notificationGrouper) Add(evalCtx *EvalContext, state *notifierState) bool {
+	matches := evalCtx.GetEvalMatches()
+	if len(matches) == 0 {
+		return false
+	}
+	if state.state != nil {
+		if err := state.state.SetToPending(); err != nil {
+			log.Errorf("set alert notification %s to pending: %v", state.notifier.GetNotifierId(), err)
+		}
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := time.Now()
+	g.cleanup(now)
+	for _, match := range matches {
+		labels := g.groupLabels(evalCtx.Rule, match)
+		key := fmt.Sprintf("%s/%s", state.notifier.GetNotifierId(), sortedLabelsString(labels))
+		group, ok := g.groups[key]
+		if !ok {
+			group = &notificationGroup{
+				key:      key,
+				labels:   labels,
+				firing:   make(map[string]*groupedMatch),
+				resolved: make(map[string]*groupedMatch),
+				states:   make(map[string]*notifierState),
+			}
+			g.groups[key] = group
+		}
+		group.notifier = state.notifier
+		group.userCred = evalCtx.UserCred
+		if state.state != nil {
+			group.params = state.state.GetParams()
+		}
+		group.states[evalCtx.Rule.Id] = state
+
+		gm := &groupedMatch{
+			alertId:   evalCtx.Rule.Id,
+			alertName: evalCtx.Rule.Name,
+			level:     evalCtx.Rule.Level,
+			match:     match,
+		}
+		mKey := matchKey(evalCtx.Rule.Id, match)
+		if evalCtx.Firing {
+			group.firing[mKey] = gm
+			delete(group.resolved, mKey)
+		} else {
+			group.resolved[mKey] = gm
+			delete(group.firing, mKey)
+		}
+		g.schedule(group, now)
+	}
+	return true
+}
+
+func (g *notificationGrouper) schedule(group *notificationGroup, now time.Time) {
+	if group.timer != nil {
+		return
+	}
+	delay := g.groupWait
+	if !group.lastFlush.IsZero() {
+		next := group.lastFlush.Add(g.groupInterval)
+		if next.After(now) {
+			delay = next.Sub(now)
+		}
+	}
+	key := group.key
+	group.timer = time.AfterFunc(delay, func() {
+		g.flush(key)
+	})
+}
+
+// cleanup removes the idle groups, a group is idle when nothing was added
+// during the group interval after the last notification
+func (g *notificationGrouper) cleanup(now time.Time) {
+	for key, group := range g.groups {
+		if group.timer == nil && group.isEmpty() && now.Sub(group.lastFlush) > g.groupInterval {
+			delete(g.groups, key)
+		}
+	}
+}
+
+func (g *notificationGrouper) flush(key string) {
+	g.lock.Lock()
+	group, ok := g.groups[key]
+	if !ok {
+		g.lock.Unlock()
+		return
+	}
+	snapshot := &notificationGroup{
+		key:      group.key,
+		labels:   group.labels,
+		notifier: group.notifier,
+		params:   group.params,
+		userCred: group.userCred,
+		firing:   group.firing,
+		resolved: group.resolved,
+		states:   group.states,
+	}
+	group.firing = make(map[string]*groupedMatch)
+	group.resolved = make(map[string]*groupedMatch)
+	group.states = make(map[string]*notifierState)
+	group.timer = nil
+	group.lastFlush = time.Now()
+	g.lock.Unlock()
+
+	if snapshot.isEmpty() {
+		return
+	}
+	timeout := time.Duration(options.Options.AlertingNotificationTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	evalCtx := g.newGroupEvalContext(ctx, snapshot)
+	if err := g.send(snapshot, evalCtx); err != nil {
+		log.Errorf("send notification of group %s: %v", snapshot.key, err)
+		return
+	}
+	for alertId, state := range snapshot.states {
+		if state.state == nil {
+			continue
+		}
+		if err := state.state.UpdateSendTime(); err != nil {
+			log.Errorf("alert %s notification UpdateSendTime: %v", alertId, err)
+		}
+		if err := state.state.SetToCompleted(); err != nil {
+			log.Errorf("alert %s notification SetToCompleted: %v", alertId, err)
+		}
+	}
+}
+
+func sortedGroupedMatches(matches map[string]*groupedMatch) []*groupedMatch {
+	keys := make([]string, 0, len(matches))
+	for k := range matches {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]*groupedMatch, len(keys))
+	for i, k := range keys {
+		ret[i] = matches[k]
+	}
+	return ret
+}
+
+// summarizeAlerts returns the alert names with the count of their matches
+func summarizeAlerts(matches []*groupedMatch) string {
+	names := make([]string, 0)
+	counts := make(map[string]int)
+	for _, m := range matches {
+		if _, ok := counts[m.alertName]; !ok {
+			names = append(names, m.alertName)
+		}
+		counts[m.alertName]++
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s(%d)", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newGroupEvalContext builds the evaluation context of the summarized notification,
+// the firing matches are the notified matches and the resolved ones are listed in the message
+func (g *notificationGrouper) newGroupEvalContext(ctx context.Context, group *notificationGroup) *EvalContext {
+	firing := sortedGroupedMatches(group.firing)
+	resolved := sortedGroupedMatches(group.resolved)
+
+	rule := &Rule{
+		Name:  sortedLabelsString(group.labels),
+		State: monitor.AlertStateOK,
+	}
+	all := append(append([]*groupedMatch{}, firing...), resolved...)
+	rule.Id = all[0].alertId
+	for _, m := range all {
+		if levelPriority[m.level] >= levelPriority[rule.Level] {
+			rule.Level = m.level
+		}
+	}
+	rule.Title = fmt.Sprintf("%d firing, %d resolved", len(firing), len(resolved))
+	if len(rule.Name) > 0 {
+		rule.Title = fmt.Sprintf("%s: %s", rule.Name, rule.Title)
+	}
+	msgs := make([]string, 0, 2)
+	if len(firing) > 0 {
+		rule.State = monitor.AlertStateAlerting
+		msgs = append(msgs, fmt.Sprintf("Firing: %s", summarizeAlerts(firing)))
+	}
+	if len(resolved) > 0 {
+		msgs = append(msgs, fmt.Sprintf("Resolved: %s", summarizeAlerts(resolved)))
+	}
+	rule.Message = strings.Join(msgs, "\n")
+
+	evalCtx := &EvalContext{
+		Firing:         len(firing) > 0,
+		StartTime:      time.Now(),
+		Rule:           rule,
+		PrevAlertState: rule.State,
+		Ctx:            ctx,
+		UserCred:       group.userCred,
+	}
+	for _, m := range firing {
+		match := m.match
+		evalCtx.EvalMatches = append(evalCtx.EvalMatches, &match)
+	}
+	for _, m := range resolved {
+		match := m.match
+		evalCtx.AlertOkEvalMatches = append(evalCtx.AlertOkEvalMatches, &match)
+	}
+	evalCtx.EndTime = time.Now()
+	return evalCtx
+}
+
+func (g *notificationGrouper) sendGroup(group *notificationGroup, evalCtx *EvalContext) error {
+	log.Infof("Sending grouped notification %s, firing %d, resolved %d", group.key, len(evalCtx.EvalMatches), len(evalCtx.AlertOkEvalMatches))
+	if err := group.notifier.Notify(evalCtx, group.params); err != nil {
+		return errors.Wrapf(err, "notifier %s notify", group.notifier.GetNotifierId())
+	}
+	return nil
+}
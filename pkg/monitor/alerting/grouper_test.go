@@ -0,0 +1,106 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/models"
+)
+
+type notifierStub struct {
+	id string
+}
+
+func (n *notifierStub) GetType() string                                 { return "stub" }
+func (n *notifierStub) GetNotifierId() string                           { return n.id }
+func (n *notifierStub) GetSendReminder() bool                           { return false }
+func (n *notifierStub) GetDisableResolveMessage() bool                  { return false }
+func (n *notifierStub) GetFrequency() time.Duration                     { return 0 }
+func (n *notifierStub) Notify(*EvalContext, jsonutils.JSONObject) error { return nil }
+func (n *notifierStub) ShouldNotify(context.Context, *EvalContext, *models.SAlertnotification) bool {
+	return true
+}
+
+func newGroupTestContext(alertId, level string, firing bool, hosts ...string) *EvalContext {
+	ctx := NewEvalContext(context.TODO(), nil, &Rule{Id: alertId, Name: alertId, Level: level})
+	ctx.Firing = firing
+	for _, host := range hosts {
+		match := &monitor.EvalMatch{
+			Metric: "cpu.usage_active",
+			Tags:   map[string]string{"name": host, "zone": "zone0"},
+		}
+		if firing {
+			ctx.EvalMatches = append(ctx.EvalMatches, match)
+		} else {
+			ctx.AlertOkEvalMatches = append(ctx.AlertOkEvalMatches, match)
+		}
+	}
+	return ctx
+}
+
+func TestNotificationGrouper(t *testing.T) {
+	Convey("Group the notifications by labels", t, func() {
+		sent := make(chan *EvalContext, 10)
+		grouper := newNotificationGrouper([]string{"zone"}, 50*time.Millisecond, 200*time.Millisecond)
+		grouper.send = func(group *notificationGroup, evalCtx *EvalContext) error {
+			sent <- evalCtx
+			return nil
+		}
+		state := &notifierState{notifier: &notifierStub{id: "n1"}}
+
+		So(grouper.Add(newGroupTestContext("cpu", "normal", true, "h1", "h2"), state), ShouldBeTrue)
+		// the repeated match is deduplicated
+		So(grouper.Add(newGroupTestContext("cpu", "normal", true, "h1"), state), ShouldBeTrue)
+		So(grouper.Add(newGroupTestContext("mem", "important", true, "h3"), state), ShouldBeTrue)
+		So(grouper.Add(newGroupTestContext("disk", "normal", false, "h4"), state), ShouldBeTrue)
+		So(grouper.Add(newGroupTestContext("disk", "normal", false), state), ShouldBeFalse)
+
+		var evalCtx *EvalContext
+		select {
+		case evalCtx = <-sent:
+		case <-time.After(time.Second):
+		}
+		So(evalCtx, ShouldNotBeNil)
+		So(evalCtx.Firing, ShouldBeTrue)
+		So(len(evalCtx.EvalMatches), ShouldEqual, 3)
+		So(len(evalCtx.AlertOkEvalMatches), ShouldEqual, 1)
+		So(evalCtx.Rule.Level, ShouldEqual, "important")
+		So(evalCtx.Rule.Title, ShouldEqual, "zone=zone0: 3 firing, 1 resolved")
+		So(evalCtx.Rule.Message, ShouldEqual, "Firing: cpu(2), mem(1)\nResolved: disk(1)")
+
+		Convey("The next notification waits for the group interval", func() {
+			start := time.Now()
+			grouper.Add(newGroupTestContext("cpu", "normal", false, "h1"), state)
+			select {
+			case evalCtx = <-sent:
+			case <-time.After(time.Second):
+				evalCtx = nil
+			}
+			So(evalCtx, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeGreaterThan, 100*time.Millisecond)
+			So(evalCtx.Firing, ShouldBeFalse)
+			So(evalCtx.Rule.State, ShouldEqual, monitor.AlertStateOK)
+			So(len(evalCtx.GetEvalMatches()), ShouldEqual, 1)
+		})
+	})
+}
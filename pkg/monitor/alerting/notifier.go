@@ -32,13 +32,32 @@ import (
 )
 
 type notificationService struct {
+	// grouper aggregates the notifications, nil if grouping is disabled
+	grouper *notificationGrouper
 }
 
 func newNotificationService() *notificationService {
-	return &notificationService{}
+	return &notificationService{
+		grouper: newNotificationGrouperFromOptions(),
+	}
 }
 
 func (n *notificationService) SendIfNeeded(evalCtx *EvalContext) error {
+	if !evalCtx.IsTestRun {
+		if err := n.applySilences(evalCtx); err != nil {
+			log.Errorf("apply silences of alert %s error: %v", evalCtx.Rule.Id, err)
+		}
+	}
+	if evalCtx.IsSilenced() {
+		log.Infof("Alert %s is silenced, skip notifications", evalCtx.Rule.Name)
+		if evalCtx.shouldUpdateAlertState() {
+			go func() {
+				n.createAlertRecordWhenNotify(evalCtx, false)
+			}()
+		}
+		return nil
+	}
+
 	notifierStates, err := n.getNeededNotifiers(evalCtx.Rule.Notifications, evalCtx)
 	if err != nil {
 		return errors.Wrap(err, "failed to get alert notifiers")
@@ -51,6 +70,19 @@ func (n *notificationService) SendIfNeeded(evalCtx *EvalContext) error {
 	return n.sendNotifications(evalCtx, notifierStates)
 }
 
+// applySilences marks the matches covered by the active silences,
+// they are excluded from the notifications but kept in the alert record
+func (n *notificationService) applySilences(evalCtx *EvalContext) error {
+	count, err := models.AlertSilenceManager.SilenceEvalMatches(evalCtx.Rule.Id, evalCtx.currentEvalMatches())
+	if err != nil {
+		return errors.Wrap(err, "SilenceEvalMatches")
+	}
+	if count > 0 {
+		log.Infof("Alert %s has %d matches silenced", evalCtx.Rule.Name, count)
+	}
+	return nil
+}
+
 type notifierState struct {
 	notifier Notifier
 	state    *models.SAlertnotification
@@ -89,6 +121,9 @@ func (n *notificationService) sendAndMarkAsComplete(evalCtx *EvalContext, state
 
 func (n *notificationService) sendNotifications(evalCtx *EvalContext, states notifierStateSlice) error {
 	for _, state := range states {
+		if n.grouper != nil && !evalCtx.IsTestRun && n.grouper.Add(evalCtx, state) {
+			continue
+		}
 		if err := n.sendNotification(evalCtx, state); err != nil {
 			log.Errorf("failed to send %s notification: %v", state.notifier.GetNotifierId(), err)
 			if evalCtx.IsTestRun {
@@ -159,12 +194,7 @@ func (n *notificationService) getNeededNotifiers(nIds []string, evalCtx *EvalCon
 }
 
 func (n *notificationService) createAlertRecordWhenNotify(evalCtx *EvalContext, shouldNotify bool) {
-	var matches []*monitor.EvalMatch
-	if evalCtx.Firing {
-		matches = evalCtx.EvalMatches
-	} else {
-		matches = evalCtx.AlertOkEvalMatches
-	}
+	matches := evalCtx.currentEvalMatches()
 	recordCreateInput := monitor.AlertRecordCreateInput{
 		StandaloneResourceCreateInput: apis.StandaloneResourceCreateInput{
 			GenerateName: evalCtx.Rule.Name,
@@ -176,7 +206,9 @@ func (n *notificationService) createAlertRecordWhenNotify(evalCtx *EvalContext,
 		EvalData:  matches,
 		AlertRule: newAlertRecordRule(evalCtx),
 	}
-	if !shouldNotify {
+	if evalCtx.IsSilenced() {
+		recordCreateInput.SendState = monitor.SEND_STATE_SILENCED
+	} else if !shouldNotify {
 		recordCreateInput.SendState = monitor.SEND_STATE_SILENT
 	}
 	recordCreateInput.ResType = recordCreateInput.AlertRule.ResType
@@ -115,6 +115,9 @@ func (manager *SAlertRecordManager) ListItemFilter(
 	if len(query.ResType) != 0 {
 		q.Filter(sqlchemy.Equals(q.Field("res_type"), query.ResType))
 	}
+	if len(query.SendState) != 0 {
+		q.Filter(sqlchemy.Equals(q.Field("send_state"), query.SendState))
+	}
 	return q, nil
 }
 
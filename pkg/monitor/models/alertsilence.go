@@ -0,0 +1,376 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+var (
+	AlertSilenceManager *SAlertSilenceManager
+)
+
+// SAlertSilenceManager manages the silences, the matched alert notifications
+// are not sent between start_time and end_time, e.g. during host maintenance
+type SAlertSilenceManager struct {
+	db.SEnabledResourceBaseManager
+	db.SStatusStandaloneResourceBaseManager
+	SMonitorScopedResourceManager
+}
+
+type SAlertSilence struct {
+	db.SEnabledResourceBase
+	db.SStatusStandaloneResourceBase
+	SMonitorScopedResource
+
+	// AlertId is empty for all alerts
+	AlertId string `width:"36" charset:"ascii" index:"true" list:"user" create:"optional"`
+	// ResIds matches the resource id tags of the eval match, e.g. host_id or vm_id
+	ResIds jsonutils.JSONObject `list:"user" create:"optional" update:"user"`
+	// Tags all equal to the eval match tags
+	Tags      jsonutils.JSONObject `list:"user" create:"optional" update:"user"`
+	StartTime time.Time            `nullable:"false" list:"user" create:"optional" update:"user"`
+	EndTime   time.Time            `nullable:"false" list:"user" create:"required" update:"user"`
+	CreatedBy string               `width:"128" charset:"utf8" list:"user"`
+	Comment   string               `width:"256" charset:"utf8" list:"user" create:"optional" update:"user"`
+}
+
+func init() {
+	AlertSilenceManager = &SAlertSilenceManager{
+		SStatusStandaloneResourceBaseManager: db.NewStatusStandaloneResourceBaseManager(
+			SAlertSilence{},
+			"alertsilence_tbl",
+			"alertsilence",
+			"alertsilences",
+		),
+	}
+
+	AlertSilenceManager.SetVirtualObject(AlertSilenceManager)
+}
+
+func (manager *SAlertSilenceManager) NamespaceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeSystem
+}
+
+func (manager *SAlertSilenceManager) ListItemExportKeys(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, keys stringutils2.SSortedStrings) (*sqlchemy.SQuery, error) {
+	q, err := manager.SStatusStandaloneResourceBaseManager.ListItemExportKeys(ctx, q, userCred, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.ListItemExportKeys")
+	}
+	q, err = manager.SScopedResourceBaseManager.ListItemExportKeys(ctx, q, userCred, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "SScopedResourceBaseManager.ListItemExportKeys")
+	}
+	return q, nil
+}
+
+func (manager *SAlertSilenceManager) ListItemFilter(
+	ctx context.Context, q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query monitor.AlertSilenceListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStatusStandaloneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StatusStandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, query.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SScopedResourceBaseManager.ListItemFilter(ctx, q, userCred, query.ScopedResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SScopedResourceBaseManager.ListItemFilter")
+	}
+	if len(query.AlertId) != 0 {
+		q = q.Equals("alert_id", query.AlertId)
+	}
+	if query.Active != nil {
+		now := time.Now()
+		if *query.Active {
+			q = q.IsTrue("enabled").LE("start_time", now).GT("end_time", now)
+		} else {
+			q = q.Filter(sqlchemy.OR(
+				sqlchemy.IsFalse(q.Field("enabled")),
+				sqlchemy.GT(q.Field("start_time"), now),
+				sqlchemy.LE(q.Field("end_time"), now),
+			))
+		}
+	}
+	return q, nil
+}
+
+func (manager *SAlertSilenceManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	input monitor.AlertSilenceListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SStatusStandaloneResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.StatusStandaloneResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.OrderByExtraFields")
+	}
+	q, err = manager.SScopedResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.ScopedResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SScopedResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (manager *SAlertSilenceManager) ValidateCreateData(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject,
+	input monitor.AlertSilenceCreateInput,
+) (monitor.AlertSilenceCreateInput, error) {
+	var err error
+	input.StatusStandaloneResourceCreateInput, err = manager.SStatusStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.StatusStandaloneResourceCreateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SStatusStandaloneResourceBaseManager.ValidateCreateData")
+	}
+	if len(input.AlertId) != 0 {
+		obj, err := AlertManager.FetchByIdOrName(userCred, input.AlertId)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return input, httperrors.NewResourceNotFoundError2(AlertManager.Keyword(), input.AlertId)
+			}
+			return input, errors.Wrapf(err, "fetch alert %s", input.AlertId)
+		}
+		input.AlertId = obj.GetId()
+	}
+	if len(input.AlertId) == 0 && len(input.ResIds) == 0 && len(input.Tags) == 0 {
+		return input, httperrors.NewMissingParameterError("alert_id, res_ids or tags")
+	}
+	if input.StartTime.IsZero() {
+		input.StartTime = time.Now()
+	}
+	if input.EndTime.IsZero() {
+		return input, httperrors.NewMissingParameterError("end_time")
+	}
+	if !input.EndTime.After(input.StartTime) {
+		return input, httperrors.NewInputParameterError("end_time %s must be after start_time %s", input.EndTime, input.StartTime)
+	}
+	return input, nil
+}
+
+func (silence *SAlertSilence) CustomizeCreate(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider,
+	query jsonutils.JSONObject,
+	data jsonutils.JSONObject,
+) error {
+	silence.SetEnabled(true)
+	silence.CreatedBy = userCred.GetUserName()
+	return silence.SMonitorScopedResource.CustomizeCreate(ctx, userCred, ownerId, query, data)
+}
+
+func (silence *SAlertSilence) ValidateUpdateData(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input monitor.AlertSilenceUpdateInput,
+) (monitor.AlertSilenceUpdateInput, error) {
+	var err error
+	input.StatusStandaloneResourceBaseUpdateInput, err = silence.SStatusStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StatusStandaloneResourceBaseUpdateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SStatusStandaloneResourceBase.ValidateUpdateData")
+	}
+	startTime, endTime := silence.StartTime, silence.EndTime
+	if input.StartTime != nil {
+		startTime = *input.StartTime
+	}
+	if input.EndTime != nil {
+		endTime = *input.EndTime
+	}
+	if !endTime.After(startTime) {
+		return input, httperrors.NewInputParameterError("end_time %s must be after start_time %s", endTime, startTime)
+	}
+	return input, nil
+}
+
+func (silence *SAlertSilence) AllowPerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) bool {
+	return db.IsProjectAllowPerform(userCred, silence, "enable")
+}
+
+func (silence *SAlertSilence) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(silence, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (silence *SAlertSilence) AllowPerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) bool {
+	return db.IsProjectAllowPerform(userCred, silence, "disable")
+}
+
+func (silence *SAlertSilence) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(silence, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (manager *SAlertSilenceManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []monitor.AlertSilenceDetails {
+	rows := make([]monitor.AlertSilenceDetails, len(objs))
+	stdRows := manager.SStatusStandaloneResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	scopedRows := manager.SScopedResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	now := time.Now()
+	for i := range rows {
+		rows[i] = monitor.AlertSilenceDetails{
+			StatusStandaloneResourceDetails: stdRows[i],
+			ScopedResourceBaseInfo:          scopedRows[i],
+		}
+		silence := objs[i].(*SAlertSilence)
+		rows[i].Active = silence.IsActive(now)
+		if len(silence.AlertId) != 0 {
+			alert, _ := AlertManager.GetAlert(silence.AlertId)
+			if alert != nil {
+				rows[i].AlertName = alert.GetName()
+			}
+		}
+	}
+	return rows
+}
+
+// GetActiveSilences returns the enabled silences of the alert in effect at now
+func (manager *SAlertSilenceManager) GetActiveSilences(alertId string, now time.Time) ([]SAlertSilence, error) {
+	alert, err := AlertManager.GetAlert(alertId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetAlert %s", alertId)
+	}
+	if alert == nil {
+		return nil, nil
+	}
+	q := manager.Query().IsTrue("enabled").LE("start_time", now).GT("end_time", now)
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.IsNullOrEmpty(q.Field("alert_id")),
+		sqlchemy.Equals(q.Field("alert_id"), alertId),
+	))
+	candidates := make([]SAlertSilence, 0)
+	if err := db.FetchModelObjects(manager, q, &candidates); err != nil {
+		return nil, errors.Wrapf(err, "fetch active silences of alert %s", alertId)
+	}
+	silences := make([]SAlertSilence, 0, len(candidates))
+	for i := range candidates {
+		if candidates[i].CoversOwner(alert.GetOwnerId()) {
+			silences = append(silences, candidates[i])
+		}
+	}
+	return silences, nil
+}
+
+// CoversOwner tells whether the silence covers the alerts of the owner,
+// a system silence covers all the alerts, a domain or project silence covers the alerts of its domain or project
+func (silence *SAlertSilence) CoversOwner(owner mcclient.IIdentityProvider) bool {
+	if len(silence.DomainId) == 0 {
+		return true
+	}
+	if owner == nil || silence.DomainId != owner.GetProjectDomainId() {
+		return false
+	}
+	return len(silence.ProjectId) == 0 || silence.ProjectId == owner.GetProjectId()
+}
+
+// SilenceEvalMatches marks the matches covered by an active silence with its id,
+// it returns the count of the silenced matches
+func (manager *SAlertSilenceManager) SilenceEvalMatches(alertId string, matches []*monitor.EvalMatch) (int, error) {
+	if len(matches) == 0 {
+		return 0, nil
+	}
+	silences, err := manager.GetActiveSilences(alertId, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, match := range matches {
+		match.SilenceId = ""
+		for i := range silences {
+			if silences[i].MatchEvalMatch(alertId, match) {
+				match.SilenceId = silences[i].Id
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+func (silence *SAlertSilence) IsActive(now time.Time) bool {
+	return silence.GetEnabled() && !now.Before(silence.StartTime) && now.Before(silence.EndTime)
+}
+
+func (silence *SAlertSilence) getResIds() []string {
+	ids := make([]string, 0)
+	if silence.ResIds != nil {
+		silence.ResIds.Unmarshal(&ids)
+	}
+	return ids
+}
+
+func (silence *SAlertSilence) getTags() map[string]string {
+	tags := make(map[string]string)
+	if silence.Tags != nil {
+		silence.Tags.Unmarshal(&tags)
+	}
+	return tags
+}
+
+// MatchEvalMatch checks the alert id, the tags and the resource ids of the match,
+// an empty matcher matches everything
+func (silence *SAlertSilence) MatchEvalMatch(alertId string, match *monitor.EvalMatch) bool {
+	if len(silence.AlertId) != 0 && silence.AlertId != alertId {
+		return false
+	}
+	for k, v := range silence.getTags() {
+		if match.Tags[k] != v {
+			return false
+		}
+	}
+	resIds := silence.getResIds()
+	if len(resIds) == 0 {
+		return true
+	}
+	for _, tagId := range monitor.MEASUREMENT_TAG_ID {
+		if id, ok := match.Tags[tagId]; ok && utils.IsInStringArray(id, resIds) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/tristate"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+func TestSAlertSilence_MatchEvalMatch(t *testing.T) {
+	match := &monitor.EvalMatch{
+		Tags: map[string]string{
+			"host_id": "host-1",
+			"vm_id":   "vm-1",
+			"brand":   "OneCloud",
+		},
+	}
+	tests := []struct {
+		name    string
+		silence *SAlertSilence
+		want    bool
+	}{
+		{
+			name:    "match all resources of the alert",
+			silence: &SAlertSilence{AlertId: "alert-1"},
+			want:    true,
+		},
+		{
+			name:    "other alert",
+			silence: &SAlertSilence{AlertId: "alert-2"},
+			want:    false,
+		},
+		{
+			name:    "guests on the host",
+			silence: &SAlertSilence{ResIds: jsonutils.Marshal([]string{"host-1"})},
+			want:    true,
+		},
+		{
+			name:    "other resource",
+			silence: &SAlertSilence{ResIds: jsonutils.Marshal([]string{"host-2"})},
+			want:    false,
+		},
+		{
+			name:    "tags matched",
+			silence: &SAlertSilence{Tags: jsonutils.Marshal(map[string]string{"brand": "OneCloud"})},
+			want:    true,
+		},
+		{
+			name: "tags not matched",
+			silence: &SAlertSilence{
+				ResIds: jsonutils.Marshal([]string{"vm-1"}),
+				Tags:   jsonutils.Marshal(map[string]string{"brand": "Aliyun"}),
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.MatchEvalMatch("alert-1", match); got != tt.want {
+				t.Errorf("MatchEvalMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSAlertSilence_IsActive(t *testing.T) {
+	now := time.Now()
+	silence := &SAlertSilence{
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now.Add(time.Hour),
+	}
+	if silence.IsActive(now) {
+		t.Errorf("disabled silence should not be active")
+	}
+	silence.Enabled = tristate.True
+	if !silence.IsActive(now) {
+		t.Errorf("silence should be active")
+	}
+	if silence.IsActive(now.Add(2 * time.Hour)) {
+		t.Errorf("expired silence should not be active")
+	}
+}
+
+func TestSAlertSilence_CoversOwner(t *testing.T) {
+	owner := &db.SOwnerId{DomainId: "domain-1", ProjectId: "project-1"}
+	tests := []struct {
+		name    string
+		silence *SAlertSilence
+		want    bool
+	}{
+		{
+			name:    "system silence",
+			silence: &SAlertSilence{},
+			want:    true,
+		},
+		{
+			name:    "domain silence",
+			silence: newScopedSilence("domain-1", ""),
+			want:    true,
+		},
+		{
+			name:    "other domain silence",
+			silence: newScopedSilence("domain-2", ""),
+			want:    false,
+		},
+		{
+			name:    "project silence",
+			silence: newScopedSilence("domain-1", "project-1"),
+			want:    true,
+		},
+		{
+			name:    "other project silence",
+			silence: newScopedSilence("domain-1", "project-2"),
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.CoversOwner(owner); got != tt.want {
+				t.Errorf("CoversOwner() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newScopedSilence(domainId, projectId string) *SAlertSilence {
+	silence := &SAlertSilence{}
+	silence.DomainId = domainId
+	silence.ProjectId = projectId
+	return silence
+}
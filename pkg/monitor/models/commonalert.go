@@ -168,12 +168,19 @@ func (man *SCommonAlertManager) ValidateCreateData(
 			if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
 				query.Comparator = "=="
 			}
-			if !utils.IsInStringArray(getQueryEvalType(query.Comparator), validators.EvaluatorDefaultTypes) {
-				return data, httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
+			if err := validateQueryEvaluator(query); err != nil {
+				return data, err
 			}
 			if _, ok := monitor.AlertReduceFunc[query.Reduce]; !ok {
 				return data, httperrors.NewInputParameterError("the reduce is illegal: %s", query.Reduce)
 			}
+			reducer := monitor.Condition{Type: query.Reduce, Params: query.ReduceParams}
+			if query.FieldOpt != "" {
+				reducer.Operators = []string{query.FieldOpt}
+			}
+			if err := validators.ValidateAlertConditionReducer(reducer); err != nil {
+				return data, err
+			}
 			/*if query.Threshold == 0 {
 				return data, httperrors.NewInputParameterError("threshold is meaningless")
 			}*/
@@ -682,6 +689,16 @@ func getCommonAlertMetricDetailsFromCondition(cond *monitor.AlertCondition,
 		cmp = "=="
 	case "lt":
 		cmp = "<="
+	default:
+		if utils.IsInStringArray(cond.Evaluator.Type, validators.EvaluatorBaselineTypes) {
+			cmp = cond.Evaluator.Type
+			if len(cond.Evaluator.Params) > 1 {
+				metricDetails.BaselineParams = cond.Evaluator.Params[1:]
+			}
+			if len(cond.Evaluator.Operators) > 0 {
+				metricDetails.BaselineDirection = cond.Evaluator.Operators[0]
+			}
+		}
 	}
 	metricDetails.Comparator = cmp
 
@@ -689,6 +706,7 @@ func getCommonAlertMetricDetailsFromCondition(cond *monitor.AlertCondition,
 		metricDetails.Threshold = cond.Evaluator.Params[0]
 	}
 	metricDetails.Reduce = cond.Reducer.Type
+	metricDetails.ReduceParams = cond.Reducer.Params
 
 	metricDetails.ConditionType = cond.Type
 	if metricDetails.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
@@ -799,10 +817,45 @@ func getQueryEvalType(evalType string) string {
 		typ = "lt"
 	case "==":
 		typ = "eq"
+	default:
+		if utils.IsInStringArray(evalType, validators.EvaluatorBaselineTypes) {
+			typ = evalType
+		}
 	}
 	return typ
 }
 
+// getQueryEvaluator builds the evaluator of a metric query, the threshold and
+// the baseline params are the params of a baseline evaluator
+func getQueryEvaluator(query *monitor.CommonAlertQuery) monitor.Condition {
+	typ := getQueryEvalType(query.Comparator)
+	if utils.IsInStringArray(typ, validators.EvaluatorBaselineTypes) {
+		evaluator := monitor.Condition{
+			Type:   typ,
+			Params: append([]float64{query.Threshold}, query.BaselineParams...),
+		}
+		if len(query.BaselineDirection) > 0 {
+			evaluator.Operators = []string{query.BaselineDirection}
+		}
+		return evaluator
+	}
+	return monitor.Condition{
+		Type:   typ,
+		Params: []float64{fieldOperatorThreshold(query.FieldOpt, query.Threshold)},
+	}
+}
+
+func validateQueryEvaluator(query *monitor.CommonAlertQuery) error {
+	typ := getQueryEvalType(query.Comparator)
+	if utils.IsInStringArray(typ, validators.EvaluatorDefaultTypes) {
+		return nil
+	}
+	if utils.IsInStringArray(typ, validators.EvaluatorBaselineTypes) {
+		return validators.ValidateAlertConditionBaselineEvaluator(getQueryEvaluator(query))
+	}
+	return httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
+}
+
 func (man *SCommonAlertManager) toAlertCreatInput(input monitor.CommonAlertCreateInput) monitor.AlertCreateInput {
 	freq, _ := time.ParseDuration(input.Period)
 	ret := new(monitor.AlertCreateInput)
@@ -819,12 +872,11 @@ func (man *SCommonAlertManager) toAlertCreatInput(input monitor.CommonAlertCreat
 			conditionType = metricquery.ConditionType
 		}
 		condition := monitor.AlertCondition{
-			Type:    conditionType,
-			Query:   *metricquery.AlertQuery,
-			Reducer: monitor.Condition{Type: metricquery.Reduce},
-			Evaluator: monitor.Condition{Type: getQueryEvalType(metricquery.Comparator),
-				Params: []float64{fieldOperatorThreshold(metricquery.FieldOpt, metricquery.Threshold)}},
-			Operator: "and",
+			Type:      conditionType,
+			Query:     *metricquery.AlertQuery,
+			Reducer:   monitor.Condition{Type: metricquery.Reduce, Params: metricquery.ReduceParams},
+			Evaluator: getQueryEvaluator(metricquery),
+			Operator:  "and",
 		}
 		if metricquery.FieldOpt != "" {
 			condition.Reducer.Operators = []string{metricquery.FieldOpt}
@@ -898,12 +950,19 @@ func (alert *SCommonAlert) ValidateUpdateData(
 			if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
 				query.Comparator = "=="
 			}
-			if !utils.IsInStringArray(getQueryEvalType(query.Comparator), validators.EvaluatorDefaultTypes) {
-				return data, httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
+			if err := validateQueryEvaluator(query); err != nil {
+				return data, err
 			}
 			if _, ok := monitor.AlertReduceFunc[query.Reduce]; !ok {
 				return data, httperrors.NewInputParameterError("the reduce is illegal: %s", query.Reduce)
 			}
+			reducer := monitor.Condition{Type: query.Reduce, Params: query.ReduceParams}
+			if query.FieldOpt != "" {
+				reducer.Operators = []string{query.FieldOpt}
+			}
+			if err := validators.ValidateAlertConditionReducer(reducer); err != nil {
+				return data, err
+			}
 			/*if query.Threshold == 0 {
 				return data, httperrors.NewInputParameterError("threshold is meaningless")
 			}*/
@@ -0,0 +1,104 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/validators"
+)
+
+func TestCommonAlertBaselineQuery(t *testing.T) {
+	newQuery := func(comparator string, threshold float64, params []float64, direction string) *monitor.CommonAlertQuery {
+		return &monitor.CommonAlertQuery{
+			AlertQuery: &monitor.AlertQuery{
+				Model: monitor.MetricQuery{Measurement: "cpu"},
+			},
+			Reduce:            "avg",
+			Comparator:        comparator,
+			Threshold:         threshold,
+			BaselineParams:    params,
+			BaselineDirection: direction,
+		}
+	}
+	cases := []struct {
+		name      string
+		query     *monitor.CommonAlertQuery
+		wantErr   bool
+		evaluator monitor.Condition
+	}{
+		{
+			name:      "threshold",
+			query:     newQuery(">=", 80, nil, ""),
+			evaluator: monitor.Condition{Type: "gt", Params: []float64{80}},
+		},
+		{
+			name:      "stddev above",
+			query:     newQuery("baseline_stddev", 3, []float64{12}, "above"),
+			evaluator: monitor.Condition{Type: "baseline_stddev", Params: []float64{3, 12}, Operators: []string{"above"}},
+		},
+		{
+			name:      "history",
+			query:     newQuery("baseline_history", 7, []float64{20}, ""),
+			evaluator: monitor.Condition{Type: "baseline_history", Params: []float64{7, 20}},
+		},
+		{
+			name:    "stddev without k",
+			query:   newQuery("baseline_stddev", 0, nil, ""),
+			wantErr: true,
+		},
+		{
+			name:    "unknown direction",
+			query:   newQuery("baseline_history", 7, nil, "sideways"),
+			wantErr: true,
+		},
+		{
+			name:    "unknown comparator",
+			query:   newQuery("baseline_mean", 7, nil, ""),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		err := validateQueryEvaluator(c.query)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		input := CommonAlertManager.toAlertCreatInput(monitor.CommonAlertCreateInput{
+			CommonMetricInputQuery: monitor.CommonMetricInputQuery{
+				MetricQuery: []*monitor.CommonAlertQuery{c.query},
+			},
+			Period: "5m",
+		})
+		if len(input.Settings.Conditions) != 1 {
+			t.Fatalf("%s: want 1 condition, got %d", c.name, len(input.Settings.Conditions))
+		}
+		cond := input.Settings.Conditions[0]
+		if !reflect.DeepEqual(cond.Evaluator, c.evaluator) {
+			t.Errorf("%s: want evaluator %#v, got %#v", c.name, c.evaluator, cond.Evaluator)
+		}
+		if err := validators.ValidateAlertConditionEvaluator(cond.Evaluator); err != nil {
+			t.Errorf("%s: evaluator of the created alert: %v", c.name, err)
+		}
+	}
+}
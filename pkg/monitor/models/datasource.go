@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"net/url"
 	"regexp"
 	"sort"
 	"strings"
@@ -38,6 +39,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/hostman/hostinfo/hostconsts"
 	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	merrors "yunion.io/x/onecloud/pkg/monitor/errors"
 	"yunion.io/x/onecloud/pkg/monitor/options"
@@ -148,11 +150,11 @@ func (man *SDataSourceManager) GetDefaultSource() (*SDataSource, error) {
 type SDataSource struct {
 	db.SStandaloneResourceBase
 
-	Type      string            `nullable:"false" list:"user"`
-	Url       string            `nullable:"false" list:"user"`
-	User      string            `width:"64" charset:"utf8" nullable:"true"`
-	Password  string            `width:"64" charset:"utf8" nullable:"true"`
-	Database  string            `width:"64" charset:"utf8" nullable:"true"`
+	Type      string            `nullable:"false" list:"user" create:"required"`
+	Url       string            `nullable:"false" list:"user" create:"required"`
+	User      string            `width:"64" charset:"utf8" nullable:"true" create:"optional"`
+	Password  string            `width:"64" charset:"utf8" nullable:"true" create:"optional"`
+	Database  string            `width:"64" charset:"utf8" nullable:"true" create:"optional"`
 	IsDefault tristate.TriState `nullable:"false" default:"false" create:"optional"`
 	/*
 		TimeInterval string
@@ -162,6 +164,28 @@ type SDataSource struct {
 	*/
 }
 
+func (man *SDataSourceManager) ValidateCreateData(
+	ctx context.Context, userCred mcclient.TokenCredential,
+	ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject,
+	data monitor.DataSourceCreateInput) (monitor.DataSourceCreateInput, error) {
+	if !utils.IsInStringArray(data.Type, []string{monitor.DataSourceTypeInfluxdb, monitor.DataSourceTypePrometheus}) ||
+		!tsdb.IsTsdbQueryEndpointRegistered(data.Type) {
+		return data, httperrors.NewInputParameterError("unsupported datasource type %q", data.Type)
+	}
+	if len(data.Url) == 0 {
+		return data, httperrors.NewMissingParameterError("url")
+	}
+	if _, err := url.Parse(data.Url); err != nil {
+		return data, httperrors.NewInputParameterError("invalid url %q: %v", data.Url, err)
+	}
+	var err error
+	data.StandaloneResourceCreateInput, err = man.SStandaloneResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, data.StandaloneResourceCreateInput)
+	if err != nil {
+		return data, errors.Wrap(err, "SStandaloneResourceBaseManager.ValidateCreateData")
+	}
+	return data, nil
+}
+
 func (m *SDataSourceManager) GetSource(id string) (*SDataSource, error) {
 	ret, err := m.FetchById(id)
 	if err != nil {
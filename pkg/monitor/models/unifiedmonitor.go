@@ -399,6 +399,10 @@ func setDefaultValue(query *monitor.AlertQuery, inputQuery *monitor.MetricInputQ
 }
 
 func setDataSourceId(query *monitor.AlertQuery) {
+	if len(query.DataSourceId) > 0 {
+		// keep the datasource chosen by the query, e.g. a prometheus one
+		return
+	}
 	datasource, _ := DataSourceManager.GetDefaultSource()
 	query.DataSourceId = datasource.Id
 }
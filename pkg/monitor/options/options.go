@@ -30,6 +30,10 @@ type AlerterOptions struct {
 	InitScopeSuggestConfigIntervalSeconds          int   `help:"internal to init scope suggest configs" default:"900"`
 	InitAlertResourceAdminRoleUsersIntervalSeconds int   `help:"internal to init alert resource admin role users " default:"3600"`
 	MonitorResourceSyncIntervalSeconds             int   `help:"internal to sync monitor resource,unit: h " default:"1"`
+
+	AlertingGroupBy              []string `help:"labels to group the alert notifications by, e.g. alert_name, level or tags like brand, empty to disable grouping"`
+	AlertingGroupWaitSeconds     int64    `help:"time to wait for more alerts of a new group before sending the notification" default:"30"`
+	AlertingGroupIntervalSeconds int64    `help:"time to wait before sending the next notification of a group" default:"300"`
 }
 
 var (
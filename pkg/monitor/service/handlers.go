@@ -59,6 +59,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.MetricMeasurementManager,
 		models.MetricFieldManager,
 		models.AlertRecordManager,
+		models.AlertSilenceManager,
 		models.AlertDashBoardManager,
 		models.GetAlertResourceManager(),
 		models.AlertPanelManager,
@@ -115,6 +116,8 @@ func InitInfluxDBSubscriptionHandlers(app *appsrv.Application, options *common_o
 	}
 }
 
+const prometheusMetricsPath = "/prometheus/metrics"
+
 func addMiscHandlers(root *mux.Router) {
 	adapterF := func(appHandleFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -128,6 +131,9 @@ func addMiscHandlers(root *mux.Router) {
 	root.HandleFunc("/stats", adapterF(appsrv.StatisticHandler))
 	root.HandleFunc("/ping", adapterF(appsrv.PingHandler))
 	root.HandleFunc("/worker_stats", adapterF(appsrv.WorkerStatsHandler))
+	// /metrics is the list of models.MetricManager, the prometheus metrics
+	// of monitor are served at /prometheus/metrics instead
+	root.HandleFunc(prometheusMetricsPath, adapterF(appsrv.MetricsHandler))
 
 	// pprof handler
 	root.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
@@ -0,0 +1,45 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAddMiscHandlers_PrometheusMetrics(t *testing.T) {
+	root := mux.NewRouter()
+	addMiscHandlers(root)
+
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, httptest.NewRequest("GET", prometheusMetricsPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want status 200 got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "go_goroutines") {
+		t.Errorf("want the prometheus metrics, got %s", w.Body.String())
+	}
+
+	// /metrics is left to the list of the metrics
+	w = httptest.NewRecorder()
+	root.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("/metrics should not be served by the misc handlers, got status %d", w.Code)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"time"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+)
+
+type Query struct {
+	Measurement string
+	Tags        []api.MetricQueryTag
+	// GroupBy is the tag keys the series are aggregated by
+	GroupBy []string
+	// GroupByAll keeps every series untouched, same as influxdb GROUP BY *
+	GroupByAll bool
+	Selects    []*Select
+	Alias      string
+	Interval   time.Duration
+}
+
+type Select struct {
+	Field    string
+	Function string
+	Params   []string
+	Math     string
+	Alias    string
+}
+
+// Response is the body of prometheus http api /api/v1/query_range
+type Response struct {
+	Status    string       `json:"status"`
+	Data      ResponseData `json:"data"`
+	ErrorType string       `json:"errorType"`
+	Error     string       `json:"error"`
+}
+
+type ResponseData struct {
+	ResultType string   `json:"resultType"`
+	Result     []Sample `json:"result"`
+}
+
+type Sample struct {
+	Metric map[string]string `json:"metric"`
+	// Values is pairs of [ <unix_time>, "<sample_value>" ]
+	Values [][]interface{} `json:"values"`
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/moul/http2curl"
+	"golang.org/x/net/context/ctxhttp"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+const (
+	ErrPrometheusInvalidResponse = errors.Error("Prometheus invalid response")
+	ErrPrometheusInvalidQuery    = errors.Error("Prometheus invalid query")
+	ErrPrometheusUnsupported     = errors.Error("Prometheus unsupported query")
+)
+
+func init() {
+	tsdb.RegisterTsdbQueryEndpoint(api.DataSourceTypePrometheus, NewPrometheusExecutor)
+}
+
+// PrometheusExecutor queries prometheus compatible storages, e.g. VictoriaMetrics,
+// through the range query http api
+type PrometheusExecutor struct {
+	QueryParser    *PrometheusQueryParser
+	ResponseParser *ResponseParser
+}
+
+func NewPrometheusExecutor(datasource *tsdb.DataSource) (tsdb.TsdbQueryEndpoint, error) {
+	return &PrometheusExecutor{
+		QueryParser:    &PrometheusQueryParser{},
+		ResponseParser: &ResponseParser{},
+	}, nil
+}
+
+func (e *PrometheusExecutor) Query(ctx context.Context, dsInfo *tsdb.DataSource, tsdbQuery *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	if len(tsdbQuery.Queries) == 0 {
+		return nil, errors.Error("query request contains no queries")
+	}
+	httpClient, err := dsInfo.GetHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &tsdb.Response{
+		Results: make(map[string]*tsdb.QueryResult),
+	}
+	for _, q := range tsdbQuery.Queries {
+		query, err := e.QueryParser.Parse(q, dsInfo)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse query %s", q.RefId)
+		}
+		exprs, interval, err := query.Build(tsdbQuery)
+		if err != nil {
+			return nil, errors.Wrapf(err, "build query %s", q.RefId)
+		}
+		responses := make([]*Response, 0, len(exprs))
+		for _, expr := range exprs {
+			req, err := e.createRequest(dsInfo, tsdbQuery.TimeRange, expr, interval)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := e.doRequest(ctx, httpClient, req)
+			if err != nil {
+				return nil, errors.Wrapf(err, "query %q", expr)
+			}
+			responses = append(responses, resp)
+		}
+		ret, err := e.ResponseParser.Parse(responses, query)
+		if err != nil {
+			return nil, err
+		}
+		ret.RefId = q.RefId
+		ret.Meta = tsdb.QueryResultMeta{
+			RawQuery: strings.Join(exprs, "; "),
+		}
+		result.Results[q.RefId] = ret
+	}
+	return result, nil
+}
+
+func (e *PrometheusExecutor) doRequest(ctx context.Context, httpClient *http.Client, req *http.Request) (*Response, error) {
+	resp, err := ctxhttp.Do(ctx, httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &Response{}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	decErr := dec.Decode(response)
+	if resp.StatusCode/100 != 2 {
+		if decErr == nil && len(response.Error) > 0 {
+			return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "status code: %v, %s: %s", resp.Status, response.ErrorType, response.Error)
+		}
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "status code: %v", resp.Status)
+	}
+	if decErr != nil {
+		return nil, errors.Wrap(decErr, "decode response")
+	}
+	if response.Status != "success" {
+		return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "%s: %s", response.ErrorType, response.Error)
+	}
+	return response, nil
+}
+
+func (e *PrometheusExecutor) createRequest(dsInfo *tsdb.DataSource, timeRange *tsdb.TimeRange, expr string, interval tsdb.Interval) (*http.Request, error) {
+	u, err := url.Parse(dsInfo.Url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid datasource url %q", dsInfo.Url)
+	}
+	u.Path = path.Join(u.Path, "api/v1/query_range")
+
+	step := interval.Milliseconds()
+	if step < 1000 {
+		step = 1000
+	}
+	// align the range to the step, so the points of successive evaluations line up
+	start := timeRange.GetFromAsMsEpoch() / step * step
+	end := timeRange.GetToAsMsEpoch() / step * step
+
+	bodyValues := url.Values{}
+	bodyValues.Set("query", expr)
+	bodyValues.Set("start", msToSeconds(start))
+	bodyValues.Set("end", msToSeconds(end))
+	bodyValues.Set("step", msToSeconds(step))
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(bodyValues.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "OneCloud Monitor")
+	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
+	if len(dsInfo.User) > 0 {
+		req.SetBasicAuth(dsInfo.User, dsInfo.Password)
+	}
+
+	curlCmd, _ := http2curl.GetCurlCommand(req)
+	log.Debugf("Prometheus query: %q, curl: %s", expr, curlCmd)
+	return req, nil
+}
+
+func msToSeconds(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
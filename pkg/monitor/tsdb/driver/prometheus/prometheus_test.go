@@ -0,0 +1,170 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+func newTestQuery() *tsdb.Query {
+	return &tsdb.Query{
+		RefId: "A",
+		MetricQuery: api.MetricQuery{
+			Measurement: "cpu",
+			Tags: []api.MetricQueryTag{
+				{Key: "host", Operator: "=", Value: "node-1"},
+				{Key: "cpu", Value: "/cpu[0-9]+/", Condition: "and"},
+			},
+			GroupBy: []api.MetricQueryPart{
+				{Type: "time", Params: []string{"1m"}},
+				{Type: "tag", Params: []string{"host_id"}},
+				{Type: "fill", Params: []string{"none"}},
+			},
+			Selects: []api.MetricQuerySelect{
+				{
+					{Type: "field", Params: []string{"usage_active"}},
+					{Type: "mean"},
+				},
+				{
+					{Type: "field", Params: []string{"usage_idle"}},
+					{Type: "max"},
+					{Type: "alias", Params: []string{"idle"}},
+				},
+			},
+		},
+	}
+}
+
+func TestPrometheusQueryBuild(t *testing.T) {
+	Convey("Prometheus query builder", t, func() {
+		parser := &PrometheusQueryParser{}
+		tsdbQuery := &tsdb.TsdbQuery{TimeRange: tsdb.NewTimeRange("1h", "now")}
+
+		Convey("Render selects with tags and group by", func() {
+			query, err := parser.Parse(newTestQuery(), &tsdb.DataSource{})
+			So(err, ShouldBeNil)
+			exprs, interval, err := query.Build(tsdbQuery)
+			So(err, ShouldBeNil)
+			So(interval.Text, ShouldEqual, "1m")
+			So(exprs, ShouldResemble, []string{
+				`avg by (host_id) (avg_over_time(cpu_usage_active{host="node-1",cpu=~"cpu[0-9]+"}[1m]))`,
+				`max by (host_id) (max_over_time(cpu_usage_idle{host="node-1",cpu=~"cpu[0-9]+"}[1m]))`,
+			})
+		})
+
+		Convey("Group by * keeps every series", func() {
+			q := newTestQuery()
+			q.GroupBy = []api.MetricQueryPart{{Type: "field", Params: []string{"*"}}}
+			q.Selects = []api.MetricQuerySelect{{
+				{Type: "field", Params: []string{"usage_active"}},
+				{Type: "percentile", Params: []string{"95"}},
+				{Type: "math", Params: []string{"/ 100"}},
+			}}
+			q.Tags = nil
+			query, err := parser.Parse(q, &tsdb.DataSource{})
+			So(err, ShouldBeNil)
+			exprs, _, err := query.Build(tsdbQuery)
+			So(err, ShouldBeNil)
+			So(exprs, ShouldResemble, []string{`(quantile_over_time(0.95, cpu_usage_active[2s])) / 100`})
+		})
+
+		Convey("Unsupported conditions", func() {
+			q := newTestQuery()
+			q.Tags[1].Condition = "OR"
+			query, err := parser.Parse(q, &tsdb.DataSource{})
+			So(err, ShouldBeNil)
+			_, _, err = query.Build(tsdbQuery)
+			So(err, ShouldNotBeNil)
+
+			q = newTestQuery()
+			q.Selects[0][1].Type = "holt_winters"
+			_, err = parser.Parse(q, &tsdb.DataSource{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+const testMatrixResponse = `{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {"metric": {"host_id": "h1"}, "values": [[1600000000, "10"], [1600000060, "20.5"]]},
+      {"metric": {"host_id": "h2"}, "values": [[1600000000, "NaN"]]}
+    ]
+  }
+}`
+
+func TestPrometheusExecutor(t *testing.T) {
+	Convey("Query prometheus range api", t, func() {
+		var forms []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/query_range" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			r.ParseForm()
+			forms = append(forms, r.Form.Get("query"))
+			if r.Form.Get("step") != "60" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"unexpected step"}`))
+				return
+			}
+			w.Write([]byte(testMatrixResponse))
+		}))
+		defer server.Close()
+
+		ds := &tsdb.DataSource{Type: api.DataSourceTypePrometheus, Url: server.URL}
+		tsdbQuery := &tsdb.TsdbQuery{
+			TimeRange: tsdb.NewTimeRange("1h", "now"),
+			Queries:   []*tsdb.Query{newTestQuery()},
+		}
+		resp, err := tsdb.HandleRequest(context.Background(), ds, tsdbQuery)
+		So(err, ShouldBeNil)
+		So(len(forms), ShouldEqual, 2)
+
+		ret := resp.Results["A"]
+		So(ret, ShouldNotBeNil)
+		So(len(ret.Series), ShouldEqual, 2)
+
+		serie := ret.Series[0]
+		So(serie.Name, ShouldEqual, "cpu.mean-idle")
+		So(serie.Columns, ShouldResemble, []string{"mean", "idle", "time"})
+		So(serie.Tags["host_id"], ShouldEqual, "h1")
+		So(len(serie.Points), ShouldEqual, 2)
+		So(serie.Points[0].Values(), ShouldResemble, []float64{10, 10})
+		So(serie.Points[1].Timestamp(), ShouldEqual, 1600000060000)
+
+		// NaN is a missing value
+		So(ret.Series[1].Points[0].IsValid(), ShouldBeFalse)
+
+		Convey("Error response", func() {
+			q := newTestQuery()
+			q.GroupBy[0].Params = []string{"5m"}
+			tsdbQuery.Queries = []*tsdb.Query{q}
+			_, err := tsdb.HandleRequest(context.Background(), ds, tsdbQuery)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unexpected step")
+		})
+	})
+}
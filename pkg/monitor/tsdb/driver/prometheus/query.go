@@ -0,0 +1,178 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+type rangeFunction struct {
+	// function applied on the range vector of each series
+	overTime string
+	// aggregation operator merging the series, like influxdb does without GROUP BY *
+	aggregator string
+}
+
+var (
+	rangeFunctions = map[string]rangeFunction{
+		"mean":                    {overTime: "avg_over_time", aggregator: "avg"},
+		"sum":                     {overTime: "sum_over_time", aggregator: "sum"},
+		"max":                     {overTime: "max_over_time", aggregator: "max"},
+		"min":                     {overTime: "min_over_time", aggregator: "min"},
+		"count":                   {overTime: "count_over_time", aggregator: "sum"},
+		"last":                    {overTime: "last_over_time", aggregator: "avg"},
+		"median":                  {overTime: "quantile_over_time", aggregator: "avg"},
+		"percentile":              {overTime: "quantile_over_time", aggregator: "avg"},
+		"stddev":                  {overTime: "stddev_over_time", aggregator: "avg"},
+		"derivative":              {overTime: "deriv", aggregator: "avg"},
+		"non_negative_derivative": {overTime: "rate", aggregator: "avg"},
+	}
+
+	regexpOperatorPattern = regexp.MustCompile(`^\/.*\/$`)
+	invalidMetricChars    = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	invalidLabelChars     = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// MetricName follows the naming of telegraf prometheus output: <measurement>_<field>
+func MetricName(measurement, field string) string {
+	return invalidMetricChars.ReplaceAllString(measurement+"_"+field, "_")
+}
+
+func labelName(key string) string {
+	return invalidLabelChars.ReplaceAllString(key, "_")
+}
+
+// Build renders one PromQL expression per select and the step of the range query
+func (query *Query) Build(queryCtx *tsdb.TsdbQuery) ([]string, tsdb.Interval, error) {
+	calculator := tsdb.NewIntervalCalculator(&tsdb.IntervalOptions{})
+	interval := calculator.Calculate(queryCtx.TimeRange, query.Interval)
+
+	matchers, err := query.renderMatchers()
+	if err != nil {
+		return nil, interval, err
+	}
+	exprs := make([]string, 0, len(query.Selects))
+	for _, sel := range query.Selects {
+		expr, err := query.renderSelect(sel, matchers, interval)
+		if err != nil {
+			return nil, interval, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, interval, nil
+}
+
+func (query *Query) renderMatchers() (string, error) {
+	matchers := make([]string, 0, len(query.Tags))
+	for i, tag := range query.Tags {
+		if i > 0 && len(tag.Condition) > 0 && strings.ToLower(tag.Condition) != "and" {
+			return "", errors.Wrapf(ErrPrometheusUnsupported, "tag condition %s", tag.Condition)
+		}
+		op := tag.Operator
+		if op == "" {
+			if regexpOperatorPattern.MatchString(tag.Value) {
+				op = "=~"
+			} else {
+				op = "="
+			}
+		}
+		value := tag.Value
+		switch op {
+		case "=", "!=":
+		case "=~", "!~":
+			if regexpOperatorPattern.MatchString(value) {
+				value = value[1 : len(value)-1]
+			}
+		case "<>":
+			op = "!="
+		default:
+			return "", errors.Wrapf(ErrPrometheusUnsupported, "tag operator %s", op)
+		}
+		matchers = append(matchers, fmt.Sprintf("%s%s%s", labelName(tag.Key), op, strconv.Quote(value)))
+	}
+	return strings.Join(matchers, ","), nil
+}
+
+func (query *Query) renderSelect(sel *Select, matchers string, interval tsdb.Interval) (string, error) {
+	expr := MetricName(query.Measurement, sel.Field)
+	if len(matchers) > 0 {
+		expr = fmt.Sprintf("%s{%s}", expr, matchers)
+	}
+	if len(sel.Function) > 0 {
+		fn := rangeFunctions[sel.Function]
+		rangeExpr := fmt.Sprintf("%s[%s]", expr, interval.Text)
+		switch sel.Function {
+		case "median":
+			expr = fmt.Sprintf("%s(0.5, %s)", fn.overTime, rangeExpr)
+		case "percentile":
+			if len(sel.Params) == 0 {
+				return "", errors.Wrap(ErrPrometheusInvalidQuery, "percentile without parameter")
+			}
+			p, err := strconv.ParseFloat(sel.Params[0], 64)
+			if err != nil {
+				return "", errors.Wrapf(ErrPrometheusInvalidQuery, "invalid percentile %q", sel.Params[0])
+			}
+			expr = fmt.Sprintf("%s(%s, %s)", fn.overTime, strconv.FormatFloat(p/100, 'f', -1, 64), rangeExpr)
+		case "derivative", "non_negative_derivative":
+			expr = fmt.Sprintf("%s(%s)", fn.overTime, rangeExpr)
+			// influxdb derivative is per unit, default 1s which is what prometheus returns
+			if len(sel.Params) > 0 && !strings.HasPrefix(sel.Params[0], "$") {
+				unit, err := time.ParseDuration(sel.Params[0])
+				if err != nil {
+					return "", errors.Wrapf(ErrPrometheusInvalidQuery, "invalid derivative unit %q", sel.Params[0])
+				}
+				if unit != time.Second {
+					expr = fmt.Sprintf("%s * %s", expr, strconv.FormatFloat(unit.Seconds(), 'f', -1, 64))
+				}
+			}
+		default:
+			expr = fmt.Sprintf("%s(%s)", fn.overTime, rangeExpr)
+		}
+		if !query.GroupByAll {
+			by := ""
+			if len(query.GroupBy) > 0 {
+				keys := make([]string, len(query.GroupBy))
+				for i := range query.GroupBy {
+					keys[i] = labelName(query.GroupBy[i])
+				}
+				by = fmt.Sprintf(" by (%s)", strings.Join(keys, ", "))
+			}
+			expr = fmt.Sprintf("%s%s (%s)", fn.aggregator, by, expr)
+		}
+	}
+	if len(sel.Math) > 0 {
+		expr = fmt.Sprintf("(%s) %s", expr, sel.Math)
+	}
+	return expr, nil
+}
+
+// column is the name of the select in the result, same as the influxdb column name
+func (sel *Select) column() string {
+	if len(sel.Alias) > 0 {
+		return sel.Alias
+	}
+	if len(sel.Function) > 0 {
+		return sel.Function
+	}
+	return sel.Field
+}
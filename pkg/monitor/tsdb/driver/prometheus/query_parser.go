@@ -0,0 +1,124 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+type PrometheusQueryParser struct{}
+
+func (qp *PrometheusQueryParser) Parse(model *tsdb.Query, dsInfo *tsdb.DataSource) (*Query, error) {
+	if len(model.Measurement) == 0 {
+		return nil, errors.Wrap(ErrPrometheusInvalidQuery, "empty measurement")
+	}
+	query := &Query{
+		Measurement: model.Measurement,
+		Tags:        model.Tags,
+		Alias:       model.Alias,
+	}
+
+	interval, err := tsdb.GetIntervalFrom(dsInfo, model, time.Millisecond*1)
+	if err != nil {
+		return nil, err
+	}
+	query.Interval = interval
+
+	for _, gb := range model.GroupBy {
+		if err := qp.parseGroupBy(query, gb); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, sel := range model.Selects {
+		s, err := qp.parseSelect(sel)
+		if err != nil {
+			return nil, err
+		}
+		query.Selects = append(query.Selects, s)
+	}
+	if len(query.Selects) == 0 {
+		return nil, errors.Wrap(ErrPrometheusInvalidQuery, "no select")
+	}
+	return query, nil
+}
+
+func (qp *PrometheusQueryParser) parseGroupBy(query *Query, part api.MetricQueryPart) error {
+	switch part.Type {
+	case "time":
+		if len(part.Params) > 0 && !strings.HasPrefix(part.Params[0], "$") {
+			interval, err := time.ParseDuration(part.Params[0])
+			if err != nil {
+				return errors.Wrapf(ErrPrometheusInvalidQuery, "invalid group by time %q", part.Params[0])
+			}
+			if interval > query.Interval {
+				query.Interval = interval
+			}
+		}
+	case "fill":
+		// prometheus never fills the missing points
+	case "tag", "field":
+		for _, key := range part.Params {
+			if key == "*" {
+				query.GroupByAll = true
+				continue
+			}
+			query.GroupBy = append(query.GroupBy, key)
+		}
+	default:
+		return errors.Wrapf(ErrPrometheusUnsupported, "group by %s", part.Type)
+	}
+	return nil
+}
+
+func (qp *PrometheusQueryParser) parseSelect(parts api.MetricQuerySelect) (*Select, error) {
+	sel := &Select{}
+	for _, part := range parts {
+		switch part.Type {
+		case "field":
+			if len(part.Params) == 0 {
+				return nil, errors.Wrap(ErrPrometheusInvalidQuery, "field without name")
+			}
+			sel.Field = part.Params[0]
+		case "alias":
+			if len(part.Params) > 0 {
+				sel.Alias = part.Params[0]
+			}
+		case "math":
+			if len(part.Params) > 0 {
+				sel.Math = part.Params[0]
+			}
+		default:
+			if _, ok := rangeFunctions[part.Type]; !ok {
+				return nil, errors.Wrapf(ErrPrometheusUnsupported, "function %s", part.Type)
+			}
+			if len(sel.Function) > 0 {
+				return nil, errors.Wrapf(ErrPrometheusUnsupported, "nested function %s(%s)", part.Type, sel.Function)
+			}
+			sel.Function = part.Type
+			sel.Params = part.Params
+		}
+	}
+	if len(sel.Field) == 0 {
+		return nil, errors.Wrap(ErrPrometheusInvalidQuery, "select without field")
+	}
+	return sel, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+var (
+	legendFormat = regexp.MustCompile(`\[\[(\w+)(\.\w+)*\]\]*|\$\s*(\w+?)*`)
+)
+
+type ResponseParser struct{}
+
+// sSerie collects the values of every select of the same label set
+type sSerie struct {
+	tags   map[string]string
+	values map[float64][]*float64
+}
+
+// Parse merges the matrix results of every select into influxdb like
+// series: one column per select, the points are [values..., timestamp]
+func (rp *ResponseParser) Parse(responses []*Response, query *Query) (*tsdb.QueryResult, error) {
+	queryRes := tsdb.NewQueryResult()
+
+	series := make(map[string]*sSerie)
+	keys := make([]string, 0)
+	for i, resp := range responses {
+		if resp.Data.ResultType != "matrix" {
+			return nil, errors.Wrapf(ErrPrometheusInvalidResponse, "unexpected result type %q", resp.Data.ResultType)
+		}
+		for _, sample := range resp.Data.Result {
+			tags := make(map[string]string)
+			for k, v := range sample.Metric {
+				if k == "__name__" {
+					continue
+				}
+				tags[k] = v
+			}
+			key := rp.seriesKey(tags)
+			serie, ok := series[key]
+			if !ok {
+				serie = &sSerie{tags: tags, values: make(map[float64][]*float64)}
+				series[key] = serie
+				keys = append(keys, key)
+			}
+			for _, pair := range sample.Values {
+				timestamp, value, err := rp.parseSamplePair(pair)
+				if err != nil {
+					return nil, err
+				}
+				vals, ok := serie.values[timestamp]
+				if !ok {
+					vals = make([]*float64, len(responses))
+					serie.values[timestamp] = vals
+				}
+				vals[i] = value
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(query.Selects)+1)
+	for _, sel := range query.Selects {
+		columns = append(columns, sel.column())
+	}
+	col := strings.Join(columns, "-")
+	columns = append(columns, "time")
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		serie := series[key]
+		timestamps := make([]float64, 0, len(serie.values))
+		for ts := range serie.values {
+			timestamps = append(timestamps, ts)
+		}
+		sort.Float64s(timestamps)
+		points := make(tsdb.TimeSeriesPoints, 0, len(timestamps))
+		for _, ts := range timestamps {
+			point := make(tsdb.TimePoint, 0, len(columns))
+			for _, v := range serie.values[ts] {
+				if v == nil {
+					// missing value is an untyped nil, same as influxdb null
+					point = append(point, nil)
+					continue
+				}
+				point = append(point, v)
+			}
+			points = append(points, append(point, ts))
+		}
+		queryRes.Series = append(queryRes.Series, &tsdb.TimeSeries{
+			Name:    rp.formatSerieName(serie.tags, col, query),
+			Columns: columns,
+			Points:  points,
+			Tags:    serie.tags,
+		})
+	}
+	return queryRes, nil
+}
+
+func (rp *ResponseParser) seriesKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%q", k, tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseSamplePair returns the timestamp in milliseconds and the value,
+// NaN and Inf are treated as missing values
+func (rp *ResponseParser) parseSamplePair(pair []interface{}) (float64, *float64, error) {
+	if len(pair) != 2 {
+		return 0, nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid sample %v", pair)
+	}
+	var timestamp float64
+	switch ts := pair[0].(type) {
+	case json.Number:
+		sec, err := ts.Float64()
+		if err != nil {
+			return 0, nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid timestamp %s", ts)
+		}
+		timestamp = math.Round(sec * 1000)
+	case float64:
+		timestamp = math.Round(ts * 1000)
+	default:
+		return 0, nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid timestamp %v", pair[0])
+	}
+	str, ok := pair[1].(string)
+	if !ok {
+		return 0, nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid sample value %v", pair[1])
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, nil, errors.Wrapf(ErrPrometheusInvalidResponse, "invalid sample value %q", str)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return timestamp, nil, nil
+	}
+	return timestamp, &value, nil
+}
+
+func (rp *ResponseParser) formatSerieName(tags map[string]string, column string, query *Query) string {
+	if query.Alias == "" {
+		return fmt.Sprintf("%s.%s", query.Measurement, column)
+	}
+
+	result := legendFormat.ReplaceAllFunc([]byte(query.Alias), func(in []byte) []byte {
+		aliasFormat := string(in)
+		aliasFormat = strings.Replace(aliasFormat, "[[", "", 1)
+		aliasFormat = strings.Replace(aliasFormat, "]]", "", 1)
+		aliasFormat = strings.Replace(aliasFormat, "$", "", 1)
+
+		if aliasFormat == "m" || aliasFormat == "measurement" {
+			return []byte(query.Measurement)
+		}
+		if aliasFormat == "col" {
+			return []byte(column)
+		}
+		if !strings.HasPrefix(aliasFormat, "tag_") {
+			return in
+		}
+		tagValue, exist := tags[strings.Replace(aliasFormat, "tag_", "", 1)]
+		if exist {
+			return []byte(tagValue)
+		}
+		return in
+	})
+
+	return string(result)
+}
@@ -50,3 +50,8 @@ func getTsdbQueryEndpointFor(dsInfo *DataSource) (TsdbQueryEndpoint, error) {
 func RegisterTsdbQueryEndpoint(dataSourceType string, fn GetTsdbQueryEndpointFn) {
 	registry[dataSourceType] = fn
 }
+
+func IsTsdbQueryEndpointRegistered(dataSourceType string) bool {
+	_, exists := registry[dataSourceType]
+	return exists
+}
@@ -37,11 +37,23 @@ const (
 var (
 	EvaluatorDefaultTypes = []string{"gt", "lt", "eq"}
 	EvaluatorRangedTypes  = []string{"within_range", "outside_range"}
+	// EvaluatorBaselineTypes compare with the baseline fetched from the datasource
+	EvaluatorBaselineTypes      = []string{"baseline_stddev", "baseline_history"}
+	EvaluatorBaselineDirections = []string{"above", "below"}
 
 	CommonAlertType             = []string{monitor.CommonAlertNomalAlertType, monitor.CommonAlertSystemAlertType}
 	CommonAlertReducerFieldOpts = []string{"/"}
 	CommonAlertNotifyTypes      = []string{"email", "mobile", "dingtalk", "webconsole", "feishu"}
 
+	ReducerTypes = []string{
+		"avg", "sum", "min", "max", "count", "last", "median", "diff", "percent_diff",
+		"count_non_null", "percentile", "rate", "delta", "stddev",
+	}
+	// MathReducerTypes are the reducer types working with CommonAlertReducerFieldOpts
+	MathReducerTypes = []string{
+		"avg", "sum", "min", "max", "count", "last", "median", "diff", "percent_diff", "count_non_null",
+	}
+
 	ConditionTypes = []string{"query", "nodata_query"}
 )
 
@@ -149,6 +161,26 @@ func ValidateFromAndToValue(input monitor.AlertQuery) error {
 }
 
 func ValidateAlertConditionReducer(input monitor.Condition) error {
+	typ := input.Type
+	if typ == "" {
+		return errors.Wrap(ErrMissingParameterType, "reducer")
+	}
+	if !utils.IsInStringArray(typ, ReducerTypes) {
+		return httperrors.NewInputParameterError("Unknown reducer type: %s", typ)
+	}
+	if typ == "percentile" && len(input.Params) != 0 {
+		if p := input.Params[0]; p <= 0 || p > 100 {
+			return httperrors.NewInputParameterError("percentile must be in (0, 100]: %v", p)
+		}
+	}
+	if len(input.Operators) != 0 {
+		if !utils.IsInStringArray(input.Operators[0], CommonAlertReducerFieldOpts) {
+			return httperrors.NewInputParameterError("Unknown reducer operator: %s", input.Operators[0])
+		}
+		if !utils.IsInStringArray(typ, MathReducerTypes) {
+			return httperrors.NewInputParameterError("reducer %s does not support operator %s", typ, input.Operators[0])
+		}
+	}
 	return nil
 }
 
@@ -163,6 +195,9 @@ func ValidateAlertConditionEvaluator(input monitor.Condition) error {
 	if utils.IsInStringArray(typ, EvaluatorRangedTypes) {
 		return ValidateAlertConditionRangedEvaluator(input)
 	}
+	if utils.IsInStringArray(typ, EvaluatorBaselineTypes) {
+		return ValidateAlertConditionBaselineEvaluator(input)
+	}
 	if typ != "no_value" {
 		return errors.Wrapf(ErrInvalidEvaluatorType, "type: %s", typ)
 	}
@@ -196,6 +231,25 @@ func ValidateAlertConditionRangedEvaluator(input monitor.Condition) error {
 	return nil
 }
 
+func ValidateAlertConditionBaselineEvaluator(input monitor.Condition) error {
+	if len(input.Params) == 0 {
+		return errors.Wrapf(ErrMissingParameterThreshold, "Evaluator %s", input.Type)
+	}
+	if input.Params[0] <= 0 {
+		return httperrors.NewInputParameterError("%s first parameter must be positive: %v", input.Type, input.Params[0])
+	}
+	if len(input.Params) > 1 && input.Params[1] < 0 {
+		return httperrors.NewInputParameterError("%s second parameter must not be negative: %v", input.Type, input.Params[1])
+	}
+	if input.Type == "baseline_stddev" && len(input.Params) > 1 && input.Params[1] == 0 {
+		return httperrors.NewInputParameterError("%s window must be positive", input.Type)
+	}
+	if len(input.Operators) > 0 && !utils.IsInStringArray(input.Operators[0], EvaluatorBaselineDirections) {
+		return httperrors.NewInputParameterError("Unknown %s direction: %s", input.Type, input.Operators[0])
+	}
+	return nil
+}
+
 // HumanThresholdType converts a threshold "type" string to a string that matches the UI
 // so errors are less confusing.
 func HumanThresholdType(typ string) string {
@@ -624,6 +624,210 @@ func (b *SBucket) DeleteCORS() error {
 	return nil
 }
 
+func (b *SBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOssClient")
+	}
+	input := []oss.LifecycleRule{}
+	for i := range rules {
+		rule := oss.LifecycleRule{
+			ID:     rules[i].Id,
+			Prefix: rules[i].Prefix,
+			Status: cloudprovider.LIFECYCLE_STATUS_DISABLED,
+		}
+		if rules[i].Enabled {
+			rule.Status = cloudprovider.LIFECYCLE_STATUS_ENABLED
+		}
+		if rules[i].ExpirationDays > 0 {
+			rule.Expiration = &oss.LifecycleExpiration{Days: rules[i].ExpirationDays}
+		}
+		for _, t := range rules[i].Transitions {
+			rule.Transitions = append(rule.Transitions, oss.LifecycleTransition{
+				Days:         t.Days,
+				StorageClass: oss.StorageClassType(t.StorageClass),
+			})
+		}
+		if rules[i].AbortIncompleteMultipartUploadDays > 0 {
+			rule.AbortMultipartUpload = &oss.LifecycleAbortMultipartUpload{Days: rules[i].AbortIncompleteMultipartUploadDays}
+		}
+		input = append(input, rule)
+	}
+	err = osscli.SetBucketLifecycle(b.Name, input)
+	if err != nil {
+		return errors.Wrapf(err, "osscli.SetBucketLifecycle(%s,%s)", b.Name, jsonutils.Marshal(input).String())
+	}
+	return nil
+}
+
+func (b *SBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetOssClient")
+	}
+	conf, err := osscli.GetBucketLifecycle(b.Name)
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchLifecycle") {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "osscli.GetBucketLifecycle(%s)", b.Name)
+	}
+	result := []cloudprovider.SBucketLifecycleRule{}
+	for i, rule := range conf.Rules {
+		r := cloudprovider.SBucketLifecycleRule{
+			Id:      rule.ID,
+			Prefix:  rule.Prefix,
+			Enabled: rule.Status == cloudprovider.LIFECYCLE_STATUS_ENABLED,
+		}
+		if len(r.Id) == 0 {
+			r.Id = strconv.Itoa(i)
+		}
+		if rule.Expiration != nil {
+			r.ExpirationDays = rule.Expiration.Days
+		}
+		for _, t := range rule.Transitions {
+			r.Transitions = append(r.Transitions, cloudprovider.SBucketLifecycleTransition{
+				Days:         t.Days,
+				StorageClass: string(t.StorageClass),
+			})
+		}
+		if rule.AbortMultipartUpload != nil {
+			r.AbortIncompleteMultipartUploadDays = rule.AbortMultipartUpload.Days
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (b *SBucket) DeleteLifecycle() error {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOssClient")
+	}
+	err = osscli.DeleteBucketLifecycle(b.Name)
+	if err != nil {
+		return errors.Wrapf(err, "osscli.DeleteBucketLifecycle(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) GetVersioning() (string, error) {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return "", errors.Wrap(err, "GetOssClient")
+	}
+	result, err := osscli.GetBucketVersioning(b.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "osscli.GetBucketVersioning(%s)", b.Name)
+	}
+	return result.Status, nil
+}
+
+func (b *SBucket) SetVersioning(status string) error {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOssClient")
+	}
+	err = osscli.SetBucketVersioning(b.Name, oss.VersioningConfig{Status: status})
+	if err != nil {
+		return errors.Wrapf(err, "osscli.SetBucketVersioning(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	result := cloudprovider.SListObjectVersionsResult{}
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return result, errors.Wrap(err, "GetOssClient")
+	}
+	bucket, err := osscli.Bucket(b.Name)
+	if err != nil {
+		return result, errors.Wrap(err, "Bucket")
+	}
+	opts := make([]oss.Option, 0)
+	if len(prefix) > 0 {
+		opts = append(opts, oss.Prefix(prefix))
+	}
+	if len(keyMarker) > 0 {
+		opts = append(opts, oss.KeyMarker(keyMarker))
+	}
+	if len(versionIdMarker) > 0 {
+		opts = append(opts, oss.VersionIdMarker(versionIdMarker))
+	}
+	if len(delimiter) > 0 {
+		opts = append(opts, oss.Delimiter(delimiter))
+	}
+	if maxCount > 0 {
+		opts = append(opts, oss.MaxKeys(maxCount))
+	}
+	output, err := bucket.ListObjectVersions(opts...)
+	if err != nil {
+		return result, errors.Wrap(err, "bucket.ListObjectVersions")
+	}
+	for _, v := range output.ObjectVersions {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:          v.Key,
+			VersionId:    v.VersionId,
+			IsLatest:     v.IsLatest,
+			SizeBytes:    v.Size,
+			StorageClass: v.StorageClass,
+			ETag:         v.ETag,
+			LastModified: v.LastModified,
+		})
+	}
+	for _, m := range output.ObjectDeleteMarkers {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:            m.Key,
+			VersionId:      m.VersionId,
+			IsLatest:       m.IsLatest,
+			IsDeleteMarker: true,
+			LastModified:   m.LastModified,
+		})
+	}
+	result.CommonPrefixes = output.CommonPrefixes
+	result.IsTruncated = output.IsTruncated
+	result.NextKeyMarker = output.NextKeyMarker
+	result.NextVersionIdMarker = output.NextVersionIdMarker
+	return result, nil
+}
+
+func (b *SBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetOssClient")
+	}
+	bucket, err := osscli.Bucket(b.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Bucket")
+	}
+	opts := []oss.Option{oss.VersionId(versionId)}
+	if rangeOpt != nil {
+		opts = append(opts, oss.NormalizedRange(rangeOpt.String()))
+	}
+	output, err := bucket.GetObject(key, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "bucket.GetObject")
+	}
+	return output, nil
+}
+
+func (b *SBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	osscli, err := b.region.GetOssClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOssClient")
+	}
+	bucket, err := osscli.Bucket(b.Name)
+	if err != nil {
+		return errors.Wrap(err, "Bucket")
+	}
+	err = bucket.DeleteObject(key, oss.VersionId(versionId))
+	if err != nil {
+		return errors.Wrap(err, "DeleteObject")
+	}
+	return nil
+}
+
 func (b *SBucket) SetReferer(conf cloudprovider.SBucketRefererConf) error {
 	osscli, err := b.region.GetOssClient()
 	if err != nil {
@@ -110,3 +110,50 @@ func (o *SObject) GetMeta() http.Header {
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ObjectSetMeta(ctx, o.bucket, o, meta)
 }
+
+func (o *SObject) GetTags() (map[string]string, error) {
+	osscli, err := o.bucket.region.GetOssClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "o.bucket.region.GetOssClient")
+	}
+	bucket, err := osscli.Bucket(o.bucket.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "osscli.Bucket")
+	}
+	result, err := bucket.GetObjectTagging(o.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "bucket.GetObjectTagging")
+	}
+	tags := map[string]string{}
+	for _, tag := range result.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	osscli, err := o.bucket.region.GetOssClient()
+	if err != nil {
+		return errors.Wrap(err, "o.bucket.region.GetOssClient")
+	}
+	bucket, err := osscli.Bucket(o.bucket.Name)
+	if err != nil {
+		return errors.Wrap(err, "osscli.Bucket")
+	}
+	if len(tags) == 0 {
+		err = bucket.DeleteObjectTagging(o.Key)
+		if err != nil {
+			return errors.Wrap(err, "bucket.DeleteObjectTagging")
+		}
+		return nil
+	}
+	tagging := oss.Tagging{}
+	for k, v := range tags {
+		tagging.Tags = append(tagging.Tags, oss.Tag{Key: k, Value: v})
+	}
+	err = bucket.PutObjectTagging(o.Key, tagging)
+	if err != nil {
+		return errors.Wrap(err, "bucket.PutObjectTagging")
+	}
+	return nil
+}
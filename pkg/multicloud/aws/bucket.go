@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 
@@ -680,6 +681,235 @@ func (b *SBucket) DeleteCORS() error {
 	return nil
 }
 
+func (b *SBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return errors.Wrap(err, "GetS3Client")
+	}
+	opts := []*s3.LifecycleRule{}
+	for i := range rules {
+		status := s3.ExpirationStatusDisabled
+		if rules[i].Enabled {
+			status = s3.ExpirationStatusEnabled
+		}
+		rule := &s3.LifecycleRule{
+			Filter: &s3.LifecycleRuleFilter{Prefix: &rules[i].Prefix},
+			Status: &status,
+		}
+		if len(rules[i].Id) > 0 {
+			rule.ID = &rules[i].Id
+		}
+		if rules[i].ExpirationDays > 0 {
+			rule.Expiration = &s3.LifecycleExpiration{Days: InputToAwsApiInt64(int64(rules[i].ExpirationDays))}
+		}
+		for j := range rules[i].Transitions {
+			rule.Transitions = append(rule.Transitions, &s3.Transition{
+				Days:         InputToAwsApiInt64(int64(rules[i].Transitions[j].Days)),
+				StorageClass: &rules[i].Transitions[j].StorageClass,
+			})
+		}
+		if rules[i].AbortIncompleteMultipartUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: InputToAwsApiInt64(int64(rules[i].AbortIncompleteMultipartUploadDays)),
+			}
+		}
+		opts = append(opts, rule)
+	}
+	input := s3.PutBucketLifecycleConfigurationInput{}
+	input.SetBucket(b.Name)
+	input.SetLifecycleConfiguration(&s3.BucketLifecycleConfiguration{Rules: opts})
+	_, err = s3cli.PutBucketLifecycleConfiguration(&input)
+	if err != nil {
+		return errors.Wrapf(err, "s3cli.PutBucketLifecycleConfiguration(%s)", input)
+	}
+	return nil
+}
+
+func (b *SBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetS3Client")
+	}
+	input := s3.GetBucketLifecycleConfigurationInput{}
+	input.SetBucket(b.Name)
+	conf, err := s3cli.GetBucketLifecycleConfiguration(&input)
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchLifecycleConfiguration") {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "s3cli.GetBucketLifecycleConfiguration(%s)", b.Name)
+	}
+	if conf == nil {
+		return nil, nil
+	}
+	result := []cloudprovider.SBucketLifecycleRule{}
+	for i, rule := range conf.Rules {
+		r := cloudprovider.SBucketLifecycleRule{
+			Id:      strconv.Itoa(i),
+			Enabled: rule.Status != nil && *rule.Status == s3.ExpirationStatusEnabled,
+		}
+		if rule.ID != nil && len(*rule.ID) > 0 {
+			r.Id = *rule.ID
+		}
+		if rule.Filter != nil && rule.Filter.Prefix != nil {
+			r.Prefix = *rule.Filter.Prefix
+		} else if rule.Prefix != nil {
+			r.Prefix = *rule.Prefix
+		}
+		if rule.Expiration != nil {
+			r.ExpirationDays = int(AwsApiInt64ToOutput(rule.Expiration.Days))
+		}
+		for _, t := range rule.Transitions {
+			transition := cloudprovider.SBucketLifecycleTransition{
+				Days: int(AwsApiInt64ToOutput(t.Days)),
+			}
+			if t.StorageClass != nil {
+				transition.StorageClass = *t.StorageClass
+			}
+			r.Transitions = append(r.Transitions, transition)
+		}
+		if rule.AbortIncompleteMultipartUpload != nil {
+			r.AbortIncompleteMultipartUploadDays = int(AwsApiInt64ToOutput(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (b *SBucket) DeleteLifecycle() error {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return errors.Wrap(err, "GetS3Client")
+	}
+	input := s3.DeleteBucketLifecycleInput{}
+	input.SetBucket(b.Name)
+	_, err = s3cli.DeleteBucketLifecycle(&input)
+	if err != nil {
+		return errors.Wrapf(err, "s3cli.DeleteBucketLifecycle(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) GetVersioning() (string, error) {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return "", errors.Wrap(err, "GetS3Client")
+	}
+	input := s3.GetBucketVersioningInput{}
+	input.SetBucket(b.Name)
+	output, err := s3cli.GetBucketVersioning(&input)
+	if err != nil {
+		return "", errors.Wrapf(err, "s3cli.GetBucketVersioning(%s)", b.Name)
+	}
+	return aws.StringValue(output.Status), nil
+}
+
+func (b *SBucket) SetVersioning(status string) error {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return errors.Wrap(err, "GetS3Client")
+	}
+	input := s3.PutBucketVersioningInput{}
+	input.SetBucket(b.Name)
+	input.SetVersioningConfiguration(&s3.VersioningConfiguration{Status: &status})
+	_, err = s3cli.PutBucketVersioning(&input)
+	if err != nil {
+		return errors.Wrapf(err, "s3cli.PutBucketVersioning(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	result := cloudprovider.SListObjectVersionsResult{}
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return result, errors.Wrap(err, "GetS3Client")
+	}
+	input := &s3.ListObjectVersionsInput{}
+	input.SetBucket(b.Name)
+	if len(prefix) > 0 {
+		input.SetPrefix(prefix)
+	}
+	if len(keyMarker) > 0 {
+		input.SetKeyMarker(keyMarker)
+	}
+	if len(versionIdMarker) > 0 {
+		input.SetVersionIdMarker(versionIdMarker)
+	}
+	if len(delimiter) > 0 {
+		input.SetDelimiter(delimiter)
+	}
+	if maxCount > 0 {
+		input.SetMaxKeys(int64(maxCount))
+	}
+	output, err := s3cli.ListObjectVersions(input)
+	if err != nil {
+		return result, errors.Wrap(err, "ListObjectVersions")
+	}
+	for _, v := range output.Versions {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:          aws.StringValue(v.Key),
+			VersionId:    aws.StringValue(v.VersionId),
+			IsLatest:     aws.BoolValue(v.IsLatest),
+			SizeBytes:    aws.Int64Value(v.Size),
+			StorageClass: aws.StringValue(v.StorageClass),
+			ETag:         aws.StringValue(v.ETag),
+			LastModified: aws.TimeValue(v.LastModified),
+		})
+	}
+	for _, m := range output.DeleteMarkers {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:            aws.StringValue(m.Key),
+			VersionId:      aws.StringValue(m.VersionId),
+			IsLatest:       aws.BoolValue(m.IsLatest),
+			IsDeleteMarker: true,
+			LastModified:   aws.TimeValue(m.LastModified),
+		})
+	}
+	for _, p := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, aws.StringValue(p.Prefix))
+	}
+	result.IsTruncated = aws.BoolValue(output.IsTruncated)
+	result.NextKeyMarker = aws.StringValue(output.NextKeyMarker)
+	result.NextVersionIdMarker = aws.StringValue(output.NextVersionIdMarker)
+	return result, nil
+}
+
+func (b *SBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetS3Client")
+	}
+	input := &s3.GetObjectInput{}
+	input.SetBucket(b.Name)
+	input.SetKey(key)
+	input.SetVersionId(versionId)
+	if rangeOpt != nil {
+		input.SetRange(rangeOpt.String())
+	}
+	output, err := s3cli.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetObject")
+	}
+	return output.Body, nil
+}
+
+func (b *SBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	s3cli, err := b.region.GetS3Client()
+	if err != nil {
+		return errors.Wrap(err, "GetS3Client")
+	}
+	input := &s3.DeleteObjectInput{}
+	input.SetBucket(b.Name)
+	input.SetKey(key)
+	input.SetVersionId(versionId)
+	_, err = s3cli.DeleteObjectWithContext(ctx, input)
+	if err != nil {
+		return errors.Wrap(err, "DeleteObject")
+	}
+	return nil
+}
+
 func (b *SBucket) GetTags() (map[string]string, error) {
 	s3cli, err := b.region.GetS3Client()
 	if err != nil {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"yunion.io/x/log"
@@ -114,3 +115,54 @@ func (o *SObject) GetMeta() http.Header {
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ObjectSetMeta(ctx, o.bucket, o, meta)
 }
+
+func (o *SObject) GetTags() (map[string]string, error) {
+	s3cli, err := o.bucket.region.GetS3Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "o.bucket.region.GetS3Client")
+	}
+	input := &s3.GetObjectTaggingInput{}
+	input.SetBucket(o.bucket.Name)
+	input.SetKey(o.Key)
+	output, err := s3cli.GetObjectTagging(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3cli.GetObjectTagging")
+	}
+	tags := map[string]string{}
+	for _, tag := range output.TagSet {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	return tags, nil
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	s3cli, err := o.bucket.region.GetS3Client()
+	if err != nil {
+		return errors.Wrap(err, "o.bucket.region.GetS3Client")
+	}
+	if len(tags) == 0 {
+		input := &s3.DeleteObjectTaggingInput{}
+		input.SetBucket(o.bucket.Name)
+		input.SetKey(o.Key)
+		_, err = s3cli.DeleteObjectTagging(input)
+		if err != nil {
+			return errors.Wrap(err, "s3cli.DeleteObjectTagging")
+		}
+		return nil
+	}
+	tagging := &s3.Tagging{}
+	for k, v := range tags {
+		tagging.TagSet = append(tagging.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	input := &s3.PutObjectTaggingInput{}
+	input.SetBucket(o.bucket.Name)
+	input.SetKey(o.Key)
+	input.SetTagging(tagging)
+	_, err = s3cli.PutObjectTagging(input)
+	if err != nil {
+		return errors.Wrap(err, "s3cli.PutObjectTagging")
+	}
+	return nil
+}
@@ -15,6 +15,9 @@
 package multicloud
 
 import (
+	"context"
+	"io"
+
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudprovider"
 )
@@ -94,6 +97,38 @@ func (b *SBaseBucket) DeleteCORS() error {
 	return cloudprovider.ErrNotImplemented
 }
 
+func (b *SBaseBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	return cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) DeleteLifecycle() error {
+	return cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) GetVersioning() (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) SetVersioning(status string) error {
+	return cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	return cloudprovider.SListObjectVersionsResult{}, cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}
+
+func (b *SBaseBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	return cloudprovider.ErrNotImplemented
+}
+
 func (b *SBaseBucket) SetReferer(conf cloudprovider.SBucketRefererConf) error {
 	return cloudprovider.ErrNotImplemented
 }
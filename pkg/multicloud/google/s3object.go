@@ -205,3 +205,12 @@ func (region *SRegion) SetObjectMeta(bucket, object string, meta http.Header) er
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return o.bucket.region.SetObjectMeta(o.bucket.Name, o.Name, meta)
 }
+
+// GCS has no object tagging, custom metadata is the closest equivalent
+func (o *SObject) GetTags() (map[string]string, error) {
+	return nil, cloudprovider.ErrNotSupported
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	return cloudprovider.ErrNotSupported
+}
@@ -676,6 +676,199 @@ func (b *SBucket) DeleteCORS() error {
 	return nil
 }
 
+func (b *SBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOBSClient")
+	}
+	input := obs.SetBucketLifecycleConfigurationInput{}
+	input.Bucket = b.Name
+	for i := range rules {
+		if rules[i].AbortIncompleteMultipartUploadDays > 0 {
+			return errors.Wrapf(cloudprovider.ErrNotSupported, "abort incomplete multipart upload in rule %s", rules[i].Id)
+		}
+		rule := obs.LifecycleRule{
+			ID:     rules[i].Id,
+			Prefix: rules[i].Prefix,
+			Status: obs.RuleStatusDisabled,
+		}
+		if rules[i].Enabled {
+			rule.Status = obs.RuleStatusEnabled
+		}
+		rule.Expiration.Days = rules[i].ExpirationDays
+		for _, t := range rules[i].Transitions {
+			rule.Transitions = append(rule.Transitions, obs.Transition{
+				Days:         t.Days,
+				StorageClass: obs.StorageClassType(t.StorageClass),
+			})
+		}
+		input.LifecycleRules = append(input.LifecycleRules, rule)
+	}
+	_, err = obscli.SetBucketLifecycleConfiguration(&input)
+	if err != nil {
+		return errors.Wrapf(err, "obscli.SetBucketLifecycleConfiguration(%s)", jsonutils.Marshal(input).String())
+	}
+	return nil
+}
+
+func (b *SBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetOBSClient")
+	}
+	conf, err := obscli.GetBucketLifecycleConfiguration(b.Name)
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchLifecycleConfiguration") {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "obscli.GetBucketLifecycleConfiguration(%s)", b.Name)
+	}
+	if conf == nil {
+		return nil, nil
+	}
+	result := []cloudprovider.SBucketLifecycleRule{}
+	for i, rule := range conf.LifecycleRules {
+		r := cloudprovider.SBucketLifecycleRule{
+			Id:             rule.ID,
+			Prefix:         rule.Prefix,
+			Enabled:        rule.Status == obs.RuleStatusEnabled,
+			ExpirationDays: rule.Expiration.Days,
+		}
+		if len(r.Id) == 0 {
+			r.Id = strconv.Itoa(i)
+		}
+		for _, t := range rule.Transitions {
+			r.Transitions = append(r.Transitions, cloudprovider.SBucketLifecycleTransition{
+				Days:         t.Days,
+				StorageClass: string(t.StorageClass),
+			})
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (b *SBucket) DeleteLifecycle() error {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOBSClient")
+	}
+	_, err = obscli.DeleteBucketLifecycleConfiguration(b.Name)
+	if err != nil {
+		return errors.Wrapf(err, "obscli.DeleteBucketLifecycleConfiguration(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) GetVersioning() (string, error) {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return "", errors.Wrap(err, "GetOBSClient")
+	}
+	output, err := obscli.GetBucketVersioning(b.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "obscli.GetBucketVersioning(%s)", b.Name)
+	}
+	return string(output.Status), nil
+}
+
+func (b *SBucket) SetVersioning(status string) error {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOBSClient")
+	}
+	input := &obs.SetBucketVersioningInput{}
+	input.Bucket = b.Name
+	input.Status = obs.VersioningStatusType(status)
+	_, err = obscli.SetBucketVersioning(input)
+	if err != nil {
+		return errors.Wrapf(err, "obscli.SetBucketVersioning(%s)", b.Name)
+	}
+	return nil
+}
+
+func (b *SBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	result := cloudprovider.SListObjectVersionsResult{}
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return result, errors.Wrap(err, "GetOBSClient")
+	}
+	input := &obs.ListVersionsInput{}
+	input.Bucket = b.Name
+	input.Prefix = prefix
+	input.Delimiter = delimiter
+	input.KeyMarker = keyMarker
+	input.VersionIdMarker = versionIdMarker
+	if maxCount > 0 {
+		input.MaxKeys = maxCount
+	}
+	output, err := obscli.ListVersions(input)
+	if err != nil {
+		return result, errors.Wrap(err, "obscli.ListVersions")
+	}
+	for _, v := range output.Versions {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:          v.Key,
+			VersionId:    v.VersionId,
+			IsLatest:     v.IsLatest,
+			SizeBytes:    v.Size,
+			StorageClass: string(v.StorageClass),
+			ETag:         v.ETag,
+			LastModified: v.LastModified,
+		})
+	}
+	for _, m := range output.DeleteMarkers {
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:            m.Key,
+			VersionId:      m.VersionId,
+			IsLatest:       m.IsLatest,
+			IsDeleteMarker: true,
+			LastModified:   m.LastModified,
+		})
+	}
+	result.CommonPrefixes = output.CommonPrefixes
+	result.IsTruncated = output.IsTruncated
+	result.NextKeyMarker = output.NextKeyMarker
+	result.NextVersionIdMarker = output.NextVersionIdMarker
+	return result, nil
+}
+
+func (b *SBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetOBSClient")
+	}
+	input := &obs.GetObjectInput{}
+	input.Bucket = b.Name
+	input.Key = key
+	input.VersionId = versionId
+	if rangeOpt != nil {
+		input.RangeStart = rangeOpt.Start
+		input.RangeEnd = rangeOpt.End
+	}
+	output, err := obscli.GetObject(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "obscli.GetObject")
+	}
+	return output.Body, nil
+}
+
+func (b *SBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	obscli, err := b.region.getOBSClient()
+	if err != nil {
+		return errors.Wrap(err, "GetOBSClient")
+	}
+	input := &obs.DeleteObjectInput{}
+	input.Bucket = b.Name
+	input.Key = key
+	input.VersionId = versionId
+	_, err = obscli.DeleteObject(input)
+	if err != nil {
+		return errors.Wrap(err, "DeleteObject")
+	}
+	return nil
+}
+
 func (b *SBucket) GetTags() (map[string]string, error) {
 	obscli, err := b.region.getOBSClient()
 	if err != nil {
@@ -101,3 +101,12 @@ func (o *SObject) GetMeta() http.Header {
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ObjectSetMeta(ctx, o.bucket, o, meta)
 }
+
+// OBS only supports tagging on buckets
+func (o *SObject) GetTags() (map[string]string, error) {
+	return nil, cloudprovider.ErrNotSupported
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	return cloudprovider.ErrNotSupported
+}
@@ -16,6 +16,7 @@ package objectstore
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
@@ -84,6 +85,24 @@ func (bucket *SBucket) SetAcl(aclStr cloudprovider.TBucketACLType) error {
 	return bucket.client.SetIBucketAcl(bucket.Name, aclStr)
 }
 
+func (bucket *SBucket) GetTags() (map[string]string, error) {
+	return bucket.client.GetIBucketTags(bucket.Name)
+}
+
+func (bucket *SBucket) SetTags(tags map[string]string, replace bool) error {
+	if !replace {
+		oldTags, err := bucket.client.GetIBucketTags(bucket.Name)
+		if err != nil {
+			return errors.Wrap(err, "GetIBucketTags")
+		}
+		for k, v := range tags {
+			oldTags[k] = v
+		}
+		tags = oldTags
+	}
+	return bucket.client.SetIBucketTags(bucket.Name, tags)
+}
+
 func (bucket *SBucket) GetLocation() string {
 	return bucket.Location
 }
@@ -363,3 +382,40 @@ func (bucket *SBucket) CopyPart(ctx context.Context, key string, uploadId string
 	}
 	return result.ETag, nil
 }
+
+func (bucket *SBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	conf := cloudprovider.NewS3LifecycleConfiguration(rules)
+	confXml, err := xml.Marshal(conf)
+	if err != nil {
+		return errors.Wrap(err, "xml.Marshal")
+	}
+	err = bucket.client.S3Client().SetBucketLifecycle(bucket.Name, string(confXml))
+	if err != nil {
+		return errors.Wrap(err, "SetBucketLifecycle")
+	}
+	return nil
+}
+
+func (bucket *SBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	confXml, err := bucket.client.S3Client().GetBucketLifecycle(bucket.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetBucketLifecycle")
+	}
+	if len(confXml) == 0 {
+		return nil, nil
+	}
+	conf := cloudprovider.SS3LifecycleConfiguration{}
+	err = xml.Unmarshal([]byte(confXml), &conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "xml.Unmarshal")
+	}
+	return conf.ToRules(), nil
+}
+
+func (bucket *SBucket) DeleteLifecycle() error {
+	err := bucket.client.S3Client().SetBucketLifecycle(bucket.Name, "")
+	if err != nil {
+		return errors.Wrap(err, "SetBucketLifecycle")
+	}
+	return nil
+}
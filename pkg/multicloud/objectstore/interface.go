@@ -40,5 +40,10 @@ type IBucketProvider interface {
 	GetIBucketAcl(name string) (cloudprovider.TBucketACLType, error)
 	SetIBucketAcl(name string, cannedAcl cloudprovider.TBucketACLType) error
 
+	GetObjectTags(bucket, key string) (map[string]string, error)
+	SetObjectTags(bucket, key string, tags map[string]string) error
+	GetIBucketTags(name string) (map[string]string, error)
+	SetIBucketTags(name string, tags map[string]string) error
+
 	// GetCapabilities() []string
 }
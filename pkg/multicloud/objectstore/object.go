@@ -90,3 +90,11 @@ func (o *SObject) GetMeta() http.Header {
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ObjectSetMeta(ctx, o.bucket, o, meta)
 }
+
+func (o *SObject) GetTags() (map[string]string, error) {
+	return o.bucket.client.GetObjectTags(o.bucket.Name, o.Key)
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	return o.bucket.client.SetObjectTags(o.bucket.Name, o.Key, tags)
+}
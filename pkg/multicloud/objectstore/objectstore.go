@@ -15,6 +15,7 @@
 package objectstore
 
 import (
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -95,6 +96,8 @@ type SObjectStoreClient struct {
 	iBuckets []cloudprovider.ICloudBucket
 
 	client *s3cli.Client
+
+	httpClient *http.Client
 }
 
 func NewObjectStoreClient(cfg *ObjectStoreClientConfig) (*SObjectStoreClient, error) {
@@ -129,6 +132,7 @@ func NewObjectStoreClientAndFetch(cfg *ObjectStoreClientConfig, doFetch bool) (*
 	cli.SetCustomTransport(tr)
 
 	client.client = cli
+	client.httpClient = &http.Client{Transport: tr}
 	client.SetVirtualObject(&client)
 
 	if client.debug {
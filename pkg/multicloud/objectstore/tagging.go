@@ -0,0 +1,119 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/s3cli"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+)
+
+const (
+	taggingPresignExpires = 5 * time.Minute
+)
+
+// doTaggingRequest sends a ?tagging request for the bucket (empty key) or the object,
+// s3cli has no tagging API, so the request is presigned and sent directly
+func (cli *SObjectStoreClient) doTaggingRequest(method string, bucket, key string, body []byte) ([]byte, error) {
+	u, err := cli.client.Presign(method, bucket, key, taggingPresignExpires, url.Values{"tagging": []string{""}})
+	if err != nil {
+		return nil, errors.Wrap(err, "Presign")
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "http.NewRequest")
+	}
+	resp, err := cli.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s tagging", method)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "ioutil.ReadAll")
+	}
+	if resp.StatusCode >= 300 {
+		errResp := s3cli.ErrorResponse{}
+		if xml.Unmarshal(respBody, &errResp) != nil || len(errResp.Code) == 0 {
+			errResp.Code = resp.Status
+			errResp.Message = string(respBody)
+		}
+		errResp.StatusCode = resp.StatusCode
+		if resp.StatusCode == http.StatusNotFound && errResp.Code == "NoSuchTagSet" {
+			return nil, errors.Wrap(cloudprovider.ErrNotFound, errResp.Message)
+		}
+		return nil, errResp
+	}
+	return respBody, nil
+}
+
+func (cli *SObjectStoreClient) GetObjectTags(bucket, key string) (map[string]string, error) {
+	body, err := cli.doTaggingRequest(http.MethodGet, bucket, key, nil)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotFound {
+			return map[string]string{}, nil
+		}
+		return nil, errors.Wrap(err, "doTaggingRequest")
+	}
+	tagging := cloudprovider.SS3Tagging{}
+	// some storages respond without the name space
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.DefaultSpace = "http://s3.amazonaws.com/doc/2006-03-01/"
+	err = decoder.Decode(&tagging)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode tagging")
+	}
+	return tagging.ToTags(), nil
+}
+
+func (cli *SObjectStoreClient) SetObjectTags(bucket, key string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := cli.doTaggingRequest(http.MethodDelete, bucket, key, nil)
+		if err != nil {
+			return errors.Wrap(err, "doTaggingRequest")
+		}
+		return nil
+	}
+	body, err := xml.Marshal(cloudprovider.NewS3Tagging(tags))
+	if err != nil {
+		return errors.Wrap(err, "xml.Marshal")
+	}
+	_, err = cli.doTaggingRequest(http.MethodPut, bucket, key, body)
+	if err != nil {
+		return errors.Wrap(err, "doTaggingRequest")
+	}
+	return nil
+}
+
+func (cli *SObjectStoreClient) GetIBucketTags(name string) (map[string]string, error) {
+	return cli.GetObjectTags(name, "")
+}
+
+func (cli *SObjectStoreClient) SetIBucketTags(name string, tags map[string]string) error {
+	return cli.SetObjectTags(name, "", tags)
+}
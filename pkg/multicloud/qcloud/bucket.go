@@ -690,6 +690,200 @@ func (b *SBucket) DeleteCORS() error {
 	return nil
 }
 
+func (b *SBucket) SetLifecycle(rules []cloudprovider.SBucketLifecycleRule) error {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return errors.Wrap(err, "b.region.GetCosClient")
+	}
+	input := cos.BucketPutLifecycleOptions{}
+	for i := range rules {
+		if len(rules[i].Transitions) > 1 {
+			return errors.Wrapf(cloudprovider.ErrNotSupported, "multiple transitions in rule %s", rules[i].Id)
+		}
+		rule := cos.BucketLifecycleRule{
+			ID:     rules[i].Id,
+			Status: cloudprovider.LIFECYCLE_STATUS_DISABLED,
+			Filter: &cos.BucketLifecycleFilter{Prefix: rules[i].Prefix},
+		}
+		if rules[i].Enabled {
+			rule.Status = cloudprovider.LIFECYCLE_STATUS_ENABLED
+		}
+		if rules[i].ExpirationDays > 0 {
+			rule.Expiration = &cos.BucketLifecycleExpiration{Days: rules[i].ExpirationDays}
+		}
+		if len(rules[i].Transitions) > 0 {
+			rule.Transition = &cos.BucketLifecycleTransition{
+				Days:         rules[i].Transitions[0].Days,
+				StorageClass: rules[i].Transitions[0].StorageClass,
+			}
+		}
+		if rules[i].AbortIncompleteMultipartUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload = &cos.BucketLifecycleAbortIncompleteMultipartUpload{
+				DaysAfterInitiation: rules[i].AbortIncompleteMultipartUploadDays,
+			}
+		}
+		input.Rules = append(input.Rules, rule)
+	}
+	_, err = coscli.Bucket.PutLifecycle(context.Background(), &input)
+	if err != nil {
+		return errors.Wrap(err, "coscli.Bucket.PutLifecycle")
+	}
+	return nil
+}
+
+func (b *SBucket) GetLifecycle() ([]cloudprovider.SBucketLifecycleRule, error) {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "b.region.GetCosClient")
+	}
+	conf, _, err := coscli.Bucket.GetLifecycle(context.Background())
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchLifecycleConfiguration") {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "coscli.Bucket.GetLifecycle")
+	}
+	result := []cloudprovider.SBucketLifecycleRule{}
+	for i, rule := range conf.Rules {
+		r := cloudprovider.SBucketLifecycleRule{
+			Id:      rule.ID,
+			Enabled: rule.Status == cloudprovider.LIFECYCLE_STATUS_ENABLED,
+		}
+		if len(r.Id) == 0 {
+			r.Id = strconv.Itoa(i)
+		}
+		if rule.Filter != nil {
+			r.Prefix = rule.Filter.Prefix
+		}
+		if rule.Expiration != nil {
+			r.ExpirationDays = rule.Expiration.Days
+		}
+		if rule.Transition != nil {
+			r.Transitions = []cloudprovider.SBucketLifecycleTransition{
+				{
+					Days:         rule.Transition.Days,
+					StorageClass: rule.Transition.StorageClass,
+				},
+			}
+		}
+		if rule.AbortIncompleteMultipartUpload != nil {
+			r.AbortIncompleteMultipartUploadDays = rule.AbortIncompleteMultipartUpload.DaysAfterInitiation
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (b *SBucket) DeleteLifecycle() error {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return errors.Wrap(err, "b.region.GetCosClient")
+	}
+	_, err = coscli.Bucket.DeleteLifecycle(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "coscli.Bucket.DeleteLifecycle")
+	}
+	return nil
+}
+
+func (b *SBucket) GetVersioning() (string, error) {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return "", errors.Wrap(err, "b.region.GetCosClient")
+	}
+	result, _, err := coscli.Bucket.GetVersioning(context.Background())
+	if err != nil {
+		return "", errors.Wrap(err, "coscli.Bucket.GetVersioning")
+	}
+	return result.Status, nil
+}
+
+func (b *SBucket) SetVersioning(status string) error {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return errors.Wrap(err, "b.region.GetCosClient")
+	}
+	_, err = coscli.Bucket.PutVersioning(context.Background(), &cos.BucketPutVersionOptions{Status: status})
+	if err != nil {
+		return errors.Wrap(err, "coscli.Bucket.PutVersioning")
+	}
+	return nil
+}
+
+func (b *SBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	result := cloudprovider.SListObjectVersionsResult{}
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return result, errors.Wrap(err, "b.region.GetCosClient")
+	}
+	opts := &cos.BucketGetObjectVersionsOptions{
+		Prefix:          prefix,
+		Delimiter:       delimiter,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxCount,
+	}
+	output, _, err := coscli.Bucket.GetObjectVersions(context.Background(), opts)
+	if err != nil {
+		return result, errors.Wrap(err, "coscli.Bucket.GetObjectVersions")
+	}
+	for _, v := range output.Version {
+		lastModified, _ := timeutils.ParseTimeStr(v.LastModified)
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:          v.Key,
+			VersionId:    v.VersionId,
+			IsLatest:     v.IsLatest,
+			SizeBytes:    int64(v.Size),
+			StorageClass: v.StorageClass,
+			ETag:         v.ETag,
+			LastModified: lastModified,
+		})
+	}
+	for _, m := range output.DeleteMarker {
+		lastModified, _ := timeutils.ParseTimeStr(m.LastModified)
+		result.Versions = append(result.Versions, cloudprovider.SCloudObjectVersion{
+			Key:            m.Key,
+			VersionId:      m.VersionId,
+			IsLatest:       m.IsLatest,
+			IsDeleteMarker: true,
+			LastModified:   lastModified,
+		})
+	}
+	result.CommonPrefixes = output.CommonPrefixes
+	result.IsTruncated = output.IsTruncated
+	result.NextKeyMarker = output.NextKeyMarker
+	result.NextVersionIdMarker = output.NextVersionIdMarker
+	return result, nil
+}
+
+func (b *SBucket) GetObjectVersion(ctx context.Context, key string, versionId string, rangeOpt *cloudprovider.SGetObjectRange) (io.ReadCloser, error) {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetCosClient")
+	}
+	opts := &cos.ObjectGetOptions{}
+	if rangeOpt != nil {
+		opts.Range = rangeOpt.String()
+	}
+	resp, err := coscli.Object.Get(ctx, key, opts, versionId)
+	if err != nil {
+		return nil, errors.Wrap(err, "coscli.Object.Get")
+	}
+	return resp.Body, nil
+}
+
+func (b *SBucket) DeleteObjectVersion(ctx context.Context, key string, versionId string) error {
+	coscli, err := b.region.GetCosClient(b)
+	if err != nil {
+		return errors.Wrap(err, "GetCosClient")
+	}
+	_, err = coscli.Object.Delete(ctx, key, &cos.ObjectDeleteOptions{VersionId: versionId})
+	if err != nil {
+		return errors.Wrap(err, "coscli.Object.Delete")
+	}
+	return nil
+}
+
 func (b *SBucket) SetReferer(conf cloudprovider.SBucketRefererConf) error {
 	coscli, err := b.region.GetCosClient(b)
 	if err != nil {
@@ -88,3 +88,42 @@ func (o *SObject) GetMeta() http.Header {
 func (o *SObject) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ObjectSetMeta(ctx, o.bucket, o, meta)
 }
+
+func (o *SObject) GetTags() (map[string]string, error) {
+	coscli, err := o.bucket.region.GetCosClient(o.bucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "o.bucket.region.GetCosClient")
+	}
+	result, _, err := coscli.Object.GetTagging(context.Background(), o.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "coscli.Object.GetTagging")
+	}
+	tags := map[string]string{}
+	for _, tag := range result.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+func (o *SObject) SetTags(ctx context.Context, tags map[string]string) error {
+	coscli, err := o.bucket.region.GetCosClient(o.bucket)
+	if err != nil {
+		return errors.Wrap(err, "o.bucket.region.GetCosClient")
+	}
+	if len(tags) == 0 {
+		_, err = coscli.Object.DeleteTagging(ctx, o.Key)
+		if err != nil {
+			return errors.Wrap(err, "coscli.Object.DeleteTagging")
+		}
+		return nil
+	}
+	opts := &cos.ObjectPutTaggingOptions{}
+	for k, v := range tags {
+		opts.TagSet = append(opts.TagSet, cos.ObjectTaggingTag{Key: k, Value: v})
+	}
+	_, err = coscli.Object.PutTagging(ctx, o.Key, opts)
+	if err != nil {
+		return errors.Wrap(err, "coscli.Object.PutTagging")
+	}
+	return nil
+}
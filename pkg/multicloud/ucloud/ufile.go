@@ -191,6 +191,14 @@ func (self *SFile) SetMeta(ctx context.Context, meta http.Header) error {
 	return cloudprovider.ErrNotSupported
 }
 
+func (self *SFile) GetTags() (map[string]string, error) {
+	return nil, cloudprovider.ErrNotSupported
+}
+
+func (self *SFile) SetTags(ctx context.Context, tags map[string]string) error {
+	return cloudprovider.ErrNotSupported
+}
+
 func doRequest(req *http.Request) (jsonutils.JSONObject, error) {
 	// ufile request use no timeout client so as to download/upload large files
 	res, err := httputils.GetAdaptiveTimeoutClient().Do(req)
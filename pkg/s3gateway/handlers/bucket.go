@@ -20,6 +20,7 @@ import (
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/s3cli"
 
+	"yunion.io/x/onecloud/pkg/cloudprovider"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/s3gateway/models"
 )
@@ -32,6 +33,18 @@ func headBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	return nil
 }
 
+func getIBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*models.SBucketDelegate, cloudprovider.ICloudBucket, error) {
+	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "models.BucketManager.GetByName")
+	}
+	iBucket, err := bucket.GetIBucket(ctx, userCred)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "bucket.GetIBucket")
+	}
+	return bucket, iBucket, nil
+}
+
 func removeBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket string) error {
 	return models.BucketManager.DeleteByName(ctx, userCred, bucket)
 }
@@ -0,0 +1,145 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SCORSRule struct {
+	ID            string   `xml:"ID,omitempty"`
+	AllowedHeader []string `xml:"AllowedHeader,omitempty"`
+	AllowedMethod []string `xml:"AllowedMethod"`
+	AllowedOrigin []string `xml:"AllowedOrigin"`
+	ExposeHeader  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+type SCORSConfiguration struct {
+	XMLName  xml.Name    `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CORSConfiguration"`
+	CORSRule []SCORSRule `xml:"CORSRule"`
+}
+
+func (conf SCORSConfiguration) validate() error {
+	for i := range conf.CORSRule {
+		if len(conf.CORSRule[i].AllowedMethod) == 0 {
+			return errors.Wrapf(httperrors.ErrMissingParameter, "rule %d: AllowedMethod", i)
+		}
+		if len(conf.CORSRule[i].AllowedOrigin) == 0 {
+			return errors.Wrapf(httperrors.ErrMissingParameter, "rule %d: AllowedOrigin", i)
+		}
+	}
+	return nil
+}
+
+func getBucketCors(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*SCORSConfiguration, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	rules, err := iBucket.GetCORSRules()
+	if err != nil {
+		return nil, errors.Wrap(err, "iBucket.GetCORSRules")
+	}
+	if len(rules) == 0 {
+		return nil, NoSuchCORSConfiguration(ctx, "The CORS configuration does not exist")
+	}
+	result := corsRules2S3(rules)
+	return &result, nil
+}
+
+func corsRules2S3(rules []cloudprovider.SBucketCORSRule) SCORSConfiguration {
+	result := SCORSConfiguration{}
+	for i := range rules {
+		id := rules[i].Id
+		if len(id) == 0 {
+			id = strconv.Itoa(i)
+		}
+		result.CORSRule = append(result.CORSRule, SCORSRule{
+			ID:            id,
+			AllowedHeader: rules[i].AllowedHeaders,
+			AllowedMethod: rules[i].AllowedMethods,
+			AllowedOrigin: rules[i].AllowedOrigins,
+			ExposeHeader:  rules[i].ExposeHeaders,
+			MaxAgeSeconds: rules[i].MaxAgeSeconds,
+		})
+	}
+	return result
+}
+
+func (conf SCORSConfiguration) toRules() []cloudprovider.SBucketCORSRule {
+	rules := make([]cloudprovider.SBucketCORSRule, len(conf.CORSRule))
+	for i := range conf.CORSRule {
+		rules[i] = cloudprovider.SBucketCORSRule{
+			Id:             conf.CORSRule[i].ID,
+			AllowedHeaders: conf.CORSRule[i].AllowedHeader,
+			AllowedMethods: conf.CORSRule[i].AllowedMethod,
+			AllowedOrigins: conf.CORSRule[i].AllowedOrigin,
+			ExposeHeaders:  conf.CORSRule[i].ExposeHeader,
+			MaxAgeSeconds:  conf.CORSRule[i].MaxAgeSeconds,
+		}
+	}
+	return rules
+}
+
+func putBucketCors(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	conf := SCORSConfiguration{}
+	err := fetchS3Xml(r, &conf)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	err = conf.validate()
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	if len(conf.CORSRule) == 0 {
+		err = iBucket.DeleteCORS()
+		if err != nil {
+			return errors.Wrap(err, "iBucket.DeleteCORS")
+		}
+		return nil
+	}
+	// PUT ?cors replaces the whole configuration, unlike cloudprovider.SetBucketCORS which merges by index
+	err = iBucket.SetCORS(conf.toRules())
+	if err != nil {
+		return errors.Wrap(err, "iBucket.SetCORS")
+	}
+	return nil
+}
+
+func deleteBucketCors(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) error {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.DeleteCORS()
+	if err != nil {
+		return errors.Wrap(err, "iBucket.DeleteCORS")
+	}
+	return nil
+}
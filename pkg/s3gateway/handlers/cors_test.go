@@ -0,0 +1,54 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestCORSConfigurationRoundTrip(t *testing.T) {
+	body := `<CORSConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<CORSRule><ID>web</ID><AllowedHeader>*</AllowedHeader><AllowedMethod>GET</AllowedMethod><AllowedMethod>PUT</AllowedMethod>` +
+		`<AllowedOrigin>https://www.example.com</AllowedOrigin><ExposeHeader>ETag</ExposeHeader><MaxAgeSeconds>3000</MaxAgeSeconds></CORSRule>` +
+		`<CORSRule><ID>1</ID><AllowedMethod>GET</AllowedMethod><AllowedOrigin>*</AllowedOrigin></CORSRule>` +
+		`</CORSConfiguration>`
+	conf := SCORSConfiguration{}
+	if err := xml.Unmarshal([]byte(body), &conf); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := conf.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	got, err := xml.Marshal(corsRules2S3(conf.toRules()))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("want %s\ngot  %s", body, got)
+	}
+
+	// the rules without id are numbered by the index
+	rules := conf.toRules()
+	rules[1].Id = ""
+	if id := corsRules2S3(rules).CORSRule[1].ID; id != "1" {
+		t.Errorf("want rule id 1 got %s", id)
+	}
+
+	conf.CORSRule[1].AllowedOrigin = nil
+	if err := conf.validate(); err == nil {
+		t.Errorf("the rule without AllowedOrigin should be rejected")
+	}
+}
@@ -89,6 +89,46 @@ func OutOfRangeError(ctx context.Context, msg string) s3cli.ErrorResponse {
 	return generalError(ctx, 416, "Range Not Satisfiable", msg)
 }
 
+func NoSuchCORSConfiguration(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchCORSConfiguration", msg)
+}
+
+func NoSuchWebsiteConfiguration(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchWebsiteConfiguration", msg)
+}
+
+func NoSuchBucketPolicy(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchBucketPolicy", msg)
+}
+
+func NoSuchLifecycleConfiguration(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchLifecycleConfiguration", msg)
+}
+
+func NoSuchVersion(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchVersion", msg)
+}
+
+func NoSuchTagSet(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 404, "NoSuchTagSet", msg)
+}
+
+func InvalidTag(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 400, "InvalidTag", msg)
+}
+
+func MethodNotAllowed(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 405, "MethodNotAllowed", msg)
+}
+
+func MalformedXML(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 400, "MalformedXML", msg)
+}
+
+func MalformedPolicy(ctx context.Context, msg string) s3cli.ErrorResponse {
+	return generalError(ctx, 400, "MalformedPolicy", msg)
+}
+
 func SendGeneralError(ctx context.Context, w http.ResponseWriter, err error) {
 	switch e := err.(type) {
 	case s3cli.ErrorResponse:
@@ -15,7 +15,9 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/xml"
 	"net/http"
 	"net/url"
 	"strings"
@@ -160,13 +162,15 @@ func readBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	} else if query.Contains("analytics") {
 
 	} else if query.Contains("cors") {
-
+		resp, err := getBucketCors(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("encryption") {
 
 	} else if query.Contains("inventory") {
 
 	} else if query.Contains("lifecycle") {
-
+		resp, err := getBucketLifecycle(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("location") {
 		result := s3cli.LocationConstraint(bucket.Location)
 		return &result, nil, nil
@@ -183,19 +187,24 @@ func readBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	} else if query.Contains("policyStatus") {
 
 	} else if query.Contains("versions") {
-
+		resp, err := listObjectVersions(ctx, userCred, bucketName, query)
+		return resp, nil, err
 	} else if query.Contains("policy") {
-
+		resp, err := getBucketPolicy(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("replication") {
 
 	} else if query.Contains("requestPayment") {
 
 	} else if query.Contains("tagging") {
-
+		resp, err := getBucketTagging(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("versioning") {
-		return &s3cli.VersioningConfiguration{}, nil, nil
+		resp, err := getBucketVersioning(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("website") {
-
+		resp, err := getBucketWebsite(ctx, userCred, bucketName)
+		return resp, nil, err
 	} else if query.Contains("uploads") {
 		input := s3cli.ListMultipartUploadsInput{}
 		err := query.Unmarshal(&input)
@@ -232,7 +241,8 @@ func readObject(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	} else if query.Contains("retention") {
 
 	} else if query.Contains("tagging") {
-
+		resp, err := getObjectTagging(ctx, userCred, bucketName, objKey)
+		return resp, nil, err
 	} else if query.Contains("torrent") {
 
 	} else {
@@ -241,6 +251,15 @@ func readObject(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	return nil, nil, NotImplemented(ctx, "not implemented")
 }
 
+func isObjectSubResource(query jsonutils.JSONObject) bool {
+	for _, res := range []string{"acl", "legal-hold", "retention", "tagging", "torrent"} {
+		if query.Contains(res) {
+			return true
+		}
+	}
+	return false
+}
+
 func getRangeOpt(rangeStr string, sizeBytes int64) (*cloudprovider.SGetObjectRange, error) {
 	if len(rangeStr) > 0 {
 		rangeOptObj := cloudprovider.ParseRange(rangeStr)
@@ -295,6 +314,21 @@ func downloadObject(ctx context.Context, userCred mcclient.TokenCredential, buck
 	return nil
 }
 
+// sendResponse sends XML by default, a few sub-resources such as bucket policy are JSON documents
+func sendResponse(w http.ResponseWriter, hdr http.Header, resp interface{}) {
+	switch jsonResp := resp.(type) {
+	case jsonutils.JSONObject:
+		for k, v := range hdr {
+			if k != "Content-Type" && k != "Content-Length" {
+				w.Header().Set(k, v[0])
+			}
+		}
+		appsrv.SendJSON(w, jsonResp)
+	default:
+		appsrv.SendXml(w, hdr, resp)
+	}
+}
+
 func readHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	o := fetchObjectRequest(ctx)
 	userCred := auth.FetchUserCredential(ctx, nil)
@@ -329,7 +363,7 @@ func readHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			SendGeneralError(ctx, w, err)
 			return
 		}
-		appsrv.SendXml(w, respHdr, resp)
+		sendResponse(w, respHdr, resp)
 	} else {
 		// object get
 		if len(r.URL.RawQuery) == 0 {
@@ -345,6 +379,14 @@ func readHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			SendError(ctx, w, BadRequest(ctx, err.Error()))
 			return
 		}
+		if versionId, _ := query.GetString("versionId"); len(versionId) > 0 && !isObjectSubResource(query) {
+			// download a specific version of object
+			err := downloadObjectVersion(ctx, userCred, o.Bucket, o.Key, versionId, r.Header, w)
+			if err != nil {
+				SendGeneralError(ctx, w, err)
+			}
+			return
+		}
 		resp, respHdr, err := readObject(ctx, userCred, o.Bucket, o.Key, query, r)
 		if err != nil {
 			SendGeneralError(ctx, w, err)
@@ -372,9 +414,6 @@ func postObject(ctx context.Context, userCred mcclient.TokenCredential, bucket s
 			return nil, nil, errors.Wrap(httperrors.ErrBadRequest, "FetchXml")
 		}
 		return completeMultipartUpload(ctx, userCred, r.Header, bucket, key, uploadId, &request)
-	} else if query.Contains("select") {
-		// select object
-		return selectObject(ctx, userCred, r.Header, bucket, key)
 	} else {
 		// upload object by form POST
 	}
@@ -397,6 +436,14 @@ func postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			SendError(ctx, w, BadRequest(ctx, err.Error()))
 			return
 		}
+		if query.Contains("select") {
+			// select object, the result is streamed as event stream
+			err := selectObject(ctx, userCred, o.Bucket, o.Key, r, w)
+			if err != nil {
+				SendGeneralError(ctx, w, err)
+			}
+			return
+		}
 		resp, respHdr, err := postObject(ctx, userCred, o.Bucket, o.Key, query, r)
 		if err != nil {
 			SendGeneralError(ctx, w, err)
@@ -416,13 +463,13 @@ func putBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket st
 	} else if query.Contains("analytics") {
 
 	} else if query.Contains("cors") {
-
+		return nil, nil, putBucketCors(ctx, userCred, bucket, r)
 	} else if query.Contains("encryption") {
 
 	} else if query.Contains("inventory") {
 
 	} else if query.Contains("lifecycle") {
-
+		return nil, nil, putBucketLifecycle(ctx, userCred, bucket, r)
 	} else if query.Contains("publicAccessBlock") {
 
 	} else if query.Contains("logging") {
@@ -434,17 +481,17 @@ func putBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket st
 	} else if query.Contains("object-lock") {
 
 	} else if query.Contains("policy") {
-
+		return nil, nil, putBucketPolicy(ctx, userCred, bucket, r)
 	} else if query.Contains("replication") {
 
 	} else if query.Contains("requestPayment") {
 
 	} else if query.Contains("tagging") {
-
+		return nil, nil, putBucketTagging(ctx, userCred, bucket, r)
 	} else if query.Contains("versioning") {
-
+		return nil, nil, putBucketVersioning(ctx, userCred, bucket, r)
 	} else if query.Contains("website") {
-
+		return nil, nil, putBucketWebsite(ctx, userCred, bucket, r)
 	} else {
 		// create bucket
 		return nil, nil, NotSupported(ctx, "Not supported")
@@ -460,7 +507,7 @@ func putObject(ctx context.Context, userCred mcclient.TokenCredential, bucketNam
 	} else if query.Contains("acl") {
 
 	} else if query.Contains("tagging") {
-
+		return nil, nil, putObjectTagging(ctx, userCred, bucketName, key, r)
 	} else {
 		// upload object
 		uploadId, _ := query.GetString("uploadId")
@@ -521,25 +568,25 @@ func deleteBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket
 	if query.Contains("analytics") {
 
 	} else if query.Contains("cors") {
-
+		return nil, deleteBucketCors(ctx, userCred, bucket)
 	} else if query.Contains("encryption") {
 
 	} else if query.Contains("inventory") {
 
 	} else if query.Contains("lifecycle") {
-
+		return nil, deleteBucketLifecycle(ctx, userCred, bucket)
 	} else if query.Contains("publicAccessBlock") {
 
 	} else if query.Contains("metrics") {
 
 	} else if query.Contains("policy") {
-
+		return nil, deleteBucketPolicy(ctx, userCred, bucket)
 	} else if query.Contains("replication") {
 
 	} else if query.Contains("tagging") {
-
+		return nil, deleteBucketTagging(ctx, userCred, bucket)
 	} else if query.Contains("website") {
-
+		return nil, deleteBucketWebsite(ctx, userCred, bucket)
 	} else {
 		// delete bucket
 		err := removeBucket(ctx, userCred, bucket)
@@ -553,7 +600,10 @@ func deleteBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket
 
 func deleteObject(ctx context.Context, userCred mcclient.TokenCredential, bucket string, key string, query jsonutils.JSONObject) (interface{}, error) {
 	if query.Contains("tagging") {
-		return deleteObjectTags(ctx, userCred, bucket, key)
+		return nil, deleteObjectTagging(ctx, userCred, bucket, key)
+	} else if query.Contains("versionId") {
+		versionId, _ := query.GetString("versionId")
+		return nil, removeObjectVersion(ctx, userCred, bucket, key, versionId)
 	} else {
 		// delete object
 		err := removeObject(ctx, userCred, bucket, key)
@@ -600,3 +650,19 @@ func deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request)
 	}
 	SendError(ctx, w, NotSupported(ctx, "method not supported"))
 }
+
+const s3XmlNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// fetchS3Xml decodes the XML request body, the elements without name space are taken as in the S3 name space
+func fetchS3Xml(r *http.Request, target interface{}) error {
+	body, err := appsrv.Fetch(r)
+	if err != nil {
+		return errors.Wrap(err, "appsrv.Fetch")
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.DefaultSpace = s3XmlNamespace
+	return decoder.Decode(target)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/s3gateway/models"
+)
+
+func getBucketLifecycle(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*cloudprovider.SS3LifecycleConfiguration, error) {
+	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "models.BucketManager.GetByName")
+	}
+	rules, err := bucket.GetLifecycle(ctx, userCred)
+	if err != nil {
+		return nil, errors.Wrap(err, "bucket.GetLifecycle")
+	}
+	if len(rules) == 0 {
+		return nil, NoSuchLifecycleConfiguration(ctx, "The lifecycle configuration does not exist")
+	}
+	result := cloudprovider.NewS3LifecycleConfiguration(rules)
+	return &result, nil
+}
+
+func putBucketLifecycle(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	conf := cloudprovider.SS3LifecycleConfiguration{}
+	err := fetchS3Xml(r, &conf)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	if len(conf.Rule) == 0 {
+		return MalformedXML(ctx, "missing Rule")
+	}
+	rules := conf.ToRules()
+	err = cloudprovider.ValidateLifecycleRules(rules)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "models.BucketManager.GetByName")
+	}
+	err = bucket.SetLifecycle(ctx, userCred, rules)
+	if err != nil {
+		return errors.Wrap(err, "bucket.SetLifecycle")
+	}
+	return nil
+}
+
+func deleteBucketLifecycle(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) error {
+	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "models.BucketManager.GetByName")
+	}
+	err = bucket.DeleteLifecycle(ctx, userCred)
+	if err != nil {
+		return errors.Wrap(err, "bucket.DeleteLifecycle")
+	}
+	return nil
+}
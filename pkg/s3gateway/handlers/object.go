@@ -205,10 +205,6 @@ func copyObject(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	}
 }
 
-func deleteObjectTags(ctx context.Context, userCred mcclient.TokenCredential, bucket string, key string) (*s3cli.Tagging, error) {
-	return nil, nil
-}
-
 func removeObject(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string) error {
 	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
 	if err != nil {
@@ -0,0 +1,470 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	POLICY_VERSION = "2012-10-17"
+
+	POLICY_CANNED_ACTION_READ         = "Read"
+	POLICY_CANNED_ACTION_READ_WRITE   = "ReadWrite"
+	POLICY_CANNED_ACTION_FULL_CONTROL = "FullControl"
+
+	POLICY_CONDITION_IP_ADDRESS     = "IpAddress"
+	POLICY_CONDITION_NOT_IP_ADDRESS = "NotIpAddress"
+	POLICY_CONDITION_KEY_SOURCE_IP  = "aws:SourceIp"
+)
+
+var (
+	policyCannedActions = map[string][]string{
+		POLICY_CANNED_ACTION_READ: {
+			"s3:GetObject",
+			"s3:ListBucket",
+		},
+		POLICY_CANNED_ACTION_READ_WRITE: {
+			"s3:GetObject",
+			"s3:ListBucket",
+			"s3:PutObject",
+			"s3:DeleteObject",
+			"s3:AbortMultipartUpload",
+			"s3:ListMultipartUploadParts",
+		},
+		POLICY_CANNED_ACTION_FULL_CONTROL: {
+			"s3:*",
+		},
+	}
+
+	policyConditionIpOps = map[string]string{
+		"ipaddress":    POLICY_CONDITION_IP_ADDRESS,
+		"ip_equal":     POLICY_CONDITION_IP_ADDRESS,
+		"notipaddress": POLICY_CONDITION_NOT_IP_ADDRESS,
+		"ip_not_equal": POLICY_CONDITION_NOT_IP_ADDRESS,
+	}
+)
+
+// sPolicyStringList accepts either a single string or an array of strings,
+// as both forms are valid in an S3 bucket policy document
+type sPolicyStringList []string
+
+func (l *sPolicyStringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = []string{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*l = multi
+	return nil
+}
+
+type SPolicyPrincipal struct {
+	AWS sPolicyStringList `json:"AWS,omitempty"`
+}
+
+func (p *SPolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var anyone string
+	if err := json.Unmarshal(data, &anyone); err == nil {
+		if anyone != "*" {
+			return fmt.Errorf("invalid principal %s", anyone)
+		}
+		p.AWS = []string{"*"}
+		return nil
+	}
+	principal := struct {
+		AWS sPolicyStringList `json:"AWS"`
+	}{}
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return err
+	}
+	p.AWS = principal.AWS
+	return nil
+}
+
+func (p SPolicyPrincipal) MarshalJSON() ([]byte, error) {
+	if len(p.AWS) == 1 && p.AWS[0] == "*" {
+		return json.Marshal("*")
+	}
+	return json.Marshal(struct {
+		AWS []string `json:"AWS"`
+	}{AWS: p.AWS})
+}
+
+type SPolicyStatement struct {
+	Sid       string                                  `json:"Sid,omitempty"`
+	Effect    string                                  `json:"Effect"`
+	Principal SPolicyPrincipal                        `json:"Principal"`
+	Action    sPolicyStringList                       `json:"Action"`
+	Resource  sPolicyStringList                       `json:"Resource"`
+	Condition map[string]map[string]sPolicyStringList `json:"Condition,omitempty"`
+}
+
+type SPolicyDocument struct {
+	Version   string             `json:"Version"`
+	Id        string             `json:"Id,omitempty"`
+	Statement []SPolicyStatement `json:"Statement"`
+}
+
+func principalId2Arn(id string) string {
+	if id == "*" {
+		return id
+	}
+	segs := strings.SplitN(id, ":", 2)
+	if len(segs) == 1 || len(segs[1]) == 0 {
+		return fmt.Sprintf("arn:aws:iam::%s:root", segs[0])
+	}
+	return fmt.Sprintf("arn:aws:iam::%s:user/%s", segs[0], segs[1])
+}
+
+func arn2PrincipalId(arn string) string {
+	if arn == "*" || !strings.HasPrefix(arn, "arn:aws:iam::") {
+		return arn
+	}
+	segs := strings.SplitN(arn[len("arn:aws:iam::"):], ":", 2)
+	if len(segs) == 1 || segs[1] == "root" {
+		return segs[0] + ":"
+	}
+	return segs[0] + ":" + strings.TrimPrefix(segs[1], "user/")
+}
+
+func resourcePath2Arn(bucketName string, path string) string {
+	if len(path) == 0 || path == "/" {
+		return "arn:aws:s3:::" + bucketName
+	}
+	return "arn:aws:s3:::" + bucketName + "/" + strings.TrimPrefix(path, "/")
+}
+
+func arn2ResourcePath(bucketName string, arn string) (string, error) {
+	bucketArn := "arn:aws:s3:::" + bucketName
+	if arn == bucketArn {
+		return "/", nil
+	}
+	if !strings.HasPrefix(arn, bucketArn+"/") {
+		return "", fmt.Errorf("resource %s is outside of bucket %s", arn, bucketName)
+	}
+	return arn[len(bucketArn):], nil
+}
+
+// actions2CannedAction only accepts the exact action sets of the canned actions,
+// as the providers grant nothing but the canned actions
+func actions2CannedAction(actions []string) (string, error) {
+	actionSet := stringutils2.NewSortedStrings(nil)
+	for _, action := range actions {
+		if action == "*" {
+			action = "s3:*"
+		}
+		actionSet = stringutils2.Append(actionSet, action)
+	}
+	for cannedAction, cannedActions := range policyCannedActions {
+		if len(cannedActions) == len(actionSet) && actionSet.ContainsAll(cannedActions...) {
+			return cannedAction, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported actions %s, supported are %s, %s or %s", strings.Join(actions, ","),
+		strings.Join(policyCannedActions[POLICY_CANNED_ACTION_READ], ","),
+		strings.Join(policyCannedActions[POLICY_CANNED_ACTION_READ_WRITE], ","),
+		strings.Join(policyCannedActions[POLICY_CANNED_ACTION_FULL_CONTROL], ","))
+}
+
+func (stmt SPolicyStatement) toInput(bucketName string) (cloudprovider.SBucketPolicyStatementInput, error) {
+	input := cloudprovider.SBucketPolicyStatementInput{}
+	if stmt.Effect != "Allow" && stmt.Effect != "Deny" {
+		return input, fmt.Errorf("invalid effect %q", stmt.Effect)
+	}
+	input.Effect = stmt.Effect
+	if len(stmt.Principal.AWS) == 0 {
+		return input, fmt.Errorf("missing principal")
+	}
+	for _, arn := range stmt.Principal.AWS {
+		input.PrincipalId = append(input.PrincipalId, arn2PrincipalId(arn))
+	}
+	if len(stmt.Action) == 0 {
+		return input, fmt.Errorf("missing action")
+	}
+	cannedAction, err := actions2CannedAction(stmt.Action)
+	if err != nil {
+		return input, err
+	}
+	input.CannedAction = cannedAction
+	if len(stmt.Resource) == 0 {
+		return input, fmt.Errorf("missing resource")
+	}
+	for _, arn := range stmt.Resource {
+		path, err := arn2ResourcePath(bucketName, arn)
+		if err != nil {
+			return input, err
+		}
+		input.ResourcePath = append(input.ResourcePath, path)
+	}
+	for op, cond := range stmt.Condition {
+		ips, ok := cond[POLICY_CONDITION_KEY_SOURCE_IP]
+		if !ok || len(cond) > 1 {
+			return input, fmt.Errorf("unsupported condition %s", op)
+		}
+		switch op {
+		case POLICY_CONDITION_IP_ADDRESS:
+			input.IpEquals = append(input.IpEquals, ips...)
+		case POLICY_CONDITION_NOT_IP_ADDRESS:
+			input.IpNotEquals = append(input.IpNotEquals, ips...)
+		default:
+			return input, fmt.Errorf("unsupported condition %s", op)
+		}
+	}
+	return input, nil
+}
+
+func policyStatement2S3(bucketName string, stmt cloudprovider.SBucketPolicyStatement) SPolicyStatement {
+	result := SPolicyStatement{
+		Sid:    stmt.Id,
+		Effect: stmt.Effect,
+	}
+	for _, id := range stmt.PrincipalId {
+		result.Principal.AWS = append(result.Principal.AWS, principalId2Arn(id))
+	}
+	if actions, ok := policyCannedActions[stmt.CannedAction]; ok {
+		result.Action = actions
+	} else {
+		result.Action = stmt.Action
+	}
+	for _, path := range stmt.ResourcePath {
+		result.Resource = append(result.Resource, resourcePath2Arn(bucketName, path))
+	}
+	result.Condition = policyCondition2S3(stmt.Condition)
+	return result
+}
+
+// policyCondition2S3 maps the source ip conditions of the providers, e.g.
+// IpAddress of aws:SourceIp or acs:SourceIp and ip_equal of qcs:ip, back to
+// the conditions accepted by toInput, the other conditions are not supported
+func policyCondition2S3(condition map[string]map[string]interface{}) map[string]map[string]sPolicyStringList {
+	result := map[string]map[string]sPolicyStringList{}
+	for op, cond := range condition {
+		s3Op, ok := policyConditionIpOps[strings.ToLower(op)]
+		if !ok {
+			continue
+		}
+		for key, value := range cond {
+			segs := strings.Split(strings.ToLower(key), ":")
+			if name := segs[len(segs)-1]; name != "sourceip" && name != "ip" {
+				continue
+			}
+			ips := policyConditionValues(value)
+			if len(ips) == 0 {
+				continue
+			}
+			if _, ok := result[s3Op]; !ok {
+				result[s3Op] = map[string]sPolicyStringList{}
+			}
+			result[s3Op][POLICY_CONDITION_KEY_SOURCE_IP] = append(result[s3Op][POLICY_CONDITION_KEY_SOURCE_IP], ips...)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func policyConditionValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		ret := []string{}
+		for i := range v {
+			if str, ok := v[i].(string); ok {
+				ret = append(ret, str)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+func getBucketPolicy(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (jsonutils.JSONObject, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	statements, err := iBucket.GetPolicy()
+	if err != nil {
+		return nil, errors.Wrap(err, "iBucket.GetPolicy")
+	}
+	if len(statements) == 0 {
+		return nil, NoSuchBucketPolicy(ctx, "The bucket policy does not exist")
+	}
+	doc := SPolicyDocument{
+		Version: POLICY_VERSION,
+	}
+	for i := range statements {
+		doc.Statement = append(doc.Statement, policyStatement2S3(bucketName, statements[i]))
+	}
+	// use encoding/json to keep the capitalized keys required by S3 clients
+	docJson, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+	return jsonutils.Parse(docJson)
+}
+
+func putBucketPolicy(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	body, err := appsrv.Fetch(r)
+	if err != nil {
+		return errors.Wrap(err, "appsrv.Fetch")
+	}
+	doc := SPolicyDocument{}
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		return MalformedPolicy(ctx, err.Error())
+	}
+	if len(doc.Statement) == 0 {
+		return MalformedPolicy(ctx, "empty statement")
+	}
+	inputs := make([]cloudprovider.SBucketPolicyStatementInput, len(doc.Statement))
+	for i := range doc.Statement {
+		inputs[i], err = doc.Statement[i].toInput(bucketName)
+		if err != nil {
+			return MalformedPolicy(ctx, fmt.Sprintf("statement %d: %s", i, err))
+		}
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	statements, err := iBucket.GetPolicy()
+	if err != nil {
+		return errors.Wrap(err, "iBucket.GetPolicy")
+	}
+	err = deletePolicyStatements(iBucket, statements)
+	if err != nil {
+		return errors.Wrap(err, "deletePolicyStatements")
+	}
+	err = setPolicyStatements(iBucket, inputs)
+	if err != nil {
+		// restore the previous policy
+		prevInputs := make([]cloudprovider.SBucketPolicyStatementInput, len(statements))
+		for i := range statements {
+			prevInputs[i] = policyStatement2Input(statements[i])
+		}
+		if cleared, clearErr := iBucket.GetPolicy(); clearErr != nil {
+			log.Errorf("iBucket.GetPolicy to restore %s fail %s", bucketName, clearErr)
+		} else if clearErr := deletePolicyStatements(iBucket, cleared); clearErr != nil {
+			log.Errorf("deletePolicyStatements to restore %s fail %s", bucketName, clearErr)
+		} else if restoreErr := setPolicyStatements(iBucket, prevInputs); restoreErr != nil {
+			log.Errorf("restore policy of %s fail %s", bucketName, restoreErr)
+		}
+		return errors.Wrap(err, "setPolicyStatements")
+	}
+	return nil
+}
+
+// setPolicyStatements adds the statements in the document order
+func setPolicyStatements(iBucket cloudprovider.ICloudBucket, inputs []cloudprovider.SBucketPolicyStatementInput) error {
+	// SetPolicy prepends the new statement, so add them backwards to keep the document order
+	for i := len(inputs) - 1; i >= 0; i-- {
+		err := iBucket.SetPolicy(inputs[i])
+		if err != nil {
+			return errors.Wrapf(err, "iBucket.SetPolicy statement %d", i)
+		}
+	}
+	return nil
+}
+
+func policyStatement2Input(stmt cloudprovider.SBucketPolicyStatement) cloudprovider.SBucketPolicyStatementInput {
+	input := cloudprovider.SBucketPolicyStatementInput{
+		PrincipalId:  stmt.PrincipalId,
+		CannedAction: stmt.CannedAction,
+		Effect:       stmt.Effect,
+		ResourcePath: stmt.ResourcePath,
+	}
+	for op, cond := range stmt.Condition {
+		for key, val := range cond {
+			// the key of source ip differs among the providers, e.g. acs:SourceIp
+			if !strings.HasSuffix(key, "SourceIp") {
+				continue
+			}
+			ips := make([]string, 0)
+			switch v := val.(type) {
+			case string:
+				ips = append(ips, v)
+			case []string:
+				ips = append(ips, v...)
+			case []interface{}:
+				for i := range v {
+					if ip, ok := v[i].(string); ok {
+						ips = append(ips, ip)
+					}
+				}
+			}
+			switch op {
+			case POLICY_CONDITION_IP_ADDRESS:
+				input.IpEquals = append(input.IpEquals, ips...)
+			case POLICY_CONDITION_NOT_IP_ADDRESS:
+				input.IpNotEquals = append(input.IpNotEquals, ips...)
+			}
+		}
+	}
+	return input
+}
+
+func deletePolicyStatements(iBucket cloudprovider.ICloudBucket, statements []cloudprovider.SBucketPolicyStatement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+	ids := make([]string, len(statements))
+	for i := range statements {
+		ids[i] = statements[i].Id
+	}
+	_, err := iBucket.DeletePolicy(ids)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.DeletePolicy")
+	}
+	return nil
+}
+
+func clearBucketPolicy(iBucket cloudprovider.ICloudBucket) error {
+	statements, err := iBucket.GetPolicy()
+	if err != nil {
+		return errors.Wrap(err, "iBucket.GetPolicy")
+	}
+	return deletePolicyStatements(iBucket, statements)
+}
+
+func deleteBucketPolicy(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) error {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	return clearBucketPolicy(iBucket)
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+)
+
+// the statement kept by a provider for the input, the conditions are stored
+// in the raw form of the provider
+func policyInput2Statement(input cloudprovider.SBucketPolicyStatementInput, condKey string, ipOp string, notIpOp string) cloudprovider.SBucketPolicyStatement {
+	stmt := cloudprovider.SBucketPolicyStatement{
+		Id:           "0",
+		Effect:       input.Effect,
+		PrincipalId:  input.PrincipalId,
+		CannedAction: input.CannedAction,
+		ResourcePath: input.ResourcePath,
+		Condition:    map[string]map[string]interface{}{},
+	}
+	if len(input.IpEquals) > 0 {
+		ips := []interface{}{}
+		for _, ip := range input.IpEquals {
+			ips = append(ips, ip)
+		}
+		stmt.Condition[ipOp] = map[string]interface{}{condKey: ips}
+	}
+	if len(input.IpNotEquals) > 0 {
+		stmt.Condition[notIpOp] = map[string]interface{}{condKey: input.IpNotEquals}
+	}
+	return stmt
+}
+
+func TestPolicyStatementRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		stmt string
+	}{
+		{
+			name: "read by anyone",
+			stmt: `{"Sid":"0","Effect":"Allow","Principal":"*","Action":["s3:GetObject","s3:ListBucket"],"Resource":["arn:aws:s3:::bucket/*"]}`,
+		},
+		{
+			name: "full control of sub account from the ips",
+			stmt: `{"Sid":"0","Effect":"Allow","Principal":{"AWS":["arn:aws:iam::123456:user/alice"]},"Action":["s3:*"],"Resource":["arn:aws:s3:::bucket","arn:aws:s3:::bucket/docs/*"],"Condition":{"IpAddress":{"aws:SourceIp":["10.0.0.0/8","192.168.1.1"]}}}`,
+		},
+		{
+			name: "deny root account out of the ips",
+			stmt: `{"Sid":"0","Effect":"Deny","Principal":{"AWS":["arn:aws:iam::123456:root"]},"Action":["s3:GetObject","s3:ListBucket"],"Resource":["arn:aws:s3:::bucket/*"],"Condition":{"NotIpAddress":{"aws:SourceIp":["172.16.0.0/12"]}}}`,
+		},
+	}
+	for _, c := range cases {
+		stmt := SPolicyStatement{}
+		if err := json.Unmarshal([]byte(c.stmt), &stmt); err != nil {
+			t.Fatalf("%s: unmarshal: %v", c.name, err)
+		}
+		input, err := stmt.toInput("bucket")
+		if err != nil {
+			t.Fatalf("%s: toInput: %v", c.name, err)
+		}
+		for _, provider := range []struct {
+			condKey string
+			ipOp    string
+			notIpOp string
+		}{
+			{condKey: "aws:SourceIp", ipOp: "IpAddress", notIpOp: "NotIpAddress"},
+			{condKey: "acs:SourceIp", ipOp: "IpAddress", notIpOp: "NotIpAddress"},
+			{condKey: "qcs:ip", ipOp: "ip_equal", notIpOp: "ip_not_equal"},
+		} {
+			result := policyStatement2S3("bucket", policyInput2Statement(input, provider.condKey, provider.ipOp, provider.notIpOp))
+			got, err := json.Marshal(result)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", c.name, err)
+			}
+			if string(got) != c.stmt {
+				t.Errorf("%s of %s:\nwant %s\ngot  %s", c.name, provider.condKey, c.stmt, got)
+			}
+		}
+	}
+}
+
+func TestPolicyStatementToInput(t *testing.T) {
+	for _, stmt := range []string{
+		`{"Effect":"Allow","Principal":"*","Action":["s3:PutObject"],"Resource":["arn:aws:s3:::bucket/*"]}`,
+		`{"Effect":"Allow","Principal":"*","Action":["s3:*"],"Resource":["arn:aws:s3:::other/*"]}`,
+		`{"Effect":"Allow","Principal":"*","Action":["s3:*"],"Resource":["arn:aws:s3:::bucket/*"],"Condition":{"StringLike":{"aws:Referer":["*.example.com"]}}}`,
+		`{"Effect":"Maybe","Principal":"*","Action":["s3:*"],"Resource":["arn:aws:s3:::bucket/*"]}`,
+	} {
+		input := SPolicyStatement{}
+		if err := json.Unmarshal([]byte(stmt), &input); err != nil {
+			t.Fatalf("unmarshal %s: %v", stmt, err)
+		}
+		if _, err := input.toInput("bucket"); err == nil {
+			t.Errorf("statement %s should be rejected", stmt)
+		}
+	}
+}
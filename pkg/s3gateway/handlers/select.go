@@ -18,11 +18,66 @@ import (
 	"context"
 	"net/http"
 
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 	"yunion.io/x/s3cli"
 
+	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3select"
 )
 
-func selectObject(ctx context.Context, userCred mcclient.TokenCredential, hdr http.Header, bucket string, key string) (*s3cli.InitiateMultipartUploadResult, http.Header, error) {
-	return nil, nil, NotImplemented(ctx, "")
+// selectObject evaluates the query of SelectObjectContent in the gateway, so that it works
+// for any backend. Errors before the response starts are sent as normal S3 errors, later
+// errors are sent as error event of the event stream
+func selectObject(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string, r *http.Request, w http.ResponseWriter) error {
+	opts := s3cli.SelectObjectOptions{}
+	err := appsrv.FetchXml(r, &opts)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	sel, err := s3select.NewSelect(&opts)
+	if err != nil {
+		code, msg := s3select.ErrorCodeMessage(err)
+		return generalError(ctx, http.StatusBadRequest, code, msg)
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	stream, err := iBucket.GetObject(ctx, key, nil)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.GetObject")
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	send := func(msg []byte) error {
+		_, err := w.Write(msg)
+		if err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	stats, err := sel.Run(stream, func(records []byte) error {
+		return send(s3select.NewRecordsMessage(records))
+	})
+	if err != nil {
+		log.Errorf("select object %s/%s fail %s", bucketName, key, err)
+		code, msg := s3select.ErrorCodeMessage(err)
+		send(s3select.NewErrorMessage(code, msg))
+		return nil
+	}
+	if opts.RequestProgress.Enabled {
+		send(s3select.NewProgressMessage(stats))
+	}
+	send(s3select.NewStatsMessage(stats))
+	send(s3select.NewEndMessage())
+	return nil
 }
@@ -0,0 +1,136 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+func fetchTagging(ctx context.Context, r *http.Request, maxCount int) (map[string]string, error) {
+	tagging := cloudprovider.SS3Tagging{}
+	err := fetchS3Xml(r, &tagging)
+	if err != nil {
+		return nil, MalformedXML(ctx, err.Error())
+	}
+	err = cloudprovider.ValidateTags(tagging.TagSet, maxCount)
+	if err != nil {
+		return nil, InvalidTag(ctx, err.Error())
+	}
+	return tagging.ToTags(), nil
+}
+
+func getBucketTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*cloudprovider.SS3Tagging, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	tags, err := iBucket.GetTags()
+	if err != nil {
+		return nil, errors.Wrap(err, "iBucket.GetTags")
+	}
+	if len(tags) == 0 {
+		return nil, NoSuchTagSet(ctx, "The TagSet does not exist")
+	}
+	result := cloudprovider.NewS3Tagging(tags)
+	return &result, nil
+}
+
+func putBucketTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	tags, err := fetchTagging(ctx, r, cloudprovider.MAX_BUCKET_TAG_COUNT)
+	if err != nil {
+		return err
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.SetTags(tags, true)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.SetTags")
+	}
+	return nil
+}
+
+func deleteBucketTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) error {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.SetTags(map[string]string{}, true)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.SetTags")
+	}
+	return nil
+}
+
+func getIObject(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string) (cloudprovider.ICloudObject, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	obj, err := cloudprovider.GetIObject(iBucket, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cloudprovider.GetIObject")
+	}
+	return obj, nil
+}
+
+func getObjectTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string) (*cloudprovider.SS3Tagging, error) {
+	obj, err := getIObject(ctx, userCred, bucketName, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIObject")
+	}
+	tags, err := obj.GetTags()
+	if err != nil {
+		return nil, errors.Wrap(err, "obj.GetTags")
+	}
+	// unlike bucket, an object without tags has an empty TagSet
+	result := cloudprovider.NewS3Tagging(tags)
+	return &result, nil
+}
+
+func putObjectTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string, r *http.Request) error {
+	tags, err := fetchTagging(ctx, r, cloudprovider.MAX_OBJECT_TAG_COUNT)
+	if err != nil {
+		return err
+	}
+	obj, err := getIObject(ctx, userCred, bucketName, key)
+	if err != nil {
+		return errors.Wrap(err, "getIObject")
+	}
+	err = obj.SetTags(ctx, tags)
+	if err != nil {
+		return errors.Wrap(err, "obj.SetTags")
+	}
+	return nil
+}
+
+func deleteObjectTagging(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string) error {
+	obj, err := getIObject(ctx, userCred, bucketName, key)
+	if err != nil {
+		return errors.Wrap(err, "getIObject")
+	}
+	err = obj.SetTags(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "obj.SetTags")
+	}
+	return nil
+}
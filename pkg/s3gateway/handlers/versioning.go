@@ -0,0 +1,206 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/s3cli"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SObjectVersion struct {
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+	StorageClass string    `xml:"StorageClass"`
+}
+
+type SDeleteMarker struct {
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type SListVersionsResult struct {
+	XMLName             xml.Name             `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string               `xml:"Name"`
+	Prefix              string               `xml:"Prefix"`
+	KeyMarker           string               `xml:"KeyMarker"`
+	VersionIdMarker     string               `xml:"VersionIdMarker"`
+	MaxKeys             int                  `xml:"MaxKeys"`
+	Delimiter           string               `xml:"Delimiter,omitempty"`
+	IsTruncated         bool                 `xml:"IsTruncated"`
+	NextKeyMarker       string               `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string               `xml:"NextVersionIdMarker,omitempty"`
+	Version             []SObjectVersion     `xml:"Version"`
+	DeleteMarker        []SDeleteMarker      `xml:"DeleteMarker"`
+	CommonPrefixes      []s3cli.CommonPrefix `xml:"CommonPrefixes"`
+}
+
+func getBucketVersioning(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*s3cli.VersioningConfiguration, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	result := s3cli.VersioningConfiguration{}
+	result.Status, err = iBucket.GetVersioning()
+	if err != nil && errors.Cause(err) != cloudprovider.ErrNotImplemented {
+		return nil, errors.Wrap(err, "iBucket.GetVersioning")
+	}
+	return &result, nil
+}
+
+func putBucketVersioning(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	conf := s3cli.VersioningConfiguration{}
+	err := fetchS3Xml(r, &conf)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	err = cloudprovider.ValidateVersioningStatus(conf.Status)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.SetVersioning(conf.Status)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.SetVersioning")
+	}
+	return nil
+}
+
+func listObjectVersions(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, query jsonutils.JSONObject) (*SListVersionsResult, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	return listIBucketObjectVersions(iBucket, bucketName, query)
+}
+
+func listIBucketObjectVersions(iBucket cloudprovider.ICloudBucket, bucketName string, query jsonutils.JSONObject) (*SListVersionsResult, error) {
+	result := SListVersionsResult{
+		Name: bucketName,
+	}
+	result.Prefix, _ = query.GetString("prefix")
+	result.KeyMarker, _ = query.GetString("key-marker")
+	result.VersionIdMarker, _ = query.GetString("version-id-marker")
+	result.Delimiter, _ = query.GetString("delimiter")
+	maxKeys, _ := query.Int("max-keys")
+	if maxKeys <= 0 || maxKeys > 1000 {
+		maxKeys = 1000
+	}
+	result.MaxKeys = int(maxKeys)
+	versions, err := iBucket.ListObjectVersions(result.Prefix, result.KeyMarker, result.VersionIdMarker, result.Delimiter, result.MaxKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "iBucket.ListObjectVersions")
+	}
+	for _, v := range versions.Versions {
+		if v.IsDeleteMarker {
+			result.DeleteMarker = append(result.DeleteMarker, SDeleteMarker{
+				Key:          v.Key,
+				VersionId:    v.VersionId,
+				IsLatest:     v.IsLatest,
+				LastModified: v.LastModified,
+			})
+			continue
+		}
+		result.Version = append(result.Version, SObjectVersion{
+			Key:          v.Key,
+			VersionId:    v.VersionId,
+			IsLatest:     v.IsLatest,
+			LastModified: v.LastModified,
+			ETag:         v.ETag,
+			Size:         v.SizeBytes,
+			StorageClass: v.StorageClass,
+		})
+	}
+	for _, prefix := range versions.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, s3cli.CommonPrefix{Prefix: prefix})
+	}
+	result.IsTruncated = versions.IsTruncated
+	result.NextKeyMarker = versions.NextKeyMarker
+	result.NextVersionIdMarker = versions.NextVersionIdMarker
+	return &result, nil
+}
+
+func downloadObjectVersion(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string, versionId string, reqHdr http.Header, w http.ResponseWriter) error {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	version, err := cloudprovider.GetObjectVersion(iBucket, key, versionId)
+	if err != nil {
+		if errors.Cause(err) == cloudprovider.ErrNotFound {
+			return NoSuchVersion(ctx, err.Error())
+		}
+		return errors.Wrap(err, "cloudprovider.GetObjectVersion")
+	}
+	if version.IsDeleteMarker {
+		return MethodNotAllowed(ctx, "The specified version is a delete marker")
+	}
+	hdr := http.Header{}
+	hdr.Set("x-amz-version-id", version.VersionId)
+	if len(version.ETag) > 0 {
+		hdr.Set("ETag", version.ETag)
+	}
+	if !version.LastModified.IsZero() {
+		hdr.Set("Last-Modified", version.LastModified.Format(timeutils.RFC2882Format))
+	}
+	rangeStr := reqHdr.Get(http.CanonicalHeaderKey("range"))
+	rangeOpt, err := getRangeOpt(rangeStr, version.SizeBytes)
+	if err != nil {
+		return errors.Wrap(err, rangeStr)
+	}
+	stream, err := iBucket.GetObjectVersion(ctx, key, versionId, rangeOpt)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.GetObjectVersion")
+	}
+	err = appsrv.SendStream(w, rangeOpt != nil, hdr, stream, version.SizeBytes)
+	if err != nil {
+		return errors.Wrap(err, "appsrv.SendStream")
+	}
+	return nil
+}
+
+func removeObjectVersion(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string, versionId string) error {
+	bucket, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.DeleteObjectVersion(ctx, key, versionId)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.DeleteObjectVersion")
+	}
+
+	bucket.Invalidate()
+
+	return nil
+}
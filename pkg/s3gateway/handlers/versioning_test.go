@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+)
+
+// sVersionBucket is a fake bucket returning a page of the version listing
+type sVersionBucket struct {
+	cloudprovider.ICloudBucket
+
+	result cloudprovider.SListObjectVersionsResult
+
+	prefix          string
+	keyMarker       string
+	versionIdMarker string
+	delimiter       string
+	maxCount        int
+}
+
+func (b *sVersionBucket) ListObjectVersions(prefix string, keyMarker string, versionIdMarker string, delimiter string, maxCount int) (cloudprovider.SListObjectVersionsResult, error) {
+	b.prefix, b.keyMarker, b.versionIdMarker, b.delimiter, b.maxCount = prefix, keyMarker, versionIdMarker, delimiter, maxCount
+	return b.result, nil
+}
+
+func TestListObjectVersions(t *testing.T) {
+	modified := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	bucket := &sVersionBucket{
+		result: cloudprovider.SListObjectVersionsResult{
+			Versions: []cloudprovider.SCloudObjectVersion{
+				{Key: "docs/a.txt", VersionId: "v3", IsLatest: true, IsDeleteMarker: true, LastModified: modified},
+				{Key: "docs/a.txt", VersionId: "v2", SizeBytes: 5, ETag: `"etag2"`, StorageClass: "STANDARD", LastModified: modified},
+				{Key: "docs/b.txt", VersionId: "v1", IsLatest: true, SizeBytes: 3, ETag: `"etag1"`, StorageClass: "STANDARD", LastModified: modified},
+			},
+			CommonPrefixes:      []string{"docs/img/"},
+			IsTruncated:         true,
+			NextKeyMarker:       "docs/b.txt",
+			NextVersionIdMarker: "v1",
+		},
+	}
+	query := jsonutils.NewDict()
+	query.Set("prefix", jsonutils.NewString("docs/"))
+	query.Set("key-marker", jsonutils.NewString("docs/0.txt"))
+	query.Set("version-id-marker", jsonutils.NewString("v0"))
+	query.Set("delimiter", jsonutils.NewString("/"))
+	query.Set("max-keys", jsonutils.NewInt(3))
+	result, err := listIBucketObjectVersions(bucket, "bucket", query)
+	if err != nil {
+		t.Fatalf("listIBucketObjectVersions: %v", err)
+	}
+	if bucket.prefix != "docs/" || bucket.keyMarker != "docs/0.txt" || bucket.versionIdMarker != "v0" || bucket.delimiter != "/" || bucket.maxCount != 3 {
+		t.Errorf("the query is not passed to the bucket: %#v", bucket)
+	}
+	got, err := xml.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<Name>bucket</Name><Prefix>docs/</Prefix><KeyMarker>docs/0.txt</KeyMarker><VersionIdMarker>v0</VersionIdMarker>` +
+		`<MaxKeys>3</MaxKeys><Delimiter>/</Delimiter><IsTruncated>true</IsTruncated>` +
+		`<NextKeyMarker>docs/b.txt</NextKeyMarker><NextVersionIdMarker>v1</NextVersionIdMarker>` +
+		`<Version><Key>docs/a.txt</Key><VersionId>v2</VersionId><IsLatest>false</IsLatest><LastModified>2020-06-01T00:00:00Z</LastModified>` +
+		`<ETag>&#34;etag2&#34;</ETag><Size>5</Size><StorageClass>STANDARD</StorageClass></Version>` +
+		`<Version><Key>docs/b.txt</Key><VersionId>v1</VersionId><IsLatest>true</IsLatest><LastModified>2020-06-01T00:00:00Z</LastModified>` +
+		`<ETag>&#34;etag1&#34;</ETag><Size>3</Size><StorageClass>STANDARD</StorageClass></Version>` +
+		`<DeleteMarker><Key>docs/a.txt</Key><VersionId>v3</VersionId><IsLatest>true</IsLatest><LastModified>2020-06-01T00:00:00Z</LastModified></DeleteMarker>` +
+		`<CommonPrefixes><Prefix>docs/img/</Prefix></CommonPrefixes>` +
+		`</ListVersionsResult>`
+	if string(got) != want {
+		t.Errorf("want %s\ngot  %s", want, got)
+	}
+
+	// the max keys are capped by the listing of the providers
+	query.Set("max-keys", jsonutils.NewInt(5000))
+	if _, err := listIBucketObjectVersions(bucket, "bucket", query); err != nil {
+		t.Fatalf("listIBucketObjectVersions: %v", err)
+	}
+	if bucket.maxCount != 1000 {
+		t.Errorf("want max keys 1000 got %d", bucket.maxCount)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SWebsiteIndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type SWebsiteErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type SWebsiteRedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type SWebsiteRoutingRuleCondition struct {
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+}
+
+type SWebsiteRoutingRuleRedirect struct {
+	HostName             string `xml:"HostName,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty"`
+}
+
+type SWebsiteRoutingRule struct {
+	Condition *SWebsiteRoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  SWebsiteRoutingRuleRedirect   `xml:"Redirect"`
+}
+
+// SWebsiteRoutingRules is a pointer in SWebsiteConfiguration, as encoding/xml
+// writes an empty RoutingRules for RoutingRules>RoutingRule even if omitempty
+type SWebsiteRoutingRules struct {
+	RoutingRule []SWebsiteRoutingRule `xml:"RoutingRule"`
+}
+
+type SWebsiteConfiguration struct {
+	XMLName               xml.Name                       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ WebsiteConfiguration"`
+	IndexDocument         *SWebsiteIndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *SWebsiteErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RedirectAllRequestsTo *SWebsiteRedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	RoutingRules          *SWebsiteRoutingRules          `xml:"RoutingRules,omitempty"`
+}
+
+func getBucketWebsite(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) (*SWebsiteConfiguration, error) {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getIBucket")
+	}
+	conf, err := iBucket.GetWebsiteConf()
+	if err != nil {
+		return nil, errors.Wrap(err, "iBucket.GetWebsiteConf")
+	}
+	if len(conf.Index) == 0 && len(conf.ErrorDocument) == 0 && len(conf.Rules) == 0 {
+		return nil, NoSuchWebsiteConfiguration(ctx, "The specified bucket does not have a website configuration")
+	}
+	result := websiteConf2S3(conf)
+	return &result, nil
+}
+
+func websiteConf2S3(conf cloudprovider.SBucketWebsiteConf) SWebsiteConfiguration {
+	result := SWebsiteConfiguration{}
+	if len(conf.Index) > 0 {
+		result.IndexDocument = &SWebsiteIndexDocument{Suffix: conf.Index}
+	}
+	if len(conf.ErrorDocument) > 0 {
+		result.ErrorDocument = &SWebsiteErrorDocument{Key: conf.ErrorDocument}
+	}
+	for i := range conf.Rules {
+		rule := SWebsiteRoutingRule{
+			Redirect: SWebsiteRoutingRuleRedirect{
+				Protocol:             conf.Rules[i].RedirectProtocol,
+				ReplaceKeyWith:       conf.Rules[i].RedirectReplaceKey,
+				ReplaceKeyPrefixWith: conf.Rules[i].RedirectReplaceKeyPrefix,
+			},
+		}
+		if len(conf.Rules[i].ConditionErrorCode) > 0 || len(conf.Rules[i].ConditionPrefix) > 0 {
+			rule.Condition = &SWebsiteRoutingRuleCondition{
+				HttpErrorCodeReturnedEquals: conf.Rules[i].ConditionErrorCode,
+				KeyPrefixEquals:             conf.Rules[i].ConditionPrefix,
+			}
+		}
+		if result.RoutingRules == nil {
+			result.RoutingRules = &SWebsiteRoutingRules{}
+		}
+		result.RoutingRules.RoutingRule = append(result.RoutingRules.RoutingRule, rule)
+	}
+	return result
+}
+
+func (input SWebsiteConfiguration) toConf() cloudprovider.SBucketWebsiteConf {
+	conf := cloudprovider.SBucketWebsiteConf{
+		Index: input.IndexDocument.Suffix,
+	}
+	if input.ErrorDocument != nil {
+		conf.ErrorDocument = input.ErrorDocument.Key
+	}
+	rules := []SWebsiteRoutingRule{}
+	if input.RoutingRules != nil {
+		rules = input.RoutingRules.RoutingRule
+	}
+	for i := range rules {
+		rule := cloudprovider.SBucketWebsiteRoutingRule{
+			RedirectProtocol:         rules[i].Redirect.Protocol,
+			RedirectReplaceKey:       rules[i].Redirect.ReplaceKeyWith,
+			RedirectReplaceKeyPrefix: rules[i].Redirect.ReplaceKeyPrefixWith,
+		}
+		if rules[i].Condition != nil {
+			rule.ConditionErrorCode = rules[i].Condition.HttpErrorCodeReturnedEquals
+			rule.ConditionPrefix = rules[i].Condition.KeyPrefixEquals
+		}
+		conf.Rules = append(conf.Rules, rule)
+	}
+	return conf
+}
+
+func putBucketWebsite(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request) error {
+	input := SWebsiteConfiguration{}
+	err := fetchS3Xml(r, &input)
+	if err != nil {
+		return MalformedXML(ctx, err.Error())
+	}
+	if input.RedirectAllRequestsTo != nil {
+		return errors.Wrap(httperrors.ErrNotSupported, "RedirectAllRequestsTo")
+	}
+	if input.IndexDocument == nil || len(input.IndexDocument.Suffix) == 0 {
+		return MalformedXML(ctx, "missing IndexDocument")
+	}
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.SetWebsite(input.toConf())
+	if err != nil {
+		return errors.Wrap(err, "iBucket.SetWebsite")
+	}
+	return nil
+}
+
+func deleteBucketWebsite(ctx context.Context, userCred mcclient.TokenCredential, bucketName string) error {
+	_, iBucket, err := getIBucket(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "getIBucket")
+	}
+	err = iBucket.DeleteWebSiteConf()
+	if err != nil {
+		return errors.Wrap(err, "iBucket.DeleteWebSiteConf")
+	}
+	return nil
+}
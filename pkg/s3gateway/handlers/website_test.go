@@ -0,0 +1,46 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestWebsiteConfigurationRoundTrip(t *testing.T) {
+	cases := []string{
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`,
+		`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+			`<IndexDocument><Suffix>index.html</Suffix></IndexDocument><ErrorDocument><Key>error.html</Key></ErrorDocument>` +
+			`<RoutingRules>` +
+			`<RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition><Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule>` +
+			`<RoutingRule><Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition><Redirect><Protocol>https</Protocol><ReplaceKeyWith>404.html</ReplaceKeyWith></Redirect></RoutingRule>` +
+			`<RoutingRule><Redirect><ReplaceKeyWith>index.html</ReplaceKeyWith></Redirect></RoutingRule>` +
+			`</RoutingRules></WebsiteConfiguration>`,
+	}
+	for _, body := range cases {
+		input := SWebsiteConfiguration{}
+		if err := xml.Unmarshal([]byte(body), &input); err != nil {
+			t.Fatalf("unmarshal %s: %v", body, err)
+		}
+		got, err := xml.Marshal(websiteConf2S3(input.toConf()))
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if string(got) != body {
+			t.Errorf("want %s\ngot  %s", body, got)
+		}
+	}
+}
@@ -243,3 +243,40 @@ func (bucket *SBucketDelegate) IsOutOfLimit() error {
 func (bucket *SBucketDelegate) Invalidate() {
 	BucketManager.Invalidate(bucket.Name)
 }
+
+// lifecycle goes through region service, which emulates it for storages without native support
+func (bucket *SBucketDelegate) GetLifecycle(ctx context.Context, userCred mcclient.TokenCredential) ([]cloudprovider.SBucketLifecycleRule, error) {
+	s := session.GetSession(ctx, userCred)
+	result, err := modules.Buckets.GetSpecific(s, bucket.Id, "lifecycle", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "modules.Buckets.GetSpecific lifecycle")
+	}
+	rules := []cloudprovider.SBucketLifecycleRule{}
+	if result.Contains("data") {
+		err = result.Unmarshal(&rules, "data")
+		if err != nil {
+			return nil, errors.Wrap(err, "result.Unmarshal")
+		}
+	}
+	return rules, nil
+}
+
+func (bucket *SBucketDelegate) SetLifecycle(ctx context.Context, userCred mcclient.TokenCredential, rules []cloudprovider.SBucketLifecycleRule) error {
+	s := session.GetSession(ctx, userCred)
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.Marshal(rules), "data")
+	_, err := modules.Buckets.PerformAction(s, bucket.Id, "set-lifecycle", params)
+	if err != nil {
+		return errors.Wrap(err, "modules.Buckets.PerformAction set-lifecycle")
+	}
+	return nil
+}
+
+func (bucket *SBucketDelegate) DeleteLifecycle(ctx context.Context, userCred mcclient.TokenCredential) error {
+	s := session.GetSession(ctx, userCred)
+	_, err := modules.Buckets.PerformAction(s, bucket.Id, "delete-lifecycle", nil)
+	if err != nil {
+		return errors.Wrap(err, "modules.Buckets.PerformAction delete-lifecycle")
+	}
+	return nil
+}
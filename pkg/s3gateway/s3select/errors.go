@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"fmt"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	ErrCodeParseUnexpectedToken     = "ParseUnexpectedToken"
+	ErrCodeUnsupportedSyntax        = "UnsupportedSyntax"
+	ErrCodeInvalidExpressionType    = "InvalidExpressionType"
+	ErrCodeInvalidDataSource        = "InvalidDataSource"
+	ErrCodeInvalidRequestParameter  = "InvalidRequestParameter"
+	ErrCodeInvalidCompressionFormat = "InvalidCompressionFormat"
+	ErrCodeCSVParsingError          = "CSVParsingError"
+	ErrCodeJSONParsingError         = "JSONParsingError"
+	ErrCodeCastFailed               = "CastFailed"
+	ErrCodeDivisionByZero           = "DivisionByZero"
+	ErrCodeInternalError            = "InternalError"
+)
+
+// SelectError carries the S3 error code of a failed select request
+type SelectError struct {
+	Code    string
+	Message string
+}
+
+func (e SelectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func newSelectError(code string, msgFmt string, args ...interface{}) SelectError {
+	return SelectError{
+		Code:    code,
+		Message: fmt.Sprintf(msgFmt, args...),
+	}
+}
+
+// ErrorCodeMessage returns the S3 error code and message of err
+func ErrorCodeMessage(err error) (string, string) {
+	if e, ok := errors.Cause(err).(SelectError); ok {
+		return e.Code, e.Message
+	}
+	return ErrCodeInternalError, err.Error()
+}
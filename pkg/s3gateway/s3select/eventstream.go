@@ -0,0 +1,106 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+)
+
+// Messages of the AWS event stream encoding:
+//
+//   | total length (4) | headers length (4) | prelude crc (4) | headers | payload | message crc (4) |
+//
+// each header is | name length (1) | name | value type (1) | value length (2) | value |
+
+const (
+	eventHeaderValueTypeString = 7
+)
+
+type sEventHeader struct {
+	name  string
+	value string
+}
+
+func encodeEventMessage(headers []sEventHeader, payload []byte) []byte {
+	hdrBuf := bytes.Buffer{}
+	for _, h := range headers {
+		hdrBuf.WriteByte(byte(len(h.name)))
+		hdrBuf.WriteString(h.name)
+		hdrBuf.WriteByte(eventHeaderValueTypeString)
+		binary.Write(&hdrBuf, binary.BigEndian, uint16(len(h.value)))
+		hdrBuf.WriteString(h.value)
+	}
+	totalLen := 4 + 4 + 4 + hdrBuf.Len() + len(payload) + 4
+	msg := bytes.Buffer{}
+	msg.Grow(totalLen)
+	binary.Write(&msg, binary.BigEndian, uint32(totalLen))
+	binary.Write(&msg, binary.BigEndian, uint32(hdrBuf.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(hdrBuf.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+func eventHeaders(eventType string, contentType string) []sEventHeader {
+	headers := []sEventHeader{
+		{name: ":event-type", value: eventType},
+	}
+	if len(contentType) > 0 {
+		headers = append(headers, sEventHeader{name: ":content-type", value: contentType})
+	}
+	return append(headers, sEventHeader{name: ":message-type", value: "event"})
+}
+
+func NewRecordsMessage(payload []byte) []byte {
+	return encodeEventMessage(eventHeaders("Records", "application/octet-stream"), payload)
+}
+
+func NewContinuationMessage() []byte {
+	return encodeEventMessage(eventHeaders("Cont", ""), nil)
+}
+
+func NewProgressMessage(stats *SStats) []byte {
+	progress := struct {
+		XMLName xml.Name `xml:"Progress"`
+		SStats
+	}{SStats: *stats}
+	payload, _ := xml.Marshal(progress)
+	return encodeEventMessage(eventHeaders("Progress", "text/xml"), payload)
+}
+
+func NewStatsMessage(stats *SStats) []byte {
+	details := struct {
+		XMLName xml.Name `xml:"Stats"`
+		SStats
+	}{SStats: *stats}
+	payload, _ := xml.Marshal(details)
+	return encodeEventMessage(eventHeaders("Stats", "text/xml"), payload)
+}
+
+func NewEndMessage() []byte {
+	return encodeEventMessage(eventHeaders("End", ""), nil)
+}
+
+func NewErrorMessage(code, message string) []byte {
+	return encodeEventMessage([]sEventHeader{
+		{name: ":error-code", value: code},
+		{name: ":error-message", value: message},
+		{name: ":message-type", value: "error"},
+	}, nil)
+}
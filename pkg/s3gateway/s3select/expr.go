@@ -0,0 +1,535 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	castTypeInt    = "INT"
+	castTypeFloat  = "FLOAT"
+	castTypeString = "STRING"
+	castTypeBool   = "BOOL"
+
+	aggregateCount = "COUNT"
+	aggregateSum   = "SUM"
+)
+
+// values are one of nil, string, int64, float64, bool or the nested
+// map[string]interface{}/[]interface{} of a JSON record
+type iExpr interface {
+	eval(rec iRecord) (interface{}, error)
+}
+
+type sLiteralExpr struct {
+	value interface{}
+}
+
+func (e *sLiteralExpr) eval(rec iRecord) (interface{}, error) {
+	return e.value, nil
+}
+
+type sColumnExpr struct {
+	path []string
+}
+
+func (e *sColumnExpr) eval(rec iRecord) (interface{}, error) {
+	val, _ := rec.Get(e.path)
+	return val, nil
+}
+
+type sNotExpr struct {
+	expr iExpr
+}
+
+func (e *sNotExpr) eval(rec iRecord) (interface{}, error) {
+	val, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := toBool(val)
+	if !ok {
+		return nil, nil
+	}
+	return !b, nil
+}
+
+type sLogicalExpr struct {
+	op    string
+	left  iExpr
+	right iExpr
+}
+
+// eval follows the three-valued logic of SQL, nil stands for unknown
+func (e *sLogicalExpr) eval(rec iRecord) (interface{}, error) {
+	lval, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	l, lok := toBool(lval)
+	// short circuit
+	if lok && ((e.op == "AND" && !l) || (e.op == "OR" && l)) {
+		return l, nil
+	}
+	rval, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	r, rok := toBool(rval)
+	if rok && ((e.op == "AND" && !r) || (e.op == "OR" && r)) {
+		return r, nil
+	}
+	if !lok || !rok {
+		return nil, nil
+	}
+	return r, nil
+}
+
+type sCompareExpr struct {
+	op    string
+	left  iExpr
+	right iExpr
+}
+
+func (e *sCompareExpr) eval(rec iRecord) (interface{}, error) {
+	lval, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	rval, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	cmp, ok := compareValues(lval, rval)
+	if !ok {
+		return nil, nil
+	}
+	switch e.op {
+	case "=":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type sIsNullExpr struct {
+	expr iExpr
+	not  bool
+}
+
+func (e *sIsNullExpr) eval(rec iRecord) (interface{}, error) {
+	val, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	return (val == nil) != e.not, nil
+}
+
+type sLikeExpr struct {
+	expr    iExpr
+	pattern iExpr
+}
+
+func (e *sLikeExpr) eval(rec iRecord) (interface{}, error) {
+	val, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := e.pattern.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil || pattern == nil {
+		return nil, nil
+	}
+	return likeMatch([]rune(formatValue(val)), []rune(formatValue(pattern))), nil
+}
+
+// likeMatch matches s against a LIKE pattern, % matches any sequence and _ matches one character
+func likeMatch(s, pattern []rune) bool {
+	// position to retry when the last % is hit
+	starIdx, matchIdx := -1, 0
+	i, j := 0, 0
+	for i < len(s) {
+		if j < len(pattern) && pattern[j] == '%' {
+			starIdx = j
+			matchIdx = i
+			j++
+		} else if j < len(pattern) && (pattern[j] == '_' || pattern[j] == s[i]) {
+			i++
+			j++
+		} else if starIdx >= 0 {
+			j = starIdx + 1
+			matchIdx++
+			i = matchIdx
+		} else {
+			return false
+		}
+	}
+	for j < len(pattern) && pattern[j] == '%' {
+		j++
+	}
+	return j == len(pattern)
+}
+
+type sInExpr struct {
+	expr iExpr
+	list []iExpr
+}
+
+func (e *sInExpr) eval(rec iRecord) (interface{}, error) {
+	val, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	unknown := false
+	for _, item := range e.list {
+		itemVal, err := item.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		cmp, ok := compareValues(val, itemVal)
+		if !ok {
+			unknown = true
+			continue
+		}
+		if cmp == 0 {
+			return true, nil
+		}
+	}
+	if unknown {
+		return nil, nil
+	}
+	return false, nil
+}
+
+type sArithExpr struct {
+	op    string
+	left  iExpr
+	right iExpr
+}
+
+func (e *sArithExpr) eval(rec iRecord) (interface{}, error) {
+	lval, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	rval, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	l, lok := toNumber(lval)
+	r, rok := toNumber(rval)
+	if !lok || !rok {
+		return nil, nil
+	}
+	li, lint := l.(int64)
+	ri, rint := r.(int64)
+	if lint && rint {
+		switch e.op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, newSelectError(ErrCodeDivisionByZero, "division by zero")
+			}
+			if e.op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+	lf, rf := toFloat(l), toFloat(r)
+	switch e.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/", "%":
+		if rf == 0 {
+			return nil, newSelectError(ErrCodeDivisionByZero, "division by zero")
+		}
+		if e.op == "/" {
+			return lf / rf, nil
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, nil
+}
+
+type sConcatExpr struct {
+	left  iExpr
+	right iExpr
+}
+
+func (e *sConcatExpr) eval(rec iRecord) (interface{}, error) {
+	lval, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	rval, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if lval == nil || rval == nil {
+		return nil, nil
+	}
+	return formatValue(lval) + formatValue(rval), nil
+}
+
+type sCastExpr struct {
+	expr iExpr
+	typ  string
+}
+
+func (e *sCastExpr) eval(rec iRecord) (interface{}, error) {
+	val, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	switch e.typ {
+	case castTypeInt:
+		num, ok := toNumber(val)
+		if !ok {
+			return nil, newSelectError(ErrCodeCastFailed, "cannot cast %q to INT", formatValue(val))
+		}
+		if f, ok := num.(float64); ok {
+			return int64(f), nil
+		}
+		return num, nil
+	case castTypeFloat:
+		num, ok := toNumber(val)
+		if !ok {
+			return nil, newSelectError(ErrCodeCastFailed, "cannot cast %q to FLOAT", formatValue(val))
+		}
+		return toFloat(num), nil
+	case castTypeBool:
+		b, ok := toBool(val)
+		if !ok {
+			return nil, newSelectError(ErrCodeCastFailed, "cannot cast %q to BOOL", formatValue(val))
+		}
+		return b, nil
+	default:
+		return formatValue(val), nil
+	}
+}
+
+// sAggregateExpr is only evaluated through sAggregateState
+type sAggregateExpr struct {
+	fn  string
+	arg iExpr
+}
+
+func (e *sAggregateExpr) eval(rec iRecord) (interface{}, error) {
+	return nil, newSelectError(ErrCodeUnsupportedSyntax, "misplaced aggregate function %s", e.fn)
+}
+
+type sAggregateState struct {
+	count    int64
+	sumInt   int64
+	sumFloat float64
+	isFloat  bool
+}
+
+func (s *sAggregateState) accumulate(e *sAggregateExpr, rec iRecord) error {
+	if e.arg == nil {
+		s.count++
+		return nil
+	}
+	val, err := e.arg.eval(rec)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return nil
+	}
+	if e.fn == aggregateCount {
+		s.count++
+		return nil
+	}
+	num, ok := toNumber(val)
+	if !ok {
+		return newSelectError(ErrCodeCastFailed, "cannot sum non-numeric value %q", formatValue(val))
+	}
+	s.count++
+	if i, ok := num.(int64); ok && !s.isFloat {
+		s.sumInt += i
+		return nil
+	}
+	if !s.isFloat {
+		s.isFloat = true
+		s.sumFloat = float64(s.sumInt)
+	}
+	s.sumFloat += toFloat(num)
+	return nil
+}
+
+func (s *sAggregateState) result(e *sAggregateExpr) interface{} {
+	if e.fn == aggregateCount {
+		return s.count
+	}
+	if s.count == 0 {
+		return nil
+	}
+	if s.isFloat {
+		return s.sumFloat
+	}
+	return s.sumInt
+}
+
+func toBool(val interface{}) (bool, bool) {
+	switch v := val.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return false, false
+		}
+		return b, true
+	}
+	return false, false
+}
+
+// toNumber converts val to int64 or float64, strings are parsed
+// as the fields of CSV records are all strings
+func toNumber(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case int64, float64:
+		return v, true
+	case json.Number:
+		return toNumber(string(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(num interface{}) float64 {
+	switch v := num.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func compareNumbers(l, r interface{}) int {
+	li, lint := l.(int64)
+	ri, rint := r.(int64)
+	if lint && rint {
+		switch {
+		case li < ri:
+			return -1
+		case li > ri:
+			return 1
+		}
+		return 0
+	}
+	lf, rf := toFloat(l), toFloat(r)
+	switch {
+	case lf < rf:
+		return -1
+	case lf > rf:
+		return 1
+	}
+	return 0
+}
+
+func isNumber(val interface{}) bool {
+	switch val.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+// compareValues returns false when either side is NULL, a string compared
+// with a number is compared numerically if it can be parsed as a number
+func compareValues(l, r interface{}) (int, bool) {
+	if l == nil || r == nil {
+		return 0, false
+	}
+	if isNumber(l) || isNumber(r) {
+		ln, lok := toNumber(l)
+		rn, rok := toNumber(r)
+		if lok && rok {
+			return compareNumbers(ln, rn), true
+		}
+	}
+	if lb, ok := l.(bool); ok {
+		if rb, ok := toBool(r); ok {
+			switch {
+			case lb == rb:
+				return 0, true
+			case rb:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return strings.Compare(formatValue(l), formatValue(r)), true
+}
+
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/s3cli"
+)
+
+type iRecord interface {
+	// Get returns the value of the column path
+	Get(path []string) (interface{}, bool)
+	// Fields returns all columns of the record, used by SELECT *
+	Fields() ([]string, []interface{})
+}
+
+type iRecordReader interface {
+	// Read returns io.EOF after the last record
+	Read() (iRecord, error)
+}
+
+type iRecordWriter interface {
+	Write(buf *bytes.Buffer, names []string, values []interface{}) error
+}
+
+type sCSVRecord struct {
+	header []string
+	values []string
+}
+
+func (r *sCSVRecord) Get(path []string) (interface{}, bool) {
+	if len(path) != 1 {
+		return nil, false
+	}
+	name := path[0]
+	for i := range r.header {
+		if r.header[i] == name && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	for i := range r.header {
+		if strings.EqualFold(r.header[i], name) && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	// positional reference _1, _2, ...
+	if strings.HasPrefix(name, "_") {
+		idx, err := strconv.Atoi(name[1:])
+		if err == nil && idx >= 1 && idx <= len(r.values) {
+			return r.values[idx-1], true
+		}
+	}
+	return nil, false
+}
+
+func (r *sCSVRecord) Fields() ([]string, []interface{}) {
+	names := make([]string, len(r.values))
+	values := make([]interface{}, len(r.values))
+	for i := range r.values {
+		if i < len(r.header) {
+			names[i] = r.header[i]
+		} else {
+			names[i] = fmt.Sprintf("_%d", i+1)
+		}
+		values[i] = r.values[i]
+	}
+	return names, values
+}
+
+type sCSVReader struct {
+	reader     *csv.Reader
+	header     []string
+	headerInfo s3cli.CSVFileHeaderInfo
+	headerRead bool
+}
+
+func singleRune(s string, def rune, name string) (rune, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, newSelectError(ErrCodeInvalidRequestParameter, "%s must be a single character", name)
+	}
+	return runes[0], nil
+}
+
+func newCSVReader(input io.Reader, opts *s3cli.CSVInputOptions) (*sCSVReader, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	var err error
+	reader.Comma, err = singleRune(opts.FieldDelimiter, ',', "FieldDelimiter")
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Comments) > 0 {
+		reader.Comment, err = singleRune(opts.Comments, '#', "Comments")
+		if err != nil {
+			return nil, err
+		}
+	}
+	// encoding/csv only understands the standard quote and line ending
+	if len(opts.QuoteCharacter) > 0 && opts.QuoteCharacter != `"` {
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "unsupported QuoteCharacter %q", opts.QuoteCharacter)
+	}
+	if len(opts.QuoteEscapeCharacter) > 0 && opts.QuoteEscapeCharacter != `"` {
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "unsupported QuoteEscapeCharacter %q", opts.QuoteEscapeCharacter)
+	}
+	if len(opts.RecordDelimiter) > 0 && opts.RecordDelimiter != "\n" && opts.RecordDelimiter != "\r\n" {
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "unsupported RecordDelimiter %q", opts.RecordDelimiter)
+	}
+	headerInfo := s3cli.CSVFileHeaderInfo(strings.ToUpper(string(opts.FileHeaderInfo)))
+	switch headerInfo {
+	case "", s3cli.CSVFileHeaderInfoNone, s3cli.CSVFileHeaderInfoIgnore, s3cli.CSVFileHeaderInfoUse:
+	default:
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "invalid FileHeaderInfo %q", opts.FileHeaderInfo)
+	}
+	return &sCSVReader{
+		reader:     reader,
+		headerInfo: headerInfo,
+	}, nil
+}
+
+func (r *sCSVReader) read() ([]string, error) {
+	values, err := r.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, newSelectError(ErrCodeCSVParsingError, "%s", err)
+	}
+	return values, nil
+}
+
+func (r *sCSVReader) Read() (iRecord, error) {
+	if !r.headerRead {
+		r.headerRead = true
+		if r.headerInfo == s3cli.CSVFileHeaderInfoUse || r.headerInfo == s3cli.CSVFileHeaderInfoIgnore {
+			header, err := r.read()
+			if err != nil {
+				return nil, err
+			}
+			if r.headerInfo == s3cli.CSVFileHeaderInfoUse {
+				r.header = header
+			}
+		}
+	}
+	values, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	return &sCSVRecord{header: r.header, values: values}, nil
+}
+
+type sJSONRecord struct {
+	object map[string]interface{}
+}
+
+func (r *sJSONRecord) Get(path []string) (interface{}, bool) {
+	var val interface{} = r.object
+	for _, name := range path {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		val, ok = obj[name]
+		if !ok {
+			// fall back to case-insensitive match
+			found := false
+			for k, v := range obj {
+				if strings.EqualFold(k, name) {
+					val, found = v, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+	}
+	if num, ok := val.(json.Number); ok {
+		if v, ok := toNumber(string(num)); ok {
+			return v, true
+		}
+	}
+	return val, true
+}
+
+func (r *sJSONRecord) Fields() ([]string, []interface{}) {
+	names := make([]string, 0, len(r.object))
+	for k := range r.object {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i := range names {
+		values[i] = r.object[names[i]]
+	}
+	return names, values
+}
+
+// sJSONReader reads both LINES and DOCUMENT input, a top level array is
+// treated as a list of records
+type sJSONReader struct {
+	decoder *json.Decoder
+	pending []interface{}
+}
+
+func newJSONReader(input io.Reader, opts *s3cli.JSONInputOptions) (*sJSONReader, error) {
+	switch s3cli.JSONType(strings.ToUpper(string(opts.Type))) {
+	case "", s3cli.JSONDocumentType, s3cli.JSONLinesType:
+	default:
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "invalid JSON Type %q", opts.Type)
+	}
+	decoder := json.NewDecoder(input)
+	decoder.UseNumber()
+	return &sJSONReader{decoder: decoder}, nil
+}
+
+func (r *sJSONReader) Read() (iRecord, error) {
+	for {
+		var val interface{}
+		if len(r.pending) > 0 {
+			val = r.pending[0]
+			r.pending = r.pending[1:]
+		} else {
+			err := r.decoder.Decode(&val)
+			if err != nil {
+				if err == io.EOF {
+					return nil, err
+				}
+				return nil, newSelectError(ErrCodeJSONParsingError, "%s", err)
+			}
+		}
+		switch v := val.(type) {
+		case map[string]interface{}:
+			return &sJSONRecord{object: v}, nil
+		case []interface{}:
+			r.pending = append(v, r.pending...)
+		default:
+			return nil, newSelectError(ErrCodeJSONParsingError, "record is not a JSON object")
+		}
+	}
+}
+
+type sCSVWriter struct {
+	fieldDelimiter  string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	quoteAlways     bool
+}
+
+func newCSVWriter(opts *s3cli.CSVOutputOptions) (*sCSVWriter, error) {
+	w := &sCSVWriter{
+		fieldDelimiter:  ",",
+		recordDelimiter: "\n",
+		quote:           `"`,
+		quoteEscape:     `"`,
+	}
+	if len(opts.FieldDelimiter) > 0 {
+		w.fieldDelimiter = opts.FieldDelimiter
+	}
+	if len(opts.RecordDelimiter) > 0 {
+		w.recordDelimiter = opts.RecordDelimiter
+	}
+	if len(opts.QuoteCharacter) > 0 {
+		w.quote = opts.QuoteCharacter
+	}
+	if len(opts.QuoteEscapeCharacter) > 0 {
+		w.quoteEscape = opts.QuoteEscapeCharacter
+	}
+	switch strings.ToUpper(string(opts.QuoteFields)) {
+	case "", strings.ToUpper(string(s3cli.CSVQuoteFieldsAsNeeded)):
+	case strings.ToUpper(string(s3cli.CSVQuoteFieldsAlways)):
+		w.quoteAlways = true
+	default:
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "invalid QuoteFields %q", opts.QuoteFields)
+	}
+	return w, nil
+}
+
+func (w *sCSVWriter) Write(buf *bytes.Buffer, names []string, values []interface{}) error {
+	for i := range values {
+		if i > 0 {
+			buf.WriteString(w.fieldDelimiter)
+		}
+		field := formatValue(values[i])
+		if w.quoteAlways || strings.Contains(field, w.fieldDelimiter) || strings.Contains(field, w.quote) ||
+			strings.ContainsAny(field, "\r\n") {
+			buf.WriteString(w.quote)
+			buf.WriteString(strings.Replace(field, w.quote, w.quoteEscape+w.quote, -1))
+			buf.WriteString(w.quote)
+		} else {
+			buf.WriteString(field)
+		}
+	}
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
+
+type sJSONWriter struct {
+	recordDelimiter string
+}
+
+func newJSONWriter(opts *s3cli.JSONOutputOptions) *sJSONWriter {
+	w := &sJSONWriter{
+		recordDelimiter: "\n",
+	}
+	if len(opts.RecordDelimiter) > 0 {
+		w.recordDelimiter = opts.RecordDelimiter
+	}
+	return w
+}
+
+func (w *sJSONWriter) Write(buf *bytes.Buffer, names []string, values []interface{}) error {
+	// keep the order of projections, which a map would lose
+	buf.WriteByte('{')
+	for i := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(names[i])
+		if err != nil {
+			return newSelectError(ErrCodeInternalError, "marshal key %s: %s", names[i], err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(values[i])
+		if err != nil {
+			return newSelectError(ErrCodeInternalError, "marshal value of %s: %s", names[i], err)
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"yunion.io/x/s3cli"
+)
+
+const (
+	// records are sent in chunks of about this size
+	recordsChunkSize = 64 * 1024
+)
+
+type SStats struct {
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+// SSelect evaluates the SQL expression of a SelectObjectContent request
+// over a CSV or JSON object stream
+type SSelect struct {
+	opts  *s3cli.SelectObjectOptions
+	query *sQuery
+}
+
+func NewSelect(opts *s3cli.SelectObjectOptions) (*SSelect, error) {
+	if len(opts.ExpressionType) > 0 && strings.ToUpper(string(opts.ExpressionType)) != string(s3cli.QueryExpressionTypeSQL) {
+		return nil, newSelectError(ErrCodeInvalidExpressionType, "unsupported ExpressionType %s", opts.ExpressionType)
+	}
+	switch s3cli.SelectCompressionType(strings.ToUpper(string(opts.InputSerialization.CompressionType))) {
+	case "", s3cli.SelectCompressionNONE, s3cli.SelectCompressionGZIP, s3cli.SelectCompressionBZIP:
+	default:
+		return nil, newSelectError(ErrCodeInvalidCompressionFormat, "unsupported CompressionType %s", opts.InputSerialization.CompressionType)
+	}
+	if opts.InputSerialization.Parquet != nil {
+		return nil, newSelectError(ErrCodeInvalidDataSource, "Parquet input is not supported")
+	}
+	if (opts.InputSerialization.CSV == nil) == (opts.InputSerialization.JSON == nil) {
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "exactly one of CSV and JSON input serialization is required")
+	}
+	if (opts.OutputSerialization.CSV == nil) == (opts.OutputSerialization.JSON == nil) {
+		return nil, newSelectError(ErrCodeInvalidRequestParameter, "exactly one of CSV and JSON output serialization is required")
+	}
+	query, err := parseQuery(opts.Expression)
+	if err != nil {
+		return nil, err
+	}
+	sel := &SSelect{
+		opts:  opts,
+		query: query,
+	}
+	// validate the serialization options before reading any data
+	_, err = sel.newRecordReader(bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	_, err = sel.newRecordWriter()
+	if err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+func (sel *SSelect) newRecordReader(input io.Reader) (iRecordReader, error) {
+	if sel.opts.InputSerialization.CSV != nil {
+		return newCSVReader(input, sel.opts.InputSerialization.CSV)
+	}
+	return newJSONReader(input, sel.opts.InputSerialization.JSON)
+}
+
+func (sel *SSelect) newRecordWriter() (iRecordWriter, error) {
+	if sel.opts.OutputSerialization.CSV != nil {
+		return newCSVWriter(sel.opts.OutputSerialization.CSV)
+	}
+	return newJSONWriter(sel.opts.OutputSerialization.JSON), nil
+}
+
+type sCountingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *sCountingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func (q *sQuery) match(rec iRecord) (bool, error) {
+	if q.where == nil {
+		return true, nil
+	}
+	val, err := q.where.eval(rec)
+	if err != nil {
+		return false, err
+	}
+	b, ok := toBool(val)
+	return ok && b, nil
+}
+
+func (q *sQuery) project(rec iRecord) ([]string, []interface{}, error) {
+	if q.selectAll {
+		names, values := rec.Fields()
+		return names, values, nil
+	}
+	names := make([]string, len(q.projections))
+	values := make([]interface{}, len(q.projections))
+	for i, proj := range q.projections {
+		val, err := proj.expr.eval(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		names[i] = q.projectionName(i)
+		values[i] = val
+	}
+	return names, values, nil
+}
+
+func (q *sQuery) projectionName(i int) string {
+	proj := q.projections[i]
+	if len(proj.alias) > 0 {
+		return proj.alias
+	}
+	if col, ok := proj.expr.(*sColumnExpr); ok {
+		return col.path[len(col.path)-1]
+	}
+	return fmt.Sprintf("_%d", i+1)
+}
+
+// Run streams the input through the query, the encoded records are passed to emit in chunks
+func (sel *SSelect) Run(input io.Reader, emit func(records []byte) error) (*SStats, error) {
+	stats := &SStats{}
+	scanned := &sCountingReader{reader: input}
+	var source io.Reader = scanned
+	switch s3cli.SelectCompressionType(strings.ToUpper(string(sel.opts.InputSerialization.CompressionType))) {
+	case s3cli.SelectCompressionGZIP:
+		gz, err := gzip.NewReader(scanned)
+		if err != nil {
+			return nil, newSelectError(ErrCodeInvalidCompressionFormat, "%s", err)
+		}
+		defer gz.Close()
+		source = gz
+	case s3cli.SelectCompressionBZIP:
+		source = bzip2.NewReader(scanned)
+	}
+	processed := &sCountingReader{reader: source}
+	reader, err := sel.newRecordReader(processed)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := sel.newRecordWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	flush := func(force bool) error {
+		if buf.Len() == 0 || (!force && buf.Len() < recordsChunkSize) {
+			return nil
+		}
+		stats.BytesReturned += int64(buf.Len())
+		err := emit(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	q := sel.query
+	aggStates := make([]sAggregateState, len(q.projections))
+	var count int64
+	for q.aggregate || q.limit < 0 || count < q.limit {
+		rec, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			if _, ok := err.(SelectError); !ok {
+				err = newSelectError(ErrCodeInternalError, "read object: %s", err)
+			}
+			return nil, err
+		}
+		matched, err := q.match(rec)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		if q.aggregate {
+			for i := range q.projections {
+				err := aggStates[i].accumulate(q.projections[i].expr.(*sAggregateExpr), rec)
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		names, values, err := q.project(rec)
+		if err != nil {
+			return nil, err
+		}
+		err = writer.Write(buf, names, values)
+		if err != nil {
+			return nil, err
+		}
+		count++
+		err = flush(false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if q.aggregate {
+		names := make([]string, len(q.projections))
+		values := make([]interface{}, len(q.projections))
+		for i := range q.projections {
+			names[i] = q.projectionName(i)
+			values[i] = aggStates[i].result(q.projections[i].expr.(*sAggregateExpr))
+		}
+		err := writer.Write(buf, names, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = flush(true)
+	if err != nil {
+		return nil, err
+	}
+	stats.BytesScanned = scanned.count
+	stats.BytesProcessed = processed.count
+	return stats, nil
+}
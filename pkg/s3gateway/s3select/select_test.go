@@ -0,0 +1,151 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"yunion.io/x/s3cli"
+)
+
+const testCSV = `name,level,latency
+api,info,12
+api,error,250
+web,error,
+db,warn,33.5
+web,info,7
+`
+
+const testJSON = `{"host":"api","req":{"status":200,"ms":12}}
+{"host":"api","req":{"status":500,"ms":250}}
+{"host":"web","req":{"status":404,"ms":3}}
+`
+
+func runSelect(t *testing.T, opts *s3cli.SelectObjectOptions, input []byte) string {
+	sel, err := NewSelect(opts)
+	if err != nil {
+		t.Fatalf("NewSelect %q: %s", opts.Expression, err)
+	}
+	output := bytes.Buffer{}
+	stats, err := sel.Run(bytes.NewReader(input), func(records []byte) error {
+		output.Write(records)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run %q: %s", opts.Expression, err)
+	}
+	if stats.BytesReturned != int64(output.Len()) {
+		t.Errorf("BytesReturned %d != %d", stats.BytesReturned, output.Len())
+	}
+	return output.String()
+}
+
+func csvOptions(expr string) *s3cli.SelectObjectOptions {
+	opts := &s3cli.SelectObjectOptions{
+		Expression:     expr,
+		ExpressionType: s3cli.QueryExpressionTypeSQL,
+	}
+	opts.InputSerialization.CSV = &s3cli.CSVInputOptions{FileHeaderInfo: s3cli.CSVFileHeaderInfoUse}
+	opts.OutputSerialization.CSV = &s3cli.CSVOutputOptions{}
+	return opts
+}
+
+func TestSelectCSV(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"SELECT * FROM S3Object LIMIT 1", "api,info,12\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.level = 'error'", "api\nweb\n"},
+		{"SELECT name, latency FROM S3Object WHERE latency > 20", "api,250\ndb,33.5\n"},
+		{"SELECT _1 FROM S3Object WHERE level IN ('warn', 'info') AND NOT name LIKE 'w%'", "api\ndb\n"},
+		{"SELECT name FROM S3Object WHERE latency IS NULL OR latency = ''", "web\n"},
+		{"SELECT COUNT(*), SUM(CAST(latency AS FLOAT)) FROM S3Object WHERE latency <> ''", "4,302.5\n"},
+		{"SELECT COUNT(*) FROM S3Object s WHERE s.name = 'api'", "2\n"},
+		{"SELECT name || '-' || level, latency * 2 FROM S3Object WHERE latency BETWEEN 7 AND 12", "api-info,24\nweb-info,14\n"},
+	}
+	for _, c := range cases {
+		got := runSelect(t, csvOptions(c.expr), []byte(testCSV))
+		if got != c.want {
+			t.Errorf("%s: got %q want %q", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestSelectJSONGzip(t *testing.T) {
+	gzBuf := bytes.Buffer{}
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write([]byte(testJSON))
+	gz.Close()
+
+	opts := &s3cli.SelectObjectOptions{
+		Expression: "SELECT s.host, s.req.ms AS ms FROM S3Object[*] s WHERE s.req.status >= 400",
+	}
+	opts.InputSerialization.CompressionType = s3cli.SelectCompressionGZIP
+	opts.InputSerialization.JSON = &s3cli.JSONInputOptions{Type: s3cli.JSONLinesType}
+	opts.OutputSerialization.JSON = &s3cli.JSONOutputOptions{}
+	got := runSelect(t, opts, gzBuf.Bytes())
+	want := "{\"host\":\"api\",\"ms\":250}\n{\"host\":\"web\",\"ms\":3}\n"
+	if got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestSelectInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"SELECT name FROM S3Object WHERE",
+		"SELECT name FROM mytable",
+		"SELECT name, COUNT(*) FROM S3Object",
+		"SELECT name FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT UPPER(name) FROM S3Object",
+		"SELECT 'abc FROM S3Object",
+	} {
+		_, err := NewSelect(csvOptions(expr))
+		if err == nil {
+			t.Errorf("%s: expect error", expr)
+			continue
+		}
+		if _, ok := err.(SelectError); !ok {
+			t.Errorf("%s: unexpected error type %T", expr, err)
+		}
+	}
+}
+
+func TestEventMessage(t *testing.T) {
+	msg := NewRecordsMessage([]byte("a,b\n"))
+	totalLen := binary.BigEndian.Uint32(msg[0:4])
+	if int(totalLen) != len(msg) {
+		t.Fatalf("total length %d != %d", totalLen, len(msg))
+	}
+	if crc32.ChecksumIEEE(msg[0:8]) != binary.BigEndian.Uint32(msg[8:12]) {
+		t.Errorf("prelude crc mismatch")
+	}
+	if crc32.ChecksumIEEE(msg[:len(msg)-4]) != binary.BigEndian.Uint32(msg[len(msg)-4:]) {
+		t.Errorf("message crc mismatch")
+	}
+	headersLen := binary.BigEndian.Uint32(msg[4:8])
+	headers := string(msg[12 : 12+headersLen])
+	if !strings.Contains(headers, ":event-type") || !strings.Contains(headers, "Records") {
+		t.Errorf("unexpected headers %q", headers)
+	}
+	if payload := string(msg[12+headersLen : len(msg)-4]); payload != "a,b\n" {
+		t.Errorf("unexpected payload %q", payload)
+	}
+}
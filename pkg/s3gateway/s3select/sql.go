@@ -0,0 +1,679 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The supported SQL subset:
+//
+//   SELECT * | expr [[AS] alias], ... | COUNT(*|expr), SUM(expr), ...
+//   FROM S3Object[[*]] [[AS] alias]
+//   [WHERE expr]
+//   [LIMIT n]
+//
+// expr supports column references (name, alias.name, "quoted name", _N),
+// string/number/boolean/NULL literals, arithmetic (+ - * / %), string concatenation (||),
+// comparison (= != <> < <= > >=), [NOT] LIKE, [NOT] IN, [NOT] BETWEEN, IS [NOT] NULL,
+// AND/OR/NOT and CAST(expr AS INT|FLOAT|STRING|BOOL)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type sToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(sql string) ([]sToken, error) {
+	tokens := make([]sToken, 0)
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			// a doubled quote inside the quotes escapes itself
+			var sb strings.Builder
+			j := i + 1
+			closed := false
+			for j < len(runes) {
+				if runes[j] == c {
+					if j+1 < len(runes) && runes[j+1] == c {
+						sb.WriteRune(c)
+						j += 2
+						continue
+					}
+					closed = true
+					break
+				}
+				sb.WriteRune(runes[j])
+				j++
+			}
+			if !closed {
+				return nil, newSelectError(ErrCodeParseUnexpectedToken, "unterminated quoted string at %d", i)
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, sToken{kind: kind, text: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sToken{kind: tokenNumber, text: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, sToken{kind: tokenIdent, text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "<=", ">=", "<>", "!=", "||":
+					tokens = append(tokens, sToken{kind: tokenSymbol, text: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune(",()*.=<>+-/%[]", c) {
+				return nil, newSelectError(ErrCodeParseUnexpectedToken, "unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, sToken{kind: tokenSymbol, text: string(c), pos: i})
+			i++
+		}
+	}
+	tokens = append(tokens, sToken{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+type sProjection struct {
+	expr  iExpr
+	alias string
+}
+
+type sQuery struct {
+	selectAll   bool
+	projections []sProjection
+	alias       string
+	where       iExpr
+	limit       int64
+	aggregate   bool
+}
+
+type sParser struct {
+	tokens []sToken
+	pos    int
+
+	alias      string
+	aggregates int
+}
+
+func (p *sParser) peek() sToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sParser) next() sToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sParser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return newSelectError(ErrCodeParseUnexpectedToken, "unexpected end of expression")
+	}
+	return newSelectError(ErrCodeParseUnexpectedToken, "unexpected token %q at %d", t.text, t.pos)
+}
+
+func (p *sParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *sParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sParser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *sParser) isSymbol(sym string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.text == sym
+}
+
+func (p *sParser) acceptSymbol(sym string) bool {
+	if p.isSymbol(sym) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sParser) expectSymbol(sym string) error {
+	if !p.acceptSymbol(sym) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "IN": true, "BETWEEN": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true, "CAST": true,
+}
+
+func isReserved(t sToken) bool {
+	return t.kind == tokenIdent && reservedWords[strings.ToUpper(t.text)]
+}
+
+func parseQuery(sql string) (*sQuery, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &sParser{tokens: tokens}
+
+	// the table alias is needed to resolve the column references in the projections,
+	// so parse the FROM clause ahead
+	fromPos := -1
+	depth := 0
+	for i, t := range tokens {
+		if t.kind == tokenSymbol && t.text == "(" {
+			depth++
+		} else if t.kind == tokenSymbol && t.text == ")" {
+			depth--
+		} else if depth == 0 && t.kind == tokenIdent && strings.EqualFold(t.text, "FROM") {
+			fromPos = i
+			break
+		}
+	}
+	if fromPos < 0 {
+		return nil, newSelectError(ErrCodeParseUnexpectedToken, "missing FROM clause")
+	}
+	p.pos = fromPos + 1
+	err = p.parseTable()
+	if err != nil {
+		return nil, err
+	}
+	tailPos := p.pos
+
+	query := &sQuery{
+		alias: p.alias,
+		limit: -1,
+	}
+	p.pos = 0
+	err = p.expectKeyword("SELECT")
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptSymbol("*") {
+		query.selectAll = true
+	} else {
+		topAggregates := 0
+		for {
+			proj, err := p.parseProjection()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := proj.expr.(*sAggregateExpr); ok {
+				topAggregates++
+			}
+			query.projections = append(query.projections, proj)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if p.aggregates != topAggregates {
+			return nil, newSelectError(ErrCodeUnsupportedSyntax, "aggregate functions are only supported as top level projections")
+		}
+		if topAggregates > 0 {
+			if topAggregates != len(query.projections) {
+				return nil, newSelectError(ErrCodeUnsupportedSyntax, "cannot mix aggregate functions with other projections")
+			}
+			query.aggregate = true
+		}
+	}
+	if p.pos != fromPos {
+		return nil, p.unexpected()
+	}
+
+	p.pos = tailPos
+	if p.acceptKeyword("WHERE") {
+		aggregates := p.aggregates
+		query.where, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.aggregates != aggregates {
+			return nil, newSelectError(ErrCodeUnsupportedSyntax, "aggregate functions are not allowed in WHERE clause")
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		if t.kind != tokenNumber {
+			return nil, newSelectError(ErrCodeParseUnexpectedToken, "invalid LIMIT %q", t.text)
+		}
+		query.limit, err = strconv.ParseInt(t.text, 10, 64)
+		if err != nil || query.limit < 0 {
+			return nil, newSelectError(ErrCodeParseUnexpectedToken, "invalid LIMIT %q", t.text)
+		}
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return query, nil
+}
+
+func (p *sParser) parseTable() error {
+	t := p.next()
+	if t.kind != tokenIdent || !strings.EqualFold(t.text, "S3Object") {
+		return newSelectError(ErrCodeInvalidDataSource, "only S3Object is supported as data source")
+	}
+	if p.acceptSymbol("[") {
+		err := p.expectSymbol("*")
+		if err != nil {
+			return err
+		}
+		err = p.expectSymbol("]")
+		if err != nil {
+			return err
+		}
+	}
+	if p.isSymbol(".") {
+		return newSelectError(ErrCodeUnsupportedSyntax, "path in FROM clause is not supported")
+	}
+	p.acceptKeyword("AS")
+	t = p.peek()
+	if (t.kind == tokenIdent && !isReserved(t)) || t.kind == tokenQuotedIdent {
+		p.alias = t.text
+		p.next()
+	}
+	return nil
+}
+
+func (p *sParser) parseProjection() (sProjection, error) {
+	proj := sProjection{}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return proj, err
+	}
+	proj.expr = expr
+	if p.acceptKeyword("AS") {
+		t := p.next()
+		if (t.kind != tokenIdent || isReserved(t)) && t.kind != tokenQuotedIdent {
+			return proj, newSelectError(ErrCodeParseUnexpectedToken, "invalid alias %q", t.text)
+		}
+		proj.alias = t.text
+	} else if t := p.peek(); (t.kind == tokenIdent && !isReserved(t)) || t.kind == tokenQuotedIdent {
+		proj.alias = t.text
+		p.next()
+	}
+	return proj, nil
+}
+
+func (p *sParser) parseExpr() (iExpr, error) {
+	return p.parseOr()
+}
+
+func (p *sParser) parseOr() (iExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &sLogicalExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseAnd() (iExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &sLogicalExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseNot() (iExpr, error) {
+	if p.acceptKeyword("NOT") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &sNotExpr{expr: expr}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *sParser) parsePredicate() (iExpr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokenSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &sCompareExpr{op: t.text, left: left, right: right}, nil
+		}
+		return left, nil
+	}
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		err := p.expectKeyword("NULL")
+		if err != nil {
+			return nil, err
+		}
+		return &sIsNullExpr{expr: left, not: not}, nil
+	}
+	not := p.acceptKeyword("NOT")
+	var expr iExpr
+	switch {
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		expr = &sLikeExpr{expr: left, pattern: pattern}
+	case p.acceptKeyword("IN"):
+		err := p.expectSymbol("(")
+		if err != nil {
+			return nil, err
+		}
+		in := &sInExpr{expr: left}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		err = p.expectSymbol(")")
+		if err != nil {
+			return nil, err
+		}
+		expr = in
+	case p.acceptKeyword("BETWEEN"):
+		lower, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectKeyword("AND")
+		if err != nil {
+			return nil, err
+		}
+		upper, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		expr = &sLogicalExpr{
+			op:    "AND",
+			left:  &sCompareExpr{op: ">=", left: left, right: lower},
+			right: &sCompareExpr{op: "<=", left: left, right: upper},
+		}
+	default:
+		if not {
+			return nil, p.unexpected()
+		}
+		return left, nil
+	}
+	if not {
+		expr = &sNotExpr{expr: expr}
+	}
+	return expr, nil
+}
+
+func (p *sParser) parseAdditive() (iExpr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("+") || p.isSymbol("-") || p.isSymbol("||") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		if op == "||" {
+			left = &sConcatExpr{left: left, right: right}
+		} else {
+			left = &sArithExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseMultiplicative() (iExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("*") || p.isSymbol("/") || p.isSymbol("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &sArithExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseUnary() (iExpr, error) {
+	if p.acceptSymbol("-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &sArithExpr{op: "-", left: &sLiteralExpr{value: int64(0)}, right: expr}, nil
+	}
+	if p.acceptSymbol("+") {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func parseNumber(text string) (interface{}, error) {
+	if !strings.Contains(text, ".") {
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, newSelectError(ErrCodeParseUnexpectedToken, "invalid number %q", text)
+	}
+	return f, nil
+}
+
+func (p *sParser) parsePrimary() (iExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		val, err := parseNumber(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return &sLiteralExpr{value: val}, nil
+	case tokenString:
+		p.next()
+		return &sLiteralExpr{value: t.text}, nil
+	case tokenSymbol:
+		if p.acceptSymbol("(") {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			err = p.expectSymbol(")")
+			if err != nil {
+				return nil, err
+			}
+			return expr, nil
+		}
+		return nil, p.unexpected()
+	case tokenQuotedIdent:
+		return p.parseColumn()
+	case tokenIdent:
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			p.next()
+			return &sLiteralExpr{value: nil}, nil
+		case "TRUE":
+			p.next()
+			return &sLiteralExpr{value: true}, nil
+		case "FALSE":
+			p.next()
+			return &sLiteralExpr{value: false}, nil
+		}
+		if p.tokens[p.pos+1].kind == tokenSymbol && p.tokens[p.pos+1].text == "(" {
+			return p.parseFunction()
+		}
+		if isReserved(t) {
+			return nil, p.unexpected()
+		}
+		return p.parseColumn()
+	}
+	return nil, p.unexpected()
+}
+
+func (p *sParser) parseFunction() (iExpr, error) {
+	name := strings.ToUpper(p.next().text)
+	p.next() // (
+	switch name {
+	case "CAST":
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectKeyword("AS")
+		if err != nil {
+			return nil, err
+		}
+		t := p.next()
+		typ := strings.ToUpper(t.text)
+		switch typ {
+		case "INT", "INTEGER", "BIGINT":
+			typ = castTypeInt
+		case "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC", "REAL":
+			typ = castTypeFloat
+		case "STRING", "VARCHAR", "CHAR", "TEXT":
+			typ = castTypeString
+		case "BOOL", "BOOLEAN":
+			typ = castTypeBool
+		default:
+			return nil, newSelectError(ErrCodeUnsupportedSyntax, "unsupported cast type %q", t.text)
+		}
+		err = p.expectSymbol(")")
+		if err != nil {
+			return nil, err
+		}
+		return &sCastExpr{expr: expr, typ: typ}, nil
+	case aggregateCount, aggregateSum:
+		agg := &sAggregateExpr{fn: name}
+		if name == aggregateCount && p.acceptSymbol("*") {
+			// COUNT(*) counts all records
+		} else {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			agg.arg = arg
+		}
+		err := p.expectSymbol(")")
+		if err != nil {
+			return nil, err
+		}
+		p.aggregates++
+		return agg, nil
+	}
+	return nil, newSelectError(ErrCodeUnsupportedSyntax, "unsupported function %s", name)
+}
+
+func (p *sParser) parseColumn() (iExpr, error) {
+	path := []string{}
+	quoted := []bool{}
+	for {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		path = append(path, t.text)
+		quoted = append(quoted, t.kind == tokenQuotedIdent)
+		if !p.acceptSymbol(".") {
+			break
+		}
+	}
+	// strip the table alias
+	if len(path) > 1 && !quoted[0] && (strings.EqualFold(path[0], "S3Object") || (len(p.alias) > 0 && strings.EqualFold(path[0], p.alias))) {
+		path = path[1:]
+	} else if len(path) > 1 && quoted[0] && path[0] == p.alias {
+		path = path[1:]
+	}
+	return &sColumnExpr{path: path}, nil
+}
@@ -42,6 +42,7 @@ import (
 	"yunion.io/x/pkg/utils"
 
 	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type THttpMethod string
@@ -482,6 +483,16 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 	if len(ctxData.RequestId) > 0 {
 		header.Set("X-Request-Id", ctxData.RequestId)
 	}
+	// the path is left out of the name of the span, as it contains the ids
+	spanName, target := string(method), ""
+	if u, err := url.Parse(urlStr); err == nil {
+		spanName, target = fmt.Sprintf("%s %s", method, u.Host), u.Path
+	}
+	ctx, span := tracing.StartSpan(ctx, spanName, tracing.SPAN_KIND_CLIENT)
+	defer span.End()
+	span.SetAttribute("http.method", string(method))
+	span.SetAttribute("http.target", target)
+	tracing.Inject(ctx, header)
 	req, err := http.NewRequest(string(method), urlStr, body)
 	if err != nil {
 		return nil, nil, err
@@ -523,8 +534,10 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 	resp, err := client.Do(req)
 	if err != nil {
 		red(err.Error())
+		span.SetError(err.Error())
 		return req, nil, err
 	}
+	span.SetStatusCode(resp.StatusCode)
 	encoding := resp.Header.Get("Content-Encoding")
 	switch encoding {
 	case "", "identity":
@@ -471,6 +471,30 @@ func init() {
 		EN("Delete Policy").
 		CN("删除Policy"),
 	)
+	t.Set(ACT_SET_LIFECYCLE, i18n.NewTableEntry().
+		EN("Set Lifecycle").
+		CN("设置生命周期"),
+	)
+	t.Set(ACT_DELETE_LIFECYCLE, i18n.NewTableEntry().
+		EN("Delete Lifecycle").
+		CN("删除生命周期"),
+	)
+	t.Set(ACT_EXPIRE_OBJECTS, i18n.NewTableEntry().
+		EN("Expire Objects").
+		CN("过期删除对象"),
+	)
+	t.Set(ACT_SET_VERSIONING, i18n.NewTableEntry().
+		EN("Set Versioning").
+		CN("设置多版本"),
+	)
+	t.Set(ACT_DELETE_OBJECT_VERSION, i18n.NewTableEntry().
+		EN("Delete Object Version").
+		CN("删除对象版本"),
+	)
+	t.Set(ACT_RESTORE_OBJECT_VERSION, i18n.NewTableEntry().
+		EN("Restore Object Version").
+		CN("恢复对象版本"),
+	)
 
 	t.Set(ACT_NAT_CREATE_SNAT, i18n.NewTableEntry().
 		EN("Nat Create Snat").
@@ -0,0 +1,366 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	ruleKeyResult     = "result"
+	ruleKeyConditions = "conditions"
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// STimeWindow is a time range of a day, e.g. "09:00-18:00" or "mon-fri 09:00-18:00"
+// a window whose end is earlier than its start spans midnight
+type STimeWindow struct {
+	// empty means every day
+	Weekdays []time.Weekday
+	// minutes since 00:00
+	Start int
+	End   int
+}
+
+// SRbacConditions restricts when a rule takes effect, all the specified conditions must be met
+type SRbacConditions struct {
+	// metadata tags of the target object, e.g. user:env=dev, an empty value matches any value of the key
+	Tags map[string]string
+	// ids or names of the groups, the caller should belong to any of them
+	Groups []string
+	// the request should happen in any of the time windows
+	TimeWindows []STimeWindow
+}
+
+// SConditionContext is what the rule conditions are evaluated against
+type SConditionContext struct {
+	// ids and names of the groups the caller belongs to
+	Groups []string
+	// time of the request, now if zero
+	Time time.Time
+	// metadata tags of the target object, nil if the request does not target an object
+	ObjectTags map[string]string
+	// assume the tag conditions are met, used to collect the tag conditions of a list request
+	AssumeTagsMet bool
+}
+
+func parseMinutes(str string) (int, error) {
+	parts := strings.Split(str, ":")
+	if len(parts) != 2 {
+		return 0, errors.Wrapf(ErrInvalidTimeWindow, "invalid time %s", str)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, errors.Wrapf(ErrInvalidTimeWindow, "invalid hour %s", str)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute > 0) {
+		return 0, errors.Wrapf(ErrInvalidTimeWindow, "invalid minute %s", str)
+	}
+	return hour*60 + minute, nil
+}
+
+func parseWeekday(str string) (time.Weekday, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	for i := range weekdayNames {
+		if strings.HasPrefix(str, weekdayNames[i]) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, errors.Wrapf(ErrInvalidTimeWindow, "invalid weekday %s", str)
+}
+
+func parseWeekdays(str string) ([]time.Weekday, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(str, ",") {
+		if idx := strings.Index(part, "-"); idx >= 0 {
+			start, err := parseWeekday(part[:idx])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseWeekday(part[idx+1:])
+			if err != nil {
+				return nil, err
+			}
+			for d := start; ; d = (d + 1) % 7 {
+				days[d] = true
+				if d == end {
+					break
+				}
+			}
+		} else {
+			day, err := parseWeekday(part)
+			if err != nil {
+				return nil, err
+			}
+			days[day] = true
+		}
+	}
+	ret := make([]time.Weekday, 0, len(days))
+	for d := range days {
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret, nil
+}
+
+func ParseTimeWindow(str string) (STimeWindow, error) {
+	win := STimeWindow{}
+	parts := strings.Fields(str)
+	var rangeStr string
+	switch len(parts) {
+	case 1:
+		rangeStr = parts[0]
+	case 2:
+		days, err := parseWeekdays(parts[0])
+		if err != nil {
+			return win, err
+		}
+		win.Weekdays = days
+		rangeStr = parts[1]
+	default:
+		return win, errors.Wrapf(ErrInvalidTimeWindow, "invalid time window %s", str)
+	}
+	idx := strings.Index(rangeStr, "-")
+	if idx < 0 {
+		return win, errors.Wrapf(ErrInvalidTimeWindow, "invalid time range %s", rangeStr)
+	}
+	var err error
+	win.Start, err = parseMinutes(rangeStr[:idx])
+	if err != nil {
+		return win, err
+	}
+	win.End, err = parseMinutes(rangeStr[idx+1:])
+	if err != nil {
+		return win, err
+	}
+	if win.Start == win.End {
+		return win, errors.Wrapf(ErrInvalidTimeWindow, "empty time range %s", rangeStr)
+	}
+	return win, nil
+}
+
+func (win STimeWindow) String() string {
+	rangeStr := fmt.Sprintf("%02d:%02d-%02d:%02d", win.Start/60, win.Start%60, win.End/60, win.End%60)
+	if len(win.Weekdays) == 0 {
+		return rangeStr
+	}
+	days := make([]string, len(win.Weekdays))
+	for i := range win.Weekdays {
+		days[i] = weekdayNames[win.Weekdays[i]]
+	}
+	return fmt.Sprintf("%s %s", strings.Join(days, ","), rangeStr)
+}
+
+func (win STimeWindow) matchWeekday(day time.Weekday) bool {
+	if len(win.Weekdays) == 0 {
+		return true
+	}
+	for i := range win.Weekdays {
+		if win.Weekdays[i] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (win STimeWindow) Contains(tm time.Time) bool {
+	minutes := tm.Hour()*60 + tm.Minute()
+	if win.Start < win.End {
+		return minutes >= win.Start && minutes < win.End && win.matchWeekday(tm.Weekday())
+	}
+	// the window spans midnight, the part after midnight belongs to the day before
+	if minutes >= win.Start {
+		return win.matchWeekday(tm.Weekday())
+	}
+	if minutes < win.End {
+		return win.matchWeekday((tm.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+func (conds *SRbacConditions) IsEmpty() bool {
+	return len(conds.Tags) == 0 && len(conds.Groups) == 0 && len(conds.TimeWindows) == 0
+}
+
+func (conds *SRbacConditions) clone() *SRbacConditions {
+	nc := &SRbacConditions{}
+	if len(conds.Tags) > 0 {
+		nc.Tags = make(map[string]string, len(conds.Tags))
+		for k, v := range conds.Tags {
+			nc.Tags[k] = v
+		}
+	}
+	if len(conds.Groups) > 0 {
+		nc.Groups = make([]string, len(conds.Groups))
+		copy(nc.Groups, conds.Groups)
+	}
+	if len(conds.TimeWindows) > 0 {
+		nc.TimeWindows = make([]STimeWindow, len(conds.TimeWindows))
+		copy(nc.TimeWindows, conds.TimeWindows)
+	}
+	return nc
+}
+
+func matchTags(condTags map[string]string, tags map[string]string) bool {
+	for k, v := range condTags {
+		val, ok := tags[strings.ToLower(k)]
+		if !ok {
+			return false
+		}
+		if !isWildMatch(v) && val != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (conds *SRbacConditions) matchTime(tm time.Time) bool {
+	if tm.IsZero() {
+		tm = time.Now()
+	}
+	for i := range conds.TimeWindows {
+		if conds.TimeWindows[i].Contains(tm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match checks the conditions against the context, the conditions are never met without a context
+func (conds *SRbacConditions) Match(cctx *SConditionContext) bool {
+	if conds == nil {
+		return true
+	}
+	if cctx == nil {
+		return false
+	}
+	if len(conds.Groups) > 0 && !intersect(conds.Groups, cctx.Groups) {
+		return false
+	}
+	if len(conds.TimeWindows) > 0 && !conds.matchTime(cctx.Time) {
+		return false
+	}
+	if len(conds.Tags) > 0 && !cctx.AssumeTagsMet {
+		if cctx.ObjectTags == nil || !matchTags(conds.Tags, cctx.ObjectTags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (conds *SRbacConditions) hasTags() bool {
+	return conds != nil && len(conds.Tags) > 0
+}
+
+// STagCondition is met by the objects carrying all the Tags and not carrying all the tags of any of NotTags
+type STagCondition struct {
+	Tags    map[string]string
+	NotTags []map[string]string
+}
+
+// IsEmpty tells whether the condition is met by any object
+func (cond STagCondition) IsEmpty() bool {
+	return len(cond.Tags) == 0 && len(cond.NotTags) == 0
+}
+
+func (cond STagCondition) Match(tags map[string]string) bool {
+	if !matchTags(cond.Tags, tags) {
+		return false
+	}
+	for i := range cond.NotTags {
+		if matchTags(cond.NotTags[i], tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (conds *SRbacConditions) Encode() jsonutils.JSONObject {
+	ret := jsonutils.NewDict()
+	if len(conds.Tags) > 0 {
+		ret.Add(jsonutils.Marshal(conds.Tags), "tags")
+	}
+	if len(conds.Groups) > 0 {
+		ret.Add(jsonutils.NewStringArray(conds.Groups), "groups")
+	}
+	if len(conds.TimeWindows) > 0 {
+		wins := make([]string, len(conds.TimeWindows))
+		for i := range conds.TimeWindows {
+			wins[i] = conds.TimeWindows[i].String()
+		}
+		ret.Add(jsonutils.NewStringArray(wins), "time_windows")
+	}
+	return ret
+}
+
+func (conds *SRbacConditions) String() string {
+	return conds.Encode().String()
+}
+
+func DecodeConditions(input jsonutils.JSONObject) (*SRbacConditions, error) {
+	conds := &SRbacConditions{}
+	if input.Contains("tags") {
+		conds.Tags = make(map[string]string)
+		err := input.Unmarshal(&conds.Tags, "tags")
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidConditions, "tags should be a map of strings")
+		}
+	}
+	if input.Contains("groups") {
+		groups, err := input.GetArray("groups")
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidConditions, "groups should be an array")
+		}
+		conds.Groups = jsonutils.JSONArray2StringArray(groups)
+	}
+	if input.Contains("time_windows") {
+		wins, err := input.GetArray("time_windows")
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidConditions, "time_windows should be an array")
+		}
+		for _, winStr := range jsonutils.JSONArray2StringArray(wins) {
+			win, err := ParseTimeWindow(winStr)
+			if err != nil {
+				return nil, errors.Wrap(err, "ParseTimeWindow")
+			}
+			conds.TimeWindows = append(conds.TimeWindows, win)
+		}
+	}
+	if conds.IsEmpty() {
+		return nil, errors.Wrap(ErrInvalidConditions, "empty conditions")
+	}
+	return conds, nil
+}
+
+// isConditionalRule tells whether a json dict is a rule with conditions, e.g.
+// {"result": "allow", "conditions": {"tags": {"user:env": "dev"}, "groups": ["ops"], "time_windows": ["mon-fri 09:00-18:00"]}}
+func isConditionalRule(dict *jsonutils.JSONDict) bool {
+	if !dict.Contains(ruleKeyResult) || !dict.Contains(ruleKeyConditions) || dict.Length() != 2 {
+		return false
+	}
+	_, err := dict.GetString(ruleKeyResult)
+	return err == nil
+}
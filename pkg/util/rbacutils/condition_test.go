@@ -0,0 +1,219 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbacutils
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	// 2020-06-01 is a Monday
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2020, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+	cases := []struct {
+		in     string
+		str    string
+		tm     time.Time
+		inside bool
+	}{
+		{"09:00-18:00", "09:00-18:00", monday(9, 0), true},
+		{"09:00-18:00", "09:00-18:00", monday(18, 0), false},
+		{"Mon-Fri 09:00-18:00", "mon,tue,wed,thu,fri 09:00-18:00", monday(12, 30), true},
+		{"sat,sun 09:00-18:00", "sun,sat 09:00-18:00", monday(12, 30), false},
+		{"fri-mon 22:00-06:00", "sun,mon,fri,sat 22:00-06:00", monday(23, 0), true},
+		// early monday belongs to the window of sunday night
+		{"sun 22:00-06:00", "sun 22:00-06:00", monday(5, 59), true},
+		{"mon 22:00-06:00", "mon 22:00-06:00", monday(5, 59), false},
+	}
+	for _, c := range cases {
+		win, err := ParseTimeWindow(c.in)
+		if err != nil {
+			t.Errorf("ParseTimeWindow %s: %s", c.in, err)
+			continue
+		}
+		if win.String() != c.str {
+			t.Errorf("%s: want %s got %s", c.in, c.str, win.String())
+		}
+		if win.Contains(c.tm) != c.inside {
+			t.Errorf("%s contains %s: want %v", c.in, c.tm, c.inside)
+		}
+	}
+	for _, str := range []string{"", "09:00", "9-18", "25:00-26:00", "09:00-09:00", "xyz 09:00-18:00", "mon 09:00-18:00 x"} {
+		if _, err := ParseTimeWindow(str); err == nil {
+			t.Errorf("invalid time window %s should fail", str)
+		}
+	}
+}
+
+func TestConditionalRules(t *testing.T) {
+	policyStr := `{
+		"compute": {
+			"servers": {
+				"get": "allow",
+				"list": "allow",
+				"perform": {
+					"stop": {"result": "allow", "conditions": {"tags": {"user:env": "dev"}}},
+					"start": {"result": "allow", "conditions": {"groups": ["ops"], "time_windows": ["mon-fri 09:00-18:00"]}},
+					"*": "deny"
+				}
+			}
+		}
+	}`
+	policyJson, err := jsonutils.ParseString(policyStr)
+	if err != nil {
+		t.Fatalf("parse policy %s", err)
+	}
+	policy, err := DecodePolicy(policyJson)
+	if err != nil {
+		t.Fatalf("DecodePolicy %s", err)
+	}
+	// encode and decode again
+	policy, err = DecodePolicy(policy.Encode())
+	if err != nil {
+		t.Fatalf("DecodePolicy of encoded policy %s", err)
+	}
+
+	workTime := time.Date(2020, 6, 1, 10, 0, 0, 0, time.Local)
+	offTime := time.Date(2020, 6, 6, 10, 0, 0, 0, time.Local)
+	cases := []struct {
+		name   string
+		cctx   *SConditionContext
+		action string
+		want   TRbacResult
+	}{
+		{"stop without object", &SConditionContext{}, "stop", Deny},
+		{"stop dev object", &SConditionContext{ObjectTags: map[string]string{"user:env": "dev"}}, "stop", Allow},
+		{"stop prod object", &SConditionContext{ObjectTags: map[string]string{"user:env": "prod"}}, "stop", Deny},
+		{"stop assume tags", &SConditionContext{AssumeTagsMet: true}, "stop", Allow},
+		{"stop without context", nil, "stop", Deny},
+		{"start in group at work", &SConditionContext{Groups: []string{"ops"}, Time: workTime}, "start", Allow},
+		{"start in group off work", &SConditionContext{Groups: []string{"ops"}, Time: offTime}, "start", Deny},
+		{"start not in group", &SConditionContext{Groups: []string{"dev"}, Time: workTime}, "start", Deny},
+	}
+	for _, c := range cases {
+		rule := policy.GetMatchRuleWithContext(c.cctx, "compute", "servers", "perform", c.action)
+		got := Deny
+		if rule != nil {
+			got = rule.Result
+		}
+		if got != c.want {
+			t.Errorf("%s: want %s got %s", c.name, c.want, got)
+		}
+	}
+
+	for _, str := range []string{
+		`{"compute": {"result": "allow", "conditions": {}}}`,
+		`{"compute": {"result": "allow", "conditions": {"time_windows": ["9-18"]}}}`,
+	} {
+		json, _ := jsonutils.ParseString(str)
+		if _, err := DecodePolicy(json); err == nil {
+			t.Errorf("invalid policy %s should fail", str)
+		}
+	}
+}
+
+func TestTagConditions(t *testing.T) {
+	policyStr := `{
+		"compute": {
+			"servers": {
+				"*": "allow",
+				"delete": {"result": "deny", "conditions": {"tags": {"user:env": "prod"}}},
+				"perform": {
+					"stop": {"result": "deny", "conditions": {"tags": {"user:env": "prod"}}},
+					"*": {"result": "allow", "conditions": {"tags": {"user:owner": ""}}}
+				}
+			}
+		}
+	}`
+	policyJson, err := jsonutils.ParseString(policyStr)
+	if err != nil {
+		t.Fatalf("parse policy %s", err)
+	}
+	policy, err := DecodePolicy(policyJson)
+	if err != nil {
+		t.Fatalf("DecodePolicy %s", err)
+	}
+
+	dev := map[string]string{"user:env": "dev"}
+	prod := map[string]string{"user:env": "prod"}
+	ownedProd := map[string]string{"user:env": "prod", "user:owner": "alice"}
+	ownedDev := map[string]string{"user:env": "dev", "user:owner": "alice"}
+	cases := []struct {
+		name   string
+		action string
+		extra  []string
+		conds  string
+		allows []map[string]string
+		denies []map[string]string
+	}{
+		{
+			name:   "get",
+			action: "get",
+			conds:  `[{}]`,
+			allows: []map[string]string{dev, prod, {}},
+		},
+		{
+			name:   "delete",
+			action: "delete",
+			conds:  `[{"not_tags":[{"user:env":"prod"}]}]`,
+			allows: []map[string]string{dev, {}},
+			denies: []map[string]string{prod, ownedProd},
+		},
+		{
+			name:   "stop",
+			action: "perform",
+			extra:  []string{"stop"},
+			conds:  `[{"not_tags":[{"user:env":"prod"}],"tags":{"user:owner":""}},{"not_tags":[{"user:env":"prod"}]}]`,
+			allows: []map[string]string{dev, ownedDev, {}},
+			denies: []map[string]string{prod, ownedProd},
+		},
+	}
+	for _, c := range cases {
+		conds := policy.GetTagConditionsWithContext(&SConditionContext{}, "compute", "servers", c.action, c.extra...)
+		if jsonutils.Marshal(conds).String() != c.conds {
+			t.Errorf("%s: want conditions %s got %s", c.name, c.conds, jsonutils.Marshal(conds))
+		}
+		match := func(tags map[string]string) bool {
+			for i := range conds {
+				if conds[i].Match(tags) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, tags := range c.allows {
+			rule := policy.GetMatchRuleWithContext(&SConditionContext{ObjectTags: tags}, "compute", "servers", c.action, c.extra...)
+			if rule == nil || rule.Result != Allow {
+				t.Errorf("%s: %v should be allowed", c.name, tags)
+			}
+			if !match(tags) {
+				t.Errorf("%s: %v should meet the tag conditions", c.name, tags)
+			}
+		}
+		for _, tags := range c.denies {
+			rule := policy.GetMatchRuleWithContext(&SConditionContext{ObjectTags: tags}, "compute", "servers", c.action, c.extra...)
+			if rule != nil && rule.Result == Allow {
+				t.Errorf("%s: %v should be denied", c.name, tags)
+			}
+			if match(tags) {
+				t.Errorf("%s: %v should not meet the tag conditions", c.name, tags)
+			}
+		}
+	}
+}
@@ -24,4 +24,8 @@ var (
 	ErrConflict = errors.New("conflict?")
 
 	ErrInvalidRules = errors.New("invalid rules")
+
+	ErrInvalidConditions = errors.New("invalid conditions")
+
+	ErrInvalidTimeWindow = errors.New("invalid time window")
 )
@@ -50,6 +50,41 @@ func (policy TPolicy) GetMatchRule(service string, resource string, action strin
 	return GetMatchRule(policy, service, resource, action, extra...)
 }
 
+func (policy TPolicy) GetMatchRuleWithContext(cctx *SConditionContext, service string, resource string, action string, extra ...string) *SRbacRule {
+	return GetMatchRuleWithContext(policy, cctx, service, resource, action, extra...)
+}
+
+func (policy TPolicy) GetTagConditionsWithContext(cctx *SConditionContext, service string, resource string, action string, extra ...string) []STagCondition {
+	return GetTagConditionsWithContext(policy, cctx, service, resource, action, extra...)
+}
+
+func (policy TPolicy) hasConditions(check func(conds *SRbacConditions) bool) bool {
+	for i := range policy {
+		if policy[i].Conditions != nil && check(policy[i].Conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy TPolicy) hasTimeConditions() bool {
+	return policy.hasConditions(func(conds *SRbacConditions) bool {
+		return len(conds.TimeWindows) > 0
+	})
+}
+
+func (policy TPolicy) HasTagConditions() bool {
+	return policy.hasConditions(func(conds *SRbacConditions) bool {
+		return len(conds.Tags) > 0
+	})
+}
+
+func (policy TPolicy) HasGroupConditions() bool {
+	return policy.hasConditions(func(conds *SRbacConditions) bool {
+		return len(conds.Groups) > 0
+	})
+}
+
 func DecodePolicy(policyJson jsonutils.JSONObject) (TPolicy, error) {
 	rules, err := json2Rules(policyJson)
 	if err != nil {
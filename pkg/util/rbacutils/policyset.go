@@ -24,9 +24,13 @@ import (
 type TPolicySet []TPolicy
 
 func (policies TPolicySet) GetMatchRules(service string, resource string, action string, extra ...string) []SRbacRule {
+	return policies.GetMatchRulesWithContext(nil, service, resource, action, extra...)
+}
+
+func (policies TPolicySet) GetMatchRulesWithContext(cctx *SConditionContext, service string, resource string, action string, extra ...string) []SRbacRule {
 	matchRules := make([]SRbacRule, 0)
 	for i := range policies {
-		rule := policies[i].GetMatchRule(service, resource, action, extra...)
+		rule := policies[i].GetMatchRuleWithContext(cctx, service, resource, action, extra...)
 		if rule != nil {
 			matchRules = append(matchRules, *rule)
 		}
@@ -34,6 +38,36 @@ func (policies TPolicySet) GetMatchRules(service string, resource string, action
 	return matchRules
 }
 
+// HasTimeConditions tells whether the decisions of the policies vary with time
+func (policies TPolicySet) HasTimeConditions() bool {
+	for i := range policies {
+		if policies[i].hasTimeConditions() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTagConditions tells whether the decisions of the policies vary with the metadata tags of objects
+func (policies TPolicySet) HasTagConditions() bool {
+	for i := range policies {
+		if policies[i].HasTagConditions() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGroupConditions tells whether the decisions of the policies vary with the groups of the caller
+func (policies TPolicySet) HasGroupConditions() bool {
+	for i := range policies {
+		if policies[i].HasGroupConditions() {
+			return true
+		}
+	}
+	return false
+}
+
 func DecodePolicySet(jsonObj jsonutils.JSONObject) (TPolicySet, error) {
 	jsonArr, err := jsonObj.GetArray()
 	if err != nil {
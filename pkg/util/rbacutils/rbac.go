@@ -15,6 +15,7 @@
 package rbacutils
 
 import (
+	"sort"
 	"strings"
 
 	"yunion.io/x/log"
@@ -90,6 +91,8 @@ type SRbacRule struct {
 	Action   string
 	Extra    []string
 	Result   TRbacResult
+	// nil means the rule takes effect unconditionally
+	Conditions *SRbacConditions
 }
 
 func (r SRbacRule) clone() SRbacRule {
@@ -98,6 +101,9 @@ func (r SRbacRule) clone() SRbacRule {
 	if len(r.Extra) > 0 {
 		copy(nr.Extra, r.Extra)
 	}
+	if r.Conditions != nil {
+		nr.Conditions = r.Conditions.clone()
+	}
 	return nr
 }
 
@@ -125,6 +131,12 @@ func (rule *SRbacRule) contains(rule2 *SRbacRule) bool {
 	if string(rule.Result) != string(rule2.Result) {
 		return false
 	}
+	if rule.Conditions != nil {
+		// a conditional rule only contains the rule with the same conditions
+		if rule2.Conditions == nil || rule.Conditions.Encode().String() != rule2.Conditions.Encode().String() {
+			return false
+		}
+	}
 	return true
 }
 
@@ -176,12 +188,22 @@ var (
 	ShowMatchRuleDebug = false
 )
 
+// GetMatchRule finds the matched rule out of the rules without conditions
 func GetMatchRule(rules []SRbacRule, service string, resource string, action string, extra ...string) *SRbacRule {
+	return GetMatchRuleWithContext(rules, nil, service, resource, action, extra...)
+}
+
+// GetMatchRuleWithContext skips the rules whose conditions are not met in the context,
+// the rules with conditions never match a nil context
+func GetMatchRuleWithContext(rules []SRbacRule, cctx *SConditionContext, service string, resource string, action string, extra ...string) *SRbacRule {
 	maxMatchCnt := 0
 	minWeight := 1000000
 	var matchRule *SRbacRule
 	for i := 0; i < len(rules); i += 1 {
 		match, matchCnt, weight := rules[i].match(service, resource, action, extra...)
+		if match && !rules[i].Conditions.Match(cctx) {
+			match = false
+		}
 		if match && ShowMatchRuleDebug {
 			log.Debugf("rule %s match cnt %d weight %d", rules[i], matchCnt, weight)
 		}
@@ -196,6 +218,65 @@ func GetMatchRule(rules []SRbacRule, service string, resource string, action str
 	return matchRule
 }
 
+type sMatchedRule struct {
+	rule   *SRbacRule
+	cnt    int
+	weight int
+}
+
+// GetTagConditionsWithContext returns the tag conditions of the objects that the rules allow the request on,
+// the conditions other than tags are evaluated in the context. An object is allowed if it meets any of the conditions,
+// i.e. the most specific rule matching its tags is an allow rule.
+func GetTagConditionsWithContext(rules []SRbacRule, cctx *SConditionContext, service string, resource string, action string, extra ...string) []STagCondition {
+	tagCctx := &SConditionContext{
+		AssumeTagsMet: true,
+	}
+	if cctx != nil {
+		tagCctx.Groups = cctx.Groups
+		tagCctx.Time = cctx.Time
+	}
+	matches := make([]sMatchedRule, 0)
+	for i := 0; i < len(rules); i += 1 {
+		match, matchCnt, weight := rules[i].match(service, resource, action, extra...)
+		if match && rules[i].Conditions.Match(tagCctx) {
+			matches = append(matches, sMatchedRule{rule: &rules[i], cnt: matchCnt, weight: weight})
+		}
+	}
+	// in the same order GetMatchRuleWithContext picks the rules
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].cnt != matches[j].cnt {
+			return matches[i].cnt > matches[j].cnt
+		}
+		if matches[i].weight != matches[j].weight {
+			return matches[i].weight < matches[j].weight
+		}
+		return matches[i].rule.looserThan(matches[j].rule)
+	})
+	ret := make([]STagCondition, 0)
+	notTags := make([]map[string]string, 0)
+	for _, m := range matches {
+		var tags map[string]string
+		if m.rule.Conditions.hasTags() {
+			tags = m.rule.Conditions.Tags
+		}
+		if m.rule.Result == Allow {
+			cond := STagCondition{
+				Tags:    tags,
+				NotTags: make([]map[string]string, len(notTags)),
+			}
+			copy(cond.NotTags, notTags)
+			ret = append(ret, cond)
+		} else if len(tags) > 0 {
+			notTags = append(notTags, tags)
+		}
+		if len(tags) == 0 {
+			// the rules behind are never picked
+			break
+		}
+	}
+	return ret
+}
+
 const (
 	levelService  = 0
 	levelResource = 1
@@ -28,6 +28,7 @@ type sRbacNode struct {
 	defNode    *sRbacNode
 	downStream map[string]*sRbacNode
 	result     *TRbacResult
+	conditions *SRbacConditions
 	level      int
 }
 
@@ -51,7 +52,9 @@ func (n *sRbacNode) addRule(rule SRbacRule, level int) {
 			}
 			n.defNode = newRbacNode(n.level + 1)
 			n.defNode.result = n.result
+			n.defNode.conditions = n.conditions
 			n.result = nil
+			n.conditions = nil
 		}
 		var key string
 		if level == levelService {
@@ -83,11 +86,13 @@ func (n *sRbacNode) addRule(rule SRbacRule, level int) {
 			log.Warningf("node has been occupide!!!")
 		}
 		n.result = &rule.Result
+		n.conditions = rule.Conditions
 	}
 }
 
+// conditional leaves are never merged
 func (n *sRbacNode) isLeaf() bool {
-	return n.result != nil && n.defNode == nil && len(n.downStream) == 0
+	return n.result != nil && n.conditions == nil && n.defNode == nil && len(n.downStream) == 0
 }
 
 func (n *sRbacNode) reduceDownstream() {
@@ -95,7 +100,7 @@ func (n *sRbacNode) reduceDownstream() {
 	denyKey := make([]string, 0)
 	skipKey := make([]string, 0)
 	for k, v := range n.downStream {
-		if v.result == nil {
+		if v.result == nil || v.conditions != nil {
 			skipKey = append(skipKey, k)
 			continue
 		}
@@ -192,6 +197,7 @@ func (n *sRbacNode) getRules(seed SRbacRule, level int) []SRbacRule {
 	if n.result != nil {
 		rule := seed.clone()
 		rule.Result = *n.result
+		rule.Conditions = n.conditions
 		return []SRbacRule{rule}
 	} else {
 		if n.defNode != nil {
@@ -257,6 +263,12 @@ func json2Rules(json jsonutils.JSONObject) ([]SRbacRule, error) {
 func (n *sRbacNode) json() jsonutils.JSONObject {
 	var result jsonutils.JSONObject
 	if n.result != nil {
+		if n.conditions != nil {
+			ret := jsonutils.NewDict()
+			ret.Add(jsonutils.NewString(string(*n.result)), ruleKeyResult)
+			ret.Add(n.conditions.Encode(), ruleKeyConditions)
+			return ret
+		}
 		return jsonutils.NewString(string(*n.result))
 	} else {
 		result = jsonutils.NewDict()
@@ -270,6 +282,15 @@ func (n *sRbacNode) json() jsonutils.JSONObject {
 	return result
 }
 
+func parseRuleResult(ruleStr string) TRbacResult {
+	switch ruleStr {
+	case string(Allow), string(AdminAllow), string(OwnerAllow), string(UserAllow), string(GuestAllow):
+		return Allow
+	default:
+		return Deny
+	}
+}
+
 func (n *sRbacNode) parseJson(input jsonutils.JSONObject) error {
 	switch val := input.(type) {
 	case *jsonutils.JSONString:
@@ -277,15 +298,21 @@ func (n *sRbacNode) parseJson(input jsonutils.JSONObject) error {
 		if err != nil {
 			return errors.Wrap(err, "val.GetString")
 		}
-		var result TRbacResult
-		switch ruleStr {
-		case string(Allow), string(AdminAllow), string(OwnerAllow), string(UserAllow), string(GuestAllow):
-			result = Allow
-		default:
-			result = Deny
-		}
+		result := parseRuleResult(ruleStr)
 		n.result = &result
 	case *jsonutils.JSONDict:
+		if isConditionalRule(val) {
+			ruleStr, _ := val.GetString(ruleKeyResult)
+			condJson, _ := val.Get(ruleKeyConditions)
+			conds, err := DecodeConditions(condJson)
+			if err != nil {
+				return errors.Wrap(err, "DecodeConditions")
+			}
+			result := parseRuleResult(ruleStr)
+			n.result = &result
+			n.conditions = conds
+			return nil
+		}
 		ruleJsonDict, err := val.GetMap()
 		if err != nil {
 			return errors.Wrap(err, "val.GetMap")
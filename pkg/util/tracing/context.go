@@ -0,0 +1,178 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// TRACEPARENT_HEADER is the W3C trace context header used by OpenTelemetry
+	TRACEPARENT_HEADER = "traceparent"
+
+	traceparentVersion = "00"
+	flagSampled        = "01"
+	flagNotSampled     = "00"
+)
+
+const (
+	ErrInvalidTraceparent = errors.Error("InvalidTraceparent")
+)
+
+type tracingContextKey string
+
+const (
+	contextKeySpan         = tracingContextKey("span")
+	contextKeyRemoteParent = tracingContextKey("remoteparent")
+)
+
+// SSpanContext is the part of a span propagated across the processes
+type SSpanContext struct {
+	TraceId string
+	SpanId  string
+	Sampled bool
+}
+
+func (sc SSpanContext) IsValid() bool {
+	return len(sc.TraceId) == 32 && len(sc.SpanId) == 16
+}
+
+// Traceparent formats the span context as the value of the traceparent header
+func (sc SSpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := flagNotSampled
+	if sc.Sampled {
+		flags = flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, sc.TraceId, sc.SpanId, flags)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func isAllZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// ParseTraceparent parses the value of the traceparent header
+func ParseTraceparent(val string) (SSpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(val), "-")
+	if len(parts) < 4 {
+		return SSpanContext{}, errors.Wrapf(ErrInvalidTraceparent, "traceparent %q", val)
+	}
+	version, traceId, spanId, flags := parts[0], parts[1], parts[2], parts[3]
+	// future versions may append fields, version 00 must have exactly 4
+	if len(version) != 2 || !isHex(version) || version == "ff" || (version == traceparentVersion && len(parts) != 4) {
+		return SSpanContext{}, errors.Wrapf(ErrInvalidTraceparent, "traceparent version %q", version)
+	}
+	if len(traceId) != 32 || !isHex(traceId) || isAllZero(traceId) {
+		return SSpanContext{}, errors.Wrapf(ErrInvalidTraceparent, "trace id %q", traceId)
+	}
+	if len(spanId) != 16 || !isHex(spanId) || isAllZero(spanId) {
+		return SSpanContext{}, errors.Wrapf(ErrInvalidTraceparent, "span id %q", spanId)
+	}
+	flagBytes, err := hex.DecodeString(flags)
+	if err != nil || len(flagBytes) != 1 {
+		return SSpanContext{}, errors.Wrapf(ErrInvalidTraceparent, "trace flags %q", flags)
+	}
+	return SSpanContext{
+		TraceId: strings.ToLower(traceId),
+		SpanId:  strings.ToLower(spanId),
+		Sampled: flagBytes[0]&0x01 != 0,
+	}, nil
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("read random: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func newTraceId() string {
+	return randomHex(16)
+}
+
+func newSpanId() string {
+	return randomHex(8)
+}
+
+// SpanFromContext returns the span started in the process, nil if none
+func SpanFromContext(ctx context.Context) *SSpan {
+	if ctx == nil {
+		return nil
+	}
+	if span, ok := ctx.Value(contextKeySpan).(*SSpan); ok {
+		return span
+	}
+	return nil
+}
+
+// ContextWithRemoteParent makes the spans started from the context the
+// children of a span of another process, e.g. the one saved in a task
+func ContextWithRemoteParent(ctx context.Context, sc SSpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKeyRemoteParent, sc)
+}
+
+// SpanContextFromContext returns the context of the current span, or that of
+// the remote parent if no span is started in the process
+func SpanContextFromContext(ctx context.Context) SSpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context()
+	}
+	if ctx == nil {
+		return SSpanContext{}
+	}
+	if sc, ok := ctx.Value(contextKeyRemoteParent).(SSpanContext); ok {
+		return sc
+	}
+	return SSpanContext{}
+}
+
+// Inject sets the traceparent header of an outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	if tp := SpanContextFromContext(ctx).Traceparent(); len(tp) > 0 {
+		header.Set(TRACEPARENT_HEADER, tp)
+	}
+}
+
+// Extract returns the context with the span context of an incoming request
+// as the remote parent, an invalid traceparent is ignored
+func Extract(ctx context.Context, header http.Header) context.Context {
+	val := header.Get(TRACEPARENT_HEADER)
+	if len(val) == 0 {
+		return ctx
+	}
+	sc, err := ParseTraceparent(val)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteParent(ctx, sc)
+}
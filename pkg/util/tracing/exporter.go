@@ -0,0 +1,319 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	exporterQueueSize     = 4096
+	exporterBatchSize     = 512
+	exporterFlushInterval = 5 * time.Second
+	exporterTimeout       = 10 * time.Second
+
+	instrumentationScope = "yunion.io/x/onecloud"
+)
+
+// SExporterOptions configures the exporter of the spans
+type SExporterOptions struct {
+	// Endpoint is the base url of the OTLP/HTTP receiver, e.g.
+	// http://otel-collector:4318, the spans are posted to /v1/traces
+	Endpoint    string
+	ServiceName string
+	// SampleRatio is the ratio of the new traces to export, the traces
+	// started by the callers follow the decisions of the callers
+	SampleRatio float64
+	Headers     map[string]string
+}
+
+// sOtlpExporter posts the spans in batches in the OTLP/HTTP JSON encoding,
+// spans are dropped if the receiver cannot keep up
+type sOtlpExporter struct {
+	url         string
+	serviceName string
+	sampleRatio float64
+	headers     map[string]string
+	client      *http.Client
+
+	queue   chan *SSpan
+	dropped int64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var (
+	exporter     *sOtlpExporter
+	exporterLock sync.RWMutex
+)
+
+func getExporter() *sOtlpExporter {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+
+	return exporter
+}
+
+// Init starts exporting the spans, the later calls are ignored
+func Init(opts SExporterOptions) error {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	if exporter != nil {
+		return nil
+	}
+	if len(opts.Endpoint) == 0 {
+		return errors.Wrap(errors.ErrNotFound, "empty endpoint")
+	}
+	ratio := opts.SampleRatio
+	if ratio < 0 || ratio > 1 {
+		return errors.Wrapf(errors.ErrNotSupported, "sample ratio %f out of [0, 1]", ratio)
+	}
+	exp := &sOtlpExporter{
+		url:         strings.TrimRight(opts.Endpoint, "/") + "/v1/traces",
+		serviceName: opts.ServiceName,
+		sampleRatio: ratio,
+		headers:     opts.Headers,
+		client:      &http.Client{Timeout: exporterTimeout},
+		queue:       make(chan *SSpan, exporterQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go exp.run()
+	exporter = exp
+	return nil
+}
+
+// Shutdown flushes the queued spans and stops exporting
+func Shutdown() {
+	exporterLock.Lock()
+	exp := exporter
+	exporter = nil
+	exporterLock.Unlock()
+
+	if exp != nil {
+		close(exp.stop)
+		<-exp.done
+	}
+}
+
+func (exp *sOtlpExporter) shouldSample() bool {
+	return exp.sampleRatio >= 1 || rand.Float64() < exp.sampleRatio
+}
+
+func (exp *sOtlpExporter) submit(span *SSpan) {
+	select {
+	case exp.queue <- span:
+	default:
+		if dropped := atomic.AddInt64(&exp.dropped, 1); dropped%1000 == 1 {
+			log.Warningf("tracing: queue full, %d spans dropped", dropped)
+		}
+	}
+}
+
+func (exp *sOtlpExporter) run() {
+	defer close(exp.done)
+
+	ticker := time.NewTicker(exporterFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SSpan, 0, exporterBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := exp.export(batch); err != nil {
+			log.Errorf("tracing: export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-exp.queue:
+			batch = append(batch, span)
+			if len(batch) >= exporterBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-exp.stop:
+			for {
+				select {
+				case span := <-exp.queue:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (exp *sOtlpExporter) export(spans []*SSpan) error {
+	body, err := json.Marshal(exp.encode(spans))
+	if err != nil {
+		return errors.Wrap(err, "marshal spans")
+	}
+	req, err := http.NewRequest(http.MethodPost, exp.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range exp.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := exp.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "post spans")
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("receiver responds %s", resp.Status)
+	}
+	return nil
+}
+
+// the OTLP JSON encoding, ids are hex strings and the 64 bits integers are
+// decimal strings
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTracesRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	otlpStatusUnset = 0
+	otlpStatusError = 2
+)
+
+func otlpValue(val interface{}) otlpAnyValue {
+	switch v := val.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprintf("%v", v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ret := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		ret[i] = otlpKeyValue{Key: k, Value: otlpValue(attrs[k])}
+	}
+	return ret
+}
+
+func (exp *sOtlpExporter) encode(spans []*SSpan) otlpTracesRequest {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, len(spans))}
+	scope.Scope.Name = instrumentationScope
+	for i, span := range spans {
+		span.lock.Lock()
+		s := otlpSpan{
+			TraceId:           span.context.TraceId,
+			SpanId:            span.context.SpanId,
+			ParentSpanId:      span.parentId,
+			Name:              span.name,
+			Kind:              int(span.kind),
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+			Status:            otlpStatus{Code: otlpStatusUnset},
+		}
+		if span.isError {
+			s.Status = otlpStatus{Code: otlpStatusError, Message: span.errMsg}
+		}
+		span.lock.Unlock()
+		scope.Spans[i] = s
+	}
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = otlpAttributes(map[string]interface{}{
+		"service.name": exp.serviceName,
+	})
+	return otlpTracesRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type TSpanKind int
+
+// the values are those of the OTLP span kinds
+const (
+	SPAN_KIND_INTERNAL = TSpanKind(1)
+	SPAN_KIND_SERVER   = TSpanKind(2)
+	SPAN_KIND_CLIENT   = TSpanKind(3)
+)
+
+// SSpan is a span of the process. The methods are no-op on a nil span, so
+// the callers never check whether tracing is turned on
+type SSpan struct {
+	context  SSpanContext
+	parentId string
+	name     string
+	kind     TSpanKind
+
+	lock       sync.Mutex
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	errMsg     string
+	isError    bool
+}
+
+// StartSpan starts a child span of the span or the remote parent in ctx.
+// Without a parent, a new trace is started only if an exporter is
+// initialized, otherwise nil is returned and ctx is left untouched
+func StartSpan(ctx context.Context, name string, kind TSpanKind) (context.Context, *SSpan) {
+	parent := SpanContextFromContext(ctx)
+	exp := getExporter()
+	sc := SSpanContext{
+		SpanId: newSpanId(),
+	}
+	if parent.IsValid() {
+		sc.TraceId = parent.TraceId
+		sc.Sampled = parent.Sampled
+	} else if exp != nil {
+		sc.TraceId = newTraceId()
+		sc.Sampled = exp.shouldSample()
+	} else {
+		return ctx, nil
+	}
+	span := &SSpan{
+		context:  sc,
+		parentId: parent.SpanId,
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+	}
+	return context.WithValue(ctx, contextKeySpan, span), span
+}
+
+// StartServerSpan starts the span of an incoming request, the child of the
+// span of the caller if the request carries a traceparent
+func StartServerSpan(ctx context.Context, r *http.Request, name string) (context.Context, *SSpan) {
+	ctx, span := StartSpan(Extract(ctx, r.Header), name, SPAN_KIND_SERVER)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+	return ctx, span
+}
+
+func (span *SSpan) Context() SSpanContext {
+	if span == nil {
+		return SSpanContext{}
+	}
+	return span.context
+}
+
+func (span *SSpan) SetAttribute(key string, val interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	if span.attributes == nil {
+		span.attributes = make(map[string]interface{})
+	}
+	span.attributes[key] = val
+}
+
+// SetError marks the span failed
+func (span *SSpan) SetError(msg string, params ...interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	span.isError = true
+	if len(params) > 0 {
+		msg = fmt.Sprintf(msg, params...)
+	}
+	span.errMsg = msg
+}
+
+// SetStatusCode records the http status of the request and marks the span
+// failed on server errors
+func (span *SSpan) SetStatusCode(code int) {
+	span.SetAttribute("http.status_code", code)
+	if code >= 500 {
+		span.SetError(http.StatusText(code))
+	}
+}
+
+// End ends the span and exports it if sampled, ending a span twice is no-op
+func (span *SSpan) End() {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	if !span.end.IsZero() {
+		span.lock.Unlock()
+		return
+	}
+	span.end = time.Now()
+	span.lock.Unlock()
+
+	if !span.context.Sampled {
+		return
+	}
+	if exp := getExporter(); exp != nil {
+		exp.submit(span)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    SSpanContext
+		wantErr bool
+	}{
+		{
+			in:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want: SSpanContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: true},
+		},
+		{
+			in:   "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-00",
+			want: SSpanContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7"},
+		},
+		{
+			in:   "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-future",
+			want: SSpanContext{TraceId: "4bf92f3577b34da6a3ce929d0e0e4736", SpanId: "00f067aa0ba902b7", Sampled: true},
+		},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{in: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", wantErr: true},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", wantErr: true},
+		{in: "00-xbf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseTraceparent(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%q: expect error", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%q: want %#v, got %#v", c.in, c.want, got)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "root", SPAN_KIND_SERVER)
+	if span != nil {
+		t.Fatalf("no span without parent and exporter")
+	}
+	if ctx != context.Background() {
+		t.Errorf("context changed without span")
+	}
+
+	header := http.Header{}
+	header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r := httptest.NewRequest("GET", "/servers", nil)
+	r.Header = header
+	ctx, span = StartServerSpan(context.Background(), r, "GET list")
+	sc := span.Context()
+	if sc.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId == "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("server span %#v", sc)
+	}
+	if span.parentId != "00f067aa0ba902b7" {
+		t.Errorf("want parent of the caller, got %s", span.parentId)
+	}
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if want := sc.Traceparent(); out.Get(TRACEPARENT_HEADER) != want {
+		t.Errorf("inject: want %s, got %s", want, out.Get(TRACEPARENT_HEADER))
+	}
+
+	// e.g. the context of a task restored from the database
+	restored := ContextWithRemoteParent(context.Background(), sc)
+	_, child := StartSpan(restored, "stage", SPAN_KIND_INTERNAL)
+	if child.Context().TraceId != sc.TraceId || child.parentId != sc.SpanId {
+		t.Errorf("child %#v of %#v", child.Context(), sc)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	received := make(chan otlpTracesRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Tenant") != "demo" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		req := otlpTracesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received <- req
+	}))
+	defer srv.Close()
+
+	err := Init(SExporterOptions{
+		Endpoint:    srv.URL + "/",
+		ServiceName: "region",
+		SampleRatio: 1,
+		Headers:     map[string]string{"X-Tenant": "demo"},
+	})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	ctx, root := StartSpan(context.Background(), "POST servers", SPAN_KIND_SERVER)
+	_, child := StartSpan(ctx, "GuestCreateTask.on_init", SPAN_KIND_INTERNAL)
+	child.SetAttribute("task.id", "abc")
+	child.SetError("stage %s failed", "on_init")
+	child.End()
+	child.End()
+	root.End()
+	Shutdown()
+
+	req := <-received
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %#v", req)
+	}
+	attrs := req.ResourceSpans[0].Resource.Attributes
+	if len(attrs) != 1 || *attrs[0].Value.StringValue != "region" {
+		t.Errorf("resource attributes %#v", attrs)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	if spans[0].ParentSpanId != spans[1].SpanId || spans[0].TraceId != spans[1].TraceId {
+		t.Errorf("child %#v of root %#v", spans[0], spans[1])
+	}
+	if spans[0].Status.Code != otlpStatusError || spans[0].Kind != int(SPAN_KIND_INTERNAL) {
+		t.Errorf("child %#v", spans[0])
+	}
+	if len(spans[0].Attributes) != 1 || spans[0].Attributes[0].Key != "task.id" {
+		t.Errorf("child attributes %#v", spans[0].Attributes)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthnutils
+
+import (
+	"encoding/binary"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// a minimal CBOR (RFC 7049) decoder for the attestation objects and COSE keys,
+// only the definite length items used by webauthn are supported:
+//   unsigned and negative integers are decoded as int64
+//   byte strings as []byte, text strings as string
+//   arrays as []interface{}, maps as map[interface{}]interface{}
+//   false, true and null as bool and nil
+
+const (
+	cborMaxDepth = 16
+
+	cborTypeUint   = 0
+	cborTypeNegInt = 1
+	cborTypeBytes  = 2
+	cborTypeText   = 3
+	cborTypeArray  = 4
+	cborTypeMap    = 5
+	cborTypeTag    = 6
+	cborTypeSimple = 7
+)
+
+// cborDecode decodes the first item of data and returns the bytes left
+func cborDecode(data []byte) (interface{}, []byte, error) {
+	return cborDecodeItem(data, 0)
+}
+
+func cborDecodeHead(data []byte) (byte, uint64, []byte, error) {
+	if len(data) == 0 {
+		return 0, 0, nil, errors.Wrap(ErrInvalidResponse, "cbor: unexpected end of data")
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+	switch {
+	case info < 24:
+		return major, uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			break
+		}
+		return major, uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			break
+		}
+		return major, uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			break
+		}
+		return major, uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			break
+		}
+		return major, binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, 0, nil, errors.Wrapf(ErrInvalidResponse, "cbor: unsupported additional information %d", info)
+	}
+	return 0, 0, nil, errors.Wrap(ErrInvalidResponse, "cbor: unexpected end of data")
+}
+
+func cborDecodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: nested too deep")
+	}
+	major, val, data, err := cborDecodeHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case cborTypeUint:
+		if val > 1<<63-1 {
+			return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: integer overflow")
+		}
+		return int64(val), data, nil
+	case cborTypeNegInt:
+		if val > 1<<63-1 {
+			return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: integer overflow")
+		}
+		return -1 - int64(val), data, nil
+	case cborTypeBytes, cborTypeText:
+		if val > uint64(len(data)) {
+			return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: unexpected end of data")
+		}
+		if major == cborTypeText {
+			return string(data[:val]), data[val:], nil
+		}
+		return data[:val], data[val:], nil
+	case cborTypeArray:
+		if val > uint64(len(data)) {
+			return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: unexpected end of data")
+		}
+		ret := make([]interface{}, 0, val)
+		for i := uint64(0); i < val; i++ {
+			var item interface{}
+			item, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			ret = append(ret, item)
+		}
+		return ret, data, nil
+	case cborTypeMap:
+		if val > uint64(len(data)) {
+			return nil, nil, errors.Wrap(ErrInvalidResponse, "cbor: unexpected end of data")
+		}
+		ret := make(map[interface{}]interface{}, val)
+		for i := uint64(0); i < val; i++ {
+			var k, v interface{}
+			k, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.Wrapf(ErrInvalidResponse, "cbor: unsupported map key type %T", k)
+			}
+			v, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			ret[k] = v
+		}
+		return ret, data, nil
+	case cborTypeTag:
+		// tags carry no meaning in webauthn structures, return the tagged item
+		return cborDecodeItem(data, depth+1)
+	case cborTypeSimple:
+		switch val {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		}
+		return nil, nil, errors.Wrapf(ErrInvalidResponse, "cbor: unsupported simple value %d", val)
+	}
+	return nil, nil, errors.Wrapf(ErrInvalidResponse, "cbor: unsupported major type %d", major)
+}
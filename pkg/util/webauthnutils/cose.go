@@ -0,0 +1,150 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthnutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// COSE key parameters, https://tools.ietf.org/html/rfc8152#section-7
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+
+	coseKeyCrv = -1
+	coseKeyX   = -2
+	coseKeyY   = -3
+
+	coseKeyN = -1
+	coseKeyE = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+type sCosePublicKey struct {
+	Alg int64
+	Key crypto.PublicKey
+}
+
+func coseInt(m map[interface{}]interface{}, key int64) (int64, bool) {
+	v, ok := m[key].(int64)
+	return v, ok
+}
+
+func coseBytes(m map[interface{}]interface{}, key int64) ([]byte, bool) {
+	v, ok := m[key].([]byte)
+	return v, ok && len(v) > 0
+}
+
+func parseCosePublicKey(data []byte) (*sCosePublicKey, error) {
+	obj, _, err := cborDecode(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode COSE key")
+	}
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Wrap(ErrUnsupportedKey, "COSE key is not a map")
+	}
+	kty, _ := coseInt(m, coseKeyKty)
+	alg, _ := coseInt(m, coseKeyAlg)
+	ret := &sCosePublicKey{Alg: alg}
+	switch {
+	case kty == coseKtyEC2 && alg == COSE_ALG_ES256:
+		crv, _ := coseInt(m, coseKeyCrv)
+		x, okx := coseBytes(m, coseKeyX)
+		y, oky := coseBytes(m, coseKeyY)
+		if crv != coseCrvP256 || !okx || !oky {
+			return nil, errors.Wrapf(ErrUnsupportedKey, "invalid EC2 key, crv %d", crv)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.Wrap(ErrUnsupportedKey, "EC2 point not on curve")
+		}
+		ret.Key = pub
+	case kty == coseKtyOKP && alg == COSE_ALG_EDDSA:
+		crv, _ := coseInt(m, coseKeyCrv)
+		x, okx := coseBytes(m, coseKeyX)
+		if crv != coseCrvEd25519 || !okx || len(x) != ed25519.PublicKeySize {
+			return nil, errors.Wrapf(ErrUnsupportedKey, "invalid OKP key, crv %d", crv)
+		}
+		ret.Key = ed25519.PublicKey(x)
+	case kty == coseKtyRSA && alg == COSE_ALG_RS256:
+		n, okn := coseBytes(m, coseKeyN)
+		e, oke := coseBytes(m, coseKeyE)
+		if !okn || !oke || len(e) > 4 {
+			return nil, errors.Wrap(ErrUnsupportedKey, "invalid RSA key")
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.Wrapf(ErrUnsupportedKey, "RSA key of %d bits too short", pub.N.BitLen())
+		}
+		ret.Key = pub
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedKey, "kty %d alg %d", kty, alg)
+	}
+	return ret, nil
+}
+
+type sEcdsaSignature struct {
+	R, S *big.Int
+}
+
+func (key *sCosePublicKey) verify(data []byte, sig []byte) error {
+	switch pub := key.Key.(type) {
+	case *ecdsa.PublicKey:
+		esig := sEcdsaSignature{}
+		rest, err := asn1.Unmarshal(sig, &esig)
+		if err != nil || len(rest) > 0 {
+			return errors.Wrap(ErrInvalidSignature, "malformed ECDSA signature")
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.Verify(pub, digest[:], esig.R, esig.S) {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, data, sig) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return errors.Wrap(ErrInvalidSignature, err.Error())
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthnutils
+
+import (
+	"yunion.io/x/pkg/errors"
+)
+
+// https://www.w3.org/TR/webauthn-2/
+
+const (
+	CEREMONY_CREATE = "webauthn.create"
+	CEREMONY_GET    = "webauthn.get"
+
+	PUBLIC_KEY_CREDENTIAL_TYPE = "public-key"
+
+	USER_VERIFICATION_REQUIRED    = "required"
+	USER_VERIFICATION_PREFERRED   = "preferred"
+	USER_VERIFICATION_DISCOURAGED = "discouraged"
+
+	ATTESTATION_NONE = "none"
+
+	COSE_ALG_ES256 = -7
+	COSE_ALG_EDDSA = -8
+	COSE_ALG_RS256 = -257
+
+	CHALLENGE_LENGTH = 32
+)
+
+const (
+	ErrInvalidResponse   = errors.Error("invalid authenticator response")
+	ErrChallengeMismatch = errors.Error("challenge mismatch")
+	ErrOriginMismatch    = errors.Error("origin mismatch")
+	ErrRpIdMismatch      = errors.Error("relying party id mismatch")
+	ErrUserNotPresent    = errors.Error("user not present")
+	ErrUserNotVerified   = errors.Error("user not verified")
+	ErrInvalidSignature  = errors.Error("invalid signature")
+	ErrSignCount         = errors.Error("signature counter not increased, the authenticator may be cloned")
+	ErrUnsupportedKey    = errors.Error("unsupported public key")
+)
+
+var (
+	// the algorithms supported, in the order of preference
+	SupportedAlgorithms = []int{COSE_ALG_ES256, COSE_ALG_EDDSA, COSE_ALG_RS256}
+)
+
+type SRelyingPartyEntity struct {
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type SUserEntity struct {
+	// base64url encoded user handle
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type SCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type SCredentialDescriptor struct {
+	Type string `json:"type"`
+	// base64url encoded credential id
+	Id         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type SAuthenticatorSelection struct {
+	AuthenticatorAttachment string `json:"authenticatorAttachment,omitempty"`
+	RequireResidentKey      bool   `json:"requireResidentKey"`
+	UserVerification        string `json:"userVerification,omitempty"`
+}
+
+// SCreationOptions is the publicKey option of navigator.credentials.create()
+type SCreationOptions struct {
+	Rp                     SRelyingPartyEntity     `json:"rp"`
+	User                   SUserEntity             `json:"user"`
+	Challenge              string                  `json:"challenge"`
+	PubKeyCredParams       []SCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                     `json:"timeout,omitempty"`
+	ExcludeCredentials     []SCredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection SAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                  `json:"attestation,omitempty"`
+}
+
+// SRequestOptions is the publicKey option of navigator.credentials.get()
+type SRequestOptions struct {
+	Challenge        string                  `json:"challenge"`
+	Timeout          int                     `json:"timeout,omitempty"`
+	RpId             string                  `json:"rpId,omitempty"`
+	AllowCredentials []SCredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                  `json:"userVerification,omitempty"`
+}
+
+// SAuthenticatorResponse holds the base64url encoded fields of
+// AuthenticatorAttestationResponse or AuthenticatorAssertionResponse
+type SAuthenticatorResponse struct {
+	ClientDataJSON string `json:"clientDataJSON"`
+
+	// attestation
+	AttestationObject string `json:"attestationObject,omitempty"`
+
+	// assertion
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// SPublicKeyCredential is the PublicKeyCredential returned by the browser
+type SPublicKeyCredential struct {
+	Id       string                 `json:"id"`
+	RawId    string                 `json:"rawId"`
+	Type     string                 `json:"type"`
+	Response SAuthenticatorResponse `json:"response"`
+}
+
+type SCollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// SCredential is the credential registered by an authenticator
+type SCredential struct {
+	Id []byte
+	// COSE_Key encoded credential public key
+	PublicKey []byte
+	SignCount uint32
+	Aaguid    []byte
+}
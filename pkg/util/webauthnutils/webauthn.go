@@ -0,0 +1,326 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthnutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	authDataFlagUserPresent  = 0x01
+	authDataFlagUserVerified = 0x04
+	authDataFlagAttested     = 0x40
+
+	authDataMinLength = 37
+	aaguidLength      = 16
+)
+
+// SRelyingParty describes the relying party verifying the ceremonies
+type SRelyingParty struct {
+	Id   string
+	Name string
+	// CheckOrigin reports whether the origin collected by the browser is
+	// the origin of the relying party
+	CheckOrigin func(origin string) bool
+	// require the authenticator to verify the user, e.g. by PIN or biometrics
+	RequireUserVerification bool
+}
+
+type sAuthenticatorData struct {
+	raw       []byte
+	rpIdHash  []byte
+	flags     byte
+	signCount uint32
+
+	aaguid       []byte
+	credentialId []byte
+	publicKey    []byte
+}
+
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, CHALLENGE_LENGTH)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, errors.Wrap(err, "rand.Read")
+	}
+	return challenge, nil
+}
+
+func EncodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeBase64 decodes base64url strings with or without paddings, as well
+// as standard base64 strings
+func DecodeBase64(str string) ([]byte, error) {
+	str = strings.TrimRight(str, "=")
+	if strings.ContainsAny(str, "+/") {
+		return base64.RawStdEncoding.DecodeString(str)
+	}
+	return base64.RawURLEncoding.DecodeString(str)
+}
+
+func (rp *SRelyingParty) userVerification() string {
+	if rp.RequireUserVerification {
+		return USER_VERIFICATION_REQUIRED
+	}
+	return USER_VERIFICATION_PREFERRED
+}
+
+func credentialDescriptors(creds []SCredential) []SCredentialDescriptor {
+	ret := make([]SCredentialDescriptor, len(creds))
+	for i := range creds {
+		ret[i] = SCredentialDescriptor{
+			Type: PUBLIC_KEY_CREDENTIAL_TYPE,
+			Id:   EncodeBase64(creds[i].Id),
+		}
+	}
+	return ret
+}
+
+// NewCreationOptions returns the options of the registration ceremony,
+// the registered credentials are excluded to avoid registering an
+// authenticator twice
+func (rp *SRelyingParty) NewCreationOptions(challenge []byte, user SUserEntity, registered []SCredential, timeoutMs int) *SCreationOptions {
+	opts := &SCreationOptions{
+		Rp: SRelyingPartyEntity{
+			Id:   rp.Id,
+			Name: rp.Name,
+		},
+		User:               user,
+		Challenge:          EncodeBase64(challenge),
+		Timeout:            timeoutMs,
+		ExcludeCredentials: credentialDescriptors(registered),
+		AuthenticatorSelection: SAuthenticatorSelection{
+			UserVerification: rp.userVerification(),
+		},
+		Attestation: ATTESTATION_NONE,
+	}
+	for _, alg := range SupportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, SCredentialParameter{
+			Type: PUBLIC_KEY_CREDENTIAL_TYPE,
+			Alg:  alg,
+		})
+	}
+	return opts
+}
+
+// NewRequestOptions returns the options of the authentication ceremony
+func (rp *SRelyingParty) NewRequestOptions(challenge []byte, registered []SCredential, timeoutMs int) *SRequestOptions {
+	return &SRequestOptions{
+		Challenge:        EncodeBase64(challenge),
+		Timeout:          timeoutMs,
+		RpId:             rp.Id,
+		AllowCredentials: credentialDescriptors(registered),
+		UserVerification: rp.userVerification(),
+	}
+}
+
+func (rp *SRelyingParty) verifyClientData(data []byte, ceremony string, challenge []byte) error {
+	clientData := SCollectedClientData{}
+	err := json.Unmarshal(data, &clientData)
+	if err != nil {
+		return errors.Wrap(ErrInvalidResponse, "malformed clientDataJSON")
+	}
+	if clientData.Type != ceremony {
+		return errors.Wrapf(ErrInvalidResponse, "expect %s ceremony, got %s", ceremony, clientData.Type)
+	}
+	collected, err := DecodeBase64(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(collected, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+	if rp.CheckOrigin == nil || !rp.CheckOrigin(clientData.Origin) {
+		return errors.Wrapf(ErrOriginMismatch, "origin %s", clientData.Origin)
+	}
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (*sAuthenticatorData, error) {
+	if len(data) < authDataMinLength {
+		return nil, errors.Wrap(ErrInvalidResponse, "authenticator data too short")
+	}
+	ret := &sAuthenticatorData{
+		raw:       data,
+		rpIdHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ret.flags&authDataFlagAttested == 0 {
+		return ret, nil
+	}
+	rest := data[authDataMinLength:]
+	if len(rest) < aaguidLength+2 {
+		return nil, errors.Wrap(ErrInvalidResponse, "attested credential data too short")
+	}
+	ret.aaguid = rest[:aaguidLength]
+	idLen := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+	rest = rest[aaguidLength+2:]
+	if len(rest) < idLen {
+		return nil, errors.Wrap(ErrInvalidResponse, "credential id too short")
+	}
+	ret.credentialId = rest[:idLen]
+	rest = rest[idLen:]
+	// the public key is followed by the optional extensions
+	_, left, err := cborDecode(rest)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode credential public key")
+	}
+	ret.publicKey = rest[:len(rest)-len(left)]
+	return ret, nil
+}
+
+func (rp *SRelyingParty) verifyAuthenticatorData(authData *sAuthenticatorData) error {
+	rpIdHash := sha256.Sum256([]byte(rp.Id))
+	if !bytes.Equal(authData.rpIdHash, rpIdHash[:]) {
+		return ErrRpIdMismatch
+	}
+	if authData.flags&authDataFlagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if rp.RequireUserVerification && authData.flags&authDataFlagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// VerifyAttestation verifies the response of navigator.credentials.create()
+// and returns the registered credential. The options request no attestation,
+// so the attestation statement is not verified and the credential is trusted
+// on the first use as the TOTP secrets are.
+func (rp *SRelyingParty) VerifyAttestation(cred *SPublicKeyCredential, challenge []byte) (*SCredential, error) {
+	if cred.Type != PUBLIC_KEY_CREDENTIAL_TYPE {
+		return nil, errors.Wrapf(ErrInvalidResponse, "invalid credential type %s", cred.Type)
+	}
+	clientData, err := DecodeBase64(cred.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "decode clientDataJSON")
+	}
+	err = rp.verifyClientData(clientData, CEREMONY_CREATE, challenge)
+	if err != nil {
+		return nil, err
+	}
+	attObjBytes, err := DecodeBase64(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "decode attestationObject")
+	}
+	obj, _, err := cborDecode(attObjBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode attestationObject")
+	}
+	attObj, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidResponse, "attestationObject is not a map")
+	}
+	authDataBytes, ok := attObj["authData"].([]byte)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidResponse, "missing authData")
+	}
+	authData, err := parseAuthenticatorData(authDataBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if len(authData.credentialId) == 0 || len(authData.publicKey) == 0 {
+		return nil, errors.Wrap(ErrInvalidResponse, "no attested credential data")
+	}
+	_, err = parseCosePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SCredential{
+		Id:        append([]byte{}, authData.credentialId...),
+		PublicKey: append([]byte{}, authData.publicKey...),
+		SignCount: authData.signCount,
+		Aaguid:    append([]byte{}, authData.aaguid...),
+	}, nil
+}
+
+// FindCredential returns the index of the credential used by the assertion
+func FindCredential(cred *SPublicKeyCredential, registered []SCredential) int {
+	id, err := DecodeBase64(cred.RawId)
+	if err != nil || len(id) == 0 {
+		id, err = DecodeBase64(cred.Id)
+		if err != nil {
+			return -1
+		}
+	}
+	for i := range registered {
+		if bytes.Equal(registered[i].Id, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// VerifyAssertion verifies the response of navigator.credentials.get()
+// signed by the registered credential and returns the new signature counter
+func (rp *SRelyingParty) VerifyAssertion(cred *SPublicKeyCredential, challenge []byte, registered *SCredential) (uint32, error) {
+	if cred.Type != PUBLIC_KEY_CREDENTIAL_TYPE {
+		return 0, errors.Wrapf(ErrInvalidResponse, "invalid credential type %s", cred.Type)
+	}
+	clientData, err := DecodeBase64(cred.Response.ClientDataJSON)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidResponse, "decode clientDataJSON")
+	}
+	err = rp.verifyClientData(clientData, CEREMONY_GET, challenge)
+	if err != nil {
+		return 0, err
+	}
+	authDataBytes, err := DecodeBase64(cred.Response.AuthenticatorData)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidResponse, "decode authenticatorData")
+	}
+	authData, err := parseAuthenticatorData(authDataBytes)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.verifyAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+	sig, err := DecodeBase64(cred.Response.Signature)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidResponse, "decode signature")
+	}
+	pubKey, err := parseCosePublicKey(registered.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	signed := make([]byte, 0, len(authDataBytes)+len(clientDataHash))
+	signed = append(signed, authDataBytes...)
+	signed = append(signed, clientDataHash[:]...)
+	err = pubKey.verify(signed, sig)
+	if err != nil {
+		return 0, err
+	}
+	// authenticators not supporting the counter always return 0
+	if (authData.signCount > 0 || registered.SignCount > 0) && authData.signCount <= registered.SignCount {
+		return 0, errors.Wrapf(ErrSignCount, "got %d, stored %d", authData.signCount, registered.SignCount)
+	}
+	return authData.signCount, nil
+}
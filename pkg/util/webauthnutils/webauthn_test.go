@@ -0,0 +1,289 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webauthnutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+)
+
+// sSoftAuthenticator is a software authenticator with an ES256 key
+type sSoftAuthenticator struct {
+	rpId      string
+	origin    string
+	credId    []byte
+	key       *ecdsa.PrivateKey
+	signCount uint32
+}
+
+func cborHead(major byte, val int) []byte {
+	switch {
+	case val < 24:
+		return []byte{major<<5 | byte(val)}
+	case val < 256:
+		return []byte{major<<5 | 24, byte(val)}
+	default:
+		return []byte{major<<5 | 25, byte(val >> 8), byte(val)}
+	}
+}
+
+func cborInt(v int) []byte {
+	if v >= 0 {
+		return cborHead(cborTypeUint, v)
+	}
+	return cborHead(cborTypeNegInt, -1-v)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(cborTypeBytes, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(cborTypeText, len(s)), s...)
+}
+
+func newSoftAuthenticator(rpId, origin string) (*sSoftAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credId := make([]byte, 16)
+	rand.Read(credId)
+	return &sSoftAuthenticator{
+		rpId:   rpId,
+		origin: origin,
+		credId: credId,
+		key:    key,
+	}, nil
+}
+
+func (a *sSoftAuthenticator) coseKey() []byte {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+	ret := cborHead(cborTypeMap, 5)
+	ret = append(ret, cborInt(coseKeyKty)...)
+	ret = append(ret, cborInt(coseKtyEC2)...)
+	ret = append(ret, cborInt(coseKeyAlg)...)
+	ret = append(ret, cborInt(COSE_ALG_ES256)...)
+	ret = append(ret, cborInt(coseKeyCrv)...)
+	ret = append(ret, cborInt(coseCrvP256)...)
+	ret = append(ret, cborInt(coseKeyX)...)
+	ret = append(ret, cborBytes(x)...)
+	ret = append(ret, cborInt(coseKeyY)...)
+	ret = append(ret, cborBytes(y)...)
+	return ret
+}
+
+func (a *sSoftAuthenticator) authData(attested bool) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+	flags := byte(authDataFlagUserPresent | authDataFlagUserVerified)
+	if attested {
+		flags |= authDataFlagAttested
+	}
+	ret := append([]byte{}, rpIdHash[:]...)
+	ret = append(ret, flags)
+	cnt := make([]byte, 4)
+	binary.BigEndian.PutUint32(cnt, a.signCount)
+	ret = append(ret, cnt...)
+	if attested {
+		ret = append(ret, make([]byte, aaguidLength)...)
+		idLen := make([]byte, 2)
+		binary.BigEndian.PutUint16(idLen, uint16(len(a.credId)))
+		ret = append(ret, idLen...)
+		ret = append(ret, a.credId...)
+		ret = append(ret, a.coseKey()...)
+	}
+	return ret
+}
+
+func (a *sSoftAuthenticator) clientData(ceremony string, challenge string) []byte {
+	data, _ := json.Marshal(SCollectedClientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    a.origin,
+	})
+	return data
+}
+
+func (a *sSoftAuthenticator) create(opts *SCreationOptions) *SPublicKeyCredential {
+	attObj := cborHead(cborTypeMap, 3)
+	attObj = append(attObj, cborText("fmt")...)
+	attObj = append(attObj, cborText("none")...)
+	attObj = append(attObj, cborText("attStmt")...)
+	attObj = append(attObj, cborHead(cborTypeMap, 0)...)
+	attObj = append(attObj, cborText("authData")...)
+	attObj = append(attObj, cborBytes(a.authData(true))...)
+	return &SPublicKeyCredential{
+		Id:    EncodeBase64(a.credId),
+		RawId: EncodeBase64(a.credId),
+		Type:  PUBLIC_KEY_CREDENTIAL_TYPE,
+		Response: SAuthenticatorResponse{
+			ClientDataJSON:    EncodeBase64(a.clientData(CEREMONY_CREATE, opts.Challenge)),
+			AttestationObject: EncodeBase64(attObj),
+		},
+	}
+}
+
+func (a *sSoftAuthenticator) get(opts *SRequestOptions) (*SPublicKeyCredential, error) {
+	a.signCount += 1
+	authData := a.authData(false)
+	clientData := a.clientData(CEREMONY_GET, opts.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &SPublicKeyCredential{
+		Id:    EncodeBase64(a.credId),
+		RawId: EncodeBase64(a.credId),
+		Type:  PUBLIC_KEY_CREDENTIAL_TYPE,
+		Response: SAuthenticatorResponse{
+			ClientDataJSON:    EncodeBase64(clientData),
+			AuthenticatorData: EncodeBase64(authData),
+			Signature:         EncodeBase64(sig),
+		},
+	}, nil
+}
+
+func TestWebAuthnCeremonies(t *testing.T) {
+	rp := &SRelyingParty{
+		Id:   "cloud.example.com",
+		Name: "Onecloud",
+		CheckOrigin: func(origin string) bool {
+			return origin == "https://cloud.example.com"
+		},
+	}
+	authenticator, err := newSoftAuthenticator(rp.Id, "https://cloud.example.com")
+	if err != nil {
+		t.Fatalf("newSoftAuthenticator %s", err)
+	}
+
+	challenge, _ := NewChallenge()
+	createOpts := rp.NewCreationOptions(challenge, SUserEntity{Id: "dXNlcg", Name: "user"}, nil, 60000)
+	attestation := authenticator.create(createOpts)
+
+	// the response goes through the json body of the requests
+	attestation2 := new(SPublicKeyCredential)
+	err = jsonutils.Marshal(attestation).Unmarshal(attestation2)
+	if err != nil {
+		t.Fatalf("unmarshal attestation %s", err)
+	}
+	cred, err := rp.VerifyAttestation(attestation2, challenge)
+	if err != nil {
+		t.Fatalf("VerifyAttestation %s", err)
+	}
+
+	otherChallenge, _ := NewChallenge()
+	_, err = rp.VerifyAttestation(attestation2, otherChallenge)
+	if errors.Cause(err) != ErrChallengeMismatch {
+		t.Errorf("want challenge mismatch, got %v", err)
+	}
+
+	challenge, _ = NewChallenge()
+	getOpts := rp.NewRequestOptions(challenge, []SCredential{*cred}, 60000)
+	assertion, err := authenticator.get(getOpts)
+	if err != nil {
+		t.Fatalf("get %s", err)
+	}
+	if idx := FindCredential(assertion, []SCredential{*cred}); idx != 0 {
+		t.Fatalf("FindCredential want 0, got %d", idx)
+	}
+	cnt, err := rp.VerifyAssertion(assertion, challenge, cred)
+	if err != nil {
+		t.Fatalf("VerifyAssertion %s", err)
+	}
+	if cnt != 1 {
+		t.Errorf("want sign count 1, got %d", cnt)
+	}
+
+	// replaying the assertion fails the counter check
+	cred.SignCount = cnt
+	_, err = rp.VerifyAssertion(assertion, challenge, cred)
+	if errors.Cause(err) != ErrSignCount {
+		t.Errorf("want sign count error, got %v", err)
+	}
+
+	// tampered signature
+	assertion, _ = authenticator.get(getOpts)
+	sig, _ := DecodeBase64(assertion.Response.Signature)
+	sig[len(sig)-1] ^= 0xff
+	assertion.Response.Signature = EncodeBase64(sig)
+	_, err = rp.VerifyAssertion(assertion, challenge, cred)
+	if errors.Cause(err) != ErrInvalidSignature {
+		t.Errorf("want invalid signature, got %v", err)
+	}
+
+	// phishing origin
+	phishing, _ := newSoftAuthenticator(rp.Id, "https://cloud.example.com.evil.org")
+	_, err = rp.VerifyAttestation(phishing.create(createOpts), decodeChallenge(t, createOpts.Challenge))
+	if errors.Cause(err) != ErrOriginMismatch {
+		t.Errorf("want origin mismatch, got %v", err)
+	}
+
+	// authenticator bound to another relying party
+	other, _ := newSoftAuthenticator("example.org", "https://cloud.example.com")
+	_, err = rp.VerifyAttestation(other.create(createOpts), decodeChallenge(t, createOpts.Challenge))
+	if errors.Cause(err) != ErrRpIdMismatch {
+		t.Errorf("want rp id mismatch, got %v", err)
+	}
+}
+
+func decodeChallenge(t *testing.T, str string) []byte {
+	challenge, err := DecodeBase64(str)
+	if err != nil {
+		t.Fatalf("decode challenge %s", err)
+	}
+	return challenge
+}
+
+func TestCborDecode(t *testing.T) {
+	data := cborHead(cborTypeMap, 2)
+	data = append(data, cborInt(-257)...)
+	data = append(data, cborText("alg")...)
+	data = append(data, cborText("list")...)
+	data = append(data, cborHead(cborTypeArray, 2)...)
+	data = append(data, cborInt(1000)...)
+	data = append(data, 0xf5)
+	data = append(data, 0xff)
+
+	obj, left, err := cborDecode(data)
+	if err != nil {
+		t.Fatalf("cborDecode %s", err)
+	}
+	if len(left) != 1 || left[0] != 0xff {
+		t.Errorf("unexpected left bytes %x", left)
+	}
+	m := obj.(map[interface{}]interface{})
+	if m[int64(-257)] != "alg" {
+		t.Errorf("unexpected value of -257: %#v", m[int64(-257)])
+	}
+	list := m["list"].([]interface{})
+	if list[0] != int64(1000) || list[1] != true {
+		t.Errorf("unexpected list %#v", list)
+	}
+
+	if _, _, err := cborDecode(cborHead(cborTypeBytes, 10)); err == nil {
+		t.Errorf("truncated byte string should fail")
+	}
+}